@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the scan of the images inside a ZIP or tar archive passed to
+-file. The images are unpacked to a temporary directory, up to -archivemax MB,
+and analyzed like the files of a directory. Results are reported under the
+archive's path followed by the image's path inside it, such as
+photos.zip/holiday/beach.png.
+*/
+
+// analyzeArchive unpacks the images of an archive, analyzes them like a
+// directory scan and returns the results
+func analyzeArchive(archivePath string, limit int64, cfg *scanConfig) ([]models.AnalysisResult, error) {
+	unpacked, err := filehandler.UnpackImages(archivePath, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(unpacked.Dir)
+	if unpacked.Skipped > 0 {
+		printWarning("Stopped unpacking at the %dMB limit, %d images in the archive are not analyzed (see -archivemax)", limit>>20, unpacked.Skipped)
+	}
+
+	cfg.names = make(map[string]string, len(unpacked.Entries))
+	files := make([]string, 0, len(unpacked.Entries))
+	for _, entry := range unpacked.Entries {
+		cfg.names[entry.Path] = filepath.Join(archivePath, filepath.FromSlash(entry.Name))
+		files = append(files, entry.Path)
+	}
+	files = cfg.sampleInputs(files, "images")
+
+	printInfo("Found %d images to analyze", len(files))
+	return analyzeFiles(files, cfg), nil
+}
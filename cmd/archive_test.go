@@ -0,0 +1,67 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+)
+
+func TestAnalyzeArchive(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "bundle.zip")
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(file)
+	for _, name := range []string{"clean.png", "lsb_rgb.png"} {
+		data, err := fixtures.Load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry, err := w.Create("images/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	registry := analyzer.NewRegistry()
+	registerAnalyzers(registry)
+	cfg := &scanConfig{registry: registry, format: "auto", sequential: true}
+	results, err := analyzeArchive(archivePath, 1<<20, cfg)
+	if err != nil {
+		t.Fatalf("failed to analyze archive: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		sequential bool // Whether the sequential LSB payload should be found
+	}{
+		{"clean.png", false},
+		{"lsb_rgb.png", true},
+	}
+	if len(results) != len(tests) {
+		t.Fatalf("got %d results, want %d", len(results), len(tests))
+	}
+	for i, tt := range tests {
+		result := results[i]
+		if want := filepath.Join(archivePath, "images", tt.name); result.Filename != want {
+			t.Errorf("result %d is for %s, want %s", i, result.Filename, want)
+		}
+		found := false
+		for _, hint := range result.ExtractionHints {
+			found = found || hint.Algorithm == "lsb-sequential"
+		}
+		if found != tt.sequential {
+			t.Errorf("%s: sequential LSB hint %v, want %v", tt.name, found, tt.sequential)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/cache"
+	"DeSteGo/pkg/filehandler"
+)
+
+func TestCachedRescan(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"clean.png", "lsb_rgb.png", "clean.jpg", "double_eoi.jpg"} {
+		data, err := fixtures.Load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := filehandler.GatherFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := analyzer.NewRegistry()
+	registerAnalyzers(registry)
+	cacheDir := t.TempDir()
+	resultCache, err := cache.New(cacheDir, cacheVersion(registry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &scanConfig{registry: registry, format: "auto", sequential: true, cache: resultCache}
+
+	var first, second []byte
+	captureStdout(t, func() {
+		if first, err = json.Marshal(analyzeFiles(files, cfg)); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if hits := resultCache.Hits(); hits != 0 {
+		t.Fatalf("first pass had %d cache hits, want 0", hits)
+	}
+	captureStdout(t, func() {
+		if second, err = json.Marshal(analyzeFiles(files, cfg)); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if hits := resultCache.Hits(); hits != int64(len(files)) {
+		t.Errorf("second pass had %d cache hits, want %d", hits, len(files))
+	}
+	if string(first) != string(second) {
+		t.Errorf("cached results differ from the first pass:\nfirst  %s\nsecond %s", first, second)
+	}
+
+	// A cache opened for another build misses every entry
+	other, err := cache.New(cacheDir, cacheVersion(registry)+"-other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.cache = other
+	captureStdout(t, func() { analyzeFiles(files, cfg) })
+	if hits := other.Hits(); hits != 0 {
+		t.Errorf("a different build had %d cache hits, want 0", hits)
+	}
+}
+
+func TestCacheVersion(t *testing.T) {
+	registry := analyzer.NewRegistry()
+	registerAnalyzers(registry)
+	version := cacheVersion(registry)
+	if revision := buildRevision(); revision == "" {
+		t.Error("no build revision for the test binary")
+	} else if !strings.Contains(version, revision) {
+		t.Errorf("cache version %q does not hold the build revision %q", version, revision)
+	}
+
+	// A plugin's executable is part of the version, so replacing it
+	// invalidates the results it contributed to
+	script := filepath.Join(t.TempDir(), "plugin")
+	describe := "#!/bin/sh\necho '{\"name\": \"Test Plugin\", \"formats\": [\"png\"]}'\n"
+	withPlugin := func() string {
+		registry := analyzer.NewRegistry()
+		registerAnalyzers(registry)
+		registerPlugins(registry, filepath.Dir(script))
+		return cacheVersion(registry)
+	}
+	if err := os.WriteFile(script, []byte(describe), 0755); err != nil {
+		t.Fatal(err)
+	}
+	before := withPlugin()
+	if !strings.Contains(before, "Test Plugin@") {
+		t.Fatalf("cache version %q does not list the plugin", before)
+	}
+	if err := os.WriteFile(script, []byte(describe+"# changed\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if after := withPlugin(); after == before {
+		t.Errorf("cache version %q unchanged after the plugin changed", after)
+	}
+}
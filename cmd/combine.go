@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"DeSteGo/pkg/analyzer/image/lenient"
+	"DeSteGo/pkg/extractor"
+	lsbextractor "DeSteGo/pkg/extractor/image/lsb"
+	"DeSteGo/pkg/filehandler"
+)
+
+/*
+This file contains the -combine mode, which looks for a secret split across
+several images. The LSB planes of the images in a directory are XORed, all of
+them together and, for small sets, each pair on its own, and the LSB extractor
+reads the combined planes. A combination that gives text or a file with a known
+signature is reported and its payload saved to <outdir>/combined.
+*/
+
+// combinePairLimit is the most images whose pairs are combined on their own
+// besides the whole set
+const combinePairLimit = 8
+
+// combinePreviewLength is how much of a revealed text is printed
+const combinePreviewLength = 80
+
+// combinedImage is an image of a -combine set
+type combinedImage struct {
+	file string
+	img  image.Image
+}
+
+// printCombination combines the LSB planes of the images in dir and reports
+// the combinations that reveal data
+func printCombination(dir string, cfg *scanConfig) error {
+	files, err := filehandler.GatherFiles(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var images []combinedImage
+	for _, file := range files {
+		img, err := decodeCombined(file)
+		if err != nil {
+			printWarning("Skipping %s: %v", file, err)
+			continue
+		}
+		if len(images) > 0 {
+			size, first := img.Bounds().Size(), images[0].img.Bounds().Size()
+			if size != first {
+				return fmt.Errorf("%s is %dx%d, not %dx%d like %s; -combine needs images of identical dimensions",
+					file, size.X, size.Y, first.X, first.Y, images[0].file)
+			}
+		}
+		images = append(images, combinedImage{file: file, img: img})
+	}
+	if len(images) < 2 {
+		return fmt.Errorf("found %d decodable images in %s, -combine needs at least two", len(images), dir)
+	}
+
+	sets := [][]combinedImage{images}
+	if len(images) > 2 && len(images) <= combinePairLimit {
+		for i := range images {
+			for j := i + 1; j < len(images); j++ {
+				sets = append(sets, []combinedImage{images[i], images[j]})
+			}
+		}
+	}
+
+	fmt.Fprintln(cfg.output(), "\n=== Combination Summary ===")
+	fmt.Fprintf(cfg.output(), "Images combined: %d, combinations tried: %d\n", len(images), len(sets))
+	revealed := 0
+	for _, set := range sets {
+		found, err := combineSet(set, cfg)
+		if err != nil {
+			return err
+		}
+		revealed += found
+	}
+	if revealed == 0 {
+		printSuccess("No combination of the LSB planes reveals text or a known file type")
+	}
+	return nil
+}
+
+// combineSet XORs the LSB planes of a set of images, extracts from the result
+// and reports the payloads that are text or known files. It returns how many
+// it found.
+func combineSet(set []combinedImage, cfg *scanConfig) (int, error) {
+	images := make([]image.Image, len(set))
+	names := make([]string, len(set))
+	for i, c := range set {
+		images[i] = c.img
+		names[i] = filepath.Base(c.file)
+	}
+	combined, err := lsbextractor.XORPlanes(images)
+	if err != nil {
+		return 0, err
+	}
+
+	// The extractor saves every candidate, noise included, so only the revealed
+	// payloads are copied to the output directory
+	scratch, err := os.MkdirTemp("", "destego_combine_")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(scratch)
+
+	result, err := lsbextractor.NewLSBExtractor().ExtractFromImage(combined, extractor.ExtractionOptions{
+		OutputDir:    scratch,
+		Thresholds:   cfg.thresholds,
+		Workers:      cfg.lsbWorkers,
+		MemoryBudget: cfg.lsbMemory,
+	})
+	if err != nil {
+		return 0, nil // Nothing met the reporting thresholds
+	}
+
+	thresholds := cfg.thresholds.WithDefaults()
+	found := 0
+	for _, payload := range append(result.Alternates[:len(result.Alternates):len(result.Alternates)], result) {
+		if !lsbextractor.Revealed(payload.ExtractedData, thresholds.MinPrintable) {
+			continue
+		}
+		found++
+		kind, extension := payload.FileType, payload.FileType
+		if kind == "" {
+			kind, extension = "text", "txt"
+		}
+		printAlert("XOR of %s reveals %s with %s", strings.Join(names, " and "), kind, payload.Algorithm)
+		if kind == "text" {
+			text := lsbextractor.LeadingText(payload.ExtractedData)
+			if len(text) > combinePreviewLength {
+				text = text[:combinePreviewLength]
+			}
+			fmt.Fprintf(cfg.output(), "   Text: %q\n", text)
+		}
+		name := fmt.Sprintf("xor_%s_%s.%s", strings.Join(stems(names), "_"), payload.Algorithm, extension)
+		path, err := filehandler.SaveFileUnique(payload.ExtractedData, filepath.Join(cfg.outputDir, "combined", name))
+		if err != nil {
+			return found, err
+		}
+		fmt.Fprintf(cfg.output(), "   Saved to: %s\n", path)
+	}
+	return found, nil
+}
+
+// decodeCombined decodes an image of a -combine set
+func decodeCombined(file string) (image.Image, error) {
+	decoded, err := lenient.DecodeFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return decoded.Image, nil
+}
+
+// stems returns file names without their extensions
+func stems(names []string) []string {
+	out := make([]string, len(names))
+	for i, name := range names {
+		out[i] = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return out
+}
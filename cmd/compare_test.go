@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+)
+
+func TestCompareFiles(t *testing.T) {
+	const size, clean = 128, 20
+	tests := []struct {
+		name  string
+		share float64 // Share of the odd image's RGB LSBs holding random data, 0 for none
+	}{
+		{"all clean", 0},
+		{"quarter embedded", 0.25},
+		{"half embedded", 0.5},
+		{"fully embedded", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Twenty photo-like carriers and the odd one out, written last
+			dir := t.TempDir()
+			var files []string
+			for seed := int64(1); seed <= clean+1; seed++ {
+				img := fixtures.Carrier(size, size, seed)
+				if seed == clean+1 {
+					payload := fixtures.RandomPayload(int(tt.share*size*size*3/8), seed)
+					if err := fixtures.EmbedLSB(img, payload, []int{0, 1, 2}, 0); err != nil {
+						t.Fatal(err)
+					}
+				}
+				data, err := fixtures.Encode(img, "png")
+				if err != nil {
+					t.Fatal(err)
+				}
+				file := filepath.Join(dir, fmt.Sprintf("%02d.png", seed))
+				if err := os.WriteFile(file, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+				files = append(files, file)
+			}
+
+			images, _, _ := compareFiles(files, NewLogger(io.Discard))
+			if len(images) != clean+1 {
+				t.Fatalf("compared %d images, want %d", len(images), clean+1)
+			}
+			var outliers []string
+			for _, img := range images {
+				if img.zScore > compareOutlierSigma {
+					outliers = append(outliers, filepath.Base(img.file))
+				}
+			}
+			want := fmt.Sprintf("[%02d.png]", clean+1)
+			if tt.share == 0 {
+				want = "[]"
+			}
+			if got := fmt.Sprint(outliers); got != want {
+				t.Errorf("got outliers %s, want %s (highest %s at %.1f standard deviations)",
+					got, want, filepath.Base(images[0].file), images[0].zScore)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"DeSteGo/pkg/imghash"
+)
+
+// deduplicateFiles groups near-duplicate images by average hash and keeps one
+// representative per group. Files that cannot be decoded are always kept. The
+// returned map records, for every skipped file, the file it duplicates.
+func deduplicateFiles(files []string, threshold int) ([]string, map[string]string) {
+	var hashed []string
+	var hashes []uint64
+	var keep []string
+
+	for _, file := range files {
+		hash, err := imghash.HashFile(file)
+		if err != nil {
+			keep = append(keep, file)
+			continue
+		}
+		hashed = append(hashed, file)
+		hashes = append(hashes, hash)
+	}
+
+	skipped := make(map[string]string)
+	for i, rep := range imghash.Group(hashes, threshold) {
+		if rep == i {
+			keep = append(keep, hashed[i])
+		} else {
+			skipped[hashed[i]] = hashed[rep]
+		}
+	}
+
+	return keep, skipped
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"DeSteGo/pkg/toolexec"
+)
+
+/*
+This file contains the "doctor" subcommand. DeSteGo recommends external tools
+(steghide, outguess, jpseek, ...) when an analyzer detects their signatures, so
+the doctor reports which of them are installed, where, and which version, along
+with the PATH that was searched.
+*/
+
+// toolVersionTimeout bounds how long a tool may take to print its version
+const toolVersionTimeout = 5 * time.Second
+
+// externalTool is an optional program DeSteGo points users to
+type externalTool struct {
+	name        string
+	purpose     string
+	versionArgs []string
+}
+
+// externalTools lists the optional tools in the order they are reported
+var externalTools = []externalTool{
+	{"steghide", "StegHide extraction (JPEG, BMP, WAV, AU)", []string{"--version"}},
+	{"stegseek", "Fast StegHide passphrase cracking", []string{"--version"}},
+	{"outguess", "OutGuess extraction (JPEG)", []string{"-h"}},
+	{"jsteg", "JSteg extraction (JPEG)", []string{"--help"}},
+	{"jpseek", "JPHide extraction (JPEG)", nil},
+	{"zsteg", "LSB extraction (PNG, BMP)", []string{"--version"}},
+	{"binwalk", "Embedded file carving", []string{"--help"}},
+}
+
+// toolStatus is the result of checking one external tool
+type toolStatus struct {
+	tool    externalTool
+	path    string
+	version string
+	err     error
+}
+
+// checkTools locates every external tool and reads its version
+func checkTools() []toolStatus {
+	statuses := make([]toolStatus, 0, len(externalTools))
+	for _, tool := range externalTools {
+		status := toolStatus{tool: tool}
+		status.path, status.err = exec.LookPath(tool.name)
+		if status.err == nil && tool.versionArgs != nil {
+			status.version = toolVersion(status.path, tool.versionArgs)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// toolVersion returns the first line of output that mentions a version, or the
+// first line when none does. Many tools print their version on stderr and exit
+// non-zero for -h, so both streams are read and the exit status is ignored.
+func toolVersion(path string, args []string) string {
+	out, _ := toolexec.Run(context.Background(), toolVersionTimeout, nil, path, args...)
+	var first string
+	for _, line := range strings.Split(strings.TrimSpace(string(out.Stdout)+"\n"+string(out.Stderr)), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if first == "" {
+			first = line
+		}
+		if strings.Contains(strings.ToLower(line), "version") {
+			return line
+		}
+	}
+	return first
+}
+
+// runDoctorCommand implements "destego doctor"
+func runDoctorCommand(args []string) int {
+	if len(args) > 0 {
+		fmt.Println("Usage:")
+		fmt.Println("  destego doctor")
+		return exitError
+	}
+
+	printInfo("Checking optional external tools")
+	found := 0
+	for _, status := range checkTools() {
+		if status.err != nil {
+			printWarning("%-9s not found (%s)", status.tool.name, status.tool.purpose)
+			continue
+		}
+		found++
+		printSuccess("%-9s %s (%s)", status.tool.name, status.path, status.tool.purpose)
+		if status.version != "" {
+			fmt.Printf("    %s\n", status.version)
+		}
+	}
+
+	fmt.Println()
+	printInfo("Searched PATH:")
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		fmt.Printf("    %s\n", dir)
+	}
+	printInfo("%d of %d optional tools found. DeSteGo's own analysis does not need them.", found, len(externalTools))
+	return 0
+}
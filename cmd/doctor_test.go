@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCheckTools(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("installs shell scripts as tools")
+	}
+
+	// PATH holds only these scripts, so every other tool is missing
+	dir := t.TempDir()
+	scripts := map[string]string{
+		"steghide": "echo 'steghide version 0.5.1'",
+		"outguess": "echo 'OutGuess 0.4 Universal Stego (c) 1999-2001 Niels Provos' >&2; echo 'usage: outguess [options]' >&2; exit 1",
+		"zsteg":    "echo 'zsteg'; echo '0.2.13'",
+		"binwalk":  "exit 0",
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+
+	statuses := make(map[string]toolStatus)
+	for _, status := range checkTools() {
+		statuses[status.tool.name] = status
+	}
+	tests := []struct {
+		tool    string
+		found   bool
+		version string
+	}{
+		{"steghide", true, "steghide version 0.5.1"},
+		{"outguess", true, "OutGuess 0.4 Universal Stego (c) 1999-2001 Niels Provos"},
+		{"zsteg", true, "zsteg"},
+		{"binwalk", true, ""},
+		{"stegseek", false, ""},
+		{"jpseek", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			status, ok := statuses[tt.tool]
+			if !ok {
+				t.Fatalf("%s was not checked", tt.tool)
+			}
+			if found := status.err == nil; found != tt.found {
+				t.Fatalf("got found %v (%v), want %v", found, status.err, tt.found)
+			}
+			if tt.found && status.path != filepath.Join(dir, tt.tool) {
+				t.Errorf("got path %q, want %q", status.path, filepath.Join(dir, tt.tool))
+			}
+			if status.version != tt.version {
+				t.Errorf("got version %q, want %q", status.version, tt.version)
+			}
+		})
+	}
+}
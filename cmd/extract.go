@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"DeSteGo/pkg/analyzer"
+	jpeganalyzer "DeSteGo/pkg/analyzer/image/jpeg"
+	"DeSteGo/pkg/analyzer/image/lenient"
+	"DeSteGo/pkg/analyzer/stats"
+	"DeSteGo/pkg/c2"
+	"DeSteGo/pkg/extractor"
+	lsbextractor "DeSteGo/pkg/extractor/image/lsb"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+	"DeSteGo/pkg/rules"
+)
+
+// manifestEntry summarizes one extracted candidate
+type manifestEntry struct {
+	Extractor  string       `json:"extractor"`
+	Algorithm  string       `json:"algorithm"`
+	Confidence float64      `json:"confidence"`
+	Size       int          `json:"size"`
+	Entropy    float64      `json:"entropy"`
+	MimeType   string       `json:"mimeType"`
+	Files      []string     `json:"files"`
+	C2Commands []string     `json:"c2Commands,omitempty"`
+	Rules      []string     `json:"rules,omitempty"`
+	URLs       []c2.URLInfo `json:"urls,omitempty"`
+}
+
+// extractManifest is written to manifest.json by the extract command
+type extractManifest struct {
+	Input       string          `json:"input"`
+	Format      string          `json:"format"`
+	Created     time.Time       `json:"created"`
+	Candidates  []manifestEntry `json:"candidates"`
+	Attempts    []traceEntry    `json:"attempts,omitempty"`    // Every method tried, with -verbose or -trace
+	Interrupted bool            `json:"interrupted,omitempty"` // Whether Ctrl-C stopped the extractors before they all ran
+}
+
+// runExtractCommand implements "destego extract". It runs the analyzers for
+// their extraction hints, then every extractor registered for the file's format,
+// and writes each payload plus a manifest.json to the input's directory under
+// the output directory.
+func runExtractCommand(args []string) int {
+	fs := flag.NewFlagSet("extract", flag.ContinueOnError)
+	filePath := fs.String("file", "", "Path to the file to extract hidden data from (- for standard input)")
+	outputDir := fs.String("outdir", "destego_output", "Directory to write the extracted payloads and manifest.json to")
+	format := fs.String("format", "auto", "Force specific format (png, jpg, tiff)")
+	verbose := fs.Bool("verbose", false, "Enable verbose output")
+	resolveURLs := fs.Bool("resolveurls", false, "Send a HEAD request to each URL in a payload to learn what it serves (contacts the URL's host)")
+	cmdList := fs.String("cmdlist", "", "File of shell/PowerShell commands to look for in the payloads (default: built-in list)")
+	rulesFile := fs.String("rules", "", "JSON file of indicator rules to add to the built-in rules")
+	configFile := fs.String("config", "", "JSON file of detection thresholds that override the built-in ones")
+	lsbWorkers := fs.Int("lsbworkers", 0, "LSB extraction methods to run at once (default: one per CPU)")
+	lsbMemory := fs.Int("lsbmemory", 0, "Memory budget in MB shared by running LSB extraction methods (default: 256)")
+	dctOrder := fs.String("dctorder", "", "DCT block order for JSteg extraction: interleaved, luminance, chrominance or sequential (default: try all)")
+	stream := fs.Bool("stream", false, "Stream extracted LSB payloads to disk instead of holding them in memory")
+	traceFile := fs.String("trace", "", "Write every extraction attempt as JSON lines to this file")
+	password := fs.String("password", "", "Password for seeded LSB extraction, tried alongside the unkeyed methods")
+	outLayout := fs.String("outlayout", layoutInput, "Layout of the payloads in -outdir: input (a subdirectory per input) or flat")
+	pluginDir := fs.String("plugins", "", "Directory of external analyzer plugins whose extraction hints are used too")
+	if err := fs.Parse(args); err != nil {
+		return parseErrorCode(err)
+	}
+
+	if *filePath == "" {
+		fmt.Println("Usage:")
+		fmt.Println("  destego extract -file <filepath> [-outdir <directory>]")
+		fs.PrintDefaults()
+		return exitError
+	}
+
+	inputPath, cleanup, err := resolveInput(*filePath)
+	if err != nil {
+		printError("%v", err)
+		return exitError
+	}
+	defer cleanup()
+
+	analyzers := analyzer.NewRegistry()
+	registerAnalyzers(analyzers)
+	if *pluginDir != "" {
+		registerPlugins(analyzers, *pluginDir)
+	}
+
+	fileFormat := *format
+	if fileFormat == "auto" {
+		detected, err := filehandler.DetectFileFormat(inputPath)
+		if err != nil {
+			printError("Failed to detect file format: %s", describeFormatError(err, analyzers))
+			return exitError
+		}
+		fileFormat = detected
+	}
+
+	detector, err := loadC2Detector(*cmdList)
+	if err != nil {
+		printError("%v", err)
+		return exitError
+	}
+
+	ruleSet, err := loadRules(*rulesFile)
+	if err != nil {
+		printError("%v", err)
+		return exitError
+	}
+	expander := newURLExpander(*resolveURLs)
+
+	layout, err := parseOutputLayout(*outLayout)
+	if err != nil {
+		printError("%v", err)
+		return exitError
+	}
+	source := lenient.NewFile(inputPath)
+	payloadDir := inputOutputDir(*outputDir, source, layout)
+	if err := os.MkdirAll(payloadDir, 0755); err != nil {
+		printError("Failed to create output directory: %v", err)
+		return exitError
+	}
+
+	// Collect the analyzers' hints so extractors can try the likely methods first
+	analysisOptions := analyzer.AnalysisOptions{Verbose: *verbose, Format: fileFormat, Source: source}
+	if *configFile != "" {
+		detection, err := analyzer.LoadDetectionConfig(*configFile)
+		if err != nil {
+			printError("%v", err)
+			return exitError
+		}
+		analysisOptions.Detection = &detection
+	}
+	var hints []string
+	for _, a := range analyzers.GetAnalyzersForFormat(fileFormat) {
+		result, err := a.Analyze(inputPath, analysisOptions)
+		if err != nil {
+			printWarning("Analysis with %s failed: %v", a.Name(), err)
+			continue
+		}
+		for _, hint := range result.ExtractionHints {
+			hints = append(hints, hint.Algorithm)
+		}
+	}
+
+	extractors := extractor.NewRegistry()
+	registerExtractors(extractors)
+	candidates, unhandled := extractorsForHints(extractors, fileFormat, hints)
+	if len(candidates) == 0 {
+		printWarning("No extractors available for format: %s", fileFormat)
+		return exitError
+	}
+	for _, algorithm := range unhandled {
+		printInfo("No extractor handles the hinted algorithm %s", algorithm)
+	}
+
+	options := extractor.ExtractionOptions{
+		OutputDir:      payloadDir,
+		AlgorithmHints: hints,
+		Verbose:        *verbose,
+		Workers:        *lsbWorkers,
+		MemoryBudget:   *lsbMemory * 1024 * 1024,
+		Stream:         *stream,
+		Password:       *password,
+		Source:         source,
+	}
+	if *dctOrder != "" {
+		if _, err := jpeganalyzer.ParseBlockOrder(*dctOrder); err != nil {
+			printError("%v", err)
+			return exitError
+		}
+		options.Parameters = map[string]interface{}{lsbextractor.BlockOrderParameter: *dctOrder}
+	}
+
+	manifest := extractManifest{
+		Input:      *filePath,
+		Format:     fileFormat,
+		Created:    time.Now(),
+		Candidates: []manifestEntry{},
+	}
+	var trace *traceWriter
+	if *traceFile != "" {
+		if trace, err = openTrace(*traceFile); err != nil {
+			printError("%v", err)
+			return exitError
+		}
+		defer trace.Close()
+	}
+
+	// Ctrl-C stops after the extractor in progress and still writes the manifest
+	ctx, stopInterrupt := notifyInterrupt()
+	defer stopInterrupt()
+
+	confirmed := false
+	for _, e := range candidates {
+		if ctx.Err() != nil {
+			manifest.Interrupted = true
+			break
+		}
+		printInfo("Running %s", e.Name())
+		if *verbose || trace != nil {
+			name := e.Name()
+			options.Trace = func(attempt extractor.Attempt) {
+				entry := traceEntry{File: *filePath, Extractor: name, Attempt: attempt}
+				manifest.Attempts = append(manifest.Attempts, entry)
+				if *verbose {
+					printAttempt(console, entry)
+				}
+				if err := trace.Write(entry); err != nil {
+					printWarning("Failed to write trace: %v", err)
+				}
+			}
+		}
+		result, err := extractor.ExtractWithContext(ctx, e, inputPath, options)
+		if ctx.Err() != nil {
+			manifest.Interrupted = true
+		}
+		if err != nil {
+			printWarning("%s found nothing: %v", e.Name(), err)
+			continue
+		}
+
+		// Payloads in other channels or methods are listed alongside the best one
+		for _, payload := range append([]*models.ExtractionResult{result}, result.Alternates...) {
+			confidence, _ := payload.Details["score"].(float64)
+			matches := detector.Match(payload.ExtractedData)
+			if len(matches) < c2.MinMatches {
+				matches = nil
+			}
+			hits := ruleSet.Evaluate(payload.ExtractedData)
+			urls := expander.Expand(ctx, payload.ExtractedData)
+
+			// A streamed payload is only partly in memory; its extractor measured the rest
+			entropy := stats.ComputeEntropy(payload.ExtractedData)
+			if streamed, _ := payload.Details["streamed"].(bool); streamed {
+				entropy, _ = payload.Details["entropy"].(float64)
+			}
+			manifest.Candidates = append(manifest.Candidates, manifestEntry{
+				Extractor:  e.Name(),
+				Algorithm:  payload.Algorithm,
+				Confidence: math.Max(0, math.Min(1, confidence)),
+				Size:       payload.DataSize,
+				Entropy:    entropy,
+				MimeType:   payload.MimeType,
+				Files:      payload.OutputFiles,
+				C2Commands: matches,
+				Rules:      rules.IDs(hits),
+				URLs:       urls,
+			})
+			printSuccess("Extracted %d bytes with %s", payload.DataSize, payload.Algorithm)
+			if len(matches) > 0 {
+				printAlert("Payload contains C2-style commands: %s", strings.Join(matches, ", "))
+			}
+			if len(matches) > 0 {
+				confirmed = true
+			}
+			for _, u := range urls {
+				if reasons := u.Reasons(); len(reasons) > 0 {
+					printAlert("Payload links to %s (%s)", u.URL, strings.Join(reasons, ", "))
+				}
+			}
+			for _, hit := range hits {
+				if hit.Rule.Severity == models.SeverityConfirmed {
+					confirmed = true
+				}
+				printAlert("Rule %s (%s): %s, e.g. %q", hit.Rule.ID, hit.Rule.Severity, hit.Rule.Description, hit.Sample)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		printError("Failed to encode manifest: %v", err)
+		return exitError
+	}
+	manifestPath, err := filehandler.SaveFileUnique(data, filepath.Join(payloadDir, "manifest.json"))
+	if err != nil {
+		printError("Failed to write manifest: %v", err)
+		return exitError
+	}
+	printInfo("Wrote %d candidates to %s", len(manifest.Candidates), manifestPath)
+	if manifest.Interrupted {
+		printWarning("Interrupted: the manifest lists the candidates found before Ctrl-C")
+	}
+
+	if confirmed {
+		return exitConfirmed
+	}
+	if manifest.Interrupted {
+		return interruptedExitCode(exitClean)
+	}
+	return exitClean
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer/image/lenient"
+	"DeSteGo/pkg/extractor"
+	"DeSteGo/pkg/models"
+)
+
+// recordingExtractor is a DataExtractor that records the order it was run in
+// and never finds anything
+type recordingExtractor struct {
+	name       string
+	algorithms []string
+	calls      *[]string
+}
+
+func (e *recordingExtractor) CanExtract(format string) bool { return format == "png" }
+
+func (e *recordingExtractor) Extract(filePath string, options extractor.ExtractionOptions) (*models.ExtractionResult, error) {
+	*e.calls = append(*e.calls, e.name)
+	return nil, errors.New("nothing found")
+}
+
+func (e *recordingExtractor) Name() string                  { return e.name }
+func (e *recordingExtractor) SupportedFormats() []string    { return []string{"png"} }
+func (e *recordingExtractor) SupportedAlgorithms() []string { return e.algorithms }
+
+func TestExtractorsForHints(t *testing.T) {
+	var calls []string
+	registry := extractor.NewRegistry()
+	for _, e := range []*recordingExtractor{
+		{name: "lsb", algorithms: []string{"lsb"}, calls: &calls},
+		{name: "trailer", algorithms: []string{"appended"}, calls: &calls},
+		{name: "palette", algorithms: []string{"palette", "EzStego"}, calls: &calls},
+	} {
+		registry.Register(e)
+	}
+
+	tests := []struct {
+		name      string
+		hints     []models.ExtractionHint
+		order     string
+		unhandled string
+	}{
+		{"no hints", nil, "lsb,trailer,palette", ""},
+		{"hint moves an extractor first", []models.ExtractionHint{{Algorithm: "ezstego", Confidence: 0.6}}, "palette,lsb,trailer", ""},
+		{"highest confidence first", []models.ExtractionHint{
+			{Algorithm: "appended", Confidence: 0.4},
+			{Algorithm: "palette", Confidence: 0.9},
+		}, "palette,trailer,lsb", ""},
+		{"repeated extractor", []models.ExtractionHint{
+			{Algorithm: "palette", Confidence: 0.9},
+			{Algorithm: "ezstego", Confidence: 0.8},
+		}, "palette,lsb,trailer", ""},
+		{"unhandled hint", []models.ExtractionHint{
+			{Algorithm: "f5", Confidence: 0.9},
+			{Algorithm: "lsb", Confidence: 0.5},
+			{Algorithm: "f5", Confidence: 0.3},
+		}, "lsb,trailer,palette", "f5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			var log strings.Builder
+			cfg := &scanConfig{extractors: registry}
+			analysis := &models.AnalysisResult{ExtractionHints: tt.hints}
+			extractHiddenData(lenient.NewFile("carrier.png"), "png", t.TempDir(), analysis, cfg, NewLogger(&log))
+
+			if got := strings.Join(calls, ","); got != tt.order {
+				t.Errorf("ran %s, want %s", got, tt.order)
+			}
+			if tt.unhandled != "" && strings.Count(log.String(), "hinted algorithm "+tt.unhandled) != 1 {
+				t.Errorf("log does not name the unhandled algorithm %s once:\n%s", tt.unhandled, log.String())
+			}
+		})
+	}
+
+	// A format without extractors runs nothing
+	calls = nil
+	var log strings.Builder
+	extractHiddenData(lenient.NewFile("carrier.gif"), "gif", t.TempDir(), &models.AnalysisResult{}, &scanConfig{extractors: registry}, NewLogger(&log))
+	if len(calls) != 0 || !strings.Contains(log.String(), "No extractors available") {
+		t.Errorf("ran %v for a format without extractors", calls)
+	}
+}
+
+func TestExtractCommand(t *testing.T) {
+	binary := buildBinary(t)
+
+	tests := []struct {
+		fixture   string
+		format    string
+		algorithm string
+		mimeType  string
+	}{
+		{"lsb_rgb.png", "png", "lsb-sequential-rgb", "text/plain; charset=utf-8"},
+		{"appended_zip.jpg", "jpeg", "appended", "application/zip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			fixture, _ := fixtures.Lookup(tt.fixture)
+			outDir := t.TempDir()
+			cmd := exec.Command(binary, "extract", "-file", fixtures.Path(tt.fixture), "-outdir", outDir, "-outlayout", "flat")
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("extract failed: %v\n%s", err, out)
+			}
+
+			data, err := os.ReadFile(filepath.Join(outDir, "manifest.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var manifest extractManifest
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				t.Fatalf("manifest.json is not valid JSON: %v", err)
+			}
+			if manifest.Format != tt.format {
+				t.Errorf("got format %q, want %q", manifest.Format, tt.format)
+			}
+
+			var found []string
+			for _, c := range manifest.Candidates {
+				found = append(found, c.Algorithm)
+				if c.Algorithm != tt.algorithm {
+					continue
+				}
+				if c.MimeType != tt.mimeType || c.Size == 0 || c.Entropy <= 0 || c.Confidence <= 0 {
+					t.Errorf("got %s candidate %+v, want a %s payload with size, entropy and confidence", c.Algorithm, c, tt.mimeType)
+				}
+				if len(c.Files) != 1 {
+					t.Fatalf("got files %q, want one", c.Files)
+				}
+				payload, err := os.ReadFile(c.Files[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				// The appended ZIP stores its entry uncompressed
+				if !bytes.Contains(payload, []byte(fixture.Payload)) {
+					t.Errorf("%s does not hold the fixture's payload", c.Files[0])
+				}
+				return
+			}
+			t.Errorf("got candidates %q, want %s", found, tt.algorithm)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/analyzer/image/lenient"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the -format override. A forced format picks the analyzers to
+run. When a file's content is in that format they analyze the file as usual;
+when it is in another one, such as a BMP analyzed with -format png, the file is
+decoded with the decoder its content calls for and the forced analyzers run
+their pixel analyses on the decoded image. The format-specific checks of the
+file's bytes (chunk layout, markers, appended data) are skipped then, since the
+bytes are not in the format they check. A file that does not decode as an image
+at all is an error rather than a silent fallback to byte-level analysis.
+*/
+
+// describeFormatError returns the message of a format detection error, listing
+// the formats the analyzers support when the file is in none of them
+func describeFormatError(err error, registry *analyzer.Registry) string {
+	if !errors.Is(err, filehandler.ErrUnsupportedFormat) {
+		return err.Error()
+	}
+	formats := registry.GetSupportedFormats()
+	sort.Strings(formats)
+	return fmt.Sprintf("%v (supported formats: %s)", err, strings.Join(formats, ", "))
+}
+
+// parseFormat checks a -format value against the registered analyzers and
+// returns its canonical name, "jpeg" for "jpg"
+func parseFormat(format string, registry *analyzer.Registry) (string, error) {
+	format = strings.ToLower(format)
+	if format == "auto" {
+		return format, nil
+	}
+	if canonical, ok := filehandler.SupportedImageFormats["."+format]; ok {
+		format = canonical
+	}
+	if len(registry.GetAnalyzersForFormat(format)) == 0 {
+		return "", fmt.Errorf("-format %s: no analyzer supports it (formats: %s)", format, strings.Join(registry.GetSupportedFormats(), ", "))
+	}
+	return format, nil
+}
+
+// forcedImage returns the decoded image of a file whose content is not the
+// forced format, and the format it was decoded as. It returns a nil image for
+// files in the forced format and for files whose content is not recognized,
+// which the analyzers read as usual.
+func forcedImage(source *lenient.File, format string) (image.Image, string, error) {
+	actual, err := filehandler.SniffFileFormat(source.Path())
+	if err != nil || actual == format {
+		return nil, "", nil
+	}
+
+	decoded, err := source.Decode()
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot analyze %s as %s: its %s content does not decode as an image: %w", source.Path(), format, actual, err)
+	}
+	return decoded.Image, decoded.Format, nil
+}
+
+// analyzeForced runs an analyzer's pixel analyses on an image decoded from a
+// file in another format
+func analyzeForced(a analyzer.FileAnalyzer, img image.Image, decodedAs, filePath string, options analyzer.AnalysisOptions) (*models.AnalysisResult, error) {
+	imageAnalyzer, ok := a.(analyzer.ImageAnalyzer)
+	if !ok {
+		return nil, fmt.Errorf("%s only analyzes %s files, not decoded images", a.Name(), options.Format)
+	}
+	result, err := imageAnalyzer.AnalyzeImage(img, options)
+	if err != nil {
+		return nil, err
+	}
+	result.Filename = filePath
+	result.Details["decoded_as"] = decodedAs
+	result.SetCleanRationale()
+	return result, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+)
+
+func TestParseFormat(t *testing.T) {
+	registry := analyzer.NewRegistry()
+	registerAnalyzers(registry)
+
+	tests := []struct {
+		format string
+		want   string // Empty when the format is rejected
+	}{
+		{"auto", "auto"},
+		{"png", "png"},
+		{"PNG", "png"},
+		{"jpg", "jpeg"},
+		{"tiff", "tiff"},
+		{"exe", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := parseFormat(tt.format, registry)
+			if (err != nil) != (tt.want == "") || got != tt.want {
+				t.Errorf("got %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestForcedFormat(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name      string
+		fixture   string
+		cut       int // Bytes of the fixture to keep, 0 for all
+		format    string
+		decodedAs string // Empty when the analyzers read the file as usual
+		findings  int
+		fails     bool
+	}{
+		{"PNG as png", "lsb_rgb.png", 0, "png", "", 1, false},
+		{"BMP as png", "lsb_rgb.bmp", 0, "png", "bmp", 1, false},
+		{"clean JPEG as png", "clean.jpg", 0, "png", "jpeg", 0, false},
+		{"truncated PNG as jpeg", "clean.png", 100, "jpeg", "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := fixtures.Load(tt.fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.cut > 0 {
+				data = data[:tt.cut]
+			}
+			path := filepath.Join(dir, tt.fixture)
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			registry := analyzer.NewRegistry()
+			registerAnalyzers(registry)
+			cfg := &scanConfig{registry: registry, format: tt.format, sequential: true}
+			var log bytes.Buffer
+			result := analyzeFile(path, cfg, NewLogger(&log), nil)
+			if tt.fails {
+				if result != nil || !strings.Contains(log.String(), "does not decode as an image") {
+					t.Errorf("got result %+v, want a decoding error\n%s", result, log.String())
+				}
+				return
+			}
+			if result == nil {
+				t.Fatalf("analysis failed\n%s", log.String())
+			}
+			decodedAs, _ := result.Details["decoded_as"].(string)
+			if result.FileType != tt.format || decodedAs != tt.decodedAs || len(result.Findings) != tt.findings {
+				t.Errorf("got %s analysis of %q content with %d findings, want %s, %q, %d",
+					result.FileType, decodedAs, len(result.Findings), tt.format, tt.decodedAs, tt.findings)
+			}
+		})
+	}
+}
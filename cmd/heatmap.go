@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"path/filepath"
+	"strings"
+
+	"DeSteGo/pkg/analyzer/image/lenient"
+	"DeSteGo/pkg/analyzer/image/lsb"
+	"DeSteGo/pkg/filehandler"
+)
+
+// writeHeatmap renders the LSB entropy heatmap of an image into dir and returns
+// the path of the written PNG
+func writeHeatmap(file *lenient.File, dir string) (string, error) {
+	decoded, err := file.Decode()
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, lsb.EntropyHeatmap(decoded.Image, lsb.DefaultHeatmapTile)); err != nil {
+		return "", fmt.Errorf("failed to encode heatmap: %w", err)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(file.Path()), filepath.Ext(file.Path()))
+	outPath := filepath.Join(dir, base+"_heatmap.png")
+	if err := filehandler.SaveFile(buf.Bytes(), outPath); err != nil {
+		return "", err
+	}
+	return outPath, nil
+}
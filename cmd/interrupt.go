@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+/*
+This file contains the handling of Ctrl-C. The first interrupt stops a scan
+from starting new files: the files in progress get interruptGrace to finish,
+and the summary covers the files that completed. LSB extraction checks the
+scan's context between its passes, so an extraction in progress stops early
+rather than holding up the summary. A second interrupt exits at once.
+*/
+
+// interruptGrace is how long an interrupted scan waits for the files in progress
+const interruptGrace = 5 * time.Second
+
+// notifyInterrupt returns a context that is canceled by the first SIGINT or
+// SIGTERM, after which a second one exits the process with exitInterrupted,
+// and a function that stops listening for them
+func notifyInterrupt() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+		case <-ctx.Done():
+			return
+		}
+		printWarning("Interrupted: finishing the files in progress, press Ctrl-C again to quit now")
+		cancel()
+		if _, ok := <-signals; ok {
+			os.Exit(exitInterrupted)
+		}
+	}()
+
+	stop := func() {
+		signal.Stop(signals)
+		cancel()
+		close(signals)
+	}
+	return ctx, stop
+}
+
+// context returns the scan's context, which is canceled when the scan is
+// interrupted
+func (cfg *scanConfig) context() context.Context {
+	if cfg.ctx == nil {
+		return context.Background()
+	}
+	return cfg.ctx
+}
+
+// interrupted reports whether the scan was interrupted
+func (cfg *scanConfig) interrupted() bool {
+	return cfg.context().Err() != nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/models"
+)
+
+// interruptingAnalyzer sends the process an interrupt while it analyzes its
+// first file, and holds every file it analyzes until the scan is interrupted
+type interruptingAnalyzer struct {
+	analyzer.BaseAnalyzer
+	ctx   context.Context
+	calls atomic.Int32
+}
+
+func (a *interruptingAnalyzer) Analyze(filePath string, options analyzer.AnalysisOptions) (*models.AnalysisResult, error) {
+	if a.calls.Add(1) == 1 {
+		process, err := os.FindProcess(os.Getpid())
+		if err != nil {
+			return nil, err
+		}
+		if err := process.Signal(os.Interrupt); err != nil {
+			return nil, err
+		}
+	}
+	select {
+	case <-a.ctx.Done():
+	case <-time.After(10 * time.Second):
+	}
+	return &models.AnalysisResult{FileType: "png", Filename: filePath}, nil
+}
+
+func TestInterruptedScan(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for i := range 10 {
+		path := filepath.Join(dir, string(rune('a'+i))+".png")
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+
+	tests := []struct {
+		name       string
+		sequential bool
+	}{
+		{"sequential", true},
+		{"parallel", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, stop := notifyInterrupt()
+			defer stop()
+			fake := &interruptingAnalyzer{BaseAnalyzer: analyzer.NewBaseAnalyzer("Fake", "", []string{"png"}), ctx: ctx}
+			registry := analyzer.NewRegistry()
+			registry.Register(fake)
+			cfg := &scanConfig{registry: registry, format: "auto", sequential: tt.sequential, ctx: ctx}
+
+			var results []models.AnalysisResult
+			summary := captureStdout(t, func() {
+				results = analyzeFiles(files, cfg)
+			})
+
+			if !cfg.interrupted() {
+				t.Fatal("scan was not interrupted")
+			}
+			if len(results) == 0 || len(results) >= len(files) {
+				t.Fatalf("%d results, want some but not all of %d files", len(results), len(files))
+			}
+			want := fmt.Sprintf("Scan interrupted: %d files were not analyzed", len(files)-len(results))
+			if !strings.Contains(summary, fmt.Sprintf("Total files analyzed: %d", len(results))) || !strings.Contains(summary, want) {
+				t.Errorf("summary does not report %d analyzed files and %q:\n%s", len(results), want, summary)
+			}
+		})
+	}
+}
+
+// captureStdout returns what f prints to standard output
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	f()
+	w.Close()
+	return <-output
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"DeSteGo/pkg/analyzer/image/lenient"
+	"DeSteGo/pkg/cache"
+)
+
+// Output directory layouts for extracted data
+const (
+	layoutInput = "input" // One subdirectory per input, named after it and its content hash
+	layoutFlat  = "flat"  // Every input writes straight into the output directory
+)
+
+// inputHashLength is the number of hex digits of the content hash in a
+// per-input directory name
+const inputHashLength = 8
+
+// parseOutputLayout checks the value of -outlayout
+func parseOutputLayout(layout string) (string, error) {
+	switch layout {
+	case layoutInput, layoutFlat:
+		return layout, nil
+	}
+	return "", fmt.Errorf("unknown output layout %q (use %s or %s)", layout, layoutInput, layoutFlat)
+}
+
+// inputOutputDir returns the directory the data extracted from source is
+// written to. With the input layout it is a subdirectory of root named after
+// the file and the start of its SHA-256, so two inputs with the same name do
+// not share a directory and rescanning a file reuses its own.
+func inputOutputDir(root string, source *lenient.File, layout string) string {
+	if layout == layoutFlat {
+		return root
+	}
+	name := strings.TrimSuffix(filepath.Base(source.Path()), filepath.Ext(source.Path()))
+	if data, err := source.Bytes(); err == nil {
+		name += "_" + cache.Hash(data)[:inputHashLength]
+	}
+	return filepath.Join(root, name)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/c2"
+	"DeSteGo/pkg/extractor"
+	"DeSteGo/pkg/rules"
+)
+
+func TestOutputLayout(t *testing.T) {
+	// Two inputs named img.png in different directories, with different payloads
+	fixture, _ := fixtures.Lookup("lsb_rgb.png")
+	payloads := []string{fixture.Payload, strings.ToUpper(fixture.Payload)}
+	var inputs []string
+	for i, payload := range payloads {
+		fixture.Payload = payload
+		data, err := fixture.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir := filepath.Join(t.TempDir(), string(rune('a'+i)))
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "img.png")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, path)
+	}
+
+	tests := []struct {
+		layout string
+		dirs   int // Directories the payloads are written to
+	}{
+		{layoutInput, 2},
+		{layoutFlat, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			registry := analyzer.NewRegistry()
+			registerAnalyzers(registry)
+			extractors := extractor.NewRegistry()
+			registerExtractors(extractors)
+			outDir := t.TempDir()
+			cfg := &scanConfig{registry: registry, extractors: extractors, c2: c2.NewDetector(), rules: rules.Default(),
+				format: "auto", sequential: true, extract: true, outputDir: outDir, outputLayout: tt.layout}
+
+			// The first input is scanned twice, which must not replace its payload either
+			for _, input := range append(inputs, inputs[0]) {
+				if analyzeFile(input, cfg, NewLogger(io.Discard), nil) == nil {
+					t.Fatalf("analysis of %s failed", input)
+				}
+			}
+
+			found := make(map[string]int)
+			dirs := make(map[string]bool)
+			err := filepath.WalkDir(outDir, func(path string, d os.DirEntry, err error) error {
+				if err != nil || !strings.HasPrefix(d.Name(), "extracted_sequential-rgb") {
+					return err
+				}
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				for _, payload := range payloads {
+					if strings.HasPrefix(string(data), payload) {
+						found[payload]++
+						dirs[filepath.Dir(path)] = true
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found[payloads[0]] != 2 || found[payloads[1]] != 1 {
+				t.Errorf("got %d and %d copies of the payloads, want 2 and 1", found[payloads[0]], found[payloads[1]])
+			}
+			if len(dirs) != tt.dirs {
+				t.Errorf("payloads written to %d directories, want %d", len(dirs), tt.dirs)
+			}
+		})
+	}
+}
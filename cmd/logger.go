@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Level is the least severe kind of message a Logger writes
+type Level int
+
+// Log levels, from the quietest
+const (
+	LevelError Level = iota // Errors and alerts
+	LevelWarn               // Warnings, and the results of files with findings
+	LevelInfo               // Progress messages and every result
+	LevelDebug              // Diagnostics for -verbose runs
+)
+
+// levelNames are the names of the levels accepted by -loglevel
+var levelNames = map[string]Level{
+	"error": LevelError,
+	"warn":  LevelWarn,
+	"info":  LevelInfo,
+	"debug": LevelDebug,
+}
+
+// ParseLevel returns the level called name
+func ParseLevel(name string) (Level, error) {
+	if level, ok := levelNames[strings.ToLower(name)]; ok {
+		return level, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q (use error, warn, info or debug)", name)
+}
+
+// Logger writes colour-coded console messages to an output stream, dropping
+// those less severe than its level. Each file scanned concurrently gets its own
+// Logger so its output can be kept together.
+type Logger struct {
+	mu    *sync.Mutex
+	out   io.Writer
+	level Level
+}
+
+// NewLogger creates a Logger writing messages up to LevelInfo to out
+func NewLogger(out io.Writer) *Logger {
+	return &Logger{mu: &sync.Mutex{}, out: out, level: LevelInfo}
+}
+
+// console is the Logger used for messages that are not tied to a single file
+var console = NewLogger(os.Stdout)
+
+// SetLevel sets the least severe kind of message the Logger writes
+func (l *Logger) SetLevel(level Level) {
+	l.level = level
+}
+
+// Enabled reports whether messages of the given level are written
+func (l *Logger) Enabled(level Level) bool {
+	return level <= l.level
+}
+
+// WithOutput returns a Logger with the same level writing to out
+func (l *Logger) WithOutput(out io.Writer) *Logger {
+	logger := NewLogger(out)
+	logger.level = l.level
+	return logger
+}
+
+// WithLevel returns a Logger writing to the same output at another level
+func (l *Logger) WithLevel(level Level) *Logger {
+	return &Logger{mu: l.mu, out: l.out, level: level}
+}
+
+// Printf writes a formatted message without a prefix at LevelInfo
+func (l *Logger) Printf(format string, args ...interface{}) {
+	if !l.Enabled(LevelInfo) {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.out, format, args...)
+}
+
+// Println writes its arguments followed by a newline at LevelInfo
+func (l *Logger) Println(args ...interface{}) {
+	if !l.Enabled(LevelInfo) {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(l.out, args...)
+}
+
+// Debug writes a diagnostic message
+func (l *Logger) Debug(format string, args ...interface{}) {
+	if !l.Enabled(LevelDebug) {
+		return
+	}
+	l.prefixed(infoColor("[.]"), format, args...)
+}
+
+// Info writes an informational message
+func (l *Logger) Info(format string, args ...interface{}) {
+	if !l.Enabled(LevelInfo) {
+		return
+	}
+	l.prefixed(infoColor("[*]"), format, args...)
+}
+
+// Success writes a success message
+func (l *Logger) Success(format string, args ...interface{}) {
+	if !l.Enabled(LevelInfo) {
+		return
+	}
+	l.prefixed(successColor("[+]"), format, args...)
+}
+
+// Warning writes a warning message
+func (l *Logger) Warning(format string, args ...interface{}) {
+	if !l.Enabled(LevelWarn) {
+		return
+	}
+	l.prefixed(warningColor("[!]"), format, args...)
+}
+
+// Error writes an error message, whatever the level
+func (l *Logger) Error(format string, args ...interface{}) {
+	l.prefixed(errorColor("[-]"), format, args...)
+}
+
+// Alert writes a high-severity alert, whatever the level
+func (l *Logger) Alert(format string, args ...interface{}) {
+	l.prefixed(alertColor("[!!!]"), format, args...)
+}
+
+// Write implements io.Writer so extractors can log through the Logger. Their
+// messages are written at LevelInfo.
+func (l *Logger) Write(p []byte) (int, error) {
+	if !l.Enabled(LevelInfo) {
+		return len(p), nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.out.Write(p)
+}
+
+func (l *Logger) prefixed(prefix string, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.out, "%s %s\n", prefix, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+)
+
+func TestQuietOutput(t *testing.T) {
+	registry := analyzer.NewRegistry()
+	registerAnalyzers(registry)
+	cfg := &scanConfig{registry: registry, format: "auto", sequential: true}
+
+	tests := []struct {
+		name   string
+		level  Level
+		result bool // Whether the file's result should be printed
+	}{
+		{"clean.jpg", LevelWarn, false},
+		{"clean.gif", LevelWarn, false},
+		{"clean.jpg", LevelInfo, true},
+		{"double_eoi.jpg", LevelWarn, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			log := NewLogger(&out)
+			log.SetLevel(tt.level)
+			if result := analyzeFile(fixtures.Path(tt.name), cfg, log, nil); result == nil {
+				t.Fatal("analysis failed")
+			}
+
+			printed := strings.Contains(out.String(), "File: "+fixtures.Path(tt.name))
+			if printed != tt.result {
+				t.Errorf("result printed %v, want %v; output:\n%s", printed, tt.result, out.String())
+			}
+			if !tt.result && out.Len() > 0 {
+				t.Errorf("printed %d lines for a clean file:\n%s", strings.Count(out.String(), "\n"), out.String())
+			}
+		})
+	}
+}
@@ -2,20 +2,46 @@ package main
 
 import (
 	"DeSteGo/pkg/analyzer"
+	gifanalyzer "DeSteGo/pkg/analyzer/image/gif"
 	jpeganalyzer "DeSteGo/pkg/analyzer/image/jpeg"
+	"DeSteGo/pkg/analyzer/image/lenient"
+	lsbanalyzer "DeSteGo/pkg/analyzer/image/lsb"
 	pnganalyzer "DeSteGo/pkg/analyzer/image/png"
+	svganalyzer "DeSteGo/pkg/analyzer/image/svg"
+	tiffanalyzer "DeSteGo/pkg/analyzer/image/tiff"
+	"DeSteGo/pkg/analyzer/plugin"
+	rawanalyzer "DeSteGo/pkg/analyzer/raw"
+	"DeSteGo/pkg/c2"
+	"DeSteGo/pkg/cache"
+	"DeSteGo/pkg/extractor"
+	appendedextractor "DeSteGo/pkg/extractor/appended"
+	lsbextractor "DeSteGo/pkg/extractor/image/lsb"
 	"DeSteGo/pkg/filehandler"
 	"DeSteGo/pkg/models"
+	htmlreport "DeSteGo/pkg/report/html"
+	"DeSteGo/pkg/rules"
+	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"image"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fatih/color"
 )
 
+// version is set at build time through -ldflags "-X main.version=..."
+var version = "v0.0.5"
+
 var (
 	// Color printers
 	infoColor    = color.New(color.FgBlue).SprintFunc()
@@ -26,55 +52,194 @@ var (
 )
 
 func printInfo(format string, args ...interface{}) {
-	fmt.Printf("%s %s\n", infoColor("[*]"), fmt.Sprintf(format, args...))
+	console.Info(format, args...)
 }
 
 func printSuccess(format string, args ...interface{}) {
-	fmt.Printf("%s %s\n", successColor("[+]"), fmt.Sprintf(format, args...))
+	console.Success(format, args...)
 }
 
 func printWarning(format string, args ...interface{}) {
-	fmt.Printf("%s %s\n", warningColor("[!]"), fmt.Sprintf(format, args...))
+	console.Warning(format, args...)
 }
 
 func printError(format string, args ...interface{}) {
-	fmt.Printf("%s %s\n", errorColor("[-]"), fmt.Sprintf(format, args...))
+	console.Error(format, args...)
 }
 
 func printAlert(format string, args ...interface{}) {
-	fmt.Printf("%s %s\n", alertColor("[!!!]"), fmt.Sprintf(format, args...))
+	console.Alert(format, args...)
+}
+
+// scanConfig holds the settings shared by every file analyzed in a run
+type scanConfig struct {
+	registry       *analyzer.Registry
+	format         string
+	verbose        bool
+	extract        bool
+	outputDir      string
+	outputLayout   string
+	sequential     bool
+	dedup          bool
+	dedupThreshold int
+	cache          *cache.Cache
+	extractors     *extractor.Registry
+	thresholds     extractor.ReportThresholds
+	heatmapDir     string
+	qr             bool
+	c2             *c2.Detector
+	urls           *c2.URLExpander
+	rules          *rules.RuleSet
+	detection      *analyzer.DetectionConfig // Thresholds from -config, nil for the built-in ones
+	lsbWorkers     int
+	lsbMemory      int
+	trace          *traceWriter
+	ndjson         *resultWriter // Receives the result of each input as it completes, nil without -ndjson
+	out            io.Writer     // Human-readable output, standard output when nil
+	dctOrder       string
+	stream         bool
+	password       string
+	minConfidence  float64
+	scanAll        bool
+	sampleFraction float64
+	sampleCount    int
+	seed           int64
+	sample         *fileSample       // Set once the inputs of a directory or URL-list scan are sampled
+	nestDepth      int               // Levels of extracted images to analyze in turn
+	depth          int               // Nesting level of the file being analyzed, 0 for inputs
+	names          map[string]string // Name to report for each image unpacked from an archive, by its unpacked path
+	ctx            context.Context   // Canceled on Ctrl-C, after which no new files are started
+}
+
+// output returns the writer for human-readable output: standard error when
+// -ndjson - streams the results to standard output, standard output otherwise
+func (cfg *scanConfig) output() io.Writer {
+	if cfg.out == nil {
+		return os.Stdout
+	}
+	return cfg.out
 }
 
 func main() {
-	// Parse command line arguments
+	// Subcommands take their own flags
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "extract":
+			os.Exit(runExtractCommand(os.Args[2:]))
+		case "doctor":
+			os.Exit(runDoctorCommand(os.Args[2:]))
+		}
+	}
+
+	// Parse command line arguments. Bad flags exit with exitError rather than
+	// the flag package's 2, which means a confirmed finding.
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
 	var (
-		filePath    = flag.String("file", "", "Path to a single file for analysis")
+		filePath    = flag.String("file", "", "Path to a single file for analysis (- for standard input); the images in a .zip, .tar or .tar.gz are analyzed in turn")
 		dirPath     = flag.String("dir", "", "Path to directory of files for analysis")
 		urlPath     = flag.String("url", "", "URL to download and analyze")
 		urlFilePath = flag.String("urlfile", "", "Path to file containing URLs to download and analyze")
 		outputDir   = flag.String("outdir", "destego_output", "Directory to store results and downloaded files")
-		format      = flag.String("format", "auto", "Force specific format analysis (png, jpg, gif, svg)")
+		format      = flag.String("format", "auto", "Force specific format analysis (png, jpeg, gif, tiff, svg); images in another format are decoded and their pixels analyzed")
 		verbose     = flag.Bool("verbose", false, "Enable verbose output")
+		quiet       = flag.Bool("quiet", false, "Print only warnings, errors, the results of files with findings and the summary")
+		logLevel    = flag.String("loglevel", "", "Least severe messages to print: error, warn, info or debug (default: info, debug with -verbose)")
 		listFormats = flag.Bool("listformats", false, "List all supported file formats")
 		sequential  = flag.Bool("seq", true, "Use sequential processing (default: true)")
 		extractFlag = flag.Bool("extract", false, "Attempt to extract hidden data if found")
+		noColor     = flag.Bool("no-color", false, "Disable colored output (also honors the NO_COLOR environment variable)")
+		dedup       = flag.Bool("dedup", false, "Scan only one image per group of near-duplicates (directory, archive and URL-list scans)")
+		noCache     = flag.Bool("nocache", false, "Do not read or write the result cache in the output directory")
+		dedupDist   = flag.Int("dedupthreshold", 5, "Maximum average-hash distance (0-64) for two images to count as duplicates")
+		heatmapDir  = flag.String("heatmap", "", "Write an LSB entropy heatmap PNG for each analyzed image to this directory")
+		qrScan      = flag.Bool("qr", false, "Search images for QR codes, including low-contrast ones, and check their text")
+		resolveURLs = flag.Bool("resolveurls", false, "Send a HEAD request to each URL in an extracted payload to learn what it serves (contacts the URL's host)")
+		cmdList     = flag.String("cmdlist", "", "File of shell/PowerShell commands to look for in extracted payloads (default: built-in list)")
+		rulesFile   = flag.String("rules", "", "JSON file of indicator rules to add to the built-in rules for extracted payloads")
+		configFile  = flag.String("config", "", "JSON file of detection thresholds that override the built-in ones")
+		scanAll     = flag.Bool("scanall", false, "Detect every file's format from its content, ignoring extensions, and skip files that are not supported images")
+		compare     = flag.Bool("compare", false, "Compare the images of a directory against each other and report statistical outliers")
+		minLen      = flag.Int("minlen", 10, "Minimum size in bytes of an extracted payload to report")
+		minPrint    = flag.Float64("minprintable", 0.8, "Minimum printable-character ratio (0-1) for an extracted text payload")
+		minEntropy  = flag.Float64("minentropy", 6.5, "Minimum entropy in bits per byte for an extracted binary payload")
+		lsbWorkers  = flag.Int("lsbworkers", 0, "LSB extraction methods to run at once (default: one per CPU)")
+		lsbMemory   = flag.Int("lsbmemory", 0, "Memory budget in MB shared by running LSB extraction methods (default: 256)")
+		stream      = flag.Bool("stream", false, "Stream extracted LSB payloads to disk instead of holding them in memory (C2 and rule checks see the first 64KB)")
+		dctOrder    = flag.String("dctorder", "", "DCT block order for JSteg extraction: interleaved, luminance, chrominance or sequential (default: try all)")
+		minConf     = flag.Float64("minconfidence", 0, "Only print findings with at least this confidence (0-1); files without one count as clean in the summary")
+		traceFile   = flag.String("trace", "", "Write every extraction attempt as JSON lines to this file")
+		ndjsonFile  = flag.String("ndjson", "", "Write each file's result as a line of JSON to this file as soon as the file completes (- for standard output, moving messages to standard error)")
+		retries     = flag.Int("retries", 2, "Times to retry a download after a network error, 429 or 5xx response")
+		timeout     = flag.Duration("timeout", 60*time.Second, "Time limit for each download request (0: none)")
+		rateLimit   = flag.Duration("ratelimit", 0, "Minimum time between the starts of two download requests to the same host, such as 500ms")
+		rateBurst   = flag.Int("rateburst", 1, "Download requests to a host that may start at once before -ratelimit applies")
+		dlHosts     = flag.Int("downloadhosts", filehandler.DefaultDownloadHosts, "Hosts to download from in parallel")
+		failOn      = flag.Float64("failon", neverFail, "Exit with status 1 when a file's detection score exceeds this value (0-1, default: never)")
+		sample      = flag.Float64("sample", 0, "Scan a random fraction (0-1) of the files of a directory, archive or URL list")
+		sampleCount = flag.Int("samplecount", 0, "Scan this many randomly chosen files of a directory, archive or URL list")
+		seed        = flag.Int64("seed", 0, "Seed for -sample and -samplecount, to repeat a selection (default: random)")
+		password    = flag.String("password", "", "Password for seeded LSB extraction, tried alongside the unkeyed methods")
+		nestDepth   = flag.Int("nestdepth", defaultNestDepth, "Levels of images found inside extracted payloads to analyze in turn (0: none)")
+		outLayout   = flag.String("outlayout", layoutInput, "Layout of extracted data in -outdir: input (a subdirectory per input) or flat")
+		reportFile  = flag.String("report", "", "Write an HTML report of all analyzed files to this file")
+		pluginDir   = flag.String("plugins", "", "Directory of external analyzer plugins to load alongside the built-in analyzers")
+		archiveMax  = flag.Int("archivemax", filehandler.DefaultArchiveLimit>>20, "Maximum total size in MB of the images unpacked from a -file archive")
+		combineDir  = flag.String("combine", "", "XOR the LSB planes of the equally sized images in this directory and report whether they reveal data split across them")
 	)
 
-	flag.Parse()
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		os.Exit(parseErrorCode(err))
+	}
+
+	// Results streamed to standard output move the console messages to standard error
+	out := os.Stdout
+	var ndjson *resultWriter
+	if *ndjsonFile != "" {
+		writer, err := openResults(*ndjsonFile)
+		if err != nil {
+			printError("%v", err)
+			os.Exit(exitError)
+		}
+		ndjson = writer
+		if *ndjsonFile == "-" {
+			out = os.Stderr
+			console = NewLogger(out)
+		}
+	}
+
+	// Fall back to plain text when asked to or when output is not a terminal
+	if *noColor || os.Getenv("NO_COLOR") != "" || !IsTerminal(out) {
+		color.NoColor = true
+	}
+
+	level, err := outputLevel(*logLevel, *quiet, *verbose)
+	if err != nil {
+		printError("%v", err)
+		os.Exit(exitError)
+	}
+	console.SetLevel(level)
 
 	// Banner and version info
-	fmt.Println("DeSteGo v0.0.5")
-	fmt.Println("A wide net steganography analysis tool")
-	fmt.Println("Developed by Ethan Hulse")
-	fmt.Println("---------------------------------")
+	if console.Enabled(LevelInfo) {
+		fmt.Fprintf(out, "DeSteGo %s\n", version)
+		fmt.Fprintln(out, "A wide net steganography analysis tool")
+		fmt.Fprintln(out, "Developed by Ethan Hulse")
+		fmt.Fprintln(out, "---------------------------------")
+	}
 
 	// Create registry and register analyzers
 	registry := analyzer.NewRegistry()
 	registerAnalyzers(registry)
+	if *pluginDir != "" {
+		registerPlugins(registry, *pluginDir)
+	}
+
+	extractors := extractor.NewRegistry()
+	registerExtractors(extractors)
 
 	// Handle list formats flag
 	if *listFormats {
-		fmt.Println("Supported file formats:")
+		fmt.Fprintln(out, "Supported file formats:")
 		formats := registry.GetSupportedFormats()
 		for _, format := range formats {
 			analyzers := registry.GetAnalyzersForFormat(format)
@@ -82,26 +247,193 @@ func main() {
 			for _, a := range analyzers {
 				names = append(names, a.Name())
 			}
-			fmt.Printf("- %s: %s\n", format, strings.Join(names, ", "))
+			fmt.Fprintf(out, "- %s: %s\n", format, strings.Join(names, ", "))
 		}
 		return
 	}
 
 	// Ensure we have at least one input method
-	if *filePath == "" && *dirPath == "" && *urlPath == "" && *urlFilePath == "" {
-		fmt.Println("Usage:")
-		fmt.Println("  destego -file <filepath>")
-		fmt.Println("  destego -dir <directory>")
-		fmt.Println("  destego -url <url>")
-		fmt.Println("  destego -urlfile <file-with-urls>")
+	if *filePath == "" && *dirPath == "" && *urlPath == "" && *urlFilePath == "" && *combineDir == "" {
+		fmt.Fprintln(out, "Usage:")
+		fmt.Fprintln(out, "  destego -file <filepath>")
+		fmt.Fprintln(out, "  destego -dir <directory>")
+		fmt.Fprintln(out, "  destego -url <url>")
+		fmt.Fprintln(out, "  destego -urlfile <file-with-urls>")
+		fmt.Fprintln(out, "  destego -combine <directory>")
+		fmt.Fprintln(out, "  destego extract -file <filepath> [-outdir <directory>]")
+		fmt.Fprintln(out, "  destego doctor")
 		flag.PrintDefaults()
-		os.Exit(1)
+		os.Exit(exitError)
+	}
+
+	cfg := &scanConfig{
+		registry:       registry,
+		ndjson:         ndjson,
+		out:            out,
+		format:         *format,
+		verbose:        *verbose,
+		extract:        *extractFlag,
+		outputDir:      *outputDir,
+		sequential:     *sequential,
+		dedup:          *dedup,
+		dedupThreshold: *dedupDist,
+		extractors:     extractors,
+		heatmapDir:     *heatmapDir,
+		qr:             *qrScan,
+		lsbWorkers:     *lsbWorkers,
+		lsbMemory:      *lsbMemory * 1024 * 1024,
+		dctOrder:       *dctOrder,
+		stream:         *stream,
+		password:       *password,
+		minConfidence:  *minConf,
+		scanAll:        *scanAll,
+		sampleFraction: *sample,
+		sampleCount:    *sampleCount,
+		seed:           *seed,
+		nestDepth:      *nestDepth,
+		thresholds: extractor.ReportThresholds{
+			MinLength:    *minLen,
+			MinPrintable: *minPrint,
+			MinEntropy:   *minEntropy,
+		},
+	}
+
+	layout, err := parseOutputLayout(*outLayout)
+	if err != nil {
+		printError("%v", err)
+		os.Exit(exitError)
+	}
+	cfg.outputLayout = layout
+
+	if cfg.format, err = parseFormat(*format, registry); err != nil {
+		printError("%v", err)
+		os.Exit(exitError)
+	}
+
+	if *nestDepth < 0 {
+		printError("-nestdepth must not be negative")
+		os.Exit(exitError)
+	}
+
+	if *retries < 0 {
+		printError("-retries must not be negative")
+		os.Exit(exitError)
+	}
+
+	if *archiveMax <= 0 {
+		printError("-archivemax must be positive")
+		os.Exit(exitError)
+	}
+
+	if *sample < 0 || *sample > 1 {
+		printError("-sample must be between 0 and 1")
+		os.Exit(exitError)
+	}
+	if *sampleCount < 0 {
+		printError("-samplecount must not be negative")
+		os.Exit(exitError)
+	}
+	if cfg.seed == 0 {
+		cfg.seed = time.Now().UnixNano()
+	}
+
+	if *minConf < 0 || *minConf > 1 {
+		printError("-minconfidence must be between 0 and 1")
+		os.Exit(exitError)
+	}
+
+	if *dctOrder != "" {
+		if _, err := jpeganalyzer.ParseBlockOrder(*dctOrder); err != nil {
+			printError("%v", err)
+			os.Exit(exitError)
+		}
+	}
+
+	// Load the C2 command list used on extracted payloads
+	detector, err := loadC2Detector(*cmdList)
+	if err != nil {
+		printError("%v", err)
+		os.Exit(exitError)
+	}
+	cfg.c2 = detector
+	cfg.urls = newURLExpander(*resolveURLs)
+
+	ruleSet, err := loadRules(*rulesFile)
+	if err != nil {
+		printError("%v", err)
+		os.Exit(exitError)
+	}
+	cfg.rules = ruleSet
+
+	if *configFile != "" {
+		detection, err := analyzer.LoadDetectionConfig(*configFile)
+		if err != nil {
+			printError("%v", err)
+			os.Exit(exitError)
+		}
+		cfg.detection = &detection
 	}
 
 	// Create output directory if it doesn't exist
 	if err := os.MkdirAll(*outputDir, 0755); err != nil {
 		printError("Failed to create output directory: %v", err)
-		os.Exit(1)
+		os.Exit(exitError)
+	}
+
+	if *traceFile != "" {
+		trace, err := openTrace(*traceFile)
+		if err != nil {
+			printError("%v", err)
+			os.Exit(exitError)
+		}
+		cfg.trace = trace
+	}
+
+	// Open the result cache, keyed by build and analyzer set
+	if !*noCache {
+		resultCache, err := cache.New(filepath.Join(*outputDir, "cache"), cacheVersion(registry))
+		if err != nil {
+			printWarning("Result cache disabled: %v", err)
+		} else {
+			cfg.cache = resultCache
+		}
+	}
+
+	// Combining the planes of a set of images replaces the per-file analysis
+	if *combineDir != "" {
+		printInfo("Combining the LSB planes of the images in %s", *combineDir)
+		if err := printCombination(*combineDir, cfg); err != nil {
+			printError("%v", err)
+			os.Exit(exitError)
+		}
+		return
+	}
+
+	// Ctrl-C stops the scan from starting new files and reports what completed
+	ctx, stopInterrupt := notifyInterrupt()
+	defer stopInterrupt()
+	cfg.ctx = ctx
+
+	// Results of every input, used for the exit code
+	var results []models.AnalysisResult
+
+	// Downloads report as they finish; failures are retried per URL. Each host
+	// gets a subdirectory, since hosts are downloaded from in parallel.
+	downloadDir := filepath.Join(*outputDir, "downloads")
+	downloadOptions := filehandler.DownloadOptions{
+		Retries:  *retries,
+		Backoff:  time.Second,
+		Timeout:  *timeout,
+		Interval: *rateLimit,
+		Burst:    *rateBurst,
+		Hosts:    *dlHosts,
+		OnResult: func(download filehandler.DownloadResult) {
+			if download.Err != nil {
+				printError("Failed to download from %s after %d attempt(s): %v", download.URL, download.Attempts, download.Err)
+				return
+			}
+			printSuccess("Downloaded %s to %s", download.URL, download.Path)
+		},
 	}
 
 	// Process URL file if specified
@@ -110,227 +442,919 @@ func main() {
 		urls, err := filehandler.ReadLines(*urlFilePath)
 		if err != nil {
 			printError("Failed to read URL file: %v", err)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
 
+		var pending []string
 		for _, url := range urls {
 			url = strings.TrimSpace(url)
 			if url == "" || strings.HasPrefix(url, "#") {
 				continue // Skip empty lines and comments
 			}
+			pending = append(pending, url)
+		}
 
-			downloadDir := filepath.Join(*outputDir, "downloads")
-			printInfo("Downloading from %s", url)
-			filePath, err := filehandler.DownloadFromURL(url, downloadDir)
-			if err != nil {
-				printError("Failed to download from %s: %v", url, err)
+		pending = cfg.sampleInputs(pending, "URLs")
+		printInfo("Downloading %d URLs", len(pending))
+		var downloaded []string
+		failed := 0
+		for _, download := range filehandler.DownloadURLs(pending, downloadDir, downloadOptions) {
+			if download.Err != nil {
+				failed++
 				continue
 			}
-			printSuccess("Downloaded to %s", filePath)
-
-			// Analyze the downloaded file
-			analyzeFile(filePath, registry, *format, *verbose, *extractFlag)
+			downloaded = append(downloaded, download.Path)
 		}
+		if failed > 0 {
+			printWarning("%d of %d URLs could not be downloaded", failed, len(pending))
+		}
+
+		// Analyze the downloaded files
+		results = append(results, analyzeFiles(downloaded, cfg)...)
 	}
 
 	// Process single URL if specified
-	if *urlPath != "" {
+	if *urlPath != "" && !cfg.interrupted() {
 		printInfo("Downloading from URL: %s", *urlPath)
-		downloadDir := filepath.Join(*outputDir, "downloads")
-		filePath, err := filehandler.DownloadFromURL(*urlPath, downloadDir)
-		if err != nil {
-			printError("Failed to download from URL: %v", err)
-			os.Exit(1)
+		download := filehandler.DownloadURLs([]string{*urlPath}, downloadDir, downloadOptions)[0]
+		if download.Err != nil {
+			os.Exit(exitError)
 		}
-		printSuccess("Downloaded to %s", filePath)
 
 		// Analyze the downloaded file
-		analyzeFile(filePath, registry, *format, *verbose, *extractFlag)
+		if result := analyzeFile(download.Path, cfg, console, nil); result != nil {
+			cfg.emitResult(result)
+			results = append(results, *result)
+		}
 	}
 
 	// Process single file if specified
-	if *filePath != "" {
+	if *filePath != "" && !cfg.interrupted() && filehandler.ArchiveKind(*filePath) != "" {
+		printInfo("Analyzing archive: %s", *filePath)
+		archiveResults, err := analyzeArchive(*filePath, int64(*archiveMax)<<20, cfg)
+		if err != nil {
+			printError("Failed to read archive: %v", err)
+			os.Exit(exitError)
+		}
+		results = append(results, archiveResults...)
+	} else if *filePath != "" && !cfg.interrupted() {
+		inputPath, cleanup, err := resolveInput(*filePath)
+		if err != nil {
+			printError("%v", err)
+			os.Exit(exitError)
+		}
 		printInfo("Analyzing file: %s", *filePath)
-		analyzeFile(*filePath, registry, *format, *verbose, *extractFlag)
+		if result := analyzeFile(inputPath, cfg, console, nil); result != nil {
+			cfg.emitResult(result)
+			results = append(results, *result)
+		}
+		cleanup()
 	}
 
 	// Process directory if specified
-	if *dirPath != "" {
+	if *dirPath != "" && !cfg.interrupted() {
 		printInfo("Analyzing directory: %s", *dirPath)
 		files, err := filehandler.GatherFiles(*dirPath)
 		if err != nil {
 			printError("Failed to read directory: %v", err)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
 
+		if *compare {
+			printInfo("Comparing %d files", len(files))
+			printComparison(cfg.output(), files)
+			return
+		}
+
+		if cfg.scanAll {
+			files = sniffImageFiles(files, cfg.registry)
+		}
+		files = cfg.sampleInputs(files, "files")
+
 		printInfo("Found %d files to analyze", len(files))
+		results = append(results, analyzeFiles(files, cfg)...)
+	}
 
-		var results []models.AnalysisResult
+	if *reportFile != "" {
+		if err := htmlreport.WriteFile(*reportFile, results, htmlreport.Options{Version: version}); err != nil {
+			printError("Failed to write report: %v", err)
+		} else {
+			printSuccess("Report written to %s", *reportFile)
+		}
+	}
 
-		if *sequential {
-			for _, file := range files {
-				result := analyzeFile(file, registry, *format, *verbose, *extractFlag)
-				if result != nil {
-					results = append(results, *result)
-				}
+	if err := cfg.trace.Close(); err != nil {
+		printWarning("Failed to close trace file: %v", err)
+	}
+	if err := cfg.ndjson.Close(); err != nil {
+		printWarning("Failed to close NDJSON file: %v", err)
+	}
+	code := exitCode(results, *failOn)
+	if cfg.interrupted() {
+		code = interruptedExitCode(code)
+	}
+	stopInterrupt()
+	os.Exit(code)
+}
+
+// analyzeFiles scans a list of files, optionally skipping near-duplicates,
+// prints the summary and returns the results
+func analyzeFiles(files []string, cfg *scanConfig) []models.AnalysisResult {
+	if cfg.dedup {
+		kept, skipped := deduplicateFiles(files, cfg.dedupThreshold)
+		for _, file := range files {
+			if original, ok := skipped[file]; ok {
+				printInfo("Skipped %s (duplicate of %s)", cfg.displayName(file), cfg.displayName(original))
 			}
-		} else {
-			// TODO: Implement parallel processing
-			printWarning("Parallel processing not yet implemented, using sequential")
-			for _, file := range files {
-				result := analyzeFile(file, registry, *format, *verbose, *extractFlag)
-				if result != nil {
-					results = append(results, *result)
-				}
+		}
+		files = kept
+		if len(skipped) > 0 {
+			printInfo("Skipped %d near-duplicate files, %d left to analyze", len(skipped), len(files))
+		}
+	}
+
+	var results []models.AnalysisResult
+	analyzed := 0
+
+	if cfg.sequential {
+		for _, file := range files {
+			if cfg.interrupted() {
+				break
+			}
+			result := analyzeFile(file, cfg, console, nil)
+			analyzed++
+			if result != nil {
+				cfg.emitResult(result)
+				results = append(results, *result)
 			}
 		}
+	} else {
+		results, analyzed = analyzeFilesParallel(files, cfg, runtime.NumCPU())
+	}
 
-		// Print summary
-		printSummary(results)
+	// Print summary, of the files that completed when the scan was interrupted
+	scan := newScanResults(results, cfg.minConfidence)
+	scan.Skipped = len(files) - analyzed
+	printSummary(cfg.output(), scan, cfg.sample)
+	if cfg.cache != nil && cfg.cache.Hits() > 0 {
+		printInfo("Reused %d cached results", cfg.cache.Hits())
 	}
+	return results
+}
+
+// sniffImageFiles keeps the files whose content is an image format with at least
+// one analyzer, whatever their extension
+func sniffImageFiles(files []string, registry *analyzer.Registry) []string {
+	var images []string
+	for _, file := range files {
+		format, err := filehandler.SniffFileFormat(file)
+		if err != nil || len(registry.GetAnalyzersForFormat(format)) == 0 {
+			continue
+		}
+		if ext := strings.ToLower(filepath.Ext(file)); filehandler.SupportedImageFormats[ext] != format {
+			printInfo("%s contains a %s image", file, format)
+		}
+		images = append(images, file)
+	}
+	if skipped := len(files) - len(images); skipped > 0 {
+		printInfo("Skipped %d files that are not supported images", skipped)
+	}
+	return images
 }
 
 func registerAnalyzers(registry *analyzer.Registry) {
 	// Register all available analyzers
 	registry.Register(pnganalyzer.NewPNGAnalyzer())
 	registry.Register(jpeganalyzer.NewJPEGAnalyzer())
+	registry.Register(tiffanalyzer.NewTIFFAnalyzer())
+	registry.Register(gifanalyzer.NewGIFAnalyzer())
+	registry.Register(svganalyzer.NewSVGAnalyzer())
 	// Add more analyzers as they become available
 }
 
-func analyzeFile(filePath string, registry *analyzer.Registry, formatHint string, verbose bool, extract bool) *models.AnalysisResult {
+// registerPlugins registers the analyzer plugins in dir, warning about the
+// ones that could not be loaded
+func registerPlugins(registry *analyzer.Registry, dir string) {
+	plugins, err := plugin.Load(dir)
+	if err != nil {
+		printWarning("Failed to load plugins: %v", err)
+	}
+	for _, p := range plugins {
+		registry.Register(p)
+		printInfo("Loaded plugin %s for %s", p.Name(), strings.Join(p.SupportedFormats(), ", "))
+	}
+}
+
+func registerExtractors(registry *extractor.Registry) {
+	// Register all available extractors
+	registry.Register(lsbextractor.NewLSBExtractor())
+	registry.Register(lsbextractor.NewJStegExtractor())
+	registry.Register(appendedextractor.NewAppendedExtractor())
+}
+
+// sampleInputs applies -sample and -samplecount to the files or URLs of a scan
+// and records the selection for the summary
+func (cfg *scanConfig) sampleInputs(inputs []string, what string) []string {
+	if cfg.sampleFraction == 0 && cfg.sampleCount == 0 {
+		return inputs
+	}
+	sampled := sampleFiles(inputs, sampleSize(len(inputs), cfg.sampleFraction, cfg.sampleCount), cfg.seed)
+	cfg.sample = &fileSample{total: len(inputs), kept: len(sampled), seed: cfg.seed}
+	printInfo("Sampled %d of %d %s (-seed %d)", len(sampled), len(inputs), what, cfg.seed)
+	return sampled
+}
+
+// displayName returns the name a file is reported under: its path inside the
+// archive it was unpacked from, after the archive's path, or else its own path
+func (cfg *scanConfig) displayName(filePath string) string {
+	if name, ok := cfg.names[filePath]; ok {
+		return name
+	}
+	return filePath
+}
+
+// outputLevel returns the log level selected by -loglevel, -quiet and -verbose
+func outputLevel(name string, quiet, verbose bool) (Level, error) {
+	switch {
+	case name != "" && quiet:
+		return 0, fmt.Errorf("-quiet and -loglevel cannot be used together")
+	case name != "":
+		return ParseLevel(name)
+	case quiet:
+		return LevelWarn, nil
+	case verbose:
+		return LevelDebug, nil
+	}
+	return LevelInfo, nil
+}
+
+// resolveInput returns the path to analyze for a -file argument. For "-" the
+// image is read from standard input into a temporary file, which the returned
+// function removes; any other path must exist.
+func resolveInput(path string) (string, func(), error) {
+	if path != filehandler.StdinPath {
+		if _, err := os.Stat(path); err != nil {
+			return "", nil, err
+		}
+		return path, func() {}, nil
+	}
+	saved, err := filehandler.SaveStdin(os.Stdin)
+	if err != nil {
+		return "", nil, err
+	}
+	return saved, func() { os.RemoveAll(filepath.Dir(saved)) }, nil
+}
+
+// analyzeFile runs every applicable analyzer on a file, writing its output to
+// log. progress, if not nil, is called after each analyzer finishes.
+func analyzeFile(filePath string, cfg *scanConfig, log *Logger, progress func(current, total int)) *models.AnalysisResult {
+	name := cfg.displayName(filePath)
+
 	// Detect file format
-	format := formatHint
+	format := cfg.format
 	if format == "auto" {
-		detectedFormat, err := filehandler.DetectFileFormat(filePath)
+		detect := filehandler.DetectFileFormat
+		if cfg.scanAll {
+			detect = filehandler.SniffFileFormat
+		}
+		detectedFormat, err := detect(filePath)
+		if errors.Is(err, filehandler.ErrUnsupportedFormat) {
+			log.Warning("Skipping %s: %s", name, describeFormatError(err, cfg.registry))
+			return nil
+		}
 		if err != nil {
-			printError("Failed to detect file format: %v", err)
+			log.Error("Failed to detect file format: %v", err)
 			return nil
 		}
 		format = detectedFormat
 	}
 
 	// Get appropriate analyzers
-	analyzers := registry.GetAnalyzersForFormat(format)
+	analyzers := cfg.registry.GetAnalyzersForFormat(format)
 	if len(analyzers) == 0 {
-		printWarning("No analyzers available for format: %s", format)
+		log.Warning("No analyzers available for format: %s", format)
 		return nil
 	}
 
-	printInfo("Analyzing %s as %s format", filePath, format)
+	// The analyzers, the QR scan, the heatmap and the extractors share the
+	// file's bytes and decoded image rather than each reading it again
+	source := lenient.NewFile(filePath)
+
+	// A forced format the content is not in runs on the decoded pixels
+	var forced image.Image
+	var decodedAs string
+	if cfg.format != "auto" {
+		var err error
+		if forced, decodedAs, err = forcedImage(source, format); err != nil {
+			log.Error("%v", err)
+			return nil
+		}
+	}
+
+	if forced != nil {
+		log.Warning("Analyzing %s as %s format on its decoded %s pixels; %s file structure checks are skipped", name, format, decodedAs, format)
+	} else {
+		log.Info("Analyzing %s as %s format", name, format)
+	}
 	startTime := time.Now()
 
+	// The heatmap is written even when the analysis result comes from the cache
+	var heatmapPath string
+	if cfg.heatmapDir != "" {
+		if path, err := writeHeatmap(source, cfg.heatmapDir); err != nil {
+			log.Warning("Failed to write heatmap: %v", err)
+		} else {
+			log.Info("Entropy heatmap written to %s", path)
+			heatmapPath = path
+		}
+	}
+
+	// Reuse a previous result for identical content. Extraction has side effects
+	// on disk, so it always runs the analyzers.
+	var cacheKey string
+	if cfg.cache != nil && !cfg.extract {
+		if data, err := source.Bytes(); err == nil {
+			cacheKey = cache.Hash(data) + ":" + format
+			if cfg.qr {
+				cacheKey += ":qr"
+			}
+			if cfg.detection != nil {
+				cacheKey += fmt.Sprintf(":%+v", *cfg.detection)
+			}
+			if cached, ok := cfg.cache.Get(cacheKey); ok {
+				cached.Filename = name
+				setHeatmapFile(cached, heatmapPath)
+				log.Info("Using cached result")
+				displayAnalysisResult(log, cached, cfg.verbose, cfg.minConfidence)
+				if progress != nil {
+					progress(len(analyzers), len(analyzers))
+				}
+				return cached
+			}
+		}
+	}
+
 	var finalResult *models.AnalysisResult
 
-	// Run all applicable analyzers
-	for _, a := range analyzers {
-		printInfo("Running %s analyzer", a.Name())
+	// Data carved out by the analyzers and extractors goes to the same place
+	var outputDir string
+	if cfg.extract {
+		outputDir = inputOutputDir(cfg.outputDir, source, cfg.outputLayout)
+	}
 
-		// Setup options
-		options := analyzer.AnalysisOptions{
-			Verbose: verbose,
-			Format:  format,
-			Extract: extract,
-		}
+	// Setup options
+	options := analyzer.AnalysisOptions{
+		Verbose:   cfg.verbose,
+		Format:    format,
+		Extract:   cfg.extract,
+		OutputDir: outputDir,
+		Detection: cfg.detection,
+		Source:    source,
+	}
+
+	// Run all applicable analyzers
+	var failures []string
+	for i, a := range analyzers {
+		log.Info("Running %s analyzer", a.Name())
 
 		// Run analysis
-		result, err := a.Analyze(filePath, options)
+		var result *models.AnalysisResult
+		var err error
+		if forced != nil {
+			result, err = analyzeForced(a, forced, decodedAs, filePath, options)
+		} else {
+			result, err = analyzer.AnalyzeWithContext(cfg.context(), a, filePath, options)
+		}
+		if progress != nil {
+			progress(i+1, len(analyzers))
+		}
 		if err != nil {
-			printError("Analysis with %s failed: %v", a.Name(), err)
+			log.Error("Analysis with %s failed: %v", a.Name(), err)
+			failures = append(failures, fmt.Sprintf("%s: %v", a.Name(), err))
 			continue
 		}
 
 		// Display results
-		displayAnalysisResult(result, verbose)
+		result.Filename = name
+		options.Thresholds().ApplyEnsemble(result)
+		displayAnalysisResult(log, result, cfg.verbose, cfg.minConfidence)
 
-		// Keep the result with highest detection score
-		if finalResult == nil || result.DetectionScore > finalResult.DetectionScore {
+		// Merge into the highest-scoring result, whose details win on conflicts
+		switch {
+		case finalResult == nil:
+			finalResult = result
+		case result.DetectionScore > finalResult.DetectionScore:
+			result.Merge(finalResult)
 			finalResult = result
+		default:
+			finalResult.Merge(result)
 		}
 	}
 
+	// Damaged carriers that no analyzer decodes still get the byte-level analyses
+	if finalResult == nil && len(failures) > 0 {
+		finalResult = analyzeRawBytes(filePath, options, failures, cfg, log)
+	}
+
+	// Detectors of different analyzers may agree where neither did alone
+	if finalResult != nil {
+		options.Thresholds().ApplyEnsemble(finalResult)
+	}
+
+	if cfg.qr && finalResult != nil {
+		scanQRCodes(source, finalResult, cfg, log)
+	}
+
 	duration := time.Since(startTime)
-	printInfo("Analysis completed in %v", duration)
+	log.Info("Analysis completed in %v", duration)
+
+	// Only files that are not clean are worth an extraction attempt
+	if cfg.extract && finalResult != nil && finalResult.Severity() > models.SeverityClean {
+		payloads := extractHiddenData(source, format, outputDir, finalResult, cfg, log)
+		analyzeNested(filePath, payloads, finalResult, cfg, log)
+	}
+
+	if cacheKey != "" && finalResult != nil {
+		if err := cfg.cache.Put(cacheKey, finalResult); err != nil {
+			log.Warning("Failed to cache result: %v", err)
+		}
+	}
 
+	// Cached results do not keep the heatmap path, which depends on -heatmap
+	setHeatmapFile(finalResult, heatmapPath)
 	return finalResult
 }
 
-func displayAnalysisResult(result *models.AnalysisResult, verbose bool) {
-	fmt.Println("\n--- Analysis Results ---")
+// analyzeRawBytes runs the raw bytes analyzer on a file that every analyzer
+// failed on, noting why the image could not be analyzed normally
+func analyzeRawBytes(filePath string, options analyzer.AnalysisOptions, failures []string, cfg *scanConfig, log *Logger) *models.AnalysisResult {
+	log.Warning("Falling back to byte-level analysis")
+	result, err := rawanalyzer.NewRawAnalyzer().Analyze(filePath, options)
+	if err != nil {
+		log.Error("Byte-level analysis failed: %v", err)
+		return nil
+	}
+	result.AddFinding("Image could not be decoded, only byte-level analyses ran", 0.3, strings.Join(failures, "; "))
+	result.Filename = cfg.displayName(filePath)
+	displayAnalysisResult(log, result, cfg.verbose, cfg.minConfidence)
+	return result
+}
+
+// extractHiddenData runs every extractor registered for the format and reports
+// the payloads that meet the configured thresholds. Extractors for the analysis
+// result's extraction hints run first, and the hints are passed on as algorithm
+// hints. Payloads are written to outputDir and returned.
+func extractHiddenData(source *lenient.File, format, outputDir string, analysis *models.AnalysisResult, cfg *scanConfig, log *Logger) []*models.ExtractionResult {
+	filePath := source.Path()
+	hintList := append([]models.ExtractionHint(nil), analysis.ExtractionHints...)
+	sort.SliceStable(hintList, func(i, j int) bool { return hintList[i].Confidence > hintList[j].Confidence })
+	var hints []string
+	for _, hint := range hintList {
+		hints = append(hints, hint.Algorithm)
+	}
+
+	extractors, unhandled := extractorsForHints(cfg.extractors, format, hints)
+	if len(extractors) == 0 {
+		log.Warning("No extractors available for format: %s", format)
+		return nil
+	}
+	for _, algorithm := range unhandled {
+		log.Info("No extractor handles the hinted algorithm %s", algorithm)
+	}
+
+	options := extractor.ExtractionOptions{
+		OutputDir:      outputDir,
+		AlgorithmHints: hints,
+		Verbose:        cfg.verbose,
+		Thresholds:     cfg.thresholds,
+		Workers:        cfg.lsbWorkers,
+		MemoryBudget:   cfg.lsbMemory,
+		Stream:         cfg.stream,
+		Password:       cfg.password,
+		Log:            log,
+		Source:         source,
+	}
+	if cfg.dctOrder != "" {
+		options.Parameters = map[string]interface{}{lsbextractor.BlockOrderParameter: cfg.dctOrder}
+	}
+
+	var attempts []traceEntry
+	var payloads []*models.ExtractionResult
+	var outputFiles []string
+	for _, e := range extractors {
+		log.Info("Running %s", e.Name())
+		if log.Enabled(LevelDebug) || cfg.trace != nil {
+			name := e.Name()
+			options.Trace = func(attempt extractor.Attempt) {
+				entry := traceEntry{File: filePath, Extractor: name, Attempt: attempt}
+				attempts = append(attempts, entry)
+				if log.Enabled(LevelDebug) {
+					printAttempt(log, entry)
+				}
+				if err := cfg.trace.Write(entry); err != nil {
+					log.Warning("Failed to write trace: %v", err)
+				}
+			}
+		}
+		result, err := extractor.ExtractWithContext(cfg.context(), e, filePath, options)
+		if err != nil {
+			log.Warning("%s found nothing: %v", e.Name(), err)
+			continue
+		}
+		// Payloads in other channels or methods are reported alongside the best one
+		for _, payload := range append([]*models.ExtractionResult{result}, result.Alternates...) {
+			log.Success("Extracted %d bytes with %s", payload.DataSize, payload.Algorithm)
+			payloads = append(payloads, payload)
+			for _, path := range payload.OutputFiles {
+				log.Printf("   Saved to: %s\n", path)
+				outputFiles = append(outputFiles, path)
+			}
+			if matches := cfg.c2.Match(payload.ExtractedData); len(matches) >= c2.MinMatches {
+				log.Alert("Extracted payload contains C2-style commands: %s", strings.Join(matches, ", "))
+				analysis.AddFindingWithSeverity("Extracted payload contains C2-style commands", models.SeverityConfirmed, 1.0,
+					fmt.Sprintf("%s payload: %s", payload.Algorithm, strings.Join(matches, ", ")))
+			}
+			urls := cfg.urls.Expand(cfg.context(), payload.ExtractedData)
+			for _, u := range urls {
+				if reasons := u.Reasons(); len(reasons) > 0 {
+					log.Alert("Extracted payload links to %s (%s)", u.URL, strings.Join(reasons, ", "))
+				}
+			}
+			c2.ApplyURLs(urls, payload.Algorithm+" payload", analysis)
+			if hits := cfg.rules.Evaluate(payload.ExtractedData); len(hits) > 0 {
+				for _, hit := range hits {
+					log.Alert("Rule %s (%s): %s, e.g. %q", hit.Rule.ID, hit.Rule.Severity, hit.Rule.Description, hit.Sample)
+				}
+				rules.Apply(hits, payload.Algorithm+" payload", analysis)
+			}
+		}
+	}
+
+	if len(attempts) > 0 {
+		if analysis.Details == nil {
+			analysis.Details = map[string]interface{}{}
+		}
+		analysis.Details["attempts"] = attempts
+	}
+	if len(outputFiles) > 0 {
+		if analysis.Details == nil {
+			analysis.Details = map[string]interface{}{}
+		}
+		analysis.Details["extracted_files"] = outputFiles
+	}
+	return payloads
+}
+
+// setHeatmapFile records the heatmap written for a result, for the HTML report
+func setHeatmapFile(result *models.AnalysisResult, path string) {
+	if result == nil || path == "" {
+		return
+	}
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	result.Details["heatmap_file"] = path
+}
+
+// extractorsForHints returns the extractors registered for format, starting
+// with the ones that handle the hinted algorithms in hint order. It also returns
+// the hinted algorithms no extractor handles.
+func extractorsForHints(registry *extractor.Registry, format string, hints []string) ([]extractor.DataExtractor, []string) {
+	var ordered []extractor.DataExtractor
+	var unhandled []string
+	seen := make(map[string]bool)
+	missing := make(map[string]bool)
+	for _, algorithm := range hints {
+		e := registry.GetExtractorForAlgorithm(algorithm, format)
+		if e == nil {
+			if !missing[algorithm] {
+				missing[algorithm] = true
+				unhandled = append(unhandled, algorithm)
+			}
+			continue
+		}
+		if !seen[e.Name()] {
+			seen[e.Name()] = true
+			ordered = append(ordered, e)
+		}
+	}
+	for _, e := range registry.GetExtractorsForFormat(format) {
+		if !seen[e.Name()] {
+			seen[e.Name()] = true
+			ordered = append(ordered, e)
+		}
+	}
+	return ordered, unhandled
+}
+
+// loadC2Detector returns the detector for the command list at path, or the
+// built-in list when path is empty
+func loadC2Detector(path string) (*c2.Detector, error) {
+	if path == "" {
+		return c2.NewDetector(), nil
+	}
+	return c2.LoadDetector(path)
+}
+
+// newURLExpander returns the expander for the URLs in extracted payloads, which
+// sends HEAD requests only when resolve is set
+func newURLExpander(resolve bool) *c2.URLExpander {
+	if !resolve {
+		return &c2.URLExpander{}
+	}
+	return &c2.URLExpander{Resolver: c2.NewHTTPResolver(c2.DefaultResolveTimeout)}
+}
+
+// loadRules returns the built-in indicator rules, extended with the rules file
+// at path when one is given
+func loadRules(path string) (*rules.RuleSet, error) {
+	if path == "" {
+		return rules.Default(), nil
+	}
+	return rules.Load(path)
+}
+
+// cacheVersion identifies the code whose results may be reused: the build of
+// the running binary and every registered analyzer, with the contents of each
+// plugin executable, which can change without DeSteGo being rebuilt
+func cacheVersion(registry *analyzer.Registry) string {
+	var names []string
+	seen := make(map[string]bool)
+	formats := registry.GetSupportedFormats()
+	sort.Strings(formats)
+	for _, format := range formats {
+		for _, a := range registry.GetAnalyzersForFormat(format) {
+			if seen[a.Name()] {
+				continue
+			}
+			seen[a.Name()] = true
+			name := a.Name()
+			if p, ok := a.(*plugin.Analyzer); ok {
+				name += "@" + fileRevision(p.Path())
+			}
+			names = append(names, name)
+		}
+	}
+	return version + "+" + buildRevision() + "|" + strings.Join(names, ",")
+}
+
+// buildRevision identifies the code of the running binary. The version string
+// only changes with a tagged build, so the SHA-256 of the executable is used:
+// any change to an analyzer changes it. The VCS revision the binary was built
+// from stands in when the executable cannot be read.
+func buildRevision() string {
+	if path, err := os.Executable(); err == nil {
+		if revision := fileRevision(path); revision != "" {
+			return revision
+		}
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	revision, modified := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if modified {
+		// Uncommitted changes leave the revision as it was, so nothing
+		// identifies this build
+		return fmt.Sprintf("dirty-%d", time.Now().UnixNano())
+	}
+	return revision
+}
+
+// fileRevision returns the first 16 hex digits of a file's SHA-256, or an empty
+// string when it cannot be read
+func fileRevision(path string) string {
+	hash, err := cache.HashFile(path)
+	if err != nil {
+		return ""
+	}
+	return hash[:16]
+}
+
+// overallProgressKey identifies the "N/M files" bar of a parallel scan
+const overallProgressKey = "\x00overall"
+
+// analyzeFilesParallel analyzes files with a pool of workers. Each file's output
+// is buffered and printed as a single block when the file completes, while a
+// progress bar per worker and an overall bar are shown on interactive terminals.
+// Once the scan is interrupted no new files are started, and the files in
+// progress get interruptGrace to complete. It returns the results and the
+// number of files that completed.
+func analyzeFilesParallel(files []string, cfg *scanConfig, workers int) ([]models.AnalysisResult, int) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	tracker := NewProgressTracker(cfg.output(), isTerminalWriter(cfg.output()) && console.Enabled(LevelInfo))
+	tracker.Add(overallProgressKey, "files", len(files))
+	defer tracker.Finish()
+
+	type fileOutcome struct {
+		result *models.AnalysisResult
+		output *bytes.Buffer
+	}
+
+	ctx := cfg.context()
+	jobs := make(chan string)
+	// Buffered so that workers still running after the grace period can finish
+	outcomes := make(chan fileOutcome, len(files))
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				buf := &bytes.Buffer{}
+				tracker.Add(file, filepath.Base(file), 0)
+				result := analyzeFile(file, cfg, console.WithOutput(buf), tracker.GetProgressCallback(file))
+				tracker.Complete(file)
+				outcomes <- fileOutcome{result: result, output: buf}
+			}
+		}()
+	}
+
+	go func() {
+	dispatch:
+		for _, file := range files {
+			if ctx.Err() != nil {
+				break
+			}
+			select {
+			case jobs <- file:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	var results []models.AnalysisResult
+	analyzed := 0
+	var grace <-chan time.Time
+	interrupted := ctx.Done()
+	for {
+		select {
+		case outcome, ok := <-outcomes:
+			if !ok {
+				return results, analyzed
+			}
+			tracker.Write(outcome.output.Bytes())
+			tracker.Increment(overallProgressKey)
+			analyzed++
+			if outcome.result != nil {
+				cfg.emitResult(outcome.result)
+				results = append(results, *outcome.result)
+			}
+		case <-interrupted:
+			interrupted = nil
+			grace = time.After(interruptGrace)
+		case <-grace:
+			printWarning("Stopped waiting for the files still in progress")
+			return results, analyzed
+		}
+	}
+}
+
+// displayAnalysisResult prints a result. Findings below minConfidence are
+// counted but not listed. Results with findings are printed down to LevelWarn,
+// so quiet runs still show them, and clean ones only from LevelInfo.
+func displayAnalysisResult(log *Logger, result *models.AnalysisResult, verbose bool, minConfidence float64) {
+	if reportedSeverity(result, minConfidence) == models.SeverityClean {
+		if !log.Enabled(LevelInfo) {
+			return
+		}
+	} else if log.Enabled(LevelWarn) && !log.Enabled(LevelInfo) {
+		log = log.WithLevel(LevelInfo)
+	}
+
+	log.Println("\n--- Analysis Results ---")
 
 	// Basic info
-	fmt.Printf("File: %s\n", result.Filename)
-	fmt.Printf("Format: %s\n", result.FileType)
+	log.Printf("File: %s\n", result.Filename)
+	log.Printf("Format: %s\n", result.FileType)
 
 	// Detection results
-	if result.DetectionScore > 0.8 {
-		printAlert("HIGH probability of steganography detected (%.2f)", result.DetectionScore)
-	} else if result.DetectionScore > 0.5 {
-		printWarning("MEDIUM probability of steganography detected (%.2f)", result.DetectionScore)
-	} else if result.DetectionScore > 0.2 {
-		printInfo("LOW probability of steganography detected (%.2f)", result.DetectionScore)
-	} else {
-		printSuccess("No steganography detected (%.2f)", result.DetectionScore)
+	switch result.Severity() {
+	case models.SeverityConfirmed:
+		log.Alert("CONFIRMED hidden payload (%.2f)", result.DetectionScore)
+	case models.SeverityHigh:
+		log.Alert("HIGH probability of steganography detected (%.2f)", result.DetectionScore)
+	case models.SeverityMedium:
+		log.Warning("MEDIUM probability of steganography detected (%.2f)", result.DetectionScore)
+	case models.SeverityLow:
+		log.Info("LOW probability of steganography detected (%.2f)", result.DetectionScore)
+	default:
+		log.Success("No steganography detected (%.2f)", result.DetectionScore)
 	}
 
 	// Confidence score
-	fmt.Printf("Detection confidence: %.2f\n", result.Confidence)
+	log.Printf("Detection confidence: %.2f\n", result.Confidence)
 
 	// Algorithm detection
 	if result.PossibleAlgorithm != "" {
-		fmt.Printf("Possible algorithm: %s\n", result.PossibleAlgorithm)
+		log.Printf("Possible algorithm: %s\n", result.PossibleAlgorithm)
+	}
+
+	if verbose {
+		if capacity := formatCapacity(result.Details["capacity"]); capacity != "" {
+			log.Printf("Capacity: %s\n", capacity)
+		}
+		if probability, ok := result.Details["ensemble_probability"].(float64); ok {
+			log.Printf("Ensemble probability: %.2f\n", probability)
+		}
+		if rationale, ok := result.Details["clean_rationale"].(string); ok {
+			log.Printf("Why clean: %s\n", rationale)
+		}
 	}
 
 	// Findings
-	if len(result.Findings) > 0 {
-		fmt.Println("\nFindings:")
-		for i, finding := range result.Findings {
-			fmt.Printf("%d. %s (Confidence: %.2f)\n", i+1, finding.Description, finding.Confidence)
+	findings := reportedFindings(result, minConfidence)
+	if len(findings) > 0 {
+		log.Println("\nFindings:")
+		for i, finding := range findings {
+			log.Printf("%d. %s (Confidence: %.2f)\n", i+1, finding.Description, finding.Confidence)
 			if verbose && finding.Details != "" {
-				fmt.Printf("   Details: %s\n", finding.Details)
+				log.Printf("   Details: %s\n", finding.Details)
 			}
 		}
 	}
+	if hidden := len(result.Findings) - len(findings); hidden > 0 {
+		log.Printf("(%d finding(s) below confidence %.2f not shown)\n", hidden, minConfidence)
+	}
 
 	// Recommendations
 	if len(result.Recommendations) > 0 {
-		fmt.Println("\nRecommendations:")
+		log.Println("\nRecommendations:")
 		for i, rec := range result.Recommendations {
-			fmt.Printf("%d. %s\n", i+1, rec)
+			log.Printf("%d. %s\n", i+1, rec)
 		}
 	}
 
-	fmt.Println("-------------------------")
+	log.Println("-------------------------")
 }
 
-func printSummary(results []models.AnalysisResult) {
-	var clean, suspicious, confirmed int
+// capacityLabels names the capacity estimates in the order they are shown
+var capacityLabels = []struct{ key, label string }{
+	{lsbanalyzer.CapacityLSBRGB, "1-bit LSB (RGB)"},
+	{lsbanalyzer.CapacityLSBRGBA, "1-bit LSB (RGBA)"},
+	{lsbanalyzer.CapacityLSB2RGB, "2-bit LSB (RGB)"},
+	{lsbanalyzer.CapacityJSteg, "JSteg"},
+}
 
-	for _, result := range results {
-		if result.DetectionScore < 0.2 {
-			clean++
-		} else if result.DetectionScore < 0.7 {
-			suspicious++
-		} else {
-			confirmed++
+// formatCapacity renders a Details["capacity"] value, which is a map[string]int
+// from an analyzer or a map[string]interface{} from the result cache
+func formatCapacity(value interface{}) string {
+	capacity := map[string]int{}
+	switch v := value.(type) {
+	case map[string]int:
+		capacity = v
+	case map[string]interface{}:
+		for key, n := range v {
+			if f, ok := n.(float64); ok {
+				capacity[key] = int(f)
+			}
 		}
 	}
 
-	fmt.Println("\n=== Analysis Summary ===")
-	fmt.Printf("Total files analyzed: %d\n", len(results))
-	fmt.Printf("%sClean files: %d%s\n", successColor("[+]"), clean, "")
-
-	if suspicious > 0 {
-		fmt.Printf("%sSuspicious files: %d%s\n", warningColor("[!]"), suspicious, "")
+	var parts []string
+	for _, c := range capacityLabels {
+		if n, ok := capacity[c.key]; ok {
+			parts = append(parts, fmt.Sprintf("%s %d bytes", c.label, n))
+		}
 	}
+	return strings.Join(parts, ", ")
+}
 
-	if confirmed > 0 {
-		fmt.Printf("%sConfirmed steganography: %d%s\n", alertColor("[!!!]"), confirmed, "")
-
-		fmt.Println("\nFiles with high probability of steganography:")
-		for _, result := range results {
-			if result.DetectionScore >= 0.7 {
-				fmt.Printf("- %s (Score: %.2f)\n", result.Filename, result.DetectionScore)
-			}
+// reportedFindings returns the findings of a result with at least the given confidence
+func reportedFindings(result *models.AnalysisResult, minConfidence float64) []models.Finding {
+	if minConfidence <= 0 {
+		return result.Findings
+	}
+	var findings []models.Finding
+	for _, finding := range result.Findings {
+		if finding.Confidence >= minConfidence {
+			findings = append(findings, finding)
 		}
 	}
+	return findings
+}
+
+// reportedSeverity is the severity a result is summarized with: its own
+// severity, or clean when minConfidence hides all of its findings
+func reportedSeverity(result *models.AnalysisResult, minConfidence float64) models.Severity {
+	if minConfidence > 0 && len(reportedFindings(result, minConfidence)) == 0 {
+		return models.SeverityClean
+	}
+	return result.Severity()
 }
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/c2"
+	"DeSteGo/pkg/extractor"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/rules"
+)
+
+func TestScanAll(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		fixture string // Empty for a file that is not an image
+		format  string // Format -scanall analyzes the file as
+	}{
+		{"photo.dat", "clean.png", "png"},
+		{"renamed.jpg", "lsb_rgb.png", "png"},
+		{"upload.bin", "clean.jpg", "jpeg"},
+		{"noext", "clean.gif", "gif"},
+		{"notes.txt", "", ""},
+	}
+	for _, tt := range tests {
+		data := []byte("not an image, just some notes\n")
+		if tt.fixture != "" {
+			var err error
+			if data, err = fixtures.Load(tt.fixture); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(dir, tt.name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	registry := analyzer.NewRegistry()
+	registerAnalyzers(registry)
+	cfg := &scanConfig{registry: registry, format: "auto", sequential: true, scanAll: true}
+	files, err := filehandler.GatherFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	images := sniffImageFiles(files, registry)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if kept := slices.Contains(images, path); kept != (tt.format != "") {
+				t.Fatalf("kept %v, want %v", kept, tt.format != "")
+			}
+			if tt.format == "" {
+				return
+			}
+			result := analyzeFile(path, cfg, NewLogger(io.Discard), nil)
+			if result == nil || result.FileType != tt.format {
+				t.Errorf("got result %+v, want a %s analysis", result, tt.format)
+			}
+		})
+	}
+}
+
+func TestParallelOutput(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for i, fixture := range []string{"lsb_rgb.png", "clean.png", "lsb_rgb.png", "appended_zip.jpg", "clean.png", "lsb_rgb.png"} {
+		data, err := fixtures.Load(fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, string(rune('a'+i))+"_"+fixture)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+
+	tests := []struct {
+		name    string
+		verbose bool
+		extract bool
+	}{
+		{"default", false, false},
+		{"verbose", true, false},
+		{"verbose extraction", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := analyzer.NewRegistry()
+			registerAnalyzers(registry)
+			extractors := extractor.NewRegistry()
+			registerExtractors(extractors)
+			var out bytes.Buffer
+			cfg := &scanConfig{registry: registry, extractors: extractors, c2: c2.NewDetector(), rules: rules.Default(), format: "auto", verbose: tt.verbose, extract: tt.extract, out: &out}
+			// Timings and output directories differ between the two scans
+			timing := regexp.MustCompile(`completed in .*`)
+			normalize := func(output string) string {
+				return timing.ReplaceAllString(strings.ReplaceAll(output, cfg.outputDir, "OUT"), "completed")
+			}
+
+			// Each file's block as a scan of that file alone prints it
+			blocks := make(map[string]string)
+			cfg.outputDir = t.TempDir()
+			for _, file := range files {
+				var block bytes.Buffer
+				if analyzeFile(file, cfg, NewLogger(&block), nil) == nil {
+					t.Fatalf("analysis of %s failed", file)
+				}
+				blocks[file] = normalize(block.String())
+			}
+
+			cfg.outputDir = t.TempDir()
+			results, analyzed := analyzeFilesParallel(files, cfg, 4)
+			if analyzed != len(files) || len(results) != len(files) {
+				t.Fatalf("analyzed %d files with %d results, want %d", analyzed, len(results), len(files))
+			}
+			output := normalize(out.String())
+			for _, file := range files {
+				if !strings.Contains(output, blocks[file]) {
+					t.Errorf("output of %s is not contiguous; want block:\n%s\ngot output:\n%s", filepath.Base(file), blocks[file], output)
+				}
+			}
+		})
+	}
+}
+
+func TestStdin(t *testing.T) {
+	binary := buildBinary(t)
+
+	tests := []struct {
+		name    string
+		fixture string
+		args    []string
+		code    int
+		payload string // File the extract command must write, if any
+	}{
+		{"clean PNG", "clean.png", []string{"-failon", "0.5"}, exitClean, ""},
+		{"LSB PNG", "lsb_rgb.png", []string{"-failon", "0.5"}, exitSuspicious, ""},
+		{"JPEG with appended ZIP", "appended_zip.jpg", []string{"-failon", "0.5"}, exitSuspicious, ""},
+		{"extract appended ZIP", "appended_zip.jpg", []string{"extract", "-outlayout", "flat"}, exitClean, "extracted_appended.zip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := fixtures.Load(tt.fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			outDir := t.TempDir()
+			args := append(tt.args, "-file", "-", "-outdir", outDir)
+			if tt.args[0] != "extract" {
+				args = append(args, "-nocache")
+			}
+			cmd := exec.Command(binary, args...)
+			cmd.Stdin = bytes.NewReader(data)
+			out, err := cmd.CombinedOutput()
+			code := 0
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.code {
+				t.Errorf("exit code %d, want %d\n%s", code, tt.code, lastLines(string(out), 10))
+			}
+			if tt.payload == "" {
+				return
+			}
+			if _, err := os.Stat(filepath.Join(outDir, tt.payload)); err != nil {
+				t.Errorf("no %s in the output directory: %v\n%s", tt.payload, err, lastLines(string(out), 10))
+			}
+		})
+	}
+}
+
+func TestDetectionConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "raised.json")
+	config := `{"lsbAnomalyHigh": 1, "lsbAnomalyUnusual": 1, "lsbEntropyHigh": 1, "lsbEntropyLow": 0,
+		"planeCorrelation": 1, "alphaEntropy": 1, "parityEvenRatio": 1, "detectorProbability": 1}`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	raised, err := analyzer.LoadDetectionConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		fixture       string
+		flagged       bool // Whether the built-in thresholds report findings
+		flaggedRaised bool
+	}{
+		{"clean.jpg", false, false},
+		{"recompressed_lsb.jpg", true, false},
+		// Appended data is a fact of the file structure, not a threshold
+		{"appended_zip.jpg", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			data, err := fixtures.Load(tt.fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file := filepath.Join(dir, tt.fixture)
+			if err := os.WriteFile(file, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			for _, detection := range []*analyzer.DetectionConfig{nil, &raised} {
+				registry := analyzer.NewRegistry()
+				registerAnalyzers(registry)
+				cfg := &scanConfig{registry: registry, format: "auto", sequential: true, detection: detection}
+				result := analyzeFile(file, cfg, NewLogger(io.Discard), nil)
+				if result == nil {
+					t.Fatal("analysis failed")
+				}
+				want := tt.flagged
+				if detection != nil {
+					want = tt.flaggedRaised
+				}
+				if got := len(result.Findings) > 0; got != want {
+					t.Errorf("raised thresholds %v: got flagged %v, want %v (findings %v)", detection != nil, got, want, result.Findings)
+				}
+			}
+		})
+	}
+}
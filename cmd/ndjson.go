@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the -ndjson output. The result of each input is written as
+one line of JSON as soon as the file completes, in the order the files
+complete, so log shippers and other tools reading the stream can process a
+scan while it runs. Every line is written to the file with a single write, so
+nothing waits in a buffer. With -ndjson - the lines go to standard output and
+the console messages move to standard error, keeping the stream parseable.
+*/
+
+// resultWriter writes analysis results as JSON lines. It is safe for
+// concurrent use.
+type resultWriter struct {
+	mu     sync.Mutex
+	closer io.Closer
+	enc    *json.Encoder
+}
+
+// openResults creates (or truncates) the NDJSON file at path, or writes to
+// standard output when path is "-"
+func openResults(path string) (*resultWriter, error) {
+	if path == "-" {
+		return &resultWriter{enc: json.NewEncoder(os.Stdout)}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create NDJSON file: %w", err)
+	}
+	return &resultWriter{closer: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Write writes result as one line. A nil writer discards it.
+func (r *resultWriter) Write(result *models.AnalysisResult) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enc.Encode(result)
+}
+
+// Close closes the NDJSON file. A nil writer, or one writing to standard
+// output, does nothing.
+func (r *resultWriter) Close() error {
+	if r == nil || r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// emitResult writes the result of an input file to the -ndjson output, if any
+func (cfg *scanConfig) emitResult(result *models.AnalysisResult) {
+	if result == nil {
+		return
+	}
+	if err := cfg.ndjson.Write(result); err != nil {
+		printWarning("Failed to write NDJSON result: %v", err)
+	}
+}
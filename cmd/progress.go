@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// progressBarWidth is the number of cells in a rendered bar
+const progressBarWidth = 30
+
+// progressBar is the state of a single bar
+type progressBar struct {
+	label   string
+	current int
+	total   int
+}
+
+// ProgressTracker renders any number of progress bars below the regular output.
+// All terminal writes go through the tracker so bars and output never overlap.
+// When disabled (e.g. output is piped) it only forwards output.
+type ProgressTracker struct {
+	mu       sync.Mutex
+	out      io.Writer
+	enabled  bool
+	bars     map[string]*progressBar
+	order    []string
+	rendered int // Number of bar lines currently on screen
+}
+
+// NewProgressTracker creates a tracker drawing to out when enabled is true
+func NewProgressTracker(out io.Writer, enabled bool) *ProgressTracker {
+	return &ProgressTracker{
+		out:     out,
+		enabled: enabled,
+		bars:    make(map[string]*progressBar),
+	}
+}
+
+// Add creates (or resets) the bar identified by key
+func (p *ProgressTracker) Add(key, label string, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.bars[key]; !ok {
+		p.order = append(p.order, key)
+	}
+	p.bars[key] = &progressBar{label: label, total: total}
+	p.render()
+}
+
+// Update sets the progress of a bar
+func (p *ProgressTracker) Update(key string, current, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	bar, ok := p.bars[key]
+	if !ok {
+		return
+	}
+	bar.current, bar.total = current, total
+	p.render()
+}
+
+// Increment advances a bar by one step
+func (p *ProgressTracker) Increment(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if bar, ok := p.bars[key]; ok {
+		bar.current++
+		p.render()
+	}
+}
+
+// Complete removes a bar
+func (p *ProgressTracker) Complete(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.bars[key]; !ok {
+		return
+	}
+	delete(p.bars, key)
+	for i, k := range p.order {
+		if k == key {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+	p.render()
+}
+
+// Count returns the number of active bars
+func (p *ProgressTracker) Count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.bars)
+}
+
+// Current returns the progress of a bar and whether it exists
+func (p *ProgressTracker) Current(key string) (int, int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	bar, ok := p.bars[key]
+	if !ok {
+		return 0, 0, false
+	}
+	return bar.current, bar.total, true
+}
+
+// GetProgressCallback returns a function that updates the bar identified by key
+func (p *ProgressTracker) GetProgressCallback(key string) func(current, total int) {
+	return func(current, total int) {
+		p.Update(key, current, total)
+	}
+}
+
+// Write prints regular output above the bars
+func (p *ProgressTracker) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clear()
+	n, err := p.out.Write(data)
+	p.render()
+	return n, err
+}
+
+// Finish removes every bar from the screen
+func (p *ProgressTracker) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clear()
+	p.bars = make(map[string]*progressBar)
+	p.order = nil
+}
+
+// clear erases the rendered bars; the caller must hold the lock
+func (p *ProgressTracker) clear() {
+	if !p.enabled || p.rendered == 0 {
+		return
+	}
+	var sb strings.Builder
+	for i := 0; i < p.rendered; i++ {
+		sb.WriteString("\033[2K\n")
+	}
+	fmt.Fprintf(&sb, "\033[%dA", p.rendered)
+	io.WriteString(p.out, sb.String())
+	p.rendered = 0
+}
+
+// render redraws the bars below the cursor; the caller must hold the lock
+func (p *ProgressTracker) render() {
+	if !p.enabled {
+		return
+	}
+	p.clear()
+	if len(p.order) == 0 {
+		return
+	}
+
+	// Keep the overall bar (added first) on top and sort the rest by label
+	keys := append([]string(nil), p.order[1:]...)
+	sort.Slice(keys, func(i, j int) bool { return p.bars[keys[i]].label < p.bars[keys[j]].label })
+	keys = append([]string{p.order[0]}, keys...)
+
+	var sb strings.Builder
+	for _, key := range keys {
+		sb.WriteString("\033[2K")
+		sb.WriteString(formatBar(p.bars[key]))
+		sb.WriteString("\n")
+	}
+	fmt.Fprintf(&sb, "\033[%dA", len(keys))
+	io.WriteString(p.out, sb.String())
+	p.rendered = len(keys)
+}
+
+// formatBar renders a bar as "[#####-----] 3/10 label"
+func formatBar(bar *progressBar) string {
+	filled := 0
+	if bar.total > 0 {
+		filled = bar.current * progressBarWidth / bar.total
+		if filled > progressBarWidth {
+			filled = progressBarWidth
+		}
+	}
+	return fmt.Sprintf("[%s%s] %d/%d %s",
+		strings.Repeat("#", filled), strings.Repeat("-", progressBarWidth-filled),
+		bar.current, bar.total, bar.label)
+}
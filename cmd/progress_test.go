@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestProgressTracker(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   int
+		workers int
+		enabled bool
+	}{
+		{"one worker", 5, 1, true},
+		{"concurrent workers", 40, 8, true},
+		{"disabled", 10, 4, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			tracker := NewProgressTracker(&out, tt.enabled)
+			tracker.Add(overallProgressKey, "files", tt.files)
+
+			// Same sequence of calls as analyzeFilesParallel
+			jobs := make(chan string)
+			var wg sync.WaitGroup
+			for range tt.workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for file := range jobs {
+						tracker.Add(file, file, 0)
+						progress := tracker.GetProgressCallback(file)
+						for i := 1; i <= 3; i++ {
+							progress(i, 3)
+						}
+						tracker.Complete(file)
+						if _, _, ok := tracker.Current(file); ok {
+							t.Errorf("bar of %s still present after Complete", file)
+						}
+						tracker.Write([]byte(file + " done\n"))
+						tracker.Increment(overallProgressKey)
+					}
+				}()
+			}
+			for i := range tt.files {
+				jobs <- fmt.Sprintf("file%02d.png", i)
+			}
+			close(jobs)
+			wg.Wait()
+
+			if n := tracker.Count(); n != 1 {
+				t.Errorf("got %d bars after every file completed, want 1", n)
+			}
+			current, total, ok := tracker.Current(overallProgressKey)
+			if !ok || current != tt.files || total != tt.files {
+				t.Errorf("got overall %d/%d, want %d/%d", current, total, tt.files, tt.files)
+			}
+			if got := strings.Count(out.String(), " done\n"); got != tt.files {
+				t.Errorf("got %d output lines, want %d", got, tt.files)
+			}
+			if hasEscape := strings.Contains(out.String(), "\033["); hasEscape != tt.enabled {
+				t.Errorf("escape sequences written %v, want %v", hasEscape, tt.enabled)
+			}
+
+			tracker.Finish()
+			if n := tracker.Count(); n != 0 {
+				t.Errorf("got %d bars after Finish, want 0", n)
+			}
+		})
+	}
+}
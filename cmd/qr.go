@@ -0,0 +1,31 @@
+package main
+
+import (
+	"DeSteGo/pkg/analyzer/image/lenient"
+	"DeSteGo/pkg/analyzer/image/qr"
+	"DeSteGo/pkg/models"
+)
+
+// scanQRCodes searches an analyzed image for QR codes and adds what they
+// decode to its result. Files that do not decode are left as they are.
+func scanQRCodes(file *lenient.File, result *models.AnalysisResult, cfg *scanConfig, log *Logger) {
+	decoded, err := file.Decode()
+	if err != nil {
+		log.Warning("Skipping the QR scan, the image does not decode: %v", err)
+		return
+	}
+	img := decoded.Image
+
+	log.Info("Searching for QR codes")
+	before := len(result.Findings)
+	if err := qr.Analyze(img, cfg.c2, cfg.rules, result); err != nil {
+		log.Warning("QR scan failed: %v", err)
+		return
+	}
+	for _, finding := range result.Findings[before:] {
+		log.Alert("%s: %s", finding.Description, finding.Details)
+	}
+	// The rationale is rebuilt with the QR check, or dropped if a code was found
+	delete(result.Details, "clean_rationale")
+	result.SetCleanRationale()
+}
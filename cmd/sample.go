@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// fileSample records how the inputs of a run were sampled
+type fileSample struct {
+	total int   // Inputs gathered before sampling
+	kept  int   // Inputs left after sampling
+	seed  int64 // Seed that reproduces the selection
+}
+
+// sampleSize returns how many of total inputs to keep for a fraction (0 to 1,
+// 0 meaning unset) and a count (0 meaning unset). When both are set the smaller
+// wins; a positive fraction keeps at least one input.
+func sampleSize(total int, fraction float64, count int) int {
+	n := total
+	if fraction > 0 {
+		n = max(int(math.Round(fraction*float64(total))), 1)
+	}
+	if count > 0 && count < n {
+		n = count
+	}
+	return min(n, total)
+}
+
+// sampleFiles picks n of files at random, using seed so the same seed gives
+// the same selection. The kept files stay in their original order.
+func sampleFiles(files []string, n int, seed int64) []string {
+	if n >= len(files) {
+		return files
+	}
+	picked := rand.New(rand.NewSource(seed)).Perm(len(files))[:n]
+	sort.Ints(picked)
+
+	sample := make([]string, n)
+	for i, index := range picked {
+		sample[i] = files[index]
+	}
+	return sample
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestSampleSize(t *testing.T) {
+	tests := []struct {
+		total    int
+		fraction float64
+		count    int
+		want     int
+	}{
+		{100, 0, 0, 100},
+		{100, 0.25, 0, 25},
+		{100, 1, 0, 100},
+		{7, 0.5, 0, 4},
+		{1000, 0.0001, 0, 1},
+		{100, 0, 10, 10},
+		{100, 0, 500, 100},
+		{100, 0.5, 10, 10},
+		{100, 0.05, 10, 5},
+		{0, 0.5, 3, 0},
+	}
+	for _, tt := range tests {
+		if got := sampleSize(tt.total, tt.fraction, tt.count); got != tt.want {
+			t.Errorf("sampleSize(%d, %v, %d) = %d, want %d", tt.total, tt.fraction, tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestSampleFiles(t *testing.T) {
+	files := make([]string, 200)
+	for i := range files {
+		files[i] = fmt.Sprintf("image%03d.png", i)
+	}
+
+	for _, fraction := range []float64{0.1, 0.25, 0.5} {
+		t.Run(fmt.Sprint(fraction), func(t *testing.T) {
+			n := sampleSize(len(files), fraction, 0)
+			sample := sampleFiles(files, n, 42)
+			if want := int(fraction * float64(len(files))); len(sample) != want {
+				t.Fatalf("sampled %d files, want %d", len(sample), want)
+			}
+			if !slices.Equal(sample, sampleFiles(files, n, 42)) {
+				t.Error("the same seed picked a different sample")
+			}
+			if slices.Equal(sample, sampleFiles(files, n, 43)) {
+				t.Error("a different seed picked the same sample")
+			}
+			if !slices.IsSorted(sample) || len(slices.Compact(slices.Clone(sample))) != n {
+				t.Error("sample is not in gathered order without repeats")
+			}
+		})
+	}
+
+	if sample := sampleFiles(files, len(files), 1); !slices.Equal(sample, files) {
+		t.Error("keeping every file changed the selection")
+	}
+}
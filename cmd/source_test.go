@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/analyzer/image/lenient"
+	"DeSteGo/pkg/extractor"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+// analyzeAndExtract runs every analyzer and every extractor for the file's
+// format, sharing source between them when it is not nil. Extracted payloads
+// are written to outputDir.
+func analyzeAndExtract(t testing.TB, filePath, outputDir string, source models.Source) ([]*models.AnalysisResult, []*models.ExtractionResult) {
+	t.Helper()
+	format, err := filehandler.DetectFileFormat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analyzers := analyzer.NewRegistry()
+	registerAnalyzers(analyzers)
+	extractors := extractor.NewRegistry()
+	registerExtractors(extractors)
+
+	var analyses []*models.AnalysisResult
+	options := analyzer.AnalysisOptions{Format: format, Source: source}
+	for _, a := range analyzers.GetAnalyzersForFormat(format) {
+		result, err := a.Analyze(filePath, options)
+		if err != nil {
+			t.Fatalf("%s: %v", a.Name(), err)
+		}
+		analyses = append(analyses, result)
+	}
+
+	var extractions []*models.ExtractionResult
+	for _, e := range extractors.GetExtractorsForFormat(format) {
+		result, err := e.Extract(filePath, extractor.ExtractionOptions{OutputDir: outputDir, Source: source})
+		if err != nil {
+			continue
+		}
+		extractions = append(extractions, result)
+	}
+	return analyses, extractions
+}
+
+func TestSharedSource(t *testing.T) {
+	for _, f := range fixtures.Fixtures {
+		t.Run(f.Name, func(t *testing.T) {
+			path := fixtures.Path(f.Name)
+			separate, separateExtractions := analyzeAndExtract(t, path, t.TempDir(), nil)
+			shared, sharedExtractions := analyzeAndExtract(t, path, t.TempDir(), lenient.NewFile(path))
+
+			if !reflect.DeepEqual(separate, shared) {
+				t.Errorf("analysis results differ with a shared source:\nseparate %+v\nshared   %+v", separate, shared)
+			}
+			if len(separateExtractions) != len(sharedExtractions) {
+				t.Fatalf("%d extractions with a shared source, want %d", len(sharedExtractions), len(separateExtractions))
+			}
+			for i, want := range separateExtractions {
+				got := sharedExtractions[i]
+				if got.Success != want.Success || got.Algorithm != want.Algorithm || string(got.ExtractedData) != string(want.ExtractedData) {
+					t.Errorf("extraction %d differs with a shared source: got %s (%d bytes), want %s (%d bytes)",
+						i, got.Algorithm, len(got.ExtractedData), want.Algorithm, len(want.ExtractedData))
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSharedSource compares analyzing and extracting from a JPEG with
+// every analyzer and extractor reading and decoding the file on its own and
+// with them sharing one source
+func BenchmarkSharedSource(b *testing.B) {
+	path := fixtures.Path("appended_zip.jpg")
+	dir := b.TempDir()
+	b.Run("separate", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			analyzeAndExtract(b, path, dir, nil)
+		}
+	})
+	b.Run("shared", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			analyzeAndExtract(b, path, dir, lenient.NewFile(path))
+		}
+	})
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the summary of a scan of several files. ScanResults counts
+the files in each severity band and aggregates the results across files: how
+many files each detector scored and flagged, and how many files each finding
+was reported for. A detector that flags most of a set of images from one
+camera, or a finding that every file shares, points at a property of the set
+rather than at hidden data, which a per-file report does not show. Files whose
+findings are all below the -min-confidence threshold count as clean, and their
+findings are not tallied.
+*/
+
+// summaryFindingLimit is the number of findings the summary lists
+const summaryFindingLimit = 5
+
+// ScanResults is the outcome of a scan of several files
+type ScanResults struct {
+	Files      []ScanResult
+	TotalFiles int
+	Clean      int
+	Suspicious int             // Files of low or medium severity
+	High       int             // Files of high severity
+	Confirmed  int             // Files of confirmed severity
+	Skipped    int             // Files not analyzed because the scan was interrupted
+	Detectors  []DetectorTally // Detectors that scored any file, most files flagged first
+	Findings   []FindingTally  // Reported findings, most files first
+}
+
+// ScanResult is one file of a scan as the summary reports it
+type ScanResult struct {
+	Filename string
+	Score    float64
+	Severity models.Severity // Clean when every finding is below -min-confidence
+	Findings int             // Findings at or above -min-confidence
+}
+
+// DetectorTally aggregates one detector's probabilities across files
+type DetectorTally struct {
+	Detector string
+	Scored   int     // Files the detector gave a probability
+	Flagged  int     // Files it gave a probability above 0.5
+	Highest  float64 // Highest probability it gave
+}
+
+// FindingTally counts the files a finding was reported for
+type FindingTally struct {
+	Description string
+	Files       int
+	Highest     float64 // Highest confidence it was reported with
+}
+
+// newScanResults summarizes the results of a scan. Findings below
+// minConfidence are left out, as they are from the per-file output.
+func newScanResults(results []models.AnalysisResult, minConfidence float64) ScanResults {
+	scan := ScanResults{TotalFiles: len(results)}
+	detectors := map[string]*DetectorTally{}
+	findings := map[string]*FindingTally{}
+
+	for i := range results {
+		result := &results[i]
+		reported := reportedFindings(result, minConfidence)
+		severity := reportedSeverity(result, minConfidence)
+		scan.Files = append(scan.Files, ScanResult{
+			Filename: result.Filename,
+			Score:    result.DetectionScore,
+			Severity: severity,
+			Findings: len(reported),
+		})
+		switch severity {
+		case models.SeverityClean:
+			scan.Clean++
+		case models.SeverityLow, models.SeverityMedium:
+			scan.Suspicious++
+		case models.SeverityHigh:
+			scan.High++
+		default:
+			scan.Confirmed++
+		}
+
+		for detector, probability := range result.DetectorScores {
+			tally, ok := detectors[detector]
+			if !ok {
+				tally = &DetectorTally{Detector: detector}
+				detectors[detector] = tally
+			}
+			tally.Scored++
+			if probability > 0.5 {
+				tally.Flagged++
+			}
+			tally.Highest = max(tally.Highest, probability)
+		}
+
+		// A finding reported twice for one file counts once
+		seen := map[string]bool{}
+		for _, finding := range reported {
+			tally, ok := findings[finding.Description]
+			if !ok {
+				tally = &FindingTally{Description: finding.Description}
+				findings[finding.Description] = tally
+			}
+			if !seen[finding.Description] {
+				seen[finding.Description] = true
+				tally.Files++
+			}
+			tally.Highest = max(tally.Highest, finding.Confidence)
+		}
+	}
+
+	for _, tally := range detectors {
+		scan.Detectors = append(scan.Detectors, *tally)
+	}
+	sort.Slice(scan.Detectors, func(i, j int) bool {
+		a, b := scan.Detectors[i], scan.Detectors[j]
+		if a.Flagged != b.Flagged {
+			return a.Flagged > b.Flagged
+		}
+		return a.Detector < b.Detector
+	})
+	for _, tally := range findings {
+		scan.Findings = append(scan.Findings, *tally)
+	}
+	sort.Slice(scan.Findings, func(i, j int) bool {
+		a, b := scan.Findings[i], scan.Findings[j]
+		if a.Files != b.Files {
+			return a.Files > b.Files
+		}
+		return a.Description < b.Description
+	})
+	return scan
+}
+
+// printSummary prints how many files fall in each severity bucket and, for a
+// scan of several files, the detectors that flagged any of them and the
+// findings reported for more than one
+func printSummary(out io.Writer, scan ScanResults, sample *fileSample) {
+	fmt.Fprintln(out, "\n=== Analysis Summary ===")
+	fmt.Fprintf(out, "Total files analyzed: %d\n", scan.TotalFiles)
+	if scan.Skipped > 0 {
+		fmt.Fprintf(out, "%sScan interrupted: %d files were not analyzed%s\n", warningColor("[!]"), scan.Skipped, "")
+	}
+	if sample != nil {
+		fmt.Fprintf(out, "Sampled %d of %d inputs (-seed %d)\n", sample.kept, sample.total, sample.seed)
+	}
+	fmt.Fprintf(out, "%sClean files: %d%s\n", successColor("[+]"), scan.Clean, "")
+
+	if scan.Suspicious > 0 {
+		fmt.Fprintf(out, "%sSuspicious files: %d%s\n", warningColor("[!]"), scan.Suspicious, "")
+	}
+
+	if scan.High > 0 {
+		fmt.Fprintf(out, "%sHigh probability files: %d%s\n", alertColor("[!!!]"), scan.High, "")
+	}
+	if scan.Confirmed > 0 {
+		fmt.Fprintf(out, "%sConfirmed steganography: %d%s\n", alertColor("[!!!]"), scan.Confirmed, "")
+	}
+
+	if scan.High+scan.Confirmed > 0 {
+
+		fmt.Fprintln(out, "\nFiles with high probability of steganography:")
+		for _, file := range scan.Files {
+			if file.Severity >= models.SeverityHigh {
+				fmt.Fprintf(out, "- %s (Score: %.2f, %s)\n", file.Filename, file.Score, file.Severity)
+			}
+		}
+	}
+	if scan.TotalFiles < 2 {
+		return
+	}
+
+	var flagging []DetectorTally
+	for _, tally := range scan.Detectors {
+		if tally.Flagged > 0 {
+			flagging = append(flagging, tally)
+		}
+	}
+	if len(flagging) > 0 {
+		fmt.Fprintln(out, "\nDetectors that flagged files:")
+		for _, tally := range flagging {
+			fmt.Fprintf(out, "- %s: above 0.5 for %d of the %d files it scored (highest %.2f)\n", tally.Detector, tally.Flagged, tally.Scored, tally.Highest)
+		}
+	}
+
+	var shared []FindingTally
+	for _, tally := range scan.Findings {
+		if tally.Files > 1 && len(shared) < summaryFindingLimit {
+			shared = append(shared, tally)
+		}
+	}
+	if len(shared) > 0 {
+		fmt.Fprintln(out, "\nFindings reported for several files:")
+		for _, tally := range shared {
+			fmt.Fprintf(out, "- %s: %d files (highest confidence %.2f)\n", tally.Description, tally.Files, tally.Highest)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/models"
+)
+
+func TestNewScanResults(t *testing.T) {
+	clean := models.AnalysisResult{Filename: "clean.png"}
+	clean.SetDetectorScore(analyzer.DetectorChiSquare, 0.1)
+
+	lsb := models.AnalysisResult{Filename: "lsb.png"}
+	lsb.AddFinding("Perfect LSB entropy", 0.9, "")
+	lsb.AddFinding("Perfect LSB entropy", 0.7, "second channel")
+	lsb.DetectionScore = 0.9
+	lsb.SetDetectorScore(analyzer.DetectorChiSquare, 0.9)
+	lsb.SetDetectorScore(analyzer.DetectorLSBDistribution, 0.8)
+
+	weak := models.AnalysisResult{Filename: "weak.png"}
+	weak.AddFinding("Perfect LSB entropy", 0.3, "")
+	weak.AddFinding("Unusual chunk order", 0.3, "")
+	weak.DetectionScore = 0.3
+	weak.SetDetectorScore(analyzer.DetectorChiSquare, 0.6)
+
+	c2 := models.AnalysisResult{Filename: "c2.png"}
+	c2.AddFindingWithSeverity("Extracted payload contains C2-style commands", models.SeverityConfirmed, 1.0, "")
+	c2.DetectionScore = 1.0
+
+	results := []models.AnalysisResult{clean, lsb, weak, c2}
+
+	tests := []struct {
+		name          string
+		minConfidence float64
+		clean         int
+		suspicious    int
+		high          int
+		confirmed     int
+		entropyFiles  int // Files the entropy finding is counted for
+		findings      int // Distinct findings tallied
+	}{
+		{"every finding", 0, 1, 1, 1, 1, 2, 3},
+		{"weak findings left out", 0.5, 2, 0, 1, 1, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan := newScanResults(results, tt.minConfidence)
+			if scan.TotalFiles != 4 || len(scan.Files) != 4 {
+				t.Fatalf("%d files and %d file results, want 4", scan.TotalFiles, len(scan.Files))
+			}
+			if scan.Clean != tt.clean || scan.Suspicious != tt.suspicious || scan.High != tt.high || scan.Confirmed != tt.confirmed {
+				t.Errorf("clean %d, suspicious %d, high %d, confirmed %d, want %d, %d, %d, %d",
+					scan.Clean, scan.Suspicious, scan.High, scan.Confirmed, tt.clean, tt.suspicious, tt.high, tt.confirmed)
+			}
+			if file := scan.Files[1]; file.Filename != "lsb.png" || file.Severity != models.SeverityHigh || file.Findings != 2 {
+				t.Errorf("file result %+v, want lsb.png, high, 2 findings", file)
+			}
+
+			if len(scan.Findings) != tt.findings {
+				t.Fatalf("findings %+v, want %d", scan.Findings, tt.findings)
+			}
+			var entropy FindingTally
+			for _, tally := range scan.Findings {
+				if tally.Description == "Perfect LSB entropy" {
+					entropy = tally
+				}
+			}
+			if entropy.Files != tt.entropyFiles || entropy.Highest != 0.9 {
+				t.Errorf("entropy finding %+v, want %d files at 0.9", entropy, tt.entropyFiles)
+			}
+
+			// Detector tallies do not depend on the confidence threshold
+			want := []DetectorTally{
+				{Detector: analyzer.DetectorChiSquare, Scored: 3, Flagged: 2, Highest: 0.9},
+				{Detector: analyzer.DetectorLSBDistribution, Scored: 1, Flagged: 1, Highest: 0.8},
+			}
+			if len(scan.Detectors) != len(want) {
+				t.Fatalf("detectors %+v, want %+v", scan.Detectors, want)
+			}
+			for i := range want {
+				if scan.Detectors[i] != want[i] {
+					t.Errorf("detector %+v, want %+v", scan.Detectors[i], want[i])
+				}
+			}
+		})
+	}
+}
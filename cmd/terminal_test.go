@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+)
+
+func TestPipedOutputIsPlain(t *testing.T) {
+	binary := buildBinary(t)
+
+	dir := t.TempDir()
+	for _, name := range []string{"clean.png", "lsb_rgb.png", "double_eoi.jpg", "appended_zip.gif"} {
+		data, err := fixtures.Load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"flagged file", []string{"-file", fixtures.Path("lsb_rgb.png"), "-verbose", "-extract"}},
+		{"parallel directory scan", []string{"-dir", dir, "-seq=false"}},
+		{"error", []string{"-file", filepath.Join(dir, "missing.png")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Buffers are connected to the process through pipes, not a terminal
+			var stdout, stderr bytes.Buffer
+			cmd := exec.Command(binary, append(tt.args, "-outdir", t.TempDir(), "-nocache")...)
+			cmd.Stdout, cmd.Stderr = &stdout, &stderr
+			// Clear NO_COLOR so that only the pipe can turn colors off
+			cmd.Env = append(os.Environ(), "NO_COLOR=")
+			cmd.Run()
+
+			if stdout.Len()+stderr.Len() == 0 {
+				t.Fatal("no output")
+			}
+			for stream, out := range map[string]string{"stdout": stdout.String(), "stderr": stderr.String()} {
+				if i := strings.Index(out, "\033["); i >= 0 {
+					t.Errorf("ANSI sequence on %s: %q", stream, out[max(i-20, 0):min(i+20, len(out))])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"DeSteGo/pkg/extractor"
+)
+
+/*
+This file contains the extraction trace. When -verbose or -trace is set, every
+method an extractor tries is recorded with the size, printable ratio, entropy
+and score of its output, and whether its length header validated, so a scan
+that finds nothing still shows what was attempted. The attempts are added to the analysis result's Details["attempts"]
+and, with -trace, appended to a JSON-lines file.
+*/
+
+// traceEntry is one line of the trace file
+type traceEntry struct {
+	File      string `json:"file"`
+	Extractor string `json:"extractor"`
+	extractor.Attempt
+}
+
+// traceWriter appends trace entries to a file. It is safe for concurrent use.
+type traceWriter struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// openTrace creates (or truncates) the trace file at path
+func openTrace(path string) (*traceWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace file: %w", err)
+	}
+	return &traceWriter{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Write appends an entry. A nil writer discards it.
+func (t *traceWriter) Write(entry traceEntry) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.enc.Encode(entry)
+}
+
+// Close closes the trace file. A nil writer does nothing.
+func (t *traceWriter) Close() error {
+	if t == nil {
+		return nil
+	}
+	return t.file.Close()
+}
+
+// printAttempt writes one attempt as a line of verbose output
+func printAttempt(log *Logger, entry traceEntry) {
+	if entry.Skipped != "" {
+		log.Printf("   %-28s skipped: %s\n", entry.Method, entry.Skipped)
+		return
+	}
+	status := ""
+	if entry.Header != "" {
+		status += "  header " + entry.Header
+	}
+	if entry.Reported {
+		status += " (reported)"
+	}
+	log.Printf("   %-28s %8d bytes  printable %.2f  entropy %.2f  score %.2f%s\n",
+		entry.Method, entry.Bytes, entry.Printable, entry.Entropy, entry.Score, status)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"DeSteGo/pkg/analyzer/plugin"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This is an example analyzer plugin. It adds a check the built-in GIF analyzer
+does not make: data after the trailer byte that ends the block stream, where
+tools append archives and text. It walks the blocks rather than searching for
+the last 0x3B, which also occurs inside image data.
+
+Build it into a plugin directory and point DeSteGo at it:
+
+	go build -o plugins/giftrailer ./examples/plugins/giftrailer
+	destego -dir images -plugins plugins
+
+A plugin does not have to be written in Go; anything that implements the
+describe/analyze protocol in pkg/analyzer/plugin works.
+*/
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: giftrailer describe | analyze <file>")
+		os.Exit(2)
+	}
+
+	var out interface{}
+	var err error
+	switch os.Args[1] {
+	case "describe":
+		out = plugin.Description{
+			Name:        "GIF Trailer Plugin",
+			Description: "Reports data appended after the GIF trailer",
+			Formats:     []string{"gif"},
+		}
+	case "analyze":
+		out, err = analyze(os.Stdin)
+	default:
+		err = fmt.Errorf("unknown command %q", os.Args[1])
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	json.NewEncoder(os.Stdout).Encode(out)
+}
+
+// analyze reads the request on stdin and checks the file it names
+func analyze(stdin io.Reader) (*models.AnalysisResult, error) {
+	var request plugin.Request
+	if err := json.NewDecoder(stdin).Decode(&request); err != nil {
+		return nil, fmt.Errorf("failed to read request: %w", err)
+	}
+	data, err := os.ReadFile(request.File)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	end, err := trailerEnd(data)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.AnalysisResult{
+		FileType:       "gif",
+		Filename:       request.File,
+		DetectionScore: 0.1,
+		Confidence:     0.8,
+		Details:        map[string]interface{}{"trailer_offset": end - 1},
+	}
+	if appended := len(data) - end; appended > 0 {
+		result.AddFinding("Found appended data after the GIF trailer", 0.8,
+			fmt.Sprintf("%d bytes follow the trailer at offset %d", appended, end-1))
+		result.DetectionScore = 0.7
+		result.Recommendations = append(result.Recommendations,
+			"Extract and analyze the data after the GIF trailer")
+	} else {
+		result.AddCheck("no data after the GIF trailer")
+	}
+	return result, nil
+}
+
+// trailerEnd walks the GIF blocks and returns the offset just past the trailer
+func trailerEnd(data []byte) (int, error) {
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return 0, errors.New("not a GIF file")
+	}
+	pos := 13
+	if data[10]&0x80 != 0 { // Global color table
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x3B: // Trailer
+			return pos + 1, nil
+		case 0x21: // Extension: label, then sub-blocks
+			pos = skipSubBlocks(data, pos+2)
+		case 0x2C: // Image descriptor, optional local color table, LZW code size, sub-blocks
+			if pos+10 > len(data) {
+				return 0, errors.New("truncated image descriptor")
+			}
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			pos = skipSubBlocks(data, pos+1)
+		default:
+			return 0, fmt.Errorf("unknown GIF block 0x%02X at offset %d", data[pos], pos)
+		}
+	}
+	return 0, errors.New("no GIF trailer found")
+}
+
+// skipSubBlocks returns the offset after the sub-block chain starting at pos
+func skipSubBlocks(data []byte, pos int) int {
+	for pos < len(data) {
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			break
+		}
+		pos += size
+	}
+	return pos
+}
@@ -5,8 +5,11 @@ go 1.23.1
 require github.com/fatih/color v1.18.0 // direct
 
 require (
+	github.com/makiuchi-d/gozxing v0.1.1 // direct
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/mattn/go-isatty v0.0.20 // direct
 	golang.org/x/image v0.24.0 // direct
 	golang.org/x/sys v0.25.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 )
@@ -0,0 +1,588 @@
+package fixtures
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/image/bmp"
+)
+
+/*
+This package synthesizes stego fixtures: carrier images with payloads embedded
+at known parameters, so detectors can be tested against files whose contents
+are known exactly. Everything is seeded, so the same fixture always has the same
+bytes. The fixtures the tests use are listed in Fixtures and written to the
+repository's testdata directory by the generator:
+
+	go generate ./internal/fixtures
+
+Tests that need other parameters can build carriers in memory with the same
+functions instead.
+*/
+
+//go:generate go run ./gen -out ../../testdata
+
+// Fixture is one generated file with a known payload
+type Fixture struct {
+	Name       string            `json:"name"`                 // File name in testdata
+	Format     string            `json:"format"`               // Carrier format
+	Method     string            `json:"method"`               // How the payload is hidden, "none" for clean carriers
+	Parameters map[string]string `json:"parameters,omitempty"` // Embedding parameters
+	Payload    string            `json:"payload,omitempty"`    // The hidden payload
+	build      func(f Fixture) ([]byte, error)
+}
+
+// Build returns the fixture's file contents
+func (f Fixture) Build() ([]byte, error) {
+	return f.build(f)
+}
+
+// Carrier dimensions and seed of the generated fixtures
+const (
+	carrierSize = 128
+	carrierSeed = 1
+)
+
+// lsbPayload is the text the LSB fixtures hide. It fills about half of a
+// carrier's RGB least significant bits.
+var lsbPayload = strings.Repeat("The quick brown fox jumps over the lazy dog. ", 66)
+
+// Fixtures are the generated fixtures in testdata
+var Fixtures = []Fixture{
+	{Name: "clean.png", Format: "png", Method: "none", build: clean},
+	{Name: "clean.jpg", Format: "jpeg", Method: "none", build: clean},
+	{Name: "clean.gif", Format: "gif", Method: "none", build: clean},
+	{Name: "clean.bmp", Format: "bmp", Method: "none", build: clean},
+	{
+		Name: "lsb_rgb.png", Format: "png", Method: "lsb",
+		Parameters: map[string]string{"channels": "RGB", "bit": "0", "order": "msb-first", "start": "0"},
+		Payload:    lsbPayload,
+		build:      lsbRGB,
+	},
+	{
+		Name: "lsb_rgb.bmp", Format: "bmp", Method: "lsb",
+		Parameters: map[string]string{"channels": "RGB", "bit": "0", "order": "msb-first", "start": "0"},
+		Payload:    lsbPayload,
+		build:      lsbRGB,
+	},
+	{
+		Name: "appended_zip.jpg", Format: "jpeg", Method: "appended",
+		Parameters: map[string]string{"container": "zip", "entry": "secret.txt"},
+		Payload:    "appended archive payload\n",
+		build:      appendedZIP,
+	},
+	{
+		Name: "appended_zip.gif", Format: "gif", Method: "appended",
+		Parameters: map[string]string{"container": "zip", "entry": "secret.txt"},
+		Payload:    "appended archive payload\n",
+		build:      appendedZIP,
+	},
+	{
+		Name: "double_eoi.jpg", Format: "jpeg", Method: "appended",
+		Parameters: map[string]string{"container": "none", "end": "second EOI marker"},
+		Payload:    "hidden between two EOI markers\n",
+		build:      doubleEOI,
+	},
+	{
+		Name: "split_scan.jpg", Format: "jpeg", Method: "eoi-spoof",
+		Parameters: map[string]string{"eoi": "inside the scan data, halfway through"},
+		build:      splitScan,
+	},
+	{
+		Name: "textured.jpg", Format: "jpeg", Method: "none",
+		Parameters: map[string]string{"quality": "75"},
+		build:      textured,
+	},
+	{
+		Name: "recompressed_lsb.jpg", Format: "jpeg", Method: "lsb-recompressed",
+		Parameters: map[string]string{"first quality": "50", "channels": "RGB", "quality": "75", "payload": "random, destroyed by the second compression"},
+		build:      recompressedLSB,
+	},
+	{
+		Name: "comment_zero_width.jpg", Format: "jpeg", Method: "metadata",
+		Parameters: map[string]string{"field": "COM", "cover": "Shot on film", "encoding": "zero-width binary, U+200B=0 U+200C=1, U+200D between bytes"},
+		Payload:    "meet at the docks",
+		build:      commentZeroWidth,
+	},
+	{
+		Name: "itxt_zero_width.png", Format: "png", Method: "metadata",
+		Parameters: map[string]string{"field": "iTXt Comment", "cover": "Nice photo", "encoding": "zero-width binary, U+200B=0 U+200C=1, U+200D between bytes"},
+		Payload:    "exfil via dns",
+		build:      itxtZeroWidth,
+	},
+}
+
+// Lookup returns the fixture called name
+func Lookup(name string) (Fixture, bool) {
+	for _, f := range Fixtures {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Fixture{}, false
+}
+
+// Dir returns the testdata directory the fixtures are generated into
+func Dir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "testdata")
+}
+
+// Path returns the path of the generated fixture called name
+func Path(name string) string {
+	return filepath.Join(Dir(), name)
+}
+
+// Load reads the generated fixture called name
+func Load(name string) ([]byte, error) {
+	data, err := os.ReadFile(Path(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// carrierGamma is the tone curve applied to carriers. Like a camera's, it maps
+// the 8-bit values unevenly, leaving the gaps and doubled values in the
+// histogram that natural images have.
+const carrierGamma = 0.8
+
+// Carrier returns a width x height opaque image of smooth colour gradients with
+// seeded noise passed through a tone curve, so its least significant bits and
+// histogram look like a photo's rather than a drawing's
+func Carrier(width, height int, seed int64) *image.RGBA {
+	var tone [256]uint8
+	for v := range tone {
+		tone[v] = clamp(255 * math.Pow(float64(v)/255, carrierGamma))
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			fx, fy := float64(x)/float64(width), float64(y)/float64(height)
+			base := [3]float64{
+				40 + 160*fx,
+				60 + 120*fy + 20*math.Sin(6*fx),
+				90 + 80*math.Cos(3*(fx+fy)),
+			}
+			i := img.PixOffset(x, y)
+			for c, v := range base {
+				img.Pix[i+c] = tone[clamp(v+rng.NormFloat64()*2)]
+			}
+			img.Pix[i+3] = 255
+		}
+	}
+	return img
+}
+
+// texturedNoise is the mean absolute deviation of the Laplacian noise added to
+// textured carriers. It spreads their DCT coefficients over enough values for
+// the coefficient histograms to have a shape.
+const texturedNoise = 8
+
+// TexturedCarrier returns a carrier with seeded Laplacian noise added to every
+// channel, whose block DCT coefficients fall off like those of a photo's
+// texture rather than staying at 0 and 1
+func TexturedCarrier(width, height int, seed int64) *image.RGBA {
+	img := Carrier(width, height, seed)
+	rng := rand.New(rand.NewSource(seed + 1))
+	for i := range img.Pix {
+		if i%4 == 3 {
+			continue // Alpha
+		}
+		noise := rng.ExpFloat64() * texturedNoise
+		if rng.Intn(2) == 0 {
+			noise = -noise
+		}
+		img.Pix[i] = clamp(float64(img.Pix[i]) + noise)
+	}
+	return img
+}
+
+// BenchSizes are the image sides the benchmarks run at: a thumbnail, a web
+// image and a camera-sized image
+var BenchSizes = []int{256, 1024, 2048}
+
+// Noise returns a size x size opaque image of seeded uniform noise. It has no
+// structure to speed up decoding or the detectors, so benchmarks on it measure
+// the worst case.
+func Noise(size int, seed int64) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	rand.New(rand.NewSource(seed)).Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	return img
+}
+
+// clamp rounds v to the nearest byte value
+func clamp(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
+
+// EmbedLSB writes payload into bit of the given channels (0-3 for RGBA) of
+// each pixel in raster order, most significant bit of each byte first. This is
+// the order the LSB extractor reads.
+func EmbedLSB(img *image.RGBA, payload []byte, channels []int, bit uint) error {
+	bounds := img.Bounds()
+	if capacity := bounds.Dx() * bounds.Dy() * len(channels) / 8; len(payload) > capacity {
+		return fmt.Errorf("payload of %d bytes exceeds the carrier's %d bytes", len(payload), capacity)
+	}
+	n := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			for _, c := range channels {
+				if n == len(payload)*8 {
+					return nil
+				}
+				v := payload[n/8] >> (7 - n%8) & 1
+				img.Pix[i+c] = img.Pix[i+c]&^(1<<bit) | v<<bit
+				n++
+			}
+		}
+	}
+	return nil
+}
+
+// RandomPayload returns n bytes of seeded random data, which equalizes LSB
+// pairs the way encrypted or compressed payloads do
+func RandomPayload(n int, seed int64) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+// Encode encodes img as png, jpeg, gif or bmp. JPEGs use quality 90.
+func Encode(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	case "bmp":
+		err = bmp.Encode(&buf, img)
+	default:
+		return nil, fmt.Errorf("unsupported fixture format: %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", format, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeJPEG encodes img as a JPEG at the given quality
+func EncodeJPEG(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode jpeg: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Kinds of image DNG writes into the first IFD
+const (
+	DNGPreviewRGB  = "rgb"  // Uncompressed 8-bit RGB preview
+	DNGPreviewJPEG = "jpeg" // JPEG-compressed preview
+	DNGRawCFA      = "cfa"  // 16-bit color filter array raw data, which Go's TIFF decoder rejects
+)
+
+// DNG returns a little-endian DNG whose first and only IFD holds img as kind,
+// in a single strip. The CFA samples are the green channel of img.
+func DNG(img image.Image, kind string) ([]byte, error) {
+	le := binary.LittleEndian
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	subfile, compression, photometric := uint32(1), uint16(1), uint16(2)
+	bits := []uint16{8, 8, 8}
+	var strip []byte
+	switch kind {
+	case DNGPreviewRGB:
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, g, b, _ := img.At(x, y).RGBA()
+				strip = append(strip, byte(r>>8), byte(g>>8), byte(b>>8))
+			}
+		}
+	case DNGPreviewJPEG:
+		data, err := EncodeJPEG(img, 90)
+		if err != nil {
+			return nil, err
+		}
+		strip, compression = data, 7
+	case DNGRawCFA:
+		subfile, photometric, bits = 0, 32803, []uint16{16}
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				_, g, _, _ := img.At(x, y).RGBA()
+				strip = le.AppendUint16(strip, uint16(g))
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported DNG kind: %s", kind)
+	}
+
+	type entry struct {
+		tag, typ uint16
+		count    uint32
+		value    []byte
+	}
+	short := func(tag uint16, values ...uint16) entry {
+		var b []byte
+		for _, v := range values {
+			b = le.AppendUint16(b, v)
+		}
+		return entry{tag, 3, uint32(len(values)), b}
+	}
+	long := func(tag uint16, v uint32) entry {
+		return entry{tag, 4, 1, le.AppendUint32(nil, v)}
+	}
+	model := "DeSteGo fixture\x00"
+	entries := []entry{
+		long(0x00FE, subfile), // NewSubFileType, 1 for a reduced-resolution preview
+		long(0x0100, uint32(w)),
+		long(0x0101, uint32(h)),
+		short(0x0102, bits...),
+		short(0x0103, compression),
+		short(0x0106, photometric),
+		long(0x0111, 0), // StripOffsets, set once the layout is known
+		short(0x0115, uint16(len(bits))),
+		long(0x0116, uint32(h)),
+		long(0x0117, uint32(len(strip))),
+	}
+	if kind == DNGRawCFA {
+		entries = append(entries, short(0x828D, 2, 2), entry{0x828E, 1, 4, []byte{0, 1, 1, 2}})
+	}
+	entries = append(entries,
+		entry{0xC612, 1, 4, []byte{1, 4, 0, 0}}, // DNGVersion
+		entry{0xC614, 2, uint32(len(model)), []byte(model)},
+	)
+
+	// Values longer than four bytes follow the IFD, and the strip follows them
+	valuesStart := 8 + 2 + len(entries)*12 + 4
+	var values []byte
+	offsets := make([]uint32, len(entries))
+	for i, e := range entries {
+		if len(e.value) > 4 {
+			offsets[i] = uint32(valuesStart + len(values))
+			values = append(values, e.value...)
+			if len(values)%2 == 1 {
+				values = append(values, 0)
+			}
+		}
+	}
+	entries[6] = long(0x0111, uint32(valuesStart+len(values)))
+
+	out := []byte("II*\x00")
+	out = le.AppendUint32(out, 8)
+	out = le.AppendUint16(out, uint16(len(entries)))
+	for i, e := range entries {
+		out = le.AppendUint16(out, e.tag)
+		out = le.AppendUint16(out, e.typ)
+		out = le.AppendUint32(out, e.count)
+		if len(e.value) > 4 {
+			out = le.AppendUint32(out, offsets[i])
+		} else {
+			out = append(out, e.value...)
+			out = append(out, make([]byte, 4-len(e.value))...)
+		}
+	}
+	out = le.AppendUint32(out, 0)
+	out = append(out, values...)
+	return append(out, strip...), nil
+}
+
+// ZIP returns a ZIP archive holding one stored entry
+func ZIP(name string, content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zip entry: %w", err)
+	}
+	if _, err := f.Write(content); err != nil {
+		return nil, fmt.Errorf("failed to write zip entry: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close zip: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ZeroWidth encodes text as zero-width characters: U+200B for 0 and U+200C for
+// 1, most significant bit first, with U+200D after each byte
+func ZeroWidth(text string) string {
+	var b strings.Builder
+	for _, c := range []byte(text) {
+		for i := 7; i >= 0; i-- {
+			if c>>i&1 == 1 {
+				b.WriteRune('\u200C')
+			} else {
+				b.WriteRune('\u200B')
+			}
+		}
+		b.WriteRune('\u200D')
+	}
+	return b.String()
+}
+
+// JPEGComment inserts a COM segment holding comment right after the SOI marker
+func JPEGComment(data []byte, comment []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errors.New("not a JPEG")
+	}
+	if len(comment) > 0xFFFF-2 {
+		return nil, errors.New("comment too long for one segment")
+	}
+	out := append([]byte{}, data[:2]...)
+	out = append(out, 0xFF, 0xFE)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(comment)+2))
+	out = append(out, comment...)
+	return append(out, data[2:]...), nil
+}
+
+// PNGChunk inserts a chunk right before the IEND chunk
+func PNGChunk(data []byte, chunkType string, chunkData []byte) ([]byte, error) {
+	const iendSize = 12
+	if len(data) < 8+iendSize || string(data[len(data)-iendSize+4:len(data)-iendSize+8]) != "IEND" {
+		return nil, errors.New("not a PNG ending in IEND")
+	}
+	iend := len(data) - iendSize
+	out := append([]byte{}, data[:iend]...)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(chunkData)))
+	out = append(out, chunkType...)
+	out = append(out, chunkData...)
+	out = binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(append([]byte(chunkType), chunkData...)))
+	return append(out, data[iend:]...), nil
+}
+
+// clean builds an unmodified carrier
+func clean(f Fixture) ([]byte, error) {
+	return Encode(Carrier(carrierSize, carrierSize, carrierSeed), f.Format)
+}
+
+// lsbRGB builds a carrier with the payload in the RGB least significant bits
+func lsbRGB(f Fixture) ([]byte, error) {
+	img := Carrier(carrierSize, carrierSize, carrierSeed)
+	if err := EmbedLSB(img, []byte(f.Payload), []int{0, 1, 2}, 0); err != nil {
+		return nil, err
+	}
+	return Encode(img, f.Format)
+}
+
+// appendedZIP builds a carrier with a ZIP archive after its end
+func appendedZIP(f Fixture) ([]byte, error) {
+	data, err := clean(f)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := ZIP(f.Parameters["entry"], []byte(f.Payload))
+	if err != nil {
+		return nil, err
+	}
+	return append(data, archive...), nil
+}
+
+// texturedSize is the side of the textured fixtures, large enough for the
+// coefficient histograms of each frequency to be judged
+const texturedSize = 256
+
+// textured builds a textured carrier compressed once
+func textured(f Fixture) ([]byte, error) {
+	return EncodeJPEG(TexturedCarrier(texturedSize, texturedSize, carrierSeed), 75)
+}
+
+// recompressedLSB builds a textured carrier compressed at a lower quality,
+// decoded, embedded in and compressed again, as a JPEG a tool hides data in
+// and saves again is
+func recompressedLSB(f Fixture) ([]byte, error) {
+	first, err := EncodeJPEG(TexturedCarrier(texturedSize, texturedSize, carrierSeed), 50)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := jpeg.Decode(bytes.NewReader(first))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode jpeg: %w", err)
+	}
+	img := image.NewRGBA(decoded.Bounds())
+	draw.Draw(img, img.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+	if err := EmbedLSB(img, RandomPayload(texturedSize*texturedSize*3/16, carrierSeed), []int{0, 1, 2}, 0); err != nil {
+		return nil, err
+	}
+	return EncodeJPEG(img, 75)
+}
+
+// doubleEOI builds a JPEG with the payload after its EOI marker followed by a
+// second EOI, so that the file ends like an image without appended data
+func doubleEOI(f Fixture) ([]byte, error) {
+	data, err := clean(f)
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, f.Payload...)
+	return append(data, 0xFF, 0xD9), nil
+}
+
+// splitScan builds a JPEG with an EOI marker inserted halfway through its scan
+// data, so that the image ends early and the rest of the scan follows it
+func splitScan(f Fixture) ([]byte, error) {
+	data, err := clean(f)
+	if err != nil {
+		return nil, err
+	}
+	sos := bytes.Index(data, []byte{0xFF, 0xDA})
+	if sos < 0 || sos+4 > len(data) {
+		return nil, errors.New("no scan in JPEG")
+	}
+	start := sos + 2 + int(binary.BigEndian.Uint16(data[sos+2:]))
+	split := start + (len(data)-2-start)/2
+	for data[split-1] == 0xFF {
+		split++ // Keep stuffed bytes whole
+	}
+	out := append([]byte{}, data[:split]...)
+	out = append(out, 0xFF, 0xD9)
+	return append(out, data[split:]...), nil
+}
+
+// commentZeroWidth builds a JPEG whose comment hides the payload in zero-width
+// characters
+func commentZeroWidth(f Fixture) ([]byte, error) {
+	data, err := clean(f)
+	if err != nil {
+		return nil, err
+	}
+	return JPEGComment(data, []byte(f.Parameters["cover"]+ZeroWidth(f.Payload)))
+}
+
+// itxtZeroWidth builds a PNG whose iTXt chunk hides the payload in zero-width
+// characters
+func itxtZeroWidth(f Fixture) ([]byte, error) {
+	data, err := clean(f)
+	if err != nil {
+		return nil, err
+	}
+	// Keyword, compression flag and method, empty language tag and translated keyword
+	chunk := []byte("Comment\x00\x00\x00\x00\x00")
+	return PNGChunk(data, "iTXt", append(chunk, f.Parameters["cover"]+ZeroWidth(f.Payload)...))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"DeSteGo/internal/fixtures"
+)
+
+/*
+This program writes the stego fixtures to a directory, along with
+fixtures.json, a manifest of each fixture's format, embedding method,
+parameters and payload. It is run by go generate:
+
+	go generate ./internal/fixtures
+*/
+
+func main() {
+	outDir := flag.String("out", "testdata", "Directory to write the fixtures to")
+	flag.Parse()
+
+	if err := generate(*outDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// generate writes every fixture and the manifest to outDir
+func generate(outDir string) error {
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	for _, f := range fixtures.Fixtures {
+		data, err := f.Build()
+		if err != nil {
+			return fmt.Errorf("failed to build %s: %w", f.Name, err)
+		}
+		if err := os.WriteFile(filepath.Join(outDir, f.Name), data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.Name, err)
+		}
+	}
+
+	manifest, err := json.MarshalIndent(fixtures.Fixtures, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "fixtures.json"), append(manifest, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	fmt.Printf("Wrote %d fixtures to %s\n", len(fixtures.Fixtures), outDir)
+	return nil
+}
@@ -1,7 +1,9 @@
 package analyzer
 
 import (
+	"context"
 	"image"
+	"strings"
 
 	"DeSteGo/pkg/models"
 )
@@ -21,9 +23,12 @@ BaseAnalyzer struct: and the FileAnalyzer interface are used by specific analyze
 
 // AnalysisOptions holds configuration options for analysis
 type AnalysisOptions struct {
-	Verbose bool
-	Format  string
-	Extract bool
+	Verbose   bool
+	Format    string
+	Extract   bool
+	OutputDir string           // Where extracted artifacts are written when Extract is set
+	Detection *DetectionConfig // Detection thresholds, the built-in ones when nil
+	Source    models.Source    // The file's bytes and image shared between analyzers, read from disk when nil
 	// Additional options can be added as needed
 }
 
@@ -53,6 +58,24 @@ type ImageAnalyzer interface {
 	AnalyzeImage(img image.Image, options AnalysisOptions) (*models.AnalysisResult, error)
 }
 
+// ContextAnalyzer is an interface for analyzers that can stop early once a
+// context is canceled, such as when a scan is interrupted
+type ContextAnalyzer interface {
+	FileAnalyzer
+
+	// AnalyzeContext is like Analyze but stops once ctx is canceled
+	AnalyzeContext(ctx context.Context, filePath string, options AnalysisOptions) (*models.AnalysisResult, error)
+}
+
+// AnalyzeWithContext runs a with ctx when it is a ContextAnalyzer, and runs it
+// to completion otherwise
+func AnalyzeWithContext(ctx context.Context, a FileAnalyzer, filePath string, options AnalysisOptions) (*models.AnalysisResult, error) {
+	if c, ok := a.(ContextAnalyzer); ok {
+		return c.AnalyzeContext(ctx, filePath, options)
+	}
+	return a.Analyze(filePath, options)
+}
+
 // BaseAnalyzer provides common functionality for analyzers
 type BaseAnalyzer struct {
 	name        string
@@ -93,3 +116,17 @@ func (b *BaseAnalyzer) CanAnalyze(format string) bool {
 	}
 	return false
 }
+
+// AddRepairs records the repairs a file needed before Go's decoder accepted it
+// in Details["lenient_decode"] and reports them, since a file edited after it
+// was encoded is what usually needs them. Nothing is recorded without repairs.
+func AddRepairs(result *models.AnalysisResult, repairs []string) {
+	if len(repairs) == 0 {
+		return
+	}
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	result.Details["lenient_decode"] = repairs
+	result.AddFinding("Image decoded only after repairs", 0.3, strings.Join(repairs, "; "))
+}
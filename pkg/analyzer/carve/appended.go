@@ -0,0 +1,170 @@
+package carve
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/analyzer/stats"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the classification of data appended after the end of an
+image stream. Viewers stop at the end marker, so anything after it is invisible,
+and what it is decides what to do next: another image is analyzed in turn, an
+archive is opened, a script or executable is a polyglot. Data without a file
+signature is told apart by its byte entropy, since encrypted data is as close to
+8 bits per byte as random data, and a blob like that is only as useful as the
+key that decrypts it.
+*/
+
+// Appended data categories
+const (
+	AppendedImage      = "image"
+	AppendedArchive    = "archive"
+	AppendedExecutable = "executable"
+	AppendedScript     = "script"
+	AppendedDocument   = "document"
+	AppendedMedia      = "audio or video"
+	AppendedKey        = "key or certificate"
+	AppendedText       = "text"
+	AppendedEncrypted  = "encrypted data"
+	AppendedBinary     = "binary data"
+)
+
+// encryptedMinEntropy is the entropy in bits per byte above which data without
+// a file signature is taken for encrypted
+const encryptedMinEntropy = 7.5
+
+// encryptedMinSize is the size below which data is too short to reach
+// encryptedMinEntropy even when it is random
+const encryptedMinSize = 1024
+
+// signatureCategories maps the type names SniffPayload returns to categories
+var signatureCategories = map[string]string{
+	"png": AppendedImage, "jpg": AppendedImage, "gif": AppendedImage, "bmp": AppendedImage,
+	"tif": AppendedImage, "webp": AppendedImage,
+	"zip": AppendedArchive, "rar": AppendedArchive, "gz": AppendedArchive, "7z": AppendedArchive,
+	"xz": AppendedArchive, "bz2": AppendedArchive, "zst": AppendedArchive,
+	"elf": AppendedExecutable, "exe": AppendedExecutable, "class": AppendedExecutable, "wasm": AppendedExecutable,
+	"sh":  AppendedScript,
+	"pdf": AppendedDocument, "ps": AppendedDocument, "sqlite": AppendedDocument,
+	"wav": AppendedMedia, "avi": AppendedMedia, "ogg": AppendedMedia, "flac": AppendedMedia, "mp3": AppendedMedia,
+	"pem": AppendedKey,
+}
+
+// Appended describes data found after the end of an image stream
+type Appended struct {
+	Offset   int     // Position of the first byte after the image
+	Size     int     // Length of the data
+	Category string  // One of the Appended* categories
+	Kind     string  // What the data is, such as "ZIP archive" or "PHP script"
+	Ext      string  // Extension the data is extracted under
+	MIME     string  // MIME type of the data
+	Entropy  float64 // Shannon entropy in bits per byte
+}
+
+// ClassifyAppended identifies the data that follows the end of an image at
+// offset end in data
+func ClassifyAppended(data []byte, end int) Appended {
+	payload := data[end:]
+	fileType, ext, mime := SniffPayload(payload)
+	a := Appended{
+		Offset:  end,
+		Size:    len(payload),
+		Ext:     ext,
+		MIME:    mime,
+		Entropy: stats.ComputeEntropy(payload),
+	}
+	base, _, _ := strings.Cut(mime, ";")
+
+	if category, ok := signatureCategories[fileType]; ok {
+		a.Category = category
+		a.Kind = strings.ToUpper(fileType) + " " + category
+		if category == AppendedScript {
+			a.Kind, _, _ = scriptKind(payload)
+		}
+		return a
+	}
+	if kind, kindExt, ok := scriptKind(payload); ok {
+		a.Kind, a.Ext = kind, kindExt
+		switch {
+		case strings.Contains(kind, "script"):
+			a.Category = AppendedScript
+		case strings.Contains(kind, "image"):
+			a.Category = AppendedImage
+		default:
+			a.Category = AppendedDocument
+		}
+		return a
+	}
+
+	switch {
+	case strings.HasPrefix(base, "text/"):
+		a.Category, a.Kind = AppendedText, AppendedText
+	case strings.HasPrefix(base, "audio/"), strings.HasPrefix(base, "video/"):
+		a.Category, a.Kind = AppendedMedia, base+" data"
+	case strings.HasPrefix(base, "image/"):
+		a.Category, a.Kind = AppendedImage, base+" data"
+	case a.Size >= encryptedMinSize && a.Entropy >= encryptedMinEntropy:
+		a.Category, a.Kind = AppendedEncrypted, "encrypted or headerless compressed data"
+	default:
+		a.Category, a.Kind = AppendedBinary, AppendedBinary
+	}
+	return a
+}
+
+// AnalyzeAppended records the data after the end of the image at offset end in
+// result and, when extraction is enabled, writes it out under the extension of
+// its type. It does nothing when end is the end of the file.
+func AnalyzeAppended(data []byte, end int, filePath string, options analyzer.AnalysisOptions, result *models.AnalysisResult) {
+	if end < 0 || end >= len(data) {
+		return
+	}
+	a := ClassifyAppended(data, end)
+
+	details := fmt.Sprintf("Found %d bytes of appended data after the end of the image at offset %d: %s (%s, %.2f bits/byte)",
+		a.Size, a.Offset, a.Kind, a.MIME, a.Entropy)
+	result.AddFinding("Found appended data after EOF", 0.8, details)
+	result.SetDetectorScore(analyzer.DetectorEmbeddedData, 0.8)
+	if result.DetectionScore < 0.7 {
+		result.DetectionScore = 0.7
+	}
+	if result.Confidence < 0.8 {
+		result.Confidence = 0.8
+	}
+
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	result.Details["appended_bytes"] = a.Size
+	result.Details["appended_offset"] = a.Offset
+	result.Details["appended_type"] = a.Kind
+	result.Details["appended_category"] = a.Category
+	result.Details["appended_entropy"] = a.Entropy
+
+	if a.Category == AppendedEncrypted {
+		result.Recommendations = append(result.Recommendations,
+			fmt.Sprintf("The appended data has no file signature and %.2f bits/byte of entropy, so it is likely encrypted: look for the key or password in the image's metadata, comments and other findings", a.Entropy))
+	}
+
+	if !options.Extract || options.OutputDir == "" {
+		result.Recommendations = append(result.Recommendations,
+			fmt.Sprintf("Inspect the %d bytes after the end of the image (re-run with -extract to write them out as .%s)", a.Size, a.Ext))
+		return
+	}
+
+	base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	outPath := filepath.Join(options.OutputDir, fmt.Sprintf("%s_appended.%s", base, a.Ext))
+	outPath, err := filehandler.SaveFileUnique(data[end:], outPath)
+	if err != nil {
+		result.AddFinding("Failed to extract appended data", 0.1, err.Error())
+		return
+	}
+	result.Details["appended_file"] = outPath
+	result.Recommendations = append(result.Recommendations,
+		fmt.Sprintf("Inspect the appended %s: %s", a.Kind, outPath))
+}
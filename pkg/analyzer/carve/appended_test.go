@@ -0,0 +1,70 @@
+package carve
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/models"
+)
+
+func TestAnalyzeAppended(t *testing.T) {
+	zipData, err := fixtures.ZIP("secret.txt", []byte("hidden after the image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	png, err := fixtures.Load("clean.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		appended []byte
+		category string
+		ext      string
+	}{
+		{"ZIP archive", zipData, AppendedArchive, "zip"},
+		{"PNG image", png, AppendedImage, "png"},
+		{"high-entropy blob", fixtures.RandomPayload(4096, 1), AppendedEncrypted, "bin"},
+		{"short blob", fixtures.RandomPayload(64, 2), AppendedBinary, "bin"},
+		{"script", []byte("<?php system($_GET['c']); ?>"), AppendedScript, "php"},
+	}
+	for _, host := range []string{"clean.jpg", "clean.png"} {
+		carrier, err := fixtures.Load(host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range tests {
+			t.Run(host+"/"+tt.name, func(t *testing.T) {
+				data := append(append([]byte{}, carrier...), tt.appended...)
+				end := ImageEnd(data)
+				if end != len(carrier) {
+					t.Fatalf("image ends at %d, want %d", end, len(carrier))
+				}
+
+				a := ClassifyAppended(data, end)
+				if a.Category != tt.category || a.Ext != tt.ext || a.Size != len(tt.appended) {
+					t.Errorf("classified as %+v, want %s saved as .%s", a, tt.category, tt.ext)
+				}
+
+				dir := t.TempDir()
+				result := &models.AnalysisResult{}
+				options := analyzer.AnalysisOptions{Extract: true, OutputDir: dir}
+				AnalyzeAppended(data, end, filepath.Join("in", host), options, result)
+				if len(result.Findings) != 1 || result.Details["appended_category"] != tt.category {
+					t.Fatalf("findings %v with category %v, want one finding of %s", result.Findings, result.Details["appended_category"], tt.category)
+				}
+				path, _ := result.Details["appended_file"].(string)
+				if filepath.Ext(path) != "."+tt.ext {
+					t.Errorf("extracted to %q, want a .%s file", path, tt.ext)
+				}
+				if written, err := os.ReadFile(path); err != nil || string(written) != string(tt.appended) {
+					t.Errorf("extracted file differs from the appended data (%v)", err)
+				}
+			})
+		}
+	}
+}
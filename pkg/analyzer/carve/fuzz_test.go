@@ -0,0 +1,81 @@
+package carve
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// seedPNGs returns a valid PNG plus copies with truncated, oversized and
+// corrupted chunks
+func seedPNGs(t testing.TB) [][]byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatalf("failed to encode seed: %v", err)
+	}
+	valid := buf.Bytes()
+
+	oversized := append([]byte(nil), valid...)
+	binary.BigEndian.PutUint32(oversized[8:], 0xFFFFFFF0) // IHDR length
+	negative := append([]byte(nil), valid...)
+	binary.BigEndian.PutUint32(negative[8:], 0x80000000)
+	noIEND := append([]byte(nil), valid[:len(valid)-12]...)
+
+	return [][]byte{
+		valid,
+		valid[:len(valid)/2],
+		oversized,
+		negative,
+		noIEND,
+		append([]byte("#!/bin/sh\necho hi\n"), valid...),
+		append(append([]byte(nil), valid...), "PK\x05\x06"...),
+	}
+}
+
+func FuzzScan(f *testing.F) {
+	for _, seed := range seedPNGs(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, m := range Scan(data) {
+			if m.Offset < 0 || m.End > len(data) || m.End < m.Offset {
+				t.Fatalf("match %s out of range: %d-%d of %d", m.Type, m.Offset, m.End, len(data))
+			}
+		}
+		Identify(data)
+		if end := ImageEnd(data); end > len(data) {
+			t.Fatalf("image end %d beyond %d bytes", end, len(data))
+		}
+		if p := FindPrefix(data, "png"); p != nil && (p.Offset <= 0 || p.Offset > len(data)) {
+			t.Fatalf("prefix offset %d out of range", p.Offset)
+		}
+		ListZIP(data)
+	})
+}
+
+func FuzzSniffPayload(f *testing.F) {
+	// Prefixes of every signature, which must not be read past their end
+	for _, seed := range []string{"", "B", "BM", "BZh", "GIF", "ID3", "RIFF", "\xff\xd8\xff", "\x89PNG", "MZ", "\x7fELF", "\x1f\x8b\x08", "hi\n"} {
+		f.Add([]byte(seed))
+	}
+	f.Add([]byte("BM\x00\x00\x00\x00\x00\x00\x00\x00\x36\x00\x00\x00"))
+	f.Add([]byte("RIFF\x00\x00\x00\x00WAVE"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fileType, extension, mimeType := SniffPayload(data)
+		if extension == "" || mimeType == "" {
+			t.Fatalf("no extension or MIME type for %q", data)
+		}
+		if fileType != "" && fileType != extension {
+			t.Fatalf("file type %s saved as .%s", fileType, extension)
+		}
+		if FileSignature(data) != fileType {
+			t.Fatalf("FileSignature disagrees with SniffPayload on %q", data)
+		}
+		if strings.ContainsAny(extension, "/.") {
+			t.Fatalf("extension %q is not a plain name", extension)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package carve
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+// MaxPrefixScan is how far into a file the image signature is searched for when
+// the file does not start with it
+const MaxPrefixScan = 1 << 20
+
+// Prefix is data found before the signature of the image a file claims to be
+type Prefix struct {
+	Offset int    // Position of the image signature, which is also the prefix size
+	Kind   string // What the prefix looks like
+	Ext    string // Extension used when the prefix is carved
+	Valid  bool   // Whether the prefix is a recognisable file or script of its own
+}
+
+// prefixKinds recognises files and scripts by their first bytes. Patterns are
+// matched against the lower-cased start of the prefix with leading whitespace
+// removed.
+var prefixKinds = []struct {
+	start string
+	kind  string
+	ext   string
+}{
+	{"#!", "script", "sh"},
+	{"<!doctype html", "HTML document", "html"},
+	{"<html", "HTML document", "html"},
+	{"<script", "HTML script", "html"},
+	{"<?php", "PHP script", "php"},
+	{"<svg", "SVG image", "svg"},
+	{"<?xml", "XML document", "xml"},
+	{"@echo off", "batch script", "bat"},
+}
+
+// FindPrefix looks for the signature of hostType ("png" or "jpg") in the first
+// MaxPrefixScan bytes of data. It returns nil when data starts with the signature
+// or the signature is not found.
+func FindPrefix(data []byte, hostType string) *Prefix {
+	for _, sig := range signatures {
+		if sig.name != hostType {
+			continue
+		}
+		if bytes.HasPrefix(data, sig.magic) {
+			return nil
+		}
+		limit := data
+		if len(limit) > MaxPrefixScan {
+			limit = limit[:MaxPrefixScan]
+		}
+		for pos := 1; pos < len(limit); pos++ {
+			i := bytes.Index(limit[pos:], sig.magic)
+			if i < 0 {
+				return nil
+			}
+			pos += i
+			if sig.valid == nil || sig.valid(data, pos) {
+				return classifyPrefix(data[:pos])
+			}
+		}
+	}
+	return nil
+}
+
+// classifyPrefix describes the data found before an image signature
+func classifyPrefix(prefix []byte) *Prefix {
+	p := &Prefix{Offset: len(prefix), Kind: "binary data", Ext: "bin"}
+	if kind, ext, ok := scriptKind(prefix); ok {
+		p.Kind, p.Ext, p.Valid = kind, ext, true
+		return p
+	}
+
+	// Binary formats the carver knows, such as an executable placed first
+	if matches := Scan(prefix); len(matches) > 0 && matches[0].Offset == 0 {
+		m := matches[0]
+		p.Kind, p.Ext, p.Valid = strings.ToUpper(m.Type)+" file", m.Type, true
+		return p
+	}
+
+	if isMostlyText(prefix) {
+		p.Kind, p.Ext = "text", "txt"
+	}
+	return p
+}
+
+// scriptKind matches the start of data against prefixKinds
+func scriptKind(data []byte) (kind, ext string, ok bool) {
+	head := data
+	if len(head) > 64 {
+		head = head[:64]
+	}
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")) // UTF-8 byte order mark
+	head = bytes.ToLower(bytes.TrimLeft(head, " \t\r\n"))
+	for _, known := range prefixKinds {
+		if bytes.HasPrefix(head, []byte(known.start)) {
+			if known.start == "#!" {
+				return "script (" + interpreter(data) + ")", known.ext, true
+			}
+			return known.kind, known.ext, true
+		}
+	}
+	return "", "", false
+}
+
+// interpreter returns the program named on a script's #! line
+func interpreter(script []byte) string {
+	line := script
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(strings.TrimPrefix(string(line), "#!"))
+	if len(fields) == 0 {
+		return "unknown interpreter"
+	}
+	if filepath.Base(fields[0]) == "env" && len(fields) > 1 {
+		return fields[1]
+	}
+	return filepath.Base(fields[0])
+}
+
+// isMostlyText reports whether data is valid UTF-8 made mostly of printable
+// characters
+func isMostlyText(data []byte) bool {
+	if len(data) == 0 || !utf8.Valid(data) {
+		return false
+	}
+	printable := 0
+	for _, b := range data {
+		if b >= 0x20 && b < 0x7F || b == '\n' || b == '\r' || b == '\t' || b >= 0x80 {
+			printable++
+		}
+	}
+	return float64(printable)/float64(len(data)) > 0.95
+}
+
+// AnalyzePrefix records data prepended to the image in result and, when
+// extraction is enabled, carves it to the output directory
+func AnalyzePrefix(data []byte, prefix *Prefix, filePath string, options analyzer.AnalysisOptions, result *models.AnalysisResult) {
+	if prefix == nil {
+		return
+	}
+
+	confidence := 0.85
+	details := fmt.Sprintf("%d bytes of %s before the image signature", prefix.Offset, prefix.Kind)
+	if prefix.Valid {
+		confidence = 0.9
+		details += "; the prefix is a file of its own (polyglot)"
+	}
+	result.AddFinding(fmt.Sprintf("Data prepended before the %s signature", strings.ToUpper(result.FileType)), confidence, details)
+	result.SetDetectorScore(analyzer.DetectorEmbeddedData, confidence)
+
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	result.Details["prepended_bytes"] = prefix.Offset
+	result.Details["prepended_type"] = prefix.Kind
+	if result.DetectionScore < confidence {
+		result.DetectionScore = confidence
+	}
+	if result.Confidence < confidence {
+		result.Confidence = confidence
+	}
+
+	if !options.Extract || options.OutputDir == "" {
+		result.Recommendations = append(result.Recommendations,
+			fmt.Sprintf("Inspect the first %d bytes of the file (re-run with -extract to carve them)", prefix.Offset))
+		return
+	}
+
+	base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	outPath := filepath.Join(options.OutputDir, fmt.Sprintf("%s_prepended.%s", base, prefix.Ext))
+	outPath, err := filehandler.SaveFileUnique(data[:prefix.Offset], outPath)
+	if err != nil {
+		result.AddFinding("Failed to carve prepended data", 0.1, err.Error())
+		return
+	}
+	result.Recommendations = append(result.Recommendations,
+		fmt.Sprintf("Inspect the prepended %s: %s", prefix.Kind, outPath))
+}
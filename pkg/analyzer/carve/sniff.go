@@ -0,0 +1,153 @@
+package carve
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"strings"
+)
+
+/*
+This file contains the identification of extracted payloads and of data appended
+to an image. The magic bytes at the start of a payload are matched against the
+carver's signatures and a table of further formats that are seldom carved but
+often hidden (archives, audio, scripts, keys). Payloads without a known
+signature are sniffed with http.DetectContentType, which recognises text, HTML,
+XML and common media types. Every check is bounded by the length of the data, so
+payloads of a few bytes are safe to sniff.
+*/
+
+// magic describes a file type recognised by the bytes at the start of a payload
+type magic struct {
+	name   string            // Short type name, also used as the file extension
+	mime   string            // MIME type
+	prefix []byte            // Bytes the payload starts with
+	valid  func([]byte) bool // Extra check against false positives (may be nil)
+}
+
+// magics holds the signatures the carver does not search for
+var magics = []magic{
+	{name: "gif", mime: "image/gif", prefix: []byte("GIF8"), valid: gifValid},
+	{name: "bmp", mime: "image/bmp", prefix: []byte("BM"), valid: bmpValid},
+	{name: "tif", mime: "image/tiff", prefix: []byte("II*\x00")},
+	{name: "tif", mime: "image/tiff", prefix: []byte("MM\x00*")},
+	{name: "webp", mime: "image/webp", prefix: []byte("RIFF"), valid: riffValid("WEBP")},
+	{name: "wav", mime: "audio/wav", prefix: []byte("RIFF"), valid: riffValid("WAVE")},
+	{name: "avi", mime: "video/avi", prefix: []byte("RIFF"), valid: riffValid("AVI ")},
+	{name: "ogg", mime: "application/ogg", prefix: []byte("OggS\x00")},
+	{name: "flac", mime: "audio/flac", prefix: []byte("fLaC")},
+	{name: "mp3", mime: "audio/mpeg", prefix: []byte("ID3"), valid: id3Valid},
+	{name: "7z", mime: "application/x-7z-compressed", prefix: []byte("7z\xbc\xaf\x27\x1c")},
+	{name: "xz", mime: "application/x-xz", prefix: []byte("\xfd7zXZ\x00")},
+	{name: "bz2", mime: "application/x-bzip2", prefix: []byte("BZh"), valid: bzip2Valid},
+	{name: "zst", mime: "application/zstd", prefix: []byte("\x28\xb5\x2f\xfd")},
+	{name: "sqlite", mime: "application/vnd.sqlite3", prefix: []byte("SQLite format 3\x00")},
+	{name: "class", mime: "application/java-vm", prefix: []byte("\xca\xfe\xba\xbe")},
+	{name: "wasm", mime: "application/wasm", prefix: []byte("\x00asm")},
+	{name: "ps", mime: "application/postscript", prefix: []byte("%!PS")},
+	{name: "pem", mime: "application/x-pem-file", prefix: []byte("-----BEGIN ")},
+	{name: "sh", mime: "text/x-shellscript", prefix: []byte("#!/")},
+}
+
+// carveMIMETypes maps the carver's type names to MIME types
+var carveMIMETypes = map[string]string{
+	"png": "image/png",
+	"jpg": "image/jpeg",
+	"zip": "application/zip",
+	"pdf": "application/pdf",
+	"rar": "application/vnd.rar",
+	"gz":  "application/gzip",
+	"elf": "application/x-executable",
+	"exe": "application/vnd.microsoft.portable-executable",
+}
+
+// sniffedExtensions maps the MIME types http.DetectContentType reports for
+// payloads without a known signature to file extensions
+var sniffedExtensions = map[string]string{
+	"text/plain":       "txt",
+	"text/html":        "html",
+	"text/xml":         "xml",
+	"application/wasm": "wasm",
+	"audio/mpeg":       "mp3",
+	"audio/aiff":       "aiff",
+	"audio/midi":       "mid",
+	"video/mp4":        "mp4",
+	"video/webm":       "webm",
+	"image/x-icon":     "ico",
+	"font/woff":        "woff",
+	"font/woff2":       "woff2",
+	"font/ttf":         "ttf",
+	"font/otf":         "otf",
+}
+
+// FileSignature returns the type of the file whose magic bytes start data, or
+// "" when data does not start with a known signature
+func FileSignature(data []byte) string {
+	name, _ := sniffSignature(data)
+	return name
+}
+
+// SniffPayload identifies a payload and returns its file type ("" when it has
+// no known signature), the extension to save it under and its MIME type
+func SniffPayload(data []byte) (fileType, extension, mimeType string) {
+	if name, mime := sniffSignature(data); name != "" {
+		return name, name, mime
+	}
+	if len(data) == 0 {
+		return "", "bin", "application/octet-stream"
+	}
+
+	mimeType = http.DetectContentType(data)
+	base, _, _ := strings.Cut(mimeType, ";")
+	extension = "bin"
+	if ext, ok := sniffedExtensions[base]; ok {
+		extension = ext
+	}
+	return "", extension, mimeType
+}
+
+// sniffSignature matches the start of data against the carver's signatures and
+// the magic table
+func sniffSignature(data []byte) (name, mime string) {
+	if name := Identify(data); name != "" {
+		return name, carveMIMETypes[name]
+	}
+	for _, m := range magics {
+		if bytes.HasPrefix(data, m.prefix) && (m.valid == nil || m.valid(data)) {
+			return m.name, m.mime
+		}
+	}
+	return "", ""
+}
+
+// gifValid checks the GIF version
+func gifValid(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("GIF87a")) || bytes.HasPrefix(data, []byte("GIF89a"))
+}
+
+// bmpValid checks that the reserved header fields are zero and the pixel data
+// offset lies after the file header
+func bmpValid(data []byte) bool {
+	if len(data) < 14 {
+		return false
+	}
+	offset := binary.LittleEndian.Uint32(data[10:])
+	return binary.LittleEndian.Uint32(data[6:]) == 0 && offset >= 14+12 // File header plus the smallest DIB header
+}
+
+// riffValid returns a check for the form type of a RIFF container
+func riffValid(form string) func([]byte) bool {
+	return func(data []byte) bool {
+		return len(data) >= 12 && string(data[8:12]) == form
+	}
+}
+
+// id3Valid checks the major version of an ID3v2 tag
+func id3Valid(data []byte) bool {
+	return len(data) >= 4 && data[3] >= 2 && data[3] <= 4
+}
+
+// bzip2Valid checks the block size digit of a bzip2 stream
+func bzip2Valid(data []byte) bool {
+	return len(data) >= 4 && data[3] >= '1' && data[3] <= '9'
+}
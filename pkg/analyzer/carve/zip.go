@@ -0,0 +1,122 @@
+package carve
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+// maxZIPEntrySize bounds how much of a single archive entry is extracted
+const maxZIPEntrySize = 50 * 1024 * 1024
+
+// suspiciousExtensions are archive entry types that can run code
+var suspiciousExtensions = map[string]bool{
+	".sh": true, ".bash": true, ".exe": true, ".dll": true, ".scr": true,
+	".bat": true, ".cmd": true, ".ps1": true, ".vbs": true, ".js": true,
+	".jar": true, ".py": true, ".pl": true, ".php": true, ".elf": true,
+	".so": true, ".msi": true, ".hta": true, ".lnk": true,
+}
+
+// ZIPEntry describes a file stored in an archive
+type ZIPEntry struct {
+	Name       string
+	Size       uint64
+	Suspicious bool   // Executable or script
+	Reason     string // Why the entry is suspicious
+}
+
+// ListZIP reads the central directory of a ZIP archive contained in data. The
+// archive may be appended to other content, such as an image.
+func ListZIP(data []byte) ([]ZIPEntry, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ZIP directory: %w", err)
+	}
+
+	entries := make([]ZIPEntry, 0, len(reader.File))
+	for _, f := range reader.File {
+		entry := ZIPEntry{Name: f.Name, Size: f.UncompressedSize64}
+		ext := strings.ToLower(filepath.Ext(f.Name))
+		switch {
+		case suspiciousExtensions[ext]:
+			entry.Suspicious = true
+			entry.Reason = fmt.Sprintf("%s file", ext)
+		case !f.Mode().IsDir() && f.Mode().Perm()&0111 != 0:
+			entry.Suspicious = true
+			entry.Reason = "executable permission bits"
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// analyzeZIP lists the archive found in a polyglot file and extracts its entries
+// when extraction is enabled
+func analyzeZIP(data []byte, filePath string, options analyzer.AnalysisOptions, result *models.AnalysisResult) {
+	entries, err := ListZIP(data)
+	if err != nil {
+		result.AddFinding("Embedded ZIP archive could not be opened", 0.4, err.Error())
+		return
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+		if entry.Suspicious {
+			result.AddFinding(fmt.Sprintf("Embedded archive contains suspicious entry %s", entry.Name), 0.9,
+				fmt.Sprintf("%s, %d bytes", entry.Reason, entry.Size))
+		}
+	}
+	result.Details["zip_entries"] = names
+	result.AddFinding(fmt.Sprintf("Embedded ZIP archive lists %d entries", len(entries)), 0.8,
+		strings.Join(names, ", "))
+
+	if !options.Extract || options.OutputDir == "" {
+		return
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return
+	}
+	base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	outDir := filepath.Join(options.OutputDir, base+"_zip")
+	for _, f := range reader.File {
+		if f.Mode().IsDir() {
+			continue
+		}
+		if err := extractZIPEntry(f, outDir); err != nil {
+			result.AddFinding(fmt.Sprintf("Failed to extract archive entry %s", f.Name), 0.1, err.Error())
+		}
+	}
+	result.Recommendations = append(result.Recommendations,
+		fmt.Sprintf("Inspect the extracted archive entries in %s", outDir))
+}
+
+// extractZIPEntry writes one archive entry below outDir, refusing names that
+// would escape it
+func extractZIPEntry(f *zip.File, outDir string) error {
+	name := filepath.Clean(filepath.FromSlash(f.Name))
+	if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("unsafe entry path: %s", f.Name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open entry: %w", err)
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(io.LimitReader(rc, maxZIPEntrySize))
+	if err != nil {
+		return fmt.Errorf("failed to read entry: %w", err)
+	}
+	return filehandler.SaveFile(content, filepath.Join(outDir, name))
+}
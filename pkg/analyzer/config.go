@@ -0,0 +1,132 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+/*
+This file contains the detection thresholds the analyzers decide on. The
+defaults are tuned for ordinary photos and screenshots; a corpus of noisy scans
+or synthetic images may need them raised or lowered. A JSON file passed with
+-config overrides any of them, and fields it leaves out keep their defaults:
+
+	{"lsbAnomalyHigh": 0.9, "detectorProbability": 0.7}
+
+The weights of the ensemble score are set in the same file, as described in
+ensemble.go.
+*/
+
+// DetectionConfig holds the thresholds above which the analyzers report a finding
+type DetectionConfig struct {
+	LSBAnomalyHigh      float64 `json:"lsbAnomalyHigh"`      // LSB anomaly score of a highly anomalous distribution
+	LSBAnomalyUnusual   float64 `json:"lsbAnomalyUnusual"`   // LSB anomaly score of an unusual distribution
+	LSBEntropyHigh      float64 `json:"lsbEntropyHigh"`      // LSB entropy that counts as unnaturally perfect
+	LSBEntropyLow       float64 `json:"lsbEntropyLow"`       // LSB entropy below which the LSBs are unnaturally uniform
+	PlaneCorrelation    float64 `json:"planeCorrelation"`    // Correlation of the R, G and B LSB planes that counts as repeated data
+	AlphaEntropy        float64 `json:"alphaEntropy"`        // Alpha LSB entropy of an opaque image that counts as data
+	ParityEvenRatio     float64 `json:"parityEvenRatio"`     // Share of even samples in every channel that counts as normalized
+	DetectorProbability float64 `json:"detectorProbability"` // Probability above which a DCT or PVD detector reports
+
+	EnsembleWeights map[string]float64 `json:"ensembleWeights"` // Weight of each detector in the ensemble score, 1 when not set
+}
+
+// DefaultDetectionConfig returns the built-in thresholds
+func DefaultDetectionConfig() DetectionConfig {
+	return DetectionConfig{
+		LSBAnomalyHigh:      0.8,
+		LSBAnomalyUnusual:   0.5,
+		LSBEntropyHigh:      0.99,
+		LSBEntropyLow:       0.3,
+		PlaneCorrelation:    0.8,
+		AlphaEntropy:        0.9,
+		ParityEvenRatio:     0.7,
+		DetectorProbability: 0.5,
+	}
+}
+
+// LoadDetectionConfig returns the built-in thresholds overridden by the JSON
+// file at path
+func LoadDetectionConfig(path string) (DetectionConfig, error) {
+	config := DefaultDetectionConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read config file: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields() // A misspelled threshold would otherwise be ignored
+	if err := decoder.Decode(&config); err != nil {
+		return config, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return config, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// validate checks that every threshold is in 0-1 and that the LSB levels are ordered
+func (c DetectionConfig) validate() error {
+	for _, t := range []struct {
+		name  string
+		value float64
+	}{
+		{"lsbAnomalyHigh", c.LSBAnomalyHigh},
+		{"lsbAnomalyUnusual", c.LSBAnomalyUnusual},
+		{"lsbEntropyHigh", c.LSBEntropyHigh},
+		{"lsbEntropyLow", c.LSBEntropyLow},
+		{"planeCorrelation", c.PlaneCorrelation},
+		{"alphaEntropy", c.AlphaEntropy},
+		{"parityEvenRatio", c.ParityEvenRatio},
+		{"detectorProbability", c.DetectorProbability},
+	} {
+		if t.value < 0 || t.value > 1 {
+			return fmt.Errorf("%s must be between 0 and 1, got %g", t.name, t.value)
+		}
+	}
+	for detector, weight := range c.EnsembleWeights {
+		if _, ok := detectorFamilies[detector]; !ok {
+			return fmt.Errorf("ensembleWeights has unknown detector %q", detector)
+		}
+		if weight < 0 {
+			return fmt.Errorf("ensembleWeights.%s must not be negative, got %g", detector, weight)
+		}
+	}
+	if c.LSBAnomalyUnusual > c.LSBAnomalyHigh {
+		return fmt.Errorf("lsbAnomalyUnusual (%g) is above lsbAnomalyHigh (%g)", c.LSBAnomalyUnusual, c.LSBAnomalyHigh)
+	}
+	if c.LSBEntropyLow > c.LSBEntropyHigh {
+		return fmt.Errorf("lsbEntropyLow (%g) is above lsbEntropyHigh (%g)", c.LSBEntropyLow, c.LSBEntropyHigh)
+	}
+	return nil
+}
+
+// LSBScore maps an LSB anomaly score to a detection score. The anomaly score is
+// rescaled piecewise so that lsbAnomalyUnusual and lsbAnomalyHigh land on the
+// built-in cut points 0.5 and 0.8: with the built-in thresholds the score is
+// unchanged, and a raised threshold lowers the score of the files it no longer
+// flags along with their findings.
+func (c DetectionConfig) LSBScore(anomaly float64) float64 {
+	const unusual, high = 0.5, 0.8 // Built-in cut points
+	switch {
+	case anomaly <= c.LSBAnomalyUnusual:
+		if c.LSBAnomalyUnusual == 0 {
+			return 0
+		}
+		return anomaly / c.LSBAnomalyUnusual * unusual
+	case anomaly <= c.LSBAnomalyHigh:
+		return unusual + (anomaly-c.LSBAnomalyUnusual)/(c.LSBAnomalyHigh-c.LSBAnomalyUnusual)*(high-unusual)
+	default:
+		return high + (anomaly-c.LSBAnomalyHigh)/(1-c.LSBAnomalyHigh)*(1-high)
+	}
+}
+
+// Thresholds returns the detection thresholds of the options, the built-in ones
+// when none were given
+func (o AnalysisOptions) Thresholds() DetectionConfig {
+	if o.Detection == nil {
+		return DefaultDetectionConfig()
+	}
+	return *o.Detection
+}
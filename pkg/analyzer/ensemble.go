@@ -0,0 +1,178 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the ensemble score. Each analyzer raises a file's detection
+score to its strongest finding, so three detectors that each give 0.7 score no
+higher than one of them. The ensemble pools the probabilities the detectors
+record with SetDetectorScore as weighted log-odds instead. A detector above
+0.5 and above detectorProbability adds evidence, so detectors that agree give a
+higher probability than any one of them alone; any other detector abstains
+rather than counting against the others, since most detectors are blind to the
+embedding the others look for. Detectors of one family measure related statistics of the same bits,
+so after the strongest of a family the others count at familyDiscount of their
+weight. Weights default to 1 and are set per detector in the -config file:
+
+	{"ensembleWeights": {"lsb_distribution": 0.5, "embedded_data": 1.5}}
+*/
+
+// Detectors whose probabilities the ensemble pools. The DCT detectors are keyed
+// by the lower-case name of the algorithm they look for.
+const (
+	DetectorLSBDistribution  = "lsb_distribution"  // LSB entropy and balance
+	DetectorChiSquare        = "chi_square"        // Pair-of-values chi-square test
+	DetectorPlaneCorrelation = "plane_correlation" // Correlation of the R, G and B LSB planes
+	DetectorParity           = "parity"            // Samples normalized to even values
+	DetectorPVD              = "pvd"               // Pixel-value differencing histogram steps
+	DetectorEmbeddedData     = "embedded_data"     // Data appended, prepended or embedded outside the image data
+	DetectorMetadata         = "metadata"          // Marker segments, comments and tags
+)
+
+// detectorFamilies groups the detectors that measure related statistics
+var detectorFamilies = map[string]string{
+	DetectorLSBDistribution:  "lsb",
+	DetectorChiSquare:        "lsb",
+	DetectorPlaneCorrelation: "lsb",
+	DetectorParity:           "lsb",
+	DetectorPVD:              "pvd",
+	"jsteg":                  "dct",
+	"f5":                     "dct",
+	"outguess":               "dct",
+	"steghide":               "dct",
+	"jphide":                 "dct",
+	DetectorEmbeddedData:     "structure",
+	DetectorMetadata:         "metadata",
+}
+
+// familyDiscount is the share of its weight a detector keeps when a stronger
+// detector of its family already agrees
+const familyDiscount = 0.5
+
+// maxDetectorProbability caps a detector's probability so that a single
+// detector at 1 does not decide the ensemble on its own
+const maxDetectorProbability = 0.99
+
+// ensembleFinding describes the finding of agreeing detectors
+const ensembleFinding = "Several detectors agree"
+
+// EnsembleScore is the pooled probability of a file's detectors
+type EnsembleScore struct {
+	Probability float64  // 0-1 probability that data is embedded
+	Agreeing    []string // Detectors that added evidence, strongest first
+}
+
+// Ensemble pools the probabilities of the detectors with the configured
+// weights. Without any detector above 0.5 and the detector threshold the
+// probability is the highest detector's, so a single detector's probability is
+// its own.
+func (c DetectionConfig) Ensemble(scores map[string]float64) EnsembleScore {
+	type vote struct {
+		detector string
+		logOdds  float64
+	}
+	families := map[string][]vote{}
+	highest := 0.0
+	for detector, probability := range scores {
+		highest = max(highest, probability)
+		if probability <= c.voteThreshold() {
+			continue
+		}
+		weight := c.ensembleWeight(detector)
+		if weight == 0 {
+			continue
+		}
+		p := min(probability, maxDetectorProbability)
+		family, ok := detectorFamilies[detector]
+		if !ok {
+			family = detector
+		}
+		families[family] = append(families[family], vote{detector, weight * math.Log(p/(1-p))})
+	}
+
+	var votes []vote
+	logOdds := 0.0
+	for _, family := range families {
+		sort.Slice(family, func(i, j int) bool { return family[i].logOdds > family[j].logOdds })
+		for i, v := range family {
+			if i > 0 {
+				v.logOdds *= familyDiscount
+			}
+			logOdds += v.logOdds
+		}
+		votes = append(votes, family...)
+	}
+	if len(votes) == 0 {
+		return EnsembleScore{Probability: highest}
+	}
+
+	sort.Slice(votes, func(i, j int) bool {
+		if votes[i].logOdds != votes[j].logOdds {
+			return votes[i].logOdds > votes[j].logOdds
+		}
+		return votes[i].detector < votes[j].detector
+	})
+	score := EnsembleScore{Probability: 1 / (1 + math.Exp(-logOdds))}
+	for _, v := range votes {
+		score.Agreeing = append(score.Agreeing, v.detector)
+	}
+	return score
+}
+
+// voteThreshold returns the probability a detector must exceed to add
+// evidence: 0.5, or the detector threshold when it is higher
+func (c DetectionConfig) voteThreshold() float64 {
+	return max(0.5, c.DetectorProbability)
+}
+
+// ensembleWeight returns the configured weight of a detector, 1 by default
+func (c DetectionConfig) ensembleWeight(detector string) float64 {
+	if weight, ok := c.EnsembleWeights[detector]; ok {
+		return weight
+	}
+	return 1
+}
+
+// ApplyEnsemble stores the ensemble probability of the result's detectors in
+// Details["ensemble_probability"]. When two or more detectors agree on a
+// probability above the detection score, it is reported as a finding, which
+// replaces that of an earlier call, and raises the score.
+func (c DetectionConfig) ApplyEnsemble(result *models.AnalysisResult) {
+	if len(result.DetectorScores) == 0 {
+		return
+	}
+	score := c.Ensemble(result.DetectorScores)
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	result.Details["ensemble_probability"] = score.Probability
+	if len(score.Agreeing) < 2 || score.Probability <= result.DetectionScore {
+		return
+	}
+
+	parts := make([]string, len(score.Agreeing))
+	for i, detector := range score.Agreeing {
+		parts[i] = fmt.Sprintf("%s %.2f", detector, result.DetectorScores[detector])
+	}
+	details := fmt.Sprintf("%d detectors above %.2f (%s) pool to %.2f", len(score.Agreeing), c.voteThreshold(), strings.Join(parts, ", "), score.Probability)
+	result.DetectionScore = score.Probability
+	for i, finding := range result.Findings {
+		if finding.Description == ensembleFinding {
+			result.Findings[i] = models.Finding{
+				Description: ensembleFinding,
+				Confidence:  score.Probability,
+				Severity:    models.SeverityFromScore(score.Probability),
+				Details:     details,
+			}
+			return
+		}
+	}
+	result.AddFinding(ensembleFinding, score.Probability, details)
+}
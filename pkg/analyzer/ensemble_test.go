@@ -0,0 +1,129 @@
+package analyzer
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"DeSteGo/pkg/models"
+)
+
+func TestEnsemble(t *testing.T) {
+	config := DefaultDetectionConfig()
+	alone := config.Ensemble(map[string]float64{DetectorChiSquare: 0.7}).Probability
+	lsbAgree := config.Ensemble(map[string]float64{
+		DetectorChiSquare:        0.7,
+		DetectorLSBDistribution:  0.7,
+		DetectorPlaneCorrelation: 0.7,
+	}).Probability
+
+	tests := []struct {
+		name      string
+		scores    map[string]float64
+		weights   map[string]float64
+		threshold float64 // Detector probability threshold, the default when 0
+		min       float64 // Exclusive bounds of the probability
+		max       float64
+	}{
+		{"single detector keeps its probability", map[string]float64{DetectorChiSquare: 0.7}, nil, 0, 0.699, 0.701},
+		{"no detector above 0.5", map[string]float64{DetectorChiSquare: 0.3, "jsteg": 0.4}, nil, 0, 0.399, 0.401},
+		{"LSB detectors agreeing beat any alone", map[string]float64{
+			DetectorChiSquare:        0.7,
+			DetectorLSBDistribution:  0.7,
+			DetectorPlaneCorrelation: 0.7,
+		}, nil, 0, 0.7, 1},
+		{"independent families beat one family", map[string]float64{
+			DetectorChiSquare:    0.7,
+			"jsteg":              0.7,
+			DetectorEmbeddedData: 0.7,
+		}, nil, 0, lsbAgree, 1},
+		{"abstaining detectors do not lower it", map[string]float64{
+			DetectorChiSquare:       0.7,
+			DetectorLSBDistribution: 0.7,
+			DetectorPVD:             0.1,
+			"f5":                    0,
+		}, nil, 0, alone, lsbAgree},
+		{"zero weight drops a detector", map[string]float64{
+			DetectorChiSquare:    0.7,
+			DetectorEmbeddedData: 0.9,
+		}, map[string]float64{DetectorEmbeddedData: 0}, 0, 0.699, 0.701},
+		{"certain detector is capped", map[string]float64{DetectorEmbeddedData: 1}, nil, 0, 0.98, 0.991},
+		{"no detector above a raised threshold", map[string]float64{
+			DetectorChiSquare: 0.7,
+			"f5":              0.9,
+			"steghide":        0.9,
+		}, nil, 0.95, 0.899, 0.901},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultDetectionConfig()
+			config.EnsembleWeights = tt.weights
+			if tt.threshold > 0 {
+				config.DetectorProbability = tt.threshold
+			}
+			got := config.Ensemble(tt.scores).Probability
+			if got <= tt.min || got >= tt.max || math.IsNaN(got) {
+				t.Errorf("probability %.4f, want between %.4f and %.4f", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestApplyEnsemble(t *testing.T) {
+	result := &models.AnalysisResult{DetectionScore: 0.7}
+	result.SetDetectorScore(DetectorChiSquare, 0.7)
+	result.SetDetectorScore(DetectorLSBDistribution, 0.75)
+	result.SetDetectorScore(DetectorPVD, 0.2)
+
+	config := DefaultDetectionConfig()
+	config.ApplyEnsemble(result)
+	first := result.DetectionScore
+	if first <= 0.75 {
+		t.Fatalf("detection score %.2f, want above the strongest detector", first)
+	}
+
+	// A later detector of another family raises the score again and updates the
+	// finding rather than adding a second one
+	result.SetDetectorScore("jsteg", 0.8)
+	config.ApplyEnsemble(result)
+	if result.DetectionScore <= first {
+		t.Errorf("detection score %.2f, want above %.2f", result.DetectionScore, first)
+	}
+	count := 0
+	for _, finding := range result.Findings {
+		if finding.Description == ensembleFinding {
+			count++
+			if finding.Confidence != result.DetectionScore {
+				t.Errorf("finding confidence %.2f, want %.2f", finding.Confidence, result.DetectionScore)
+			}
+		}
+	}
+	if count != 1 {
+		t.Errorf("%d ensemble findings, want 1", count)
+	}
+}
+
+func TestEnsembleWeightsConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		json  string
+		valid bool
+	}{
+		{"known detector", `{"ensembleWeights": {"chi_square": 0.5, "jsteg": 2}}`, true},
+		{"unknown detector", `{"ensembleWeights": {"rs_analysis": 1}}`, false},
+		{"negative weight", `{"ensembleWeights": {"pvd": -1}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(tt.json), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadDetectionConfig(path)
+			if (err == nil) != tt.valid {
+				t.Errorf("LoadDetectionConfig() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
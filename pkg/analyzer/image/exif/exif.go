@@ -0,0 +1,378 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+/*
+This file contains a small EXIF (TIFF IFD) parser. It only understands the parts
+of the format the analyzers need: IFD0/IFD1, the EXIF and GPS sub-IFDs, and the
+scalar, rational and ASCII tag types.
+*/
+
+// Tag IDs used by the analyzers
+const (
+	TagExifIFDPointer     = 0x8769
+	TagGPSIFDPointer      = 0x8825
+	TagJPEGInterchange    = 0x0201 // Thumbnail offset (IFD1)
+	TagJPEGInterchangeLen = 0x0202 // Thumbnail length (IFD1)
+	TagGPSLatitudeRef     = 0x0001
+	TagGPSLatitude        = 0x0002
+	TagGPSLongitudeRef    = 0x0003
+	TagGPSLongitude       = 0x0004
+	TagGPSAltitudeRef     = 0x0005
+	TagGPSAltitude        = 0x0006
+	TagGPSTimeStamp       = 0x0007
+	TagGPSDateStamp       = 0x001D
+	TagImageDescription   = 0x010E
+	TagArtist             = 0x013B
+	TagCopyright          = 0x8298
+	TagUserComment        = 0x9286
+)
+
+// TIFF field types
+const (
+	typeByte      = 1
+	typeASCII     = 2
+	typeShort     = 3
+	typeLong      = 4
+	typeRational  = 5
+	typeSByte     = 6
+	typeUndefined = 7
+	typeSShort    = 8
+	typeSLong     = 9
+	typeSRational = 10
+	typeFloat     = 11
+	typeDouble    = 12
+)
+
+const (
+	headerSize    = 8    // Byte order, magic and IFD0 offset
+	ifdEntrySize  = 12   // Size of a single IFD entry
+	maxTagsPerIFD = 1024 // Sanity limit for corrupt IFDs
+)
+
+// typeSizes maps a TIFF field type to its size in bytes
+var typeSizes = map[uint16]int{
+	typeByte: 1, typeASCII: 1, typeShort: 2, typeLong: 4, typeRational: 8,
+	typeSByte: 1, typeUndefined: 1, typeSShort: 2, typeSLong: 4, typeSRational: 8,
+	typeFloat: 4, typeDouble: 8,
+}
+
+// Header is the prefix of EXIF data stored in a JPEG APP1 segment
+var Header = []byte("Exif\x00\x00")
+
+// Tag is a single IFD entry
+type Tag struct {
+	ID    uint16
+	Type  uint16
+	Count uint32
+	Value []byte // Raw value bytes, already resolved from the offset if needed
+}
+
+// Data holds the parsed IFDs of an EXIF block
+type Data struct {
+	ByteOrder binary.ByteOrder
+	IFD0      map[uint16]Tag
+	IFD1      map[uint16]Tag // Thumbnail IFD
+	Exif      map[uint16]Tag
+	GPS       map[uint16]Tag
+	raw       []byte
+}
+
+// GPSInfo contains decoded GPS coordinates
+type GPSInfo struct {
+	Latitude    float64 // Decimal degrees, negative for south
+	Longitude   float64 // Decimal degrees, negative for west
+	Altitude    float64 // Metres, negative below sea level
+	HasAltitude bool
+	Timestamp   string // "YYYY:MM:DD HH:MM:SS" in UTC when present
+}
+
+// Parse parses TIFF-structured EXIF data. A leading "Exif\0\0" header is accepted.
+func Parse(data []byte) (*Data, error) {
+	data = bytes.TrimPrefix(data, Header)
+	if len(data) < headerSize {
+		return nil, errors.New("EXIF data too short")
+	}
+
+	d := &Data{raw: data}
+	switch string(data[:2]) {
+	case "II":
+		d.ByteOrder = binary.LittleEndian
+	case "MM":
+		d.ByteOrder = binary.BigEndian
+	default:
+		return nil, errors.New("invalid EXIF byte order marker")
+	}
+	if d.ByteOrder.Uint16(data[2:4]) != 42 {
+		return nil, errors.New("invalid TIFF magic number")
+	}
+
+	ifd0, next, err := d.readIFD(d.ByteOrder.Uint32(data[4:8]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read IFD0: %w", err)
+	}
+	d.IFD0 = ifd0
+
+	if next != 0 {
+		// A broken thumbnail IFD should not hide the rest of the metadata
+		if ifd1, _, err := d.readIFD(next); err == nil {
+			d.IFD1 = ifd1
+		}
+	}
+	if tag, ok := ifd0[TagExifIFDPointer]; ok {
+		if offset, ok := d.Uint(tag, 0); ok {
+			d.Exif, _, _ = d.readIFD(uint32(offset))
+		}
+	}
+	if tag, ok := ifd0[TagGPSIFDPointer]; ok {
+		if offset, ok := d.Uint(tag, 0); ok {
+			d.GPS, _, _ = d.readIFD(uint32(offset))
+		}
+	}
+
+	return d, nil
+}
+
+// readIFD reads the IFD at offset and returns its tags and the next IFD offset
+func (d *Data) readIFD(offset uint32) (map[uint16]Tag, uint32, error) {
+	if offset < headerSize || int64(offset)+2 > int64(len(d.raw)) {
+		return nil, 0, fmt.Errorf("IFD offset %d out of range", offset)
+	}
+
+	count := int(d.ByteOrder.Uint16(d.raw[offset:]))
+	if count > maxTagsPerIFD {
+		return nil, 0, fmt.Errorf("too many IFD entries: %d", count)
+	}
+	start := int(offset) + 2
+	if start+count*ifdEntrySize > len(d.raw) {
+		return nil, 0, errors.New("IFD entries extend past end of data")
+	}
+
+	tags := make(map[uint16]Tag, count)
+	for i := 0; i < count; i++ {
+		entry := d.raw[start+i*ifdEntrySize : start+(i+1)*ifdEntrySize]
+		tag := Tag{
+			ID:    d.ByteOrder.Uint16(entry[0:2]),
+			Type:  d.ByteOrder.Uint16(entry[2:4]),
+			Count: d.ByteOrder.Uint32(entry[4:8]),
+		}
+
+		size, ok := typeSizes[tag.Type]
+		if !ok {
+			continue // Unknown type, skip the entry
+		}
+		total := int64(size) * int64(tag.Count)
+		if total <= 4 {
+			tag.Value = entry[8 : 8+total]
+		} else {
+			valueOffset := int64(d.ByteOrder.Uint32(entry[8:12]))
+			if valueOffset+total > int64(len(d.raw)) {
+				continue // Value points outside the data
+			}
+			tag.Value = d.raw[valueOffset : valueOffset+total]
+		}
+		tags[tag.ID] = tag
+	}
+
+	next := uint32(0)
+	if end := start + count*ifdEntrySize; end+4 <= len(d.raw) {
+		next = d.ByteOrder.Uint32(d.raw[end:])
+	}
+	return tags, next, nil
+}
+
+// Uint returns the i-th value of a BYTE, SHORT or LONG tag
+func (d *Data) Uint(tag Tag, i int) (uint64, bool) {
+	switch tag.Type {
+	case typeByte, typeUndefined:
+		if i < len(tag.Value) {
+			return uint64(tag.Value[i]), true
+		}
+	case typeShort:
+		if 2*i+2 <= len(tag.Value) {
+			return uint64(d.ByteOrder.Uint16(tag.Value[2*i:])), true
+		}
+	case typeLong:
+		if 4*i+4 <= len(tag.Value) {
+			return uint64(d.ByteOrder.Uint32(tag.Value[4*i:])), true
+		}
+	}
+	return 0, false
+}
+
+// Rational returns the i-th value of a RATIONAL or SRATIONAL tag
+func (d *Data) Rational(tag Tag, i int) (float64, bool) {
+	if (tag.Type != typeRational && tag.Type != typeSRational) || 8*i+8 > len(tag.Value) {
+		return 0, false
+	}
+	num := d.ByteOrder.Uint32(tag.Value[8*i:])
+	den := d.ByteOrder.Uint32(tag.Value[8*i+4:])
+	if den == 0 {
+		return 0, false
+	}
+	if tag.Type == typeSRational {
+		return float64(int32(num)) / float64(int32(den)), true
+	}
+	return float64(num) / float64(den), true
+}
+
+// String returns the value of an ASCII tag without trailing NULs
+func (d *Data) String(tag Tag) string {
+	return strings.TrimRight(string(tag.Value), "\x00 ")
+}
+
+// textTagNames names the text tags reported by Texts; other ASCII tags are
+// named by their ID
+var textTagNames = map[uint16]string{
+	TagImageDescription: "ImageDescription",
+	0x010F:              "Make",
+	0x0110:              "Model",
+	0x0131:              "Software",
+	TagArtist:           "Artist",
+	TagCopyright:        "Copyright",
+	TagUserComment:      "UserComment",
+}
+
+// TextField is a text value stored in the EXIF data
+type TextField struct {
+	Name string // Tag name, such as "Artist", or "tag 0xC4A5" for unnamed tags
+	Text string // Value with trailing NULs removed, but not trailing spaces
+}
+
+// Texts returns the ASCII tags of IFD0 and the EXIF sub-IFD and the user
+// comment, in tag order. The user comment's character code prefix is removed and
+// its UNICODE form decoded from UTF-16.
+func (d *Data) Texts() []TextField {
+	var fields []TextField
+	for _, ifd := range []map[uint16]Tag{d.IFD0, d.Exif} {
+		ids := make([]int, 0, len(ifd))
+		for id := range ifd {
+			ids = append(ids, int(id))
+		}
+		sort.Ints(ids)
+		for _, id := range ids {
+			tag := ifd[uint16(id)]
+			name, ok := textTagNames[tag.ID]
+			if !ok {
+				name = fmt.Sprintf("tag 0x%04X", tag.ID)
+			}
+			switch {
+			case tag.ID == TagUserComment && tag.Type == typeUndefined:
+				if text := d.userComment(tag.Value); text != "" {
+					fields = append(fields, TextField{Name: name, Text: text})
+				}
+			case tag.Type == typeASCII:
+				if text := strings.TrimRight(string(tag.Value), "\x00"); text != "" {
+					fields = append(fields, TextField{Name: name, Text: text})
+				}
+			}
+		}
+	}
+	return fields
+}
+
+// userComment decodes a UserComment value, whose first eight bytes name its
+// character code
+func (d *Data) userComment(value []byte) string {
+	if len(value) < 8 {
+		return ""
+	}
+	code, text := string(bytes.TrimRight(value[:8], "\x00 ")), value[8:]
+	if code != "UNICODE" {
+		return strings.TrimRight(string(text), "\x00")
+	}
+	units := make([]uint16, len(text)/2)
+	for i := range units {
+		units[i] = d.ByteOrder.Uint16(text[2*i:])
+	}
+	return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+}
+
+// Raw returns the TIFF-structured bytes the offsets in the IFDs refer to
+func (d *Data) Raw() []byte {
+	return d.raw
+}
+
+// GPSInfo decodes the GPS IFD into decimal degrees. It returns nil when the
+// image carries no usable coordinates.
+func (d *Data) GPSInfo() *GPSInfo {
+	if d.GPS == nil {
+		return nil
+	}
+
+	lat, okLat := d.degrees(TagGPSLatitude)
+	lon, okLon := d.degrees(TagGPSLongitude)
+	if !okLat || !okLon {
+		return nil
+	}
+	if ref, ok := d.GPS[TagGPSLatitudeRef]; ok && strings.HasPrefix(strings.ToUpper(d.String(ref)), "S") {
+		lat = -lat
+	}
+	if ref, ok := d.GPS[TagGPSLongitudeRef]; ok && strings.HasPrefix(strings.ToUpper(d.String(ref)), "W") {
+		lon = -lon
+	}
+
+	info := &GPSInfo{Latitude: lat, Longitude: lon}
+
+	if tag, ok := d.GPS[TagGPSAltitude]; ok {
+		if alt, ok := d.Rational(tag, 0); ok {
+			info.Altitude = alt
+			info.HasAltitude = true
+			if ref, ok := d.GPS[TagGPSAltitudeRef]; ok {
+				if v, ok := d.Uint(ref, 0); ok && v == 1 {
+					info.Altitude = -alt
+				}
+			}
+		}
+	}
+
+	if tag, ok := d.GPS[TagGPSTimeStamp]; ok {
+		h, okH := d.Rational(tag, 0)
+		m, okM := d.Rational(tag, 1)
+		s, okS := d.Rational(tag, 2)
+		if okH && okM && okS {
+			clock := fmt.Sprintf("%02d:%02d:%02d", int(h), int(m), int(math.Round(s)))
+			if date, ok := d.GPS[TagGPSDateStamp]; ok {
+				info.Timestamp = d.String(date) + " " + clock
+			} else {
+				info.Timestamp = clock
+			}
+		}
+	}
+
+	return info
+}
+
+// degrees converts a degrees/minutes/seconds GPS tag into decimal degrees
+func (d *Data) degrees(id uint16) (float64, bool) {
+	tag, ok := d.GPS[id]
+	if !ok {
+		return 0, false
+	}
+	deg, ok1 := d.Rational(tag, 0)
+	min, ok2 := d.Rational(tag, 1)
+	sec, ok3 := d.Rational(tag, 2)
+	if !ok1 {
+		return 0, false
+	}
+	value := deg
+	if ok2 {
+		value += min / 60
+	}
+	if ok3 {
+		value += sec / 3600
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false
+	}
+	return value, true
+}
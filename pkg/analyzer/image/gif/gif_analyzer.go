@@ -0,0 +1,130 @@
+package gif
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/analyzer/carve"
+	"DeSteGo/pkg/analyzer/image/lsb"
+	"DeSteGo/pkg/models"
+)
+
+/*
+Summary of this file and these functions:
+- This file contains the GIFAnalyzer, an ImageAnalyzer for GIF images.
+- The Analyze method decodes every frame, runs the palette analysis on the first
+  frame and, for animations, the frame timing analysis in timing.go.
+- GIF pixels are palette indexes, so the LSB distribution tests of the PNG and
+  TIFF analyzers do not apply; twin palette entries are the pixel-level tell.
+- Files embedded inside or after the GIF data are reported by the shared carver.
+*/
+
+// GIFAnalyzer implements analysis for GIF images
+type GIFAnalyzer struct {
+	analyzer.BaseAnalyzer
+}
+
+// NewGIFAnalyzer creates a new GIF analyzer
+func NewGIFAnalyzer() *GIFAnalyzer {
+	return &GIFAnalyzer{
+		BaseAnalyzer: analyzer.NewBaseAnalyzer(
+			"GIF Analyzer",
+			"Analyzes GIF images and animations for steganography",
+			[]string{"gif"},
+		),
+	}
+}
+
+// Analyze performs analysis on a GIF file
+func (a *GIFAnalyzer) Analyze(filePath string, options analyzer.AnalysisOptions) (*models.AnalysisResult, error) {
+	data, err := models.ReadFile(options.Source, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode GIF: %w", err)
+	}
+	if len(g.Image) == 0 {
+		return nil, errors.New("failed to decode GIF: no frames")
+	}
+
+	result, err := a.AnalyzeImage(g.Image[0], options)
+	if err != nil {
+		return nil, err
+	}
+	result.Filename = filePath
+	result.Details["width"] = g.Config.Width
+	result.Details["height"] = g.Config.Height
+	result.Details["frames"] = len(g.Image)
+	result.Details["loop_count"] = g.LoopCount
+
+	// Frame delays and disposal methods can carry data without changing a pixel
+	if len(g.Image) > 1 {
+		before := len(result.Findings)
+		analyzeTiming(g, filePath, options, result)
+		if len(result.Findings) == before {
+			result.AddCheck(fmt.Sprintf("frame delays and disposal methods as an editor sets them (%d frames)", len(g.Image)))
+		}
+	}
+
+	before := len(result.Findings)
+	carve.AnalyzeEmbeddedFiles(data, "gif", filePath, options, result)
+	if len(result.Findings) == before {
+		result.AddCheck("no embedded or appended files")
+	}
+
+	result.SetCleanRationale()
+	return result, nil
+}
+
+// AnalyzeImage analyzes one decoded GIF frame
+func (a *GIFAnalyzer) AnalyzeImage(img image.Image, options analyzer.AnalysisOptions) (*models.AnalysisResult, error) {
+	if img == nil {
+		return nil, errors.New("nil image provided")
+	}
+
+	result := &models.AnalysisResult{
+		FileType:        "gif",
+		Findings:        []models.Finding{},
+		Recommendations: []string{},
+		DetectionScore:  0.1,
+		Confidence:      0.5,
+	}
+	bounds := img.Bounds()
+	result.Details = map[string]interface{}{
+		"width":    bounds.Dx(),
+		"height":   bounds.Dy(),
+		"capacity": lsb.EstimateCapacity(img),
+	}
+
+	// Twin palette entries let the index choice carry data
+	palette := lsb.AnalyzePalette(img)
+	if !palette.Indexed {
+		return result, nil
+	}
+	result.Details["palette_size"] = palette.Size
+	result.Details["palette_used"] = palette.Used
+	result.Details["palette_duplicates"] = palette.Duplicates
+	result.Details["palette_near_duplicates"] = palette.NearDuplicates
+	switch {
+	case palette.Suspicious:
+		result.AddFinding("Palette entries with identical colors are used interchangeably", 0.85, palette.Describe())
+		result.AddExtractionHint("palette-index", 0.85, map[string]interface{}{"duplicates": palette.Duplicates})
+		result.Recommendations = append(result.Recommendations,
+			"Read one bit per pixel from which of the twin palette entries it uses")
+		result.DetectionScore = 0.85
+		result.PossibleAlgorithm = "Palette Index Steganography"
+	case palette.Duplicates > 0:
+		result.AddFinding("Palette contains duplicate colors", 0.4, palette.Describe())
+		result.DetectionScore = 0.4
+	default:
+		result.AddCheck(fmt.Sprintf("no duplicate palette colors (%d of %d entries used)", palette.Used, palette.Size))
+	}
+	return result, nil
+}
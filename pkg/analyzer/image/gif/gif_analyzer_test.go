@@ -0,0 +1,96 @@
+package gif
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"DeSteGo/pkg/analyzer"
+)
+
+func TestAnalyzeTiming(t *testing.T) {
+	// bits spells text one bit per frame, most significant bit first, as the
+	// larger of two values
+	bits := func(text string, zero, one int) []int {
+		var values []int
+		for _, b := range []byte(text) {
+			for bit := 7; bit >= 0; bit-- {
+				values = append(values, zero+int(b>>bit&1)*(one-zero))
+			}
+		}
+		return values
+	}
+	repeat := func(n int, values ...int) []int {
+		var out []int
+		for len(out) < n {
+			out = append(out, values...)
+		}
+		return out[:n]
+	}
+	var message []int
+	for _, b := range []byte("HIDDEN MESSAGE") {
+		message = append(message, int(b))
+	}
+
+	tests := []struct {
+		name      string
+		delays    []int
+		disposals []int
+		want      []string
+	}{
+		{"steady", repeat(16, 10), repeat(16, 1), nil},
+		{"frame rate cycle", repeat(32, 3, 4), repeat(32, 1), nil},
+		{"delays as bytes", message, repeat(len(message), 1), []string{
+			"Frame delays vary like data",
+			"Frame delays as bytes decode to text",
+		}},
+		{"delays as bits", bits("Hide", 10, 11), repeat(32, 1), []string{
+			"Frame delays flip irregularly between two nearly equal values",
+			"Frame delays as bits decode to text",
+		}},
+		{"disposals as bits", repeat(32, 10), bits("Hide", 0, 1), []string{
+			"Disposal methods flip irregularly between two that render the same",
+			"Frame disposal methods as bits decode to text",
+		}},
+		{"reserved disposal", repeat(8, 10), repeat(8, 1, 5), []string{"Frames use reserved GIF disposal methods"}},
+		{"long delay", []int{10, 10, 7000}, repeat(3, 1), []string{"Frames have implausibly long delays"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &gif.GIF{}
+			palette := color.Palette{color.Black, color.White}
+			for i := range tt.delays {
+				frame := image.NewPaletted(image.Rect(0, 0, 4, 4), palette)
+				frame.Pix[i%len(frame.Pix)] = 1
+				g.Image = append(g.Image, frame)
+				g.Delay = append(g.Delay, tt.delays[i])
+				g.Disposal = append(g.Disposal, byte(tt.disposals[i]))
+			}
+			var buf bytes.Buffer
+			if err := gif.EncodeAll(&buf, g); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "anim.gif")
+			if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := NewGIFAnalyzer().Analyze(path, analyzer.AnalysisOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range result.Findings {
+				got = append(got, f.Description)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got findings %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package gif
+
+import (
+	"fmt"
+	"image/gif"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/analyzer/stats"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the frame timing analysis of animated GIFs. The graphic
+control extension of every frame holds a delay in hundredths of a second and a
+disposal method, and neither changes a pixel. Editors give every frame the same
+delay, or a few deliberate ones such as a longer hold on the last frame, and one
+disposal method throughout; encoders that approximate a frame rate alternate two
+delays in a fixed cycle. Data shows up instead as delays that flip irregularly
+between two values too close to tell apart, one bit per frame; as delays that
+take many different values, up to a byte per frame; as disposal methods that
+flip between "unspecified" and "do not dispose", which render the same; and as
+reserved disposal methods or delays no animation uses. Each sequence is also
+read as bytes, and one that reads as text is reported with the text.
+*/
+
+// Frame timing parameters
+const (
+	minTimingFrames   = 8    // Frames an animation needs before its sequences are judged
+	nearDelay         = 2    // Delay difference, in hundredths of a second, no viewer notices
+	minMinorityShare  = 0.1  // Share of frames the rarer of two values needs to carry bits
+	maxCyclePeriod    = 4    // Longest repeating cycle of an encoder approximating a frame rate
+	highDelayEntropy  = 3.0  // Delay entropy, in bits per frame, of delays that vary like data
+	maxPlausibleDelay = 6000 // Delays above a minute are not animation timing
+	minTimingText     = 4    // Bytes a decoded sequence needs to be reported as text
+	minByteEntropy    = 2.0  // Delay entropy, in bits per frame, below which delays are not read as bytes
+	maxDisposal       = 3    // Highest disposal method the GIF specification defines
+)
+
+// timingSequence is the bytes a per-frame sequence may encode
+type timingSequence struct {
+	name    string
+	decoded []byte
+}
+
+// analyzeTiming reports frame delay and disposal method sequences that look
+// like they carry data
+func analyzeTiming(g *gif.GIF, filePath string, options analyzer.AnalysisOptions, result *models.AnalysisResult) {
+	delays := g.Delay
+	disposals := make([]int, len(g.Disposal))
+	for i, d := range g.Disposal {
+		disposals[i] = int(d)
+	}
+	delayValues := distinct(delays)
+	disposalValues := distinct(disposals)
+	delayEntropy := sequenceEntropy(delays)
+	result.Details["frame_delays"] = delayValues
+	result.Details["frame_delay_entropy"] = delayEntropy
+	result.Details["disposal_methods"] = disposalValues
+
+	// Values outside the ranges encoders write
+	if reserved := countIf(disposals, func(v int) bool { return v > maxDisposal }); reserved > 0 {
+		result.AddFinding("Frames use reserved GIF disposal methods", 0.4,
+			fmt.Sprintf("%d of %d frames have a disposal method above %d (methods used: %v)", reserved, len(disposals), maxDisposal, disposalValues))
+		if result.DetectionScore < 0.4 {
+			result.DetectionScore = 0.4
+		}
+	}
+	if long := countIf(delays, func(v int) bool { return v > maxPlausibleDelay }); long > 0 {
+		result.AddFinding("Frames have implausibly long delays", 0.3,
+			fmt.Sprintf("%d of %d frames are shown for over %d seconds", long, len(delays), maxPlausibleDelay/100))
+	}
+
+	if len(delays) < minTimingFrames {
+		return
+	}
+
+	// One bit per frame in delays nobody can tell apart
+	if len(delayValues) == 2 && delayValues[1]-delayValues[0] <= nearDelay && carriesBits(delays, delayValues) {
+		result.AddFinding("Frame delays flip irregularly between two nearly equal values", 0.7,
+			fmt.Sprintf("%d and %d hundredths of a second over %d frames, with no repeating cycle", delayValues[0], delayValues[1], len(delays)))
+		if result.DetectionScore < 0.7 {
+			result.DetectionScore = 0.7
+		}
+	}
+
+	// Up to a byte per frame in delays that keep changing
+	if delayEntropy >= highDelayEntropy && len(delayValues) >= 1<<int(highDelayEntropy) {
+		result.AddFinding("Frame delays vary like data", 0.6,
+			fmt.Sprintf("%d distinct delays over %d frames, %.2f bits of entropy per frame", len(delayValues), len(delays), delayEntropy))
+		if result.DetectionScore < 0.6 {
+			result.DetectionScore = 0.6
+		}
+	}
+
+	// "Unspecified" and "do not dispose" look the same on screen
+	if len(disposalValues) == 2 && disposalValues[0] == 0 && disposalValues[1] == 1 && carriesBits(disposals, disposalValues) {
+		result.AddFinding("Disposal methods flip irregularly between two that render the same", 0.7,
+			fmt.Sprintf("methods 0 (unspecified) and 1 (do not dispose) over %d frames, with no repeating cycle", len(disposals)))
+		if result.DetectionScore < 0.7 {
+			result.DetectionScore = 0.7
+		}
+	}
+
+	base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	for _, seq := range decodeSequences(delays, delayValues, delayEntropy, disposals, disposalValues) {
+		if len(seq.decoded) < minTimingText || stats.PrintableRatio(seq.decoded) < 0.9 {
+			continue
+		}
+		result.AddFinding(fmt.Sprintf("Frame %s decode to text", seq.name), 0.85,
+			fmt.Sprintf("%d bytes: %q", len(seq.decoded), preview(seq.decoded)))
+		if result.DetectionScore < 0.85 {
+			result.DetectionScore = 0.85
+			result.PossibleAlgorithm = "GIF Frame Timing"
+		}
+		result.Recommendations = append(result.Recommendations,
+			fmt.Sprintf("Read the text encoded in the frame %s", seq.name))
+
+		if !options.Extract || options.OutputDir == "" {
+			continue
+		}
+		name := strings.ReplaceAll(seq.name, " ", "_")
+		outPath := filepath.Join(options.OutputDir, fmt.Sprintf("%s_%s.txt", base, name))
+		if outPath, err := filehandler.SaveFileUnique(seq.decoded, outPath); err == nil {
+			result.Details[name+"_file"] = outPath
+		}
+	}
+}
+
+// decodeSequences reads the delay and disposal sequences that vary enough to
+// carry data as bytes: delays one byte per frame when they fit, and two-valued
+// sequences one bit per frame, most significant bit first, with the larger
+// value as 1
+func decodeSequences(delays, delayValues []int, delayEntropy float64, disposals, disposalValues []int) []timingSequence {
+	var seqs []timingSequence
+	if delayEntropy >= minByteEntropy && delayValues[len(delayValues)-1] <= 0xFF {
+		decoded := make([]byte, len(delays))
+		for i, d := range delays {
+			decoded[i] = byte(d)
+		}
+		seqs = append(seqs, timingSequence{name: "delays as bytes", decoded: decoded})
+	}
+	if len(delayValues) == 2 && carriesBits(delays, delayValues) {
+		seqs = append(seqs, timingSequence{name: "delays as bits", decoded: packBits(delays, delayValues[1])})
+	}
+	if len(disposalValues) == 2 && carriesBits(disposals, disposalValues) {
+		seqs = append(seqs, timingSequence{name: "disposal methods as bits", decoded: packBits(disposals, disposalValues[1])})
+	}
+	return seqs
+}
+
+// packBits packs one bit per value, 1 where the value equals one, into bytes,
+// dropping a final partial byte
+func packBits(values []int, one int) []byte {
+	decoded := make([]byte, len(values)/8)
+	for i := range decoded {
+		for bit := 0; bit < 8; bit++ {
+			if values[i*8+bit] == one {
+				decoded[i] |= 0x80 >> bit
+			}
+		}
+	}
+	return decoded
+}
+
+// carriesBits reports whether a sequence of two values uses the rarer one often
+// enough, and irregularly enough, to carry data. The last frame is left out,
+// since animations commonly hold it longer.
+func carriesBits(values, distinctValues []int) bool {
+	body := values[:len(values)-1]
+	rare := countIf(body, func(v int) bool { return v == distinctValues[0] })
+	if other := len(body) - rare; other < rare {
+		rare = other
+	}
+	if float64(rare) < minMinorityShare*float64(len(body)) {
+		return false
+	}
+	return !cyclic(body)
+}
+
+// cyclic reports whether values repeat with a period of at most maxCyclePeriod
+func cyclic(values []int) bool {
+	for period := 1; period <= maxCyclePeriod && period < len(values); period++ {
+		repeats := true
+		for i := period; i < len(values); i++ {
+			if values[i] != values[i-period] {
+				repeats = false
+				break
+			}
+		}
+		if repeats {
+			return true
+		}
+	}
+	return false
+}
+
+// distinct returns the distinct values in ascending order
+func distinct(values []int) []int {
+	seen := map[int]bool{}
+	var out []int
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// sequenceEntropy returns the Shannon entropy of a sequence's values in bits per value
+func sequenceEntropy(values []int) float64 {
+	counts := map[int]int{}
+	for _, v := range values {
+		counts[v]++
+	}
+	entropy := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(len(values))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// countIf returns how many values satisfy f
+func countIf(values []int, f func(int) bool) int {
+	n := 0
+	for _, v := range values {
+		if f(v) {
+			n++
+		}
+	}
+	return n
+}
+
+// preview returns the start of decoded text for a finding
+func preview(data []byte) string {
+	const limit = 80
+	if len(data) > limit {
+		return string(data[:limit]) + "..."
+	}
+	return string(data)
+}
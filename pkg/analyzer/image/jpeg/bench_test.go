@@ -0,0 +1,46 @@
+package jpeg
+
+import (
+	"fmt"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+)
+
+// benchJPEG returns a size x size JPEG of seeded noise at quality 90
+func benchJPEG(b *testing.B, size int) []byte {
+	data, err := fixtures.EncodeJPEG(fixtures.Noise(size, 1), 90)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+func BenchmarkExtractJPEGMetadata(b *testing.B) {
+	for _, size := range fixtures.BenchSizes {
+		data := benchJPEG(b, size)
+		b.Run(fmt.Sprintf("%dpx", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := ExtractJPEGMetadata(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkParseJPEGDCTCoefficients decodes the entropy-coded data, which the
+// DCT detectors and JSteg extraction start from
+func BenchmarkParseJPEGDCTCoefficients(b *testing.B) {
+	for _, size := range fixtures.BenchSizes {
+		data := benchJPEG(b, size)
+		b.Run(fmt.Sprintf("%dpx", size), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := ParseJPEGDCTCoefficients(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package jpeg
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+This file contains the block orders used to read a bit stream out of DCT
+coefficients. Embedders that work inside the encoder, such as JSteg, see the
+blocks in MCU order with the components interleaved, while tools that edit a
+decoded coefficient array usually walk one component at a time. Some only use
+the luminance or only the chrominance blocks. The same coefficients give a
+different stream in each order, so extractors try several.
+*/
+
+// BlockOrder selects which components' blocks are visited and in what order
+type BlockOrder string
+
+// Block orders supported by JPEGDCTData.OrderedBlocks
+const (
+	OrderInterleaved BlockOrder = "interleaved" // All components, MCU by MCU as stored in the scan
+	OrderLuminance   BlockOrder = "luminance"   // Only Y blocks, in MCU order
+	OrderChrominance BlockOrder = "chrominance" // Only Cb and Cr blocks, in MCU order
+	OrderSequential  BlockOrder = "sequential"  // All components, one after another in raster order
+)
+
+// BlockOrders lists every supported order, most common first
+var BlockOrders = []BlockOrder{OrderInterleaved, OrderLuminance, OrderChrominance, OrderSequential}
+
+// ParseBlockOrder converts a name such as "y", "chroma" or "interleaved" into a BlockOrder
+func ParseBlockOrder(name string) (BlockOrder, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "interleaved", "mcu", "all":
+		return OrderInterleaved, nil
+	case "luminance", "luma", "y":
+		return OrderLuminance, nil
+	case "chrominance", "chroma", "cbcr":
+		return OrderChrominance, nil
+	case "sequential", "component", "planar":
+		return OrderSequential, nil
+	}
+	return "", fmt.Errorf("unknown block order %q (use interleaved, luminance, chrominance or sequential)", name)
+}
+
+// MCUs returns the number of MCU columns and rows of an interleaved scan. An
+// MCU covers 8 pixels times the largest sampling factor in each direction, so
+// a 4:2:0 image has 16x16-pixel MCUs of four luminance blocks and one block of
+// each chrominance component.
+func (d *JPEGDCTData) MCUs() (int, int) {
+	maxH, maxV := 1, 1
+	for _, c := range d.Components {
+		maxH = max(maxH, c.HSamplingFactor)
+		maxV = max(maxV, c.VSamplingFactor)
+	}
+	return ceilDiv(d.Width, 8*maxH), ceilDiv(d.Height, 8*maxV)
+}
+
+// OrderedBlocks returns the blocks in the given order. MCU orders skip the
+// padding blocks of partial MCUs at the right and bottom edges, which are not
+// part of Blocks.
+func (d *JPEGDCTData) OrderedBlocks(order BlockOrder) []*DCTCoefficientBlock {
+	// Blocks holds every component's coded blocks in raster order, one component after another
+	start := make([]int, len(d.Components))
+	total := 0
+	for i, c := range d.Components {
+		start[i] = total
+		total += c.BlocksWide * c.BlocksHigh
+	}
+	if total != len(d.Blocks) {
+		return nil
+	}
+
+	include := func(component int) bool {
+		switch order {
+		case OrderLuminance:
+			return component == 0
+		case OrderChrominance:
+			return component > 0
+		}
+		return true
+	}
+
+	var blocks []*DCTCoefficientBlock
+	if order == OrderSequential || len(d.Components) == 1 {
+		for i := range d.Blocks {
+			if include(d.Blocks[i].Component) {
+				blocks = append(blocks, &d.Blocks[i])
+			}
+		}
+		return blocks
+	}
+
+	mcusX, mcusY := d.MCUs()
+	for my := 0; my < mcusY; my++ {
+		for mx := 0; mx < mcusX; mx++ {
+			for i, c := range d.Components {
+				if !include(i) {
+					continue
+				}
+				for v := 0; v < c.VSamplingFactor; v++ {
+					for h := 0; h < c.HSamplingFactor; h++ {
+						row, col := my*c.VSamplingFactor+v, mx*c.HSamplingFactor+h
+						if row < c.BlocksHigh && col < c.BlocksWide {
+							blocks = append(blocks, &d.Blocks[start[i]+row*c.BlocksWide+col])
+						}
+					}
+				}
+			}
+		}
+	}
+	return blocks
+}
@@ -0,0 +1,102 @@
+package jpeg
+
+import (
+	"bytes"
+	"image"
+)
+
+/*
+This file contains the color model of a JPEG. The frame header only gives the
+number of components; what they hold comes from convention and from the Adobe
+APP14 segment, whose transform flag says whether the encoder converted the
+colors. Three components are YCbCr unless Adobe says they were stored as they
+are (RGB), and four are CMYK, or YCCK when Adobe says the first three were
+converted. Print workflows produce CMYK and YCCK files; image/jpeg decodes both
+to *image.CMYK, inverting the channels as Adobe encoders store them inverted.
+*/
+
+// ColorModel names what the components of a JPEG hold
+type ColorModel string
+
+// Color models of JPEG files
+const (
+	ColorGray  ColorModel = "grayscale"
+	ColorYCbCr ColorModel = "YCbCr"
+	ColorRGB   ColorModel = "RGB"
+	ColorCMYK  ColorModel = "CMYK"
+	ColorYCCK  ColorModel = "YCCK"
+)
+
+// Adobe APP14 color transforms
+const (
+	adobeTransformUnknown = -1 // No Adobe segment
+	adobeTransformNone    = 0  // RGB or CMYK
+	adobeTransformYCCK    = 2  // YCbCr plus black
+)
+
+// adobeTransform returns the color transform of an APP14 payload, or
+// adobeTransformUnknown when it is not an Adobe segment
+func adobeTransform(payload []byte) int {
+	if len(payload) < 12 || !bytes.HasPrefix(payload, []byte("Adobe")) {
+		return adobeTransformUnknown
+	}
+	return int(payload[11])
+}
+
+// colorModelOf returns the color model of a frame with the given number of
+// components and Adobe transform, or "" for an unusual component count
+func colorModelOf(components, transform int) ColorModel {
+	switch components {
+	case 1:
+		return ColorGray
+	case 3:
+		if transform == adobeTransformNone {
+			return ColorRGB
+		}
+		return ColorYCbCr
+	case 4:
+		if transform == adobeTransformYCCK {
+			return ColorYCCK
+		}
+		return ColorCMYK
+	}
+	return ""
+}
+
+// Multichannel reports whether the model has a fourth (black) component
+func (m ColorModel) Multichannel() bool {
+	return m == ColorCMYK || m == ColorYCCK
+}
+
+// firstComponentAt returns a function reading the samples of a decoded image's
+// first component as the encoder coded them: luminance for YCbCr and YCCK, red
+// for RGB and uninverted cyan for CMYK
+func firstComponentAt(img image.Image, model ColorModel) func(x, y int) float64 {
+	switch img := img.(type) {
+	case *image.YCbCr:
+		return func(x, y int) float64 {
+			return float64(img.Y[img.YOffset(x, y)])
+		}
+	case *image.CMYK:
+		if model == ColorYCCK {
+			// image/jpeg turns YCbCr into RGB and inverts it to CMY
+			return func(x, y int) float64 {
+				p := img.Pix[img.PixOffset(x, y):]
+				return 0.299*float64(255-p[0]) + 0.587*float64(255-p[1]) + 0.114*float64(255-p[2])
+			}
+		}
+		return func(x, y int) float64 {
+			return float64(255 - img.Pix[img.PixOffset(x, y)])
+		}
+	}
+	if model == ColorRGB {
+		return func(x, y int) float64 {
+			r, _, _, _ := img.At(x, y).RGBA()
+			return float64(r) / 257
+		}
+	}
+	return func(x, y int) float64 {
+		r, g, b, _ := img.At(x, y).RGBA()
+		return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+	}
+}
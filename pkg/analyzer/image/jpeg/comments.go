@@ -0,0 +1,144 @@
+package jpeg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/analyzer/stats"
+	"DeSteGo/pkg/analyzer/whitespace"
+	"DeSteGo/pkg/c2"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+	"DeSteGo/pkg/rules"
+)
+
+/*
+This file contains the JPEG comment (COM) analysis. Decoders ignore comments,
+so they are an easy place to hide text. Each comment is decoded as base64 or hex
+when its whole content fits that alphabet, and the decoded (or plain) text is
+checked against the C2 command list and the built-in indicator rules and for
+text hidden in invisible characters. With extraction enabled, decoded payloads
+are written to the output directory.
+*/
+
+// minEncodedComment is the shortest comment that is tried as base64 or hex
+const minEncodedComment = 16
+
+// DecodedComment is a comment together with its decoded payload
+type DecodedComment struct {
+	Raw      []byte
+	Encoding string // "base64", "hex" or empty when the comment is not encoded
+	Payload  []byte // Decoded bytes, or Raw when the comment is not encoded
+}
+
+// DecodeComment decodes a comment that consists entirely of base64 or hex text
+func DecodeComment(comment []byte) DecodedComment {
+	decoded := DecodedComment{Raw: comment, Payload: comment}
+
+	text := strings.Join(strings.Fields(string(bytes.TrimRight(comment, "\x00"))), "")
+	if len(text) < minEncodedComment {
+		return decoded
+	}
+
+	if len(text)%2 == 0 {
+		if payload, err := hex.DecodeString(text); err == nil {
+			decoded.Encoding, decoded.Payload = "hex", payload
+			return decoded
+		}
+	}
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if payload, err := encoding.DecodeString(text); err == nil && plausiblePayload(payload) {
+			decoded.Encoding, decoded.Payload = "base64", payload
+			return decoded
+		}
+	}
+	return decoded
+}
+
+// plausiblePayload rejects base64 decodings of ordinary words, which decode to
+// binary noise, by requiring text or a known file signature
+func plausiblePayload(payload []byte) bool {
+	if utf8.Valid(payload) && stats.PrintableRatio(payload) > 0.9 {
+		return true
+	}
+	for _, magic := range [][]byte{[]byte("\x89PNG"), {0xFF, 0xD8, 0xFF}, []byte("PK\x03\x04"), []byte("%PDF"),
+		{0x1F, 0x8B}, []byte("\x7fELF"), []byte("MZ"), []byte("Rar!")} {
+		if bytes.HasPrefix(payload, magic) {
+			return true
+		}
+	}
+	return false
+}
+
+// analyzeComments decodes the COM segments and reports encoded or suspicious
+// content
+func analyzeComments(meta *JPEGMetadata, filePath string, options analyzer.AnalysisOptions, result *models.AnalysisResult) {
+	if len(meta.Comments) == 0 {
+		return
+	}
+
+	detector := c2.NewDetector()
+	ruleSet := rules.Default()
+	base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+
+	var comments []map[string]interface{}
+	for i, comment := range meta.Comments {
+		decoded := DecodeComment(comment)
+		entry := map[string]interface{}{
+			"length":   len(comment),
+			"encoding": decoded.Encoding,
+			"preview":  commentPreview(decoded.Payload),
+		}
+		comments = append(comments, entry)
+
+		source := fmt.Sprintf("JPEG comment %d", i+1)
+		if decoded.Encoding != "" {
+			result.AddFinding(fmt.Sprintf("%s is %s-encoded", source, decoded.Encoding), 0.7,
+				fmt.Sprintf("%d bytes decode to %d bytes: %s", len(comment), len(decoded.Payload), commentPreview(decoded.Payload)))
+			if result.DetectionScore < 0.7 {
+				result.DetectionScore = 0.7
+			}
+		}
+
+		if matches := detector.Match(decoded.Payload); len(matches) >= c2.MinMatches {
+			result.AddFindingWithSeverity(fmt.Sprintf("%s contains C2-style commands", source), models.SeverityConfirmed, 1.0,
+				strings.Join(matches, ", "))
+		}
+		rules.Apply(ruleSet.Evaluate(decoded.Payload), source, result)
+		whitespace.Analyze(string(comment), source, filePath, options, result)
+
+		if decoded.Encoding == "" || !options.Extract || options.OutputDir == "" {
+			continue
+		}
+		ext := "bin"
+		if utf8.Valid(decoded.Payload) && stats.PrintableRatio(decoded.Payload) > 0.9 {
+			ext = "txt"
+		}
+		outPath := filepath.Join(options.OutputDir, fmt.Sprintf("%s_comment_%d.%s", base, i+1, ext))
+		if outPath, err := filehandler.SaveFileUnique(decoded.Payload, outPath); err == nil {
+			entry["file"] = outPath
+			result.Recommendations = append(result.Recommendations,
+				fmt.Sprintf("Inspect the decoded %s: %s", source, outPath))
+		}
+	}
+
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	result.Details["comments"] = comments
+}
+
+// commentPreview returns the start of a payload as quoted display text
+func commentPreview(payload []byte) string {
+	const maxPreview = 60
+	if len(payload) > maxPreview {
+		return fmt.Sprintf("%q...", payload[:maxPreview])
+	}
+	return fmt.Sprintf("%q", payload)
+}
@@ -0,0 +1,953 @@
+package jpeg
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+This file contains a decoder for the entropy-coded portion of a JPEG file.
+Unlike image/jpeg it stops after Huffman decoding and keeps the quantized DCT
+coefficients, which is where JPEG steganography (JSteg, F5, OutGuess, ...) lives.
+Both baseline and progressive Huffman-coded JPEGs are supported.
+*/
+
+// JPEG marker codes used by the parser
+const (
+	markerSOF0  = 0xC0 // Baseline DCT
+	markerSOF1  = 0xC1 // Extended sequential DCT
+	markerSOF2  = 0xC2 // Progressive DCT
+	markerDHT   = 0xC4
+	markerRST0  = 0xD0
+	markerRST7  = 0xD7
+	markerSOI   = 0xD8
+	markerEOI   = 0xD9
+	markerSOS   = 0xDA
+	markerDQT   = 0xDB
+	markerDRI   = 0xDD
+	markerAPP0  = 0xE0
+	markerAPP14 = 0xEE
+	markerCOM   = 0xFE
+)
+
+// maxDCTPixels caps the image size the coefficient parser will allocate for.
+// The coefficients of a 4:2:0 image take about 3 bytes per pixel.
+const maxDCTPixels = 50 * 1000 * 1000
+
+// Every coded block takes at least this many bits of entropy-coded data: a DC
+// code, and in a sequential scan an end-of-block code. The parser refuses to
+// allocate more blocks than the rest of the file could hold.
+const (
+	minBaselineBlockBits    = 2
+	minProgressiveBlockBits = 1
+)
+
+// errScanExhausted is returned when a scan needs more bits than its
+// entropy-coded data holds
+var errScanExhausted = errors.New("invalid JPEG: entropy-coded data ends before the scan's last block")
+
+// maxRestartGaps caps the bytes kept from between restart intervals
+const maxRestartGaps = 1024 * 1024
+
+// DCTCoefficientBlock holds the quantized coefficients of one 8x8 block
+type DCTCoefficientBlock struct {
+	Component    int       // Index into JPEGDCTData.Components
+	Row          int       // Block row within the component
+	Col          int       // Block column within the component
+	Coefficients [64]int16 // Quantized coefficients in zig-zag order (index 0 is DC)
+}
+
+// ComponentInfo describes a single colour component declared in the frame header
+type ComponentInfo struct {
+	ID              int
+	HSamplingFactor int
+	VSamplingFactor int
+	QuantTableID    int
+	BlocksWide      int // Number of coded block columns
+	BlocksHigh      int // Number of coded block rows
+}
+
+// JPEGDCTData contains the decoded DCT coefficients of a JPEG image
+type JPEGDCTData struct {
+	Width           int
+	Height          int
+	Progressive     bool
+	RestartInterval int
+	Restarts        RestartMarkers
+	Components      []ComponentInfo
+	ColorModel      ColorModel // What the components hold, from their count and the Adobe segment
+	QuantTables     map[int][64]uint16
+	Blocks          []DCTCoefficientBlock
+}
+
+// RestartMarkers describes the RSTn markers of the scans against the restart
+// interval declared by DRI. An encoder writes RST0 to RST7 in turn, each right
+// after the padded end of an interval.
+type RestartMarkers struct {
+	Expected   int    // Places where the interval calls for a marker
+	Found      int    // Markers found at those places
+	Missing    int    // Places where the scan ended before a marker
+	OutOfOrder int    // Markers whose number does not follow the previous marker's
+	Stray      int    // Markers in the scans that the interval does not call for
+	Gaps       []byte // Bytes found between the end of an interval and its marker
+}
+
+// huffmanTable is a canonical Huffman decoding table (JPEG spec F.2.2.3)
+type huffmanTable struct {
+	maxCode [17]int32
+	valPtr  [17]int32
+	minCode [17]int32
+	values  []byte
+}
+
+// scanComponent is a component selected by a SOS header
+type scanComponent struct {
+	index int // Index into the frame components
+	dc    int // DC Huffman table ID
+	ac    int // AC Huffman table ID
+}
+
+// dctDecoder carries the state needed while decoding the entropy-coded segments
+type dctDecoder struct {
+	width, height   int
+	progressive     bool
+	restartInterval int
+	restarts        RestartMarkers
+	components      []ComponentInfo
+	blocks          []DCTCoefficientBlock // Coded blocks of every component, allocated at the first scan
+	offsets         []int                 // Index in blocks of each component's first block
+	padding         [64]int16             // Decoded blocks of the MCU padding, which are not kept
+	maxH, maxV      int
+	mcusX, mcusY    int
+	dcTables        [4]*huffmanTable
+	acTables        [4]*huffmanTable
+	quant           map[int][64]uint16
+	eobrun          int
+	frameSeen       bool
+	adobeTransform  int
+}
+
+// ParseJPEGDCTCoefficients decodes the quantized DCT coefficients of a JPEG file
+func ParseJPEGDCTCoefficients(data []byte) (*JPEGDCTData, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != markerSOI {
+		return nil, errors.New("invalid JPEG: missing SOI marker")
+	}
+
+	d := &dctDecoder{quant: make(map[int][64]uint16), adobeTransform: adobeTransformUnknown}
+	pos := 2
+
+	for pos < len(data) {
+		// Find the next marker, skipping any fill bytes
+		if data[pos] != 0xFF {
+			return nil, fmt.Errorf("invalid JPEG: expected marker at offset %d", pos)
+		}
+		for pos < len(data) && data[pos] == 0xFF {
+			pos++
+		}
+		if pos >= len(data) {
+			break
+		}
+		marker := data[pos]
+		pos++
+
+		if marker == markerEOI {
+			break
+		}
+		if marker >= markerRST0 && marker <= markerRST7 {
+			continue // Stray restart marker, no payload
+		}
+
+		if pos+2 > len(data) {
+			return nil, errors.New("invalid JPEG: truncated segment header")
+		}
+		length := int(data[pos])<<8 | int(data[pos+1])
+		if length < 2 || pos+length > len(data) {
+			return nil, fmt.Errorf("invalid JPEG: bad segment length %d for marker 0x%02X", length, marker)
+		}
+		segment := data[pos+2 : pos+length]
+		pos += length
+
+		var err error
+		switch marker {
+		case markerSOF0, markerSOF1, markerSOF2:
+			err = d.parseFrame(segment, marker == markerSOF2)
+		case markerDHT:
+			err = d.parseHuffmanTables(segment)
+		case markerDQT:
+			err = d.parseQuantTables(segment)
+		case markerDRI:
+			if len(segment) < 2 {
+				err = errors.New("invalid JPEG: short DRI segment")
+			} else {
+				d.restartInterval = int(segment[0])<<8 | int(segment[1])
+			}
+		case markerAPP14:
+			if transform := adobeTransform(segment); transform != adobeTransformUnknown {
+				d.adobeTransform = transform
+			}
+		case markerSOS:
+			if err = d.allocate(len(data) - pos); err != nil {
+				break
+			}
+			end := FindScanEnd(data, pos)
+			err = d.decodeScan(segment, data[pos:end])
+			pos = end
+		default:
+			if marker >= 0xC3 && marker <= 0xCF && marker != markerDHT && marker != 0xC8 && marker != 0xCC {
+				return nil, fmt.Errorf("unsupported JPEG coding process (SOF marker 0x%02X)", marker)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !d.frameSeen {
+		return nil, errors.New("invalid JPEG: no frame header found")
+	}
+
+	return d.result(), nil
+}
+
+// allocate creates the coefficient storage at the first scan, once it is known
+// that the available bytes of entropy-coded data could hold every block the
+// frame header declares
+func (d *dctDecoder) allocate(available int) error {
+	if !d.frameSeen {
+		return errors.New("invalid JPEG: scan before frame header")
+	}
+	if d.blocks != nil {
+		return nil
+	}
+
+	total := 0
+	d.offsets = make([]int, len(d.components))
+	for i, c := range d.components {
+		d.offsets[i] = total
+		total += c.BlocksWide * c.BlocksHigh
+	}
+	minBits := minBaselineBlockBits
+	if d.progressive {
+		minBits = minProgressiveBlockBits
+	}
+	if total > available*8/minBits {
+		return fmt.Errorf("invalid JPEG: %d bytes of scan data cannot hold the %d blocks of a %dx%d image", available, total, d.width, d.height)
+	}
+
+	d.blocks = make([]DCTCoefficientBlock, total)
+	for i, c := range d.components {
+		for row := 0; row < c.BlocksHigh; row++ {
+			for col := 0; col < c.BlocksWide; col++ {
+				block := &d.blocks[d.offsets[i]+row*c.BlocksWide+col]
+				block.Component, block.Row, block.Col = i, row, col
+			}
+		}
+	}
+	return nil
+}
+
+// block returns the coefficients of a component's block, or scratch storage
+// for a block of the padding of the last MCU row or column
+func (d *dctDecoder) block(component, row, col int) *[64]int16 {
+	c := d.components[component]
+	if row >= c.BlocksHigh || col >= c.BlocksWide {
+		return &d.padding
+	}
+	return &d.blocks[d.offsets[component]+row*c.BlocksWide+col].Coefficients
+}
+
+// FindScanEnd returns the offset of the first marker after an entropy-coded
+// segment starting at pos. Stuffed bytes (FF 00), fill bytes and restart
+// markers (FF D0-D7) are part of the scan and are skipped.
+func FindScanEnd(data []byte, pos int) int {
+	for pos < len(data)-1 {
+		if data[pos] != 0xFF {
+			pos++
+			continue
+		}
+		next := data[pos+1]
+		if next == 0x00 || next == 0xFF || (next >= markerRST0 && next <= markerRST7) {
+			pos++
+			continue
+		}
+		return pos
+	}
+	return len(data)
+}
+
+// parseFrame reads a SOF segment and sizes the components
+func (d *dctDecoder) parseFrame(seg []byte, progressive bool) error {
+	if d.frameSeen {
+		return errors.New("invalid JPEG: multiple frame headers")
+	}
+	if len(seg) < 6 {
+		return errors.New("invalid JPEG: short frame header")
+	}
+	if seg[0] != 8 {
+		return fmt.Errorf("unsupported JPEG sample precision: %d", seg[0])
+	}
+
+	d.height = int(seg[1])<<8 | int(seg[2])
+	d.width = int(seg[3])<<8 | int(seg[4])
+	count := int(seg[5])
+	if d.width == 0 || d.height == 0 {
+		return errors.New("invalid JPEG: zero image dimensions")
+	}
+	if d.width*d.height > maxDCTPixels {
+		return fmt.Errorf("JPEG too large for coefficient analysis (%dx%d)", d.width, d.height)
+	}
+	if count < 1 || count > 4 || len(seg) < 6+3*count {
+		return fmt.Errorf("invalid JPEG: bad component count %d", count)
+	}
+
+	d.progressive = progressive
+	d.maxH, d.maxV = 1, 1
+	for i := 0; i < count; i++ {
+		c := seg[6+3*i:]
+		info := ComponentInfo{
+			ID:              int(c[0]),
+			HSamplingFactor: int(c[1] >> 4),
+			VSamplingFactor: int(c[1] & 0x0F),
+			QuantTableID:    int(c[2] & 0x03),
+		}
+		if info.HSamplingFactor < 1 || info.HSamplingFactor > 4 || info.VSamplingFactor < 1 || info.VSamplingFactor > 4 {
+			return errors.New("invalid JPEG: bad sampling factors")
+		}
+		if info.HSamplingFactor > d.maxH {
+			d.maxH = info.HSamplingFactor
+		}
+		if info.VSamplingFactor > d.maxV {
+			d.maxV = info.VSamplingFactor
+		}
+		d.components = append(d.components, info)
+	}
+
+	d.mcusX = ceilDiv(d.width, 8*d.maxH)
+	d.mcusY = ceilDiv(d.height, 8*d.maxV)
+
+	for i := range d.components {
+		c := &d.components[i]
+		c.BlocksWide = ceilDiv(ceilDiv(d.width*c.HSamplingFactor, d.maxH), 8)
+		c.BlocksHigh = ceilDiv(ceilDiv(d.height*c.VSamplingFactor, d.maxV), 8)
+	}
+
+	d.frameSeen = true
+	return nil
+}
+
+// parseHuffmanTables reads one or more tables from a DHT segment
+func (d *dctDecoder) parseHuffmanTables(seg []byte) error {
+	for len(seg) > 0 {
+		if len(seg) < 17 {
+			return errors.New("invalid JPEG: short DHT segment")
+		}
+		class := seg[0] >> 4
+		id := seg[0] & 0x0F
+		if class > 1 || id > 3 {
+			return errors.New("invalid JPEG: bad Huffman table class or ID")
+		}
+
+		var counts [17]int
+		total := 0
+		for i := 1; i <= 16; i++ {
+			counts[i] = int(seg[i])
+			total += counts[i]
+		}
+		if total > 256 || len(seg) < 17+total {
+			return errors.New("invalid JPEG: bad Huffman table length")
+		}
+
+		t := &huffmanTable{values: append([]byte(nil), seg[17:17+total]...)}
+		code := int32(0)
+		k := int32(0)
+		for l := 1; l <= 16; l++ {
+			if counts[l] == 0 {
+				t.maxCode[l] = -1
+			} else {
+				t.valPtr[l] = k
+				t.minCode[l] = code
+				code += int32(counts[l])
+				k += int32(counts[l])
+				t.maxCode[l] = code - 1
+			}
+			code <<= 1
+		}
+
+		if class == 0 {
+			d.dcTables[id] = t
+		} else {
+			d.acTables[id] = t
+		}
+		seg = seg[17+total:]
+	}
+	return nil
+}
+
+// parseQuantTables reads one or more tables from a DQT segment
+func (d *dctDecoder) parseQuantTables(seg []byte) error {
+	for len(seg) > 0 {
+		precision := seg[0] >> 4
+		id := int(seg[0] & 0x0F)
+		if id > 3 {
+			return errors.New("invalid JPEG: bad quantization table ID")
+		}
+
+		var table [64]uint16
+		if precision == 0 {
+			if len(seg) < 65 {
+				return errors.New("invalid JPEG: short DQT segment")
+			}
+			for i := 0; i < 64; i++ {
+				table[i] = uint16(seg[1+i])
+			}
+			seg = seg[65:]
+		} else {
+			if len(seg) < 129 {
+				return errors.New("invalid JPEG: short DQT segment")
+			}
+			for i := 0; i < 64; i++ {
+				table[i] = uint16(seg[1+2*i])<<8 | uint16(seg[2+2*i])
+			}
+			seg = seg[129:]
+		}
+		d.quant[id] = table
+	}
+	return nil
+}
+
+// decodeScan decodes one entropy-coded scan described by a SOS header
+func (d *dctDecoder) decodeScan(header []byte, scan []byte) error {
+	if !d.frameSeen {
+		return errors.New("invalid JPEG: scan before frame header")
+	}
+	if len(header) < 1 {
+		return errors.New("invalid JPEG: short SOS header")
+	}
+	n := int(header[0])
+	if n < 1 || n > len(d.components) || len(header) < 1+2*n+3 {
+		return errors.New("invalid JPEG: bad SOS component count")
+	}
+
+	comps := make([]scanComponent, n)
+	for i := 0; i < n; i++ {
+		id := int(header[1+2*i])
+		index := -1
+		for j, c := range d.components {
+			if c.ID == id {
+				index = j
+				break
+			}
+		}
+		if index < 0 {
+			return fmt.Errorf("invalid JPEG: scan references unknown component %d", id)
+		}
+		comps[i] = scanComponent{
+			index: index,
+			dc:    int(header[2+2*i] >> 4 & 0x03),
+			ac:    int(header[2+2*i] & 0x03),
+		}
+	}
+
+	p := header[1+2*n:]
+	ss, se := int(p[0]), int(p[1])
+	ah, al := uint(p[2]>>4), uint(p[2]&0x0F)
+
+	if d.progressive {
+		if ss > se || se > 63 || (ss == 0 && se != 0) || (ss > 0 && n != 1) || al > 13 {
+			return errors.New("invalid JPEG: bad progressive scan parameters")
+		}
+	} else {
+		ss, se, ah, al = 0, 63, 0, 0
+	}
+
+	// Make sure every table this scan needs is present
+	for _, c := range comps {
+		if ss == 0 && ah == 0 && d.dcTables[c.dc] == nil {
+			return errors.New("invalid JPEG: missing DC Huffman table")
+		}
+		if se > 0 && d.acTables[c.ac] == nil {
+			return errors.New("invalid JPEG: missing AC Huffman table")
+		}
+	}
+
+	br := &bitReader{data: scan}
+	preds := make([]int32, len(d.components))
+	d.eobrun = 0
+	nextRST, found := 0, 0
+	ended := false // The scan ended before a restart marker; the rest of it is missing
+	defer func() {
+		d.restarts.Found += found
+		d.restarts.Stray += max(countRestartMarkers(scan)-found, 0)
+	}()
+
+	decodeBlock := func(c scanComponent, row, col int) error {
+		if ended {
+			return nil
+		}
+		block := d.block(c.index, row, col)
+		switch {
+		case !d.progressive:
+			return d.decodeBaseline(br, block, c, &preds[c.index])
+		case ss == 0 && ah == 0:
+			return d.decodeDCFirst(br, block, c, &preds[c.index], al)
+		case ss == 0:
+			return d.decodeDCRefine(br, block, al)
+		case ah == 0:
+			return d.decodeACFirst(br, block, c, ss, se, al)
+		default:
+			return d.decodeACRefine(br, block, c, ss, se, al)
+		}
+	}
+
+	restart := func(unit int) error {
+		if d.restartInterval == 0 || unit == 0 || unit%d.restartInterval != 0 {
+			return nil
+		}
+		d.restarts.Expected++
+		if ended {
+			d.restarts.Missing++
+			return nil
+		}
+		number, gap, ok := br.restart()
+		switch {
+		case !ok:
+			d.restarts.Missing++
+			ended = true
+		case number != nextRST:
+			// The bytes skipped belong to an interval whose marker is missing
+			found++
+			d.restarts.OutOfOrder++
+			nextRST = (number + 1) % 8
+		default:
+			found++
+			if len(d.restarts.Gaps)+len(gap) <= maxRestartGaps {
+				d.restarts.Gaps = append(d.restarts.Gaps, gap...)
+			}
+			nextRST = (nextRST + 1) % 8
+		}
+		for i := range preds {
+			preds[i] = 0
+		}
+		d.eobrun = 0
+		return nil
+	}
+
+	if n == 1 {
+		// Non-interleaved scan: one block per MCU, limited to the coded area
+		c := comps[0]
+		info := d.components[c.index]
+		unit := 0
+		for row := 0; row < info.BlocksHigh; row++ {
+			for col := 0; col < info.BlocksWide; col++ {
+				if err := restart(unit); err != nil {
+					return err
+				}
+				if err := decodeBlock(c, row, col); err != nil {
+					return err
+				}
+				unit++
+			}
+		}
+		return nil
+	}
+
+	// Interleaved scan: each MCU holds H x V blocks of every component
+	unit := 0
+	for my := 0; my < d.mcusY; my++ {
+		for mx := 0; mx < d.mcusX; mx++ {
+			if err := restart(unit); err != nil {
+				return err
+			}
+			for _, c := range comps {
+				info := d.components[c.index]
+				for v := 0; v < info.VSamplingFactor; v++ {
+					for h := 0; h < info.HSamplingFactor; h++ {
+						row := my*info.VSamplingFactor + v
+						col := mx*info.HSamplingFactor + h
+						if err := decodeBlock(c, row, col); err != nil {
+							return err
+						}
+					}
+				}
+			}
+			unit++
+		}
+	}
+	return nil
+}
+
+// decodeBaseline decodes a full sequential block
+func (d *dctDecoder) decodeBaseline(br *bitReader, block *[64]int16, c scanComponent, pred *int32) error {
+	if err := d.decodeDCFirst(br, block, c, pred, 0); err != nil {
+		return err
+	}
+
+	table := d.acTables[c.ac]
+	for k := 1; k < 64; {
+		rs, err := br.decode(table)
+		if err != nil {
+			return err
+		}
+		r, s := int(rs>>4), uint(rs&0x0F)
+		if s == 0 {
+			if r != 15 {
+				break // End of block
+			}
+			k += 16
+			continue
+		}
+		k += r
+		if k > 63 {
+			return errors.New("invalid JPEG: AC coefficient index out of range")
+		}
+		v, err := br.receiveExtend(s)
+		if err != nil {
+			return err
+		}
+		block[k] = int16(v)
+		k++
+	}
+	return nil
+}
+
+// decodeDCFirst decodes a DC coefficient difference (baseline or first progressive pass)
+func (d *dctDecoder) decodeDCFirst(br *bitReader, block *[64]int16, c scanComponent, pred *int32, al uint) error {
+	t, err := br.decode(d.dcTables[c.dc])
+	if err != nil {
+		return err
+	}
+	if t > 16 {
+		return errors.New("invalid JPEG: bad DC coefficient size")
+	}
+	diff, err := br.receiveExtend(uint(t))
+	if err != nil {
+		return err
+	}
+	*pred += diff
+	block[0] = int16(*pred << al)
+	return nil
+}
+
+// decodeDCRefine adds one refinement bit to a DC coefficient
+func (d *dctDecoder) decodeDCRefine(br *bitReader, block *[64]int16, al uint) error {
+	bit, err := br.readBit()
+	if err != nil {
+		return err
+	}
+	if bit != 0 {
+		block[0] |= 1 << al
+	}
+	return nil
+}
+
+// decodeACFirst decodes the first pass of a spectral band in a progressive scan
+func (d *dctDecoder) decodeACFirst(br *bitReader, block *[64]int16, c scanComponent, ss, se int, al uint) error {
+	if d.eobrun > 0 {
+		d.eobrun--
+		return nil
+	}
+
+	table := d.acTables[c.ac]
+	for k := ss; k <= se; {
+		rs, err := br.decode(table)
+		if err != nil {
+			return err
+		}
+		r, s := int(rs>>4), uint(rs&0x0F)
+		if s == 0 {
+			if r < 15 {
+				d.eobrun = 1<<uint(r) - 1
+				if r > 0 {
+					extra, err := br.receive(uint(r))
+					if err != nil {
+						return err
+					}
+					d.eobrun += int(extra)
+				}
+				break
+			}
+			k += 16
+			continue
+		}
+		k += r
+		if k > se {
+			return errors.New("invalid JPEG: AC coefficient index out of range")
+		}
+		v, err := br.receiveExtend(s)
+		if err != nil {
+			return err
+		}
+		block[k] = int16(v << al)
+		k++
+	}
+	return nil
+}
+
+// decodeACRefine adds refinement bits to a spectral band in a progressive scan
+func (d *dctDecoder) decodeACRefine(br *bitReader, block *[64]int16, c scanComponent, ss, se int, al uint) error {
+	p1 := int16(1) << al
+	m1 := int16(-1) << al
+
+	refine := func(k int) error {
+		bit, err := br.readBit()
+		if err != nil {
+			return err
+		}
+		if bit != 0 && block[k]&p1 == 0 {
+			if block[k] >= 0 {
+				block[k] += p1
+			} else {
+				block[k] += m1
+			}
+		}
+		return nil
+	}
+
+	k := ss
+	if d.eobrun == 0 {
+		table := d.acTables[c.ac]
+		for ; k <= se; k++ {
+			rs, err := br.decode(table)
+			if err != nil {
+				return err
+			}
+			r, s := int(rs>>4), int(rs&0x0F)
+
+			var val int16
+			if s != 0 {
+				if s != 1 {
+					return errors.New("invalid JPEG: bad AC refinement coefficient size")
+				}
+				bit, err := br.readBit()
+				if err != nil {
+					return err
+				}
+				if bit != 0 {
+					val = p1
+				} else {
+					val = m1
+				}
+			} else if r != 15 {
+				d.eobrun = 1 << uint(r)
+				if r > 0 {
+					extra, err := br.receive(uint(r))
+					if err != nil {
+						return err
+					}
+					d.eobrun += int(extra)
+				}
+				break
+			}
+
+			// Skip r zero-valued coefficients, refining non-zero ones on the way
+			for ; k <= se; k++ {
+				if block[k] != 0 {
+					if err := refine(k); err != nil {
+						return err
+					}
+				} else {
+					if r == 0 {
+						break
+					}
+					r--
+				}
+			}
+
+			if val != 0 && k <= se {
+				block[k] = val
+			}
+		}
+	}
+
+	if d.eobrun > 0 {
+		for ; k <= se; k++ {
+			if block[k] != 0 {
+				if err := refine(k); err != nil {
+					return err
+				}
+			}
+		}
+		d.eobrun--
+	}
+	return nil
+}
+
+// result converts the decoder state into the exported representation
+func (d *dctDecoder) result() *JPEGDCTData {
+	return &JPEGDCTData{
+		Width:           d.width,
+		Height:          d.height,
+		Progressive:     d.progressive,
+		RestartInterval: d.restartInterval,
+		Restarts:        d.restarts,
+		Components:      d.components,
+		ColorModel:      colorModelOf(len(d.components), d.adobeTransform),
+		QuantTables:     d.quant,
+		Blocks:          d.blocks,
+	}
+}
+
+// bitReader reads bits from an entropy-coded segment, removing byte stuffing
+type bitReader struct {
+	data   []byte
+	pos    int
+	acc    uint32
+	nbits  uint
+	marker bool // A marker or the end of the data was reached
+}
+
+// fill ensures at least n bits (n <= 24) are buffered. The decoder reads no
+// further ahead than the bits it needs, so running into a marker or the end of
+// the data means the scan is cut short.
+func (br *bitReader) fill(n uint) error {
+	for br.nbits < n {
+		if br.marker || br.pos >= len(br.data) {
+			br.marker = true
+			return errScanExhausted
+		}
+		b := br.data[br.pos]
+		if b == 0xFF {
+			if br.pos+1 >= len(br.data) || br.data[br.pos+1] != 0x00 {
+				br.marker = true
+				return errScanExhausted
+			}
+			br.pos += 2
+		} else {
+			br.pos++
+		}
+		br.acc = br.acc<<8 | uint32(b)
+		br.nbits += 8
+	}
+	return nil
+}
+
+// readBit returns the next bit of the stream
+func (br *bitReader) readBit() (int, error) {
+	v, err := br.receive(1)
+	return int(v), err
+}
+
+// receive reads n raw bits (n <= 16)
+func (br *bitReader) receive(n uint) (int32, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	if err := br.fill(n); err != nil {
+		return 0, err
+	}
+	br.nbits -= n
+	return int32(br.acc>>br.nbits) & (1<<n - 1), nil
+}
+
+// receiveExtend reads an n-bit magnitude and sign-extends it (JPEG spec F.2.2.1)
+func (br *bitReader) receiveExtend(n uint) (int32, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	if n > 16 {
+		return 0, errors.New("invalid JPEG: coefficient size out of range")
+	}
+	v, err := br.receive(n)
+	if err != nil {
+		return 0, err
+	}
+	if v < 1<<(n-1) {
+		v += -1<<n + 1
+	}
+	return v, nil
+}
+
+// decode reads one Huffman-coded symbol
+func (br *bitReader) decode(t *huffmanTable) (byte, error) {
+	if t == nil {
+		return 0, errors.New("invalid JPEG: missing Huffman table")
+	}
+	code := int32(0)
+	for l := 1; l <= 16; l++ {
+		bit, err := br.receive(1)
+		if err != nil {
+			return 0, err
+		}
+		code = code<<1 | bit
+		if t.maxCode[l] >= 0 && code <= t.maxCode[l] {
+			idx := t.valPtr[l] + code - t.minCode[l]
+			if idx < 0 || int(idx) >= len(t.values) {
+				return 0, errors.New("invalid JPEG: corrupt Huffman data")
+			}
+			return t.values[idx], nil
+		}
+	}
+	return 0, errors.New("invalid JPEG: bad Huffman code")
+}
+
+// restart discards buffered bits and skips the next RSTn marker. It returns the
+// marker's number and the bytes in front of it other than fill bytes, or ok
+// false when the scan ends first.
+func (br *bitReader) restart() (number int, gap []byte, ok bool) {
+	br.acc, br.nbits = 0, 0
+	br.marker = false
+
+	// Find the restart marker, tolerating garbage in front of it
+	for br.pos < len(br.data)-1 {
+		if br.data[br.pos] == 0xFF {
+			next := br.data[br.pos+1]
+			if next >= markerRST0 && next <= markerRST7 {
+				br.pos += 2
+				return int(next - markerRST0), gap, true
+			}
+			if next == 0xFF {
+				br.pos++
+				continue
+			}
+		}
+		gap = append(gap, br.data[br.pos])
+		br.pos++
+	}
+	br.marker = true
+	return 0, nil, false
+}
+
+// countRestartMarkers returns the number of RSTn markers in an entropy-coded segment
+func countRestartMarkers(scan []byte) int {
+	count := 0
+	for i := 0; i+1 < len(scan); i++ {
+		if scan[i] == 0xFF && scan[i+1] >= markerRST0 && scan[i+1] <= markerRST7 {
+			count++
+		}
+	}
+	return count
+}
+
+// ceilDiv returns a/b rounded up
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
+
+// GetStegoCoefficientCount returns the number of AC coefficients other than 0
+// and 1, which are the ones JSteg-style embedders can change. It is the JSteg
+// capacity in bits.
+func (d *JPEGDCTData) GetStegoCoefficientCount() int {
+	count := 0
+	for i := range d.Blocks {
+		for _, v := range d.Blocks[i].Coefficients[1:] {
+			if _, ok := JStegBit(v); ok {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// JStegBit returns the bit JSteg stores in a quantized coefficient, and false
+// for 0 and 1, which JSteg skips since changing them would add or remove a zero
+// and change the run lengths. The bit is the LSB of the coefficient's two's
+// complement value, as JSteg sets it on the coefficient before Huffman coding,
+// so -1 holds 1 and -2 holds 0, the parity of the magnitude. It is not the last
+// of the extra bits the Huffman coder writes, which for a negative value hold
+// the value minus one and so have the opposite LSB.
+func JStegBit(v int16) (byte, bool) {
+	if v == 0 || v == 1 {
+		return 0, false
+	}
+	return byte(v & 1), true
+}
@@ -0,0 +1,148 @@
+package jpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"math"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+)
+
+func TestSubsampledBlocks(t *testing.T) {
+	clean, err := fixtures.Load("clean.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	odd, err := fixtures.EncodeJPEG(fixtures.Carrier(100, 75, 7), 75)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		blocks [][2]int // Block columns and rows of each component
+		mcus   [2]int   // MCU columns and rows
+	}{
+		{"clean.jpg", clean, [][2]int{{16, 16}, {8, 8}, {8, 8}}, [2]int{8, 8}},
+		// 13x10 luminance blocks fill 7x5 MCUs of 2x2 blocks only partly
+		{"odd size", odd, [][2]int{{13, 10}, {7, 5}, {7, 5}}, [2]int{7, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dct, err := ParseJPEGDCTCoefficients(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(dct.Components) != len(tt.blocks) {
+				t.Fatalf("%d components, want %d", len(dct.Components), len(tt.blocks))
+			}
+			if c := dct.Components[0]; c.HSamplingFactor != 2 || c.VSamplingFactor != 2 {
+				t.Fatalf("luminance sampled %dx%d, want 2x2 (4:2:0)", c.HSamplingFactor, c.VSamplingFactor)
+			}
+			total := 0
+			for i, c := range dct.Components {
+				if c.BlocksWide != tt.blocks[i][0] || c.BlocksHigh != tt.blocks[i][1] {
+					t.Errorf("component %d has %dx%d blocks, want %dx%d", i, c.BlocksWide, c.BlocksHigh, tt.blocks[i][0], tt.blocks[i][1])
+				}
+				total += tt.blocks[i][0] * tt.blocks[i][1]
+			}
+			if len(dct.Blocks) != total {
+				t.Errorf("%d blocks, want %d", len(dct.Blocks), total)
+			}
+			if x, y := dct.MCUs(); x != tt.mcus[0] || y != tt.mcus[1] {
+				t.Errorf("%dx%d MCUs, want %dx%d", x, y, tt.mcus[0], tt.mcus[1])
+			}
+
+			// Every order visits each block of the components it includes once
+			for _, order := range BlockOrders {
+				seen := map[*DCTCoefficientBlock]bool{}
+				for _, block := range dct.OrderedBlocks(order) {
+					seen[block] = true
+				}
+				want := total
+				switch order {
+				case OrderLuminance:
+					want = tt.blocks[0][0] * tt.blocks[0][1]
+				case OrderChrominance:
+					want = total - tt.blocks[0][0]*tt.blocks[0][1]
+				}
+				if len(seen) != want {
+					t.Errorf("%s order visits %d blocks, want %d", order, len(seen), want)
+				}
+			}
+
+			// The DC coefficient of each block is the mean of the samples it
+			// covers in the decoded plane of its component. The decoder keeps
+			// the planes padded to whole MCUs, so edge blocks are covered too.
+			img, err := jpeg.Decode(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ycbcr := img.(*image.YCbCr)
+			planes := []struct {
+				pix    []byte
+				stride int
+			}{{ycbcr.Y, ycbcr.YStride}, {ycbcr.Cb, ycbcr.CStride}, {ycbcr.Cr, ycbcr.CStride}}
+			for _, block := range dct.Blocks {
+				plane := planes[block.Component]
+				c := dct.Components[block.Component]
+				sum := 0
+				for y := 0; y < 8; y++ {
+					for x := 0; x < 8; x++ {
+						sum += int(plane.pix[(block.Row*8+y)*plane.stride+block.Col*8+x])
+					}
+				}
+				q := float64(dct.QuantTables[c.QuantTableID][0])
+				dc := float64(block.Coefficients[0])*q/8 + 128
+				if mean := float64(sum) / 64; math.Abs(mean-dc) > q/8+2 {
+					t.Fatalf("component %d block (%d, %d) has DC %.1f, its samples average %.1f", block.Component, block.Row, block.Col, dc, mean)
+				}
+			}
+		})
+	}
+}
+
+func TestTruncatedScan(t *testing.T) {
+	clean, err := fixtures.Load("clean.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sos := bytes.Index(clean, []byte{0xFF, markerSOS})
+	if sos < 0 {
+		t.Fatal("no scan in clean.jpg")
+	}
+	start := sos + 2 + int(binary.BigEndian.Uint16(clean[sos+2:]))
+	truncated := clean[:start+(len(clean)-start)/2]
+
+	if _, err := ParseJPEGDCTCoefficients(truncated); !errors.Is(err, errScanExhausted) {
+		t.Errorf("truncated scan gave error %v, want %v", err, errScanExhausted)
+	}
+}
+
+func TestOversizedFrame(t *testing.T) {
+	clean, err := fixtures.Load("clean.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sof := bytes.Index(clean, []byte{0xFF, markerSOF0})
+	if sof < 0 {
+		t.Fatal("no baseline frame header in clean.jpg")
+	}
+
+	// 7000x7000 is under the pixel cap, but a scan of a few kilobytes cannot
+	// code its million blocks
+	data := append([]byte(nil), clean...)
+	binary.BigEndian.PutUint16(data[sof+5:], 7000)
+	binary.BigEndian.PutUint16(data[sof+7:], 7000)
+
+	_, err = ParseJPEGDCTCoefficients(data)
+	if err == nil || !strings.Contains(err.Error(), "cannot hold") {
+		t.Errorf("oversized frame gave error %v, want the scan data to be too short", err)
+	}
+}
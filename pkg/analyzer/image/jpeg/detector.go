@@ -0,0 +1,191 @@
+package jpeg
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// StegoDetector is implemented by every coefficient-level JPEG steganography detector
+type StegoDetector interface {
+	// Name returns the algorithm the detector looks for
+	Name() string
+
+	// Detect returns the probability (0.0-1.0) that the algorithm was used and a
+	// human-readable explanation of the statistic behind it
+	Detect(dct *JPEGDCTData) (probability float64, details string)
+}
+
+// DefaultDetectors returns the detectors run by the JPEG analyzer. The decoded
+// image is needed by detectors that rely on calibration and may be nil.
+func DefaultDetectors(img image.Image) []StegoDetector {
+	return []StegoDetector{
+		&JStegDetector{},
+		&F5Detector{Image: img},
+		&OutGuessDetector{},
+		&StegHideDetector{Image: img},
+		&JPHideDetector{},
+	}
+}
+
+// detectorRecommendations holds the follow-up advice for each detector
+var detectorRecommendations = map[string]string{
+	"JSteg":    "Extract the LSBs of DCT coefficients not equal to 0 or 1 in scan order (jsteg reveal)",
+	"F5":       "Try extracting with an F5 implementation (a password may be required)",
+	"OutGuess": "Try extracting with outguess (a password may be required)",
+	"StegHide": "Try extracting with steghide (a passphrase may be required)",
+	"JPHide":   "Try extracting with jpseek (JPHide/JPSeek)",
+}
+
+// JStegDetector detects sequential LSB replacement in DCT coefficients
+type JStegDetector struct{}
+
+// Name returns the detector name
+func (d *JStegDetector) Name() string { return "JSteg" }
+
+// Detect runs the sequential pair-of-values test
+func (d *JStegDetector) Detect(dct *JPEGDCTData) (float64, string) {
+	return detectJSteg(dct)
+}
+
+// F5Detector detects F5 coefficient shrinkage using calibration
+type F5Detector struct {
+	Image image.Image // Decoded pixels used for calibration
+}
+
+// Name returns the detector name
+func (d *F5Detector) Name() string { return "F5" }
+
+// Detect estimates the F5 modification rate
+func (d *F5Detector) Detect(dct *JPEGDCTData) (float64, string) {
+	return detectF5(dct, d.Image)
+}
+
+// OutGuessDetector detects pair equalization across the whole histogram
+type OutGuessDetector struct{}
+
+// Name returns the detector name
+func (d *OutGuessDetector) Name() string { return "OutGuess" }
+
+// Detect runs the histogram step and chi-square tests
+func (d *OutGuessDetector) Detect(dct *JPEGDCTData) (float64, string) {
+	return detectOutGuess(dct)
+}
+
+// StegHideDetector looks for histogram-preserving changes that only show up
+// against a calibrated cover estimate
+type StegHideDetector struct {
+	Image image.Image // Decoded pixels used for calibration
+}
+
+// Name returns the detector name
+func (d *StegHideDetector) Name() string { return "StegHide" }
+
+// Detect compares the per-mode histograms against the calibrated estimate
+func (d *StegHideDetector) Detect(dct *JPEGDCTData) (float64, string) {
+	return detectStegHide(dct, d.Image)
+}
+
+// JPHideDetector detects low-frequency-first LSB replacement
+type JPHideDetector struct{}
+
+// Name returns the detector name
+func (d *JPHideDetector) Name() string { return "JPHide" }
+
+// Detect compares low- and high-frequency pair statistics
+func (d *JPHideDetector) Detect(dct *JPEGDCTData) (float64, string) {
+	return detectJPHide(dct)
+}
+
+// jstegPrefixSteps is the number of image prefixes tested by the JSteg detector
+const jstegPrefixSteps = 10
+
+// detectJSteg estimates the probability that JSteg embedded data sequentially.
+//
+// JSteg replaces coefficient LSBs in scan order and stops when the message ends,
+// so the pair-of-values test passes on the leading part of the image and fails
+// once the unmodified remainder is included. The test is repeated on growing
+// prefixes (by block row) and the longest equalized prefix is reported.
+func detectJSteg(dct *JPEGDCTData) (float64, string) {
+	if dct == nil || len(dct.Blocks) == 0 {
+		return 0, "no DCT coefficients available"
+	}
+
+	best, bestFraction := 0.0, 0.0
+	for step := 1; step <= jstegPrefixSteps; step++ {
+		fraction := float64(step) / jstegPrefixSteps
+		hist := make(map[int]int)
+		for i := range dct.Blocks {
+			block := &dct.Blocks[i]
+			rows := dct.Components[block.Component].BlocksHigh
+			if float64(block.Row) >= fraction*float64(rows) {
+				continue
+			}
+			for k := 1; k < 64; k++ {
+				hist[int(block.Coefficients[k])]++
+			}
+		}
+
+		p, dof := pairChiSquare(hist)
+		if dof == 0 {
+			continue
+		}
+		if p > 0.5 {
+			bestFraction = fraction
+		}
+		if p > best {
+			best = p
+		}
+	}
+
+	if bestFraction == 0 {
+		return best, fmt.Sprintf("no equalized prefix found (best pair p=%.3f)", best)
+	}
+	return best, fmt.Sprintf("coefficient pairs equalized over the first %.0f%% of the image (best pair p=%.3f)",
+		bestFraction*100, best)
+}
+
+// detectStegHide estimates the probability of StegHide-style embedding.
+//
+// StegHide exchanges coefficient values so that the global histogram is kept,
+// which defeats first-order tests. The per-mode histograms of the low
+// frequencies still drift away from the calibrated cover estimate, so their
+// average L1 distance is used as a (weak) indicator.
+func detectStegHide(dct *JPEGDCTData, img image.Image) (float64, string) {
+	if dct == nil || img == nil || len(dct.Components) == 0 {
+		return 0, "StegHide calibration needs both coefficients and decoded pixels"
+	}
+
+	quant, ok := dct.QuantTables[dct.Components[0].QuantTableID]
+	if !ok {
+		return 0, "missing luminance quantization table"
+	}
+
+	stego := analyzeHistogram(dct, 0)
+	calibrated := calibratedHistogram(img, quant, dct.ColorModel)
+	if calibrated == nil {
+		return 0, "image too small for calibration"
+	}
+
+	const modes = 15
+	distance := 0.0
+	for k := 1; k <= modes; k++ {
+		stegoTotal, calTotal := 0.0, 0.0
+		for _, n := range stego.modes[k] {
+			stegoTotal += float64(n)
+		}
+		for _, n := range calibrated.modes[k] {
+			calTotal += float64(n)
+		}
+		if stegoTotal == 0 || calTotal == 0 {
+			continue
+		}
+		for v := -5; v <= 5; v++ {
+			distance += math.Abs(float64(stego.modes[k][v])/stegoTotal - float64(calibrated.modes[k][v])/calTotal)
+		}
+	}
+	distance /= modes
+
+	probability := clamp01((distance - 0.12) / 0.18)
+	return probability, fmt.Sprintf("mean calibrated histogram distance=%.3f over %d low-frequency modes", distance, modes)
+}
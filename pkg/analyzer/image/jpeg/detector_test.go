@@ -0,0 +1,82 @@
+package jpeg
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// replaceLSBTopHalf equalizes the pairs of the blocks in the top half of each
+// component only, like JSteg embedding a message that ends halfway
+func replaceLSBTopHalf(block *DCTCoefficientBlock, k int, rng *rand.Rand) {
+	if block.Row < 8 {
+		replaceLSB(block, k, rng)
+	}
+}
+
+// replaceLSBLowModes equalizes the pairs of the lowest frequencies of every
+// block only, which JPHide fills first
+func replaceLSBLowModes(block *DCTCoefficientBlock, k int, rng *rand.Rand) {
+	if k <= jphideLowModes {
+		replaceLSB(block, k, rng)
+	}
+}
+
+// exchangeValues swaps half of the non-zero coefficients of a block with
+// coefficients elsewhere in it, which keeps the histogram the way StegHide's
+// exchanges do but moves values between frequencies
+func exchangeValues(block *DCTCoefficientBlock, k int, rng *rand.Rand) {
+	if block.Coefficients[k] == 0 || rng.Intn(2) == 0 {
+		return
+	}
+	other := 1 + rng.Intn(63)
+	block.Coefficients[k], block.Coefficients[other] = block.Coefficients[other], block.Coefficients[k]
+}
+
+func TestDefaultDetectors(t *testing.T) {
+	// clean.jpg is 128x128, so its luminance has 16 block rows and its
+	// chrominance 8
+	img := decodeFixture(t, "clean.jpg")
+	tests := []struct {
+		detector string
+		change   coefficientChange
+	}{
+		{"JSteg", replaceLSBTopHalf},
+		{"F5", shrinkF5},
+		{"OutGuess", replaceLSB},
+		{"StegHide", exchangeValues},
+		{"JPHide", replaceLSBLowModes},
+	}
+
+	detectors := DefaultDetectors(img)
+	if len(detectors) != len(tests) {
+		t.Fatalf("%d default detectors, want %d", len(detectors), len(tests))
+	}
+	clean := loadCoefficients(t, "clean.jpg", nil)
+	for _, d := range detectors {
+		if probability, details := d.Detect(clean); probability > 0.5 {
+			t.Errorf("%s scores the clean image %.2f (%s)", d.Name(), probability, details)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.detector, func(t *testing.T) {
+			var detector StegoDetector
+			for _, d := range detectors {
+				if d.Name() == tt.detector {
+					detector = d
+				}
+			}
+			if detector == nil {
+				t.Fatalf("no %s detector among the defaults", tt.detector)
+			}
+			if _, ok := detectorRecommendations[tt.detector]; !ok {
+				t.Errorf("no recommendation for %s", tt.detector)
+			}
+
+			dct := loadCoefficients(t, "clean.jpg", tt.change)
+			if probability, details := detector.Detect(dct); probability <= 0.5 {
+				t.Errorf("probability %.2f (%s), want above 0.5", probability, details)
+			}
+		})
+	}
+}
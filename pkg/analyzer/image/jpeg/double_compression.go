@@ -0,0 +1,178 @@
+package jpeg
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the double-compression detector. A JPEG that is decoded,
+changed and saved again is quantized twice, and the histograms of its DCT
+coefficients show it: with a coarser first step, the values the second step
+cannot reach from the first one stay empty, and with a finer first step some
+values collect the counts of two. Either way the histogram of each frequency
+gets a periodic comb of gaps and peaks on top of the smooth, Laplacian-like
+fall-off of a single compression.
+
+The detector measures the comb as the curvature of each histogram in the log
+domain: the difference between the log count of each value and the mean of its
+neighbors' log counts. A smooth fall-off has a constant curvature, zero for a
+Laplacian and negative for a Gaussian, so the curvature is compared against its
+own mean per frequency and only the alternation around it counts. Statistical
+noise and a small tolerance for shapes that are not quite smooth are allowed
+for, which keeps the statistic near 1 for single compression whatever the
+number of blocks.
+
+Recompression is also what every editor and upload pipeline does to a JPEG, so
+the finding alone is weak evidence. Its probability is recorded but not pooled
+into the ensemble with the embedding detectors.
+*/
+
+// Double-compression detector parameters
+const (
+	doubleCompressionModes     = 9    // Leading zig-zag AC modes examined, the ones with the most non-zero values
+	doubleCompressionMaxValue  = 40   // Largest coefficient magnitude examined
+	doubleCompressionMinCounts = 30   // Counts three neighboring values need before the middle one is judged
+	doubleCompressionMinBins   = 3    // Values a mode needs to be judged
+	doubleCompressionMinModes  = 3    // Judged modes an image needs to be judged
+	doubleCompressionTolerance = 0.1  // Log-domain deviation from a smooth shape that is not counted
+	doubleCompressionMidpoint  = 3.0  // Statistic at which the probability is 0.5
+	doubleCompressionSlope     = 3.0  // Steepness of the probability around the midpoint
+	doubleCompressionWeight    = 0.5  // Share of its probability the finding is reported with
+	doubleCompressionGapSigma  = -2.0 // Curvature, in standard deviations, below which a value counts as a gap
+)
+
+// DoubleCompression is the result of the double-compression detector
+type DoubleCompression struct {
+	Probability float64 // 0-1 probability that the image was compressed twice
+	Statistic   float64 // Curvature deviation per degree of freedom, about 1 for a single compression
+	Modes       int     // Modes judged
+	Mode        int     // Zig-zag position of the mode with the strongest comb, 0 when none was judged
+	Gaps        []int   // Coefficient magnitudes left thin in that mode
+}
+
+// DetectDoubleCompression looks for the comb double quantization leaves in the
+// histograms of the first component's low-frequency coefficients
+func DetectDoubleCompression(dct *JPEGDCTData) DoubleCompression {
+	var result DoubleCompression
+	if dct == nil {
+		return result
+	}
+
+	var counts [doubleCompressionModes + 1][doubleCompressionMaxValue + 2]float64
+	for i := range dct.Blocks {
+		block := &dct.Blocks[i]
+		if block.Component != 0 {
+			continue
+		}
+		for k := 1; k <= doubleCompressionModes; k++ {
+			v := int(block.Coefficients[k])
+			if v < 0 {
+				v = -v
+			}
+			if v <= doubleCompressionMaxValue+1 {
+				counts[k][v]++
+			}
+		}
+	}
+
+	total, dof := 0.0, 0
+	strongest := 0.0
+	for k := 1; k <= doubleCompressionModes; k++ {
+		statistic, n, gaps := histogramComb(counts[k][:])
+		if n == 0 {
+			continue
+		}
+		result.Modes++
+		total += statistic
+		dof += n
+		if statistic/float64(n) > strongest {
+			strongest = statistic / float64(n)
+			result.Mode, result.Gaps = k, gaps
+		}
+	}
+	if result.Modes < doubleCompressionMinModes {
+		result.Mode, result.Gaps = 0, nil
+		return result
+	}
+
+	result.Statistic = total / float64(dof)
+	result.Probability = 1 / (1 + math.Pow(doubleCompressionMidpoint/max(result.Statistic, 1e-9), doubleCompressionSlope))
+	return result
+}
+
+// histogramComb returns the weighted sum of squared deviations of a histogram's
+// log-domain curvature from its mean, its degrees of freedom, 0 when the
+// histogram has too few values, and the values that are gaps. Magnitude 0 and
+// 1 are left out, since the dead zone around zero bends every histogram there.
+func histogramComb(counts []float64) (float64, int, []int) {
+	var curvature, weights []float64
+	var values []int
+	for v := 2; v+1 < len(counts); v++ {
+		a, b, c := counts[v-1]+0.5, counts[v]+0.5, counts[v+1]+0.5
+		if a+b+c < doubleCompressionMinCounts {
+			break
+		}
+		variance := 1/b + (1/a+1/c)/4 + doubleCompressionTolerance*doubleCompressionTolerance
+		curvature = append(curvature, math.Log(b)-(math.Log(a)+math.Log(c))/2)
+		weights = append(weights, 1/variance)
+		values = append(values, v)
+	}
+	if len(curvature) < doubleCompressionMinBins {
+		return 0, 0, nil
+	}
+
+	mean, sum := 0.0, 0.0
+	for i, e := range curvature {
+		mean += e * weights[i]
+		sum += weights[i]
+	}
+	mean /= sum
+
+	statistic := 0.0
+	var gaps []int
+	for i, e := range curvature {
+		z := (e - mean) * math.Sqrt(weights[i])
+		statistic += z * z
+		if z < doubleCompressionGapSigma {
+			gaps = append(gaps, values[i])
+		}
+	}
+	return statistic, len(curvature) - 1, gaps
+}
+
+// analyzeDoubleCompression records the double-compression probability and
+// reports an image that was compressed twice
+func analyzeDoubleCompression(dct *JPEGDCTData, threshold float64, result *models.AnalysisResult) {
+	double := DetectDoubleCompression(dct)
+	if double.Modes < doubleCompressionMinModes {
+		return
+	}
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	result.Details["double_compression_probability"] = double.Probability
+	if double.Probability <= threshold {
+		result.AddCheck(fmt.Sprintf("coefficient histograms of a single compression (%.2f)", double.Probability))
+		return
+	}
+
+	gaps := make([]string, len(double.Gaps))
+	for i, v := range double.Gaps {
+		gaps[i] = fmt.Sprint(v)
+	}
+	details := fmt.Sprintf("The histograms of %d low-frequency modes alternate %.1f times as much as a single compression leaves them",
+		double.Modes, double.Statistic)
+	if len(gaps) > 0 {
+		details += fmt.Sprintf("; mode %d has thin values at |v| = %s", double.Mode, strings.Join(gaps, ", "))
+	}
+	confidence := double.Probability * doubleCompressionWeight
+	result.AddFinding("JPEG was compressed twice (double quantization)", confidence,
+		details+". Data hidden in the pixels of a decoded JPEG that was saved again leaves this, as does ordinary re-editing.")
+	if result.DetectionScore < confidence {
+		result.DetectionScore = confidence
+	}
+}
@@ -0,0 +1,47 @@
+package jpeg
+
+import (
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/models"
+)
+
+func TestDetectDoubleCompression(t *testing.T) {
+	tests := []struct {
+		fixture string
+		double  bool // Whether the image is reported as compressed twice
+	}{
+		{"textured.jpg", false},
+		{"recompressed_lsb.jpg", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			data, err := fixtures.Load(tt.fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dct, err := ParseJPEGDCTCoefficients(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			double := DetectDoubleCompression(dct)
+			if double.Modes < doubleCompressionMinModes {
+				t.Fatalf("judged %d modes, want at least %d", double.Modes, doubleCompressionMinModes)
+			}
+			if got := double.Probability > 0.5; got != tt.double {
+				t.Errorf("probability %.2f (statistic %.1f), want compressed twice = %v", double.Probability, double.Statistic, tt.double)
+			}
+
+			result := &models.AnalysisResult{}
+			analyzeDoubleCompression(dct, 0.5, result)
+			if got := len(result.Findings) == 1; got != tt.double {
+				t.Errorf("findings %v, want compressed twice = %v", result.Findings, tt.double)
+			}
+			if _, ok := result.Details["double_compression_probability"]; !ok {
+				t.Error("probability not recorded in the details")
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package jpeg
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"DeSteGo/pkg/analyzer/carve"
+	"DeSteGo/pkg/analyzer/stats"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the check for spoofed EOI markers. The image ends at the EOI
+the marker walk reaches, but tools that look for the first or the last FF D9
+in the file can be misled by extra ones: a second EOI at the end of the file
+hides the data between the two from tools that take the last FF D9 for the end
+of the image, an EOI inside a comment stops tools that take the first one in
+the header, and an EOI inserted into the scan data cuts the image short so that
+the rest of its scan reads as appended data. EOI markers that end a JPEG stream
+appended after the image, such as the pictures of a Multi-Picture Format file,
+belong to that stream and are not counted.
+*/
+
+// scanDataMinEntropy is the entropy in bits per byte above which the bytes
+// between two EOI markers are taken for the rest of a scan
+const scanDataMinEntropy = 7.0
+
+// scanDataMinSize is the size below which the entropy of those bytes is not
+// judged
+const scanDataMinSize = 64
+
+// EOIMarkers lists the FF D9 byte pairs of a JPEG file other than the EOI
+// that ends the image
+type EOIMarkers struct {
+	End        int   // Offset just past the EOI that ends the image, 0 when the walk did not reach one
+	InComments []int // Offsets of the pairs inside COM segments
+	After      []int // Offsets of the pairs after the end of the image, outside appended JPEG streams
+	ScanSplit  bool  // The bytes up to the first pair after the end are entropy-coded scan data
+}
+
+// FindEOIMarkers walks a JPEG file to the EOI that ends the image and returns
+// the FF D9 byte pairs elsewhere in it
+func FindEOIMarkers(data []byte) EOIMarkers {
+	var markers EOIMarkers
+	end, err := WalkImage(data, func(seg Segment) bool {
+		if seg.Marker == markerCOM {
+			markers.InComments = append(markers.InComments, eoiOffsets(seg.Payload, seg.Offset+4)...)
+		}
+		return true
+	})
+	if err != nil || end == 0 {
+		return markers
+	}
+	markers.End = end
+
+	for pos := end; pos+1 < len(data); pos++ {
+		if data[pos] != 0xFF {
+			continue
+		}
+		if data[pos+1] == markerSOI {
+			if streamEnd := carve.ImageEnd(data[pos:]); streamEnd > 0 {
+				pos += streamEnd - 1
+				continue
+			}
+		}
+		if data[pos+1] == markerEOI {
+			markers.After = append(markers.After, pos)
+		}
+	}
+
+	if len(markers.After) > 0 {
+		first := markers.After[0]
+		between := data[end:first]
+		markers.ScanSplit = FindScanEnd(data, end) == first && len(between) >= scanDataMinSize &&
+			stats.ComputeEntropy(between) >= scanDataMinEntropy
+	}
+	return markers
+}
+
+// eoiOffsets returns the offsets of the FF D9 pairs in data, which starts at
+// base in the file
+func eoiOffsets(data []byte, base int) []int {
+	var offsets []int
+	for i := 0; ; {
+		j := bytes.Index(data[i:], []byte{0xFF, markerEOI})
+		if j < 0 {
+			return offsets
+		}
+		offsets = append(offsets, base+i+j)
+		i += j + 2
+	}
+}
+
+// analyzeEOIMarkers reports EOI markers that can be taken for the end of the
+// image in place of the one that ends it
+func analyzeEOIMarkers(data []byte, result *models.AnalysisResult) {
+	markers := FindEOIMarkers(data)
+	if markers.End == 0 {
+		return
+	}
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	result.Details["eoi_markers"] = 1 + len(markers.InComments) + len(markers.After)
+
+	found := false
+	if len(markers.After) > 0 {
+		found = true
+		last := markers.After[len(markers.After)-1]
+		if markers.ScanSplit {
+			result.AddFinding("EOI marker inside the scan data", 0.85,
+				fmt.Sprintf("The EOI at offset %d is followed by %d bytes of entropy-coded data up to another EOI at offset %d: the image was cut short and the rest of its scan reads as appended data",
+					markers.End-2, markers.After[0]-markers.End, markers.After[0]))
+			if result.DetectionScore < 0.85 {
+				result.DetectionScore = 0.85
+			}
+		} else {
+			result.AddFinding("Multiple EOI markers", 0.8,
+				fmt.Sprintf("The image ends at the EOI at offset %d, and further EOI markers follow at %s; the %d bytes up to the last are hidden from tools that take the last FF D9 for the end of the image",
+					markers.End-2, formatOffsets(markers.After), last-markers.End))
+			if result.DetectionScore < 0.8 {
+				result.DetectionScore = 0.8
+			}
+		}
+		result.Recommendations = append(result.Recommendations,
+			fmt.Sprintf("Extract the data after the EOI marker at offset %d, which ends the image", markers.End-2))
+	}
+	if len(markers.InComments) > 0 {
+		found = true
+		result.AddFinding("EOI marker inside a comment", 0.6,
+			fmt.Sprintf("FF D9 at %s, inside COM segments before the image data; tools that take the first FF D9 for the end of the image stop there",
+				formatOffsets(markers.InComments)))
+		if result.DetectionScore < 0.6 {
+			result.DetectionScore = 0.6
+		}
+	}
+	if !found {
+		result.AddCheck(fmt.Sprintf("single EOI marker, at offset %d", markers.End-2))
+	}
+}
+
+// formatOffsets lists file offsets for a finding, at most five of them
+func formatOffsets(offsets []int) string {
+	const shown = 5
+	parts := make([]string, 0, shown+1)
+	for i, offset := range offsets {
+		if i == shown {
+			parts = append(parts, fmt.Sprintf("and %d more", len(offsets)-shown))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("offset %d", offset))
+	}
+	return strings.Join(parts, ", ")
+}
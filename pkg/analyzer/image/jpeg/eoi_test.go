@@ -0,0 +1,70 @@
+package jpeg
+
+import (
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/models"
+)
+
+func TestFindEOIMarkers(t *testing.T) {
+	clean, err := fixtures.Load("clean.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commented, err := fixtures.JPEGComment(clean, []byte("cover\xFF\xD9text"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte // Built in the test when set, else the fixture called name
+		after      int    // EOI markers expected after the end of the image
+		inComments int    // EOI markers expected inside comments
+		scanSplit  bool
+		finding    string // Expected finding, empty when none
+	}{
+		{name: "double_eoi.jpg", after: 1, finding: "Multiple EOI markers"},
+		{name: "split_scan.jpg", after: 1, scanSplit: true, finding: "EOI marker inside the scan data"},
+		{name: "clean.jpg"},
+		{name: "appended_zip.jpg"},
+		{name: "comment with EOI", data: commented, inComments: 1, finding: "EOI marker inside a comment"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data
+			if data == nil {
+				if data, err = fixtures.Load(tt.name); err != nil {
+					t.Fatal(err)
+				}
+			}
+			markers := FindEOIMarkers(data)
+			if markers.End == 0 {
+				t.Fatal("the marker walk did not reach EOI")
+			}
+			if len(markers.After) != tt.after || len(markers.InComments) != tt.inComments || markers.ScanSplit != tt.scanSplit {
+				t.Errorf("found %+v, want %d after the image, %d in comments and scan split %v", markers, tt.after, tt.inComments, tt.scanSplit)
+			}
+			if tt.after > 0 && markers.After[tt.after-1] != len(data)-2 {
+				t.Errorf("last EOI at offset %d, want the end of the file at %d", markers.After[tt.after-1], len(data)-2)
+			}
+
+			// The payload sits between the EOI that ends the image and the last one
+			if fixture, ok := fixtures.Lookup(tt.name); ok && fixture.Payload != "" && tt.after > 0 {
+				if hidden := string(data[markers.End:markers.After[0]]); hidden != fixture.Payload {
+					t.Errorf("found %q between the EOI markers, want %q", hidden, fixture.Payload)
+				}
+			}
+
+			result := &models.AnalysisResult{}
+			analyzeEOIMarkers(data, result)
+			switch {
+			case tt.finding == "" && len(result.Findings) > 0:
+				t.Errorf("reported %q on a file with one EOI", result.Findings[0].Description)
+			case tt.finding != "" && (len(result.Findings) != 1 || result.Findings[0].Description != tt.finding):
+				t.Errorf("reported %+v, want %q", result.Findings, tt.finding)
+			}
+		})
+	}
+}
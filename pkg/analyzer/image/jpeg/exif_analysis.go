@@ -0,0 +1,152 @@
+package jpeg
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"path/filepath"
+	"strings"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/analyzer/image/exif"
+	"DeSteGo/pkg/analyzer/whitespace"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/imghash"
+	"DeSteGo/pkg/models"
+)
+
+// markerAPP1 is the application segment EXIF data is stored in
+const markerAPP1 = 0xE1
+
+// thumbnailHashThreshold is the aHash distance above which a thumbnail is
+// considered to show a different picture than the main image
+const thumbnailHashThreshold = 16
+
+// findEXIFSegment returns the payload of the first APP1 segment holding EXIF data
+func findEXIFSegment(data []byte) []byte {
+	var found []byte
+	WalkMarkers(data, func(seg Segment) bool {
+		if seg.Marker == markerAPP1 && bytes.HasPrefix(seg.Payload, exif.Header) {
+			found = seg.Payload
+			return false
+		}
+		return true // EXIF must precede the image data, which ends the walk
+	})
+	return found
+}
+
+// analyzeEXIF parses the EXIF block of a JPEG file, reports any GPS position and
+// compares the embedded thumbnail against the main image
+func analyzeEXIF(data []byte, img image.Image, filePath string, options analyzer.AnalysisOptions, result *models.AnalysisResult) {
+	segment := findEXIFSegment(data)
+	if segment == nil {
+		return
+	}
+
+	exifData, err := exif.Parse(segment)
+	if err != nil {
+		result.AddFinding("Malformed EXIF metadata", 0.3, err.Error())
+		return
+	}
+
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+
+	if gps := exifData.GPSInfo(); gps != nil {
+		location := map[string]interface{}{
+			"latitude":  gps.Latitude,
+			"longitude": gps.Longitude,
+		}
+		if gps.HasAltitude {
+			location["altitude"] = gps.Altitude
+		}
+		if gps.Timestamp != "" {
+			location["timestamp"] = gps.Timestamp
+		}
+		result.Details["gps"] = location
+
+		details := fmt.Sprintf("Latitude %.6f, longitude %.6f", gps.Latitude, gps.Longitude)
+		if gps.HasAltitude {
+			details += fmt.Sprintf(", altitude %.1fm", gps.Altitude)
+		}
+		if gps.Timestamp != "" {
+			details += ", recorded " + gps.Timestamp + " UTC"
+		}
+		result.AddFinding(fmt.Sprintf("Image contains GPS coordinates (%.6f, %.6f)", gps.Latitude, gps.Longitude),
+			1.0, details)
+	}
+
+	// Text tags can hide data in characters no viewer shows
+	before := len(result.Findings)
+	texts := exifData.Texts()
+	for _, field := range texts {
+		whitespace.Analyze(field.Text, "EXIF "+field.Name, filePath, options, result)
+	}
+	if len(texts) > 0 && len(result.Findings) == before {
+		result.AddCheck(fmt.Sprintf("no invisible characters in %d EXIF text tags", len(texts)))
+	}
+
+	analyzeThumbnail(exifData, img, filePath, options, result)
+}
+
+// analyzeThumbnail flags an EXIF thumbnail whose content does not match the
+// main image, a known way of smuggling a second picture
+func analyzeThumbnail(exifData *exif.Data, img image.Image, filePath string, options analyzer.AnalysisOptions, result *models.AnalysisResult) {
+	if exifData.IFD1 == nil {
+		return
+	}
+	offsetTag, ok1 := exifData.IFD1[exif.TagJPEGInterchange]
+	lengthTag, ok2 := exifData.IFD1[exif.TagJPEGInterchangeLen]
+	if !ok1 || !ok2 {
+		return
+	}
+	offset, ok1 := exifData.Uint(offsetTag, 0)
+	length, ok2 := exifData.Uint(lengthTag, 0)
+	raw := exifData.Raw()
+	if !ok1 || !ok2 || length == 0 || offset+length > uint64(len(raw)) {
+		result.AddFinding("EXIF thumbnail points outside the metadata block", 0.4,
+			fmt.Sprintf("offset=%d length=%d, EXIF size=%d", offset, length, len(raw)))
+		return
+	}
+	thumbData := raw[offset : offset+length]
+
+	if options.Extract && options.OutputDir != "" {
+		base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+		outputPath := filepath.Join(options.OutputDir, base+"_exif_thumbnail.jpg")
+		if outputPath, err := filehandler.SaveFileUnique(thumbData, outputPath); err == nil {
+			result.Details["exif_thumbnail_file"] = outputPath
+		}
+	}
+
+	thumb, err := jpeg.Decode(bytes.NewReader(thumbData))
+	if err != nil {
+		result.AddFinding("EXIF thumbnail is not a decodable JPEG", 0.6,
+			fmt.Sprintf("%d bytes declared as thumbnail: %v", len(thumbData), err))
+		result.Recommendations = append(result.Recommendations,
+			"Inspect the raw EXIF thumbnail bytes for hidden data")
+		return
+	}
+
+	thumbBounds := thumb.Bounds()
+	distance := imghash.Distance(imghash.AverageHash(img), imghash.AverageHash(thumb))
+	result.Details["exif_thumbnail"] = map[string]interface{}{
+		"width":         thumbBounds.Dx(),
+		"height":        thumbBounds.Dy(),
+		"size":          len(thumbData),
+		"hash_distance": distance,
+	}
+
+	if distance > thumbnailHashThreshold {
+		confidence := 0.6 + 0.4*float64(distance-thumbnailHashThreshold)/float64(64-thumbnailHashThreshold)
+		result.AddFinding("EXIF thumbnail differs from the main image", confidence,
+			fmt.Sprintf("Thumbnail %dx%d, average-hash distance %d/64 (threshold %d)",
+				thumbBounds.Dx(), thumbBounds.Dy(), distance, thumbnailHashThreshold))
+		result.Recommendations = append(result.Recommendations,
+			"Extract the EXIF thumbnail (-extract) and inspect it as a separate image")
+		if confidence > result.DetectionScore {
+			result.DetectionScore = confidence
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package jpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/jpeg"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/analyzer/image/exif"
+	"DeSteGo/pkg/models"
+)
+
+// gpsEXIF returns a big-endian EXIF block whose GPS IFD places the image at
+// 48°51'29.88"N 2°17'40.2"W, 35m up, on 2024:05:01 at 12:30:15 UTC
+func gpsEXIF() []byte {
+	be := binary.BigEndian
+	rationals := func(values ...[2]uint32) []byte {
+		var b []byte
+		for _, v := range values {
+			b = be.AppendUint32(b, v[0])
+			b = be.AppendUint32(b, v[1])
+		}
+		return b
+	}
+	type entry struct {
+		tag, typ uint16
+		count    uint32
+		value    []byte
+	}
+	entries := []entry{
+		{exif.TagGPSLatitudeRef, 2, 2, []byte("N\x00")},
+		{exif.TagGPSLatitude, 5, 3, rationals([2]uint32{48, 1}, [2]uint32{51, 1}, [2]uint32{2988, 100})},
+		{exif.TagGPSLongitudeRef, 2, 2, []byte("W\x00")},
+		{exif.TagGPSLongitude, 5, 3, rationals([2]uint32{2, 1}, [2]uint32{17, 1}, [2]uint32{402, 10})},
+		{exif.TagGPSAltitudeRef, 1, 1, []byte{0}},
+		{exif.TagGPSAltitude, 5, 1, rationals([2]uint32{35, 1})},
+		{exif.TagGPSTimeStamp, 5, 3, rationals([2]uint32{12, 1}, [2]uint32{30, 1}, [2]uint32{15, 1})},
+		{exif.TagGPSDateStamp, 2, 11, []byte("2024:05:01\x00")},
+	}
+
+	// IFD0 holds only the GPS pointer, the GPS IFD follows it and the values
+	// longer than four bytes follow the GPS IFD
+	const gpsIFD = 8 + 2 + 12 + 4
+	valuesStart := gpsIFD + 2 + len(entries)*12 + 4
+
+	out := []byte("MM\x00\x2A")
+	out = be.AppendUint32(out, 8)
+	out = be.AppendUint16(out, 1)
+	out = be.AppendUint16(out, exif.TagGPSIFDPointer)
+	out = be.AppendUint16(out, 4)
+	out = be.AppendUint32(out, 1)
+	out = be.AppendUint32(out, gpsIFD)
+	out = be.AppendUint32(out, 0)
+
+	var values []byte
+	out = be.AppendUint16(out, uint16(len(entries)))
+	for _, e := range entries {
+		out = be.AppendUint16(out, e.tag)
+		out = be.AppendUint16(out, e.typ)
+		out = be.AppendUint32(out, e.count)
+		if len(e.value) > 4 {
+			out = be.AppendUint32(out, uint32(valuesStart+len(values)))
+			values = append(values, e.value...)
+		} else {
+			out = append(out, e.value...)
+			out = append(out, make([]byte, 4-len(e.value))...)
+		}
+	}
+	out = be.AppendUint32(out, 0)
+	return append(append(append([]byte{}, exif.Header...), out...), values...)
+}
+
+func TestAnalyzeEXIFGPS(t *testing.T) {
+	clean, err := fixtures.Load("clean.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	segment := gpsEXIF()
+	data := append([]byte{0xFF, markerSOI, 0xFF, markerAPP1}, byte((len(segment)+2)>>8), byte(len(segment)+2))
+	data = append(append(data, segment...), clean[2:]...)
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := &models.AnalysisResult{}
+	analyzeEXIF(data, img, "gps.jpg", analyzer.AnalysisOptions{}, result)
+
+	gps, ok := result.Details["gps"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("no GPS details in %v", result.Details)
+	}
+	want := map[string]float64{"latitude": 48.8583, "longitude": -2.2945, "altitude": 35}
+	for key, v := range want {
+		if got, _ := gps[key].(float64); math.Abs(got-v) > 1e-4 {
+			t.Errorf("%s = %v, want %v", key, gps[key], v)
+		}
+	}
+	if got := gps["timestamp"]; got != "2024:05:01 12:30:15" {
+		t.Errorf("timestamp = %v, want 2024:05:01 12:30:15", got)
+	}
+
+	found := false
+	for _, f := range result.Findings {
+		if f.Description == "Image contains GPS coordinates (48.858300, -2.294500)" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no GPS finding in %v", result.Findings)
+	}
+
+	// The clean image has no EXIF block, so nothing is reported
+	result = &models.AnalysisResult{}
+	analyzeEXIF(clean, img, "clean.jpg", analyzer.AnalysisOptions{}, result)
+	if _, ok := result.Details["gps"]; ok || len(result.Findings) > 0 {
+		t.Errorf("clean image reported GPS details %v and findings %v", result.Details, result.Findings)
+	}
+}
+
+func TestAnalyzeThumbnail(t *testing.T) {
+	photo := fixtures.Carrier(64, 48, 1)
+	inverted := fixtures.Carrier(16, 12, 2)
+	for i := range inverted.Pix {
+		if i%4 != 3 {
+			inverted.Pix[i] = 255 - inverted.Pix[i]
+		}
+	}
+	same, err := fixtures.EncodeJPEG(fixtures.Carrier(16, 12, 2), 90)
+	if err != nil {
+		t.Fatal(err)
+	}
+	different, err := fixtures.EncodeJPEG(inverted, 90)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		thumb   []byte
+		finding string // Expected finding, empty for none
+	}{
+		{"downscaled main image", same, ""},
+		{"different image", different, "EXIF thumbnail differs from the main image"},
+		{"not a JPEG", []byte("not a thumbnail at all"), "EXIF thumbnail is not a decodable JPEG"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Big-endian TIFF block with an empty IFD0, an IFD1 holding the
+			// thumbnail offset and length, and the thumbnail after it
+			be := binary.BigEndian
+			const ifd1, thumbOffset = 8 + 2 + 4, 8 + 2 + 4 + 2 + 2*12 + 4
+			block := be.AppendUint32([]byte("MM\x00\x2A"), 8)
+			block = be.AppendUint16(block, 0)
+			block = be.AppendUint32(block, ifd1)
+			block = be.AppendUint16(block, 2)
+			for _, e := range [][2]uint32{{exif.TagJPEGInterchange, thumbOffset}, {exif.TagJPEGInterchangeLen, uint32(len(tt.thumb))}} {
+				block = be.AppendUint16(block, uint16(e[0]))
+				block = be.AppendUint16(block, 4)
+				block = be.AppendUint32(block, 1)
+				block = be.AppendUint32(block, e[1])
+			}
+			block = be.AppendUint32(block, 0)
+			segment := append(append(append([]byte{}, exif.Header...), block...), tt.thumb...)
+			data := append([]byte{0xFF, markerSOI, 0xFF, markerAPP1}, byte((len(segment)+2)>>8), byte(len(segment)+2))
+			data = append(data, segment...)
+
+			outDir := t.TempDir()
+			result := &models.AnalysisResult{Details: map[string]interface{}{}}
+			analyzeEXIF(data, photo, "photo.jpg", analyzer.AnalysisOptions{Extract: true, OutputDir: outDir}, result)
+
+			var found []string
+			for _, f := range result.Findings {
+				found = append(found, f.Description)
+			}
+			if tt.finding == "" && len(found) > 0 {
+				t.Errorf("got findings %q, want none", found)
+			}
+			if tt.finding != "" && (len(found) != 1 || found[0] != tt.finding) {
+				t.Errorf("got findings %q, want %q", found, tt.finding)
+			}
+			if tt.finding == "EXIF thumbnail differs from the main image" {
+				if result.DetectionScore < 0.6 {
+					t.Errorf("got score %.2f, want at least 0.6", result.DetectionScore)
+				}
+				thumb, _ := result.Details["exif_thumbnail"].(map[string]interface{})
+				if thumb["width"] != 16 || thumb["height"] != 12 {
+					t.Errorf("got thumbnail details %v, want 16x12", thumb)
+				}
+			}
+
+			saved, err := os.ReadFile(filepath.Join(outDir, "photo_exif_thumbnail.jpg"))
+			if err != nil || !bytes.Equal(saved, tt.thumb) {
+				t.Errorf("thumbnail not extracted: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package jpeg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// seedJPEGs returns valid JPEGs at a few qualities plus truncated and corrupted
+// copies of them
+func seedJPEGs(t testing.TB) [][]byte {
+	img := image.NewRGBA(image.Rect(0, 0, 24, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 24; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 10), uint8(y * 15), uint8(x ^ y), 255})
+		}
+	}
+
+	var seeds [][]byte
+	for _, quality := range []int{10, 75, 100} {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			t.Fatalf("failed to encode seed: %v", err)
+		}
+		valid := buf.Bytes()
+		seeds = append(seeds, valid)
+
+		truncated := append([]byte(nil), valid[:len(valid)/2]...)
+		seeds = append(seeds, truncated)
+
+		// Zero the length of the first segment after SOI
+		zeroLength := append([]byte(nil), valid...)
+		zeroLength[4], zeroLength[5] = 0, 0
+		seeds = append(seeds, zeroLength)
+
+		// Declare a first segment longer than the file
+		overlong := append([]byte(nil), valid...)
+		overlong[4], overlong[5] = 0xFF, 0xFF
+		seeds = append(seeds, overlong)
+
+		// Declare a restart interval the scan has no markers for
+		restarts := append([]byte{0xFF, 0xD8, 0xFF, 0xDD, 0x00, 0x04, 0x00, 0x01}, valid[2:]...)
+		seeds = append(seeds, restarts)
+	}
+	return append(seeds, []byte{0xFF, 0xD8}, []byte{0xFF, 0xD8, 0xFF, 0xDB, 0x00, 0x03, 0x40})
+}
+
+func FuzzExtractJPEGMetadata(f *testing.F) {
+	for _, seed := range seedJPEGs(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		meta, err := ExtractJPEGMetadata(data)
+		if meta == nil && err == nil {
+			t.Fatal("no metadata and no error")
+		}
+		if meta != nil {
+			for _, seg := range meta.AppSegments {
+				seg.Suspicious()
+			}
+		}
+	})
+}
+
+func FuzzParseJPEGDCTCoefficients(f *testing.F) {
+	for _, seed := range seedJPEGs(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		dct, err := ParseJPEGDCTCoefficients(data)
+		if dct == nil && err == nil {
+			t.Fatal("no coefficients and no error")
+		}
+	})
+}
@@ -3,10 +3,11 @@ package jpeg
 import (
 	"fmt"
 	"image"
-	"image/jpeg"
-	"os"
+	"strings"
 
 	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/analyzer/carve"
+	"DeSteGo/pkg/analyzer/image/lsb"
 	"DeSteGo/pkg/models"
 )
 
@@ -28,17 +29,29 @@ func NewJPEGAnalyzer() *JPEGAnalyzer {
 
 // Analyze performs analysis on a JPEG file
 func (a *JPEGAnalyzer) Analyze(filePath string, options analyzer.AnalysisOptions) (*models.AnalysisResult, error) {
-	// Open the file
-	file, err := os.Open(filePath)
+	data, err := models.ReadFile(options.Source, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
-	defer file.Close()
 
-	// Decode the JPEG image
-	img, err := jpeg.Decode(file)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode JPEG: %w", err)
+	// Data prepended to the JPEG hides its signature from the decoder
+	prefix := carve.FindPrefix(data, "jpg")
+	imageData := data
+	if prefix != nil {
+		imageData = data[prefix.Offset:]
+	}
+
+	// Decode the JPEG image, resynchronizing stray markers and a cut-off scan if
+	// needed. The shared image is the same unless a prefix was cut off.
+	img, repairs, ok := models.SourceImage(options.Source, filePath, "jpeg")
+	if !ok || prefix != nil {
+		if img, repairs, err = DecodeLenient(imageData); err != nil {
+			// The decoder rejects restart markers that are out of turn
+			if result := undecodableResult(imageData, err, filePath, options); result != nil {
+				return result, nil
+			}
+			return nil, fmt.Errorf("failed to decode JPEG: %w", err)
+		}
 	}
 
 	// Create result object
@@ -48,21 +61,58 @@ func (a *JPEGAnalyzer) Analyze(filePath string, options analyzer.AnalysisOptions
 		Findings:        []models.Finding{},
 		Recommendations: []string{},
 	}
+	analyzer.AddRepairs(result, repairs)
+
+	// The marker walk runs through every scan to EOI, so the EOI bytes that
+	// turn up inside progressive and restart-coded image data are not taken
+	// for the end of the file. A missing EOI is reported by AnalyzeMetadata.
+	meta := AnalyzeMetadata(imageData, filePath, options, result)
+	if meta != nil && meta.End > 0 {
+		if meta.AppendedBytes(len(imageData)) > 0 {
+			carve.AnalyzeAppended(data, len(data)-len(imageData)+meta.End, filePath, options, result)
+		} else {
+			result.AddCheck("no data after EOI")
+		}
+		if result.Details == nil {
+			result.Details = map[string]interface{}{}
+		}
+		result.Details["jpeg_scans"] = meta.Scans
+		analyzeEOIMarkers(imageData, result)
+	}
 
-	// Check for appended data (reopen the file to check for appended data)
-	file.Seek(0, 0)
-	hasAppendedData, appendedSize, err := checkForAppendedData(file)
-	if err != nil {
-		return nil, fmt.Errorf("failed to check for appended data: %w", err)
+	before := len(result.Findings)
+	analyzeEXIF(imageData, img, filePath, options, result)
+	carve.AnalyzePrefix(data, prefix, filePath, options, result)
+	carve.AnalyzeEmbeddedFiles(data, "jpg", filePath, options, result)
+	if len(result.Findings) == before {
+		result.AddCheck("no suspicious EXIF data or embedded files")
 	}
 
-	if hasAppendedData {
-		result.AddFinding("Found appended data after EOF", 0.8,
-			fmt.Sprintf("Found %d bytes of appended data", appendedSize))
-		result.DetectionScore = 0.7
-		result.Confidence = 0.8
-		result.Recommendations = append(result.Recommendations,
-			"Extract and analyze the appended data after JPEG EOF marker")
+	// Decode the quantized DCT coefficients for algorithm-specific detectors,
+	// from the repaired file when the image needed repairs, so that they are the
+	// coefficients of the image that was decoded. The blocks of a scan cut off
+	// at the end of the file are all zero, which the detectors would take for
+	// the statistics of an embedding.
+	dctInput := imageData
+	if len(repairs) > 0 {
+		dctInput, _ = Resync(imageData)
+	}
+	var dctData *JPEGDCTData
+	if len(repairs) > 0 && (meta == nil || meta.End == 0) {
+		result.AddFinding("DCT coefficient analysis unavailable", 0.1, "the image data is cut off before EOI")
+	} else if dctData, err = ParseJPEGDCTCoefficients(dctInput); err != nil {
+		result.AddFinding("DCT coefficient analysis unavailable", 0.1, err.Error())
+	} else {
+		analyzeDCTCoefficients(dctData, img, options.Thresholds().DetectorProbability, result)
+		// The repairs renumber restart markers, so they are checked as the file has them
+		restartData := dctData
+		if len(repairs) > 0 {
+			restartData, _ = ParseJPEGDCTCoefficients(imageData)
+		}
+		if restartData != nil {
+			analyzeRestarts(restartData, filePath, options, result)
+		}
+		analyzeDoubleCompression(dctData, options.Thresholds().DetectorProbability, result)
 	}
 
 	// Run image-based analysis (common for all image types)
@@ -71,26 +121,16 @@ func (a *JPEGAnalyzer) Analyze(filePath string, options analyzer.AnalysisOptions
 		return nil, fmt.Errorf("image analysis failed: %w", err)
 	}
 
-	// Merge results
-	if imgResult != nil {
-		for _, finding := range imgResult.Findings {
-			result.AddFinding(finding.Description, finding.Confidence, finding.Details)
-		}
-
-		// Take the higher detection score
-		if imgResult.DetectionScore > result.DetectionScore {
-			result.DetectionScore = imgResult.DetectionScore
-		}
-
-		// Add image recommendations
-		result.Recommendations = append(result.Recommendations, imgResult.Recommendations...)
+	// The image-level findings and details join the coefficient ones
+	result.Merge(imgResult)
 
-		// Set possible algorithm if not already set
-		if result.PossibleAlgorithm == "" {
-			result.PossibleAlgorithm = imgResult.PossibleAlgorithm
-		}
+	// Pixel LSBs do not survive JPEG compression, so the capacity is that of
+	// coefficient-domain embedding, limited by the usable AC coefficients
+	if dctData != nil {
+		result.Details["capacity"] = map[string]int{lsb.CapacityJSteg: dctData.GetStegoCoefficientCount() / 8}
 	}
 
+	result.SetCleanRationale()
 	return result, nil
 }
 
@@ -125,42 +165,88 @@ func (a *JPEGAnalyzer) AnalyzeImage(img image.Image, options analyzer.AnalysisOp
 	return result, nil
 }
 
-// checkForAppendedData looks for data after the JPEG EOF marker
-func checkForAppendedData(file *os.File) (bool, int64, error) {
-	// Get file size
-	fileInfo, err := file.Stat()
+// AnalyzeMetadata runs the checks of the marker segments before the image
+// data: application segments, quantization tables, comments and the color
+// model. They only need the file's bytes, so they also run on files the
+// decoder rejects.
+func AnalyzeMetadata(data []byte, filePath string, options analyzer.AnalysisOptions, result *models.AnalysisResult) *JPEGMetadata {
+	meta, err := ExtractJPEGMetadata(data)
 	if err != nil {
-		return false, 0, err
+		result.AddFinding("Malformed JPEG marker structure", 0.3, err.Error())
+	}
+	if meta == nil {
+		return nil
+	}
+	before := len(result.Findings)
+	analyzeAppSegments(meta, result)
+	analyzeQuantTables(meta, result)
+	analyzeComments(meta, filePath, options, result)
+	if len(result.Findings) == before {
+		result.AddCheck("no suspicious metadata, quantization tables or comments")
 	}
-	fileSize := fileInfo.Size()
+	metadata := 0.0
+	for _, finding := range result.Findings[before:] {
+		metadata = max(metadata, finding.Confidence)
+	}
+	result.SetDetectorScore(analyzer.DetectorMetadata, metadata)
+	analyzeColorModel(meta, result)
+	return meta
+}
 
-	// Buffer for reading
-	buffer := make([]byte, 2)
+// analyzeDCTCoefficients runs the coefficient-level detectors and records the
+// findings of those whose probability is above threshold
+func analyzeDCTCoefficients(dctData *JPEGDCTData, img image.Image, threshold float64, result *models.AnalysisResult) {
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
 
-	// JPEG files end with the EOI marker: 0xFF 0xD9
-	// Start from the end and search backwards for the EOI marker
-	for pos := fileSize - 2; pos >= 0; pos -= 1 {
-		_, err = file.Seek(pos, 0)
-		if err != nil {
-			return false, 0, err
+	// Blocks decoded per component, four for CMYK and YCCK files
+	blocks := make([]int, 0, len(dctData.Components))
+	for _, c := range dctData.Components {
+		blocks = append(blocks, c.BlocksWide*c.BlocksHigh)
+	}
+	result.Details["dct_component_blocks"] = blocks
+
+	var clean []string
+	for _, detector := range DefaultDetectors(img) {
+		probability, details := detector.Detect(dctData)
+		name := detector.Name()
+		result.Details[strings.ToLower(name)+"_probability"] = probability
+		result.SetDetectorScore(strings.ToLower(name), probability)
+
+		if probability <= threshold {
+			clean = append(clean, fmt.Sprintf("%s %.2f", name, probability))
+			continue
 		}
 
-		_, err = file.Read(buffer)
-		if err != nil {
-			return false, 0, err
+		result.AddFinding(fmt.Sprintf("DCT coefficient statistics match %s embedding", name), probability, details)
+		result.AddExtractionHint(strings.ToLower(name), probability, nil)
+		if rec, ok := detectorRecommendations[name]; ok {
+			result.Recommendations = append(result.Recommendations, rec)
 		}
-
-		// Check if we found the EOI marker
-		if buffer[0] == 0xFF && buffer[1] == 0xD9 {
-			// If the marker is not at the end, we have appended data
-			if pos+2 < fileSize {
-				appendedSize := fileSize - (pos + 2)
-				return true, appendedSize, nil
-			}
-			return false, 0, nil
+		if probability > result.DetectionScore {
+			result.DetectionScore = probability
+			result.PossibleAlgorithm = name
 		}
 	}
+	if len(clean) > 0 {
+		result.AddCheck("DCT statistics natural (" + strings.Join(clean, ", ") + ")")
+	}
+}
 
-	// If we reach here, we didn't find an EOI marker
-	return false, 0, fmt.Errorf("invalid JPEG: no EOI marker found")
+// analyzeColorModel records the color model and notes four-component files,
+// whose black channel is one more place for coefficient embedding
+func analyzeColorModel(meta *JPEGMetadata, result *models.AnalysisResult) {
+	model := meta.ColorModel()
+	if model == "" {
+		return
+	}
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	result.Details["color_model"] = string(model)
+	if model.Multichannel() {
+		result.AddFinding(fmt.Sprintf("JPEG uses the %s color model", model), 0.1,
+			fmt.Sprintf("%d components, as written by print and Adobe workflows; the coefficients of all of them are analyzed", meta.Components))
+	}
 }
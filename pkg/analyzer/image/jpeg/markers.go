@@ -0,0 +1,111 @@
+package jpeg
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+This file contains the marker walker shared by the metadata and EXIF readers.
+WalkMarkers stops at the first scan, which is all the EXIF reader needs, while
+WalkImage continues through every scan to EOI. Both work on an in-memory byte
+slice and report every malformed structure as an error wrapping one of the
+sentinel errors below, so callers and tests can tell a truncated file from a
+corrupt segment with errors.Is.
+*/
+
+// Errors returned by WalkMarkers and the segment readers
+var (
+	ErrNotJPEG        = errors.New("invalid JPEG: missing SOI marker")
+	ErrTruncated      = errors.New("invalid JPEG: truncated")
+	ErrSegmentLength  = errors.New("invalid JPEG: bad segment length")
+	ErrExpectedMarker = errors.New("invalid JPEG: expected marker")
+
+	// ErrMalformedSegment is returned by readers of segment contents, such as
+	// ExtractJPEGMetadata, when a segment's payload contradicts its own fields
+	ErrMalformedSegment = errors.New("invalid JPEG: malformed segment")
+)
+
+// Segment is one marker segment of a JPEG file
+type Segment struct {
+	Marker  byte   // Marker code, the byte after 0xFF
+	Offset  int    // Position of the 0xFF that starts the marker
+	Payload []byte // Bytes after the length field; nil for EOI
+}
+
+// WalkMarkers calls visit for each marker segment of data, in file order, up to
+// and including the first SOS or EOI marker. Fill bytes and stray restart
+// markers are skipped. Walking stops early without an error when visit returns
+// false. The image data after SOS is not examined.
+func WalkMarkers(data []byte, visit func(Segment) bool) error {
+	_, err := walk(data, visit, false)
+	return err
+}
+
+// WalkImage is like WalkMarkers but walks through the whole image: the
+// entropy-coded data after each SOS, with its restart markers and stuffed
+// bytes, is skipped to the next marker, so the tables and scans that follow
+// the first scan of a progressive file are visited too. It returns the offset
+// just past EOI, which is where data appended to the image starts, or 0 when
+// walking stopped before EOI.
+func WalkImage(data []byte, visit func(Segment) bool) (int, error) {
+	return walk(data, visit, true)
+}
+
+// walk implements WalkMarkers and, when throughScans is set, WalkImage
+func walk(data []byte, visit func(Segment) bool, throughScans bool) (int, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != markerSOI {
+		return 0, ErrNotJPEG
+	}
+
+	pos := 2
+	for {
+		if pos >= len(data) {
+			if throughScans {
+				return 0, fmt.Errorf("%w: no EOI marker before the end of the file", ErrTruncated)
+			}
+			return 0, fmt.Errorf("%w: no SOS or EOI marker before the end of the file", ErrTruncated)
+		}
+		if data[pos] != 0xFF {
+			return 0, fmt.Errorf("%w at offset %d, found 0x%02X", ErrExpectedMarker, pos, data[pos])
+		}
+		if pos+1 >= len(data) {
+			return 0, fmt.Errorf("%w: marker at offset %d is cut off", ErrTruncated, pos)
+		}
+
+		marker := data[pos+1]
+		switch {
+		case marker == 0xFF:
+			pos++ // Fill byte
+			continue
+		case marker == markerEOI:
+			visit(Segment{Marker: marker, Offset: pos})
+			return pos + 2, nil
+		case marker >= markerRST0 && marker <= markerRST7, marker == 0x01:
+			pos += 2 // Standalone markers without a length field
+			continue
+		}
+
+		if pos+4 > len(data) {
+			return 0, fmt.Errorf("%w: length of marker 0x%02X at offset %d is cut off", ErrTruncated, marker, pos)
+		}
+		length := int(data[pos+2])<<8 | int(data[pos+3])
+		if length < 2 {
+			return 0, fmt.Errorf("%w: %d for marker 0x%02X at offset %d", ErrSegmentLength, length, marker, pos)
+		}
+		if pos+2+length > len(data) {
+			return 0, fmt.Errorf("%w: marker 0x%02X at offset %d declares %d bytes but only %d remain",
+				ErrTruncated, marker, pos, length-2, len(data)-pos-4)
+		}
+
+		segment := Segment{Marker: marker, Offset: pos, Payload: data[pos+4 : pos+2+length]}
+		if !visit(segment) || (marker == markerSOS && !throughScans) {
+			return 0, nil
+		}
+		pos += 2 + length
+		if marker == markerSOS {
+			// RSTn markers and stuffed bytes are part of the scan, not boundaries
+			pos = FindScanEnd(data, pos)
+		}
+	}
+}
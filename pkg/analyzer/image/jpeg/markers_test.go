@@ -0,0 +1,110 @@
+package jpeg
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// segment encodes a marker segment with the given payload
+func segment(marker byte, payload ...byte) []byte {
+	length := len(payload) + 2
+	return append([]byte{0xFF, marker, byte(length >> 8), byte(length)}, payload...)
+}
+
+// jpegBytes joins SOI and the given parts
+func jpegBytes(parts ...[]byte) []byte {
+	return bytes.Join(append([][]byte{{0xFF, markerSOI}}, parts...), nil)
+}
+
+// flatTables returns a DQT segment of 1s and one-symbol DC and AC Huffman
+// tables, which code a DC difference of 0 and an end of block as a single 0
+// bit each, so that every block of a flat image is two 0 bits
+func flatTables() []byte {
+	table := func(class byte) []byte {
+		return append(append([]byte{class, 1}, make([]byte, 15)...), 0)
+	}
+	return bytes.Join([][]byte{segment(markerDQT, dqt(0, 1)...), segment(markerDHT, table(0x00)...), segment(markerDHT, table(0x10)...)}, nil)
+}
+
+func TestWalkMarkers(t *testing.T) {
+	eoi := []byte{0xFF, markerEOI}
+	sos := segment(markerSOS, 1, 1, 0, 0, 63, 0)
+	tests := []struct {
+		name    string
+		data    []byte
+		markers []byte // Markers visited, in order
+		err     error
+	}{
+		{"minimal", jpegBytes(segment(markerCOM, 'h', 'i'), eoi), []byte{markerCOM, markerEOI}, nil},
+		{"stops at SOS", jpegBytes(segment(markerAPP0), sos, []byte{0x12, 0x34}), []byte{markerAPP0, markerSOS}, nil},
+		{"fill bytes", jpegBytes([]byte{0xFF, 0xFF}, segment(markerCOM), eoi), []byte{markerCOM, markerEOI}, nil},
+		{"stray restart marker", jpegBytes([]byte{0xFF, markerRST0}, segment(markerCOM), eoi), []byte{markerCOM, markerEOI}, nil},
+		{"empty", nil, nil, ErrNotJPEG},
+		{"not a JPEG", []byte("\x89PNG\r\n\x1a\n"), nil, ErrNotJPEG},
+		{"missing EOI", jpegBytes(segment(markerCOM)), []byte{markerCOM}, ErrTruncated},
+		{"marker cut off", jpegBytes(segment(markerCOM), []byte{0xFF}), []byte{markerCOM}, ErrTruncated},
+		{"length cut off", jpegBytes([]byte{0xFF, markerCOM, 0x00}), nil, ErrTruncated},
+		{"truncated segment", jpegBytes(segment(markerCOM, 1, 2, 3, 4)[:6]), nil, ErrTruncated},
+		{"length past the end", jpegBytes([]byte{0xFF, markerAPP0, 0xFF, 0xFF, 0, 0}), nil, ErrTruncated},
+		{"zero length", jpegBytes([]byte{0xFF, markerCOM, 0, 0}, eoi), nil, ErrSegmentLength},
+		{"length one", jpegBytes([]byte{0xFF, markerCOM, 0, 1, 0}, eoi), nil, ErrSegmentLength},
+		{"garbage between segments", jpegBytes(segment(markerCOM), []byte{0x00}, eoi), []byte{markerCOM}, ErrExpectedMarker},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var markers []byte
+			err := WalkMarkers(tt.data, func(seg Segment) bool {
+				markers = append(markers, seg.Marker)
+				return true
+			})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+			if !bytes.Equal(markers, tt.markers) {
+				t.Errorf("visited % X, want % X", markers, tt.markers)
+			}
+		})
+	}
+}
+
+func TestWalkMarkersStop(t *testing.T) {
+	data := jpegBytes(segment(markerAPP0), segment(markerCOM), []byte{0xFF, markerEOI})
+	visited := 0
+	err := WalkMarkers(data, func(seg Segment) bool {
+		visited++
+		return false
+	})
+	if err != nil || visited != 1 {
+		t.Errorf("visited %d segments with error %v, want 1 and no error", visited, err)
+	}
+}
+
+func TestWalkImage(t *testing.T) {
+	// Two scans whose entropy-coded data holds a stuffed byte and a restart marker
+	sos := segment(markerSOS, 1, 1, 0, 0, 63, 0)
+	scan := []byte{0x12, 0xFF, 0x00, 0x34, 0xFF, markerRST0 + 1, 0x56}
+	data := jpegBytes(segment(markerDQT), sos, scan, segment(markerDHT), sos, scan, []byte{0xFF, markerEOI})
+
+	var markers []byte
+	end, err := WalkImage(append(data, "appended"...), func(seg Segment) bool {
+		markers = append(markers, seg.Marker)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{markerDQT, markerSOS, markerDHT, markerSOS, markerEOI}
+	if !bytes.Equal(markers, want) {
+		t.Errorf("visited % X, want % X", markers, want)
+	}
+	if end != len(data) {
+		t.Errorf("end %d, want %d", end, len(data))
+	}
+
+	// Without EOI the walk runs off the end of the last scan
+	end, err = WalkImage(data[:len(data)-2], func(Segment) bool { return true })
+	if !errors.Is(err, ErrTruncated) || end != 0 {
+		t.Errorf("got end %d, error %v, want 0 and ErrTruncated", end, err)
+	}
+}
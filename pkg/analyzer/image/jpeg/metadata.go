@@ -0,0 +1,311 @@
+package jpeg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"DeSteGo/pkg/analyzer/stats"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the JPEG metadata extractor. ExtractJPEGMetadata walks the
+marker segments of the file, including the tables and comments between the
+scans of a progressive image, and records the tables, frame header, comments,
+every application (APPn) segment and where EOI ends the image. Application segments are
+a popular hiding place because decoders skip the ones they do not understand,
+so each is tagged with its identifier, entropy and whether it looks like base64.
+*/
+
+// Application segment thresholds
+const (
+	appSegmentLargeSize   = 4096 // Unknown segments above this size are reported
+	appSegmentMinAnalysis = 256  // Segments shorter than this are too small to judge by entropy
+	appSegmentHighEntropy = 7.0  // Bits per byte above which a segment looks encrypted or compressed
+)
+
+// knownAppIdentifiers maps the identifier prefixes of common application
+// segments to a description
+var knownAppIdentifiers = []struct {
+	marker byte
+	prefix string
+	name   string
+}{
+	{0xE0, "JFIF\x00", "JFIF"},
+	{0xE0, "JFXX\x00", "JFIF extension"},
+	{0xE1, "Exif\x00\x00", "EXIF"},
+	{0xE1, "http://ns.adobe.com/xap/1.0/\x00", "XMP"},
+	{0xE1, "http://ns.adobe.com/xmp/extension/\x00", "Extended XMP"},
+	{0xE2, "ICC_PROFILE\x00", "ICC profile"},
+	{0xE2, "FPXR\x00", "FlashPix"},
+	{0xE2, "MPF\x00", "Multi-Picture Format"},
+	{0xEC, "Ducky", "Photoshop Ducky"},
+	{0xED, "Photoshop 3.0\x00", "Photoshop IRB"},
+	{0xEE, "Adobe", "Adobe"},
+}
+
+// AppSegment describes one APPn segment
+type AppSegment struct {
+	Marker     byte    // 0xE0-0xEF
+	Offset     int     // Position of the marker in the file
+	Length     int     // Payload length (excluding the marker and length field)
+	Identifier string  // Recognised identifier, empty when unknown
+	Entropy    float64 // Shannon entropy of the payload in bits per byte
+	Base64     bool    // Whether the payload is almost entirely base64 text
+	Payload    []byte  // Segment payload including the identifier
+}
+
+// Name returns the APPn name of the segment
+func (s AppSegment) Name() string {
+	return fmt.Sprintf("APP%d", s.Marker-markerAPP0)
+}
+
+// JPEGMetadata holds the marker segments of a JPEG file, including the ones
+// between the scans of a progressive image
+type JPEGMetadata struct {
+	QuantTables  int                // Number of DQT segments
+	Quant        map[int][64]uint16 // Quantization tables by ID, in zigzag order
+	HuffTables   int                // Number of DHT segments
+	Frame        byte               // SOF marker, 0 when none was found
+	Components   int                // Components declared by the frame header
+	Comments     [][]byte           // COM segment payloads
+	AppSegments  []AppSegment       // Every APPn segment in file order
+	ScanOffset   int                // Position of the first SOS marker
+	Scans        int                // Number of SOS segments
+	End          int                // Offset just past EOI, 0 when the file ends before it
+	Restart      int                // Restart interval in MCUs from DRI, 0 when there is none
+	OtherMarkers []byte             // Markers with no dedicated handling
+}
+
+// ExtractJPEGMetadata walks the marker segments of a JPEG file through every
+// scan to EOI. On a malformed file it returns the segments read so far together
+// with the error from WalkImage, or the first ErrMalformedSegment of a table.
+func ExtractJPEGMetadata(data []byte) (*JPEGMetadata, error) {
+	meta := &JPEGMetadata{Quant: make(map[int][64]uint16)}
+	var segErr error
+	end, err := WalkImage(data, func(seg Segment) bool {
+		marker := seg.Marker
+		switch {
+		case marker == markerDQT:
+			meta.QuantTables++
+			// The segment length is sound, so keep walking and report the first
+			// malformed table at the end
+			if err := parseDQT(seg, meta.Quant); err != nil && segErr == nil {
+				segErr = err
+			}
+		case marker == markerDHT:
+			meta.HuffTables++
+		case marker >= markerSOF0 && marker <= 0xCF && marker != 0xC8 && marker != 0xCC:
+			// SOF0-SOF15 except the JPG extension and arithmetic conditioning markers
+			meta.Frame = marker
+			if len(seg.Payload) >= 6 {
+				meta.Components = int(seg.Payload[5])
+			}
+		case marker == markerCOM:
+			meta.Comments = append(meta.Comments, seg.Payload)
+		case marker >= markerAPP0 && marker <= 0xEF:
+			meta.AppSegments = append(meta.AppSegments, newAppSegment(marker, seg.Offset, seg.Payload))
+		case marker == markerDRI:
+			if len(seg.Payload) != 2 {
+				if segErr == nil {
+					segErr = fmt.Errorf("%w: DRI at offset %d has %d bytes", ErrMalformedSegment, seg.Offset, len(seg.Payload))
+				}
+			} else {
+				meta.Restart = int(seg.Payload[0])<<8 | int(seg.Payload[1])
+			}
+		case marker == markerSOS:
+			if meta.Scans == 0 {
+				meta.ScanOffset = seg.Offset
+			}
+			meta.Scans++
+		case marker == markerEOI:
+		default:
+			meta.OtherMarkers = append(meta.OtherMarkers, marker)
+		}
+		return true
+	})
+	if errors.Is(err, ErrNotJPEG) {
+		return nil, err
+	}
+	meta.End = end
+	if err == nil {
+		err = segErr
+	}
+	return meta, err
+}
+
+// AppendedBytes returns how many bytes of a file of the given size follow EOI,
+// 0 when the walk did not reach EOI
+func (m *JPEGMetadata) AppendedBytes(size int) int {
+	if m.End == 0 || m.End >= size {
+		return 0
+	}
+	return size - m.End
+}
+
+// ColorModel returns what the components hold, from their count and the
+// transform of the Adobe segment
+func (m *JPEGMetadata) ColorModel() ColorModel {
+	transform := adobeTransformUnknown
+	for _, seg := range m.AppSegments {
+		if seg.Marker == markerAPP14 {
+			if t := adobeTransform(seg.Payload); t != adobeTransformUnknown {
+				transform = t
+			}
+		}
+	}
+	return colorModelOf(m.Components, transform)
+}
+
+// parseDQT reads the quantization tables of a DQT segment into tables. Each
+// table is checked against the bytes left in the segment before it is read.
+func parseDQT(seg Segment, tables map[int][64]uint16) error {
+	payload := seg.Payload
+	if len(payload) == 0 {
+		return fmt.Errorf("%w: empty DQT segment at offset %d", ErrMalformedSegment, seg.Offset)
+	}
+	for len(payload) > 0 {
+		precision, id := payload[0]>>4, int(payload[0]&0x0F)
+		if precision > 1 || id > 3 {
+			return fmt.Errorf("%w: DQT at offset %d has precision %d, table ID %d", ErrMalformedSegment, seg.Offset, precision, id)
+		}
+		size := 1 + 64*(int(precision)+1)
+		if len(payload) < size {
+			return fmt.Errorf("%w: DQT at offset %d needs %d bytes for table %d, %d remain",
+				ErrMalformedSegment, seg.Offset, size, id, len(payload))
+		}
+
+		var table [64]uint16
+		for i := range table {
+			if precision == 0 {
+				table[i] = uint16(payload[1+i])
+			} else {
+				table[i] = uint16(payload[1+2*i])<<8 | uint16(payload[2+2*i])
+			}
+			if table[i] == 0 {
+				return fmt.Errorf("%w: DQT at offset %d has a zero entry in table %d", ErrMalformedSegment, seg.Offset, id)
+			}
+		}
+		tables[id] = table
+		payload = payload[size:]
+	}
+	return nil
+}
+
+// ReadJPEGMetadata reads a JPEG file and extracts its metadata
+func ReadJPEGMetadata(filePath string) (*JPEGMetadata, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return ExtractJPEGMetadata(data)
+}
+
+// newAppSegment identifies and measures an application segment payload
+func newAppSegment(marker byte, offset int, payload []byte) AppSegment {
+	seg := AppSegment{
+		Marker:  marker,
+		Offset:  offset,
+		Length:  len(payload),
+		Entropy: stats.ComputeEntropy(payload),
+		Base64:  isBase64Text(payload),
+		Payload: payload,
+	}
+	for _, known := range knownAppIdentifiers {
+		if known.marker == marker && bytes.HasPrefix(payload, []byte(known.prefix)) {
+			seg.Identifier = known.name
+			break
+		}
+	}
+	return seg
+}
+
+// Suspicious returns why an application segment looks like a carrier, or an
+// empty string when it looks normal
+func (s AppSegment) Suspicious() string {
+	switch s.Identifier {
+	case "JFIF":
+		// 14 header bytes plus an optional RGB thumbnail of the declared size
+		if s.Length >= 14 {
+			expected := 14 + 3*int(s.Payload[12])*int(s.Payload[13])
+			if s.Length > expected {
+				return fmt.Sprintf("%d bytes beyond the declared JFIF thumbnail", s.Length-expected)
+			}
+		}
+		return ""
+	case "":
+		// Unknown segments are judged by size and content
+	default:
+		return ""
+	}
+
+	var reasons []string
+	if s.Length > appSegmentLargeSize {
+		reasons = append(reasons, fmt.Sprintf("unrecognised segment of %d bytes", s.Length))
+	}
+	if s.Length >= appSegmentMinAnalysis && s.Entropy > appSegmentHighEntropy {
+		reasons = append(reasons, fmt.Sprintf("entropy %.2f bits/byte", s.Entropy))
+	}
+	if s.Base64 {
+		reasons = append(reasons, "base64-encoded text")
+	}
+	if len(reasons) == 0 {
+		return ""
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// analyzeAppSegments reports application segments that may carry hidden data
+func analyzeAppSegments(meta *JPEGMetadata, result *models.AnalysisResult) {
+	var segments []map[string]interface{}
+	for _, seg := range meta.AppSegments {
+		segments = append(segments, map[string]interface{}{
+			"marker":     seg.Name(),
+			"offset":     seg.Offset,
+			"length":     seg.Length,
+			"identifier": seg.Identifier,
+			"entropy":    seg.Entropy,
+			"base64":     seg.Base64,
+		})
+
+		reason := seg.Suspicious()
+		if reason == "" {
+			continue
+		}
+		confidence := 0.6
+		if seg.Entropy > appSegmentHighEntropy || seg.Base64 {
+			confidence = 0.8
+		}
+		result.AddFinding(fmt.Sprintf("Suspicious %s segment at offset %d", seg.Name(), seg.Offset), confidence, reason)
+		if confidence > result.DetectionScore {
+			result.DetectionScore = confidence
+		}
+		result.Recommendations = append(result.Recommendations,
+			fmt.Sprintf("Dump the %s segment payload (%d bytes at offset %d) and inspect it", seg.Name(), seg.Length, seg.Offset+4))
+	}
+
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	result.Details["app_segments"] = segments
+}
+
+// isBase64Text reports whether data is long enough and made almost entirely of
+// base64 alphabet characters
+func isBase64Text(data []byte) bool {
+	if len(data) < 64 {
+		return false
+	}
+	valid := 0
+	for _, b := range data {
+		switch {
+		case b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z', b >= '0' && b <= '9',
+			b == '+', b == '/', b == '=', b == '\r', b == '\n':
+			valid++
+		}
+	}
+	return float64(valid)/float64(len(data)) > 0.98
+}
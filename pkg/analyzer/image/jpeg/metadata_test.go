@@ -0,0 +1,328 @@
+package jpeg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/models"
+)
+
+// dqt returns a DQT payload holding one 8-bit table with every entry set to q
+func dqt(id, q byte) []byte {
+	return append([]byte{id}, bytes.Repeat([]byte{q}, 64)...)
+}
+
+func TestExtractJPEGMetadataDQT(t *testing.T) {
+	eoi := []byte{0xFF, markerEOI}
+	tests := []struct {
+		name   string
+		tables []byte
+		ids    int // Tables read
+		err    error
+	}{
+		{"one table", dqt(0, 16), 1, nil},
+		{"two tables", append(dqt(0, 16), dqt(1, 20)...), 2, nil},
+		{"16-bit table", append([]byte{0x12}, bytes.Repeat([]byte{0, 3}, 64)...), 1, nil},
+		{"empty", nil, 0, ErrMalformedSegment},
+		{"short table", dqt(0, 16)[:40], 0, ErrMalformedSegment},
+		{"second table short", append(dqt(0, 16), dqt(1, 20)[:10]...), 1, ErrMalformedSegment},
+		{"bad precision", append([]byte{0x20}, dqt(0, 16)[1:]...), 0, ErrMalformedSegment},
+		{"bad table ID", dqt(7, 16), 0, ErrMalformedSegment},
+		{"zero entry", dqt(0, 0), 0, ErrMalformedSegment},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A COM segment after the bad table shows the walk carries on
+			data := jpegBytes(segment(markerDQT, tt.tables...), segment(markerCOM, 'x'), eoi)
+			meta, err := ExtractJPEGMetadata(data)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+			if meta == nil {
+				t.Fatal("no metadata")
+			}
+			if len(meta.Quant) != tt.ids {
+				t.Errorf("read %d tables, want %d", len(meta.Quant), tt.ids)
+			}
+			if meta.QuantTables != 1 || len(meta.Comments) != 1 {
+				t.Errorf("got %d DQT and %d COM segments, want 1 of each", meta.QuantTables, len(meta.Comments))
+			}
+		})
+	}
+}
+
+func TestExtractJPEGMetadataDRI(t *testing.T) {
+	eoi := []byte{0xFF, markerEOI}
+	meta, err := ExtractJPEGMetadata(jpegBytes(segment(markerDRI, 0, 8), eoi))
+	if err != nil || meta.Restart != 8 {
+		t.Errorf("got restart interval %d, error %v, want 8 and no error", meta.Restart, err)
+	}
+	if _, err := ExtractJPEGMetadata(jpegBytes(segment(markerDRI, 8), eoi)); !errors.Is(err, ErrMalformedSegment) {
+		t.Errorf("got error %v for a 1-byte DRI, want ErrMalformedSegment", err)
+	}
+}
+
+// isWalkError reports whether err is nil or wraps one of the marker errors
+func isWalkError(err error) bool {
+	for _, target := range []error{ErrNotJPEG, ErrTruncated, ErrSegmentLength, ErrExpectedMarker, ErrMalformedSegment} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return err == nil
+}
+
+func TestExtractJPEGMetadataCorrupted(t *testing.T) {
+	valid, err := fixtures.Load("clean.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ExtractJPEGMetadata(valid); err != nil {
+		t.Fatalf("failed to read the valid file: %v", err)
+	}
+
+	check := func(t *testing.T, data []byte) {
+		t.Helper()
+		meta, err := ExtractJPEGMetadata(data)
+		if meta == nil && err == nil {
+			t.Fatal("no metadata and no error")
+		}
+		if !isWalkError(err) {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		// Every cut through the header, then a sample through the scan
+		for n := 0; n < len(valid); n++ {
+			if n > 1024 && n%97 != 0 {
+				continue
+			}
+			meta, err := ExtractJPEGMetadata(valid[:n])
+			if err == nil {
+				t.Fatalf("no error for the first %d bytes", n)
+			}
+			if !isWalkError(err) {
+				t.Fatalf("unexpected error %v for the first %d bytes", err, n)
+			}
+			if n >= 2 && meta == nil && !errors.Is(err, ErrNotJPEG) {
+				t.Fatalf("no metadata for the first %d bytes", n)
+			}
+		}
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(1))
+		header := bytes.Index(valid, []byte{0xFF, markerSOS}) + 16
+		for i := 0; i < 2000; i++ {
+			data := append([]byte(nil), valid...)
+			for j := rng.Intn(4); j >= 0; j-- {
+				data[rng.Intn(header)] = byte(rng.Intn(256))
+			}
+			check(t, data)
+		}
+	})
+
+	t.Run("seeds", func(t *testing.T) {
+		for _, seed := range seedJPEGs(t) {
+			check(t, seed)
+		}
+	})
+}
+
+func TestAnalyzeMissingEOI(t *testing.T) {
+	valid, err := fixtures.Load("clean.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "no_eoi.jpg")
+	if err := os.WriteFile(path, valid[:len(valid)-2], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewJPEGAnalyzer().Analyze(path, analyzer.AnalysisOptions{})
+	if err != nil {
+		t.Fatalf("failed to analyze: %v", err)
+	}
+	for _, finding := range result.Findings {
+		if strings.HasPrefix(finding.Description, "Malformed JPEG") && strings.Contains(finding.Details, "no EOI marker") {
+			return
+		}
+	}
+	t.Errorf("no malformed structure finding for a missing EOI in %+v", result.Findings)
+}
+
+func TestAnalyzeAppSegments(t *testing.T) {
+	clean, err := fixtures.Load("clean.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := fixtures.RandomPayload(8000, 1)
+
+	tests := []struct {
+		name    string
+		marker  byte
+		payload []byte
+		reason  string  // Start of the finding's details, empty for no finding
+		score   float64 // Expected detection score
+	}{
+		{"large random APP12", 0xEC, random, "unrecognised segment of 8000 bytes, entropy", 0.8},
+		{"small random APP12", 0xEC, random[:128], "", 0},
+		{"large text APP12", 0xEC, []byte(strings.Repeat("lorem ipsum dolor ", 300)), "unrecognised segment of 5400 bytes", 0.6},
+		{"base64 APP15", 0xEF, []byte(base64.StdEncoding.EncodeToString(random[:300])), "base64-encoded text", 0.8},
+		{"Photoshop Ducky APP12", 0xEC, append([]byte("Ducky"), random...), "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := jpegBytes(segment(tt.marker, tt.payload...), clean[2:])
+			meta, err := ExtractJPEGMetadata(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := &models.AnalysisResult{}
+			analyzeAppSegments(meta, result)
+
+			var found []models.Finding
+			for _, f := range result.Findings {
+				if strings.HasPrefix(f.Description, "Suspicious APP") {
+					found = append(found, f)
+				}
+			}
+			if tt.reason == "" {
+				if len(found) > 0 {
+					t.Errorf("got findings %+v, want none", found)
+				}
+				return
+			}
+			name := fmt.Sprintf("Suspicious APP%d segment at offset 2", tt.marker-markerAPP0)
+			if len(found) != 1 || found[0].Description != name || !strings.HasPrefix(found[0].Details, tt.reason) {
+				t.Fatalf("got findings %+v, want %q: %s...", found, name, tt.reason)
+			}
+			if result.DetectionScore != tt.score {
+				t.Errorf("got score %.1f, want %.1f", result.DetectionScore, tt.score)
+			}
+		})
+	}
+}
+
+func TestAnalyzeQuantTables(t *testing.T) {
+	encode := func(quality int) []byte {
+		data, err := fixtures.EncodeJPEG(fixtures.Carrier(64, 64, 4), quality)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	// The quality-75 tables with two luminance entries hand-edited
+	luma, chroma := scaledTable(&standardLuminance, 75), scaledTable(&standardChrominance, 75)
+	luma[10], luma[40] = 1, 90
+	var tables []byte
+	for id, table := range [][64]uint16{luma, chroma} {
+		tables = append(tables, byte(id))
+		for _, v := range table {
+			tables = append(tables, byte(v))
+		}
+	}
+	modified := jpegBytes(segment(markerDQT, tables...), []byte{0xFF, markerEOI})
+
+	tests := []struct {
+		name    string
+		data    []byte
+		quality int
+		flagged bool
+	}{
+		{"quality 50", encode(50), 50, false},
+		{"quality 20", encode(20), 20, false},
+		{"quality 95", encode(95), 95, false},
+		{"hand-modified table", modified, 75, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := ExtractJPEGMetadata(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := &models.AnalysisResult{}
+			analyzeQuantTables(meta, result)
+
+			if result.Details["jpeg_quality"] != tt.quality {
+				t.Errorf("got quality %v, want %d", result.Details["jpeg_quality"], tt.quality)
+			}
+			flagged := len(result.Findings) == 1 && result.Findings[0].Description == "Quantization tables match no standard quality"
+			if flagged != tt.flagged || len(result.Findings) > 1 {
+				t.Errorf("got findings %v, want flagged %v", result.Findings, tt.flagged)
+			}
+		})
+	}
+}
+
+func TestAnalyzeProgressiveAppended(t *testing.T) {
+	// A 16x8 grayscale progressive JPEG of a DC scan and an AC scan, each with
+	// a restart marker between its two blocks. Every block of a scan is a
+	// single 0 bit, padded with 1s at each restart.
+	scan := []byte{0x7F, 0xFF, markerRST0, 0x7F}
+	progressive := jpegBytes(
+		flatTables(),
+		segment(0xC2, 8, 0, 8, 0, 16, 1, 1, 0x11, 0),
+		segment(markerDRI, 0, 1),
+		segment(markerSOS, 1, 1, 0x00, 0, 0, 0), scan,
+		// Progressive encoders write segments between the scans
+		segment(markerCOM, []byte("between the scans")...),
+		segment(markerSOS, 1, 1, 0x00, 1, 63, 0), scan,
+		[]byte{0xFF, markerEOI})
+
+	tests := []struct {
+		name     string
+		appended string
+	}{
+		{"clean", ""},
+		{"text", "appended after the last scan\n"},
+		// A trailer with its own end marker must not be taken for the end of the image
+		{"JPEG markers", "\xFF\xD8 trailer \xFF\xD9 more trailer\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append(bytes.Clone(progressive), tt.appended...)
+			meta, err := ExtractJPEGMetadata(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if meta.Scans != 2 || len(meta.Comments) != 1 || meta.AppendedBytes(len(data)) != len(tt.appended) {
+				t.Errorf("got %d scans, %d comments and %d appended bytes, want 2, 1 and %d",
+					meta.Scans, len(meta.Comments), meta.AppendedBytes(len(data)), len(tt.appended))
+			}
+
+			path := filepath.Join(t.TempDir(), "progressive.jpg")
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			result, err := NewJPEGAnalyzer().Analyze(path, analyzer.AnalysisOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var appended *models.Finding
+			for i, finding := range result.Findings {
+				if finding.Description == "Found appended data after EOF" {
+					appended = &result.Findings[i]
+				}
+			}
+			switch {
+			case tt.appended == "" && appended != nil:
+				t.Errorf("got appended data finding %q for a clean file", appended.Details)
+			case tt.appended != "" && appended == nil:
+				t.Errorf("no appended data finding in %+v", result.Findings)
+			case appended != nil && !strings.HasPrefix(appended.Details, fmt.Sprintf("Found %d bytes of appended data", len(tt.appended))):
+				t.Errorf("got %q, want %d appended bytes", appended.Details, len(tt.appended))
+			}
+		})
+	}
+}
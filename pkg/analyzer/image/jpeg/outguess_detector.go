@@ -0,0 +1,88 @@
+package jpeg
+
+import (
+	"fmt"
+	"math"
+
+	"DeSteGo/pkg/analyzer/stats"
+)
+
+// minPairSamples is the number of coefficients a value pair needs before it is trusted
+const minPairSamples = 20
+
+// detectOutGuess estimates the probability that OutGuess-style LSB embedding was
+// applied to the DCT coefficients.
+//
+// OutGuess (like JSteg) overwrites the LSB of every coefficient outside {0, 1},
+// which drives the counts of each value pair (2k, 2k+1) towards each other. A
+// natural histogram decays smoothly with |v|, so the difference inside a pair is
+// about the same as the difference between neighbouring pairs. After embedding the
+// in-pair differences collapse while the between-pair steps remain, leaving a
+// "staircase" histogram. Both that step ratio and a pair-of-values chi-square test
+// are combined into the returned probability.
+func detectOutGuess(dct *JPEGDCTData) (float64, string) {
+	if dct == nil || len(dct.Blocks) == 0 {
+		return 0, "no DCT coefficients available"
+	}
+
+	hist := analyzeHistogram(dct, -1).all
+
+	// Walk each side of the histogram in order of increasing magnitude. LSB
+	// replacement swaps values inside the pairs {2,3}, {4,5}, ... and
+	// {-1,-2}, {-3,-4}, ...; 0 and 1 are never used for embedding.
+	var withinSum, betweenSum, chiSquare float64
+	pairs, dof := 0, 0
+	for _, seq := range []struct{ start, step int }{{2, 1}, {-1, -1}} {
+		for i := 0; i < 62; i += 2 {
+			a := seq.start + i*seq.step
+			b := a + seq.step
+			next := b + seq.step
+			na, nb, nn := float64(hist[a]), float64(hist[b]), float64(hist[next])
+			if na+nb < minPairSamples {
+				break
+			}
+
+			// Chi-square contribution of this pair against the equalized expectation
+			expected := (na + nb) / 2
+			chiSquare += (na - expected) * (na - expected) / expected
+			dof++
+
+			if nb+nn == 0 {
+				continue
+			}
+			withinSum += math.Abs(na-nb) / (na + nb)
+			betweenSum += math.Abs(nb-nn) / (nb + nn)
+			pairs++
+		}
+	}
+
+	if pairs == 0 || dof == 0 {
+		return 0, "not enough non-zero AC coefficients for OutGuess analysis"
+	}
+
+	// Step ratio: ~1 for a natural histogram, ~0 when pairs are equalized
+	stepRatio := 1.0
+	if betweenSum > 0 {
+		stepRatio = withinSum / betweenSum
+	}
+	stepScore := clamp01((0.8 - stepRatio) / 0.6)
+
+	pValue := stats.ChiSquarePValue(chiSquare, dof)
+
+	probability := clamp01(0.5*stepScore + 0.5*pValue)
+	details := fmt.Sprintf("pair step ratio=%.3f over %d pairs, pair chi-square=%.2f (dof=%d, p=%.3f)",
+		stepRatio, pairs, chiSquare, dof, pValue)
+
+	return probability, details
+}
+
+// clamp01 limits v to the range [0, 1]
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
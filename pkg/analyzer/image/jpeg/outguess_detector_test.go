@@ -0,0 +1,64 @@
+package jpeg
+
+import (
+	"math/rand"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+)
+
+// coefficientChange alters the AC coefficient at zig-zag position k of a block
+// the way an embedder would
+type coefficientChange func(block *DCTCoefficientBlock, k int, rng *rand.Rand)
+
+// loadCoefficients parses the fixture called name and applies change, when set,
+// to every AC coefficient with a seeded random source
+func loadCoefficients(t *testing.T, name string, change coefficientChange) *JPEGDCTData {
+	t.Helper()
+	data, err := fixtures.Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dct, err := ParseJPEGDCTCoefficients(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change != nil {
+		rng := rand.New(rand.NewSource(1))
+		for i := range dct.Blocks {
+			for k := 1; k < 64; k++ {
+				change(&dct.Blocks[i], k, rng)
+			}
+		}
+	}
+	return dct
+}
+
+// replaceLSB sets the LSB of every coefficient other than 0 and 1 to a random
+// bit, which equalizes the value pairs the way embedding an encrypted payload
+// in all of them does
+func replaceLSB(block *DCTCoefficientBlock, k int, rng *rand.Rand) {
+	if v := block.Coefficients[k]; v != 0 && v != 1 {
+		block.Coefficients[k] = v&^1 | int16(rng.Intn(2))
+	}
+}
+
+func TestDetectOutGuess(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   coefficientChange
+		embedded bool
+	}{
+		{"clean", nil, false},
+		{"pairs equalized", replaceLSB, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dct := loadCoefficients(t, "textured.jpg", tt.change)
+			probability, details := detectOutGuess(dct)
+			if got := probability > 0.5; got != tt.embedded {
+				t.Errorf("probability %.2f (%s), want embedded = %v", probability, details, tt.embedded)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package jpeg
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the JPEG quality estimator. Most encoders derive their
+quantization tables by scaling the example tables of the JPEG standard (Annex
+K) with the IJG quality formula, so a recompressed low-quality JPEG has tables
+far from the quality-75 defaults without anything being wrong with it.
+EstimateQuality finds the quality whose scaled table is closest to a file's
+table. Only tables that match no quality are reported as non-standard, since
+some tools hand-tune tables to make room for or hide embedded data.
+*/
+
+// standardEntryTolerance is the relative difference each entry may have from the
+// closest scaled standard table for the table to count as standard. Entries may
+// also always differ by 1, which absorbs rounding differences between encoders.
+const standardEntryTolerance = 0.05
+
+// standardLuminance and standardChrominance are the Annex K example tables in
+// natural (row-major) order
+var standardLuminance = [64]uint16{
+	16, 11, 10, 16, 24, 40, 51, 61,
+	12, 12, 14, 19, 26, 58, 60, 55,
+	14, 13, 16, 24, 40, 57, 69, 56,
+	14, 17, 22, 29, 51, 87, 80, 62,
+	18, 22, 37, 56, 68, 109, 103, 77,
+	24, 35, 55, 64, 81, 104, 113, 92,
+	49, 64, 78, 87, 103, 121, 120, 101,
+	72, 92, 95, 98, 112, 100, 103, 99,
+}
+
+var standardChrominance = [64]uint16{
+	17, 18, 24, 47, 99, 99, 99, 99,
+	18, 21, 26, 66, 99, 99, 99, 99,
+	24, 26, 56, 99, 99, 99, 99, 99,
+	47, 66, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+}
+
+// QualityEstimate is the result of matching a quantization table against the
+// scaled standard tables
+type QualityEstimate struct {
+	Quality   int     // IJG quality (1-100) of the closest standard table
+	Deviation float64 // Mean relative difference from that table
+	Standard  bool    // Whether every entry is within rounding tolerance of that table
+}
+
+// scaledTable returns the standard table scaled to an IJG quality, in zigzag
+// order to match the tables stored in DQT segments
+func scaledTable(base *[64]uint16, quality int) [64]uint16 {
+	scale := 200 - 2*quality
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+	var table [64]uint16
+	for k := 0; k < 64; k++ {
+		v := (int(base[zigzag[k]])*scale + 50) / 100
+		if v < 1 {
+			v = 1
+		}
+		if v > 255 {
+			v = 255
+		}
+		table[k] = uint16(v)
+	}
+	return table
+}
+
+// EstimateQuality finds the IJG quality whose scaled luminance (or chrominance)
+// table is closest to table, which must be in zigzag order
+func EstimateQuality(table [64]uint16, chrominance bool) QualityEstimate {
+	base := &standardLuminance
+	if chrominance {
+		base = &standardChrominance
+	}
+
+	best := QualityEstimate{Deviation: math.Inf(1)}
+	var closest [64]uint16
+	for quality := 1; quality <= 100; quality++ {
+		scaled := scaledTable(base, quality)
+		deviation := 0.0
+		for k := 0; k < 64; k++ {
+			deviation += math.Abs(float64(table[k])-float64(scaled[k])) / float64(scaled[k])
+		}
+		deviation /= 64
+		if deviation < best.Deviation {
+			best = QualityEstimate{Quality: quality, Deviation: deviation}
+			closest = scaled
+		}
+	}
+
+	// A hand-edited table can be close on average yet far off in a few entries
+	best.Standard = true
+	for k := 0; k < 64; k++ {
+		diff := math.Abs(float64(table[k]) - float64(closest[k]))
+		if diff > 1 && diff > standardEntryTolerance*float64(closest[k]) {
+			best.Standard = false
+			break
+		}
+	}
+	return best
+}
+
+// analyzeQuantTables records the estimated quality and reports tables that do
+// not match any scaled standard table
+func analyzeQuantTables(meta *JPEGMetadata, result *models.AnalysisResult) {
+	luma, ok := meta.Quant[0]
+	if !ok {
+		return
+	}
+	estimate := EstimateQuality(luma, false)
+
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	result.Details["jpeg_quality"] = estimate.Quality
+	result.Details["quant_table_deviation"] = estimate.Deviation
+
+	var nonStandard []string
+	if !estimate.Standard {
+		nonStandard = append(nonStandard, fmt.Sprintf("luminance table differs %.0f%% from quality %d", estimate.Deviation*100, estimate.Quality))
+	}
+	if chroma, ok := meta.Quant[1]; ok {
+		chromaEstimate := EstimateQuality(chroma, true)
+		if !chromaEstimate.Standard {
+			nonStandard = append(nonStandard, fmt.Sprintf("chrominance table differs %.0f%% from quality %d", chromaEstimate.Deviation*100, chromaEstimate.Quality))
+		}
+	}
+
+	if len(nonStandard) == 0 {
+		return
+	}
+	result.AddFinding("Quantization tables match no standard quality", 0.4,
+		fmt.Sprintf("%s; camera and editor tables can also cause this", strings.Join(nonStandard, "; ")))
+}
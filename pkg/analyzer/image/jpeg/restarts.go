@@ -0,0 +1,117 @@
+package jpeg
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the restart marker analysis. A DRI segment asks the encoder
+to end the entropy-coded data every N MCUs with RST0 to RST7 in turn. Decoders
+look for the next marker after an interval and skip whatever lies in front of
+it, so bytes placed there are invisible. Markers that are missing, out of turn
+or present without a DRI segment show that the scan was edited after encoding;
+Go's decoder rejects out-of-turn markers, which is why these files can also be
+reported when the image itself cannot be decoded.
+*/
+
+// analyzeRestarts reports tampered restart markers and data hidden between
+// restart intervals
+func analyzeRestarts(dctData *JPEGDCTData, filePath string, options analyzer.AnalysisOptions, result *models.AnalysisResult) {
+	restarts := dctData.Restarts
+	if dctData.RestartInterval == 0 && restarts.Stray == 0 {
+		return
+	}
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	result.Details["restart_interval"] = dctData.RestartInterval
+	result.Details["restart_markers"] = map[string]int{
+		"expected":     restarts.Expected,
+		"found":        restarts.Found,
+		"missing":      restarts.Missing,
+		"out_of_order": restarts.OutOfOrder,
+		"stray":        restarts.Stray,
+		"gap_bytes":    len(restarts.Gaps),
+	}
+
+	var problems []string
+	if restarts.Missing > 0 {
+		problems = append(problems, fmt.Sprintf("%d markers missing", restarts.Missing))
+	}
+	if restarts.OutOfOrder > 0 {
+		problems = append(problems, fmt.Sprintf("%d markers out of turn", restarts.OutOfOrder))
+	}
+	switch {
+	case restarts.Stray > 0 && dctData.RestartInterval == 0:
+		problems = append(problems, fmt.Sprintf("%d markers in a file without a DRI segment", restarts.Stray))
+	case restarts.Stray > 0:
+		problems = append(problems, fmt.Sprintf("%d markers not called for by the interval of %d MCUs", restarts.Stray, dctData.RestartInterval))
+	}
+	if len(problems) > 0 {
+		result.AddFinding("Restart markers do not match the declared restart interval", 0.6,
+			fmt.Sprintf("%s; the interval calls for %d", strings.Join(problems, ", "), restarts.Expected))
+		if result.DetectionScore < 0.6 {
+			result.DetectionScore = 0.6
+		}
+	}
+
+	if len(restarts.Gaps) == 0 {
+		if len(problems) == 0 {
+			result.AddCheck(fmt.Sprintf("restart markers follow the interval of %d MCUs", dctData.RestartInterval))
+		}
+		return
+	}
+
+	result.AddFinding("Data hidden between restart intervals", 0.85,
+		fmt.Sprintf("%d bytes sit between the end of an interval and its RST marker: %q",
+			len(restarts.Gaps), truncateText(restarts.Gaps, 60)))
+	if result.DetectionScore < 0.85 {
+		result.DetectionScore = 0.85
+		result.PossibleAlgorithm = "Restart Interval Injection"
+	}
+
+	if options.Extract && options.OutputDir != "" {
+		base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+		outputPath := filepath.Join(options.OutputDir, base+"_restart_gaps.bin")
+		if outputPath, err := filehandler.SaveFileUnique(restarts.Gaps, outputPath); err == nil {
+			result.Details["restart_gaps_file"] = outputPath
+			result.Recommendations = append(result.Recommendations,
+				fmt.Sprintf("Inspect the bytes found between restart intervals: %s", outputPath))
+		}
+	}
+}
+
+// truncateText returns data as a string of at most n bytes, marking a cut with "..."
+func truncateText(data []byte, n int) string {
+	if len(data) <= n {
+		return string(data)
+	}
+	return string(data[:n]) + "..."
+}
+
+// undecodableResult analyzes the restart and EOI markers of a JPEG that Go's
+// decoder rejected, which an EOI inserted into the scan data also makes it do.
+// It returns nil when they show nothing unusual.
+func undecodableResult(data []byte, decodeErr error, filePath string, options analyzer.AnalysisOptions) *models.AnalysisResult {
+	result := &models.AnalysisResult{
+		FileType:        "jpeg",
+		Filename:        filePath,
+		Findings:        []models.Finding{},
+		Recommendations: []string{},
+	}
+	if dctData, err := ParseJPEGDCTCoefficients(data); err == nil {
+		analyzeRestarts(dctData, filePath, options, result)
+	}
+	analyzeEOIMarkers(data, result)
+	if len(result.Findings) == 0 {
+		return nil
+	}
+	result.AddFinding("Image could not be decoded, only its restart and EOI markers were analyzed", 0.3, decodeErr.Error())
+	return result
+}
@@ -0,0 +1,205 @@
+package jpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+)
+
+/*
+This file contains the lenient decoding of JPEGs that Go's decoder rejects. The
+decoder stops at the first marker inside the scan data that is neither a
+stuffed byte nor a restart marker, so an EOI or any other marker inserted into
+the scan keeps the whole image from being analyzed, and it gives up on scan
+data cut off at the end of the file. Resync rebuilds the marker structure the
+decoder expects: markers inside a scan that do not start a well-formed segment
+are removed, restart markers are renumbered in turn, and scan data cut off at
+the end of the file is filled with zero bytes and closed with EOI, which
+decodes the missing blocks as flat gray rather than failing.
+*/
+
+// scanPaddingPerBlock is the number of zero bytes added per 8x8 block for scan
+// data cut off at the end of the file. Zero bits decode as the shortest codes
+// of the usual Huffman tables, which take up to about three bits per
+// coefficient.
+const scanPaddingPerBlock = 32
+
+// maxScanPadding caps the zero bytes added to a cut-off scan
+const maxScanPadding = 16 << 20
+
+// DecodeLenient decodes a JPEG, resynchronizing its markers when it does not
+// decode as it is. It returns the repairs that were needed, none when the file
+// decoded as it is. When the repaired file does not decode either, the error
+// is that of the file as it is.
+func DecodeLenient(data []byte) (image.Image, []string, error) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err == nil {
+		return img, nil, nil
+	}
+	repaired, repairs := Resync(data)
+	if len(repairs) == 0 {
+		return nil, nil, err
+	}
+	img, repairErr := jpeg.Decode(bytes.NewReader(repaired))
+	if repairErr != nil {
+		return nil, nil, err
+	}
+	return img, repairs, nil
+}
+
+// Resync returns a copy of data with the marker structure Go's decoder expects,
+// along with a description of each repair made. Data after the EOI that ends
+// the image is kept as it is. Data that is not a JPEG is returned as it is.
+func Resync(data []byte) ([]byte, []string) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != markerSOI {
+		return data, nil
+	}
+
+	out := append([]byte{}, data[:2]...)
+	var repairs []string
+	blocks := 0 // 8x8 blocks of the frame, for padding a cut-off scan
+	pos := 2
+	for {
+		// Bytes between segments are skipped by the decoder, so they are kept
+		for pos < len(data) && data[pos] != 0xFF {
+			out = append(out, data[pos])
+			pos++
+		}
+		for pos+1 < len(data) && data[pos+1] == 0xFF {
+			pos++ // Fill bytes
+		}
+		if pos+1 >= len(data) {
+			repairs = append(repairs, "appended the missing EOI marker")
+			return append(out, 0xFF, markerEOI), repairs
+		}
+
+		marker := data[pos+1]
+		switch {
+		case marker == markerEOI:
+			return append(out, data[pos:]...), repairs
+		case marker >= markerRST0 && marker <= markerRST7:
+			out = append(out, data[pos:pos+2]...)
+			pos += 2
+			continue
+		case marker < markerSOF0, marker == markerSOI:
+			repairs = append(repairs, fmt.Sprintf("removed the stray marker 0x%02X at offset %d", marker, pos))
+			pos += 2
+			continue
+		}
+
+		if pos+4 > len(data) {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			break
+		}
+		segment := data[pos : pos+2+length]
+		out = append(out, segment...)
+		pos += len(segment)
+		if (marker >= markerSOF0 && marker <= markerSOF2) && length >= 8 {
+			blocks = frameBlocks(segment[4:])
+		}
+		if marker != markerSOS {
+			continue
+		}
+
+		var scanRepairs []string
+		out, pos, scanRepairs = resyncScan(data, pos, out)
+		repairs = append(repairs, scanRepairs...)
+		if pos == len(data) {
+			padding := min(max(blocks, 1)*scanPaddingPerBlock, maxScanPadding)
+			repairs = append(repairs, fmt.Sprintf("filled the scan data cut off at the end of the file with %d zero bytes and an EOI marker", padding))
+			out = append(out, make([]byte, padding)...)
+			return append(out, 0xFF, markerEOI), repairs
+		}
+	}
+
+	// A segment cut off before the image data cannot be repaired
+	repairs = append(repairs, fmt.Sprintf("dropped the %d bytes of the segment cut off at offset %d and appended an EOI marker", len(data)-pos, pos))
+	return append(out, 0xFF, markerEOI), repairs
+}
+
+// resyncScan copies the entropy-coded data of a scan starting at pos to out. It
+// returns the offset of the marker that ends the scan, len(data) when the file
+// ends first, and a description of each repair made.
+func resyncScan(data []byte, pos int, out []byte) ([]byte, int, []string) {
+	var repairs []string
+	restart, renumbered := 0, 0
+	for pos < len(data) {
+		if data[pos] != 0xFF {
+			out = append(out, data[pos])
+			pos++
+			continue
+		}
+		if pos+1 == len(data) {
+			pos++ // A lone 0xFF at the end of the file
+			break
+		}
+		next := data[pos+1]
+		switch {
+		case next == 0x00:
+			out = append(out, 0xFF, 0x00)
+			pos += 2
+		case next == 0xFF:
+			pos++
+		case next >= markerRST0 && next <= markerRST7:
+			expected := byte(markerRST0 + restart%8)
+			if next != expected {
+				renumbered++
+			}
+			out = append(out, 0xFF, expected)
+			restart++
+			pos += 2
+		case endsScan(data, pos):
+			if renumbered > 0 {
+				repairs = append(repairs, fmt.Sprintf("renumbered %d restart markers", renumbered))
+			}
+			return out, pos, repairs
+		default:
+			repairs = append(repairs, fmt.Sprintf("removed the stray marker 0x%02X at offset %d from the scan data", next, pos))
+			pos += 2
+		}
+	}
+	if renumbered > 0 {
+		repairs = append(repairs, fmt.Sprintf("renumbered %d restart markers", renumbered))
+	}
+	return out, len(data), repairs
+}
+
+// endsScan reports whether the marker at pos in scan data ends the scan: it is
+// an EOI, other than one inserted into the scan data, the header of the next
+// scan, or a segment that fits in the file and is followed by another marker or
+// the end of the file
+func endsScan(data []byte, pos int) bool {
+	marker := data[pos+1]
+	if marker == markerEOI {
+		// The rest of a split scan runs from an inserted EOI to the real one
+		next := FindScanEnd(data, pos+2)
+		return next+1 >= len(data) || data[next+1] != markerEOI || next-pos-2 < scanDataMinSize
+	}
+	if marker < markerSOF0 || marker == markerSOI || marker > markerCOM {
+		return false
+	}
+	if pos+4 > len(data) {
+		return false
+	}
+	length := int(binary.BigEndian.Uint16(data[pos+2:]))
+	end := pos + 2 + length
+	if marker == markerSOS {
+		// Scan data follows the header, whose length is set by its component count
+		return pos+4 < len(data) && length == 6+2*int(data[pos+4]) && end <= len(data)
+	}
+	return length >= 2 && (end == len(data) || (end < len(data) && data[end] == 0xFF))
+}
+
+// frameBlocks returns the number of 8x8 blocks of a frame from the payload of
+// its SOF segment, taking every component at full resolution
+func frameBlocks(sof []byte) int {
+	height := int(binary.BigEndian.Uint16(sof[1:]))
+	width := int(binary.BigEndian.Uint16(sof[3:]))
+	components := int(sof[5])
+	return ceilDiv(width, 8) * ceilDiv(height, 8) * components
+}
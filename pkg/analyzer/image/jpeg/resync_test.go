@@ -0,0 +1,63 @@
+package jpeg
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+)
+
+func TestResync(t *testing.T) {
+	clean, err := fixtures.Load("clean.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	split, err := fixtures.Load("split_scan.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	middle := bytes.Index(clean, []byte{0xFF, markerSOS}) + 200
+	stray := append(append(append([]byte{}, clean[:middle]...), 0xFF, 0x12), clean[middle:]...)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		repairs []string // Prefixes of the repairs expected
+		want    []byte   // Repaired bytes expected, not checked when nil
+	}{
+		{"inserted EOI", split, []string{"removed the stray marker 0xD9"}, clean},
+		{"stray marker", stray, []string{"removed the stray marker 0x12"}, clean},
+		{"cut-off scan", clean[:len(clean)*2/3], []string{"filled the scan data cut off"}, nil},
+		{"missing EOI", clean[:len(clean)-2], []string{"filled the scan data cut off"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repaired, repairs := Resync(tt.data)
+			if len(repairs) != len(tt.repairs) {
+				t.Fatalf("repairs %q, want %q", repairs, tt.repairs)
+			}
+			for i, prefix := range tt.repairs {
+				if !strings.HasPrefix(repairs[i], prefix) {
+					t.Errorf("repair %q, want one starting with %q", repairs[i], prefix)
+				}
+			}
+			if tt.want != nil && !bytes.Equal(repaired, tt.want) {
+				t.Error("repaired file differs from the original")
+			}
+
+			img, decodeRepairs, err := DecodeLenient(tt.data)
+			if err != nil {
+				t.Fatalf("DecodeLenient() error = %v", err)
+			}
+			if img.Bounds().Dx() != 128 || len(decodeRepairs) != len(repairs) {
+				t.Errorf("decoded %v with repairs %q, want a 128x128 image and %q", img.Bounds(), decodeRepairs, repairs)
+			}
+		})
+	}
+
+	// Files the decoder accepts are not repaired
+	if _, repairs := Resync(clean); len(repairs) != 0 {
+		t.Errorf("repairs %q for a clean file, want none", repairs)
+	}
+}
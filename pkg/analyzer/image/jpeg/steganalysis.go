@@ -0,0 +1,279 @@
+package jpeg
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"DeSteGo/pkg/analyzer/stats"
+)
+
+/*
+This file contains the coefficient histogram helpers and the F5 and JPHide
+detectors. F5 is detected with Fridrich's calibration attack: the decoded image
+is cropped by 4 pixels and re-transformed, which yields an estimate of the cover
+histogram to compare the file's coefficients against.
+*/
+
+// calibrationModes are the zig-zag positions of the low-frequency AC modes used
+// for F5 calibration: (0,1), (1,0) and (1,1)
+var calibrationModes = []int{1, 2, 4}
+
+// coefficientHistogram holds value counts of quantized AC coefficients
+type coefficientHistogram struct {
+	all     map[int]int     // Every AC coefficient
+	modes   [64]map[int]int // Per zig-zag position (index 0 is unused)
+	total   int
+	nonZero int
+}
+
+// analyzeHistogram builds the AC coefficient histogram for one component, or for
+// all components when component is negative
+func analyzeHistogram(dct *JPEGDCTData, component int) *coefficientHistogram {
+	h := &coefficientHistogram{all: make(map[int]int)}
+	for k := 1; k < 64; k++ {
+		h.modes[k] = make(map[int]int)
+	}
+
+	for i := range dct.Blocks {
+		block := &dct.Blocks[i]
+		if component >= 0 && block.Component != component {
+			continue
+		}
+		for k := 1; k < 64; k++ {
+			v := int(block.Coefficients[k])
+			h.all[v]++
+			h.modes[k][v]++
+			h.total++
+			if v != 0 {
+				h.nonZero++
+			}
+		}
+	}
+	return h
+}
+
+// absCounts returns the counts of |v| = 0, 1 and 2 for a histogram
+func absCounts(hist map[int]int) (float64, float64, float64) {
+	return float64(hist[0]), float64(hist[1] + hist[-1]), float64(hist[2] + hist[-2])
+}
+
+// detectF5 estimates the probability that F5 was used to embed data.
+//
+// F5 decrements the magnitude of the coefficients it changes, so embedding moves
+// counts from |v|=2 to |v|=1 and from |v|=1 to 0 ("shrinkage"). The fraction of
+// modified coefficients (beta) is estimated per low-frequency mode by comparing
+// the luminance histogram against the calibrated cover estimate.
+func detectF5(dct *JPEGDCTData, img image.Image) (float64, string) {
+	if dct == nil || img == nil || len(dct.Components) == 0 {
+		return 0, "F5 calibration needs both coefficients and decoded pixels"
+	}
+
+	quant, ok := dct.QuantTables[dct.Components[0].QuantTableID]
+	if !ok {
+		return 0, "missing luminance quantization table"
+	}
+
+	stego := analyzeHistogram(dct, 0)
+	calibrated := calibratedHistogram(img, quant, dct.ColorModel)
+	if calibrated == nil {
+		return 0, "image too small for F5 calibration"
+	}
+
+	// The calibrated image has fewer blocks; scale its counts to the stego block count
+	var betaSum float64
+	modes := 0
+	for _, k := range calibrationModes {
+		s0, s1, _ := absCounts(stego.modes[k])
+		c0, c1, c2 := absCounts(calibrated.modes[k])
+		cTotal := c0 + c1 + c2
+		for v, n := range calibrated.modes[k] {
+			if v < -2 || v > 2 {
+				cTotal += float64(n)
+			}
+		}
+		if cTotal == 0 || c1 == 0 {
+			continue
+		}
+		scale := float64(stego.total/63) / cTotal
+		c0, c1, c2 = c0*scale, c1*scale, c2*scale
+
+		// Least-squares estimate of beta (Fridrich, Goljan, Hogea 2002)
+		num := c1*(s0-c0) + (s1-c1)*(c2-c1)
+		den := c1*c1 + (c2-c1)*(c2-c1)
+		if den == 0 {
+			continue
+		}
+		betaSum += num / den
+		modes++
+	}
+
+	if modes == 0 {
+		return 0, "not enough low-frequency coefficients for F5 calibration"
+	}
+
+	beta := betaSum / float64(modes)
+	probability := clamp01((beta - 0.05) / 0.2)
+	return probability, fmt.Sprintf("estimated F5 modification rate beta=%.3f over %d calibration modes", beta, modes)
+}
+
+// calibratedHistogram crops the decoded first component (the luminance of most
+// files) by 4 pixels, recomputes the block DCT and quantizes it with the file's
+// own table
+func calibratedHistogram(img image.Image, quant [64]uint16, model ColorModel) *coefficientHistogram {
+	bounds := img.Bounds()
+	width, height := bounds.Dx()-4, bounds.Dy()-4
+	blocksX, blocksY := width/8, height/8
+	if blocksX < 1 || blocksY < 1 {
+		return nil
+	}
+
+	luma := firstComponentAt(img, model)
+	h := &coefficientHistogram{all: make(map[int]int)}
+	for k := 1; k < 64; k++ {
+		h.modes[k] = make(map[int]int)
+	}
+
+	var pixels, coeffs [64]float64
+	for by := 0; by < blocksY; by++ {
+		for bx := 0; bx < blocksX; bx++ {
+			for y := 0; y < 8; y++ {
+				for x := 0; x < 8; x++ {
+					px := bounds.Min.X + 4 + bx*8 + x
+					py := bounds.Min.Y + 4 + by*8 + y
+					pixels[y*8+x] = luma(px, py) - 128
+				}
+			}
+			forwardDCT(&pixels, &coeffs)
+			for k := 1; k < 64; k++ {
+				q := float64(quant[k])
+				if q == 0 {
+					q = 1
+				}
+				v := int(math.Round(coeffs[zigzag[k]] / q))
+				h.all[v]++
+				h.modes[k][v]++
+				h.total++
+				if v != 0 {
+					h.nonZero++
+				}
+			}
+		}
+	}
+	return h
+}
+
+// zigzag maps a zig-zag index to its natural (row-major) position in a block
+var zigzag = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// dctCos holds cos((2x+1)u*pi/16) scaled by the DCT normalization factor
+var dctCos = func() [8][8]float64 {
+	var t [8][8]float64
+	for u := 0; u < 8; u++ {
+		c := 0.5
+		if u == 0 {
+			c = 1 / (2 * math.Sqrt2)
+		}
+		for x := 0; x < 8; x++ {
+			t[u][x] = c * math.Cos(float64(2*x+1)*float64(u)*math.Pi/16)
+		}
+	}
+	return t
+}()
+
+// forwardDCT computes the 8x8 type-II DCT of a block in natural order
+func forwardDCT(in, out *[64]float64) {
+	var tmp [64]float64
+	for y := 0; y < 8; y++ {
+		for u := 0; u < 8; u++ {
+			s := 0.0
+			for x := 0; x < 8; x++ {
+				s += dctCos[u][x] * in[y*8+x]
+			}
+			tmp[y*8+u] = s
+		}
+	}
+	for u := 0; u < 8; u++ {
+		for v := 0; v < 8; v++ {
+			s := 0.0
+			for y := 0; y < 8; y++ {
+				s += dctCos[v][y] * tmp[y*8+u]
+			}
+			out[v*8+u] = s
+		}
+	}
+}
+
+// jphideLowModes is the number of leading zig-zag positions JPHide fills first
+const jphideLowModes = 6
+
+// detectJPHide estimates the probability that JPHide was used to embed data.
+//
+// JPHide writes into a fixed sequence of coefficients that starts with the lowest
+// frequencies of every block, so for short messages only those modes show LSB
+// pair equalization while the high frequencies stay natural.
+func detectJPHide(dct *JPEGDCTData) (float64, string) {
+	if dct == nil || len(dct.Blocks) == 0 {
+		return 0, "no DCT coefficients available"
+	}
+
+	hist := analyzeHistogram(dct, -1)
+
+	low := make(map[int]int)
+	high := make(map[int]int)
+	for k := 1; k < 64; k++ {
+		target := high
+		if k <= jphideLowModes {
+			target = low
+		}
+		for v, n := range hist.modes[k] {
+			target[v] += n
+		}
+	}
+
+	lowP, lowDof := pairChiSquare(low)
+	highP, highDof := pairChiSquare(high)
+	if lowDof == 0 {
+		return 0, "not enough low-frequency coefficients for JPHide analysis"
+	}
+
+	// Equalized low frequencies with natural high frequencies is the JPHide pattern
+	probability := lowP
+	if highDof > 0 {
+		probability = clamp01(lowP - highP)
+	}
+	return probability, fmt.Sprintf("low-frequency pair p=%.3f (dof=%d), high-frequency pair p=%.3f (dof=%d)",
+		lowP, lowDof, highP, highDof)
+}
+
+// pairChiSquare runs the pair-of-values chi-square test over an AC histogram and
+// returns the p-value and the number of pairs used
+func pairChiSquare(hist map[int]int) (float64, int) {
+	var chiSquare float64
+	dof := 0
+	for _, seq := range []struct{ start, step int }{{2, 1}, {-1, -1}} {
+		for i := 0; i < 62; i += 2 {
+			a := seq.start + i*seq.step
+			na, nb := float64(hist[a]), float64(hist[a+seq.step])
+			if na+nb < minPairSamples {
+				break
+			}
+			expected := (na + nb) / 2
+			chiSquare += (na - expected) * (na - expected) / expected
+			dof++
+		}
+	}
+	if dof == 0 {
+		return 0, 0
+	}
+	return stats.ChiSquarePValue(chiSquare, dof), dof
+}
@@ -0,0 +1,85 @@
+package jpeg
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"math/rand"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+)
+
+// f5Rate is the fraction of non-zero coefficients the F5 stand-in changes
+const f5Rate = 0.3
+
+// shrinkF5 decrements the magnitude of a share of the non-zero coefficients, as
+// F5 does to each coefficient whose bit does not match the message
+func shrinkF5(block *DCTCoefficientBlock, k int, rng *rand.Rand) {
+	v := block.Coefficients[k]
+	if v == 0 || rng.Float64() >= f5Rate {
+		return
+	}
+	if v > 0 {
+		block.Coefficients[k] = v - 1
+	} else {
+		block.Coefficients[k] = v + 1
+	}
+}
+
+// decodeFixture decodes the pixels of the fixture called name
+func decodeFixture(t *testing.T, name string) image.Image {
+	t.Helper()
+	data, err := fixtures.Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestAnalyzeHistogram(t *testing.T) {
+	dct := loadCoefficients(t, "clean.jpg", nil)
+	all := analyzeHistogram(dct, -1)
+	luma := analyzeHistogram(dct, 0)
+
+	if want := len(dct.Blocks) * 63; all.total != want {
+		t.Errorf("%d coefficients counted, want %d", all.total, want)
+	}
+	if want := dct.Components[0].BlocksWide * dct.Components[0].BlocksHigh * 63; luma.total != want {
+		t.Errorf("%d luminance coefficients counted, want %d", luma.total, want)
+	}
+	sum := 0
+	for _, n := range all.all {
+		sum += n
+	}
+	if sum != all.total || all.nonZero != all.total-all.all[0] {
+		t.Errorf("histogram sums to %d with %d non-zero, want %d with %d", sum, all.nonZero, all.total, all.total-all.all[0])
+	}
+}
+
+func TestDetectF5(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   coefficientChange
+		embedded bool
+	}{
+		{"clean", nil, false},
+		{"shrunk", shrinkF5, true},
+	}
+	for _, fixture := range []string{"clean.jpg", "textured.jpg"} {
+		img := decodeFixture(t, fixture)
+		for _, tt := range tests {
+			t.Run(fixture+"/"+tt.name, func(t *testing.T) {
+				dct := loadCoefficients(t, fixture, tt.change)
+				probability, details := detectF5(dct, img)
+				if got := probability > 0.5; got != tt.embedded {
+					t.Errorf("probability %.2f (%s), want embedded = %v", probability, details, tt.embedded)
+				}
+			})
+		}
+	}
+}
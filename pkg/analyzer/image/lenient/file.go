@@ -0,0 +1,64 @@
+package lenient
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"sync"
+)
+
+// File is an image file that is read once and decoded once, however many
+// analyzers, extractors and commands look at it. It implements models.Source
+// and is safe for concurrent use.
+type File struct {
+	path string
+
+	readOnce sync.Once
+	data     []byte
+	readErr  error
+
+	decodeOnce sync.Once
+	decoded    *Decoded
+	decodeErr  error
+}
+
+// NewFile returns a File for the image at path. Nothing is read until the
+// bytes or the image are asked for.
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// Path returns the path of the file
+func (f *File) Path() string {
+	return f.path
+}
+
+// Bytes returns the contents of the file, reading it the first time
+func (f *File) Bytes() ([]byte, error) {
+	f.readOnce.Do(func() {
+		f.data, f.readErr = os.ReadFile(f.path)
+	})
+	return f.data, f.readErr
+}
+
+// Decode returns the file decoded like Decode, decoding it the first time
+func (f *File) Decode() (*Decoded, error) {
+	f.decodeOnce.Do(func() {
+		data, err := f.Bytes()
+		if err != nil {
+			f.decodeErr = fmt.Errorf("failed to read file: %w", err)
+			return
+		}
+		f.decoded, f.decodeErr = Decode(data)
+	})
+	return f.decoded, f.decodeErr
+}
+
+// Image returns the decoded image, its format and the repairs it needed
+func (f *File) Image() (image.Image, string, []string, error) {
+	decoded, err := f.Decode()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return decoded.Image, decoded.Format, decoded.Repairs, nil
+}
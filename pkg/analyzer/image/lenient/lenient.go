@@ -0,0 +1,62 @@
+package lenient
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"os"
+
+	"DeSteGo/pkg/analyzer/image/jpeg"
+	"DeSteGo/pkg/analyzer/image/png"
+)
+
+/*
+This package decodes images the way the analyzers do, for the extractors and
+commands that decode files on their own. A file is decoded with the registered
+decoders first. When they reject a PNG or a JPEG, it is decoded again after the
+repairs of png.DecodeLenient or jpeg.DecodeLenient, so that a file the analyzers
+analyzed after repairing it can also be extracted from, compared and scanned.
+A File reads and decodes a file once for all of them.
+*/
+
+// Decoded is an image decoded from a file
+type Decoded struct {
+	Image   image.Image
+	Format  string   // Format name, as image.Decode reports it
+	Repairs []string // Repairs needed to decode the file, none when it decoded as it is
+}
+
+// Decode decodes an image, repairing a PNG or a JPEG that does not decode as
+// it is. The error is that of the file as it is when the repairs do not help.
+func Decode(data []byte) (*Decoded, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err == nil {
+		return &Decoded{Image: img, Format: format}, nil
+	}
+
+	var repairs []string
+	var repairErr error
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		format = "png"
+		img, repairs, repairErr = png.DecodeLenient(data)
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		format = "jpeg"
+		img, repairs, repairErr = jpeg.DecodeLenient(data)
+	default:
+		return nil, err
+	}
+	if repairErr != nil {
+		return nil, err
+	}
+	return &Decoded{Image: img, Format: format, Repairs: repairs}, nil
+}
+
+// DecodeFile reads and decodes an image file like Decode
+func DecodeFile(filePath string) (*Decoded, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return Decode(data)
+}
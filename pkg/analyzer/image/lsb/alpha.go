@@ -0,0 +1,59 @@
+package lsb
+
+import (
+	"image"
+	"image/color"
+)
+
+// AlphaAnalysis describes the least significant bits of an image's alpha channel
+type AlphaAnalysis struct {
+	HasAlpha   bool    // Whether the color model stores alpha at all
+	NearOpaque bool    // Whether every alpha value is 254 or 255
+	LSBEntropy float64 // Entropy of the alpha LSB plane (0.0-1.0)
+}
+
+// AnalyzeAlphaChannel checks for data hidden only in the alpha channel.
+//
+// Tools that hide data in alpha alone leave an image that looks fully opaque but
+// whose alpha values alternate between 254 and 255. Genuinely opaque images have
+// an alpha LSB entropy of zero and translucent ones vary more than one level, so
+// high entropy combined with near-opacity is the tell. calculateAnomalyScore only
+// scores alpha when its entropy matches the RGB planes (data spread over all four
+// channels), so alpha-only embedding in a natural image is reported here instead.
+func AnalyzeAlphaChannel(img image.Image) *AlphaAnalysis {
+	result := &AlphaAnalysis{}
+	if img == nil {
+		return result
+	}
+
+	switch img.ColorModel() {
+	case color.NRGBAModel, color.RGBAModel, color.NRGBA64Model, color.RGBA64Model:
+		result.HasAlpha = true
+	default:
+		return result
+	}
+
+	bounds := img.Bounds()
+	total := bounds.Dx() * bounds.Dy()
+	if total == 0 {
+		return result
+	}
+
+	ones := 0
+	result.NearOpaque = true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			a := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA).A
+			if a < 254 {
+				result.NearOpaque = false
+			}
+			if a&1 == 1 {
+				ones++
+			}
+		}
+	}
+
+	oneProb := float64(ones) / float64(total)
+	result.LSBEntropy = calculateEntropy(1-oneProb, oneProb)
+	return result
+}
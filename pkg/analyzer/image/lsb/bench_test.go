@@ -0,0 +1,22 @@
+package lsb
+
+import (
+	"fmt"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+)
+
+func BenchmarkAnalyzeDistribution(b *testing.B) {
+	for _, size := range fixtures.BenchSizes {
+		img := fixtures.Noise(size, 1)
+		b.Run(fmt.Sprintf("%dpx", size), func(b *testing.B) {
+			b.SetBytes(int64(len(img.Pix)))
+			for i := 0; i < b.N; i++ {
+				if _, err := AnalyzeDistribution(img); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package lsb
+
+import "image"
+
+/*
+This file contains the capacity estimator. The capacity of an image is the
+largest payload, in bytes, that a scheme could hide in it. It tells an analyst
+whether an extracted payload could have filled the whole image and how much
+of a suspected carrier is left unexplained.
+*/
+
+// Capacity keys, in the order they are usually shown
+const (
+	CapacityLSBRGB  = "lsb1_rgb"  // One bit in each of R, G and B
+	CapacityLSBRGBA = "lsb1_rgba" // One bit in each of R, G, B and A
+	CapacityLSB2RGB = "lsb2_rgb"  // Two bits in each of R, G and B
+	CapacityJSteg   = "jsteg"     // One bit per AC coefficient other than 0 and 1 (JPEG only)
+)
+
+// EstimateCapacity returns the bytes that the pixel-domain LSB schemes could
+// hide in img, keyed by the Capacity constants
+func EstimateCapacity(img image.Image) map[string]int {
+	pixels := 0
+	if img != nil {
+		bounds := img.Bounds()
+		pixels = bounds.Dx() * bounds.Dy()
+	}
+
+	return map[string]int{
+		CapacityLSBRGB:  pixels * 3 / 8,
+		CapacityLSBRGBA: pixels * 4 / 8,
+		CapacityLSB2RGB: pixels * 3 * 2 / 8,
+	}
+}
@@ -0,0 +1,35 @@
+package lsb
+
+import (
+	"image"
+	"testing"
+)
+
+func TestEstimateCapacity(t *testing.T) {
+	tests := []struct {
+		name            string
+		img             image.Image
+		rgb, rgba, lsb2 int
+	}{
+		{"200x120", image.NewRGBA(image.Rect(0, 0, 200, 120)), 9000, 12000, 18000},
+		{"500x500", image.NewNRGBA(image.Rect(0, 0, 500, 500)), 93750, 125000, 187500},
+		{"offset bounds", image.NewGray(image.Rect(10, 10, 18, 13)), 9, 12, 18},
+		{"3x1 rounds down", image.NewRGBA(image.Rect(0, 0, 3, 1)), 1, 1, 2},
+		{"empty", image.NewRGBA(image.Rect(0, 0, 0, 0)), 0, 0, 0},
+		{"nil", nil, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capacity := EstimateCapacity(tt.img)
+			want := map[string]int{CapacityLSBRGB: tt.rgb, CapacityLSBRGBA: tt.rgba, CapacityLSB2RGB: tt.lsb2}
+			if len(capacity) != len(want) {
+				t.Errorf("got keys %v, want %v", capacity, want)
+			}
+			for key, n := range want {
+				if capacity[key] != n {
+					t.Errorf("%s: got %d, want %d", key, capacity[key], n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package lsb
+
+import (
+	"fmt"
+	"image"
+)
+
+/*
+This file contains the cross-channel LSB plane correlation test. In a natural
+color image the R, G and B least significant bits are close to independent
+noise, so all three agree on about a quarter of the pixels. A tool that writes
+the same bit stream into every channel, for redundancy or because it repeats
+one plane, makes them agree on every embedded pixel. Gray pixels (R = G = B)
+and pixels equal to their left neighbour agree trivially and are skipped.
+
+Agreement is measured against what the planes' own bit balance predicts, so
+synthetic images whose LSBs are constant do not count as correlated. Images
+decoded from JPEG have partly correlated LSBs (the channels are rebuilt from
+shared luminance) but stay well short of full agreement.
+*/
+
+// Plane correlation parameters
+const (
+	planeSegments    = 20  // Raster-order segments, so short payloads at the start are not diluted
+	planeMinSamples  = 200 // Counted pixels a segment needs before it is judged
+	planeMaxExpected = 0.5 // Chance agreement above which the planes are too constant to judge
+)
+
+// PlaneCorrelation describes how often the R, G and B LSBs of a pixel are equal
+type PlaneCorrelation struct {
+	Agreement    float64 // Share of counted pixels whose R, G and B LSBs are equal
+	Expected     float64 // Agreement predicted by the planes' bit balance if they were independent
+	Correlation  float64 // Highest excess agreement of a segment: 0 at chance, 1 when every pixel agrees
+	Samples      int     // Pixels counted (not gray, not equal to their left neighbour)
+	SegmentIndex int     // Segment with the highest correlation
+}
+
+// AnalyzePlaneCorrelation measures the agreement of the R, G and B LSB planes
+func AnalyzePlaneCorrelation(img image.Image) *PlaneCorrelation {
+	result := &PlaneCorrelation{}
+	if img == nil {
+		return result
+	}
+
+	pixelAt, shift := pixelReader(img)
+	bounds := img.Bounds()
+	total := bounds.Dx() * bounds.Dy()
+	if total == 0 {
+		return result
+	}
+	segmentSize := (total + planeSegments - 1) / planeSegments
+
+	var agree, counted [planeSegments]int
+	var ones [planeSegments][3]int
+	i := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		var pr, pg, pb uint32
+		for x := bounds.Min.X; x < bounds.Max.X; x, i = x+1, i+1 {
+			r, g, b, _ := pixelAt(x, y)
+			flat := x > bounds.Min.X && r == pr && g == pg && b == pb
+			pr, pg, pb = r, g, b
+			if flat || (r == g && g == b) {
+				continue
+			}
+
+			segment := i / segmentSize
+			counted[segment]++
+			rl, gl, bl := (r>>shift)&1, (g>>shift)&1, (b>>shift)&1
+			ones[segment][0] += int(rl)
+			ones[segment][1] += int(gl)
+			ones[segment][2] += int(bl)
+			if rl == gl && gl == bl {
+				agree[segment]++
+			}
+		}
+	}
+
+	var agreeing int
+	var allOnes [3]int
+	for s := 0; s < planeSegments; s++ {
+		agreeing += agree[s]
+		result.Samples += counted[s]
+		for c := range allOnes {
+			allOnes[c] += ones[s][c]
+		}
+		if counted[s] < planeMinSamples {
+			continue
+		}
+		expected := chanceAgreement(ones[s], counted[s])
+		if expected > planeMaxExpected {
+			continue
+		}
+		observed := float64(agree[s]) / float64(counted[s])
+		if correlation := (observed - expected) / (1 - expected); correlation > result.Correlation {
+			result.Correlation, result.SegmentIndex = correlation, s
+		}
+	}
+	if result.Samples > 0 {
+		result.Agreement = float64(agreeing) / float64(result.Samples)
+		result.Expected = chanceAgreement(allOnes, result.Samples)
+	}
+	return result
+}
+
+// chanceAgreement returns how often three independent bit planes with the
+// given counts of ones out of n would all be equal
+func chanceAgreement(ones [3]int, n int) float64 {
+	pr, pg, pb := float64(ones[0])/float64(n), float64(ones[1])/float64(n), float64(ones[2])/float64(n)
+	return pr*pg*pb + (1-pr)*(1-pg)*(1-pb)
+}
+
+// Describe explains the measurement in a finding's details
+func (c *PlaneCorrelation) Describe() string {
+	return fmt.Sprintf("R, G and B LSBs agree on %.0f%% of pixels (%.0f%% expected by chance); segment %d of %d has a correlation of %.2f",
+		c.Agreement*100, c.Expected*100, c.SegmentIndex+1, planeSegments, c.Correlation)
+}
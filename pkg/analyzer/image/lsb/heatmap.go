@@ -0,0 +1,49 @@
+package lsb
+
+import (
+	"image"
+	"image/color"
+)
+
+// DefaultHeatmapTile is the edge length in pixels of a heatmap tile
+const DefaultHeatmapTile = 16
+
+// EntropyHeatmap computes the Shannon entropy of the RGB LSB plane over square
+// tiles and renders it as a grayscale image of the same size as img. Bright
+// tiles have LSBs close to a 50/50 split, which is where sequentially embedded
+// data tends to show up; flat areas stay dark. Sensor noise balances the LSBs
+// of photos by itself, so in noisy areas the map is bright with or without data.
+func EntropyHeatmap(img image.Image, tile int) *image.Gray {
+	if tile <= 0 {
+		tile = DefaultHeatmapTile
+	}
+
+	bounds := img.Bounds()
+	heatmap := image.NewGray(bounds)
+
+	pixelAt, shift := pixelReader(img)
+	for ty := bounds.Min.Y; ty < bounds.Max.Y; ty += tile {
+		for tx := bounds.Min.X; tx < bounds.Max.X; tx += tile {
+			maxX, maxY := min(tx+tile, bounds.Max.X), min(ty+tile, bounds.Max.Y)
+
+			ones, total := 0, 0
+			for y := ty; y < maxY; y++ {
+				for x := tx; x < maxX; x++ {
+					r, g, b, _ := pixelAt(x, y)
+					ones += int(r>>shift&1) + int(g>>shift&1) + int(b>>shift&1)
+					total += 3
+				}
+			}
+
+			oneProb := float64(ones) / float64(total)
+			level := color.Gray{Y: uint8(calculateEntropy(1-oneProb, oneProb) * 255)}
+			for y := ty; y < maxY; y++ {
+				for x := tx; x < maxX; x++ {
+					heatmap.SetGray(x, y, level)
+				}
+			}
+		}
+	}
+
+	return heatmap
+}
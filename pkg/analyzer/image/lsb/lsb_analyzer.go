@@ -8,13 +8,55 @@ import (
 
 // AnalysisResult represents the result of LSB distribution analysis
 type AnalysisResult struct {
-	AnomalyScore float64
-	Entropy      float64
-	Confidence   float64
-	ChannelStats map[string]float64
+	AnomalyScore     float64
+	Entropy          float64
+	ReferenceEntropy float64 // Average RGB entropy of the bit plane above the LSBs
+	Confidence       float64
+	ChannelStats     map[string]float64
+	BitDepth         int // Bits per sample the LSBs were taken from (8 or 16)
 }
 
-// AnalyzeDistribution analyzes the LSB distribution in an image across all color channels
+// pixelReader returns a function reading the stored channel samples of a pixel
+// and the shift that moves a sample's least significant bit to bit 0.
+//
+// RGBA() scales 8-bit samples to 16 bits, so for ordinary images the LSB is bit 8
+// of its result. 16-bit images store real 16-bit samples whose LSB is bit 0; they
+// are also read without alpha premultiplication, which would alter the low bits.
+// So are 8-bit NRGBA images, the PNGs with transparency, whose stored samples
+// are scaled to 16 bits the way RGBA() scales them.
+func pixelReader(img image.Image) (func(x, y int) (r, g, b, a uint32), uint) {
+	switch m := img.(type) {
+	case *image.NRGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			s := m.Pix[m.PixOffset(x, y):]
+			return uint32(s[0]) * 0x101, uint32(s[1]) * 0x101, uint32(s[2]) * 0x101, uint32(s[3]) * 0x101
+		}, 8
+	case *image.NRGBA64:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			c := m.NRGBA64At(x, y)
+			return uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A)
+		}, 0
+	case *image.RGBA64:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			c := m.RGBA64At(x, y)
+			return uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A)
+		}, 0
+	case *image.Gray16:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			v := uint32(m.Gray16At(x, y).Y)
+			return v, v, v, 0xFFFF
+		}, 0
+	}
+	return func(x, y int) (uint32, uint32, uint32, uint32) {
+		return img.At(x, y).RGBA()
+	}, 8
+}
+
+// AnalyzeDistribution analyzes the LSB distribution in an image across all color channels.
+//
+// Sensor noise alone balances the LSBs of a photograph, so the LSB plane is scored
+// against the next bit plane, which LSB embedding leaves alone: only balance the
+// image's own noise does not explain counts as anomalous.
 func AnalyzeDistribution(img image.Image) (*AnalysisResult, error) {
 	if img == nil {
 		return nil, errors.New("nil image provided")
@@ -24,98 +66,79 @@ func AnalyzeDistribution(img image.Image) (*AnalysisResult, error) {
 	width, height := bounds.Dx(), bounds.Dy()
 	totalPixels := width * height
 
-	// Initialize counters for each channel's LSB values
-	rZeros, rOnes := 0, 0
-	gZeros, gOnes := 0, 0
-	bZeros, bOnes := 0, 0
-	aZeros, aOnes := 0, 0
+	// Count the zero bits of each channel (R, G, B, A) in the LSB plane and the
+	// plane above it
+	var lsbZeros, refZeros [4]int
 
-	// Analyze LSB distribution across all pixels
+	pixelAt, shift := pixelReader(img)
 	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
 		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, a := img.At(x, y).RGBA()
-
-			// Extract LSBs from each channel (16-bit color values from RGBA())
-			// Using just the 8 most significant bits (>>8) to match standard 8-bit color depth
-			// Then extracting just the least significant bit (&1)
-			rLSB := uint8(r>>8) & 1
-			gLSB := uint8(g>>8) & 1
-			bLSB := uint8(b>>8) & 1
-			aLSB := uint8(a>>8) & 1
-
-			// Count occurrences of 0s and 1s for each channel
-			if rLSB == 0 {
-				rZeros++
-			} else {
-				rOnes++
-			}
-
-			if gLSB == 0 {
-				gZeros++
-			} else {
-				gOnes++
-			}
-
-			if bLSB == 0 {
-				bZeros++
-			} else {
-				bOnes++
-			}
-
-			if aLSB == 0 {
-				aZeros++
-			} else {
-				aOnes++
+			r, g, b, a := pixelAt(x, y)
+			for c, v := range [4]uint32{r, g, b, a} {
+				if (v>>shift)&1 == 0 {
+					lsbZeros[c]++
+				}
+				if (v>>(shift+1))&1 == 0 {
+					refZeros[c]++
+				}
 			}
 		}
 	}
 
-	// Calculate channel-specific statistics
-	rZeroPercent := float64(rZeros) / float64(totalPixels)
-	rOnePercent := float64(rOnes) / float64(totalPixels)
-	gZeroPercent := float64(gZeros) / float64(totalPixels)
-	gOnePercent := float64(gOnes) / float64(totalPixels)
-	bZeroPercent := float64(bZeros) / float64(totalPixels)
-	bOnePercent := float64(bOnes) / float64(totalPixels)
-	aZeroPercent := float64(aZeros) / float64(totalPixels)
-	aOnePercent := float64(aOnes) / float64(totalPixels)
-
-	// Calculate Shannon entropy for each channel
-	rEntropy := calculateEntropy(rZeroPercent, rOnePercent)
-	gEntropy := calculateEntropy(gZeroPercent, gOnePercent)
-	bEntropy := calculateEntropy(bZeroPercent, bOnePercent)
-	aEntropy := calculateEntropy(aZeroPercent, aOnePercent)
+	lsbScore, lsbEntropies, lsbZeroPercents := planeStats(lsbZeros, totalPixels)
+	refScore, refEntropies, _ := planeStats(refZeros, totalPixels)
+	rEntropy, gEntropy, bEntropy, aEntropy := lsbEntropies[0], lsbEntropies[1], lsbEntropies[2], lsbEntropies[3]
 
 	// Calculate average entropy across RGB channels
 	avgEntropy := (rEntropy + gEntropy + bEntropy) / 3.0
+	refEntropy := (refEntropies[0] + refEntropies[1] + refEntropies[2]) / 3.0
 
-	// Calculate anomaly score based on entropy and distribution patterns
-	anomalyScore := calculateAnomalyScore(
-		rEntropy, gEntropy, bEntropy, aEntropy,
-		rZeroPercent, gZeroPercent, bZeroPercent, aZeroPercent,
-	)
+	// Only the part of the score the reference plane does not share is anomalous
+	anomalyScore := math.Max(lsbScore-refScore, 0)
 
 	// Calculate confidence based on sample size and entropy variance
-	entropyVariance := calculateVariance([]float64{rEntropy, gEntropy, bEntropy, aEntropy})
+	entropyVariance := calculateVariance(lsbEntropies[:])
 	confidence := calculateConfidence(totalPixels, entropyVariance)
 
+	bitDepth := 8
+	if shift == 0 {
+		bitDepth = 16
+	}
+
 	return &AnalysisResult{
-		AnomalyScore: anomalyScore,
-		Entropy:      avgEntropy,
-		Confidence:   confidence,
+		BitDepth:         bitDepth,
+		AnomalyScore:     anomalyScore,
+		Entropy:          avgEntropy,
+		ReferenceEntropy: refEntropy,
+		Confidence:       confidence,
 		ChannelStats: map[string]float64{
 			"R":       rEntropy,
 			"G":       gEntropy,
 			"B":       bEntropy,
 			"A":       aEntropy,
-			"R_zeros": rZeroPercent,
-			"G_zeros": gZeroPercent,
-			"B_zeros": bZeroPercent,
-			"A_zeros": aZeroPercent,
+			"R_zeros": lsbZeroPercents[0],
+			"G_zeros": lsbZeroPercents[1],
+			"B_zeros": lsbZeroPercents[2],
+			"A_zeros": lsbZeroPercents[3],
 		},
 	}, nil
 }
 
+// planeStats returns the anomaly score, channel entropies and channel zero shares
+// of one bit plane from its per-channel zero counts
+func planeStats(zeros [4]int, total int) (float64, [4]float64, [4]float64) {
+	var entropies, zeroPercents [4]float64
+	for c, n := range zeros {
+		zeroPercents[c] = float64(n) / float64(total)
+		entropies[c] = calculateEntropy(zeroPercents[c], 1-zeroPercents[c])
+	}
+	score := calculateAnomalyScore(
+		entropies[0], entropies[1], entropies[2], entropies[3],
+		zeroPercents[0], zeroPercents[1], zeroPercents[2], zeroPercents[3],
+	)
+	return score, entropies, zeroPercents
+}
+
 // calculateEntropy calculates Shannon entropy from probability distribution
 func calculateEntropy(zeroProb, oneProb float64) float64 {
 	// Avoid log(0) errors
@@ -0,0 +1,403 @@
+package lsb
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"math/rand"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+)
+
+// flat returns an opaque single-colour image, whose LSBs carry no noise
+func flat(size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.Set(x, y, color.RGBA{100, 120, 140, 255})
+		}
+	}
+	return img
+}
+
+func TestAnalyzeDistribution(t *testing.T) {
+	data, err := fixtures.Load("clean.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clean, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const size = 128
+	embedded := flat(size)
+	if err := fixtures.EmbedLSB(embedded, fixtures.RandomPayload(size*size*3/8, 1), []int{0, 1, 2}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Noise balances a photo's LSBs with or without a payload, so the pair
+	// tests rather than the distribution detect embedding in it
+	noisy := fixtures.Carrier(size, size, 1)
+	if err := fixtures.EmbedLSB(noisy, fixtures.RandomPayload(size*size*3/8, 1), []int{0, 1, 2}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		img       image.Image
+		anomalous bool
+	}{
+		// Noise balances the LSBs of clean.png and the bit plane above them alike
+		{"clean.png", clean, false},
+		{"flat", flat(size), false},
+		{"flat with random LSBs", embedded, true},
+		{"noisy carrier with random LSBs", noisy, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := AnalyzeDistribution(tt.img)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := result.AnomalyScore > 0.5; got != tt.anomalous {
+				t.Errorf("anomaly score %.2f (entropy %.2f, next plane %.2f), want anomalous %v",
+					result.AnomalyScore, result.Entropy, result.ReferenceEntropy, tt.anomalous)
+			}
+		})
+	}
+}
+
+func TestEntropyHeatmap(t *testing.T) {
+	const size, half = 128, 64
+	quadrants := []struct {
+		name string
+		rect image.Rectangle
+	}{
+		{"top left", image.Rect(0, 0, half, half)},
+		{"top right", image.Rect(half, 0, size, half)},
+		{"bottom left", image.Rect(0, half, half, size)},
+		{"bottom right", image.Rect(half, half, size, size)},
+	}
+
+	tests := []struct {
+		name   string
+		filled []bool // Whether each quadrant holds the payload
+	}{
+		{"no payload", []bool{false, false, false, false}},
+		{"top-left quadrant", []bool{true, false, false, false}},
+		{"bottom half", []bool{false, false, true, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := flat(size)
+			for i, q := range quadrants {
+				if !tt.filled[i] {
+					continue
+				}
+				quadrant := img.SubImage(q.rect).(*image.RGBA)
+				if err := fixtures.EmbedLSB(quadrant, fixtures.RandomPayload(half*half*3/8, int64(i)), []int{0, 1, 2}, 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			heatmap := EntropyHeatmap(img, DefaultHeatmapTile)
+			if heatmap.Bounds() != img.Bounds() {
+				t.Fatalf("heatmap bounds %v, want %v", heatmap.Bounds(), img.Bounds())
+			}
+			for i, q := range quadrants {
+				sum := 0
+				for y := q.rect.Min.Y; y < q.rect.Max.Y; y++ {
+					for x := q.rect.Min.X; x < q.rect.Max.X; x++ {
+						sum += int(heatmap.GrayAt(x, y).Y)
+					}
+				}
+				mean := sum / (half * half)
+				if bright := mean > 200; bright != tt.filled[i] || (!bright && mean > 20) {
+					t.Errorf("%s quadrant has mean brightness %d, want bright %v", q.name, mean, tt.filled[i])
+				}
+			}
+		})
+	}
+}
+
+func TestClassifyEmbedding(t *testing.T) {
+	const size = 128
+	capacity := size * size * 3 / 8
+	tests := []struct {
+		name     string
+		payload  int // Bytes written to the RGB LSBs from the first pixel
+		pattern  string
+		fraction float64
+	}{
+		{"clean", 0, PatternScattered, 0},
+		{"30% front-loaded", capacity * 3 / 10, PatternSequential, 0.3},
+		{"full image", capacity, PatternFullImage, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := fixtures.Carrier(size, size, 2)
+			if err := fixtures.EmbedLSB(img, fixtures.RandomPayload(tt.payload, 2), []int{0, 1, 2}, 0); err != nil {
+				t.Fatal(err)
+			}
+			profile := ClassifyEmbedding(img)
+			if profile.Pattern != tt.pattern || math.Abs(profile.Fraction-tt.fraction) > 0.05 {
+				t.Errorf("got %s over %.2f of the image, want %s over %.2f (p-values %.2f)",
+					profile.Pattern, profile.Fraction, tt.pattern, tt.fraction, profile.PValues)
+			}
+		})
+	}
+}
+
+func TestPVDDetector(t *testing.T) {
+	// pvd hides random bits in the R, G and B differences of the first share of
+	// the horizontal pixel pairs, with the Wu-Tsai ranges
+	ranges := [][2]int{{0, 7}, {8, 15}, {16, 31}, {32, 63}, {64, 127}, {128, 255}}
+	pvd := func(img *image.RGBA, share float64) {
+		rng := rand.New(rand.NewSource(3))
+		pairs := int(float64(len(img.Pix)/8) * share)
+		for p := 0; p < pairs; p++ {
+			for c := 0; c < 3; c++ {
+				i, j := p*8+c, p*8+4+c
+				p1, p2 := int(img.Pix[i]), int(img.Pix[j])
+				d, size := p2-p1, int(absDiff(uint32(p1), uint32(p2)))
+				for _, r := range ranges {
+					if size < r[0] || size > r[1] {
+						continue
+					}
+					newDiff := r[0] + rng.Intn(r[1]-r[0]+1)
+					if d < 0 {
+						newDiff = -newDiff
+					}
+					m := newDiff - d
+					q1, q2 := p1-m/2, p2+m-m/2
+					if q1 >= 0 && q1 <= 255 && q2 >= 0 && q2 <= 255 {
+						img.Pix[i], img.Pix[j] = uint8(q1), uint8(q2)
+					}
+					break
+				}
+			}
+		}
+	}
+
+	const size = 256
+	lsbEmbedded := fixtures.TexturedCarrier(size, size, 4)
+	if err := fixtures.EmbedLSB(lsbEmbedded, fixtures.RandomPayload(size*size*3/8, 4), []int{0, 1, 2}, 0); err != nil {
+		t.Fatal(err)
+	}
+	half, full := fixtures.TexturedCarrier(size, size, 4), fixtures.TexturedCarrier(size, size, 4)
+	pvd(half, 0.5)
+	pvd(full, 1)
+
+	tests := []struct {
+		name     string
+		img      image.Image
+		embedded bool
+	}{
+		{"clean", fixtures.TexturedCarrier(size, size, 4), false},
+		{"full-capacity LSB", lsbEmbedded, false},
+		{"PVD over half the image", half, true},
+		{"PVD over the whole image", full, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probability, details := (&PVDDetector{}).Detect(tt.img)
+			if got := probability > 0.5; got != tt.embedded {
+				t.Errorf("got probability %.2f (%s), want embedded %v", probability, details, tt.embedded)
+			}
+		})
+	}
+}
+
+func TestAnalyzePlaneCorrelation(t *testing.T) {
+	const size = 128
+	payload := fixtures.RandomPayload(2000, 5)
+	embed := func(streams ...[]byte) image.Image {
+		img := fixtures.Carrier(size, size, 5)
+		for c, stream := range streams {
+			if err := fixtures.EmbedLSB(img, stream, []int{c}, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return img
+	}
+
+	tests := []struct {
+		name       string
+		img        image.Image
+		correlated bool
+	}{
+		{"clean", fixtures.Carrier(size, size, 5), false},
+		{"flat", flat(size), false},
+		{"different stream per channel", embed(payload, fixtures.RandomPayload(2000, 6), fixtures.RandomPayload(2000, 7)), false},
+		{"same stream in R, G and B", embed(payload, payload, payload), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := AnalyzePlaneCorrelation(tt.img)
+			if got := result.Correlation > 0.8; got != tt.correlated {
+				t.Errorf("got correlation %.2f (%s), want correlated %v", result.Correlation, result.Describe(), tt.correlated)
+			}
+		})
+	}
+}
+
+func TestAnalyzeParity(t *testing.T) {
+	const size = 128
+	// masked returns the carrier with the given low bits of every sample cleared
+	masked := func(mask uint8) *image.RGBA {
+		img := fixtures.Carrier(size, size, 3)
+		for i := range img.Pix {
+			if i%4 != 3 {
+				img.Pix[i] &^= mask
+			}
+		}
+		return img
+	}
+
+	tests := []struct {
+		name       string
+		img        func(t *testing.T) *image.RGBA
+		normalized bool
+		quantized  bool
+		evenRatio  [2]float64 // Range of the least even channel's even share
+		evenTail   [2]float64 // Range of the all-even share at the end of the image
+	}{
+		{"natural", func(t *testing.T) *image.RGBA { return fixtures.Carrier(size, size, 3) }, false, false, [2]float64{0.45, 0.55}, [2]float64{0, 0}},
+		{"normalized", func(t *testing.T) *image.RGBA { return masked(1) }, true, false, [2]float64{1, 1}, [2]float64{1, 1}},
+		{"normalized, first 30% embedded", func(t *testing.T) *image.RGBA {
+			img := masked(1)
+			if err := fixtures.EmbedLSB(img, fixtures.RandomPayload(size*size*3*3/10/8, 4), []int{0, 1, 2}, 0); err != nil {
+				t.Fatal(err)
+			}
+			return img
+		}, true, false, [2]float64{0.8, 0.9}, [2]float64{0.65, 0.7}},
+		{"drawn in steps of 4", func(t *testing.T) *image.RGBA { return masked(3) }, false, true, [2]float64{1, 1}, [2]float64{0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parity := AnalyzeParity(tt.img(t))
+			if got := parity.Normalized(0.7); got != tt.normalized || parity.Quantized != tt.quantized {
+				t.Errorf("got normalized %v, quantized %v, want %v, %v (%s)", got, parity.Quantized, tt.normalized, tt.quantized, parity.Describe())
+			}
+			if ratio := parity.MinEvenRatio(); ratio < tt.evenRatio[0] || ratio > tt.evenRatio[1] {
+				t.Errorf("got even ratio %.3f, want %.2f to %.2f", ratio, tt.evenRatio[0], tt.evenRatio[1])
+			}
+			if parity.EvenTail < tt.evenTail[0] || parity.EvenTail > tt.evenTail[1] {
+				t.Errorf("got even tail %.3f, want %.2f to %.2f", parity.EvenTail, tt.evenTail[0], tt.evenTail[1])
+			}
+		})
+	}
+}
+
+func TestAnalyzeRecompression(t *testing.T) {
+	// fromJPEG returns a carrier as a PNG saved from a JPEG at quality 80 has it
+	fromJPEG := func(t *testing.T) *image.RGBA {
+		data, err := fixtures.EncodeJPEG(fixtures.Carrier(256, 256, 1), 80)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := image.NewRGBA(decoded.Bounds())
+		draw.Draw(img, img.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+		return img
+	}
+
+	tests := []struct {
+		name       string
+		image      func(t *testing.T) *image.RGBA
+		quality    int // 0 when the image is not JPEG-derived
+		suspicious bool
+		fragile    [2]float64 // Range of the fragile LSB fraction
+	}{
+		{"natural", func(t *testing.T) *image.RGBA { return fixtures.Carrier(256, 256, 1) }, 0, false, [2]float64{0, 0}},
+		{"saved from a JPEG", fromJPEG, 80, false, [2]float64{0, 0.05}},
+		{"saved from a JPEG, first third embedded", func(t *testing.T) *image.RGBA {
+			img := fromJPEG(t)
+			if err := fixtures.EmbedLSB(img, fixtures.RandomPayload(256*256*3/8/3, 2), []int{0, 1, 2}, 0); err != nil {
+				t.Fatal(err)
+			}
+			return img
+		}, 80, true, [2]float64{0.1, 0.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := AnalyzeRecompression(tt.image(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff.Quality != tt.quality || diff.Suspicious != tt.suspicious {
+				t.Errorf("got quality %d, suspicious %v, want %d, %v", diff.Quality, diff.Suspicious, tt.quality, tt.suspicious)
+			}
+			if diff.FragileLSBs < tt.fragile[0] || diff.FragileLSBs > tt.fragile[1] {
+				t.Errorf("got fragile LSB fraction %.3f, want %.2f to %.2f", diff.FragileLSBs, tt.fragile[0], tt.fragile[1])
+			}
+		})
+	}
+}
+
+func TestAnalyzePalette(t *testing.T) {
+	// indexed returns a 128x128 image of 16 gray bands. With twins each band's
+	// entry appears twice in the palette, with near it is followed by one a
+	// level redder, and pixelBit chooses between the pair for each pixel.
+	indexed := func(twins, near bool, pixelBit func(i int) uint8) image.Image {
+		var palette color.Palette
+		for i := 0; i < 16; i++ {
+			c := color.RGBA{uint8(i * 16), uint8(i * 16), uint8(i * 16), 255}
+			palette = append(palette, c)
+			if near {
+				c.R++
+			}
+			if twins || near {
+				palette = append(palette, c)
+			}
+		}
+		img := image.NewPaletted(image.Rect(0, 0, 128, 128), palette)
+		for i := range img.Pix {
+			band := uint8(i % 128 / 8)
+			if twins || near {
+				img.Pix[i] = band*2 + pixelBit(i)
+			} else {
+				img.Pix[i] = band
+			}
+		}
+		return img
+	}
+	rng := rand.New(rand.NewSource(1))
+	payload := func(int) uint8 { return uint8(rng.Intn(2)) }
+
+	tests := []struct {
+		name       string
+		image      image.Image
+		duplicates int
+		near       int
+		suspicious bool
+	}{
+		{"distinct colors", indexed(false, false, nil), 0, 0, false},
+		{"twins choosing a bit per pixel", indexed(true, false, payload), 16, 0, true},
+		{"one pixel on a twin", indexed(true, false, func(i int) uint8 {
+			if i == 0 {
+				return 1
+			}
+			return 0
+		}), 1, 0, false},
+		{"twins one level apart", indexed(false, true, payload), 0, 16, false},
+		{"not indexed", fixtures.Carrier(64, 64, 1), 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := AnalyzePalette(tt.image)
+			if result.Duplicates != tt.duplicates || result.NearDuplicates != tt.near || result.Suspicious != tt.suspicious {
+				t.Errorf("got %d duplicates, %d near duplicates, suspicious %v, want %d, %d, %v",
+					result.Duplicates, result.NearDuplicates, result.Suspicious, tt.duplicates, tt.near, tt.suspicious)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package lsb
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+/*
+This file contains the palette analysis of indexed images. Encoders and color
+quantizers build a palette of distinct colors, so two entries with the same
+color do not come out of them. Palette steganography adds such entries: the
+image looks the same whichever of the twins a pixel points to, and the choice
+carries a bit per pixel. Duplicates that many pixels use, split roughly evenly
+between the twins, are the signature. Entries only one level apart per channel
+are counted separately, since they also look identical but dithering produces
+them too.
+*/
+
+// Palette analysis parameters
+const (
+	paletteNearTolerance = 1    // Channel difference up to which two colors count as nearly identical
+	paletteMinShare      = 0.05 // Share of pixels in duplicated colors above which duplicates are suspicious
+	paletteMinBalance    = 0.5  // Evenness of the split between twins above which duplicates are suspicious
+)
+
+// PaletteAnalysis describes the palette of an indexed image
+type PaletteAnalysis struct {
+	Indexed        bool    // Whether the image is indexed
+	Size           int     // Palette entries
+	Used           int     // Entries at least one pixel points to
+	Duplicates     int     // Used entries with the same color as an earlier used entry
+	NearDuplicates int     // Used entries within one level per channel of an earlier used entry, but not equal
+	Share          float64 // Share of pixels whose color has more than one used entry
+	Balance        float64 // How evenly those pixels are split between the entries of their color (0-1)
+	Suspicious     bool    // Duplicates used by many pixels in even proportions
+}
+
+// AnalyzePalette counts the palette entries of an indexed image that look
+// identical but have distinct indexes, and how the pixels use them
+func AnalyzePalette(img image.Image) *PaletteAnalysis {
+	result := &PaletteAnalysis{}
+	paletted, ok := img.(*image.Paletted)
+	if !ok {
+		return result
+	}
+	result.Indexed = true
+	result.Size = len(paletted.Palette)
+
+	bounds := paletted.Bounds()
+	total := bounds.Dx() * bounds.Dy()
+	if total == 0 {
+		return result
+	}
+	var counts [256]int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := paletted.Pix[paletted.PixOffset(bounds.Min.X, y):][:bounds.Dx()]
+		for _, index := range row {
+			counts[index]++
+		}
+	}
+
+	// Group the used entries by exact color
+	group := make(map[color.NRGBA][]int)
+	var colors []color.NRGBA
+	for i, c := range paletted.Palette {
+		if counts[i] == 0 {
+			continue
+		}
+		result.Used++
+		nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+		if len(group[nc]) > 0 {
+			result.Duplicates++
+		} else {
+			for _, other := range colors {
+				if nearColor(nc, other) {
+					result.NearDuplicates++
+					break
+				}
+			}
+			colors = append(colors, nc)
+		}
+		group[nc] = append(group[nc], i)
+	}
+
+	// The split is even when no twin takes more than its fair share of the color
+	shared, spread, evenSpread := 0, 0.0, 0.0
+	for _, entries := range group {
+		if len(entries) < 2 {
+			continue
+		}
+		pixels, most := 0, 0
+		for _, i := range entries {
+			pixels += counts[i]
+			most = max(most, counts[i])
+		}
+		shared += pixels
+		spread += float64(pixels - most)
+		evenSpread += float64(pixels) * (1 - 1/float64(len(entries)))
+	}
+	result.Share = float64(shared) / float64(total)
+	if evenSpread > 0 {
+		result.Balance = spread / evenSpread
+	}
+	result.Suspicious = result.Duplicates > 0 && result.Share >= paletteMinShare && result.Balance >= paletteMinBalance
+	return result
+}
+
+// Describe explains the measurement in a finding's details
+func (p *PaletteAnalysis) Describe() string {
+	return fmt.Sprintf("%d of the %d used palette entries (of %d) repeat an earlier color; %.0f%% of pixels have a repeated color, with a balance of %.2f between its entries (1 is an even split)",
+		p.Duplicates, p.Used, p.Size, p.Share*100, p.Balance)
+}
+
+// nearColor reports whether two colors differ by at most paletteNearTolerance
+// in every channel
+func nearColor(a, b color.NRGBA) bool {
+	near := func(x, y uint8) bool {
+		return int(x)-int(y) <= paletteNearTolerance && int(y)-int(x) <= paletteNearTolerance
+	}
+	return near(a.R, b.R) && near(a.G, b.G) && near(a.B, b.B) && near(a.A, b.A)
+}
@@ -0,0 +1,151 @@
+package lsb
+
+import (
+	"fmt"
+	"image"
+)
+
+/*
+This file contains the even-parity normalization test. Some LSB tools clear the
+least significant bit of every sample before embedding, so that the image's own
+bits cannot be mistaken for payload, and then write the payload from the start
+of the image. Everything past the payload is left even. A natural image has
+about as many odd samples as even ones, so a channel that is mostly even, or a
+tail of the image that is even throughout, is the fingerprint of such a tool
+whether or not the payload itself looks unusual.
+
+Samples at 0 and at the channel maximum are skipped, since clipped shadows and
+highlights have a fixed parity, and so are pixels equal to their left
+neighbour, which repeat a value rather than add one. Synthetic images drawn in
+steps of 2 or 4 are even too, but clearing one bit leaves the bit above it as
+varied as before, so evenness only counts where that bit is balanced.
+*/
+
+// Parity parameters
+const (
+	paritySegments    = 20   // Raster-order segments, so the tail past a payload can be measured
+	parityMinSamples  = 200  // Counted samples a segment needs before it is judged
+	parityEvenSegment = 0.99 // Even share of a segment that was normalized
+	parityMinTail     = 0.25 // Share of the image an all-even tail needs to count
+	parityMinSecond   = 0.25 // Share of set second bits below which a channel is quantized, not normalized
+)
+
+// ParityAnalysis describes how many of an image's samples are even
+type ParityAnalysis struct {
+	EvenRatio [3]float64 // Share of even R, G and B samples
+	Samples   int        // Samples counted per channel at most
+	EvenTail  float64    // Share of the image, counted from the end, in which every channel is even
+	Quantized bool       // Whether a channel's second bit is as skewed as its first, as in drawn images
+}
+
+// AnalyzeParity measures the share of even samples in each channel of img, and
+// how much of the end of the image is even in every channel
+func AnalyzeParity(img image.Image) *ParityAnalysis {
+	result := &ParityAnalysis{}
+	if img == nil {
+		return result
+	}
+
+	pixelAt, shift := pixelReader(img)
+	bounds := img.Bounds()
+	total := bounds.Dx() * bounds.Dy()
+	if total == 0 {
+		return result
+	}
+	maxSample := uint32(0xFFFF) >> shift
+	segmentSize := (total + paritySegments - 1) / paritySegments
+
+	var even, second, counted [paritySegments][3]int
+	i := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		var prev [3]uint32
+		for x := bounds.Min.X; x < bounds.Max.X; x, i = x+1, i+1 {
+			r, g, b, _ := pixelAt(x, y)
+			samples := [3]uint32{r >> shift, g >> shift, b >> shift}
+			flat := x > bounds.Min.X && samples == prev
+			prev = samples
+			if flat {
+				continue
+			}
+
+			segment := i / segmentSize
+			for c, v := range samples {
+				if v == 0 || v == maxSample {
+					continue
+				}
+				counted[segment][c]++
+				if v&1 == 0 {
+					even[segment][c]++
+				}
+				if v&2 != 0 {
+					second[segment][c]++
+				}
+			}
+		}
+	}
+
+	var allEven, allSecond, allCounted [3]int
+	tailStart, tailOpen := paritySegments, true
+	for s := paritySegments - 1; s >= 0; s-- {
+		normalized, judged := true, true
+		for c := 0; c < 3; c++ {
+			allEven[c] += even[s][c]
+			allSecond[c] += second[s][c]
+			allCounted[c] += counted[s][c]
+			if counted[s][c] < parityMinSamples {
+				judged = false
+			} else if float64(even[s][c]) < parityEvenSegment*float64(counted[s][c]) || !balanced(second[s][c], counted[s][c]) {
+				normalized = false
+			}
+		}
+		// Segments too flat to judge neither extend nor end the tail
+		switch {
+		case !tailOpen || !judged:
+		case normalized:
+			tailStart = s
+		default:
+			tailOpen = false
+		}
+	}
+	for c := 0; c < 3; c++ {
+		if allCounted[c] > 0 {
+			result.EvenRatio[c] = float64(allEven[c]) / float64(allCounted[c])
+		}
+		if !balanced(allSecond[c], allCounted[c]) {
+			result.Quantized = true
+		}
+		result.Samples = max(result.Samples, allCounted[c])
+	}
+	if tailStart < paritySegments {
+		result.EvenTail = float64(total-tailStart*segmentSize) / float64(total)
+	}
+	return result
+}
+
+// balanced reports whether ones of n bits is far enough from all or none to be
+// natural variation
+func balanced(ones, n int) bool {
+	share := float64(ones) / float64(max(n, 1))
+	return share >= parityMinSecond && share <= 1-parityMinSecond
+}
+
+// MinEvenRatio returns the even share of the least even channel
+func (p *ParityAnalysis) MinEvenRatio() float64 {
+	return min(p.EvenRatio[0], p.EvenRatio[1], p.EvenRatio[2])
+}
+
+// Normalized reports whether the image looks normalized to even values: every
+// channel is more than evenRatio even, or enough of the end of the image is even
+// throughout, and the channels are not simply quantized
+func (p *ParityAnalysis) Normalized(evenRatio float64) bool {
+	if p.Quantized {
+		return false
+	}
+	return p.MinEvenRatio() > evenRatio || p.EvenTail >= parityMinTail
+}
+
+// Describe explains the measurement in a finding's details
+func (p *ParityAnalysis) Describe() string {
+	return fmt.Sprintf("R %.1f%%, G %.1f%%, B %.1f%% of %d samples are even (about 50%% is natural); the last %.0f%% of the image is even in every channel",
+		p.EvenRatio[0]*100, p.EvenRatio[1]*100, p.EvenRatio[2]*100, p.Samples, p.EvenTail*100)
+}
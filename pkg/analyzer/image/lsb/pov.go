@@ -0,0 +1,156 @@
+package lsb
+
+import (
+	"fmt"
+	"image"
+
+	"DeSteGo/pkg/analyzer/stats"
+)
+
+/*
+This file contains the per-channel pair-of-values test. LSB replacement moves
+samples between the values 2k and 2k+1 but never out of the pair, so it
+equalizes the counts of each pair in the channels it writes to. The test builds
+a 256-bin histogram per channel and runs the chi-square test over its 128 value
+pairs: a p-value close to 1 means the pairs are equalized.
+
+Each channel is tested on its own, so payloads written to only one or two
+channels are not diluted by the clean ones, both over the whole image and over
+consecutive segments of it in raster order. A sequential payload passes the
+test in the segments it covers and fails it in the ones after. For 16-bit
+images the low byte of each sample is histogrammed.
+*/
+
+// Pair-of-values parameters
+const (
+	povSegments  = 20   // Raster-order segments tested
+	povEmbeddedP = 0.5  // Segment p-value above which a segment counts as equalized
+	povCleanP    = 0.1  // p-value below which a segment or channel counts as clean
+	povWholeP    = 0.95 // Whole-image p-value above which a channel counts as equalized throughout
+	povMinPrefix = 0.1  // Equalized share of the image a sequential payload needs
+)
+
+// channelNames names the channels of the per-channel tests
+var channelNames = [3]string{"R", "G", "B"}
+
+// PoVAnalysis is the pair-of-values chi-square test of each color channel
+type PoVAnalysis struct {
+	PValues   [3]float64              // R, G and B p-value over the whole image
+	Fractions [3]float64              // Share of the image from its start over which each channel is equalized
+	Segments  [3][povSegments]float64 // p-value of each channel in each twentieth of the image in raster order
+}
+
+// AnalyzePairsOfValues runs the pair-of-values chi-square test on the R, G and B
+// histograms of img, over the whole image and over each of its segments
+func AnalyzePairsOfValues(img image.Image) *PoVAnalysis {
+	result := &PoVAnalysis{}
+	if img == nil {
+		return result
+	}
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	total := width * bounds.Dy()
+	if total < povSegments {
+		return result
+	}
+
+	pixelAt, shift := pixelReader(img)
+	var whole [3][256]int
+	segmentSize := total / povSegments
+	for s := 0; s < povSegments; s++ {
+		var hist [3][256]int
+		end := (s + 1) * segmentSize
+		if s == povSegments-1 {
+			end = total
+		}
+		for i := s * segmentSize; i < end; i++ {
+			r, g, b, _ := pixelAt(bounds.Min.X+i%width, bounds.Min.Y+i/width)
+			hist[0][(r>>shift)&0xFF]++
+			hist[1][(g>>shift)&0xFF]++
+			hist[2][(b>>shift)&0xFF]++
+		}
+		for c := range hist {
+			result.Segments[c][s] = histogramChiSquare(&hist[c])
+			for v, n := range hist[c] {
+				whole[c][v] += n
+			}
+		}
+	}
+
+	for c := range whole {
+		result.PValues[c] = histogramChiSquare(&whole[c])
+		leading := 0
+		for leading < povSegments && result.Segments[c][leading] > povEmbeddedP {
+			leading++
+		}
+		result.Fractions[c] = float64(leading) / povSegments
+	}
+	return result
+}
+
+// histogramChiSquare returns the pair-of-values chi-square p-value of a 256-bin
+// histogram
+func histogramChiSquare(hist *[256]int) float64 {
+	var chiSquare float64
+	pairs := 0
+	for v := 0; v < len(hist); v += 2 {
+		even, odd := float64(hist[v]), float64(hist[v+1])
+		if even+odd < profileMinPairSize {
+			continue
+		}
+		expected := (even + odd) / 2
+		chiSquare += (even - expected) * (even - expected) / expected
+		pairs++
+	}
+	if pairs < 2 {
+		return 0
+	}
+	return stats.ChiSquarePValue(chiSquare, pairs-1)
+}
+
+// Sequential returns the channels that are equalized from the start of the image
+// and clean after it, the trace of a sequential payload that ends part way
+// through. Flat regions of natural images pass the test too, so the segments
+// after the run must be clean right away, skipping the one the payload may end
+// in, and mostly clean for the rest of the image.
+func (p *PoVAnalysis) Sequential() []string {
+	var channels []string
+	for c, name := range channelNames {
+		leading := int(p.Fractions[c] * povSegments)
+		if p.Fractions[c] < povMinPrefix || leading+3 > povSegments {
+			continue
+		}
+		after := p.Segments[c][leading+1:]
+		if max(after[0], after[1]) < povEmbeddedP && median(after) < povCleanP {
+			channels = append(channels, name)
+		}
+	}
+	return channels
+}
+
+// Selective returns the channels equalized over the whole image when at least
+// one other channel is clean, the trace of a payload written to only some
+// channels. It is empty when every channel is equalized, which noisy images
+// also are.
+func (p *PoVAnalysis) Selective() []string {
+	var equalized []string
+	clean := false
+	for c, name := range channelNames {
+		switch {
+		case p.PValues[c] > povWholeP:
+			equalized = append(equalized, name)
+		case p.PValues[c] < povCleanP:
+			clean = true
+		}
+	}
+	if !clean {
+		return nil
+	}
+	return equalized
+}
+
+// Describe explains the measurement in a finding's details
+func (p *PoVAnalysis) Describe() string {
+	return fmt.Sprintf("pair-of-values p-value R %.2f, G %.2f, B %.2f over the whole image; equalized over the first R %.0f%%, G %.0f%%, B %.0f%%",
+		p.PValues[0], p.PValues[1], p.PValues[2], p.Fractions[0]*100, p.Fractions[1]*100, p.Fractions[2]*100)
+}
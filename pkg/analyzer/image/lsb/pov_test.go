@@ -0,0 +1,59 @@
+package lsb
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+)
+
+func TestAnalyzePairsOfValues(t *testing.T) {
+	tests := []struct {
+		name       string
+		channels   []int   // Channels the payload is written to
+		share      float64 // Share of their LSBs it replaces
+		sequential []string
+		selective  []string
+	}{
+		{"clean", nil, 0, nil, nil},
+		{"B 50%", []int{2}, 0.5, []string{"B"}, nil},
+		{"B 100%", []int{2}, 1, nil, []string{"B"}},
+		{"RG 50%", []int{0, 1}, 0.5, []string{"R", "G"}, nil},
+		{"RG 100%", []int{0, 1}, 1, nil, []string{"R", "G"}},
+		{"RGB 50%", []int{0, 1, 2}, 0.5, []string{"R", "G", "B"}, nil},
+		{"RGB 100%", []int{0, 1, 2}, 1, nil, nil},
+	}
+	const size = 256
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := fixtures.Carrier(size, size, 1)
+			payload := fixtures.RandomPayload(int(tt.share*size*size)*len(tt.channels)/8, 2)
+			if err := fixtures.EmbedLSB(img, payload, tt.channels, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			pov := AnalyzePairsOfValues(img)
+			if got := pov.Sequential(); !reflect.DeepEqual(got, tt.sequential) {
+				t.Errorf("sequential channels %v, want %v (%s)", got, tt.sequential, pov.Describe())
+			}
+			if got := pov.Selective(); !reflect.DeepEqual(got, tt.selective) {
+				t.Errorf("selective channels %v, want %v (%s)", got, tt.selective, pov.Describe())
+			}
+			for c := 0; c < 3; c++ {
+				embedded := false
+				for _, e := range tt.channels {
+					embedded = embedded || e == c
+				}
+				switch {
+				case !embedded && pov.PValues[c] >= povCleanP:
+					t.Errorf("clean %s channel has p-value %.2f", channelNames[c], pov.PValues[c])
+				case embedded && tt.share == 1 && pov.PValues[c] <= povWholeP:
+					t.Errorf("fully embedded %s channel has p-value %.2f", channelNames[c], pov.PValues[c])
+				case embedded && tt.share < 1 && math.Abs(pov.Fractions[c]-tt.share) > 1.0/povSegments:
+					t.Errorf("%s channel equalized over %.2f of the image, want %.2f", channelNames[c], pov.Fractions[c], tt.share)
+				}
+			}
+		})
+	}
+}
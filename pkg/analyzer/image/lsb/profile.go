@@ -0,0 +1,148 @@
+package lsb
+
+import (
+	"image"
+	"sort"
+
+	"DeSteGo/pkg/analyzer/stats"
+)
+
+// Embedding patterns reported by ClassifyEmbedding
+const (
+	PatternSequential = "front-loaded sequential"
+	PatternFullImage  = "full-image"
+	PatternScattered  = "scattered/none"
+)
+
+// Profile parameters
+const (
+	profileSegments    = 20  // Number of raster-order segments tested
+	profileEmbeddedP   = 0.9 // Chi-square p-value above which a segment counts as embedded
+	profileCleanP      = 0.5 // p-value below which a segment counts as clean
+	profileFullShare   = 0.9 // Share of embedded segments needed for a full-image classification
+	profileMinPairSize = 5   // Samples a value pair needs before it is used
+)
+
+// EmbeddingProfile describes where in the image LSB replacement was detected
+type EmbeddingProfile struct {
+	Pattern  string    // One of the Pattern constants
+	Fraction float64   // Share of the image (in raster order) that looks embedded
+	PValues  []float64 // Pair-of-values chi-square p-value per segment
+}
+
+// ClassifyEmbedding runs the pair-of-values chi-square test on consecutive
+// segments of the image in raster order.
+//
+// LSB replacement equalizes the counts of each value pair (2k, 2k+1), so
+// embedded segments have p-values close to 1 while clean ones are close to 0.
+// Tools that write sequentially leave a leading run of embedded segments whose
+// length is the payload size; full-capacity embedding covers every segment.
+// PRNG-scattered embedding at a low rate does not equalize any segment and is
+// indistinguishable from a clean image with this test.
+func ClassifyEmbedding(img image.Image) *EmbeddingProfile {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	total := width * bounds.Dy()
+	profile := &EmbeddingProfile{Pattern: PatternScattered}
+	if total < profileSegments {
+		return profile
+	}
+
+	pixelAt, shift := pixelReader(img)
+	segmentSize := total / profileSegments
+	for s := 0; s < profileSegments; s++ {
+		counts := make(map[uint32]int)
+		for i := s * segmentSize; i < (s+1)*segmentSize; i++ {
+			x, y := bounds.Min.X+i%width, bounds.Min.Y+i/width
+			r, g, b, _ := pixelAt(x, y)
+			counts[r>>shift]++
+			counts[g>>shift]++
+			counts[b>>shift]++
+		}
+		profile.PValues = append(profile.PValues, pairChiSquare(counts))
+	}
+
+	leading, embedded, lowest := 0, 0, 1.0
+	for i, p := range profile.PValues {
+		if p > profileEmbeddedP {
+			embedded++
+			if leading == i {
+				leading++
+			}
+		}
+		if p < lowest {
+			lowest = p
+		}
+	}
+
+	// Noisy natural images also pass the test in places, so full-image embedding
+	// needs (almost) every segment to pass and none to clearly fail, and a
+	// sequential payload needs a clear drop after the leading run
+	switch {
+	case float64(embedded) >= profileFullShare*profileSegments && lowest >= profileCleanP:
+		profile.Pattern = PatternFullImage
+		profile.Fraction = 1
+	case leading > 0 && median(profile.PValues[leading:]) < profileCleanP:
+		profile.Pattern = PatternSequential
+		profile.Fraction = float64(leading) / profileSegments
+	}
+	return profile
+}
+
+// median returns the median of values
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// pairChiSquare returns the pair-of-values chi-square p-value of a histogram
+func pairChiSquare(counts map[uint32]int) float64 {
+	var chiSquare float64
+	pairs := 0
+	for v, n := range counts {
+		if v&1 == 1 {
+			continue
+		}
+		even, odd := float64(n), float64(counts[v|1])
+		if even+odd < profileMinPairSize {
+			continue
+		}
+		expected := (even + odd) / 2
+		chiSquare += (even - expected) * (even - expected) / expected
+		pairs++
+	}
+	if pairs < 2 {
+		return 0
+	}
+	return stats.ChiSquarePValue(chiSquare, pairs-1)
+}
+
+// MaxPValue returns the highest segment p-value, which stays close to 0 when no
+// part of the image was embedded
+func (p *EmbeddingProfile) MaxPValue() float64 {
+	highest := 0.0
+	for _, v := range p.PValues {
+		if v > highest {
+			highest = v
+		}
+	}
+	return highest
+}
+
+// MeanPValue returns the average segment p-value, which grows with the share of
+// the image whose value pairs are equalized
+func (p *EmbeddingProfile) MeanPValue() float64 {
+	if len(p.PValues) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range p.PValues {
+		sum += v
+	}
+	return sum / float64(len(p.PValues))
+}
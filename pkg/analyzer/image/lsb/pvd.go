@@ -0,0 +1,127 @@
+package lsb
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+/*
+This file contains the pixel-value differencing (PVD) detector. PVD (Wu and
+Tsai) hides more bits in pixel pairs whose difference is large, so it leaves the
+LSB planes of smooth areas untouched and is missed by the LSB tests. It splits
+the differences 0-255 into ranges of growing width and replaces a pair's
+difference with the lower bound of its range plus the embedded bits. The
+differences in each range become uniformly distributed, so the difference
+histogram turns into a staircase: flat inside each range with a step down at
+every range boundary. A natural image's histogram decays smoothly across the
+boundaries.
+*/
+
+// pvdBoundaries are the lower bounds of the Wu-Tsai ranges 8-15, 16-31, 32-63
+// and 64-127. The boundary at 128 is not tested because few pairs differ that much.
+var pvdBoundaries = []int{8, 16, 32, 64}
+
+// PVD detector parameters
+const (
+	pvdGroup      = 2  // Histogram bins summed on each side of a boundary
+	pvdMinSamples = 50 // Pairs each side of a boundary needs before it is tested
+)
+
+// PVDDetector detects pixel-value differencing steganography from the
+// histogram of differences between horizontally adjacent pixel pairs
+type PVDDetector struct{}
+
+// Name returns the detector name
+func (d *PVDDetector) Name() string { return "PVD" }
+
+// Detect returns the probability (0.0-1.0) that PVD embedding was used and a
+// human-readable explanation of the statistic behind it
+func (d *PVDDetector) Detect(img image.Image) (float64, string) {
+	hist := DifferenceHistogram(img)
+
+	var steps []string
+	excessSum, tested := 0.0, 0
+	for _, boundary := range pvdBoundaries {
+		excess, ok := boundaryStep(hist, boundary)
+		if !ok {
+			continue
+		}
+		excessSum += excess
+		tested++
+		steps = append(steps, fmt.Sprintf("%d: %+.2f", boundary, excess))
+	}
+	if tested == 0 {
+		return 0, "too few high-contrast pixel pairs to test"
+	}
+
+	// A staircase drops by about ln 2 more than the smooth trend at each
+	// boundary, since each range is twice as wide as the one before
+	mean := excessSum / float64(tested)
+	probability := math.Max(0, math.Min(1, mean/math.Ln2))
+	return probability, fmt.Sprintf("difference histogram steps at range boundaries (log excess over the trend) %v", steps)
+}
+
+// DifferenceHistogram counts the absolute differences between the pixels of
+// each non-overlapping horizontal pair, (0,1), (2,3) and so on, in the R, G and
+// B channels. 16-bit samples are compared by their high byte.
+func DifferenceHistogram(img image.Image) [256]int {
+	var hist [256]int
+	if img == nil {
+		return hist
+	}
+
+	pixelAt, _ := pixelReader(img)
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x+1 < bounds.Max.X; x += 2 {
+			r1, g1, b1, _ := pixelAt(x, y)
+			r2, g2, b2, _ := pixelAt(x+1, y)
+			hist[absDiff(r1>>8, r2>>8)]++
+			hist[absDiff(g1>>8, g2>>8)]++
+			hist[absDiff(b1>>8, b2>>8)]++
+		}
+	}
+	return hist
+}
+
+// boundaryStep compares the drop of the histogram across a range boundary with
+// the drop expected from the slopes inside the two ranges next to it. It
+// returns the difference in log counts and false when there are too few samples.
+func boundaryStep(hist [256]int, boundary int) (float64, bool) {
+	left, right := boundary/2, boundary // Widths of the ranges below and above the boundary
+	if boundary == 8 {
+		left = 8 // The first range, 0-7, is as wide as the second
+	}
+
+	sum := func(from int) float64 {
+		s := 0
+		for i := from; i < from+pvdGroup; i++ {
+			s += hist[i]
+		}
+		return float64(s)
+	}
+
+	leftStart, leftEnd := sum(boundary-left), sum(boundary-pvdGroup)
+	rightStart, rightEnd := sum(boundary), sum(boundary+right-pvdGroup)
+	for _, n := range []float64{leftStart, leftEnd, rightStart, rightEnd} {
+		if n < pvdMinSamples {
+			return 0, false
+		}
+	}
+
+	// Log-count slopes per bin inside each range, and the drop across the boundary
+	leftSlope := math.Log(leftStart/leftEnd) / float64(left-pvdGroup)
+	rightSlope := math.Log(rightStart/rightEnd) / float64(right-pvdGroup)
+	cross := math.Log(leftEnd / rightStart)
+	expected := float64(pvdGroup) * (leftSlope + rightSlope) / 2
+	return cross - expected, true
+}
+
+// absDiff returns |a - b|
+func absDiff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,195 @@
+package lsb
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+)
+
+/*
+This file contains the re-encode diff test. Many lossless images are screenshots
+or conversions of a JPEG, and decoding a JPEG gives pixels that survive another
+JPEG round trip at the same quality: every 8x8 block comes back bit for bit.
+LSB embedding moves the pixels off that lattice, so the blocks that carry
+payload no longer round-trip and their LSBs come back changed ("fragile").
+
+The quality is found by round-tripping a crop from the middle of the image at
+every setting of Go's encoder, which uses the standard IJG tables and 4:2:0
+chroma subsampling. Images saved from other JPEG encoders or subsampling modes
+do not round-trip at any quality and are not judged, and neither is an image
+whose every block was embedded in.
+*/
+
+// Re-encode diff parameters
+const (
+	recompressCrop       = 128 // Side of the crop used to find the quality, in pixels
+	recompressSegments   = 20  // Raster-order segments of blocks, so a payload at the start is not diluted
+	recompressMinBlocks  = 16  // Blocks a segment needs before it is judged
+	recompressCompatible = 0.5 // Block agreement above which the image counts as JPEG-derived
+	recompressIntact     = 0.8 // Agreement the best segment needs before segments are compared
+	recompressBroken     = 0.1 // Segment agreement below which the segment counts as embedded
+)
+
+// RecompressionDiff describes how an image survives a JPEG round trip
+type RecompressionDiff struct {
+	Quality      int     // Go JPEG quality that reproduced most blocks, 0 when the image is not JPEG-derived
+	Compatible   float64 // Share of 8x8 blocks reproduced exactly at Quality
+	FragileLSBs  float64 // Share of R, G and B LSBs changed by the round trip
+	Suspicious   bool    // Whether a segment of blocks stopped round-tripping
+	Intact       float64 // Block agreement of the best segment
+	Broken       float64 // Block agreement of the worst segment
+	SegmentIndex int     // Worst segment
+}
+
+// AnalyzeRecompression round-trips an image through JPEG at the quality it
+// appears to have been saved with and compares the pixels
+func AnalyzeRecompression(img image.Image) (*RecompressionDiff, error) {
+	result := &RecompressionDiff{}
+	if img == nil {
+		return result, nil
+	}
+	switch img.(type) {
+	case *image.NRGBA64, *image.RGBA64, *image.Gray16:
+		return result, nil // JPEG only holds 8-bit samples
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() < recompressCrop/2 || bounds.Dy() < recompressCrop/2 {
+		return result, nil
+	}
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	// Find the quality on a crop aligned to the 16x16 chroma blocks
+	crop := centerCrop(src.Bounds(), recompressCrop)
+	sample := src.SubImage(crop).(*image.RGBA)
+	for quality := 1; quality <= 100; quality++ {
+		out, err := jpegRoundTrip(sample, quality)
+		if err != nil {
+			return nil, err
+		}
+		if agree := blockAgreement(sample, out, nil); agree > result.Compatible {
+			result.Compatible, result.Quality = agree, quality
+		}
+	}
+	if result.Compatible < recompressCompatible {
+		result.Quality, result.Compatible = 0, 0
+		return result, nil
+	}
+
+	out, err := jpegRoundTrip(src, result.Quality)
+	if err != nil {
+		return nil, err
+	}
+	var segments [recompressSegments][2]int
+	result.Compatible = blockAgreement(src, out, &segments)
+	result.FragileLSBs = fragileLSBs(src, out)
+
+	result.Broken = 1
+	for s, counts := range segments {
+		if counts[1] < recompressMinBlocks {
+			continue
+		}
+		agree := float64(counts[0]) / float64(counts[1])
+		if agree > result.Intact {
+			result.Intact = agree
+		}
+		if agree < result.Broken {
+			result.Broken, result.SegmentIndex = agree, s
+		}
+	}
+	result.Suspicious = result.Intact >= recompressIntact && result.Broken <= recompressBroken
+	return result, nil
+}
+
+// Describe explains the measurement in a finding's details
+func (d *RecompressionDiff) Describe() string {
+	return fmt.Sprintf("At JPEG quality %d, %.0f%% of 8x8 blocks round-trip exactly and %.1f%% of LSBs change; blocks in segment %d of %d round-trip %.0f%% of the time, against %.0f%% in the best segment",
+		d.Quality, d.Compatible*100, d.FragileLSBs*100, d.SegmentIndex+1, recompressSegments, d.Broken*100, d.Intact*100)
+}
+
+// centerCrop returns a square of up to size pixels from the middle of r,
+// aligned to 16 pixels from r's origin
+func centerCrop(r image.Rectangle, size int) image.Rectangle {
+	w, h := min(size, r.Dx())&^15, min(size, r.Dy())&^15
+	x := ((r.Dx() - w) / 2) &^ 15
+	y := ((r.Dy() - h) / 2) &^ 15
+	return image.Rect(r.Min.X+x, r.Min.Y+y, r.Min.X+x+w, r.Min.Y+y+h)
+}
+
+// jpegRoundTrip encodes img as a JPEG at quality and decodes it again
+func jpegRoundTrip(img *image.RGBA, quality int) (image.Image, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode JPEG: %w", err)
+	}
+	out, err := jpeg.Decode(&buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JPEG: %w", err)
+	}
+	return out, nil
+}
+
+// blockAgreement returns the share of whole 8x8 blocks of a whose R, G and B
+// values are unchanged in b, whose origin is at a's minimum point. When
+// segments is not nil it receives the agreeing and total blocks per raster
+// segment.
+func blockAgreement(a *image.RGBA, b image.Image, segments *[recompressSegments][2]int) float64 {
+	bounds := a.Bounds()
+	cols, rows := bounds.Dx()/8, bounds.Dy()/8
+	if cols == 0 || rows == 0 {
+		return 0
+	}
+	segmentSize := (cols*rows + recompressSegments - 1) / recompressSegments
+
+	agreeing := 0
+	for by := 0; by < rows; by++ {
+		for bx := 0; bx < cols; bx++ {
+			same := blockUnchanged(a, b, bounds.Min.X+bx*8, bounds.Min.Y+by*8)
+			if same {
+				agreeing++
+			}
+			if segments != nil {
+				s := (by*cols + bx) / segmentSize
+				segments[s][1]++
+				if same {
+					segments[s][0]++
+				}
+			}
+		}
+	}
+	return float64(agreeing) / float64(cols*rows)
+}
+
+// blockUnchanged reports whether the 8x8 block of a at (x0, y0) is identical in b
+func blockUnchanged(a *image.RGBA, b image.Image, x0, y0 int) bool {
+	origin := a.Bounds().Min
+	for y := y0; y < y0+8; y++ {
+		for x := x0; x < x0+8; x++ {
+			c := a.RGBAAt(x, y)
+			r, g, bl, _ := b.At(x-origin.X, y-origin.Y).RGBA()
+			if uint32(c.R) != r>>8 || uint32(c.G) != g>>8 || uint32(c.B) != bl>>8 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// fragileLSBs returns the share of R, G and B LSBs of a that differ in b
+func fragileLSBs(a *image.RGBA, b image.Image) float64 {
+	bounds := a.Bounds()
+	changed := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := a.RGBAAt(x, y)
+			r, g, bl, _ := b.At(x, y).RGBA()
+			changed += int((uint32(c.R) ^ r>>8) & 1)
+			changed += int((uint32(c.G) ^ g>>8) & 1)
+			changed += int((uint32(c.B) ^ bl>>8) & 1)
+		}
+	}
+	return float64(changed) / float64(bounds.Dx()*bounds.Dy()*3)
+}
@@ -0,0 +1,326 @@
+package png
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/analyzer/carve"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the analysis of the color and pixel-size chunks: gAMA,
+cHRM, sRGB, iCCP and pHYs. Decoders and viewers mostly ignore them, so a value
+no encoder writes, a chunk that appears twice or after the image data, or both
+sRGB and iCCP in one file point to a file that was edited by hand. The iCCP
+chunk holds a zlib-compressed ICC profile that can be of any size and is a
+common hiding spot: it is inflated and checked for data after the zlib stream,
+data past the size the profile declares, bytes inside the profile that no tag
+refers to and embedded files.
+*/
+
+// Limits of the iCCP analysis
+const (
+	maxICCProfile    = 16 << 20  // Bytes an iCCP profile is inflated to at most
+	iccpLargeProfile = 128 << 10 // Profile size above which a profile counts as unusually large
+	iccHeaderSize    = 128       // Size of the fixed ICC profile header
+	iccSlackMin      = 64        // Non-zero unreferenced profile bytes that count as hidden data
+)
+
+// colorChunkTypes are the chunks this file checks, in the order they are listed
+var colorChunkTypes = []string{"gAMA", "cHRM", "sRGB", "iCCP", "pHYs"}
+
+// ICCProfile is the profile of an iCCP chunk
+type ICCProfile struct {
+	Name         string // Profile name given in the chunk
+	Compressed   int    // Size of the compressed profile
+	Data         []byte // Inflated profile
+	Trailing     int    // Bytes after the zlib stream in the chunk
+	DeclaredSize int    // Profile size given in the profile header
+	Signature    bool   // The header holds the "acsp" signature
+	Unreferenced int    // Non-zero bytes inside the declared size outside the header, tag table and tags
+	Err          error  // Why the profile did not inflate, if it did not
+}
+
+// splitICCP returns the profile name and the compressed profile of an iCCP
+// chunk payload
+func splitICCP(data []byte) (name, compressed []byte, err error) {
+	name, rest, ok := bytes.Cut(data, []byte{0})
+	if !ok || len(name) == 0 || len(name) > 79 {
+		return nil, nil, fmt.Errorf("profile name of %d bytes is not terminated or out of range", len(name))
+	}
+	if len(rest) < 1 || rest[0] != 0 {
+		return nil, nil, errors.New("unknown compression method")
+	}
+	return name, rest[1:], nil
+}
+
+// ParseICCProfile reads the name and profile of an iCCP chunk payload
+func ParseICCProfile(data []byte) (*ICCProfile, error) {
+	name, stream, err := splitICCP(data)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &ICCProfile{Name: string(latin1(name)), Compressed: len(stream)}
+	compressed := bytes.NewReader(stream)
+	r, err := zlib.NewReader(compressed)
+	if err != nil {
+		profile.Err = err
+		return profile, nil
+	}
+	defer r.Close()
+	profile.Data, profile.Err = io.ReadAll(io.LimitReader(r, maxICCProfile))
+	if profile.Err == nil && len(profile.Data) < maxICCProfile {
+		// The zlib reader reads the checksum straight from compressed, so what
+		// is left follows the stream
+		profile.Trailing = compressed.Len()
+	}
+
+	if len(profile.Data) >= iccHeaderSize {
+		profile.DeclaredSize = int(binary.BigEndian.Uint32(profile.Data))
+		profile.Signature = string(profile.Data[36:40]) == "acsp"
+		profile.Unreferenced = unreferencedBytes(profile.Data)
+	}
+	return profile, nil
+}
+
+// unreferencedBytes counts the non-zero bytes of an ICC profile that lie inside
+// its declared size but outside the header, the tag table and the tags
+func unreferencedBytes(data []byte) int {
+	size := min(int(binary.BigEndian.Uint32(data)), len(data))
+	if size < iccHeaderSize+4 {
+		return 0
+	}
+	covered := make([]bool, size)
+	mark := func(start, length int) {
+		for i := max(start, 0); i < start+length && i < size; i++ {
+			covered[i] = true
+		}
+	}
+
+	count := int(binary.BigEndian.Uint32(data[iccHeaderSize:]))
+	table := iccHeaderSize + 4
+	if count > (size-table)/12 {
+		return 0 // A tag table that does not fit leaves nothing to compare against
+	}
+	mark(0, table+12*count)
+	for i := 0; i < count; i++ {
+		entry := data[table+12*i:]
+		offset := int(binary.BigEndian.Uint32(entry[4:]))
+		length := int(binary.BigEndian.Uint32(entry[8:]))
+		if offset < 0 || length < 0 {
+			continue
+		}
+		mark(offset, length+3) // Tags are padded to four bytes
+	}
+
+	unreferenced := 0
+	for i, c := range covered {
+		if !c && data[i] != 0 {
+			unreferenced++
+		}
+	}
+	return unreferenced
+}
+
+// colorChunkProblems returns the malformed values, repeats and misplaced
+// chunks among the color and pixel-size chunks
+func colorChunkProblems(chunks []Chunk) []string {
+	var problems []string
+	seen := map[string]int{}
+	afterPLTE, afterIDAT := false, false
+	for _, chunk := range chunks {
+		switch chunk.Type {
+		case "PLTE":
+			afterPLTE = true
+			continue
+		case "IDAT":
+			afterIDAT = true
+			continue
+		case "gAMA", "cHRM", "sRGB", "iCCP", "pHYs":
+		default:
+			continue
+		}
+
+		seen[chunk.Type]++
+		if seen[chunk.Type] == 2 {
+			problems = append(problems, fmt.Sprintf("more than one %s chunk", chunk.Type))
+		}
+		switch {
+		case afterIDAT:
+			problems = append(problems, fmt.Sprintf("%s after the image data", chunk.Type))
+		case afterPLTE && chunk.Type != "pHYs":
+			problems = append(problems, fmt.Sprintf("%s after PLTE", chunk.Type))
+		}
+		if problem := colorChunkValue(chunk); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	if seen["sRGB"] > 0 && seen["iCCP"] > 0 {
+		problems = append(problems, "both sRGB and iCCP")
+	}
+	return problems
+}
+
+// colorChunkValue returns what is wrong with the payload of a color or
+// pixel-size chunk, or "" when it holds values an encoder writes
+func colorChunkValue(chunk Chunk) string {
+	d := chunk.Data
+	switch chunk.Type {
+	case "gAMA":
+		if len(d) != 4 {
+			return fmt.Sprintf("gAMA of %d bytes instead of 4", len(d))
+		}
+		// Stored as 100000 / gamma; encoders write gammas between about 0.1 and 10
+		if gamma := binary.BigEndian.Uint32(d); gamma < 10000 || gamma > 1000000 {
+			return fmt.Sprintf("gAMA value %d outside the plausible range", gamma)
+		}
+	case "cHRM":
+		if len(d) != 32 {
+			return fmt.Sprintf("cHRM of %d bytes instead of 32", len(d))
+		}
+		// White point and primaries as x, y pairs times 100000
+		for i := 0; i < 32; i += 8 {
+			x, y := binary.BigEndian.Uint32(d[i:]), binary.BigEndian.Uint32(d[i+4:])
+			if y == 0 || uint64(x)+uint64(y) > 100000 {
+				return fmt.Sprintf("cHRM chromaticity (%d, %d) is not a color", x, y)
+			}
+		}
+	case "sRGB":
+		if len(d) != 1 {
+			return fmt.Sprintf("sRGB of %d bytes instead of 1", len(d))
+		}
+		if d[0] > 3 {
+			return fmt.Sprintf("sRGB rendering intent %d", d[0])
+		}
+	case "iCCP":
+		if _, _, err := splitICCP(d); err != nil {
+			return "iCCP " + err.Error()
+		}
+	case "pHYs":
+		if len(d) != 9 {
+			return fmt.Sprintf("pHYs of %d bytes instead of 9", len(d))
+		}
+		if d[8] > 1 {
+			return fmt.Sprintf("pHYs unit %d", d[8])
+		}
+		if binary.BigEndian.Uint32(d) == 0 || binary.BigEndian.Uint32(d[4:]) == 0 {
+			return "pHYs of zero pixels per unit"
+		}
+	}
+	return ""
+}
+
+// analyzeColorChunks reports malformed color and pixel-size chunks and data
+// hidden in the ICC profile of an iCCP chunk
+func analyzeColorChunks(chunks []Chunk, filePath string, options analyzer.AnalysisOptions, result *models.AnalysisResult) {
+	var present []string
+	for _, chunkType := range colorChunkTypes {
+		for _, chunk := range chunks {
+			if chunk.Type == chunkType {
+				present = append(present, chunkType)
+				break
+			}
+		}
+	}
+	if len(present) == 0 {
+		return
+	}
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	result.Details["color_chunks"] = present
+
+	before := len(result.Findings)
+	if problems := colorChunkProblems(chunks); len(problems) > 0 {
+		result.AddFinding("Malformed color or pixel-size chunks", 0.4, strings.Join(problems, ", "))
+		result.SetDetectorScore(analyzer.DetectorMetadata, 0.4)
+		if result.DetectionScore < 0.4 {
+			result.DetectionScore = 0.4
+		}
+	}
+	for _, chunk := range chunks {
+		if chunk.Type == "iCCP" {
+			analyzeICCProfile(chunk, filePath, options, result)
+			break
+		}
+	}
+	if len(result.Findings) == before {
+		result.AddCheck(fmt.Sprintf("%s chunks well-formed", strings.Join(present, ", ")))
+	}
+}
+
+// analyzeICCProfile reports an iCCP chunk that holds something other than an
+// ICC profile, or more than one
+func analyzeICCProfile(chunk Chunk, filePath string, options analyzer.AnalysisOptions, result *models.AnalysisResult) {
+	profile, err := ParseICCProfile(chunk.Data)
+	if err != nil {
+		return // Reported with the malformed chunks
+	}
+	result.Details["iccp_profile_size"] = len(profile.Data)
+
+	found := false
+	raise := func(description string, confidence float64, details string) {
+		found = true
+		result.AddFinding(description, confidence, details)
+		result.SetDetectorScore(analyzer.DetectorEmbeddedData, confidence)
+		if result.DetectionScore < confidence {
+			result.DetectionScore = confidence
+			result.PossibleAlgorithm = "ICC Profile Data Injection"
+		}
+	}
+
+	switch {
+	case profile.Err != nil:
+		raise("iCCP chunk does not hold an ICC profile", 0.75,
+			fmt.Sprintf("The %d compressed bytes of profile %q do not inflate: %v", profile.Compressed, profile.Name, profile.Err))
+	case !profile.Signature:
+		raise("iCCP chunk does not hold an ICC profile", 0.75,
+			fmt.Sprintf("Profile %q inflates to %d bytes without the acsp signature of an ICC profile", profile.Name, len(profile.Data)))
+	}
+	if len(profile.Data) > iccpLargeProfile {
+		raise("Unusually large iCCP color profile", 0.6,
+			fmt.Sprintf("Profile %q is %d bytes (%d compressed); embedded profiles are rarely over %d KB", profile.Name, len(profile.Data), profile.Compressed, iccpLargeProfile>>10))
+	}
+
+	var hidden []string
+	if profile.Trailing > 0 {
+		hidden = append(hidden, fmt.Sprintf("%d bytes after the zlib stream", profile.Trailing))
+	}
+	if profile.Signature && profile.DeclaredSize < len(profile.Data) {
+		hidden = append(hidden, fmt.Sprintf("%d bytes past the declared profile size of %d", len(profile.Data)-profile.DeclaredSize, profile.DeclaredSize))
+	}
+	if profile.Signature && profile.Unreferenced >= iccSlackMin {
+		hidden = append(hidden, fmt.Sprintf("%d non-zero bytes no tag refers to", profile.Unreferenced))
+	}
+	if len(hidden) > 0 {
+		raise("Data hidden in the iCCP color profile", 0.8, strings.Join(hidden, ", "))
+	}
+	for _, m := range carve.Scan(profile.Data) {
+		if m.Parent >= 0 {
+			continue
+		}
+		raise(fmt.Sprintf("Embedded %s file found in the iCCP color profile", strings.ToUpper(m.Type)), 0.85,
+			fmt.Sprintf("bytes %d-%d of the inflated profile (%d bytes)", m.Offset, m.End, m.Size()))
+	}
+
+	if !found || !options.Extract || options.OutputDir == "" {
+		return
+	}
+	base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	outputPath := filepath.Join(options.OutputDir, base+"_iccp.icc")
+	if outputPath, err := filehandler.SaveFileUnique(profile.Data, outputPath); err == nil {
+		result.Details["iccp_profile_file"] = outputPath
+		result.Recommendations = append(result.Recommendations,
+			fmt.Sprintf("Inspect the inflated ICC profile: %s", outputPath))
+	}
+}
@@ -0,0 +1,99 @@
+package png
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/models"
+)
+
+// iccProfile returns an ICC profile with one 16-byte tag, followed by slack
+// bytes the declared size covers but no tag refers to
+func iccProfile(slack []byte) []byte {
+	const tagOffset, tagSize = iccHeaderSize + 4 + 12, 16
+	size := tagOffset + tagSize + len(slack)
+	profile := make([]byte, iccHeaderSize, size)
+	binary.BigEndian.PutUint32(profile, uint32(size))
+	copy(profile[36:], "acsp")
+	profile = binary.BigEndian.AppendUint32(profile, 1)
+	profile = append(profile, "desc"...)
+	profile = binary.BigEndian.AppendUint32(profile, tagOffset)
+	profile = binary.BigEndian.AppendUint32(profile, tagSize)
+	profile = append(profile, "desc\x00\x00\x00\x00sRGB\x00\x00\x00\x00"...)
+	return append(profile, slack...)
+}
+
+// iccpChunk returns an iCCP chunk holding profile compressed, followed by trailing
+func iccpChunk(t testing.TB, profile, trailing []byte) Chunk {
+	var buf bytes.Buffer
+	buf.WriteString("ICC Profile\x00\x00")
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(profile); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(trailing)
+	return Chunk{Type: "iCCP", Data: buf.Bytes()}
+}
+
+func TestAnalyzeColorChunks(t *testing.T) {
+	zipData, err := fixtures.ZIP("secret.txt", []byte("hidden in the color profile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gama := Chunk{Type: "gAMA", Data: []byte{0, 0, 0xB1, 0x8F}}
+	srgb := Chunk{Type: "sRGB", Data: []byte{0}}
+	phys := Chunk{Type: "pHYs", Data: []byte{0, 0, 0x0B, 0x13, 0, 0, 0x0B, 0x13, 1}}
+
+	tests := []struct {
+		name   string
+		chunks []Chunk
+		want   []string // Findings expected, none for a clean file
+	}{
+		{"well-formed chunks", []Chunk{gama, srgb, phys}, nil},
+		{"valid profile", []Chunk{gama, iccpChunk(t, iccProfile(nil), nil)}, nil},
+		{"oversized garbage profile", []Chunk{iccpChunk(t, fixtures.RandomPayload(200<<10, 1), nil)},
+			[]string{"iCCP chunk does not hold an ICC profile", "Unusually large iCCP color profile"}},
+		{"profile that does not inflate", []Chunk{{Type: "iCCP", Data: append([]byte("ICC\x00\x00"), fixtures.RandomPayload(512, 2)...)}},
+			[]string{"iCCP chunk does not hold an ICC profile"}},
+		{"data after the zlib stream", []Chunk{iccpChunk(t, iccProfile(nil), []byte("appended after the stream"))},
+			[]string{"Data hidden in the iCCP color profile"}},
+		{"data past the declared size", []Chunk{iccpChunk(t, append(iccProfile(nil), bytes.Repeat([]byte("x"), 100)...), nil)},
+			[]string{"Data hidden in the iCCP color profile"}},
+		{"bytes no tag refers to", []Chunk{iccpChunk(t, iccProfile(bytes.Repeat([]byte("slack"), 20)), nil)},
+			[]string{"Data hidden in the iCCP color profile"}},
+		{"embedded archive", []Chunk{iccpChunk(t, iccProfile(zipData), nil)},
+			[]string{"Data hidden in the iCCP color profile", "Embedded ZIP file found in the iCCP color profile"}},
+		{"malformed gAMA", []Chunk{{Type: "gAMA", Data: []byte{0, 1, 2}}, phys},
+			[]string{"Malformed color or pixel-size chunks"}},
+		{"sRGB and iCCP", []Chunk{srgb, iccpChunk(t, iccProfile(nil), nil)},
+			[]string{"Malformed color or pixel-size chunks"}},
+		{"chunk after the image data", []Chunk{{Type: "IDAT"}, gama},
+			[]string{"Malformed color or pixel-size chunks"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := append([]Chunk{{Type: "IHDR"}}, tt.chunks...)
+			result := &models.AnalysisResult{}
+			analyzeColorChunks(chunks, "test.png", analyzer.AnalysisOptions{}, result)
+
+			var got []string
+			for _, finding := range result.Findings {
+				got = append(got, finding.Description)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("findings %q, want %q", got, tt.want)
+			}
+			if len(tt.want) == 0 && len(result.Checks) != 1 {
+				t.Errorf("checks %q, want one", result.Checks)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package png
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+/*
+This file contains the PNG chunk reader used by the analyses that need more than
+the decoded pixels. ReadChunks works on an in-memory byte slice and stops at
+IEND. CRCs are not checked, since a wrong CRC is itself worth reporting rather
+than a reason to stop.
+*/
+
+// pngSignature is the 8-byte signature every PNG starts with
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// maxChunkLength is the largest chunk payload the PNG specification allows
+const maxChunkLength = 1<<31 - 1
+
+// Errors returned by ReadChunks and ParseHeader
+var (
+	ErrNotPNG      = errors.New("invalid PNG: missing signature")
+	ErrChunkTrunc  = errors.New("invalid PNG: truncated chunk")
+	ErrMissingIHDR = errors.New("invalid PNG: first chunk is not IHDR")
+	ErrInvalidIHDR = errors.New("invalid PNG: malformed IHDR")
+)
+
+// Chunk is one chunk of a PNG file
+type Chunk struct {
+	Type   string // Four-letter chunk type, such as "IDAT"
+	Offset int    // Position of the chunk's length field
+	Data   []byte // Chunk payload
+	CRC    uint32 // CRC stored in the file
+}
+
+// Header holds the fields of the IHDR chunk
+type Header struct {
+	Width     int
+	Height    int
+	BitDepth  int
+	ColorType int
+	Interlace int
+}
+
+// ReadChunks returns the chunks of data in file order up to and including IEND.
+// The chunks read before an error are returned with it.
+func ReadChunks(data []byte) ([]Chunk, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, ErrNotPNG
+	}
+
+	var chunks []Chunk
+	pos := len(pngSignature)
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return chunks, fmt.Errorf("%w: header at offset %d is cut off", ErrChunkTrunc, pos)
+		}
+		length := binary.BigEndian.Uint32(data[pos:])
+		if length > maxChunkLength || uint64(pos)+12+uint64(length) > uint64(len(data)) {
+			return chunks, fmt.Errorf("%w: chunk at offset %d declares %d bytes", ErrChunkTrunc, pos, length)
+		}
+		end := pos + 8 + int(length)
+		chunk := Chunk{
+			Type:   string(data[pos+4 : pos+8]),
+			Offset: pos,
+			Data:   data[pos+8 : end],
+			CRC:    binary.BigEndian.Uint32(data[end:]),
+		}
+		chunks = append(chunks, chunk)
+		pos = end + 4
+		if chunk.Type == "IEND" {
+			break
+		}
+	}
+	return chunks, nil
+}
+
+// ParseHeader reads the IHDR chunk, which must be the first chunk
+func ParseHeader(chunks []Chunk) (*Header, error) {
+	if len(chunks) == 0 || chunks[0].Type != "IHDR" {
+		return nil, ErrMissingIHDR
+	}
+	d := chunks[0].Data
+	if len(d) != 13 {
+		return nil, fmt.Errorf("%w: %d bytes instead of 13", ErrInvalidIHDR, len(d))
+	}
+	h := &Header{
+		Width:     int(binary.BigEndian.Uint32(d[0:])),
+		Height:    int(binary.BigEndian.Uint32(d[4:])),
+		BitDepth:  int(d[8]),
+		ColorType: int(d[9]),
+		Interlace: int(d[12]),
+	}
+	if h.Width <= 0 || h.Height <= 0 || h.Channels() == 0 {
+		return nil, fmt.Errorf("%w: %dx%d, color type %d", ErrInvalidIHDR, h.Width, h.Height, h.ColorType)
+	}
+	return h, nil
+}
+
+// Channels returns the number of samples per pixel, or 0 for an unknown color type
+func (h *Header) Channels() int {
+	switch h.ColorType {
+	case 0, 3: // Grayscale, palette
+		return 1
+	case 2: // RGB
+		return 3
+	case 4: // Grayscale with alpha
+		return 2
+	case 6: // RGBA
+		return 4
+	}
+	return 0
+}
+
+// RowBytes returns the size of one unfiltered scanline of a non-interlaced image
+func (h *Header) RowBytes() int {
+	return (h.Width*h.Channels()*h.BitDepth + 7) / 8
+}
+
+// PixelBytes returns the filter unit: the bytes per complete pixel, at least 1
+func (h *Header) PixelBytes() int {
+	n := h.Channels() * h.BitDepth / 8
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// ImageData returns the concatenated payloads of the IDAT chunks
+func ImageData(chunks []Chunk) []byte {
+	var idat []byte
+	for _, chunk := range chunks {
+		if chunk.Type == "IDAT" {
+			idat = append(idat, chunk.Data...)
+		}
+	}
+	return idat
+}
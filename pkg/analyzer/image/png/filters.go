@@ -0,0 +1,306 @@
+package png
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the analysis of the per-scanline filter types. Every PNG
+scanline starts with a filter byte (None, Sub, Up, Average or Paeth) that the
+decoder undoes and image viewers never show. Encoders either use one filter for
+every row or pick per row with the minimum-sum-of-absolute-differences
+heuristic that libpng and Go use. A tool that stores one bit per scanline in the
+filter choice does neither, so its rows mix filters that the heuristic would not
+have picked. The filter bits are also read back as a payload.
+*/
+
+// Filter analysis parameters
+const (
+	filterTypes        = 5        // None, Sub, Up, Average, Paeth
+	filterMinRows      = 16       // Rows an image needs before its filter choices are judged
+	filterAgreementLow = 0.5      // Heuristic agreement below which mixed filters are suspicious
+	filterMinText      = 8        // Leading printable bytes needed to report a filter payload
+	filterMaxRowBytes  = 16 << 20 // Longest scanline buffered, so a forged IHDR width cannot exhaust memory
+)
+
+// errInterlaced is returned for Adam7 images, whose passes are not analyzed
+var errInterlaced = errors.New("interlaced PNG")
+
+// FilterAnalysis describes the filter types of a PNG's scanlines
+type FilterAnalysis struct {
+	Rows      int              // Scanlines read
+	Counts    [filterTypes]int // Rows per filter type
+	Invalid   int              // Rows with a filter byte above 4
+	Agreement float64          // Share of rows whose filter is as good as the heuristic's choice
+	Filters   []byte           // Filter byte of each row
+}
+
+// AnalyzeFilters decompresses the image data and records the filter type of
+// every scanline, together with whether the minimum-sum heuristic would have
+// chosen it. Only non-interlaced images are supported.
+func AnalyzeFilters(header *Header, idat []byte) (*FilterAnalysis, error) {
+	if header.Interlace != 0 {
+		return nil, errInterlaced
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(idat))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image data: %w", err)
+	}
+	defer zr.Close()
+
+	rowBytes, bpp := header.RowBytes(), header.PixelBytes()
+	if rowBytes > filterMaxRowBytes {
+		return nil, fmt.Errorf("scanlines of %d bytes exceed the limit of %d bytes", rowBytes, filterMaxRowBytes)
+	}
+	line := make([]byte, rowBytes+1)
+	prev := make([]byte, rowBytes) // Unfiltered previous row, zero above the first
+	cur := make([]byte, rowBytes)
+
+	analysis := &FilterAnalysis{}
+	agreeing := 0
+	for row := 0; row < header.Height; row++ {
+		if _, err := io.ReadFull(zr, line); err != nil {
+			if analysis.Rows == 0 {
+				return nil, fmt.Errorf("failed to read image data: %w", err)
+			}
+			break // Judge the rows that could be read
+		}
+		filter := line[0]
+		analysis.Rows++
+		analysis.Filters = append(analysis.Filters, filter)
+		if filter >= filterTypes {
+			analysis.Invalid++
+			copy(prev, line[1:])
+			continue
+		}
+		analysis.Counts[filter]++
+
+		unfilter(filter, line[1:], prev, cur, bpp)
+		if heuristicAgrees(filter, cur, prev, bpp) {
+			agreeing++
+		}
+		prev, cur = cur, prev
+	}
+
+	analysis.Agreement = float64(agreeing) / float64(analysis.Rows)
+	return analysis, nil
+}
+
+// TypesUsed returns how many different valid filter types occur
+func (a *FilterAnalysis) TypesUsed() int {
+	used := 0
+	for _, n := range a.Counts {
+		if n > 0 {
+			used++
+		}
+	}
+	return used
+}
+
+// Payload packs one bit per scanline into bytes, most significant bit first.
+// When exactly two filter types occur the lower one reads as 0 and the higher
+// as 1; otherwise the low bit of the filter type is used.
+func (a *FilterAnalysis) Payload() []byte {
+	low, high := -1, -1
+	if a.TypesUsed() == 2 {
+		for t, n := range a.Counts {
+			if n == 0 {
+				continue
+			}
+			if low < 0 {
+				low = t
+			} else {
+				high = t
+			}
+		}
+	}
+
+	out := make([]byte, 0, len(a.Filters)/8)
+	var current byte
+	for i, filter := range a.Filters {
+		bit := filter & 1
+		if high >= 0 {
+			bit = 0
+			if int(filter) == high {
+				bit = 1
+			}
+		}
+		current = current<<1 | bit
+		if i%8 == 7 {
+			out = append(out, current)
+			current = 0
+		}
+	}
+	return out
+}
+
+// unfilter reverses the filter of one scanline into cur
+func unfilter(filter byte, line, prev, cur []byte, bpp int) {
+	for i := range line {
+		var left, upLeft byte
+		if i >= bpp {
+			left, upLeft = cur[i-bpp], prev[i-bpp]
+		}
+		up := prev[i]
+		switch filter {
+		case 0:
+			cur[i] = line[i]
+		case 1:
+			cur[i] = line[i] + left
+		case 2:
+			cur[i] = line[i] + up
+		case 3:
+			cur[i] = line[i] + byte((int(left)+int(up))/2)
+		case 4:
+			cur[i] = line[i] + paeth(left, up, upLeft)
+		}
+	}
+}
+
+// heuristicAgrees reports whether filter gives the minimum sum of absolute
+// (signed) filtered values for the row, which is how adaptive encoders choose.
+// Ties count as agreement since encoders break them differently.
+func heuristicAgrees(filter byte, cur, prev []byte, bpp int) bool {
+	var sums [filterTypes]int
+	for i := range cur {
+		var left, upLeft byte
+		if i >= bpp {
+			left, upLeft = cur[i-bpp], prev[i-bpp]
+		}
+		up := prev[i]
+		sums[0] += absInt8(cur[i])
+		sums[1] += absInt8(cur[i] - left)
+		sums[2] += absInt8(cur[i] - up)
+		sums[3] += absInt8(cur[i] - byte((int(left)+int(up))/2))
+		sums[4] += absInt8(cur[i] - paeth(left, up, upLeft))
+	}
+	for _, sum := range sums {
+		if sum < sums[filter] {
+			return false
+		}
+	}
+	return true
+}
+
+// paeth is the Paeth predictor of the PNG specification
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+// absInt8 returns the magnitude of a byte read as a signed value
+func absInt8(v byte) int {
+	return abs(int(int8(v)))
+}
+
+// abs returns |v|
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// leadingText returns the length of the run of printable ASCII at the start of data
+func leadingText(data []byte) int {
+	for i, b := range data {
+		if (b < 32 || b > 126) && b != '\n' && b != '\r' && b != '\t' {
+			return i
+		}
+	}
+	return len(data)
+}
+
+// analyzeFilters reports scanline filter choices that no encoder would make and
+// text stored in the filter bits
+func analyzeFilters(data []byte, filePath string, options analyzer.AnalysisOptions, result *models.AnalysisResult) {
+	chunks, err := ReadChunks(data)
+	if err != nil && len(chunks) == 0 {
+		return
+	}
+	header, err := ParseHeader(chunks)
+	if err != nil {
+		return
+	}
+	analysis, err := AnalyzeFilters(header, ImageData(chunks))
+	if err != nil {
+		if !errors.Is(err, errInterlaced) {
+			result.AddFinding("Scanline filters could not be analyzed", 0.1, err.Error())
+		}
+		return
+	}
+
+	counts := map[string]int{}
+	for t, name := range []string{"none", "sub", "up", "average", "paeth"} {
+		counts[name] = analysis.Counts[t]
+	}
+	result.Details["filter_types"] = counts
+	result.Details["filter_heuristic_agreement"] = analysis.Agreement
+
+	if analysis.Invalid > 0 {
+		result.AddFinding("Invalid scanline filter types", 0.6,
+			fmt.Sprintf("%d of %d rows have a filter byte above 4", analysis.Invalid, analysis.Rows))
+	}
+
+	if analysis.Rows >= filterMinRows && analysis.TypesUsed() >= 2 && analysis.Agreement < filterAgreementLow {
+		// PNG optimizers that pick filters by compressed size also disagree with
+		// the heuristic, so this alone is no more than a medium finding
+		confidence := 0.4 + (filterAgreementLow-analysis.Agreement)*0.6
+		result.AddFinding("Scanline filter choices do not follow the adaptive encoder heuristic", confidence,
+			fmt.Sprintf("%d filter types used, only %.0f%% of rows use the filter libpng or Go would pick (PNG optimizers can also cause this)",
+				analysis.TypesUsed(), analysis.Agreement*100))
+		if confidence > result.DetectionScore {
+			result.DetectionScore = confidence
+		}
+	}
+
+	payload := analysis.Payload()
+	textLen := leadingText(payload)
+	if textLen < filterMinText {
+		return
+	}
+	text := payload[:textLen]
+	result.AddFinding("Scanline filter types encode text", 0.9,
+		fmt.Sprintf("One bit per row reads %q", truncate(string(text), 60)))
+	if result.DetectionScore < 0.9 {
+		result.DetectionScore = 0.9
+		result.PossibleAlgorithm = "PNG Filter-Type Steganography"
+	}
+	result.AddExtractionHint("png-filter-bits", 0.9, nil)
+
+	if options.Extract && options.OutputDir != "" {
+		base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+		outputPath := filepath.Join(options.OutputDir, base+"_filter_bits.txt")
+		if outputPath, err := filehandler.SaveFileUnique(text, outputPath); err == nil {
+			result.Details["filter_payload_file"] = outputPath
+			result.Recommendations = append(result.Recommendations,
+				fmt.Sprintf("Inspect the text read from the scanline filter types: %s", outputPath))
+		}
+	}
+}
+
+// truncate shortens s to at most n bytes, marking the cut with "..."
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
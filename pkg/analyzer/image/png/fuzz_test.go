@@ -0,0 +1,143 @@
+package png
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+)
+
+// seedPNGs returns the PNG fixtures, clean.png with an iCCP chunk, and copies
+// of clean.png that are truncated, oversized or have a bad CRC
+func seedPNGs(f *testing.F) [][]byte {
+	var seeds [][]byte
+	for _, name := range []string{"clean.png", "lsb_rgb.png", "itxt_zero_width.png"} {
+		data, err := fixtures.Load(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		seeds = append(seeds, data)
+	}
+	clean := seeds[0]
+
+	chunks, err := ReadChunks(clean)
+	if err != nil {
+		f.Fatal(err)
+	}
+	withICCP := append([]Chunk{chunks[0], iccpChunk(f, iccProfile([]byte("slack")), []byte("PK"))}, chunks[1:]...)
+
+	oversized := append([]byte(nil), clean...)
+	binary.BigEndian.PutUint32(oversized[16:], 0x7FFFFFFF) // IHDR width
+	badCRC := append([]byte(nil), clean...)
+	badCRC[len(badCRC)-iendSize-1] ^= 0xFF // Last byte of the last IDAT CRC
+
+	return append(seeds,
+		encodeChunks(withICCP),
+		clean[:len(clean)/2],
+		clean[:len(clean)-iendSize],
+		oversized,
+		badCRC,
+	)
+}
+
+func FuzzReadChunks(f *testing.F) {
+	for _, seed := range seedPNGs(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		chunks, _ := ReadChunks(data)
+		end := len(pngSignature)
+		for _, chunk := range chunks {
+			if chunk.Offset != end || len(chunk.Type) != 4 {
+				t.Fatalf("%q chunk at offset %d, want offset %d", chunk.Type, chunk.Offset, end)
+			}
+			end = chunk.Offset + 12 + len(chunk.Data)
+		}
+		if len(chunks) > 0 && end > len(data) {
+			t.Fatalf("chunks end at %d of %d bytes", end, len(data))
+		}
+		for _, text := range TextChunks(chunks) {
+			if len(text.Text) > 2*maxTextChunk {
+				t.Fatalf("%s text of %d bytes exceeds the inflate limit", text.Type, len(text.Text))
+			}
+		}
+		AnalyzeChunkLayout(chunks)
+	})
+}
+
+func FuzzAnalyzeFilters(f *testing.F) {
+	for _, seed := range seedPNGs(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		chunks, _ := ReadChunks(data)
+		header, err := ParseHeader(chunks)
+		if err != nil {
+			return
+		}
+		analysis, err := AnalyzeFilters(header, ImageData(chunks))
+		if err != nil {
+			return
+		}
+		if analysis.Rows == 0 || analysis.Rows > header.Height || len(analysis.Filters) != analysis.Rows {
+			t.Fatalf("%d rows with %d filters for a height of %d", analysis.Rows, len(analysis.Filters), header.Height)
+		}
+		if analysis.Agreement < 0 || analysis.Agreement > 1 {
+			t.Fatalf("agreement %v out of range", analysis.Agreement)
+		}
+		analysis.Payload()
+	})
+}
+
+func FuzzParseICCProfile(f *testing.F) {
+	for _, seed := range seedPNGs(f) {
+		chunks, _ := ReadChunks(seed)
+		for _, chunk := range chunks {
+			if chunk.Type == "iCCP" {
+				f.Add(chunk.Data)
+			}
+		}
+	}
+	f.Add([]byte("ICC\x00\x00"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		profile, err := ParseICCProfile(data)
+		if err != nil {
+			return
+		}
+		if profile.Compressed > len(data) || profile.Trailing < 0 || profile.Trailing > profile.Compressed {
+			t.Fatalf("%d compressed and %d trailing bytes in a %d-byte chunk", profile.Compressed, profile.Trailing, len(data))
+		}
+		if len(profile.Data) > maxICCProfile {
+			t.Fatalf("profile inflated to %d bytes", len(profile.Data))
+		}
+		if profile.Unreferenced > len(profile.Data) {
+			t.Fatalf("%d unreferenced bytes in a %d-byte profile", profile.Unreferenced, len(profile.Data))
+		}
+	})
+}
+
+func FuzzRepairChunks(f *testing.F) {
+	for _, seed := range seedPNGs(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		repaired, repairs := RepairChunks(data)
+		if !bytes.HasPrefix(data, pngSignature) {
+			if !bytes.Equal(repaired, data) || repairs != nil {
+				t.Fatalf("non-PNG data was changed")
+			}
+			return
+		}
+		chunks, err := ReadChunks(repaired)
+		if err != nil {
+			t.Fatalf("repaired data does not read: %v", err)
+		}
+		if chunks[len(chunks)-1].Type != "IEND" {
+			t.Fatalf("repaired data ends with %s, want IEND", chunks[len(chunks)-1].Type)
+		}
+		if again, more := RepairChunks(repaired); !bytes.Equal(again, repaired) || len(more) > 0 {
+			t.Fatalf("second repair changed the data: %v", more)
+		}
+	})
+}
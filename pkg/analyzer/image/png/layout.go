@@ -0,0 +1,180 @@
+package png
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the analysis of the chunk layout. Encoders write the image
+data as a run of consecutive IDAT chunks of one fixed size (8KB for libpng, 32KB
+for Go) with a shorter last chunk. Many tiny IDAT chunks, other chunks in the
+middle of the run and critical chunks after it are not produced by encoders;
+tools that smuggle data between or inside the image data chunks do produce them.
+Bytes left over after the end of the zlib stream are ignored by decoders and are
+read back as a payload.
+*/
+
+// Chunk layout parameters
+const (
+	tinyIDATSize     = 512 // IDAT chunks below this size, other than the last, count as tiny
+	tinyIDATMinCount = 4   // Tiny IDAT chunks needed before the split is reported
+	maxListedChunks  = 64  // Chunks listed in Details
+)
+
+// ChunkLayout describes where the IDAT chunks of a PNG are and what surrounds them
+type ChunkLayout struct {
+	IDATCount     int      // Number of IDAT chunks
+	IDATSizes     []int    // Payload size of each IDAT chunk
+	TinyIDATs     int      // IDAT chunks, other than the last, below tinyIDATSize
+	Interleaved   []string // Types of chunks found between the first and last IDAT
+	CriticalAfter []string // Critical chunks other than IEND after the last IDAT
+	Trailing      []byte   // Image data left over after the end of the zlib stream
+}
+
+// AnalyzeChunkLayout inspects the IDAT chunks and the chunks around them
+func AnalyzeChunkLayout(chunks []Chunk) *ChunkLayout {
+	layout := &ChunkLayout{}
+	first, last := -1, -1
+	for i, chunk := range chunks {
+		if chunk.Type == "IDAT" {
+			if first < 0 {
+				first = i
+			}
+			last = i
+			layout.IDATCount++
+			layout.IDATSizes = append(layout.IDATSizes, len(chunk.Data))
+		}
+	}
+	if first < 0 {
+		return layout
+	}
+
+	for _, size := range layout.IDATSizes[:len(layout.IDATSizes)-1] {
+		if size < tinyIDATSize {
+			layout.TinyIDATs++
+		}
+	}
+	for _, chunk := range chunks[first:last] {
+		if chunk.Type != "IDAT" {
+			layout.Interleaved = append(layout.Interleaved, chunk.Type)
+		}
+	}
+	for _, chunk := range chunks[last+1:] {
+		// Critical chunks have an upper-case first letter
+		if chunk.Type != "IEND" && len(chunk.Type) == 4 && chunk.Type[0]&0x20 == 0 {
+			layout.CriticalAfter = append(layout.CriticalAfter, chunk.Type)
+		}
+	}
+	layout.Trailing = zlibTrailing(ImageData(chunks))
+	return layout
+}
+
+// zlibTrailing returns the bytes after the end of the zlib stream in data, or
+// nil when the stream cannot be read to its end
+func zlibTrailing(data []byte) []byte {
+	r := bytes.NewReader(data)
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil
+	}
+	defer zr.Close()
+	// The checksum is verified at EOF, so a clean EOF means the whole stream was read
+	if _, err := io.Copy(io.Discard, zr); err != nil {
+		return nil
+	}
+	return data[len(data)-r.Len():]
+}
+
+// analyzeChunkLayout reports IDAT splitting and chunk placement that no encoder
+// produces, and extracts image data hidden after the zlib stream
+func analyzeChunkLayout(data []byte, filePath string, options analyzer.AnalysisOptions, result *models.AnalysisResult) {
+	chunks, err := ReadChunks(data)
+	if len(chunks) == 0 {
+		return
+	}
+	if err != nil {
+		result.AddFinding("PNG chunk structure is damaged", 0.3, err.Error())
+	}
+
+	layout := AnalyzeChunkLayout(chunks)
+	listed := make([]string, 0, min(len(chunks), maxListedChunks))
+	for _, chunk := range chunks[:min(len(chunks), maxListedChunks)] {
+		listed = append(listed, fmt.Sprintf("%s:%d", chunk.Type, len(chunk.Data)))
+	}
+	result.Details["chunks"] = listed
+	result.Details["idat_chunks"] = layout.IDATCount
+
+	if layout.TinyIDATs >= tinyIDATMinCount {
+		result.AddFinding("Image data split into many tiny IDAT chunks", 0.5,
+			fmt.Sprintf("%d of %d IDAT chunks are under %d bytes; encoders write IDAT chunks of one fixed size",
+				layout.TinyIDATs, layout.IDATCount, tinyIDATSize))
+		if result.DetectionScore < 0.5 {
+			result.DetectionScore = 0.5
+		}
+	}
+	if len(layout.Interleaved) > 0 {
+		result.AddFinding("Chunks between IDAT chunks", 0.7,
+			fmt.Sprintf("%s found inside the IDAT run, which the PNG specification requires to be consecutive",
+				strings.Join(layout.Interleaved, ", ")))
+		if result.DetectionScore < 0.7 {
+			result.DetectionScore = 0.7
+		}
+		result.Recommendations = append(result.Recommendations,
+			"Dump the chunks found between the IDAT chunks and inspect their contents")
+	}
+	if len(layout.CriticalAfter) > 0 {
+		result.AddFinding("Critical chunk after the image data", 0.6,
+			fmt.Sprintf("%s found after the last IDAT chunk", strings.Join(layout.CriticalAfter, ", ")))
+		if result.DetectionScore < 0.6 {
+			result.DetectionScore = 0.6
+		}
+	}
+
+	if len(layout.Trailing) == 0 {
+		return
+	}
+	result.AddFinding("Data after the end of the compressed image data", 0.85,
+		fmt.Sprintf("%d bytes follow the zlib stream inside the IDAT chunks: %q",
+			len(layout.Trailing), truncate(string(layout.Trailing), 60)))
+	result.SetDetectorScore(analyzer.DetectorEmbeddedData, 0.85)
+	if result.DetectionScore < 0.85 {
+		result.DetectionScore = 0.85
+		result.PossibleAlgorithm = "IDAT Data Injection"
+	}
+	if options.Extract && options.OutputDir != "" {
+		base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+		outputPath := filepath.Join(options.OutputDir, base+"_idat_trailing.bin")
+		if outputPath, err := filehandler.SaveFileUnique(layout.Trailing, outputPath); err == nil {
+			result.Details["idat_trailing_file"] = outputPath
+			result.Recommendations = append(result.Recommendations,
+				fmt.Sprintf("Inspect the data found after the zlib stream: %s", outputPath))
+		}
+	}
+}
+
+// undecodableResult analyzes the chunk layout of a PNG that Go's decoder
+// rejected. It returns nil when the layout shows nothing unusual.
+func undecodableResult(data []byte, decodeErr error, filePath string, options analyzer.AnalysisOptions) *models.AnalysisResult {
+	result := &models.AnalysisResult{
+		FileType:        "png",
+		Filename:        filePath,
+		Findings:        []models.Finding{},
+		Recommendations: []string{},
+		Details:         map[string]interface{}{},
+	}
+	analyzeChunkLayout(data, filePath, options, result)
+	if len(result.Findings) == 0 {
+		return nil
+	}
+	result.AddFinding("Image could not be decoded, only its chunk layout was analyzed", 0.3, decodeErr.Error())
+	return result
+}
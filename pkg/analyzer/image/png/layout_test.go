@@ -0,0 +1,184 @@
+package png
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+)
+
+// encodeChunks writes chunks as a PNG file with valid CRCs
+func encodeChunks(chunks []Chunk) []byte {
+	out := append([]byte(nil), pngSignature...)
+	for _, chunk := range chunks {
+		out = binary.BigEndian.AppendUint32(out, uint32(len(chunk.Data)))
+		out = append(out, chunk.Type...)
+		out = append(out, chunk.Data...)
+		out = binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(append([]byte(chunk.Type), chunk.Data...)))
+	}
+	return out
+}
+
+// rebuild returns clean.png with its image data split into IDAT chunks of at
+// most size bytes, passing the chunks through edit first
+func rebuild(t *testing.T, size int, edit func([]Chunk) []Chunk) []byte {
+	t.Helper()
+	data, err := fixtures.Load("clean.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := ReadChunks(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []Chunk
+	for _, chunk := range chunks {
+		switch chunk.Type {
+		case "IDAT":
+			continue
+		case "IEND":
+			image := ImageData(chunks)
+			for len(image) > 0 {
+				n := min(size, len(image))
+				out = append(out, Chunk{Type: "IDAT", Data: image[:n]})
+				image = image[n:]
+			}
+		}
+		out = append(out, chunk)
+	}
+	if edit != nil {
+		out = edit(out)
+	}
+	return encodeChunks(out)
+}
+
+// insertAfterIDAT inserts chunk after the nth IDAT chunk
+func insertAfterIDAT(n int, chunk Chunk) func([]Chunk) []Chunk {
+	return func(chunks []Chunk) []Chunk {
+		seen := 0
+		for i, c := range chunks {
+			if c.Type == "IDAT" {
+				seen++
+				if seen == n {
+					return append(chunks[:i+1], append([]Chunk{chunk}, chunks[i+1:]...)...)
+				}
+			}
+		}
+		return chunks
+	}
+}
+
+func TestAnalyzeChunkLayout(t *testing.T) {
+	text := Chunk{Type: "tEXt", Data: []byte("Comment\x00between the image data")}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		finding  string // Description of the finding expected, empty for none
+		decodes  bool
+		minIDATs int
+	}{
+		{"one IDAT", rebuild(t, 1<<20, nil), "", true, 1},
+		{"8KB IDATs", rebuild(t, 8192, nil), "", true, 1},
+		{"fragmented", rebuild(t, 100, nil), "Image data split into many tiny IDAT chunks", true, 20},
+		{"three tiny IDATs", rebuild(t, 1<<20, func(chunks []Chunk) []Chunk {
+			// Only the first three of four chunks are tiny, fewer than tinyIDATMinCount
+			return rebuildSplit(chunks, 200, 3)
+		}), "", true, 4},
+		{"chunk between IDATs", rebuild(t, 1000, insertAfterIDAT(1, text)), "Chunks between IDAT chunks", false, 2},
+		{"critical chunk after IDAT", rebuild(t, 1<<20, insertAfterIDAT(1, Chunk{Type: "PLTE", Data: make([]byte, 6)})), "Critical chunk after the image data", false, 1},
+		{"ancillary chunk after IDAT", rebuild(t, 1<<20, insertAfterIDAT(1, text)), "", true, 1},
+		{"data after the zlib stream", rebuild(t, 1<<20, appendToLastIDAT([]byte("hidden after the stream"))), "Data after the end of the compressed image data", true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "layout.png")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			result, err := NewPNGAnalyzer().Analyze(path, analyzer.AnalysisOptions{Extract: true, OutputDir: t.TempDir()})
+			if err != nil {
+				t.Fatalf("failed to analyze: %v", err)
+			}
+
+			var layoutFindings []string
+			for _, finding := range result.Findings {
+				for _, description := range []string{"tiny IDAT", "between IDAT", "Critical chunk", "after the end of the compressed"} {
+					if strings.Contains(finding.Description, description) {
+						layoutFindings = append(layoutFindings, finding.Description)
+					}
+				}
+			}
+			if tt.finding == "" && len(layoutFindings) > 0 {
+				t.Errorf("unexpected findings %v", layoutFindings)
+			}
+			if tt.finding != "" && (len(layoutFindings) != 1 || layoutFindings[0] != tt.finding) {
+				t.Errorf("got findings %v, want %q", layoutFindings, tt.finding)
+			}
+
+			undecodable := false
+			for _, finding := range result.Findings {
+				undecodable = undecodable || strings.HasPrefix(finding.Description, "Image could not be decoded")
+			}
+			if undecodable == tt.decodes {
+				t.Errorf("undecodable is %v, want %v", undecodable, !tt.decodes)
+			}
+
+			count, _ := result.Details["idat_chunks"].(int)
+			chunks, _ := result.Details["chunks"].([]string)
+			if count < tt.minIDATs || len(chunks) == 0 {
+				t.Errorf("details list %d IDAT chunks and %d chunks, want at least %d IDATs", count, len(chunks), tt.minIDATs)
+			}
+		})
+	}
+}
+
+// rebuildSplit splits the single IDAT chunk of chunks into n chunks of size
+// bytes followed by the rest
+func rebuildSplit(chunks []Chunk, size, n int) []Chunk {
+	var out []Chunk
+	for _, chunk := range chunks {
+		if chunk.Type != "IDAT" {
+			out = append(out, chunk)
+			continue
+		}
+		data := chunk.Data
+		for i := 0; i < n; i++ {
+			out = append(out, Chunk{Type: "IDAT", Data: data[:size]})
+			data = data[size:]
+		}
+		out = append(out, Chunk{Type: "IDAT", Data: data})
+	}
+	return out
+}
+
+// appendToLastIDAT appends payload to the last IDAT chunk, after the zlib stream
+func appendToLastIDAT(payload []byte) func([]Chunk) []Chunk {
+	return func(chunks []Chunk) []Chunk {
+		for i := len(chunks) - 1; i >= 0; i-- {
+			if chunks[i].Type == "IDAT" {
+				chunks[i].Data = append(append([]byte(nil), chunks[i].Data...), payload...)
+				break
+			}
+		}
+		return chunks
+	}
+}
+
+func TestChunkLayoutTrailing(t *testing.T) {
+	payload := []byte("hidden after the stream")
+	chunks, err := ReadChunks(rebuild(t, 8192, appendToLastIDAT(payload)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layout := AnalyzeChunkLayout(chunks); !bytes.Equal(layout.Trailing, payload) {
+		t.Errorf("got trailing data %q, want %q", layout.Trailing, payload)
+	}
+}
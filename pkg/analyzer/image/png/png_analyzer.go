@@ -4,10 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"image"
-	"image/png"
-	"os"
+	"strings"
 
 	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/analyzer/carve"
 	"DeSteGo/pkg/analyzer/image/lsb"
 	"DeSteGo/pkg/models"
 )
@@ -41,21 +41,73 @@ func NewPNGAnalyzer() *PNGAnalyzer {
 
 // Analyze performs analysis on a PNG file
 func (a *PNGAnalyzer) Analyze(filePath string, options analyzer.AnalysisOptions) (*models.AnalysisResult, error) {
-	// Open the file
-	file, err := os.Open(filePath)
+	data, err := models.ReadFile(options.Source, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
-	defer file.Close()
 
-	// Decode the PNG image
-	img, err := png.Decode(file)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode PNG: %w", err)
+	// Data prepended to the PNG hides its signature from the decoder
+	prefix := carve.FindPrefix(data, "png")
+	start := 0
+	if prefix != nil {
+		start = prefix.Offset
+	}
+
+	// Decode the PNG image, repairing chunk CRCs and a missing IEND if needed.
+	// The shared image is the same unless a prefix was cut off.
+	img, repairs, ok := models.SourceImage(options.Source, filePath, "png")
+	if !ok || prefix != nil {
+		if img, repairs, err = DecodeLenient(data[start:]); err != nil {
+			// The decoder rejects chunks placed inside or after the IDAT run
+			if result := undecodableResult(data[start:], err, filePath, options); result != nil {
+				return result, nil
+			}
+			return nil, fmt.Errorf("failed to decode PNG: %w", err)
+		}
 	}
 
 	// Pass to image analyzer
-	return a.AnalyzeImage(img, options)
+	result, err := a.AnalyzeImage(img, options)
+	if err != nil {
+		return nil, err
+	}
+	result.Filename = filePath
+	analyzer.AddRepairs(result, repairs)
+
+	// Data can be smuggled between or inside the image data chunks
+	before := len(result.Findings)
+	analyzeChunkLayout(data[start:], filePath, options, result)
+	if len(result.Findings) == before {
+		result.AddCheck(fmt.Sprintf("IDAT chunks laid out as an encoder writes them (%d)", result.Details["idat_chunks"]))
+	}
+
+	// Scanline filter bytes are invisible in the decoded image
+	before = len(result.Findings)
+	analyzeFilters(data[start:], filePath, options, result)
+	if len(result.Findings) == before {
+		result.AddCheck("scanline filters as an encoder would choose them")
+	}
+
+	// Text chunks can hide data in characters no viewer shows
+	analyzeText(data[start:], filePath, options, result)
+
+	// Viewers ignore the color chunks, and iCCP can hold a profile of any size
+	chunks, _ := ReadChunks(data[start:])
+	analyzeColorChunks(chunks, filePath, options, result)
+
+	// Look for files hidden before, inside or after the PNG stream
+	before = len(result.Findings)
+	carve.AnalyzePrefix(data, prefix, filePath, options, result)
+	carve.AnalyzeEmbeddedFiles(data, "png", filePath, options, result)
+	if end := carve.ImageEnd(data[start:]); end > 0 {
+		carve.AnalyzeAppended(data, start+end, filePath, options, result)
+	}
+	if len(result.Findings) == before {
+		result.AddCheck("no prepended, embedded or appended files")
+	}
+
+	result.SetCleanRationale()
+	return result, nil
 }
 
 // AnalyzeImage analyzes a decoded PNG image
@@ -78,43 +130,246 @@ func (a *PNGAnalyzer) AnalyzeImage(img image.Image, options analyzer.AnalysisOpt
 
 	// Add basic image info
 	result.Details = map[string]interface{}{
-		"width":  width,
-		"height": height,
+		"width":    width,
+		"height":   height,
+		"capacity": lsb.EstimateCapacity(img),
 	}
 
 	// Run LSB analysis using the shared package
+	thresholds := options.Thresholds()
 	lsbResult, err := lsb.AnalyzeDistribution(img)
 	if err != nil {
 		return nil, fmt.Errorf("LSB analysis failed: %w", err)
 	}
 
+	result.Details["bit_depth"] = lsbResult.BitDepth
+
 	// Update result with LSB findings
-	result.DetectionScore = lsbResult.AnomalyScore
+	result.DetectionScore = thresholds.LSBScore(lsbResult.AnomalyScore)
 	result.Confidence = lsbResult.Confidence
+	result.SetDetectorScore(analyzer.DetectorLSBDistribution, result.DetectionScore)
 
 	// Add findings based on LSB analysis
-	if lsbResult.AnomalyScore > 0.8 {
+	if lsbResult.AnomalyScore > thresholds.LSBAnomalyHigh {
 		result.AddFinding("Highly anomalous LSB distribution", 0.9,
-			fmt.Sprintf("Statistical anomaly score=%.4f (>0.8 is suspicious)", lsbResult.AnomalyScore))
+			fmt.Sprintf("Statistical anomaly score=%.4f (>%g is suspicious)", lsbResult.AnomalyScore, thresholds.LSBAnomalyHigh))
 		result.PossibleAlgorithm = "LSB Steganography"
 
 		result.Recommendations = append(result.Recommendations,
 			"Extract LSB data using specialized tools",
 			"Check for hidden text patterns in LSB data")
-	} else if lsbResult.AnomalyScore > 0.5 {
+	} else if lsbResult.AnomalyScore > thresholds.LSBAnomalyUnusual {
 		result.AddFinding("Unusual LSB distribution", 0.7,
-			fmt.Sprintf("Statistical anomaly score=%.4f (>0.5 is unusual)", lsbResult.AnomalyScore))
+			fmt.Sprintf("Statistical anomaly score=%.4f (>%g is unusual)", lsbResult.AnomalyScore, thresholds.LSBAnomalyUnusual))
 		result.Recommendations = append(result.Recommendations,
 			"Run further analysis with specialized tools")
+	} else {
+		result.AddCheck(fmt.Sprintf("LSB distribution natural (anomaly score %.2f)", lsbResult.AnomalyScore))
+	}
+
+	// Where the embedding is tells the user which extraction to try
+	profile := lsb.ClassifyEmbedding(img)
+	result.Details["embedding_pattern"] = profile.Pattern
+	result.Details["embedding_fraction"] = profile.Fraction
+	chiSquare := 0.0
+	switch profile.Pattern {
+	case lsb.PatternSequential:
+		result.AddExtractionHint("lsb-sequential", 0.7, map[string]interface{}{"fraction": profile.Fraction})
+		result.AddFinding("LSB pairs equalized at the start of the image", 0.7,
+			fmt.Sprintf("Sequential embedding over the first %.0f%% of pixels", profile.Fraction*100))
+		chiSquare = 0.7
+		if result.DetectionScore < 0.7 {
+			result.DetectionScore = 0.7
+			result.PossibleAlgorithm = "LSB Steganography"
+		}
+	case lsb.PatternFullImage:
+		result.AddExtractionHint("lsb-rgb", 0.6, map[string]interface{}{"fraction": profile.Fraction})
+		result.AddFinding("LSB pairs equalized across the whole image", 0.6,
+			fmt.Sprintf("Full-capacity embedding over %.0f%% of pixels", profile.Fraction*100))
+		chiSquare = 0.6
+		if result.DetectionScore < 0.6 {
+			result.DetectionScore = 0.6
+			result.PossibleAlgorithm = "LSB Steganography"
+		}
+	default:
+		result.AddCheck(fmt.Sprintf("no LSB pair equalization (chi-square p=%.2f)", profile.MaxPValue()))
+	}
+
+	// Each channel's value pairs tested on their own catch payloads written to
+	// only some channels, which the combined test dilutes
+	pov := lsb.AnalyzePairsOfValues(img)
+	result.Details["pov_p_values"] = map[string]float64{"R": pov.PValues[0], "G": pov.PValues[1], "B": pov.PValues[2]}
+	result.Details["pov_fractions"] = map[string]float64{"R": pov.Fractions[0], "G": pov.Fractions[1], "B": pov.Fractions[2]}
+	sequential, selective := pov.Sequential(), pov.Selective()
+	switch {
+	case len(sequential) > 0 && profile.Pattern == lsb.PatternSequential:
+		// Reported by the combined test above
+	case len(sequential) > 0:
+		result.AddFinding(fmt.Sprintf("%s channel LSB pairs equalized at the start of the image", strings.Join(sequential, "/")), 0.7, pov.Describe())
+		result.AddExtractionHint("lsb-sequential", 0.7, map[string]interface{}{"channels": strings.Join(sequential, "")})
+		chiSquare = 0.7
+		if result.DetectionScore < 0.7 {
+			result.DetectionScore = 0.7
+			result.PossibleAlgorithm = "LSB Steganography"
+		}
+	case len(selective) > 0:
+		result.AddFinding(fmt.Sprintf("LSB pairs equalized in the %s channel only", strings.Join(selective, "/")), 0.7, pov.Describe())
+		result.AddExtractionHint("lsb-rgb", 0.7, map[string]interface{}{"channels": strings.Join(selective, "")})
+		chiSquare = 0.7
+		if result.DetectionScore < 0.7 {
+			result.DetectionScore = 0.7
+			result.PossibleAlgorithm = "LSB Steganography"
+		}
+	default:
+		result.AddCheck(fmt.Sprintf("no per-channel LSB pair equalization (p R %.2f, G %.2f, B %.2f)", pov.PValues[0], pov.PValues[1], pov.PValues[2]))
+	}
+	result.SetDetectorScore(analyzer.DetectorChiSquare, chiSquare)
+
+	// Data hidden only in the alpha channel of an otherwise opaque image
+	alpha := lsb.AnalyzeAlphaChannel(img)
+	if alpha.HasAlpha {
+		result.Details["alpha_lsb_entropy"] = alpha.LSBEntropy
+	}
+	alphaData := alpha.NearOpaque && alpha.LSBEntropy > thresholds.AlphaEntropy
+	if alpha.HasAlpha && !alphaData {
+		result.AddCheck(fmt.Sprintf("alpha LSBs consistent (entropy %.2f)", alpha.LSBEntropy))
+	}
+	if alphaData {
+		result.AddFinding("Alpha channel LSBs vary in an otherwise opaque image", 0.85,
+			fmt.Sprintf("Alpha values are all 254/255 with LSB entropy=%.4f", alpha.LSBEntropy))
+		result.AddExtractionHint("lsb-alpha", 0.85, nil)
+		result.Recommendations = append(result.Recommendations,
+			"Extract the alpha channel LSBs (alpha-only LSB steganography)")
+		if result.DetectionScore < 0.85 {
+			result.DetectionScore = 0.85
+			result.PossibleAlgorithm = "Alpha LSB Steganography"
+		}
+	}
+
+	// Twin palette entries let the index choice carry data
+	palette := lsb.AnalyzePalette(img)
+	if palette.Indexed {
+		result.Details["palette_size"] = palette.Size
+		result.Details["palette_used"] = palette.Used
+		result.Details["palette_duplicates"] = palette.Duplicates
+		result.Details["palette_near_duplicates"] = palette.NearDuplicates
+		switch {
+		case palette.Suspicious:
+			result.AddFinding("Palette entries with identical colors are used interchangeably", 0.85, palette.Describe())
+			result.AddExtractionHint("palette-index", 0.85, map[string]interface{}{"duplicates": palette.Duplicates})
+			result.Recommendations = append(result.Recommendations,
+				"Read one bit per pixel from which of the twin palette entries it uses")
+			if result.DetectionScore < 0.85 {
+				result.DetectionScore = 0.85
+				result.PossibleAlgorithm = "Palette Index Steganography"
+			}
+		case palette.Duplicates > 0:
+			result.AddFinding("Palette contains duplicate colors", 0.4, palette.Describe())
+			if result.DetectionScore < 0.4 {
+				result.DetectionScore = 0.4
+			}
+		default:
+			result.AddCheck(fmt.Sprintf("no duplicate palette colors (%d of %d entries used)", palette.Used, palette.Size))
+		}
+	}
+
+	// The same bits written into every channel make the LSB planes agree
+	planes := lsb.AnalyzePlaneCorrelation(img)
+	result.Details["lsb_plane_correlation"] = planes.Correlation
+	if planes.Correlation <= thresholds.PlaneCorrelation {
+		result.AddCheck(fmt.Sprintf("LSB planes independent (correlation %.2f)", planes.Correlation))
+		result.SetDetectorScore(analyzer.DetectorPlaneCorrelation, 0)
+	} else {
+		confidence := 0.6 + (planes.Correlation-0.8)*1.5
+		result.SetDetectorScore(analyzer.DetectorPlaneCorrelation, confidence)
+		result.AddFinding("R, G and B LSB planes are nearly identical", confidence, planes.Describe())
+		result.AddExtractionHint("lsb-sequential", confidence, map[string]interface{}{"channels": "any single channel"})
+		result.Recommendations = append(result.Recommendations,
+			"Extract the LSBs of a single channel (the same data is repeated in R, G and B)")
+		if result.DetectionScore < confidence {
+			result.DetectionScore = confidence
+			result.PossibleAlgorithm = "LSB Steganography"
+		}
+	}
+
+	// Tools that clear every LSB before embedding leave the image mostly even
+	parity := lsb.AnalyzeParity(img)
+	result.Details["even_ratio"] = map[string]float64{
+		"R": parity.EvenRatio[0],
+		"G": parity.EvenRatio[1],
+		"B": parity.EvenRatio[2],
+	}
+	result.Details["even_tail"] = parity.EvenTail
+	switch {
+	case parity.Quantized:
+		result.AddCheck(fmt.Sprintf("sample parity follows quantized channels (%.0f%% even in the least even channel)", parity.MinEvenRatio()*100))
+		result.SetDetectorScore(analyzer.DetectorParity, 0)
+	case !parity.Normalized(thresholds.ParityEvenRatio):
+		result.AddCheck(fmt.Sprintf("even and odd samples balanced (%.0f%% even in the least even channel)", parity.MinEvenRatio()*100))
+		result.SetDetectorScore(analyzer.DetectorParity, 0)
+	default:
+		confidence := 0.75
+		if parity.MinEvenRatio() > thresholds.ParityEvenRatio {
+			confidence = min(0.6+(parity.MinEvenRatio()-thresholds.ParityEvenRatio), 0.9)
+		}
+		result.SetDetectorScore(analyzer.DetectorParity, confidence)
+		result.AddFinding("Samples were normalized to even values", confidence, parity.Describe())
+		result.AddExtractionHint("lsb-sequential", confidence, map[string]interface{}{"even_tail": parity.EvenTail})
+		result.Recommendations = append(result.Recommendations,
+			"Extract the LSBs from the start of the image; the tool that set every sample even writes its payload there")
+		if result.DetectionScore < confidence {
+			result.DetectionScore = confidence
+			result.PossibleAlgorithm = "LSB Steganography"
+		}
+	}
+
+	// LSB embedding breaks the JPEG round trip of images saved from a JPEG
+	if diff, err := lsb.AnalyzeRecompression(img); err == nil && diff.Quality > 0 {
+		result.Details["recompress_quality"] = diff.Quality
+		result.Details["fragile_lsb_fraction"] = diff.FragileLSBs
+		if !diff.Suspicious {
+			result.AddCheck(fmt.Sprintf("JPEG round trip intact (quality %d, %.0f%% of blocks)", diff.Quality, diff.Compatible*100))
+		} else {
+			confidence := 0.7 + (diff.Intact-diff.Broken)*0.25
+			result.AddFinding("Pixels saved from a JPEG no longer survive re-encoding in part of the image", confidence, diff.Describe())
+			result.AddExtractionHint("lsb-sequential", confidence, nil)
+			result.Recommendations = append(result.Recommendations,
+				"Extract the LSBs of the region that does not round-trip through JPEG")
+			if result.DetectionScore < confidence {
+				result.DetectionScore = confidence
+				result.PossibleAlgorithm = "LSB Steganography"
+			}
+		}
+	}
+
+	// Pixel-value differencing hides data in edges, where the LSB tests miss it
+	pvdProbability, pvdDetails := (&lsb.PVDDetector{}).Detect(img)
+	result.Details["pvd_probability"] = pvdProbability
+	result.SetDetectorScore(analyzer.DetectorPVD, pvdProbability)
+	if pvdProbability <= thresholds.DetectorProbability {
+		result.AddCheck(fmt.Sprintf("no PVD histogram steps (%.2f)", pvdProbability))
+	} else {
+		result.AddFinding("Pixel difference histogram steps at PVD range boundaries", pvdProbability, pvdDetails)
+		result.Recommendations = append(result.Recommendations,
+			"Try extracting with a pixel-value differencing (PVD) tool")
+		if result.DetectionScore < pvdProbability {
+			result.DetectionScore = pvdProbability
+			result.PossibleAlgorithm = "PVD Steganography"
+		}
 	}
 
-	// Add entropy-based findings
-	if lsbResult.Entropy > 0.99 {
+	// Add entropy-based findings; noise that randomizes the bit plane above the
+	// LSBs as well explains perfect LSB entropy
+	if lsbResult.Entropy > thresholds.LSBEntropyHigh && lsbResult.ReferenceEntropy <= thresholds.LSBEntropyHigh {
 		result.AddFinding("Perfect LSB entropy", 0.9,
-			fmt.Sprintf("LSB entropy=%.4f (unnaturally perfect randomness)", lsbResult.Entropy))
-	} else if lsbResult.Entropy < 0.3 {
+			fmt.Sprintf("LSB entropy=%.4f (unnaturally perfect randomness, next bit plane %.4f)", lsbResult.Entropy, lsbResult.ReferenceEntropy))
+	} else if lsbResult.Entropy > thresholds.LSBEntropyHigh {
+		result.AddCheck(fmt.Sprintf("LSB entropy %.2f matches the next bit plane (%.2f)", lsbResult.Entropy, lsbResult.ReferenceEntropy))
+	} else if lsbResult.Entropy < thresholds.LSBEntropyLow {
 		result.AddFinding("Abnormally low LSB entropy", 0.8,
 			fmt.Sprintf("LSB entropy=%.4f (unnaturally low randomness)", lsbResult.Entropy))
+	} else {
+		result.AddCheck(fmt.Sprintf("LSB entropy in the natural range (%.2f)", lsbResult.Entropy))
 	}
 
 	return result, nil
@@ -0,0 +1,253 @@
+package png
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/models"
+)
+
+func TestAnalyzeFixtures(t *testing.T) {
+	tests := []struct {
+		name    string
+		flagged bool
+	}{
+		{"clean.png", false},
+		{"lsb_rgb.png", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewPNGAnalyzer().Analyze(fixtures.Path(tt.name), analyzer.AnalysisOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := result.DetectionScore >= 0.5; got != tt.flagged {
+				t.Errorf("score %.2f with findings %v, want flagged %v", result.DetectionScore, result.Findings, tt.flagged)
+			}
+		})
+	}
+}
+
+// TestAnalyzeDetectionRate embeds random payloads in noisy photo-like carriers,
+// whose LSBs are balanced with or without a payload, and counts the images the
+// analyzer flags
+func TestAnalyzeDetectionRate(t *testing.T) {
+	const size, seeds = 256, 4
+	tests := []struct {
+		name     string
+		channels []int   // Channels the payload is written to
+		share    float64 // Share of their LSBs it replaces
+	}{
+		{"clean", nil, 0},
+		{"RGB 10%", []int{0, 1, 2}, 0.1},
+		{"RGB 50%", []int{0, 1, 2}, 0.5},
+		{"RGB 100%", []int{0, 1, 2}, 1},
+		{"B 100%", []int{2}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flagged := 0
+			for seed := int64(1); seed <= seeds; seed++ {
+				img := fixtures.Carrier(size, size, seed)
+				payload := fixtures.RandomPayload(int(tt.share*size*size)*len(tt.channels)/8, seed+100)
+				if err := fixtures.EmbedLSB(img, payload, tt.channels, 0); err != nil {
+					t.Fatal(err)
+				}
+				result, err := NewPNGAnalyzer().AnalyzeImage(img, analyzer.AnalysisOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.DetectionScore >= 0.5 {
+					flagged++
+				}
+			}
+			want := seeds
+			if tt.channels == nil {
+				want = 0
+			}
+			if flagged != want {
+				t.Errorf("flagged %d of %d carriers, want %d", flagged, seeds, want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeAlphaOnly(t *testing.T) {
+	const size = 128
+	tests := []struct {
+		name     string
+		channels []int             // Channels the payload is written to
+		alpha    func(y int) uint8 // Alpha before embedding
+		flagged  bool
+	}{
+		{"opaque", nil, func(int) uint8 { return 255 }, false},
+		{"payload in alpha", []int{3}, func(int) uint8 { return 255 }, true},
+		{"payload in RGB", []int{0, 1, 2}, func(int) uint8 { return 255 }, false},
+		{"alpha gradient", nil, func(y int) uint8 { return uint8(64 + y) }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			carrier := fixtures.Carrier(size, size, 2)
+			for y := 0; y < size; y++ {
+				for x := 0; x < size; x++ {
+					carrier.Pix[carrier.PixOffset(x, y)+3] = tt.alpha(y)
+				}
+			}
+			payload := fixtures.RandomPayload(size*size*len(tt.channels)/8, 3)
+			if err := fixtures.EmbedLSB(carrier, payload, tt.channels, 0); err != nil {
+				t.Fatal(err)
+			}
+			// The samples are stored unpremultiplied, as in a PNG file
+			img := image.NewNRGBA(carrier.Bounds())
+			copy(img.Pix, carrier.Pix)
+
+			result, err := NewPNGAnalyzer().AnalyzeImage(img, analyzer.AnalysisOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			flagged := false
+			for _, f := range result.Findings {
+				if f.Description == "Alpha channel LSBs vary in an otherwise opaque image" {
+					flagged = true
+				}
+			}
+			hinted := false
+			for _, hint := range result.ExtractionHints {
+				if hint.Algorithm == "lsb-alpha" {
+					hinted = true
+				}
+			}
+			if flagged != tt.flagged || hinted != tt.flagged {
+				t.Errorf("alpha finding %v and lsb-alpha hint %v, want %v (alpha entropy %v)",
+					flagged, hinted, tt.flagged, result.Details["alpha_lsb_entropy"])
+			}
+			if tt.flagged && result.DetectionScore < 0.85 {
+				t.Errorf("got score %.2f, want at least 0.85", result.DetectionScore)
+			}
+		})
+	}
+}
+
+func TestAnalyzeFilterBits(t *testing.T) {
+	const message = "filters hide this"
+	tests := []struct {
+		name     string
+		zero     byte // Filter type of the rows that read as 0
+		one      byte // and of the rows that read as 1
+		detected bool
+	}{
+		{"none and sub", 0, 1, true},
+		{"sub and up", 1, 2, true},
+		{"one filter", 1, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := fixtures.Carrier(32, 8*len(message), 7)
+			bounds := img.Bounds()
+			rowBytes := bounds.Dx() * 4
+
+			// Filter each row with the type its bit of the message selects
+			var raw []byte
+			for y := 0; y < bounds.Dy(); y++ {
+				filter := tt.zero
+				if message[y/8]>>(7-y%8)&1 == 1 {
+					filter = tt.one
+				}
+				row := img.Pix[y*img.Stride : y*img.Stride+rowBytes]
+				raw = append(raw, filter)
+				for i, v := range row {
+					switch {
+					case filter == 1 && i >= 4:
+						v -= row[i-4]
+					case filter == 2 && y > 0:
+						v -= img.Pix[(y-1)*img.Stride+i]
+					}
+					raw = append(raw, v)
+				}
+			}
+			var idat bytes.Buffer
+			zw := zlib.NewWriter(&idat)
+			zw.Write(raw)
+			zw.Close()
+			ihdr := binary.BigEndian.AppendUint32(nil, uint32(bounds.Dx()))
+			ihdr = binary.BigEndian.AppendUint32(ihdr, uint32(bounds.Dy()))
+			ihdr = append(ihdr, 8, 6, 0, 0, 0)
+			data := encodeChunks([]Chunk{{Type: "IHDR", Data: ihdr}, {Type: "IDAT", Data: idat.Bytes()}, {Type: "IEND"}})
+
+			decoded, err := png.Decode(bytes.NewReader(data))
+			if err != nil || !bytes.Equal(decoded.(*image.NRGBA).Pix, img.Pix) {
+				t.Fatalf("the filtered PNG does not decode to the carrier (%v)", err)
+			}
+
+			dir := t.TempDir()
+			result := &models.AnalysisResult{Details: map[string]interface{}{}}
+			analyzeFilters(data, filepath.Join(dir, "filters.png"), analyzer.AnalysisOptions{Extract: true, OutputDir: dir}, result)
+
+			detected := false
+			for _, finding := range result.Findings {
+				detected = detected || finding.Description == "Scanline filter types encode text"
+			}
+			if detected != tt.detected {
+				t.Fatalf("got text finding %v, want %v; findings %+v", detected, tt.detected, result.Findings)
+			}
+			if !tt.detected {
+				return
+			}
+			written, err := os.ReadFile(filepath.Join(dir, "filters_filter_bits.txt"))
+			if err != nil || string(written) != message {
+				t.Errorf("got extracted %q (%v), want %q", written, err, message)
+			}
+		})
+	}
+}
+
+func TestAnalyzeCleanRationale(t *testing.T) {
+	tests := []struct {
+		fixture string
+		checks  []string // Start of each check the rationale must list, nil when the file is not clean
+	}{
+		{"clean.png", []string{
+			"IDAT chunks laid out as an encoder writes them",
+			"scanline filters as an encoder would choose them",
+			"no prepended, embedded or appended files",
+			"LSB distribution natural",
+			"no LSB pair equalization",
+			"no per-channel LSB pair equalization",
+			"LSB planes independent",
+			"no PVD histogram steps",
+		}},
+		{"lsb_rgb.png", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			result, err := NewPNGAnalyzer().Analyze(fixtures.Path(tt.fixture), analyzer.AnalysisOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			rationale, ok := result.Details["clean_rationale"].(string)
+			if tt.checks == nil {
+				if ok {
+					t.Errorf("got rationale %q for a file with findings", rationale)
+				}
+				return
+			}
+			if rationale != strings.Join(result.Checks, ", ") {
+				t.Errorf("got rationale %q, want the checks %q", rationale, result.Checks)
+			}
+			for _, check := range tt.checks {
+				if !strings.Contains(rationale, check) {
+					t.Errorf("rationale %q does not list %q", rationale, check)
+				}
+			}
+		})
+	}
+}
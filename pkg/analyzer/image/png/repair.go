@@ -0,0 +1,104 @@
+package png
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+)
+
+/*
+This file contains the lenient decoding of PNGs that Go's decoder rejects. The
+decoder checks the CRC of every chunk, ancillary ones included, so a tEXt chunk
+edited without updating its CRC keeps the whole image from being analyzed, and
+it gives up on a file cut off after its image data but before IEND. Both are
+typical of files edited by hand or by a hiding tool. RepairChunks recomputes
+the CRCs of ancillary chunks and ends a cut-off chunk run with IEND, and
+DecodeLenient decodes the repaired file when the file as it is does not decode.
+A critical chunk with a bad CRC is left as it is: its contents, such as the
+image size in IHDR, cannot be trusted.
+*/
+
+// iendChunk is an IEND chunk with its CRC
+var iendChunk = []byte("\x00\x00\x00\x00IEND\xae\x42\x60\x82")
+
+// maxDecodePixels caps the image size DecodeLenient will decode. Go's decoder
+// allocates the whole image up front from the size in IHDR.
+const maxDecodePixels = 50 * 1000 * 1000
+
+// DecodeLenient decodes a PNG, repairing its chunks when it does not decode as
+// it is. It returns the repairs that were needed, none when the file decoded as
+// it is. When the repaired file does not decode either, the error is that of
+// the file as it is.
+func DecodeLenient(data []byte) (image.Image, []string, error) {
+	img, err := decodeBounded(data)
+	if err == nil {
+		return img, nil, nil
+	}
+	repaired, repairs := RepairChunks(data)
+	if len(repairs) == 0 {
+		return nil, nil, err
+	}
+	img, repairErr := decodeBounded(repaired)
+	if repairErr != nil {
+		return nil, nil, err
+	}
+	return img, repairs, nil
+}
+
+// decodeBounded decodes a PNG whose IHDR size is within maxDecodePixels
+func decodeBounded(data []byte) (image.Image, error) {
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if pixels := int64(config.Width) * int64(config.Height); pixels > maxDecodePixels {
+		return nil, fmt.Errorf("image of %dx%d pixels exceeds the limit of %d pixels", config.Width, config.Height, maxDecodePixels)
+	}
+	return png.Decode(bytes.NewReader(data))
+}
+
+// RepairChunks returns a copy of data in which every ancillary chunk has the
+// CRC of its type and contents, and in which a chunk run that is cut off before IEND ends
+// with IEND instead of the partial chunk. It also describes each repair made.
+// Data that is not a PNG is returned as it is.
+func RepairChunks(data []byte) ([]byte, []string) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return data, nil
+	}
+
+	out := append([]byte{}, pngSignature...)
+	var repairs []string
+	pos := len(pngSignature)
+	for pos+12 <= len(data) {
+		length := binary.BigEndian.Uint32(data[pos:])
+		if length > maxChunkLength || uint64(pos)+12+uint64(length) > uint64(len(data)) {
+			break
+		}
+		end := pos + 8 + int(length)
+		chunkType := string(data[pos+4 : pos+8])
+		crc := crc32.ChecksumIEEE(data[pos+4 : end])
+		stored := binary.BigEndian.Uint32(data[end:])
+		// Critical chunks have an upper-case first letter and keep a bad CRC
+		if stored != crc && chunkType[0]&0x20 == 0 {
+			crc = stored
+		} else if stored != crc {
+			repairs = append(repairs, fmt.Sprintf("recomputed the CRC of the %s chunk at offset %d (0x%08X, stored 0x%08X)",
+				chunkType, pos, crc, stored))
+		}
+		out = append(out, data[pos:end]...)
+		out = binary.BigEndian.AppendUint32(out, crc)
+		pos = end + 4
+		if chunkType == "IEND" {
+			return append(out, data[pos:]...), repairs
+		}
+	}
+
+	if pos < len(data) {
+		repairs = append(repairs, fmt.Sprintf("dropped the %d bytes of the chunk cut off at offset %d", len(data)-pos, pos))
+	}
+	repairs = append(repairs, "appended the missing IEND chunk")
+	return append(out, iendChunk...), repairs
+}
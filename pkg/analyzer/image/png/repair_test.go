@@ -0,0 +1,115 @@
+package png
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/models"
+)
+
+// badCRC returns clean.png with a tEXt chunk whose CRC does not match it
+func badCRC(t *testing.T) []byte {
+	data, err := fixtures.Load("clean.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = fixtures.PNGChunk(data, "tEXt", []byte("Comment\x00edited after encoding"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-iendSize-1] ^= 0xFF // Last byte of the tEXt CRC
+	return data
+}
+
+// iendSize is the size of an IEND chunk
+const iendSize = 12
+
+func TestDecodeLenient(t *testing.T) {
+	clean, err := fixtures.Load("clean.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := badCRC(t)
+
+	// An IHDR width of 0x30000009, once with its CRC left stale and once with
+	// it recomputed
+	oversized := append([]byte(nil), clean...)
+	binary.BigEndian.PutUint32(oversized[16:], 0x30000009)
+	badIHDR := append([]byte(nil), oversized...)
+	binary.BigEndian.PutUint32(oversized[29:], crc32.ChecksumIEEE(oversized[12:29]))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		repairs []string // Prefixes of the repairs expected
+		fails   bool
+	}{
+		{"decodes as it is", clean, nil, false},
+		{"bad ancillary CRC", corrupt, []string{"recomputed the CRC of the tEXt chunk"}, false},
+		{"missing IEND", clean[:len(clean)-iendSize], []string{"appended the missing IEND chunk"}, false},
+		{"cut off IEND", clean[:len(clean)-4], []string{"dropped the 8 bytes", "appended the missing IEND chunk"}, false},
+		{"cut off image data", clean[:len(clean)/2], nil, true},
+		{"bad critical CRC", badIHDR, nil, true},
+		{"oversized IHDR", oversized, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, repairs, err := DecodeLenient(tt.data)
+			if tt.fails {
+				if err == nil {
+					t.Errorf("decoded with repairs %q, want an error", repairs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeLenient() error = %v", err)
+			}
+			if img.Bounds().Dx() != 128 {
+				t.Errorf("decoded %v, want a 128x128 image", img.Bounds())
+			}
+			if len(repairs) != len(tt.repairs) {
+				t.Fatalf("repairs %q, want %q", repairs, tt.repairs)
+			}
+			for i, prefix := range tt.repairs {
+				if !strings.HasPrefix(repairs[i], prefix) {
+					t.Errorf("repair %q, want one starting with %q", repairs[i], prefix)
+				}
+			}
+		})
+	}
+
+	// The corrupt chunk is what keeps Go's decoder from reading the file
+	if _, err := png.Decode(bytes.NewReader(corrupt)); err == nil {
+		t.Error("png.Decode() accepted the bad CRC, the test does not exercise the repair")
+	}
+}
+
+func TestAnalyzeBadCRC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad_crc.png")
+	if err := os.WriteFile(path, badCRC(t), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := NewPNGAnalyzer().Analyze(path, analyzer.AnalysisOptions{})
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	repairs, ok := result.Details["lenient_decode"].([]string)
+	if !ok || len(repairs) != 1 {
+		t.Errorf("lenient_decode %v, want the one repair", result.Details["lenient_decode"])
+	}
+	found := slices.ContainsFunc(result.Findings, func(f models.Finding) bool {
+		return f.Description == "Image decoded only after repairs"
+	})
+	if !found {
+		t.Error("no finding for the repairs")
+	}
+}
@@ -0,0 +1,118 @@
+package png
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/analyzer/whitespace"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the analysis of the PNG text chunks. tEXt holds Latin-1
+text, zTXt the same compressed, and iTXt UTF-8 text that may be compressed.
+Viewers show at most the keyword and the text, so invisible characters in the
+text are checked for hidden data.
+*/
+
+// maxTextChunk limits how much a compressed text chunk may inflate to
+const maxTextChunk = 1 << 20
+
+// TextChunk is the keyword and text of a tEXt, zTXt or iTXt chunk
+type TextChunk struct {
+	Type    string
+	Keyword string
+	Text    string
+}
+
+// TextChunks returns the text chunks of chunks in file order. Chunks that are
+// malformed or do not inflate are skipped.
+func TextChunks(chunks []Chunk) []TextChunk {
+	var texts []TextChunk
+	for _, chunk := range chunks {
+		keyword, rest, ok := bytes.Cut(chunk.Data, []byte{0})
+		if !ok {
+			continue
+		}
+		var text []byte
+		var err error
+		switch chunk.Type {
+		case "tEXt":
+			text = latin1(rest)
+		case "zTXt":
+			// Compression method byte, then zlib data
+			if len(rest) < 1 {
+				continue
+			}
+			if text, err = inflate(rest[1:]); err == nil {
+				text = latin1(text)
+			}
+		case "iTXt":
+			// Compression flag and method, language tag, translated keyword
+			if len(rest) < 2 {
+				continue
+			}
+			compressed := rest[0] == 1
+			_, rest, ok = bytes.Cut(rest[2:], []byte{0})
+			if !ok {
+				continue
+			}
+			_, text, ok = bytes.Cut(rest, []byte{0})
+			if !ok {
+				continue
+			}
+			if compressed {
+				text, err = inflate(text)
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		texts = append(texts, TextChunk{Type: chunk.Type, Keyword: string(latin1(keyword)), Text: string(text)})
+	}
+	return texts
+}
+
+// inflate decompresses zlib data of at most maxTextChunk bytes
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(io.LimitReader(r, maxTextChunk))
+}
+
+// latin1 converts Latin-1 text to UTF-8
+func latin1(data []byte) []byte {
+	var buf bytes.Buffer
+	for _, b := range data {
+		buf.WriteRune(rune(b))
+	}
+	return buf.Bytes()
+}
+
+// analyzeText checks the text chunks for text hidden in invisible characters
+func analyzeText(data []byte, filePath string, options analyzer.AnalysisOptions, result *models.AnalysisResult) {
+	chunks, _ := ReadChunks(data)
+	texts := TextChunks(chunks)
+	if len(texts) == 0 {
+		return
+	}
+
+	keywords := make([]string, len(texts))
+	before := len(result.Findings)
+	for i, text := range texts {
+		keywords[i] = text.Keyword
+		whitespace.Analyze(text.Text, fmt.Sprintf("PNG %s chunk %s", text.Type, text.Keyword), filePath, options, result)
+	}
+	result.Details["text_chunks"] = keywords
+	if len(result.Findings) == before {
+		result.AddCheck(fmt.Sprintf("no invisible characters in %d text chunks", len(texts)))
+	}
+}
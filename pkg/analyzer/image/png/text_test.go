@@ -0,0 +1,48 @@
+package png
+
+import (
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer/whitespace"
+)
+
+func TestTextChunksFixtures(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks int // Text chunks the fixture has
+	}{
+		{"itxt_zero_width.png", 1},
+		{"clean.png", 0},
+		{"lsb_rgb.png", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := fixtures.Load(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			chunks, err := ReadChunks(data)
+			if err != nil {
+				t.Fatalf("failed to read chunks: %v", err)
+			}
+			texts := TextChunks(chunks)
+			if len(texts) != tt.chunks {
+				t.Fatalf("found %d text chunks, want %d", len(texts), tt.chunks)
+			}
+			if tt.chunks == 0 {
+				return
+			}
+
+			fixture, _ := fixtures.Lookup(tt.name)
+			text := texts[0]
+			if text.Type != "iTXt" || text.Keyword != "Comment" {
+				t.Errorf("found %s chunk %s, want iTXt chunk Comment", text.Type, text.Keyword)
+			}
+			hidden := whitespace.Scan(text.Text)
+			if len(hidden) != 1 || string(hidden[0].Decoded) != fixture.Payload {
+				t.Errorf("decoded %+v, want %q", hidden, fixture.Payload)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package qr
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"github.com/makiuchi-d/gozxing"
+	multiqr "github.com/makiuchi-d/gozxing/multi/qrcode"
+
+	"DeSteGo/pkg/c2"
+	"DeSteGo/pkg/models"
+	"DeSteGo/pkg/rules"
+)
+
+/*
+This file contains the QR code scan. A QR code pasted into a corner of an image,
+or blended into a region at a contrast too low to notice, carries a URL or key
+past anyone who only looks at the picture. The image is searched twice: as it
+is, and after a local contrast stretch that maps each neighbourhood's darkest
+and brightest pixels to black and white, so a code a few gray levels away from
+its background becomes readable. The decoded text of every code is reported and
+checked against the C2 command list and the indicator rules.
+*/
+
+// Contrast stretch parameters
+const (
+	stretchTile   = 8 // Pixels per side of the tiles whose range is measured
+	stretchRadius = 2 // Tiles on each side included in a pixel's neighbourhood
+	minRange      = 2 // Neighbourhoods with a smaller range are left flat
+)
+
+// Code is a QR code found in an image
+type Code struct {
+	Text   string
+	Bounds image.Rectangle // Box around the code's finder patterns
+	Pass   string          // "original" or "contrast-stretched"
+}
+
+// Scan returns the QR codes in img. Codes found by both passes are returned
+// once, from the first pass that found them.
+func Scan(img image.Image) ([]Code, error) {
+	var codes []Code
+	seen := map[string]bool{}
+	passes := []struct {
+		name string
+		img  image.Image
+	}{
+		{"original", img},
+		{"contrast-stretched", stretchContrast(img)},
+	}
+	for _, pass := range passes {
+		found, err := decode(pass.img)
+		if err != nil {
+			return codes, err
+		}
+		for _, result := range found {
+			if seen[result.GetText()] {
+				continue
+			}
+			seen[result.GetText()] = true
+			codes = append(codes, Code{
+				Text:   result.GetText(),
+				Bounds: bounds(result.GetResultPoints()).Add(img.Bounds().Min),
+				Pass:   pass.name,
+			})
+		}
+	}
+	return codes, nil
+}
+
+// decode returns every QR code the decoder finds in img
+func decode(img image.Image) ([]*gozxing.Result, error) {
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare image for QR decoding: %w", err)
+	}
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+	results, err := multiqr.NewQRCodeMultiReader().DecodeMultiple(bitmap, hints)
+	if err != nil {
+		// No code, or only codes too damaged to decode
+		return nil, nil
+	}
+	return results, nil
+}
+
+// bounds returns the box around a code's result points
+func bounds(points []gozxing.ResultPoint) image.Rectangle {
+	var r image.Rectangle
+	for i, p := range points {
+		pt := image.Pt(int(p.GetX()), int(p.GetY()))
+		if i == 0 {
+			r = image.Rectangle{Min: pt, Max: pt.Add(image.Pt(1, 1))}
+			continue
+		}
+		r = r.Union(image.Rectangle{Min: pt, Max: pt.Add(image.Pt(1, 1))})
+	}
+	return r
+}
+
+// stretchContrast returns the luminance of img with every pixel mapped from the
+// range of its neighbourhood to the full 0-255 range
+func stretchContrast(img image.Image) *image.Gray {
+	b := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			gray.SetGray(x, y, color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray))
+		}
+	}
+
+	// Range of each tile
+	tilesWide := (b.Dx() + stretchTile - 1) / stretchTile
+	tilesHigh := (b.Dy() + stretchTile - 1) / stretchTile
+	lo := make([]uint8, tilesWide*tilesHigh)
+	hi := make([]uint8, tilesWide*tilesHigh)
+	for ty := 0; ty < tilesHigh; ty++ {
+		for tx := 0; tx < tilesWide; tx++ {
+			minV, maxV := uint8(255), uint8(0)
+			for y := ty * stretchTile; y < min((ty+1)*stretchTile, b.Dy()); y++ {
+				for x := tx * stretchTile; x < min((tx+1)*stretchTile, b.Dx()); x++ {
+					v := gray.Pix[y*gray.Stride+x]
+					minV, maxV = min(minV, v), max(maxV, v)
+				}
+			}
+			lo[ty*tilesWide+tx], hi[ty*tilesWide+tx] = minV, maxV
+		}
+	}
+
+	out := image.NewGray(gray.Rect)
+	for ty := 0; ty < tilesHigh; ty++ {
+		for tx := 0; tx < tilesWide; tx++ {
+			// Range of the neighbourhood, so that tiles inside one module of a
+			// code still see both of its colors
+			minV, maxV := uint8(255), uint8(0)
+			for ny := max(ty-stretchRadius, 0); ny <= min(ty+stretchRadius, tilesHigh-1); ny++ {
+				for nx := max(tx-stretchRadius, 0); nx <= min(tx+stretchRadius, tilesWide-1); nx++ {
+					minV, maxV = min(minV, lo[ny*tilesWide+nx]), max(maxV, hi[ny*tilesWide+nx])
+				}
+			}
+			span := int(maxV) - int(minV)
+			for y := ty * stretchTile; y < min((ty+1)*stretchTile, b.Dy()); y++ {
+				for x := tx * stretchTile; x < min((tx+1)*stretchTile, b.Dx()); x++ {
+					v := uint8(128)
+					if span >= minRange {
+						v = uint8((int(gray.Pix[y*gray.Stride+x]) - int(minV)) * 255 / span)
+					}
+					out.Pix[y*out.Stride+x] = v
+				}
+			}
+		}
+	}
+	return out
+}
+
+// Analyze scans img for QR codes and reports each one with its decoded text,
+// checked against detector and ruleSet
+func Analyze(img image.Image, detector *c2.Detector, ruleSet *rules.RuleSet, result *models.AnalysisResult) error {
+	codes, err := Scan(img)
+	if err != nil {
+		return err
+	}
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	if len(codes) == 0 {
+		result.AddCheck("no QR codes")
+		return nil
+	}
+
+	var texts []string
+	for i, code := range codes {
+		texts = append(texts, code.Text)
+		source := fmt.Sprintf("QR code %d", i+1)
+		description := "QR code found in the image"
+		if code.Pass != "original" {
+			description = "Low-contrast QR code found in the image"
+		}
+		result.AddFinding(description, 0.5,
+			fmt.Sprintf("%s at %v (%s pass) decodes to %q", source, code.Bounds, code.Pass, shorten(code.Text)))
+		if result.DetectionScore < 0.5 {
+			result.DetectionScore = 0.5
+		}
+
+		if matches := detector.Match([]byte(code.Text)); len(matches) >= c2.MinMatches {
+			result.AddFindingWithSeverity(fmt.Sprintf("%s contains C2-style commands", source), models.SeverityConfirmed, 1.0,
+				strings.Join(matches, ", "))
+		}
+		rules.Apply(ruleSet.Evaluate([]byte(code.Text)), source, result)
+	}
+	result.Details["qr_codes"] = texts
+	result.Recommendations = append(result.Recommendations,
+		"Inspect the text of the QR codes found in the image")
+	return nil
+}
+
+// shorten limits decoded text shown in a finding
+func shorten(text string) string {
+	const limit = 200
+	if len(text) <= limit {
+		return text
+	}
+	return text[:limit] + "..."
+}
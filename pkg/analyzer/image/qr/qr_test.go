@@ -0,0 +1,74 @@
+package qr
+
+import (
+	"image"
+	"image/color"
+	"slices"
+	"testing"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+
+	"DeSteGo/pkg/c2"
+	"DeSteGo/pkg/models"
+	"DeSteGo/pkg/rules"
+)
+
+func TestAnalyze(t *testing.T) {
+	const url = "http://evil.example.com/dl/payload.exe"
+	matrix, err := qrcode.NewQRCodeWriter().Encode(url, gozxing.BarcodeFormat_QR_CODE, 200, 200, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// picture returns a 600x400 gray image of the given level, with the code
+	// drawn at (300, 100) in dark modules of level code unless code is negative
+	picture := func(background, code int) image.Image {
+		img := image.NewGray(image.Rect(0, 0, 600, 400))
+		for i := range img.Pix {
+			img.Pix[i] = uint8(background)
+		}
+		if code < 0 {
+			return img
+		}
+		for y := 0; y < matrix.GetHeight(); y++ {
+			for x := 0; x < matrix.GetWidth(); x++ {
+				if matrix.Get(x, y) {
+					img.SetGray(300+x, 100+y, color.Gray{uint8(code)})
+				}
+			}
+		}
+		return img
+	}
+
+	tests := []struct {
+		name     string
+		image    image.Image
+		findings []string
+	}{
+		{"black on white", picture(255, 0), []string{"QR code found in the image", "Rule executable-url matched the QR code 1", "Rule url matched the QR code 1"}},
+		{"six levels darker", picture(180, 174), []string{"Low-contrast QR code found in the image", "Rule executable-url matched the QR code 1", "Rule url matched the QR code 1"}},
+		{"no code", picture(180, -1), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &models.AnalysisResult{}
+			if err := Analyze(tt.image, c2.NewDetector(), rules.Default(), result); err != nil {
+				t.Fatal(err)
+			}
+			var found []string
+			for _, finding := range result.Findings {
+				found = append(found, finding.Description)
+			}
+			if !slices.Equal(found, tt.findings) {
+				t.Errorf("got findings %q, want %q", found, tt.findings)
+			}
+			if codes, _ := result.Details["qr_codes"].([]string); tt.findings != nil && !slices.Equal(codes, []string{url}) {
+				t.Errorf("got codes %q, want %q", codes, url)
+			}
+			if tt.findings == nil && !slices.Contains(result.Checks, "no QR codes") {
+				t.Errorf("checks %q do not include the QR scan", result.Checks)
+			}
+		})
+	}
+}
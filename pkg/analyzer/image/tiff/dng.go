@@ -0,0 +1,111 @@
+package tiff
+
+import (
+	"fmt"
+	"image"
+
+	"DeSteGo/pkg/analyzer/image/exif"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the DNG support of the TIFF analyzer. A DNG is a TIFF whose
+first IFD usually holds a reduced-resolution preview, with the raw sensor data
+in a sub-IFD that Go's TIFF decoder cannot read. The pixel analyses run on an
+uncompressed preview. A JPEG-compressed preview is not decoded: the LSB tests
+do not apply to JPEG pixels, so it is pointed out for analysis as a JPEG
+instead. The tag, text and embedded file checks run on every DNG, so a DNG whose
+image data does not decode still gets a result rather than an error.
+*/
+
+// TIFF tags the DNG support reads
+const (
+	tagNewSubFileType  = 0x00FE
+	tagCompression     = 0x0103
+	tagJPEGThumbnail   = 0x0201 // JPEGInterchangeFormat, the offset of a JPEG thumbnail
+	tagDNGVersion      = 0xC612
+	newSubFilePreview  = 1 // NewSubFileType bit of reduced-resolution images
+	compressionOldJPEG = 6
+	compressionJPEG    = 7
+)
+
+// dngVersion returns the DNGVersion of a file, and false for a plain TIFF
+func dngVersion(tags *exif.Data) (string, bool) {
+	if tags == nil {
+		return "", false
+	}
+	tag, ok := tags.IFD0[tagDNGVersion]
+	if !ok || len(tag.Value) < 4 {
+		return "", false
+	}
+	v := tag.Value
+	return fmt.Sprintf("%d.%d.%d.%d", v[0], v[1], v[2], v[3]), true
+}
+
+// jpegStrip returns the offset and size of the first IFD's image when it is
+// a single JPEG-compressed strip
+func jpegStrip(tags *exif.Data) (offset, size int, ok bool) {
+	compression, _ := tags.Uint(tags.IFD0[tagCompression], 0)
+	if compression != compressionOldJPEG && compression != compressionJPEG {
+		return 0, 0, false
+	}
+	offsets, counts := tags.IFD0[tagStripOffsets], tags.IFD0[tagStripByteCounts]
+	if offsets.Count != 1 || counts.Count != 1 {
+		return 0, 0, false
+	}
+	start, _ := tags.Uint(offsets, 0)
+	length, _ := tags.Uint(counts, 0)
+	return int(start), int(length), true
+}
+
+// knownJPEGs returns the offsets of the JPEGs the IFDs point to: a
+// JPEG-compressed image strip and the thumbnail. The carver finds them like
+// any embedded file.
+func knownJPEGs(tags *exif.Data) []int {
+	var offsets []int
+	if offset, _, ok := jpegStrip(tags); ok {
+		offsets = append(offsets, offset)
+	}
+	for _, ifd := range []map[uint16]exif.Tag{tags.IFD0, tags.IFD1} {
+		if tag, ok := ifd[tagJPEGThumbnail]; ok {
+			if offset, ok := tags.Uint(tag, 0); ok {
+				offsets = append(offsets, int(offset))
+			}
+		}
+	}
+	return offsets
+}
+
+// describeDNG records what of a DNG was analyzed: img, the decoded first IFD,
+// or only the tags when decodeErr says it did not decode
+func describeDNG(version string, tags *exif.Data, img image.Image, decodeErr error, result *models.AnalysisResult) {
+	result.FileType = "dng"
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	result.Details["dng_version"] = version
+
+	if decodeErr != nil {
+		details := fmt.Sprintf("The first IFD does not decode (%v), so only the tags and embedded files were analyzed", decodeErr)
+		if offset, size, ok := jpegStrip(tags); ok {
+			details = fmt.Sprintf("The first IFD is a JPEG preview (bytes %d-%d), which the pixel LSB analyses do not apply to, so only the tags and embedded files were analyzed", offset, offset+size)
+			result.Details["dng_jpeg_preview"] = map[string]int{"offset": offset, "size": size}
+			result.Recommendations = append(result.Recommendations,
+				fmt.Sprintf("Carve the JPEG preview (%d bytes at offset %d) and analyze it as a JPEG", size, offset))
+		}
+		result.AddFinding("DNG image data not analyzed", 0.1, details)
+		result.Recommendations = append(result.Recommendations,
+			"Convert the DNG to TIFF (dcraw -T or darktable-cli) and analyze the result to run the pixel analyses")
+		return
+	}
+
+	bounds := img.Bounds()
+	subfile, _ := tags.Uint(tags.IFD0[tagNewSubFileType], 0)
+	if subfile&newSubFilePreview == 0 {
+		result.Details["dng_analyzed_image"] = fmt.Sprintf("%dx%d main image", bounds.Dx(), bounds.Dy())
+		return
+	}
+	result.Details["dng_analyzed_image"] = fmt.Sprintf("%dx%d preview", bounds.Dx(), bounds.Dy())
+	result.Recommendations = append(result.Recommendations,
+		"The pixel analyses ran on the DNG's preview; convert the raw image to TIFF (dcraw -T) to analyze it at full resolution")
+}
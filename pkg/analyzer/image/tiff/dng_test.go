@@ -0,0 +1,67 @@
+package tiff
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+)
+
+func TestAnalyzeDNG(t *testing.T) {
+	tests := []struct {
+		kind     string
+		analyzed string // Details["dng_analyzed_image"], empty when the pixels are not analyzed
+	}{
+		{fixtures.DNGPreviewRGB, "64x48 preview"},
+		{fixtures.DNGPreviewJPEG, ""},
+		{fixtures.DNGRawCFA, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			data, err := fixtures.DNG(fixtures.Carrier(64, 48, 3), tt.kind)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "photo.dng")
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := NewTIFFAnalyzer().Analyze(path, analyzer.AnalysisOptions{Format: "dng"})
+			if err != nil {
+				t.Fatalf("DNG analysis failed: %v", err)
+			}
+			if result.FileType != "dng" || result.Details["dng_version"] != "1.4.0.0" {
+				t.Errorf("file type %q with DNG version %v, want dng 1.4.0.0", result.FileType, result.Details["dng_version"])
+			}
+
+			analyzed, _ := result.Details["dng_analyzed_image"].(string)
+			_, pixels := result.Details["width"]
+			notAnalyzed := false
+			for _, finding := range result.Findings {
+				notAnalyzed = notAnalyzed || finding.Description == "DNG image data not analyzed"
+				if strings.HasPrefix(finding.Description, "Embedded") {
+					t.Errorf("the DNG's own image data was reported: %s (%s)", finding.Description, finding.Details)
+				}
+			}
+			if tt.analyzed != "" {
+				if analyzed != tt.analyzed || !pixels || notAnalyzed {
+					t.Errorf("analyzed %q (pixel details %v), want the %s", analyzed, pixels, tt.analyzed)
+				}
+				return
+			}
+			if analyzed != "" || pixels || !notAnalyzed {
+				t.Errorf("analyzed %q (pixel details %v), want a finding that the image data was not analyzed", analyzed, pixels)
+			}
+			if !strings.Contains(strings.Join(result.Recommendations, "\n"), "Convert the DNG to TIFF") {
+				t.Errorf("no conversion recommendation in %q", result.Recommendations)
+			}
+			if _, ok := result.Details["dng_jpeg_preview"]; ok != (tt.kind == fixtures.DNGPreviewJPEG) {
+				t.Errorf("JPEG preview reported %v, want %v", ok, !ok)
+			}
+		})
+	}
+}
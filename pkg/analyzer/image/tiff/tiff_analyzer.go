@@ -0,0 +1,375 @@
+package tiff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/tiff"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/analyzer/carve"
+	"DeSteGo/pkg/analyzer/image/exif"
+	"DeSteGo/pkg/analyzer/image/lsb"
+	"DeSteGo/pkg/analyzer/whitespace"
+	"DeSteGo/pkg/models"
+)
+
+/*
+Summary of this file and these functions:
+- This file contains the TIFFAnalyzer, an ImageAnalyzer for TIFF images.
+- The Analyze method decodes the TIFF (strips and tiles are reassembled by the decoder),
+  runs the shared LSB distribution analysis and inspects the tags of the first IFD.
+- Private tags (IDs 32768 and up) that are not well-known, and oversized tags of any
+  kind, are reported because decoders ignore them and they can hold arbitrary data.
+- Files embedded inside or after the TIFF data are reported by the shared carver.
+- DNGs are TIFFs too: dng.go covers the previews and raw data they hold.
+*/
+
+// TIFF tag IDs the analyzer knows about
+const (
+	tagStripOffsets    = 0x0111
+	tagStripByteCounts = 0x0117
+	tagTileOffsets     = 0x0144
+	tagTileByteCounts  = 0x0145
+	tagXMP             = 0x02BC
+	tagIPTC            = 0x83BB
+	tagPhotoshop       = 0x8649
+	tagICCProfile      = 0x8773
+	firstPrivateTag    = 0x8000
+)
+
+// Tag size thresholds in bytes
+const (
+	privateTagLimit = 256       // Unknown private tags larger than this are reported
+	anyTagLimit     = 64 * 1024 // Other tags larger than this are reported
+)
+
+// knownLargeTags are tags that legitimately carry large values
+var knownLargeTags = map[uint16]string{
+	tagStripOffsets:        "StripOffsets",
+	tagStripByteCounts:     "StripByteCounts",
+	tagTileOffsets:         "TileOffsets",
+	tagTileByteCounts:      "TileByteCounts",
+	tagXMP:                 "XMP",
+	tagIPTC:                "IPTC",
+	tagPhotoshop:           "Photoshop",
+	tagICCProfile:          "ICC Profile",
+	exif.TagExifIFDPointer: "Exif IFD",
+	exif.TagGPSIFDPointer:  "GPS IFD",
+	0x9C9B:                 "XPTitle",
+	0x9C9C:                 "XPComment",
+	0x935C:                 "ImageSourceData",
+	0xC612:                 "DNGVersion",
+	0xC634:                 "DNGPrivateData",
+	0xC68C:                 "OriginalRawFileData",
+	0xC68F:                 "AsShotICCProfile",
+	0xC691:                 "CurrentICCProfile",
+	0xC6FA:                 "ProfileHueSatMapData1",
+	0xC6FB:                 "ProfileHueSatMapData2",
+	0xC6FC:                 "ProfileToneCurve",
+	0xC726:                 "ProfileLookTableData",
+	0xC740:                 "OpcodeList1",
+	0xC741:                 "OpcodeList2",
+	0xC742:                 "OpcodeList3",
+}
+
+// TIFFAnalyzer implements analysis for TIFF images
+type TIFFAnalyzer struct {
+	analyzer.BaseAnalyzer
+}
+
+// NewTIFFAnalyzer creates a new TIFF analyzer
+func NewTIFFAnalyzer() *TIFFAnalyzer {
+	return &TIFFAnalyzer{
+		BaseAnalyzer: analyzer.NewBaseAnalyzer(
+			"TIFF Analyzer",
+			"Analyzes TIFF images for steganography",
+			[]string{"tiff", "dng"},
+		),
+	}
+}
+
+// Analyze performs analysis on a TIFF file
+func (a *TIFFAnalyzer) Analyze(filePath string, options analyzer.AnalysisOptions) (*models.AnalysisResult, error) {
+	data, err := models.ReadFile(options.Source, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	// The tags tell a DNG, whose first IFD may not decode, from a plain TIFF
+	tags, tagErr := exif.Parse(data)
+	version, dng := dngVersion(tags)
+
+	// Decode the TIFF image
+	img, decodeErr := tiff.Decode(bytes.NewReader(data))
+
+	var result *models.AnalysisResult
+	switch {
+	case decodeErr == nil:
+		if result, err = a.AnalyzeImage(img, options); err != nil {
+			return nil, err
+		}
+	case dng:
+		result = &models.AnalysisResult{Findings: []models.Finding{}, Recommendations: []string{}}
+	default:
+		return nil, fmt.Errorf("failed to decode TIFF: %w", decodeErr)
+	}
+	result.Filename = filePath
+	if dng {
+		describeDNG(version, tags, img, decodeErr, result)
+	}
+
+	// Inspect the tags of the first IFD
+	if tagErr != nil {
+		result.AddFinding("TIFF tag analysis unavailable", 0.1, tagErr.Error())
+	} else {
+		before := len(result.Findings)
+		analyzeTags(tags, result)
+		if len(result.Findings) == before {
+			result.AddCheck("no private or oversized tags")
+		}
+
+		// Text tags can hide data in characters no viewer shows
+		before = len(result.Findings)
+		texts := tags.Texts()
+		for _, field := range texts {
+			whitespace.Analyze(field.Text, "TIFF "+field.Name, filePath, options, result)
+		}
+		if len(texts) > 0 && len(result.Findings) == before {
+			result.AddCheck(fmt.Sprintf("no invisible characters in %d text tags", len(texts)))
+		}
+	}
+
+	before := len(result.Findings)
+	var known []int
+	if tagErr == nil {
+		known = knownJPEGs(tags)
+	}
+	carve.AnalyzeEmbeddedFiles(data, "tiff", filePath, options, result, known...)
+	if len(result.Findings) == before {
+		result.AddCheck("no embedded or appended files")
+	}
+
+	result.SetCleanRationale()
+	return result, nil
+}
+
+// AnalyzeImage analyzes a decoded TIFF image
+func (a *TIFFAnalyzer) AnalyzeImage(img image.Image, options analyzer.AnalysisOptions) (*models.AnalysisResult, error) {
+	if img == nil {
+		return nil, errors.New("nil image provided")
+	}
+
+	result := &models.AnalysisResult{
+		FileType:        "tiff",
+		Findings:        []models.Finding{},
+		Recommendations: []string{},
+	}
+
+	bounds := img.Bounds()
+	result.Details = map[string]interface{}{
+		"width":    bounds.Dx(),
+		"height":   bounds.Dy(),
+		"capacity": lsb.EstimateCapacity(img),
+	}
+
+	// Run LSB analysis using the shared package
+	thresholds := options.Thresholds()
+	lsbResult, err := lsb.AnalyzeDistribution(img)
+	if err != nil {
+		return nil, fmt.Errorf("LSB analysis failed: %w", err)
+	}
+
+	result.DetectionScore = thresholds.LSBScore(lsbResult.AnomalyScore)
+	result.Confidence = lsbResult.Confidence
+	result.SetDetectorScore(analyzer.DetectorLSBDistribution, result.DetectionScore)
+
+	if lsbResult.AnomalyScore > thresholds.LSBAnomalyHigh {
+		result.AddFinding("Highly anomalous LSB distribution", 0.9,
+			fmt.Sprintf("Statistical anomaly score=%.4f (>%g is suspicious)", lsbResult.AnomalyScore, thresholds.LSBAnomalyHigh))
+		result.PossibleAlgorithm = "LSB Steganography"
+		result.Recommendations = append(result.Recommendations,
+			"Extract LSB data using specialized tools")
+	} else if lsbResult.AnomalyScore > thresholds.LSBAnomalyUnusual {
+		result.AddFinding("Unusual LSB distribution", 0.7,
+			fmt.Sprintf("Statistical anomaly score=%.4f (>%g is unusual)", lsbResult.AnomalyScore, thresholds.LSBAnomalyUnusual))
+		result.Recommendations = append(result.Recommendations,
+			"Run further analysis with specialized tools")
+	} else {
+		result.AddCheck(fmt.Sprintf("LSB distribution natural (anomaly score %.2f)", lsbResult.AnomalyScore))
+	}
+
+	// The same bits written into every channel make the LSB planes agree
+	planes := lsb.AnalyzePlaneCorrelation(img)
+	result.Details["lsb_plane_correlation"] = planes.Correlation
+	if planes.Correlation <= thresholds.PlaneCorrelation {
+		result.AddCheck(fmt.Sprintf("LSB planes independent (correlation %.2f)", planes.Correlation))
+		result.SetDetectorScore(analyzer.DetectorPlaneCorrelation, 0)
+	} else {
+		confidence := 0.6 + (planes.Correlation-0.8)*1.5
+		result.SetDetectorScore(analyzer.DetectorPlaneCorrelation, confidence)
+		result.AddFinding("R, G and B LSB planes are nearly identical", confidence, planes.Describe())
+		result.AddExtractionHint("lsb-sequential", confidence, map[string]interface{}{"channels": "any single channel"})
+		result.Recommendations = append(result.Recommendations,
+			"Extract the LSBs of a single channel (the same data is repeated in R, G and B)")
+		if result.DetectionScore < confidence {
+			result.DetectionScore = confidence
+			result.PossibleAlgorithm = "LSB Steganography"
+		}
+	}
+
+	// Each channel's value pairs tested on their own catch payloads written to
+	// only some channels, which the combined test dilutes
+	pov := lsb.AnalyzePairsOfValues(img)
+	result.Details["pov_p_values"] = map[string]float64{"R": pov.PValues[0], "G": pov.PValues[1], "B": pov.PValues[2]}
+	result.Details["pov_fractions"] = map[string]float64{"R": pov.Fractions[0], "G": pov.Fractions[1], "B": pov.Fractions[2]}
+	sequential, selective := pov.Sequential(), pov.Selective()
+	profile := lsb.ClassifyEmbedding(img)
+	result.Details["embedding_pattern"] = profile.Pattern
+	chiSquare := 0.0
+	switch {
+	case len(sequential) > 0:
+		result.AddFinding(fmt.Sprintf("%s channel LSB pairs equalized at the start of the image", strings.Join(sequential, "/")), 0.7, pov.Describe())
+		result.AddExtractionHint("lsb-sequential", 0.7, map[string]interface{}{"channels": strings.Join(sequential, "")})
+		chiSquare = 0.7
+		if result.DetectionScore < 0.7 {
+			result.DetectionScore = 0.7
+			result.PossibleAlgorithm = "LSB Steganography"
+		}
+	case len(selective) > 0:
+		result.AddFinding(fmt.Sprintf("LSB pairs equalized in the %s channel only", strings.Join(selective, "/")), 0.7, pov.Describe())
+		result.AddExtractionHint("lsb-rgb", 0.7, map[string]interface{}{"channels": strings.Join(selective, "")})
+		chiSquare = 0.7
+		if result.DetectionScore < 0.7 {
+			result.DetectionScore = 0.7
+			result.PossibleAlgorithm = "LSB Steganography"
+		}
+	case profile.Pattern == lsb.PatternFullImage:
+		// Every channel equalized over the whole image, which the per-channel
+		// tests read as neither sequential nor selective
+		result.AddFinding("LSB pairs equalized across the whole image", 0.6,
+			fmt.Sprintf("Full-capacity embedding over %.0f%% of pixels", profile.Fraction*100))
+		result.AddExtractionHint("lsb-rgb", 0.6, map[string]interface{}{"fraction": profile.Fraction})
+		chiSquare = 0.6
+		if result.DetectionScore < 0.6 {
+			result.DetectionScore = 0.6
+			result.PossibleAlgorithm = "LSB Steganography"
+		}
+	default:
+		result.AddCheck(fmt.Sprintf("no per-channel LSB pair equalization (p R %.2f, G %.2f, B %.2f)", pov.PValues[0], pov.PValues[1], pov.PValues[2]))
+	}
+	result.SetDetectorScore(analyzer.DetectorChiSquare, chiSquare)
+
+	// Tools that clear every LSB before embedding leave the image mostly even
+	parity := lsb.AnalyzeParity(img)
+	result.Details["even_ratio"] = map[string]float64{
+		"R": parity.EvenRatio[0],
+		"G": parity.EvenRatio[1],
+		"B": parity.EvenRatio[2],
+	}
+	result.Details["even_tail"] = parity.EvenTail
+	switch {
+	case parity.Quantized:
+		result.AddCheck(fmt.Sprintf("sample parity follows quantized channels (%.0f%% even in the least even channel)", parity.MinEvenRatio()*100))
+		result.SetDetectorScore(analyzer.DetectorParity, 0)
+	case !parity.Normalized(thresholds.ParityEvenRatio):
+		result.AddCheck(fmt.Sprintf("even and odd samples balanced (%.0f%% even in the least even channel)", parity.MinEvenRatio()*100))
+		result.SetDetectorScore(analyzer.DetectorParity, 0)
+	default:
+		confidence := 0.75
+		if parity.MinEvenRatio() > thresholds.ParityEvenRatio {
+			confidence = min(0.6+(parity.MinEvenRatio()-thresholds.ParityEvenRatio), 0.9)
+		}
+		result.SetDetectorScore(analyzer.DetectorParity, confidence)
+		result.AddFinding("Samples were normalized to even values", confidence, parity.Describe())
+		result.AddExtractionHint("lsb-sequential", confidence, map[string]interface{}{"even_tail": parity.EvenTail})
+		result.Recommendations = append(result.Recommendations,
+			"Extract the LSBs from the start of the image; the tool that set every sample even writes its payload there")
+		if result.DetectionScore < confidence {
+			result.DetectionScore = confidence
+			result.PossibleAlgorithm = "LSB Steganography"
+		}
+	}
+
+	// LSB embedding breaks the JPEG round trip of images saved from a JPEG
+	if diff, err := lsb.AnalyzeRecompression(img); err == nil && diff.Quality > 0 {
+		result.Details["recompress_quality"] = diff.Quality
+		result.Details["fragile_lsb_fraction"] = diff.FragileLSBs
+		if !diff.Suspicious {
+			result.AddCheck(fmt.Sprintf("JPEG round trip intact (quality %d, %.0f%% of blocks)", diff.Quality, diff.Compatible*100))
+		} else {
+			confidence := 0.7 + (diff.Intact-diff.Broken)*0.25
+			result.AddFinding("Pixels saved from a JPEG no longer survive re-encoding in part of the image", confidence, diff.Describe())
+			result.AddExtractionHint("lsb-sequential", confidence, nil)
+			result.Recommendations = append(result.Recommendations,
+				"Extract the LSBs of the region that does not round-trip through JPEG")
+			if result.DetectionScore < confidence {
+				result.DetectionScore = confidence
+				result.PossibleAlgorithm = "LSB Steganography"
+			}
+		}
+	}
+
+	// Pixel-value differencing hides data in edges, where the LSB tests miss it
+	pvdProbability, pvdDetails := (&lsb.PVDDetector{}).Detect(img)
+	result.Details["pvd_probability"] = pvdProbability
+	result.SetDetectorScore(analyzer.DetectorPVD, pvdProbability)
+	if pvdProbability <= thresholds.DetectorProbability {
+		result.AddCheck(fmt.Sprintf("no PVD histogram steps (%.2f)", pvdProbability))
+	} else {
+		result.AddFinding("Pixel difference histogram steps at PVD range boundaries", pvdProbability, pvdDetails)
+		result.Recommendations = append(result.Recommendations,
+			"Try extracting with a pixel-value differencing (PVD) tool")
+		if result.DetectionScore < pvdProbability {
+			result.DetectionScore = pvdProbability
+			result.PossibleAlgorithm = "PVD Steganography"
+		}
+	}
+
+	return result, nil
+}
+
+// analyzeTags reports private and oversized tags in the image IFD
+func analyzeTags(tags *exif.Data, result *models.AnalysisResult) {
+	ids := make([]int, 0, len(tags.IFD0))
+	for id := range tags.IFD0 {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	if tag, ok := tags.IFD0[tagStripOffsets]; ok {
+		result.Details["strips"] = tag.Count
+	}
+	if tag, ok := tags.IFD0[tagTileOffsets]; ok {
+		result.Details["tiles"] = tag.Count
+	}
+
+	for _, id := range ids {
+		tag := tags.IFD0[uint16(id)]
+		size := len(tag.Value)
+		if _, known := knownLargeTags[tag.ID]; known {
+			continue
+		}
+
+		switch {
+		case tag.ID >= firstPrivateTag && size > privateTagLimit:
+			result.AddFinding(fmt.Sprintf("Unknown private TIFF tag 0x%04X holds %d bytes", tag.ID, size), 0.7,
+				"Private tags are ignored by image viewers and can carry arbitrary data")
+		case size > anyTagLimit:
+			result.AddFinding(fmt.Sprintf("Oversized TIFF tag 0x%04X holds %d bytes", tag.ID, size), 0.6,
+				"Unusually large tag value")
+		default:
+			continue
+		}
+
+		if result.DetectionScore < 0.7 {
+			result.DetectionScore = 0.7
+		}
+		result.Recommendations = append(result.Recommendations,
+			fmt.Sprintf("Dump the value of TIFF tag 0x%04X and inspect it", tag.ID))
+	}
+}
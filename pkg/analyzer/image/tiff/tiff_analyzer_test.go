@@ -0,0 +1,87 @@
+package tiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/tiff"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+)
+
+func TestAnalyzeTIFF(t *testing.T) {
+	tests := []struct {
+		name       string
+		payload    int    // LSB payload bytes written to R, G and B
+		privateTag int    // Size of an unknown private tag added to the IFD, 0 for none
+		want       string // Finding prefix, empty for a clean result
+	}{
+		{"clean", 0, 0, ""},
+		{"LSB payload", 128 * 128 * 3 / 8, 0, "LSB pairs equalized"},
+		{"private tag", 0, 4096, "Unknown private TIFF tag 0xABCD holds 4096 bytes"},
+		{"small private tag", 0, 64, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := fixtures.Carrier(128, 128, 5)
+			if tt.payload > 0 {
+				if err := fixtures.EmbedLSB(img, fixtures.RandomPayload(tt.payload, 5), []int{0, 1, 2}, 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var buf bytes.Buffer
+			if err := tiff.Encode(&buf, img, nil); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+
+			if tt.privateTag > 0 {
+				// Copy the IFD to the end with the private tag added, its value after it
+				le := binary.LittleEndian
+				ifd := le.Uint32(data[4:])
+				count := int(le.Uint16(data[ifd:]))
+				entries := data[ifd+2 : int(ifd)+2+count*12]
+				start := uint32(len(data))
+				if start%2 == 1 {
+					data, start = append(data, 0), start+1
+				}
+				out := le.AppendUint16(data, uint16(count+1))
+				out = append(out, entries...)
+				out = le.AppendUint16(out, 0xABCD)
+				out = le.AppendUint16(out, 7)
+				out = le.AppendUint32(out, uint32(tt.privateTag))
+				out = le.AppendUint32(out, start+2+uint32(count+1)*12+4)
+				out = le.AppendUint32(out, 0)
+				data = append(out, fixtures.RandomPayload(tt.privateTag, 6)...)
+				le.PutUint32(data[4:], start)
+			}
+
+			path := filepath.Join(t.TempDir(), "image.tiff")
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			result, err := NewTIFFAnalyzer().Analyze(path, analyzer.AnalysisOptions{Format: "tiff"})
+			if err != nil {
+				t.Fatalf("TIFF analysis failed: %v", err)
+			}
+
+			var found []string
+			matched := false
+			for _, finding := range result.Findings {
+				found = append(found, finding.Description)
+				matched = matched || (tt.want != "" && strings.HasPrefix(finding.Description, tt.want))
+			}
+			if tt.want == "" && len(found) > 0 {
+				t.Errorf("got findings %q, want none", found)
+			}
+			if tt.want != "" && !matched {
+				t.Errorf("got findings %q, want %q", found, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/models"
+	"DeSteGo/pkg/toolexec"
+)
+
+/*
+This file contains external analyzer plugins. A plugin is an executable in the
+plugin directory that speaks a small JSON protocol, so detectors can be written
+in any language and added without recompiling DeSteGo:
+
+	<plugin> describe
+	    prints {"name": ..., "description": ..., "formats": [...]}
+	<plugin> analyze <file>
+	    reads the analysis options as JSON on stdin and prints an
+	    AnalysisResult as JSON on stdout
+
+A plugin reports failure with a non-zero exit status and a message on stderr.
+Subprocesses were chosen over Go's plugin package, which needs cgo and plugins
+built with the exact same toolchain and dependencies as the binary loading them.
+Each plugin is wrapped in an analyzer that implements analyzer.FileAnalyzer and
+is registered alongside the built-in analyzers.
+*/
+
+// Plugin timeouts
+const (
+	DescribeTimeout = 10 * time.Second // Time a plugin may take to describe itself
+	AnalyzeTimeout  = 5 * time.Minute  // Default time a plugin may take to analyze a file
+)
+
+// Description is what a plugin prints for "describe"
+type Description struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Formats     []string `json:"formats"`
+}
+
+// Request is the analysis options a plugin reads on stdin for "analyze"
+type Request struct {
+	File      string `json:"file"`
+	Format    string `json:"format"`
+	Verbose   bool   `json:"verbose"`
+	Extract   bool   `json:"extract"`
+	OutputDir string `json:"outputDir,omitempty"`
+}
+
+// Analyzer runs an external plugin as an analyzer
+type Analyzer struct {
+	analyzer.BaseAnalyzer
+	path    string
+	Timeout time.Duration // Time limit for one analysis (default: AnalyzeTimeout)
+}
+
+// NewAnalyzer asks the plugin at path to describe itself and returns an
+// analyzer for it
+func NewAnalyzer(path string) (*Analyzer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DescribeTimeout)
+	defer cancel()
+
+	out, err := run(ctx, path, nil, "describe")
+	if err != nil {
+		return nil, err
+	}
+	var desc Description
+	if err := json.Unmarshal(out, &desc); err != nil {
+		return nil, fmt.Errorf("failed to parse description of plugin %s: %w", filepath.Base(path), err)
+	}
+	if desc.Name == "" || len(desc.Formats) == 0 {
+		return nil, fmt.Errorf("plugin %s describes no name or formats", filepath.Base(path))
+	}
+	formats := make([]string, len(desc.Formats))
+	for i, f := range desc.Formats {
+		formats[i] = strings.ToLower(f)
+	}
+
+	return &Analyzer{
+		BaseAnalyzer: analyzer.NewBaseAnalyzer(desc.Name, desc.Description, formats),
+		path:         path,
+		Timeout:      AnalyzeTimeout,
+	}, nil
+}
+
+// Path returns the plugin executable
+func (a *Analyzer) Path() string {
+	return a.path
+}
+
+// Analyze runs the plugin on a file
+func (a *Analyzer) Analyze(filePath string, options analyzer.AnalysisOptions) (*models.AnalysisResult, error) {
+	return a.AnalyzeContext(context.Background(), filePath, options)
+}
+
+// AnalyzeContext runs the plugin on a file, killing it when ctx is canceled or
+// the timeout runs out
+func (a *Analyzer) AnalyzeContext(ctx context.Context, filePath string, options analyzer.AnalysisOptions) (*models.AnalysisResult, error) {
+	timeout := a.Timeout
+	if timeout <= 0 {
+		timeout = AnalyzeTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	request, err := json.Marshal(Request{
+		File:      filePath,
+		Format:    options.Format,
+		Verbose:   options.Verbose,
+		Extract:   options.Extract,
+		OutputDir: options.OutputDir,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode plugin request: %w", err)
+	}
+	out, err := run(ctx, a.path, request, "analyze", filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var result models.AnalysisResult
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result of plugin %s: %w", a.Name(), err)
+	}
+	if result.Filename == "" {
+		result.Filename = filePath
+	}
+	if result.FileType == "" {
+		result.FileType = options.Format
+	}
+	// Plugins that only give a confidence get the matching severity
+	for i := range result.Findings {
+		if result.Findings[i].Severity == models.SeverityClean {
+			result.Findings[i].Severity = models.SeverityFromScore(result.Findings[i].Confidence)
+		}
+	}
+	return &result, nil
+}
+
+// Load returns an analyzer for every executable in dir, in name order. Plugins
+// that fail to describe themselves are skipped and reported in the error.
+func Load(dir string) ([]*Analyzer, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var analyzers []*Analyzer
+	var errs []error
+	for _, entry := range entries {
+		// Stat follows symlinks, so linked plugins are loaded too
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		a, err := NewAnalyzer(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		analyzers = append(analyzers, a)
+	}
+	return analyzers, errors.Join(errs...)
+}
+
+// run runs a plugin with the given stdin and returns its stdout, or an error
+// carrying the first line of its stderr
+func run(ctx context.Context, path string, stdin []byte, args ...string) ([]byte, error) {
+	out, err := toolexec.Run(ctx, 0, stdin, path, args...)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("plugin %s timed out: %w", filepath.Base(path), ctx.Err())
+		}
+		if message := out.Message(); message != "" {
+			return nil, fmt.Errorf("plugin %w: %s", err, message)
+		}
+		return nil, fmt.Errorf("plugin %w", err)
+	}
+	return out.Stdout, nil
+}
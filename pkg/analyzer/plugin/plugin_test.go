@@ -0,0 +1,149 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+)
+
+// writeScript writes an executable shell script plugin into dir
+func writeScript(t *testing.T, dir, name, script string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins need a Unix shell")
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the example plugin")
+	}
+	dir := t.TempDir()
+	build := exec.Command("go", "build", "-o", filepath.Join(dir, "giftrailer"), "DeSteGo/examples/plugins/giftrailer")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("failed to build the example plugin: %v\n%s", err, out)
+	}
+	writeScript(t, dir, "broken", "echo 'not json'\n")
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("not a plugin"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plugins, err := Load(dir)
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("got error %v, want one for the broken plugin", err)
+	}
+	if len(plugins) != 1 || plugins[0].Name() != "GIF Trailer Plugin" {
+		t.Fatalf("loaded %v, want only the GIF trailer plugin", plugins)
+	}
+
+	registry := analyzer.NewRegistry()
+	for _, p := range plugins {
+		registry.Register(p)
+	}
+	if formats := registry.GetSupportedFormats(); !slices.Contains(formats, "gif") {
+		t.Errorf("supported formats %v do not include gif", formats)
+	}
+
+	tests := []struct {
+		name     string
+		findings int
+	}{
+		{"appended_zip.gif", 1},
+		{"clean.gif", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := registry.GetAnalyzersForFormat("gif")[0]
+			result, err := a.Analyze(fixtures.Path(tt.name), analyzer.AnalysisOptions{Format: "gif"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Findings) != tt.findings {
+				t.Errorf("got findings %+v, want %d", result.Findings, tt.findings)
+			}
+			if result.Filename != fixtures.Path(tt.name) || result.FileType != "gif" {
+				t.Errorf("got file %s of type %s", result.Filename, result.FileType)
+			}
+		})
+	}
+}
+
+func TestAnalyzeResult(t *testing.T) {
+	dir := t.TempDir()
+	path := writeScript(t, dir, "echo", `case "$1" in
+describe) echo '{"name": "Echo", "formats": ["PNG"]}' ;;
+analyze) cat >/dev/null; echo '{"detectionScore": 0.7, "findings": [{"description": "marker", "confidence": 0.9}]}' ;;
+esac
+`)
+	a, err := NewAnalyzer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.CanAnalyze("png") {
+		t.Error("format names are not lower-cased")
+	}
+	result, err := a.Analyze("image.png", analyzer.AnalysisOptions{Format: "png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.DetectionScore != 0.7 || len(result.Findings) != 1 || result.Findings[0].Severity.String() != "high" {
+		t.Errorf("got %+v, want score 0.7 and one high finding", result)
+	}
+}
+
+func TestAnalyzeStops(t *testing.T) {
+	dir := t.TempDir()
+	path := writeScript(t, dir, "slow", `case "$1" in
+describe) echo '{"name": "Slow", "formats": ["png"]}' ;;
+analyze) exec sleep 30 ;;
+esac
+`)
+	a, err := NewAnalyzer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		a.Timeout = 200 * time.Millisecond
+		start := time.Now()
+		_, err := a.Analyze("image.png", analyzer.AnalysisOptions{})
+		if err == nil || !strings.Contains(err.Error(), "timed out") {
+			t.Errorf("got error %v, want a timeout", err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("took %s to time out", elapsed)
+		}
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		a.Timeout = 0
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			cancel()
+		}()
+		start := time.Now()
+		_, err := analyzer.AnalyzeWithContext(ctx, a, "image.png", analyzer.AnalysisOptions{})
+		if err == nil || !strings.Contains(err.Error(), "stopped") {
+			t.Errorf("got error %v, want the plugin stopped", err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("took %s to stop", elapsed)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package raw
+
+import (
+	"fmt"
+	"strings"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/analyzer/carve"
+	"DeSteGo/pkg/analyzer/image/jpeg"
+	"DeSteGo/pkg/analyzer/image/png"
+	"DeSteGo/pkg/analyzer/stats"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the raw bytes analyzer, the fallback for files the format
+analyzers cannot decode. A carrier that is truncated or corrupted part way
+through still holds whatever was prepended, embedded or appended to it, and its
+headers still hold their metadata, so the analyses that work on bytes rather
+than pixels are run on it: the carver, the data after the image's end marker,
+the JPEG marker segments or PNG chunks that can be read, and a scan of the
+image data for plaintext.
+*/
+
+// Plaintext scan parameters
+const (
+	minTextRun   = 32 // Printable bytes in a row that count as text
+	maxTextRuns  = 5  // Runs listed in the finding
+	textRunLimit = 80 // Characters of each run shown
+)
+
+// carveTypes maps format names to the carver's type names where they differ
+var carveTypes = map[string]string{"jpeg": "jpg"}
+
+// RawAnalyzer runs the byte-level analyses of a file that may not decode
+type RawAnalyzer struct {
+	analyzer.BaseAnalyzer
+}
+
+// NewRawAnalyzer creates a new raw bytes analyzer
+func NewRawAnalyzer() *RawAnalyzer {
+	return &RawAnalyzer{
+		BaseAnalyzer: analyzer.NewBaseAnalyzer(
+			"Raw Bytes Analyzer",
+			"Analyzes the bytes of files that cannot be decoded: prepended, embedded and appended data, metadata and plaintext",
+			[]string{"png", "jpeg", "jpg", "tiff"},
+		),
+	}
+}
+
+// Analyze runs the byte-level analyses on a file of the format given in options
+func (a *RawAnalyzer) Analyze(filePath string, options analyzer.AnalysisOptions) (*models.AnalysisResult, error) {
+	data, err := models.ReadFile(options.Source, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	hostType := options.Format
+	if t, ok := carveTypes[hostType]; ok {
+		hostType = t
+	}
+	result := &models.AnalysisResult{
+		FileType:        options.Format,
+		Filename:        filePath,
+		Findings:        []models.Finding{},
+		Recommendations: []string{},
+		Details:         map[string]interface{}{"raw_analysis": true},
+	}
+
+	// Prepended, embedded and appended files
+	before := len(result.Findings)
+	prefix := carve.FindPrefix(data, hostType)
+	start := 0
+	if prefix != nil {
+		start = prefix.Offset
+	}
+	carve.AnalyzePrefix(data, prefix, filePath, options, result)
+	carve.AnalyzeEmbeddedFiles(data, hostType, filePath, options, result)
+	analyzeTrailer(data[start:], result)
+	if len(result.Findings) == before {
+		result.AddCheck("no prepended, embedded or appended files")
+	}
+
+	// The headers before the image data, and where the image data starts
+	body := 0
+	switch hostType {
+	case "jpg":
+		if meta := jpeg.AnalyzeMetadata(data[start:], filePath, options, result); meta != nil && meta.ScanOffset > 0 {
+			body = start + meta.ScanOffset
+		}
+	case "png":
+		body = start + analyzeChunks(data[start:], result)
+	}
+
+	before = len(result.Findings)
+	analyzePlaintext(data[body:], body, result)
+	if len(result.Findings) == before {
+		result.AddCheck("no plaintext in the image data")
+	}
+
+	result.SetCleanRationale()
+	return result, nil
+}
+
+// analyzeTrailer reports data after the end marker of a PNG or JPEG stream.
+// Without an end marker the carver's embedded file and the plaintext scan are
+// what is left to find appended data.
+func analyzeTrailer(data []byte, result *models.AnalysisResult) {
+	end := carve.ImageEnd(data)
+	if end < 0 {
+		return
+	}
+	result.Details["image_end"] = end
+	if end >= len(data) {
+		return
+	}
+	result.AddFinding("Found appended data after the end of the image", 0.8,
+		fmt.Sprintf("%d bytes follow the end marker at offset %d", len(data)-end, end))
+	if result.DetectionScore < 0.7 {
+		result.DetectionScore = 0.7
+	}
+	if result.Confidence < 0.8 {
+		result.Confidence = 0.8
+	}
+	result.Recommendations = append(result.Recommendations,
+		"Extract and analyze the data after the end of the image")
+}
+
+// analyzeChunks records the PNG chunks that can be read and returns the offset
+// of the first IDAT chunk, or 0 when there is none
+func analyzeChunks(data []byte, result *models.AnalysisResult) int {
+	chunks, err := png.ReadChunks(data)
+	result.Details["chunks_read"] = len(chunks)
+	if err != nil {
+		result.AddFinding("Malformed PNG chunk structure", 0.3, err.Error())
+	}
+	for _, chunk := range chunks {
+		if chunk.Type == "IDAT" {
+			return chunk.Offset
+		}
+	}
+	return 0
+}
+
+// analyzePlaintext reports runs of printable text in data, which starts at
+// offset in the file. Compressed and entropy-coded image data has no long
+// printable runs, so text there was put there.
+func analyzePlaintext(data []byte, offset int, result *models.AnalysisResult) {
+	var runs []string
+	total, count := 0, 0
+	runStart := -1
+	for i := 0; i <= len(data); i++ {
+		if i < len(data) && stats.IsPrintable(data[i]) {
+			if runStart < 0 {
+				runStart = i
+			}
+			continue
+		}
+		if runStart >= 0 && i-runStart >= minTextRun {
+			count++
+			total += i - runStart
+			if len(runs) < maxTextRuns {
+				text := strings.Join(strings.Fields(string(data[runStart:min(i, runStart+textRunLimit)])), " ")
+				runs = append(runs, fmt.Sprintf("offset %d (%d bytes): %q", offset+runStart, i-runStart, text))
+			}
+		}
+		runStart = -1
+	}
+	if count == 0 {
+		return
+	}
+
+	result.Details["plaintext_runs"] = count
+	result.AddFinding("Plaintext found in the image data", 0.6,
+		fmt.Sprintf("%d runs, %d bytes: %s", count, total, strings.Join(runs, "; ")))
+	if result.DetectionScore < 0.6 {
+		result.DetectionScore = 0.6
+	}
+	result.Recommendations = append(result.Recommendations,
+		"Inspect the plaintext in the image data")
+}
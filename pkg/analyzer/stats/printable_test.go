@@ -0,0 +1,24 @@
+package stats
+
+import "testing"
+
+func TestPrintableRatio(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want float64
+	}{
+		{"empty", nil, 0},
+		{"text", []byte("Hello, world!"), 1},
+		{"text with whitespace", []byte("line one\r\n\tline two\n"), 1},
+		{"control bytes", []byte{0x00, 0x07, 0x1B, 0x7F}, 0},
+		{"high bytes", []byte{'o', 'k', 0x80, 0xFF}, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PrintableRatio(tt.data); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
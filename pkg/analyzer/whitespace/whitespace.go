@@ -0,0 +1,311 @@
+package whitespace
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/analyzer/stats"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the invisible-character analysis of text fields: SVG
+documents, JPEG comments, EXIF strings and PNG text chunks. Text can carry data
+in characters no viewer shows. Zero-width characters (U+200B, U+200C, U+200D
+and U+FEFF) are read as bits, one character per bit, with a third character
+separating the bytes when there is one, or as two bits each when all four are
+used. Spaces and tabs at the ends of lines are read as bits the same way. Both
+assignments of 0 and 1 are tried, and a reading counts as hidden text only when
+it decodes to printable text. Zero-width characters are reported even when
+they do not decode, since metadata has no use for them; trailing whitespace is
+not, since editors leave it everywhere.
+*/
+
+// Invisible character parameters
+const (
+	minZeroWidth   = 8   // Zero-width characters a field needs before they are reported undecoded
+	minHiddenText  = 4   // Decoded bytes a reading needs to count as hidden text
+	minPrintable   = 0.9 // Printable share of a decoded reading that counts as text
+	maxTextPreview = 80  // Characters of decoded text shown in a finding
+)
+
+// zeroWidth lists the zero-width characters in the order their two-bit values
+// are assigned
+var zeroWidth = []rune{'\u200B', '\u200C', '\u200D', '\uFEFF'}
+
+// Hidden is data found in the invisible characters of a text field
+type Hidden struct {
+	Carrier string // "zero-width characters" or "trailing whitespace"
+	Symbols int    // Invisible characters found
+	Mapping string // How the characters were read, empty when they did not decode
+	Decoded []byte // Decoded text, nil when no reading decodes to text
+}
+
+// Scan returns what the zero-width characters and the trailing whitespace of
+// text hide. A carrier is left out when it has no characters to read, or, for
+// trailing whitespace, when they do not decode to text.
+func Scan(text string) []Hidden {
+	var found []Hidden
+	if zw := scanZeroWidth(text); zw != nil {
+		found = append(found, *zw)
+	}
+	if ws := scanTrailing(text); ws != nil {
+		found = append(found, *ws)
+	}
+	return found
+}
+
+// scanZeroWidth reads the zero-width characters of text. A byte order mark at
+// the very start is part of the encoding, not of the text, and is skipped.
+func scanZeroWidth(text string) *Hidden {
+	text = strings.TrimPrefix(text, "\uFEFF")
+	var symbols []rune
+	for _, r := range text {
+		for _, z := range zeroWidth {
+			if r == z {
+				symbols = append(symbols, r)
+			}
+		}
+	}
+	if len(symbols) == 0 {
+		return nil
+	}
+
+	hidden := &Hidden{Carrier: "zero-width characters", Symbols: len(symbols)}
+	used := distinctRunes(symbols)
+	var readings []reading
+	switch len(used) {
+	case 2:
+		readings = binaryReadings(symbols, used[0], used[1], 0)
+	case 3:
+		// Two characters for the bits and one between the bytes
+		for _, sep := range used {
+			var bits []rune
+			for _, r := range used {
+				if r != sep {
+					bits = append(bits, r)
+				}
+			}
+			readings = append(readings, binaryReadings(symbols, bits[0], bits[1], sep)...)
+		}
+	case 4:
+		readings = append(readings, reading{mapping: "two bits per character, U+200B=00 to U+FEFF=11", data: quaternary(symbols)})
+	}
+	hidden.Mapping, hidden.Decoded = bestReading(readings)
+	if hidden.Decoded == nil && len(symbols) < minZeroWidth {
+		return nil
+	}
+	return hidden
+}
+
+// scanTrailing reads the spaces and tabs at the ends of the lines of text
+func scanTrailing(text string) *Hidden {
+	var symbols []rune
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimRight(line, " \t")
+		symbols = append(symbols, []rune(line[len(trimmed):])...)
+	}
+	if len(symbols) < 8*minHiddenText {
+		return nil
+	}
+	mapping, decoded := bestReading(binaryReadings(symbols, ' ', '\t', 0))
+	if decoded == nil {
+		return nil
+	}
+	return &Hidden{Carrier: "trailing whitespace", Symbols: len(symbols), Mapping: mapping, Decoded: decoded}
+}
+
+// reading is one way of turning invisible characters into bytes
+type reading struct {
+	mapping string
+	data    []byte
+}
+
+// binaryReadings reads symbols as bits with a as 0 and b as 1 and the other way
+// round. With a separator, each run between separators is one byte; without,
+// every eight bits are, most significant bit first.
+func binaryReadings(symbols []rune, a, b, sep rune) []reading {
+	var readings []reading
+	for _, zero := range []rune{a, b} {
+		one := a
+		if zero == a {
+			one = b
+		}
+		mapping := fmt.Sprintf("%s=0 %s=1", runeName(zero), runeName(one))
+		var data []byte
+		if sep != 0 {
+			mapping += fmt.Sprintf(", %s between bytes", runeName(sep))
+			var value, bits int
+			flush := func() {
+				if bits > 0 && value < 256 {
+					data = append(data, byte(value))
+				}
+				value, bits = 0, 0
+			}
+			for _, r := range symbols {
+				switch r {
+				case sep:
+					flush()
+				case zero, one:
+					value, bits = value<<1, bits+1
+					if r == one {
+						value |= 1
+					}
+				}
+			}
+			flush()
+		} else {
+			var bits []byte
+			for _, r := range symbols {
+				if r == zero || r == one {
+					bits = append(bits, boolBit(r == one))
+				}
+			}
+			data = packBits(bits)
+		}
+		readings = append(readings, reading{mapping: mapping, data: data})
+	}
+	return readings
+}
+
+// quaternary reads each zero-width character as two bits, by its position in zeroWidth
+func quaternary(symbols []rune) []byte {
+	var bits []byte
+	for _, r := range symbols {
+		for i, z := range zeroWidth {
+			if r == z {
+				bits = append(bits, byte(i>>1), byte(i&1))
+			}
+		}
+	}
+	return packBits(bits)
+}
+
+// bestReading returns the reading that decodes to the most printable text, or
+// an empty mapping and nil when none decodes to text
+func bestReading(readings []reading) (string, []byte) {
+	best, bestRatio := -1, minPrintable
+	for i, r := range readings {
+		if len(r.data) < minHiddenText || !utf8.Valid(r.data) {
+			continue
+		}
+		if ratio := stats.PrintableRatio(r.data); ratio >= bestRatio {
+			best, bestRatio = i, ratio
+		}
+	}
+	if best < 0 {
+		return "", nil
+	}
+	return readings[best].mapping, readings[best].data
+}
+
+// packBits packs bits into bytes, most significant bit first, dropping a final
+// partial byte
+func packBits(bits []byte) []byte {
+	data := make([]byte, len(bits)/8)
+	for i := range data {
+		for j := 0; j < 8; j++ {
+			data[i] = data[i]<<1 | bits[i*8+j]
+		}
+	}
+	return data
+}
+
+// boolBit returns 1 for true and 0 for false
+func boolBit(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// distinctRunes returns the distinct runes in ascending order
+func distinctRunes(runes []rune) []rune {
+	seen := map[rune]bool{}
+	var out []rune
+	for _, r := range runes {
+		if !seen[r] {
+			seen[r] = true
+			out = append(out, r)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// runeName returns how a character is named in a mapping
+func runeName(r rune) string {
+	switch r {
+	case ' ':
+		return "space"
+	case '\t':
+		return "tab"
+	}
+	return fmt.Sprintf("U+%04X", r)
+}
+
+// Analyze reports the invisible characters of a text field. source names the
+// field in findings, such as "PNG tEXt chunk Comment". With extraction enabled,
+// decoded text is written to the output directory.
+func Analyze(text string, source, filePath string, options analyzer.AnalysisOptions, result *models.AnalysisResult) {
+	for _, hidden := range Scan(text) {
+		if hidden.Decoded == nil {
+			result.AddFinding(fmt.Sprintf("%s contains zero-width characters", source), 0.5,
+				fmt.Sprintf("%d zero-width characters that do not decode to text", hidden.Symbols))
+			if result.DetectionScore < 0.5 {
+				result.DetectionScore = 0.5
+			}
+			continue
+		}
+
+		result.AddFinding(fmt.Sprintf("%s hides text in %s", source, hidden.Carrier), 0.85,
+			fmt.Sprintf("%d characters read as %s decode to %d bytes: %q", hidden.Symbols, hidden.Mapping, len(hidden.Decoded), preview(hidden.Decoded)))
+		if result.DetectionScore < 0.85 {
+			result.DetectionScore = 0.85
+			result.PossibleAlgorithm = "Whitespace Steganography"
+		}
+		result.Recommendations = append(result.Recommendations,
+			fmt.Sprintf("Read the text hidden in the %s of the %s", hidden.Carrier, source))
+
+		if !options.Extract || options.OutputDir == "" {
+			continue
+		}
+		base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+		name := fileName(source + " " + hidden.Carrier)
+		outPath := filepath.Join(options.OutputDir, fmt.Sprintf("%s_%s.txt", base, name))
+		if outPath, err := filehandler.SaveFileUnique(hidden.Decoded, outPath); err == nil {
+			if result.Details == nil {
+				result.Details = map[string]interface{}{}
+			}
+			files, _ := result.Details["whitespace_files"].([]string)
+			result.Details["whitespace_files"] = append(files, outPath)
+		}
+	}
+}
+
+// fileName turns a description into a lower-case file name part
+func fileName(description string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '_'
+	}, description)
+}
+
+// preview returns the start of decoded text for a finding
+func preview(data []byte) string {
+	if len(data) > maxTextPreview {
+		return string(data[:maxTextPreview]) + "..."
+	}
+	return string(data)
+}
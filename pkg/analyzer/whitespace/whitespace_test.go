@@ -0,0 +1,135 @@
+package whitespace
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/analyzer"
+	"DeSteGo/pkg/models"
+)
+
+// encodeBits writes the bits of text, most significant first, as zero and one,
+// with sep after each byte when it is not 0
+func encodeBits(text string, zero, one, sep rune) string {
+	var b strings.Builder
+	for _, c := range []byte(text) {
+		for i := 7; i >= 0; i-- {
+			if c>>i&1 == 1 {
+				b.WriteRune(one)
+			} else {
+				b.WriteRune(zero)
+			}
+		}
+		if sep != 0 {
+			b.WriteRune(sep)
+		}
+	}
+	return b.String()
+}
+
+// encodeTrailing hides text in the spaces (0) and tabs (1) at the ends of lines,
+// one byte per line
+func encodeTrailing(text string) string {
+	var lines []string
+	for _, bits := range strings.Split(encodeBits(text, ' ', '\t', '\n'), "\n") {
+		lines = append(lines, "<line/>"+bits)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// encodeQuaternary writes text with two bits per zero-width character
+func encodeQuaternary(text string) string {
+	var b strings.Builder
+	for _, c := range []byte(text) {
+		for shift := 6; shift >= 0; shift -= 2 {
+			b.WriteRune(zeroWidth[c>>shift&3])
+		}
+	}
+	return b.String()
+}
+
+func TestScan(t *testing.T) {
+	const message = "meet at the docks"
+	tests := []struct {
+		name    string
+		text    string
+		carrier string // Carrier expected, empty for nothing found
+		decoded string // Text expected, empty when it should not decode
+	}{
+		{"separated bytes", "Nice photo" + fixtures.ZeroWidth(message), "zero-width characters", message},
+		{"interleaved with text", interleave("Nice photo of the harbour at dusk", fixtures.ZeroWidth(message)), "zero-width characters", message},
+		{"two characters", "Cover" + encodeBits(message, '\u200B', '\u200C', 0), "zero-width characters", message},
+		{"inverted bits", "Cover" + encodeBits(message, '\u200C', '\u200B', 0), "zero-width characters", message},
+		{"joiner and BOM", "Cover" + encodeBits(message, '\u200D', '\uFEFF', '\u200B'), "zero-width characters", message},
+		{"two bits per character", "Cover" + encodeQuaternary(message), "zero-width characters", message},
+		{"trailing whitespace", encodeTrailing(message), "trailing whitespace", message},
+		{"undecodable zero-width", "Cover" + strings.Repeat("\u200B\u200C\u200C", 6), "zero-width characters", ""},
+		{"few zero-width", "Cover\u200B\u200Cstory", "", ""},
+		{"leading BOM", "\uFEFF<svg></svg>", "", ""},
+		{"uniform trailing spaces", strings.Repeat("<g/>    \n", 40), "", ""},
+		{"plain text", "Shot on film, no edits", "", ""},
+		{"empty", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found := Scan(tt.text)
+			if tt.carrier == "" {
+				if len(found) != 0 {
+					t.Errorf("found %+v in a clean field", found)
+				}
+				return
+			}
+			if len(found) != 1 || found[0].Carrier != tt.carrier {
+				t.Fatalf("found %+v, want data in %s", found, tt.carrier)
+			}
+			if string(found[0].Decoded) != tt.decoded {
+				t.Errorf("decoded %q with %q, want %q", found[0].Decoded, found[0].Mapping, tt.decoded)
+			}
+		})
+	}
+}
+
+// interleave spreads the characters of hidden between the characters of cover
+func interleave(cover, hidden string) string {
+	c, h := []rune(cover), []rune(hidden)
+	var b strings.Builder
+	for i := 0; i < max(len(c), len(h)); i++ {
+		if i < len(c) {
+			b.WriteRune(c[i])
+		}
+		if i < len(h) {
+			b.WriteRune(h[i])
+		}
+	}
+	return b.String()
+}
+
+func TestAnalyze(t *testing.T) {
+	dir := t.TempDir()
+	result := &models.AnalysisResult{}
+	options := analyzer.AnalysisOptions{Extract: true, OutputDir: dir}
+	Analyze("Cover"+fixtures.ZeroWidth("exfil via dns"), "PNG iTXt chunk Comment", "/images/photo.png", options, result)
+
+	if len(result.Findings) != 1 || result.Findings[0].Description != "PNG iTXt chunk Comment hides text in zero-width characters" {
+		t.Fatalf("got findings %+v", result.Findings)
+	}
+	if result.DetectionScore != 0.85 || result.PossibleAlgorithm != "Whitespace Steganography" {
+		t.Errorf("got score %v, algorithm %q", result.DetectionScore, result.PossibleAlgorithm)
+	}
+	files, _ := result.Details["whitespace_files"].([]string)
+	if len(files) != 1 || !strings.HasSuffix(files[0], "photo_png_itxt_chunk_comment_zero_width_characters.txt") {
+		t.Fatalf("saved %v", files)
+	}
+	if data, err := os.ReadFile(files[0]); err != nil || string(data) != "exfil via dns" {
+		t.Errorf("saved %q, %v", data, err)
+	}
+
+	// Characters that do not decode give a weaker finding
+	result = &models.AnalysisResult{}
+	Analyze("Cover"+strings.Repeat("\u200B\u200C\u200C", 6), "JPEG comment", "photo.jpg", analyzer.AnalysisOptions{}, result)
+	if len(result.Findings) != 1 || result.Findings[0].Description != "JPEG comment contains zero-width characters" || result.DetectionScore != 0.5 {
+		t.Errorf("got findings %+v with score %v", result.Findings, result.DetectionScore)
+	}
+}
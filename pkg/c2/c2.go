@@ -0,0 +1,113 @@
+package c2
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+/*
+This file contains the command-and-control (C2) command detector. Payloads
+recovered from images are checked for shell and PowerShell commands that point
+at a C2 channel rather than an innocent hidden message. The command list is
+embedded in the binary so detection works from any directory, and can be
+replaced at runtime with a file of the same format.
+*/
+
+// MinMatches is the number of distinct commands a payload needs to be reported
+const MinMatches = 2
+
+//go:embed commands.txt
+var defaultCommands string
+
+// Detector finds known commands in text
+type Detector struct {
+	commands []string
+}
+
+// NewDetector creates a detector using the embedded command list
+func NewDetector() *Detector {
+	d, _ := parseCommands(strings.NewReader(defaultCommands))
+	return d
+}
+
+// LoadDetector creates a detector from a command list file
+func LoadDetector(path string) (*Detector, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open command list: %w", err)
+	}
+	defer file.Close()
+
+	d, err := parseCommands(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read command list: %w", err)
+	}
+	if len(d.commands) == 0 {
+		return nil, fmt.Errorf("command list %s is empty", path)
+	}
+	return d, nil
+}
+
+// parseCommands reads one command per line, skipping blanks and comments
+func parseCommands(r io.Reader) (*Detector, error) {
+	d := &Detector{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		d.commands = append(d.commands, strings.ToLower(line))
+	}
+	return d, scanner.Err()
+}
+
+// Commands returns the number of commands the detector knows
+func (d *Detector) Commands() int {
+	return len(d.commands)
+}
+
+// Match returns the distinct commands that appear in data as whole words
+func (d *Detector) Match(data []byte) []string {
+	text := strings.ToLower(string(data))
+	var found []string
+	for _, cmd := range d.commands {
+		if containsWord(text, cmd) {
+			found = append(found, cmd)
+		}
+	}
+	return found
+}
+
+// IsLikelyC2 reports whether data contains enough known commands to look like a
+// C2 payload
+func (d *Detector) IsLikelyC2(data []byte) bool {
+	return len(d.Match(data)) >= MinMatches
+}
+
+// containsWord reports whether word occurs in text without being part of a
+// longer word
+func containsWord(text, word string) bool {
+	for start := 0; ; {
+		i := strings.Index(text[start:], word)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(word)
+		if (i == 0 || !isWordByte(text[i-1]) || !isWordByte(word[0])) &&
+			(end == len(text) || !isWordByte(text[end]) || !isWordByte(word[len(word)-1])) {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+// isWordByte reports whether b can be part of a word
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
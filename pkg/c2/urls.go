@@ -0,0 +1,266 @@
+package c2
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the URL expander. Payloads often carry the address their
+second stage or C2 channel is reached at. Every HTTP(S) URL in a payload is
+pulled out and classified offline: whether its host is an IP literal rather
+than a domain, whether the domain is under a TLD that is cheap and mostly used
+for abuse, whether it is a link shortener hiding the real destination, and
+whether it names an executable or script. With a Resolver the expander also
+asks each URL's host what it serves, with a HEAD request that downloads
+nothing. Resolving contacts hosts the payload's author may be watching, so it
+is only done when the analyst asks for it.
+*/
+
+// MaxURLs bounds the URLs expanded per payload
+const MaxURLs = 20
+
+// DefaultResolveTimeout is the time limit of each HEAD request of an HTTPResolver
+const DefaultResolveTimeout = 10 * time.Second
+
+// urlPattern matches HTTP(S) URLs, like the built-in "url" indicator rule
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s"'<>]+`)
+
+// suspiciousTLDs are top-level domains that are cheap or free to register and
+// account for an outsized share of malware and phishing domains
+var suspiciousTLDs = map[string]bool{
+	"top": true, "xyz": true, "tk": true, "ml": true, "ga": true, "cf": true,
+	"gq": true, "zip": true, "mov": true, "click": true, "icu": true, "buzz": true,
+	"rest": true, "cam": true, "work": true, "kim": true, "country": true,
+	"loan": true, "su": true, "onion": true,
+}
+
+// shorteners are link shortening services, whose links hide the destination
+var shorteners = map[string]bool{
+	"bit.ly": true, "tinyurl.com": true, "t.co": true, "goo.gl": true, "is.gd": true,
+	"v.gd": true, "ow.ly": true, "buff.ly": true, "rebrand.ly": true, "cutt.ly": true,
+	"rb.gy": true, "shorturl.at": true, "tiny.cc": true, "bit.do": true, "t.ly": true,
+}
+
+// executableExtensions are the file types of the built-in "executable-url" rule
+var executableExtensions = map[string]bool{
+	".exe": true, ".dll": true, ".scr": true, ".msi": true, ".bat": true, ".cmd": true,
+	".ps1": true, ".vbs": true, ".hta": true, ".jar": true, ".apk": true,
+}
+
+// executableTypes are content types servers send executables with
+var executableTypes = map[string]bool{
+	"application/x-msdownload":                      true,
+	"application/x-dosexec":                         true,
+	"application/x-msdos-program":                   true,
+	"application/x-ms-installer":                    true,
+	"application/vnd.microsoft.portable-executable": true,
+	"application/java-archive":                      true,
+	"application/vnd.android.package-archive":       true,
+	"application/hta":                               true,
+}
+
+// URLInfo is a URL found in a payload and what is known about it
+type URLInfo struct {
+	URL          string `json:"url"`
+	Host         string `json:"host"`
+	IPLiteral    bool   `json:"ipLiteral,omitempty"`    // The host is an IP address, not a domain
+	TLD          string `json:"tld,omitempty"`          // Top-level domain of a domain host
+	SuspectTLD   bool   `json:"suspectTld,omitempty"`   // The TLD is one mostly used for abuse
+	Shortener    bool   `json:"shortener,omitempty"`    // The host is a link shortener
+	Executable   bool   `json:"executable,omitempty"`   // The path or the served content type is an executable or script
+	Resolved     bool   `json:"resolved,omitempty"`     // A HEAD request was answered
+	Status       int    `json:"status,omitempty"`       // HTTP status of the HEAD request
+	ContentType  string `json:"contentType,omitempty"`  // Content type the URL serves
+	Location     string `json:"location,omitempty"`     // Where the URL redirects to
+	ResolveError string `json:"resolveError,omitempty"` // Why the HEAD request failed
+}
+
+// Reasons returns why the URL is suspicious, empty when nothing about it is
+func (u URLInfo) Reasons() []string {
+	var reasons []string
+	if u.IPLiteral {
+		reasons = append(reasons, "IP address host")
+	}
+	if u.SuspectTLD {
+		reasons = append(reasons, "abused TLD ."+u.TLD)
+	}
+	if u.Shortener {
+		reasons = append(reasons, "link shortener")
+	}
+	if u.Executable {
+		reasons = append(reasons, "executable download")
+	}
+	return reasons
+}
+
+// Confidence returns how strongly the URL points at a C2 channel or a second
+// stage, 0 for a URL nothing is suspicious about
+func (u URLInfo) Confidence() float64 {
+	confidence := 0.0
+	if u.Shortener && confidence < 0.4 {
+		confidence = 0.4
+	}
+	if (u.IPLiteral || u.SuspectTLD) && confidence < 0.5 {
+		confidence = 0.5
+	}
+	if u.Executable && confidence < 0.7 {
+		confidence = 0.7
+	}
+	if u.Executable && (u.IPLiteral || u.SuspectTLD) {
+		confidence = 0.8
+	}
+	return confidence
+}
+
+// Resolution is what a host answered about a URL
+type Resolution struct {
+	Status      int
+	ContentType string
+	Location    string
+}
+
+// Resolver looks up what a URL serves without downloading it
+type Resolver interface {
+	Resolve(ctx context.Context, rawURL string) (Resolution, error)
+}
+
+// HTTPResolver resolves URLs with HEAD requests. Redirects are reported rather
+// than followed, so only the payload's own hosts are contacted.
+type HTTPResolver struct {
+	Client *http.Client
+}
+
+// NewHTTPResolver creates a resolver whose requests time out after timeout
+func NewHTTPResolver(timeout time.Duration) *HTTPResolver {
+	return &HTTPResolver{Client: &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// Resolve sends a HEAD request for rawURL
+func (r *HTTPResolver) Resolve(ctx context.Context, rawURL string) (Resolution, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return Resolution{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return Resolution{}, fmt.Errorf("failed to send HEAD request: %w", err)
+	}
+	resp.Body.Close()
+	return Resolution{
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Location:    resp.Header.Get("Location"),
+	}, nil
+}
+
+// URLExpander finds and classifies the URLs in payloads
+type URLExpander struct {
+	Resolver Resolver // Asked about each URL when not nil; nil keeps the expander offline
+}
+
+// Expand returns the distinct URLs in data, at most MaxURLs, classified and,
+// with a Resolver, resolved
+func (e *URLExpander) Expand(ctx context.Context, data []byte) []URLInfo {
+	var urls []URLInfo
+	seen := make(map[string]bool)
+	for _, match := range urlPattern.FindAll(data, -1) {
+		raw := strings.TrimRight(string(match), ".,;:!?)]}")
+		if seen[raw] {
+			continue
+		}
+		seen[raw] = true
+		info, ok := ClassifyURL(raw)
+		if !ok {
+			continue
+		}
+		if e != nil && e.Resolver != nil && ctx.Err() == nil {
+			info.resolve(ctx, e.Resolver)
+		}
+		urls = append(urls, info)
+		if len(urls) == MaxURLs {
+			break
+		}
+	}
+	return urls
+}
+
+// ClassifyURL classifies rawURL without contacting its host. It returns false
+// when rawURL has no host.
+func ClassifyURL(rawURL string) (URLInfo, bool) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Hostname() == "" {
+		return URLInfo{}, false
+	}
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	info := URLInfo{URL: rawURL, Host: host}
+	if net.ParseIP(host) != nil {
+		info.IPLiteral = true
+	} else {
+		info.TLD = host[strings.LastIndex(host, ".")+1:]
+		info.SuspectTLD = suspiciousTLDs[info.TLD]
+		info.Shortener = shorteners[strings.TrimPrefix(host, "www.")]
+	}
+	info.Executable = executableExtensions[strings.ToLower(path.Ext(parsed.Path))]
+	return info, true
+}
+
+// resolve records what resolver finds out about the URL
+func (u *URLInfo) resolve(ctx context.Context, resolver Resolver) {
+	resolution, err := resolver.Resolve(ctx, u.URL)
+	if err != nil {
+		u.ResolveError = err.Error()
+		return
+	}
+	u.Resolved = true
+	u.Status = resolution.Status
+	u.ContentType = resolution.ContentType
+	u.Location = resolution.Location
+	mediaType, _, _ := strings.Cut(strings.ToLower(resolution.ContentType), ";")
+	if executableTypes[strings.TrimSpace(mediaType)] {
+		u.Executable = true
+	}
+}
+
+// ApplyURLs lists urls, found in the payload named by source, in
+// result.Details["payload_urls"] and adds a finding for each suspicious one
+func ApplyURLs(urls []URLInfo, source string, result *models.AnalysisResult) {
+	if len(urls) == 0 {
+		return
+	}
+	for _, u := range urls {
+		reasons := u.Reasons()
+		if len(reasons) == 0 {
+			continue
+		}
+		details := fmt.Sprintf("%s: %s (%s)", source, u.URL, strings.Join(reasons, ", "))
+		switch {
+		case u.Location != "":
+			details += fmt.Sprintf(", redirects to %s", u.Location)
+		case u.Resolved:
+			details += fmt.Sprintf(", serves %q with status %d", u.ContentType, u.Status)
+		case u.ResolveError != "":
+			details += fmt.Sprintf(", not resolved: %s", u.ResolveError)
+		}
+		result.AddFinding("Extracted payload links to a suspicious URL", u.Confidence(), details)
+	}
+	if result.Details == nil {
+		result.Details = map[string]interface{}{}
+	}
+	listed, _ := result.Details["payload_urls"].([]URLInfo)
+	result.Details["payload_urls"] = append(listed, urls...)
+}
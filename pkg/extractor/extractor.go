@@ -1,7 +1,14 @@
 package extractor
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"image"
+	"io"
+	"os"
 
 	"DeSteGo/pkg/models"
 )
@@ -13,6 +20,131 @@ type ExtractionOptions struct {
 	Parameters     map[string]interface{}
 	Password       string
 	Verbose        bool
+	LengthHeader   LengthHeader     // Length prefix to expect in front of the payload
+	Thresholds     ReportThresholds // What a candidate needs to be reported
+	MaxBytes       int              // Cap on the size of each extracted payload (0 means no extra cap)
+	ZeroRunLength  int              // End payloads at this many consecutive zero bytes (0 disables)
+	Workers        int              // Extraction methods run at once (0 uses one per CPU, 1 runs them in turn)
+	MemoryBudget   int              // Bytes the running methods may allocate together (0 uses the extractor's default)
+	Trace          func(Attempt)    // Called for every extraction attempt when not nil
+	Stream         bool             // Judge candidates by their first bytes and stream the chosen payload to disk
+	Log            io.Writer        // Where verbose progress messages go (nil means standard output)
+	Source         models.Source    // The file's bytes and image shared with the analyzers, read from disk when nil
+}
+
+// VerboseLog returns the writer for verbose progress messages, or nil when
+// Verbose is off
+func (o ExtractionOptions) VerboseLog() io.Writer {
+	if !o.Verbose {
+		return nil
+	}
+	if o.Log == nil {
+		return os.Stdout
+	}
+	return o.Log
+}
+
+// Attempt records one extraction method an extractor tried and what it produced.
+// Extractors only build attempts when ExtractionOptions.Trace is set.
+type Attempt struct {
+	Method    string  `json:"method"`
+	Bytes     int     `json:"bytes"`
+	Printable float64 `json:"printable"`         // Printable-character ratio of the output
+	Entropy   float64 `json:"entropy"`           // Shannon entropy in bits per byte
+	Score     float64 `json:"score"`             // Extractor-specific quality score
+	Reported  bool    `json:"reported"`          // Whether the output met the reporting thresholds
+	Skipped   string  `json:"skipped,omitempty"` // Why the method did not run
+	Header    string  `json:"header,omitempty"`  // "valid", or why the length header did not validate; empty without one
+}
+
+// ReportThresholds control which extracted candidates are reported. Zero fields
+// fall back to the defaults.
+type ReportThresholds struct {
+	MinLength    int     // Minimum payload size in bytes
+	MinPrintable float64 // Printable-character ratio (0.0-1.0) for text payloads
+	MinEntropy   float64 // Shannon entropy in bits per byte for binary payloads
+}
+
+// DefaultReportThresholds returns the thresholds used when none are configured
+func DefaultReportThresholds() ReportThresholds {
+	return ReportThresholds{
+		MinLength:    10,
+		MinPrintable: 0.8,
+		MinEntropy:   6.5,
+	}
+}
+
+// WithDefaults returns the thresholds with zero fields replaced by the defaults
+func (t ReportThresholds) WithDefaults() ReportThresholds {
+	defaults := DefaultReportThresholds()
+	if t.MinLength <= 0 {
+		t.MinLength = defaults.MinLength
+	}
+	if t.MinPrintable <= 0 {
+		t.MinPrintable = defaults.MinPrintable
+	}
+	if t.MinEntropy <= 0 {
+		t.MinEntropy = defaults.MinEntropy
+	}
+	return t
+}
+
+// LengthHeader describes the length prefix some embedders write in front of a
+// payload. The zero value disables header parsing, so extractors return the raw
+// bit stream.
+type LengthHeader struct {
+	Size      int              // Bytes holding the length: 1, 2, 4 or 8 (0 disables parsing)
+	ByteOrder binary.ByteOrder // Defaults to big-endian when nil
+	Magic     []byte           // Optional marker that precedes the length field
+}
+
+// Enabled reports whether a length header is expected
+func (h LengthHeader) Enabled() bool {
+	return h.Size > 0
+}
+
+// Parse validates the header at the start of data and returns the payload it
+// describes
+func (h LengthHeader) Parse(data []byte) ([]byte, error) {
+	if !h.Enabled() {
+		return data, nil
+	}
+
+	if len(h.Magic) > 0 {
+		if !bytes.HasPrefix(data, h.Magic) {
+			return nil, errors.New("length header magic not found")
+		}
+		data = data[len(h.Magic):]
+	}
+
+	if len(data) < h.Size {
+		return nil, errors.New("data too short for length header")
+	}
+
+	order := h.ByteOrder
+	if order == nil {
+		order = binary.BigEndian
+	}
+
+	var length uint64
+	switch h.Size {
+	case 1:
+		length = uint64(data[0])
+	case 2:
+		length = uint64(order.Uint16(data))
+	case 4:
+		length = uint64(order.Uint32(data))
+	case 8:
+		length = order.Uint64(data)
+	default:
+		return nil, fmt.Errorf("unsupported length header size: %d", h.Size)
+	}
+
+	payload := data[h.Size:]
+	if length == 0 || length > uint64(len(payload)) {
+		return nil, fmt.Errorf("declared length %d does not fit the %d available bytes", length, len(payload))
+	}
+	return payload[:length], nil
 }
 
 // DataExtractor is the interface that all extractors must implement
@@ -41,6 +173,24 @@ type ImageExtractor interface {
 	ExtractFromImage(img image.Image, options ExtractionOptions) (*models.ExtractionResult, error)
 }
 
+// ContextExtractor is an interface for extractors that can stop early once a
+// context is canceled, such as when a scan is interrupted
+type ContextExtractor interface {
+	DataExtractor
+
+	// ExtractContext is like Extract but stops once ctx is canceled
+	ExtractContext(ctx context.Context, filePath string, options ExtractionOptions) (*models.ExtractionResult, error)
+}
+
+// ExtractWithContext runs e with ctx when it is a ContextExtractor, and runs it
+// to completion otherwise
+func ExtractWithContext(ctx context.Context, e DataExtractor, filePath string, options ExtractionOptions) (*models.ExtractionResult, error) {
+	if c, ok := e.(ContextExtractor); ok {
+		return c.ExtractContext(ctx, filePath, options)
+	}
+	return e.Extract(filePath, options)
+}
+
 // BaseExtractor provides common functionality for extractors
 type BaseExtractor struct {
 	name       string
@@ -0,0 +1,40 @@
+package lsb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/extractor"
+)
+
+// BenchmarkExtractFromImage runs every extraction method on an image, as
+// -extract does, including saving the best candidate
+func BenchmarkExtractFromImage(b *testing.B) {
+	e := NewLSBExtractor()
+	for _, size := range fixtures.BenchSizes {
+		img := fixtures.Noise(size, 1)
+		b.Run(fmt.Sprintf("%dpx", size), func(b *testing.B) {
+			options := extractor.ExtractionOptions{OutputDir: b.TempDir()}
+			b.SetBytes(int64(len(img.Pix)))
+			for i := 0; i < b.N; i++ {
+				e.ExtractFromImage(img, options)
+			}
+		})
+	}
+}
+
+// BenchmarkExtractChannelBits reads the sequential RGB stream, the inner loop
+// of every layout method
+func BenchmarkExtractChannelBits(b *testing.B) {
+	for _, size := range fixtures.BenchSizes {
+		img := fixtures.Noise(size, 1)
+		b.Run(fmt.Sprintf("%dpx", size), func(b *testing.B) {
+			b.SetBytes(int64(len(img.Pix)))
+			for i := 0; i < b.N; i++ {
+				extractChannelBits(context.Background(), img, []int{0, 1, 2}, 0, MSBFirst, "sequential-rgb")
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package lsb
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+/*
+This file contains the combination of LSB planes across images. Some schemes
+split a secret over several carriers so that no single image holds anything
+readable: each image's LSBs look random, and only the XOR of all of them gives
+the message, as with the shares of visual cryptography. XORPlanes builds an
+image whose channel values are the XOR of the carriers' least significant
+bits, which the extractor can read like any other image. Combining the planes
+of unrelated images gives noise, so only data with a file signature and text
+count as revealed, including text followed by noise where a message ends
+before the planes do.
+*/
+
+// XORPlanes returns an image of the same size as the images whose R, G, B and
+// A values are the XOR of the least significant bits of their channels, 0 or 1.
+// The images must have identical dimensions.
+func XORPlanes(images []image.Image) (*image.RGBA, error) {
+	if len(images) < 2 {
+		return nil, errors.New("at least two images are needed to combine their planes")
+	}
+	width, height := images[0].Bounds().Dx(), images[0].Bounds().Dy()
+	for i, img := range images[1:] {
+		if w, h := img.Bounds().Dx(), img.Bounds().Dy(); w != width || h != height {
+			return nil, fmt.Errorf("image %d is %dx%d, not %dx%d like the first", i+2, w, h, width, height)
+		}
+	}
+
+	// Values are stored as they are, not premultiplied, so the extractor reads
+	// back exactly the combined bits
+	combined := image.NewRGBA(image.Rect(0, 0, width, height))
+	for _, img := range images {
+		bounds := img.Bounds()
+		pixelAt, shift := sampleReader(img)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				r, g, b, a := pixelAt(bounds.Min.X+x, bounds.Min.Y+y)
+				i := combined.PixOffset(x, y)
+				combined.Pix[i] ^= byte(r>>shift) & 1
+				combined.Pix[i+1] ^= byte(g>>shift) & 1
+				combined.Pix[i+2] ^= byte(b>>shift) & 1
+				combined.Pix[i+3] ^= byte(a>>shift) & 1
+			}
+		}
+	}
+	return combined, nil
+}
+
+// minRevealedText is the run of printable characters at the start of combined
+// data that counts as revealed text even when noise follows it, as it does
+// after a message shorter than the planes
+const minRevealedText = 16
+
+// Revealed reports whether data extracted from combined planes is text of at
+// least the given printable ratio, starts with a run of text or starts with a
+// file signature. The extractor also reports high-entropy binary data, which is
+// what the planes of unrelated images combine to.
+func Revealed(data []byte, minPrintable float64) bool {
+	return looksLikePayload(data, minPrintable) || len(LeadingText(data)) >= minRevealedText
+}
+
+// LeadingText returns the printable characters and whitespace data starts with
+func LeadingText(data []byte) []byte {
+	for i, b := range data {
+		if (b < 32 || b > 126) && b != 9 && b != 10 && b != 13 {
+			return data[:i]
+		}
+	}
+	return data
+}
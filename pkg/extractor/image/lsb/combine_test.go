@@ -0,0 +1,71 @@
+package lsb
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/extractor"
+)
+
+func TestXORPlanes(t *testing.T) {
+	const secret = "The XOR of both shares spells this message."
+	mask := fixtures.RandomPayload(len(secret), 7)
+	masked := make([]byte, len(secret))
+	for i := range masked {
+		masked[i] = secret[i] ^ mask[i]
+	}
+
+	// Each share alone holds random bits
+	share := func(payload []byte, seed int64) image.Image {
+		img := fixtures.Carrier(128, 128, seed)
+		if payload != nil {
+			if err := fixtures.EmbedLSB(img, payload, []int{0, 1, 2}, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return img
+	}
+
+	tests := []struct {
+		name   string
+		images []image.Image
+		want   string // Expected start of the revealed payload, empty when nothing should be revealed
+	}{
+		{"shares", []image.Image{share(mask, 1), share(masked, 2)}, secret},
+		{"unrelated carriers", []image.Image{share(nil, 1), share(nil, 2)}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, img := range tt.images {
+				if result, err := NewLSBExtractor().ExtractFromImage(img, extractor.ExtractionOptions{OutputDir: t.TempDir()}); err == nil && Revealed(result.ExtractedData, 0.8) {
+					t.Fatalf("a single image reveals %q", result.ExtractedData)
+				}
+			}
+
+			combined, err := XORPlanes(tt.images)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := NewLSBExtractor().ExtractFromImage(combined, extractor.ExtractionOptions{OutputDir: t.TempDir()})
+			revealed := err == nil && Revealed(result.ExtractedData, 0.8)
+			if tt.want == "" {
+				if revealed {
+					t.Errorf("combined planes reveal %q", result.ExtractedData)
+				}
+				return
+			}
+			if !revealed || !bytes.HasPrefix(result.ExtractedData, []byte(tt.want)) {
+				t.Fatalf("extracted %v (%v), want %q first", result, err, tt.want)
+			}
+			if result.Algorithm != "lsb-sequential-rgb" {
+				t.Errorf("extracted with %s, want lsb-sequential-rgb", result.Algorithm)
+			}
+		})
+	}
+
+	if _, err := XORPlanes([]image.Image{fixtures.Carrier(8, 8, 1), fixtures.Carrier(8, 4, 1)}); err == nil {
+		t.Error("combined images of different sizes")
+	}
+}
@@ -0,0 +1,141 @@
+package lsb
+
+import (
+	"fmt"
+	"strings"
+
+	"DeSteGo/pkg/analyzer/image/jpeg"
+	"DeSteGo/pkg/extractor"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the JSteg extractor. JSteg replaces the least significant
+bit of the quantized AC coefficients that are not 0 or 1, so the payload is read
+back from the coefficients rather than the decoded pixels. Which blocks carry
+the stream, and in what order, depends on the embedding tool, so every block
+order is tried unless one is configured with the "block_order" parameter. Each
+order tried is recorded with the size and score of its output, in the result's
+Details["attempts"] or in the error when none produced a payload, so that it is
+clear why an order won.
+*/
+
+// BlockOrderParameter is the ExtractionOptions.Parameters key that selects the
+// DCT block order, as a name accepted by jpeg.ParseBlockOrder
+const BlockOrderParameter = "block_order"
+
+// JStegExtractor extracts JSteg payloads from the DCT coefficients of a JPEG
+type JStegExtractor struct {
+	extractor.BaseExtractor
+}
+
+// NewJStegExtractor creates a new JSteg extractor
+func NewJStegExtractor() *JStegExtractor {
+	formats := []string{"jpg", "jpeg"}
+	algorithms := []string{"jsteg", "dct-lsb"}
+
+	return &JStegExtractor{
+		BaseExtractor: extractor.NewBaseExtractor("JSteg Extractor", formats, algorithms),
+	}
+}
+
+// Extract implements the DataExtractor interface
+func (e *JStegExtractor) Extract(filePath string, options extractor.ExtractionOptions) (*models.ExtractionResult, error) {
+	data, err := models.ReadFile(options.Source, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	dct, err := jpeg.ParseJPEGDCTCoefficients(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode DCT coefficients: %w", err)
+	}
+	return extractFromDCT(dct, options)
+}
+
+// extractFromDCT reads the JSteg stream of the coefficients in each block order
+// and saves the best payload
+func extractFromDCT(dct *jpeg.JPEGDCTData, options extractor.ExtractionOptions) (*models.ExtractionResult, error) {
+	orders := jpeg.BlockOrders
+	if name, ok := options.Parameters[BlockOrderParameter].(string); ok && name != "" {
+		order, err := jpeg.ParseBlockOrder(name)
+		if err != nil {
+			return nil, err
+		}
+		orders = []jpeg.BlockOrder{order}
+	}
+
+	thresholds := options.Thresholds.WithDefaults()
+	var best *ExtractionCandidate
+	var bestOrder jpeg.BlockOrder
+	var attempts []extractor.Attempt
+	for _, order := range orders {
+		candidate := extractJSteg(dct, order)
+		terminateCandidate(candidate, options, thresholds)
+
+		// Coefficient LSBs of a clean image are close to random, so only text or a
+		// known file signature counts, never high entropy alone
+		reported := len(candidate.Data) >= thresholds.MinLength && looksLikePayload(candidate.Data, thresholds.MinPrintable)
+		attempt := newAttempt(candidate.Method, methodOutcome{candidate: candidate}, reported)
+		attempts = append(attempts, attempt)
+		if options.Trace != nil {
+			options.Trace(attempt)
+		}
+		if reported && (best == nil || candidate.Score > best.Score) {
+			best, bestOrder = candidate, order
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no block order produced a recognisable payload (%s)", describeAttempts(attempts))
+	}
+
+	result, err := processExtractedData(best, options)
+	if err != nil {
+		return nil, err
+	}
+	result.Algorithm = "jsteg"
+	result.Details["block_order"] = string(bestOrder)
+	result.Details["attempts"] = attempts
+	return result, nil
+}
+
+// describeAttempts summarizes the output of each attempt in one line
+func describeAttempts(attempts []extractor.Attempt) string {
+	parts := make([]string, len(attempts))
+	for i, a := range attempts {
+		parts[i] = fmt.Sprintf("%s: %d bytes, score %.2f", a.Method, a.Bytes, a.Score)
+		if a.Header != "" && a.Header != "valid" {
+			parts[i] += ", " + a.Header
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// extractJSteg reads the JSteg bits of the AC coefficients from the blocks in the
+// given order, packing them most significant bit first. Negative coefficients
+// count like positive ones, by the parity of their value.
+func extractJSteg(dct *jpeg.JPEGDCTData, order jpeg.BlockOrder) *ExtractionCandidate {
+	var out []byte
+	var current byte
+	bits := 0
+
+	for _, block := range dct.OrderedBlocks(order) {
+		for k := 1; k < 64 && len(out) < MaxExtractSize; k++ {
+			bit, ok := jpeg.JStegBit(block.Coefficients[k])
+			if !ok {
+				continue
+			}
+			current = current<<1 | bit
+			bits++
+			if bits == 8 {
+				out = append(out, current)
+				current, bits = 0, 0
+			}
+		}
+	}
+
+	return &ExtractionCandidate{
+		Data:   out,
+		Method: "jsteg-" + string(order),
+		Score:  evaluateExtraction(out),
+	}
+}
@@ -0,0 +1,187 @@
+package lsb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"DeSteGo/pkg/analyzer/image/jpeg"
+	"DeSteGo/pkg/extractor"
+)
+
+func TestJStegBit(t *testing.T) {
+	tests := []struct {
+		coefficient int16
+		bit         byte
+		ok          bool
+	}{
+		{0, 0, false},
+		{1, 0, false},
+		{2, 0, true},
+		{3, 1, true},
+		{-1, 1, true},
+		{-2, 0, true},
+		{-3, 1, true},
+		{-4, 0, true},
+		{-255, 1, true},
+		{-32768, 0, true},
+	}
+	for _, tt := range tests {
+		bit, ok := jpeg.JStegBit(tt.coefficient)
+		if bit != tt.bit || ok != tt.ok {
+			t.Errorf("JStegBit(%d) = %d, %v, want %d, %v", tt.coefficient, bit, ok, tt.bit, tt.ok)
+		}
+	}
+}
+
+func TestExtractJStegNegative(t *testing.T) {
+	const message = "Negative coefficients carry bits too"
+
+	// embed writes the message into one coefficient per bit, taken in turn from
+	// values with its LSB set to the bit the way JSteg's (v & ~1) | bit does, so
+	// -2 becomes -1 for a 1. The coefficients in skipped follow every bit.
+	embed := func(values, skipped []int16) *jpeg.JPEGDCTData {
+		var coefficients []int16
+		for _, b := range []byte(message) {
+			for i := 7; i >= 0; i-- {
+				bit := int16(b>>i) & 1
+				v := values[len(coefficients)%len(values)]
+				coefficients = append(coefficients, v&^1|bit)
+				coefficients = append(coefficients, skipped...)
+			}
+		}
+
+		// Every block keeps its DC coefficient and holds 63 AC coefficients
+		var blocks []jpeg.DCTCoefficientBlock
+		for len(coefficients) > 0 {
+			block := jpeg.DCTCoefficientBlock{Col: len(blocks)}
+			block.Coefficients[0] = -512
+			n := copy(block.Coefficients[1:], coefficients)
+			coefficients = coefficients[n:]
+			blocks = append(blocks, block)
+		}
+		return &jpeg.JPEGDCTData{
+			Components: []jpeg.ComponentInfo{{ID: 1, BlocksWide: len(blocks), BlocksHigh: 1}},
+			Blocks:     blocks,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		values  []int16
+		skipped []int16
+	}{
+		{"negative", []int16{-2, -4, -6, -8, -16, -100, -1024}, nil},
+		{"negative with skipped zeros and ones", []int16{-2, -4, -30}, []int16{0, 1}},
+		{"mixed signs", []int16{-2, 4, -6, 8, -254, 2}, []int16{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidate := extractJSteg(embed(tt.values, tt.skipped), jpeg.OrderInterleaved)
+			if !bytes.Equal(candidate.Data, []byte(message)) {
+				t.Errorf("extracted %q, want %q", candidate.Data, message)
+			}
+		})
+	}
+}
+
+func TestJStegAttemptLog(t *testing.T) {
+	const message = "Every block order reads this one"
+
+	// jstegData hides stream in the AC coefficients of a grayscale image, whose
+	// blocks the interleaved, luminance and sequential orders all read the same
+	// way and the chrominance order not at all
+	jstegData := func(stream []byte) *jpeg.JPEGDCTData {
+		var coefficients []int16
+		for _, b := range stream {
+			for i := 7; i >= 0; i-- {
+				coefficients = append(coefficients, int16(4+len(coefficients)%5*2)|int16(b>>i)&1)
+			}
+		}
+		var blocks []jpeg.DCTCoefficientBlock
+		for len(coefficients) > 0 {
+			block := jpeg.DCTCoefficientBlock{Col: len(blocks)}
+			coefficients = coefficients[copy(block.Coefficients[1:], coefficients):]
+			blocks = append(blocks, block)
+		}
+		return &jpeg.JPEGDCTData{
+			Components: []jpeg.ComponentInfo{{ID: 1, BlocksWide: len(blocks), BlocksHigh: 1}},
+			Blocks:     blocks,
+		}
+	}
+	headed := binary.BigEndian.AppendUint32(nil, uint32(len(message)))
+
+	tests := []struct {
+		name     string
+		stream   []byte
+		header   extractor.LengthHeader
+		reported int    // Attempts that produced a payload
+		found    bool   // Whether a payload is extracted
+		checked  string // Header outcome of the orders that read the blocks
+		empty    string // Header outcome of the chrominance order, which reads none
+	}{
+		{"same payload in three orders", []byte(message), extractor.LengthHeader{}, 3, true, "", ""},
+		{"length header", append(headed, message...), extractor.LengthHeader{Size: 4}, 3, true, "valid", "data too short for length header"},
+		{"no payload", bytes.Repeat([]byte{0x81, 0x7E}, 64), extractor.LengthHeader{}, 0, false, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var traced []extractor.Attempt
+			options := extractor.ExtractionOptions{
+				OutputDir:    t.TempDir(),
+				LengthHeader: tt.header,
+				Trace:        func(a extractor.Attempt) { traced = append(traced, a) },
+			}
+			result, err := extractFromDCT(jstegData(tt.stream), options)
+
+			if len(traced) != len(jpeg.BlockOrders) {
+				t.Fatalf("%d attempts traced, want one per block order", len(traced))
+			}
+			reported := 0
+			for i, attempt := range traced {
+				if want := "jsteg-" + string(jpeg.BlockOrders[i]); attempt.Method != want {
+					t.Errorf("attempt %d is %s, want %s", i, attempt.Method, want)
+				}
+				if attempt.Reported {
+					reported++
+				}
+				want := tt.checked
+				if jpeg.BlockOrders[i] == jpeg.OrderChrominance {
+					want = tt.empty
+				}
+				if attempt.Header != want {
+					t.Errorf("%s header %q, want %q", attempt.Method, attempt.Header, want)
+				}
+			}
+			if reported != tt.reported {
+				t.Errorf("%d attempts reported, want %d", reported, tt.reported)
+			}
+
+			if !tt.found {
+				if err == nil {
+					t.Fatal("extracted a payload from coefficients without one")
+				}
+				for _, order := range jpeg.BlockOrders {
+					if !strings.Contains(err.Error(), "jsteg-"+string(order)) {
+						t.Errorf("error %q does not describe the %s attempt", err, order)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(result.ExtractedData) != message {
+				t.Errorf("extracted %q, want %q", result.ExtractedData, message)
+			}
+			// Equal scores keep the first order
+			if result.Details["block_order"] != string(jpeg.OrderInterleaved) {
+				t.Errorf("block order %v, want interleaved", result.Details["block_order"])
+			}
+			if attempts, _ := result.Details["attempts"].([]extractor.Attempt); len(attempts) != len(jpeg.BlockOrders) {
+				t.Errorf("result records %d attempts, want %d", len(attempts), len(jpeg.BlockOrders))
+			}
+		})
+	}
+}
@@ -1,39 +1,27 @@
 package lsb
 
 import (
-	//"bytes"
-	"encoding/binary"
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"image"
-
-	//"image/color"
 	_ "image/jpeg"
 	_ "image/png"
+	"io"
+	"path/filepath"
+	"sort"
+	"unicode/utf8"
 
 	_ "golang.org/x/image/bmp"
 	_ "golang.org/x/image/tiff"
 
-	"os"
-	"path/filepath"
-	"strings"
-	"unicode/utf8"
-
+	"DeSteGo/pkg/analyzer/carve"
+	"DeSteGo/pkg/analyzer/image/lenient"
+	"DeSteGo/pkg/analyzer/stats"
 	"DeSteGo/pkg/extractor"
+	"DeSteGo/pkg/filehandler"
 	"DeSteGo/pkg/models"
-	//_ "golang.org/x/image/bmp"
-	//_ "golang.org/x/image/tiff"
-)
-
-const (
-	// Common file signatures/magic numbers
-	pngSignature   = "\x89PNG"
-	jpgSignature   = "\xff\xd8\xff"
-	pdfSignature   = "%PDF"
-	zipSignature   = "PK\x03\x04"
-	gifSignature   = "GIF8"
-	bmpSignature   = "BM"
-	nullTerminator = "\x00"
 )
 
 // LSBExtractor implements the ImageExtractor interface for LSB steganography
@@ -47,7 +35,7 @@ const MaxExtractSize = 50 * 1024 * 1024 // 50MB
 // NewLSBExtractor creates a new LSB extractor
 func NewLSBExtractor() *LSBExtractor {
 	formats := []string{"png", "bmp", "tiff", "jpg", "jpeg", "gif"}
-	algorithms := []string{"lsb-basic", "lsb-sequential", "lsb-rgb"}
+	algorithms := []string{"lsb-basic", "lsb-sequential", "lsb-rgb", "lsb-alpha"}
 	base := extractor.NewBaseExtractor("LSB Extractor", formats, algorithms)
 
 	return &LSBExtractor{
@@ -57,282 +45,434 @@ func NewLSBExtractor() *LSBExtractor {
 
 // Extract implements the DataExtractor interface
 func (e *LSBExtractor) Extract(filePath string, options extractor.ExtractionOptions) (*models.ExtractionResult, error) {
-	// Open the image file
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open image file: %w", err)
-	}
-	defer file.Close()
+	return e.ExtractContext(context.Background(), filePath, options)
+}
 
-	// Decode the image
-	img, _, err := image.Decode(file)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %w", err)
+// ExtractContext is like Extract but stops between extraction passes once ctx
+// is cancelled
+func (e *LSBExtractor) ExtractContext(ctx context.Context, filePath string, options extractor.ExtractionOptions) (*models.ExtractionResult, error) {
+	// Decode the image, repairing it as the analyzers do if needed, unless the
+	// analyzers already decoded it
+	img, repairs, ok := models.SourceImage(options.Source, filePath, "")
+	if !ok {
+		decoded, err := lenient.DecodeFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+		img, repairs = decoded.Image, decoded.Repairs
 	}
 
 	// Call the image-specific extraction method
-	return e.ExtractFromImage(img, options)
+	result, err := e.ExtractFromImageContext(ctx, img, options)
+	if result != nil && len(repairs) > 0 {
+		result.Details["lenient_decode"] = repairs
+	}
+	return result, err
 }
 
 // ExtractFromImage implements the ImageExtractor interface
 func (e *LSBExtractor) ExtractFromImage(img image.Image, options extractor.ExtractionOptions) (*models.ExtractionResult, error) {
+	return e.ExtractFromImageContext(context.Background(), img, options)
+}
+
+// ExtractFromImageContext is like ExtractFromImage but returns ctx.Err() as
+// soon as it notices that ctx was cancelled. No output is written in that case.
+func (e *LSBExtractor) ExtractFromImageContext(ctx context.Context, img image.Image, options extractor.ExtractionOptions) (*models.ExtractionResult, error) {
 	if img == nil {
 		return nil, errors.New("nil image provided")
 	}
 
 	// Try multiple extraction techniques and return the best result
-	results := make(map[string]*ExtractionCandidate)
 	var bestResult *ExtractionCandidate
+	var bestWrite writeFunc // Re-extracts the best candidate's full stream in streaming mode
+	var skipped []string
+	var found []reportedCandidate // Every candidate that met the thresholds, for the alternates
 
 	// Try different extraction methods
-	extractionMethods := []struct {
-		name   string
-		method func(image.Image) *ExtractionCandidate
-	}{
-		{"sequential-rgb", extractSequentialRGB},
-		{"sequential-rgba", extractSequentialRGBA},
-		{"sequential-r", extractSequentialR},
-		{"sequential-g", extractSequentialG},
-		{"sequential-b", extractSequentialB},
-		{"planes-rgb", extractPlanesRGB},
-	}
-
-	verbose := options.Verbose
-
-	for _, method := range extractionMethods {
-		if verbose {
-			fmt.Printf("Trying extraction method: %s\n", method.name)
+	layouts := standardLayouts
+
+	// The analyzers hint at alpha-only embedding when the image is opaque but its
+	// alpha LSBs are random
+	for _, hint := range options.AlgorithmHints {
+		if hint == "lsb-alpha" {
+			layouts = append(layouts[:len(layouts):len(layouts)], alphaLayout)
+			break
+		}
+	}
+	extractionMethods := layoutMethods(layouts, options.Stream)
+	if options.Password != "" {
+		extractionMethods = append(extractionMethods, seededMethods(options.Password, options.Stream)...)
+	}
+
+	log := options.VerboseLog()
+	thresholds := options.Thresholds.WithDefaults()
+
+	outcomes := runMethods(ctx, img, extractionMethods, options.Workers, options.MemoryBudget, log)
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("extraction cancelled: %w", err)
+	}
+	for i, outcome := range outcomes {
+		candidate := outcome.candidate
+		if candidate == nil {
+			skipped = append(skipped, extractionMethods[i].name+": "+outcome.skipped)
+			traceAttempt(options, extractionMethods[i].name, outcome, false)
+			continue
+		}
+		terminateCandidate(candidate, options, thresholds)
+		reported := meetsThresholds(candidate.Data, thresholds)
+		traceAttempt(options, extractionMethods[i].name, outcome, reported)
+		if !reported {
+			continue
 		}
 
-		candidate := method.method(img)
-		results[method.name] = candidate
+		found = append(found, reportedCandidate{candidate: candidate, write: extractionMethods[i].write})
 
 		// Evaluate if this is the best result so far
 		if bestResult == nil || candidate.Score > bestResult.Score {
-			bestResult = candidate
+			bestResult, bestWrite = candidate, extractionMethods[i].write
 		}
 	}
 
-	if bestResult == nil || bestResult.Data == nil || len(bestResult.Data) == 0 {
-		return nil, errors.New("failed to extract any hidden data")
-	}
+	// Tools differ in bit order and in which bit they use. When no standard
+	// method produced a recognisable payload, try the alternate variants.
+	var usedVariant *bitLayout
+	if bestResult == nil || !looksLikePayload(bestResult.Data, thresholds.MinPrintable) {
+		variants := layoutMethods(alternateVariants, options.Stream)
 
-	// Process extracted data to determine file type and save output
-	return processExtractedData(bestResult, options)
-}
+		outcomes := runMethods(ctx, img, variants, options.Workers, options.MemoryBudget, log)
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("extraction cancelled: %w", err)
+		}
+		for i, outcome := range outcomes {
+			candidate := outcome.candidate
+			if candidate == nil {
+				skipped = append(skipped, variants[i].name+": "+outcome.skipped)
+				traceAttempt(options, variants[i].name, outcome, false)
+				continue
+			}
+			terminateCandidate(candidate, options, thresholds)
 
-// ExtractionCandidate represents a possible extraction result with quality metrics
-type ExtractionCandidate struct {
-	Data        []byte
-	Method      string
-	Score       float64
-	FileType    string
-	TextQuality float64
-}
+			reported := len(candidate.Data) >= thresholds.MinLength && looksLikePayload(candidate.Data, thresholds.MinPrintable)
+			traceAttempt(options, variants[i].name, outcome, reported)
+			if reported {
+				found = append(found, reportedCandidate{candidate: candidate, write: variants[i].write, variant: &alternateVariants[i]})
+			}
+			// Channel orders that differ in two channels can both read as
+			// printable text, and only one of them reads as words
+			better := usedVariant == nil || candidate.Score > bestResult.Score ||
+				candidate.Score == bestResult.Score && letterRatio(candidate.Data) > letterRatio(bestResult.Data)
+			if reported && better {
+				bestResult, bestWrite = candidate, variants[i].write
+				usedVariant = &alternateVariants[i]
+			}
+		}
+	}
 
-// extractSequentialRGB extracts LSB data sequentially from R, G, B channels
-func extractSequentialRGB(img image.Image) *ExtractionCandidate {
-	bounds := img.Bounds()
-	width, height := bounds.Dx(), bounds.Dy()
+	// Tools that reserve the first pixels start the payload further in
+	usedOffset := 0
+	if bestResult == nil || !looksLikePayload(bestResult.Data, thresholds.MinPrintable) {
+		shifted, offsets := offsetMethods(ctx, img, layouts, thresholds.MinPrintable, options.Stream)
 
-	// Maximum possible size of hidden data (1 bit per channel per pixel)
-	maxBytes := (width * height * 3) / 8
-	if maxBytes > MaxExtractSize {
-		maxBytes = MaxExtractSize
-	}
+		outcomes := runMethods(ctx, img, shifted, options.Workers, options.MemoryBudget, log)
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("extraction cancelled: %w", err)
+		}
+		for i, outcome := range outcomes {
+			candidate := outcome.candidate
+			if candidate == nil {
+				skipped = append(skipped, shifted[i].name+": "+outcome.skipped)
+				traceAttempt(options, shifted[i].name, outcome, false)
+				continue
+			}
+			terminateCandidate(candidate, options, thresholds)
 
-	// Pre-allocate result buffer
-	result := make([]byte, 0, maxBytes)
+			reported := len(candidate.Data) >= thresholds.MinLength && looksLikePayload(candidate.Data, thresholds.MinPrintable)
+			traceAttempt(options, shifted[i].name, outcome, reported)
+			if reported {
+				found = append(found, reportedCandidate{candidate: candidate, write: shifted[i].write, offset: offsets[i]})
+			}
+			if reported && (usedOffset == 0 || candidate.Score > bestResult.Score) {
+				bestResult, bestWrite = candidate, shifted[i].write
+				usedVariant = nil
+				usedOffset = offsets[i]
+			}
+		}
+	}
 
-	var currentByte byte = 0
-	bitIndex := 0
+	if bestResult == nil {
+		return nil, errors.New("no extracted candidate met the reporting thresholds")
+	}
 
-	// Extract LSBs sequentially from each RGB channel
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, _ := img.At(x, y).RGBA()
+	best := reportedCandidate{candidate: bestResult, write: bestWrite, variant: usedVariant, offset: usedOffset}
+	result, err := saveCandidate(ctx, img, best, options)
+	if err != nil {
+		return nil, err
+	}
+	if len(skipped) > 0 {
+		result.Details["skipped_methods"] = skipped
+	}
 
-			// Extract 1 bit from each channel
-			pixels := []uint32{r, g, b}
-			for _, p := range pixels {
-				// Get LSB from the current channel
-				bit := byte(p>>8) & 1
+	// Other channels and methods can carry payloads of their own
+	for _, alternate := range alternates(found, bestResult, thresholds.MinPrintable) {
+		alternateResult, err := saveCandidate(ctx, img, alternate, options)
+		if err != nil {
+			return nil, err
+		}
+		result.Alternates = append(result.Alternates, alternateResult)
+	}
+	return result, nil
+}
 
-				// Add bit to current byte
-				currentByte |= bit << uint(7-bitIndex)
-				bitIndex++
+// Alternate payload parameters
+const (
+	minAlternateScore = 0.4 // Score an alternate candidate needs, about that of clean text
+	maxAlternates     = 8   // Alternates returned at most
+)
 
-				// When we have a complete byte, add it to the result
-				if bitIndex == 8 {
-					result = append(result, currentByte)
-					currentByte = 0
-					bitIndex = 0
+// reportedCandidate is a candidate that met the reporting thresholds, with how
+// it was read
+type reportedCandidate struct {
+	candidate *ExtractionCandidate
+	write     writeFunc  // Re-extracts the full stream in streaming mode
+	variant   *bitLayout // Alternate variant that read it, if any
+	offset    int        // Pixels skipped before it, if any
+}
 
-					// Check if we've reached the maximum size
-					if len(result) >= maxBytes {
-						score := evaluateExtraction(result)
-						return &ExtractionCandidate{
-							Data:   result,
-							Method: "sequential-rgb",
-							Score:  score,
-						}
-					}
-				}
-			}
+// alternates returns the reported candidates other than best that start with a
+// file signature or text of minPrintable quality, best first. Candidates whose
+// data repeats an earlier one, as the same bits read through two methods do,
+// are left out.
+func alternates(reported []reportedCandidate, best *ExtractionCandidate, minPrintable float64) []reportedCandidate {
+	sort.SliceStable(reported, func(i, j int) bool { return reported[i].candidate.Score > reported[j].candidate.Score })
+	var out []reportedCandidate
+	seen := [][]byte{best.Data}
+	for _, r := range reported {
+		c := r.candidate
+		if c == best || c.Score < minAlternateScore || !looksLikePayload(c.Data, minPrintable) || containsData(seen, c.Data) {
+			continue
+		}
+		seen = append(seen, c.Data)
+		out = append(out, r)
+		if len(out) == maxAlternates {
+			break
 		}
 	}
+	return out
+}
 
-	// Add the final partial byte if there is one
-	if bitIndex > 0 {
-		result = append(result, currentByte)
+// containsData reports whether data equals one of seen
+func containsData(seen [][]byte, data []byte) bool {
+	for _, s := range seen {
+		if bytes.Equal(s, data) {
+			return true
+		}
 	}
+	return false
+}
 
-	score := evaluateExtraction(result)
-	return &ExtractionCandidate{
-		Data:   result,
-		Method: "sequential-rgb",
-		Score:  score,
+// saveCandidate determines the file type of a candidate's data and saves it.
+// In streaming mode only the head of the stream is in memory, so the whole
+// stream is written straight to its output file.
+func saveCandidate(ctx context.Context, img image.Image, r reportedCandidate, options extractor.ExtractionOptions) (*models.ExtractionResult, error) {
+	var result *models.ExtractionResult
+	var err error
+	if r.candidate.Partial {
+		result, err = streamExtractedData(ctx, img, r.candidate, r.write, options)
+	} else {
+		result, err = processExtractedData(r.candidate, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Details["alternate_variant"] = r.variant != nil
+	if r.variant != nil {
+		result.Details["channel_order"] = r.variant.channelOrder()
+		result.Details["bit_order"] = r.variant.order.String()
+	}
+	if r.offset > 0 {
+		result.Details["pixel_offset"] = r.offset
 	}
+	return result, nil
 }
 
-// extractSequentialRGBA extracts LSB data sequentially from R, G, B, A channels
-func extractSequentialRGBA(img image.Image) *ExtractionCandidate {
-	bounds := img.Bounds()
-	width, height := bounds.Dx(), bounds.Dy()
-
-	// Pre-allocate result buffer
-	maxBytes := (width * height * 4) / 8
-	if maxBytes > MaxExtractSize {
-		maxBytes = MaxExtractSize
+// traceAttempt passes the outcome of a method to options.Trace, if set
+func traceAttempt(options extractor.ExtractionOptions, name string, outcome methodOutcome, reported bool) {
+	if options.Trace == nil {
+		return
 	}
-	result := make([]byte, 0, maxBytes)
-
-	var currentByte byte = 0
-	bitIndex := 0
+	options.Trace(newAttempt(name, outcome, reported))
+}
 
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, a := img.At(x, y).RGBA()
+// newAttempt describes the outcome of a method
+func newAttempt(name string, outcome methodOutcome, reported bool) extractor.Attempt {
+	attempt := extractor.Attempt{Method: name, Reported: reported, Skipped: outcome.skipped}
+	if candidate := outcome.candidate; candidate != nil {
+		attempt.Bytes = len(candidate.Data)
+		attempt.Printable = stats.PrintableRatio(candidate.Data)
+		attempt.Entropy = stats.ComputeEntropy(candidate.Data)
+		attempt.Score = candidate.Score
+		attempt.Header = candidate.Header
+	}
+	return attempt
+}
 
-			// Extract 1 bit from each channel, including alpha
-			pixels := []uint32{r, g, b, a}
-			for _, p := range pixels {
-				bit := byte(p>>8) & 1
-				currentByte |= bit << uint(7-bitIndex)
-				bitIndex++
+// BitOrder is the order in which extracted bits fill a byte. It is independent of
+// which bit of a channel value is read.
+type BitOrder int
 
-				if bitIndex == 8 {
-					result = append(result, currentByte)
-					currentByte = 0
-					bitIndex = 0
-
-					if len(result) >= maxBytes {
-						score := evaluateExtraction(result)
-						return &ExtractionCandidate{
-							Data:   result,
-							Method: "sequential-rgba",
-							Score:  score,
-						}
-					}
-				}
-			}
-		}
-	}
+const (
+	MSBFirst BitOrder = iota // The first bit read becomes the byte's most significant bit
+	LSBFirst                 // The first bit read becomes the byte's least significant bit
+)
 
-	if bitIndex > 0 {
-		result = append(result, currentByte)
+// String returns "msb-first" or "lsb-first"
+func (o BitOrder) String() string {
+	if o == LSBFirst {
+		return "lsb-first"
 	}
+	return "msb-first"
+}
 
-	score := evaluateExtraction(result)
-	return &ExtractionCandidate{
-		Data:   result,
-		Method: "sequential-rgba",
-		Score:  score,
+// set returns current with bit placed at position index (0-7) of the byte being
+// assembled
+func (o BitOrder) set(current byte, index int, bit byte) byte {
+	if o == LSBFirst {
+		return current | bit<<uint(index)
 	}
+	return current | bit<<uint(7-index)
 }
 
-// extractSequentialR extracts LSB data from the R channel only
-func extractSequentialR(img image.Image) *ExtractionCandidate {
-	return extractSingleChannel(img, 0, "sequential-r")
+// bitLayout describes which bits an alternate variant reads and how it packs them
+type bitLayout struct {
+	name     string
+	channels []int    // 0-3 for R, G, B, A, in the order they are read
+	bit      uint     // Bit position within the 8-bit channel value
+	order    BitOrder // How the extracted bits fill a byte
+	planes   bool     // Read the RGB planes one after another instead of per pixel
 }
 
-// extractSequentialG extracts LSB data from the G channel only
-func extractSequentialG(img image.Image) *ExtractionCandidate {
-	return extractSingleChannel(img, 1, "sequential-g")
+// channelOrder returns the layout's channels as letters, such as "BGR"
+func (l bitLayout) channelOrder() string {
+	order := make([]byte, len(l.channels))
+	for i, c := range l.channels {
+		order[i] = "RGBA"[c]
+	}
+	return string(order)
 }
 
-// extractSequentialB extracts LSB data from the B channel only
-func extractSequentialB(img image.Image) *ExtractionCandidate {
-	return extractSingleChannel(img, 2, "sequential-b")
+// method returns the extraction method that reads the layout
+func (l bitLayout) method() extractionMethod {
+	if l.planes {
+		return extractionMethod{
+			name: l.name,
+			run: func(ctx context.Context, img image.Image) *ExtractionCandidate {
+				return extractPlanes(ctx, img, l.order, l.name)
+			},
+			memory: planesMemory,
+			write: func(ctx context.Context, img image.Image, limit int, w io.ByteWriter) int {
+				return writePlanes(ctx, img, l.order, limit, w)
+			},
+		}
+	}
+	return extractionMethod{
+		name: l.name,
+		run: func(ctx context.Context, img image.Image) *ExtractionCandidate {
+			return extractChannelBits(ctx, img, l.channels, l.bit, l.order, l.name)
+		},
+		memory: streamMemory(len(l.channels)),
+		write: func(ctx context.Context, img image.Image, limit int, w io.ByteWriter) int {
+			return writeChannelBits(ctx, img, l.channels, l.bit, l.order, limit, w)
+		},
+	}
 }
 
-// extractSingleChannel extracts LSB data from a single color channel
-func extractSingleChannel(img image.Image, channel int, methodName string) *ExtractionCandidate {
-	bounds := img.Bounds()
-	width, height := bounds.Dx(), bounds.Dy()
-
-	// Pre-allocate result buffer
-	maxBytes := (width * height) / 8
-	if maxBytes > MaxExtractSize {
-		maxBytes = MaxExtractSize
+// layoutMethods returns the methods for a list of layouts. In streaming mode
+// they only extract the head of each stream.
+func layoutMethods(layouts []bitLayout, stream bool) []extractionMethod {
+	methods := make([]extractionMethod, len(layouts))
+	for i, l := range layouts {
+		methods[i] = l.method()
+		if stream {
+			methods[i] = headMethod(methods[i])
+		}
 	}
-	result := make([]byte, 0, maxBytes)
+	return methods
+}
 
-	var currentByte byte = 0
-	bitIndex := 0
+// standardLayouts are the methods tried first on every image
+var standardLayouts = []bitLayout{
+	{"sequential-rgb", []int{0, 1, 2}, 0, MSBFirst, false},
+	{"sequential-rgba", []int{0, 1, 2, 3}, 0, MSBFirst, false},
+	{"sequential-r", []int{0}, 0, MSBFirst, false},
+	{"sequential-g", []int{1}, 0, MSBFirst, false},
+	{"sequential-b", []int{2}, 0, MSBFirst, false},
+	{"planes-rgb", []int{0, 1, 2}, 0, MSBFirst, true},
+}
 
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, _ := img.At(x, y).RGBA()
-
-			// Select the right channel
-			var p uint32
-			switch channel {
-			case 0:
-				p = r
-			case 1:
-				p = g
-			case 2:
-				p = b
-			}
+// alphaLayout is added to the standard methods when the analyzers hint at
+// alpha-only embedding, and is one of the alternate variants otherwise
+var alphaLayout = bitLayout{"sequential-a", []int{3}, 0, MSBFirst, false}
+
+// alternateVariants are the bit layouts tried when the standard methods fail.
+// Besides other bit positions they cover LSB-first assembly of every standard
+// method and channel orders other than RGB, such as the BGR order of Windows bitmaps.
+var alternateVariants = []bitLayout{
+	{"sequential-rgb-lsbfirst", []int{0, 1, 2}, 0, LSBFirst, false},
+	{"sequential-rgba-lsbfirst", []int{0, 1, 2, 3}, 0, LSBFirst, false},
+	{"sequential-r-lsbfirst", []int{0}, 0, LSBFirst, false},
+	{"sequential-g-lsbfirst", []int{1}, 0, LSBFirst, false},
+	{"sequential-b-lsbfirst", []int{2}, 0, LSBFirst, false},
+	{"planes-rgb-lsbfirst", []int{0, 1, 2}, 0, LSBFirst, true},
+	{"sequential-rgb-bit1", []int{0, 1, 2}, 1, MSBFirst, false},
+	{"sequential-rgba-bit1", []int{0, 1, 2, 3}, 1, MSBFirst, false},
+	alphaLayout,
+	{"sequential-bgr", []int{2, 1, 0}, 0, MSBFirst, false},
+	{"sequential-bgr-lsbfirst", []int{2, 1, 0}, 0, LSBFirst, false},
+	{"sequential-bgra", []int{2, 1, 0, 3}, 0, MSBFirst, false},
+	{"sequential-bgra-lsbfirst", []int{2, 1, 0, 3}, 0, LSBFirst, false},
+	{"sequential-argb", []int{3, 0, 1, 2}, 0, MSBFirst, false},
+	{"sequential-abgr", []int{3, 2, 1, 0}, 0, MSBFirst, false},
+	{"sequential-rbg", []int{0, 2, 1}, 0, MSBFirst, false},
+	{"sequential-grb", []int{1, 0, 2}, 0, MSBFirst, false},
+	{"sequential-gbr", []int{1, 2, 0}, 0, MSBFirst, false},
+	{"sequential-brg", []int{2, 0, 1}, 0, MSBFirst, false},
+}
 
-			// Extract LSB
-			bit := byte(p>>8) & 1
-			currentByte |= bit << uint(7-bitIndex)
-			bitIndex++
+// payloadProbeSize is how much of an extracted stream is inspected for text
+const payloadProbeSize = 64
 
-			if bitIndex == 8 {
-				result = append(result, currentByte)
-				currentByte = 0
-				bitIndex = 0
-
-				if len(result) >= maxBytes {
-					score := evaluateExtraction(result)
-					return &ExtractionCandidate{
-						Data:   result,
-						Method: methodName,
-						Score:  score,
-					}
-				}
-			}
-		}
+// looksLikePayload reports whether data starts with a known file signature or
+// with text of at least the given quality
+func looksLikePayload(data []byte, minPrintable float64) bool {
+	if len(data) > payloadProbeSize {
+		data = data[:payloadProbeSize]
 	}
+	return carve.FileSignature(data) != "" || evaluateAsText(data) >= minPrintable
+}
 
-	if bitIndex > 0 {
-		result = append(result, currentByte)
+// meetsThresholds reports whether a candidate is long enough and looks like
+// either a recognisable payload or high-entropy binary data
+func meetsThresholds(data []byte, thresholds extractor.ReportThresholds) bool {
+	if len(data) < thresholds.MinLength {
+		return false
 	}
+	return looksLikePayload(data, thresholds.MinPrintable) || stats.ComputeEntropy(data) >= thresholds.MinEntropy
+}
 
-	score := evaluateExtraction(result)
-	return &ExtractionCandidate{
-		Data:   result,
-		Method: methodName,
-		Score:  score,
-	}
+// ExtractionCandidate represents a possible extraction result with quality metrics
+type ExtractionCandidate struct {
+	Data        []byte
+	Method      string
+	Score       float64
+	FileType    string
+	TextQuality float64
+	Partial     bool   // Data is only the head of a longer stream (streaming mode)
+	Header      string // "valid", or why the length header did not validate; empty without one
 }
 
-// extractPlanesRGB extracts LSB data by collecting all bits from R channel first,
-// then G channel, then B channel
-func extractPlanesRGB(img image.Image) *ExtractionCandidate {
+// extractPlanes collects the LSBs of the R plane, then the G plane, then the B
+// plane, packing each plane's bits into bytes in the given order
+func extractPlanes(ctx context.Context, img image.Image, order BitOrder, methodName string) *ExtractionCandidate {
 	bounds := img.Bounds()
 	width, height := bounds.Dx(), bounds.Dy()
 	pixelCount := width * height
@@ -343,14 +483,15 @@ func extractPlanesRGB(img image.Image) *ExtractionCandidate {
 	bBits := make([]byte, pixelCount)
 
 	// Extract LSBs from each channel
+	pixelAt, shift := sampleReader(img)
 	i := 0
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+	for y := bounds.Min.Y; y < bounds.Max.Y && ctx.Err() == nil; y++ {
 		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, _ := img.At(x, y).RGBA()
+			r, g, b, _ := pixelAt(x, y)
 
-			rBits[i] = byte(r>>8) & 1
-			gBits[i] = byte(g>>8) & 1
-			bBits[i] = byte(b>>8) & 1
+			rBits[i] = byte(r>>shift) & 1
+			gBits[i] = byte(g>>shift) & 1
+			bBits[i] = byte(b>>shift) & 1
 
 			i++
 		}
@@ -372,7 +513,7 @@ func extractPlanesRGB(img image.Image) *ExtractionCandidate {
 
 		for i := 0; i < pixelCount; i++ {
 			bitIndex := i % 8
-			currentByte |= channel[i] << uint(7-bitIndex)
+			currentByte = order.set(currentByte, bitIndex, channel[i])
 
 			if bitIndex == 7 {
 				result = append(result, currentByte)
@@ -388,11 +529,143 @@ func extractPlanesRGB(img image.Image) *ExtractionCandidate {
 	score := evaluateExtraction(result)
 	return &ExtractionCandidate{
 		Data:   result,
-		Method: "planes-rgb",
+		Method: methodName,
 		Score:  score,
 	}
 }
 
+// extractChannelBits extracts one bit per selected channel in raster order and
+// packs the bits into bytes in the given order. It stops early, returning what it
+// has so far, when ctx is cancelled.
+func extractChannelBits(ctx context.Context, img image.Image, channels []int, bit uint, order BitOrder, methodName string) *ExtractionCandidate {
+	bounds := img.Bounds()
+	maxBytes := (bounds.Dx() * bounds.Dy() * len(channels)) / 8
+	if maxBytes > MaxExtractSize {
+		maxBytes = MaxExtractSize
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, maxBytes))
+	writeChannelBits(ctx, img, channels, bit, order, maxBytes, buf)
+	result := buf.Bytes()
+
+	return &ExtractionCandidate{
+		Data:   result,
+		Method: methodName,
+		Score:  evaluateExtraction(result),
+	}
+}
+
+// writeChannelBits is the streaming form of extractChannelBits. It writes at
+// most limit bytes to w and returns how many it wrote.
+func writeChannelBits(ctx context.Context, img image.Image, channels []int, bit uint, order BitOrder, limit int, w io.ByteWriter) int {
+	return writeChannelBitsFrom(ctx, img, channels, bit, order, 0, limit, w)
+}
+
+// writeChannelBitsFrom is like writeChannelBits but starts skip pixels into
+// the image in raster order
+func writeChannelBitsFrom(ctx context.Context, img image.Image, channels []int, bit uint, order BitOrder, skip, limit int, w io.ByteWriter) int {
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 {
+		return 0
+	}
+	written := 0
+	var currentByte byte = 0
+	bitIndex := 0
+
+	pixelAt, shift := sampleReader(img)
+	startY, startX := bounds.Min.Y+skip/bounds.Dx(), bounds.Min.X+skip%bounds.Dx()
+	for y := startY; y < bounds.Max.Y && written < limit; y++ {
+		if ctx.Err() != nil {
+			break
+		}
+		x := bounds.Min.X
+		if y == startY {
+			x = startX
+		}
+		for ; x < bounds.Max.X && written < limit; x++ {
+			r, g, b, a := pixelAt(x, y)
+			values := [4]uint32{r, g, b, a}
+
+			for _, c := range channels {
+				v := byte(values[c]>>(shift+bit)) & 1
+				currentByte = order.set(currentByte, bitIndex, v)
+				bitIndex++
+
+				if bitIndex == 8 {
+					if w.WriteByte(currentByte) != nil {
+						return written
+					}
+					written++
+					currentByte = 0
+					bitIndex = 0
+				}
+			}
+		}
+	}
+	return written
+}
+
+// terminateCandidate decides where the payload in a raw bit stream ends. A
+// configured length header takes precedence. Otherwise the stream is capped at
+// options.MaxBytes, cut at a run of options.ZeroRunLength zero bytes when that is
+// enabled, and text candidates end where their text ends. Binary data is never
+// cut at zero runs by default because real files contain them.
+func terminateCandidate(candidate *ExtractionCandidate, options extractor.ExtractionOptions, thresholds extractor.ReportThresholds) {
+	if options.LengthHeader.Enabled() {
+		applyLengthHeader(candidate, options.LengthHeader)
+		return
+	}
+
+	data := candidate.Data
+	if options.MaxBytes > 0 && len(data) > options.MaxBytes {
+		data = data[:options.MaxBytes]
+	}
+
+	if options.ZeroRunLength > 0 {
+		if i := bytes.Index(data, make([]byte, options.ZeroRunLength)); i >= 0 {
+			data = data[:i]
+		}
+	} else if carve.FileSignature(data) == "" && looksLikePayload(data, thresholds.MinPrintable) {
+		data = data[:textEnd(data)]
+	}
+
+	if len(data) != len(candidate.Data) {
+		candidate.Data = data
+		candidate.Score = evaluateExtraction(data)
+		candidate.Partial = false // The payload ends inside the extracted head
+	}
+}
+
+// textEnd returns the length of the leading run of printable UTF-8 text in data
+func textEnd(data []byte) int {
+	i := 0
+	for i < len(data) {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError || (r < 32 && r != '\t' && r != '\n' && r != '\r') || r == 127 {
+			return i
+		}
+		i += size
+	}
+	return i
+}
+
+// applyLengthHeader trims a candidate to the payload its length header declares.
+// Candidates without a valid header cannot be the payload and get a zero score.
+func applyLengthHeader(candidate *ExtractionCandidate, header extractor.LengthHeader) {
+	payload, err := header.Parse(candidate.Data)
+	if err != nil {
+		candidate.Data = nil
+		candidate.Score = 0
+		candidate.Header = err.Error()
+		return
+	}
+
+	// A header that validates is itself good evidence of a real payload
+	candidate.Data = payload
+	candidate.Header = "valid"
+	candidate.Score = evaluateExtraction(payload) + 0.2
+}
+
 // evaluateExtraction scores the quality of extracted data
 func evaluateExtraction(data []byte) float64 {
 	if len(data) == 0 {
@@ -402,7 +675,7 @@ func evaluateExtraction(data []byte) float64 {
 	score := 0.0
 
 	// Check for known file signatures
-	if detectFileSignature(data) != "" {
+	if carve.FileSignature(data) != "" {
 		score += 0.5 // Strong indicator of successful extraction
 	}
 
@@ -411,7 +684,7 @@ func evaluateExtraction(data []byte) float64 {
 	score += textScore * 0.3
 
 	// Check entropy - good steganography data often has high entropy
-	entropy := calculateDataEntropy(data)
+	entropy := stats.ComputeEntropy(data)
 
 	// Adjust score based on entropy
 	// Too low entropy might be just zeros, too high might be random noise
@@ -427,36 +700,10 @@ func evaluateExtraction(data []byte) float64 {
 	return score
 }
 
-// detectFileSignature checks if the data starts with a known file signature
-func detectFileSignature(data []byte) string {
-	if len(data) < 8 {
-		return ""
-	}
-
-	prefix := string(data[:8])
-
-	// Check for common file signatures
-	if strings.HasPrefix(prefix, pngSignature) {
-		return "png"
-	} else if strings.HasPrefix(prefix, jpgSignature) {
-		return "jpg"
-	} else if strings.HasPrefix(prefix, pdfSignature) {
-		return "pdf"
-	} else if strings.HasPrefix(prefix, zipSignature) {
-		return "zip"
-	} else if strings.HasPrefix(prefix, gifSignature) {
-		return "gif"
-	} else if strings.HasPrefix(prefix, bmpSignature) {
-		return "bmp"
-	}
-
-	return ""
-}
-
 // evaluateAsText determines if the data is likely to be text
 func evaluateAsText(data []byte) float64 {
-	// Skip evaluation if too short
-	if len(data) < 10 {
+	// How short a payload may be is left to ReportThresholds.MinLength
+	if len(data) == 0 {
 		return 0.0
 	}
 
@@ -497,34 +744,19 @@ func evaluateAsText(data []byte) float64 {
 	return textScore
 }
 
-// calculateDataEntropy calculates Shannon entropy of the data
-func calculateDataEntropy(data []byte) float64 {
+// letterRatio returns the fraction of bytes that are ASCII letters or spaces,
+// which is high for natural-language text
+func letterRatio(data []byte) float64 {
 	if len(data) == 0 {
-		return 0.0
+		return 0
 	}
-
-	// Count occurrences of each byte value
-	counts := make(map[byte]int, 256)
+	letters := 0
 	for _, b := range data {
-		counts[b]++
-	}
-
-	// Calculate entropy
-	entropy := 0.0
-	for _, count := range counts {
-		probability := float64(count) / float64(len(data))
-		entropy -= probability * (logBase2(probability))
-	}
-
-	return entropy
-}
-
-// logBase2 calculates log base 2 of a number
-func logBase2(x float64) float64 {
-	if x <= 0 {
-		return 0
+		if b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b == ' ' {
+			letters++
+		}
 	}
-	return float64(binary.Size(nil))
+	return float64(letters) / float64(len(data))
 }
 
 // calculateRepetitionPenalty detects unnatural byte repetitions
@@ -566,64 +798,41 @@ func calculateRepetitionPenalty(data []byte) float64 {
 // processExtractedData analyzes the extracted data and saves it appropriately
 func processExtractedData(candidate *ExtractionCandidate, options extractor.ExtractionOptions) (*models.ExtractionResult, error) {
 	data := candidate.Data
-	//Removed nil check as its unnecessary
-	//if data == nil || len(data) == 0 {
-	//	return nil, errors.New("no extracted data to process")
-	//}
-	// Try to detect the file type
-	fileType := detectFileSignature(data)
-
-	// Determine appropriate file extension
-	extension := "bin"
-	mimeType := "application/octet-stream"
-
-	if fileType != "" {
-		extension = fileType
-		switch fileType {
-		case "png":
-			mimeType = "image/png"
-		case "jpg":
-			mimeType = "image/jpeg"
-		case "pdf":
-			mimeType = "application/pdf"
-		case "zip":
-			mimeType = "application/zip"
-		case "gif":
-			mimeType = "image/gif"
-		case "bmp":
-			mimeType = "image/bmp"
-		}
-	} else if evaluateAsText(data) > 0.7 {
-		// Likely text data
-		extension = "txt"
-		mimeType = "text/plain"
-	}
-
-	// Create output filename
-	filename := fmt.Sprintf("extracted_%s.%s", candidate.Method, extension)
-	outputPath := filepath.Join(options.OutputDir, filename)
+	fileType, extension, mimeType := carve.SniffPayload(data)
+	outputPath := payloadPath(candidate, extension, options)
 
-	// Write the extracted data to a file
-	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+	// Write the extracted data to a file, keeping any earlier payload of the same name
+	outputPath, err := filehandler.SaveFileUnique(data, outputPath)
+	if err != nil {
 		return nil, fmt.Errorf("failed to write extracted data: %w", err)
 	}
 
-	// Create the extraction result
-	result := &models.ExtractionResult{
+	return newExtractionResult(candidate, fileType, mimeType, outputPath, len(data), stats.ComputeEntropy(data)), nil
+}
+
+// payloadPath returns the output file of a candidate
+func payloadPath(candidate *ExtractionCandidate, extension string, options extractor.ExtractionOptions) string {
+	filename := fmt.Sprintf("extracted_%s.%s", candidate.Method, extension)
+	return filepath.Join(options.OutputDir, filename)
+}
+
+// newExtractionResult describes a candidate saved to outputPath. ExtractedData
+// is the candidate's data, which is only the head of a streamed payload.
+func newExtractionResult(candidate *ExtractionCandidate, fileType, mimeType, outputPath string, size int, entropy float64) *models.ExtractionResult {
+	return &models.ExtractionResult{
 		Algorithm:     "lsb-" + candidate.Method,
 		Success:       true,
 		FileType:      fileType,
-		ExtractedData: data,
-		DataSize:      len(data),
+		ExtractedData: candidate.Data,
+		DataSize:      size,
 		Details: map[string]interface{}{
 			"extraction_method": candidate.Method,
-			"text_quality":      evaluateAsText(data),
-			"entropy":           calculateDataEntropy(data),
+			"score":             candidate.Score,
+			"text_quality":      evaluateAsText(candidate.Data),
+			"entropy":           entropy,
 		},
 		OutputFiles: []string{outputPath},
 		MimeType:    mimeType,
 		DataType:    "binary",
 	}
-
-	return result, nil
 }
@@ -0,0 +1,652 @@
+package lsb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/extractor"
+	"DeSteGo/pkg/models"
+)
+
+func TestExtractFixtures(t *testing.T) {
+	tests := []struct {
+		name  string
+		found bool // Whether the fixture's payload should be extracted
+	}{
+		{"lsb_rgb.png", true},
+		{"lsb_rgb.bmp", true},
+		{"clean.png", false},
+		{"clean.bmp", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixture, ok := fixtures.Lookup(tt.name)
+			if !ok {
+				t.Fatalf("no fixture %s", tt.name)
+			}
+			result, err := NewLSBExtractor().Extract(fixtures.Path(tt.name), extractor.ExtractionOptions{OutputDir: t.TempDir()})
+			if err != nil {
+				t.Fatalf("failed to extract: %v", err)
+			}
+
+			// No method may read text or a file out of a clean carrier's noise
+			if !tt.found {
+				minPrintable := extractor.ReportThresholds{}.WithDefaults().MinPrintable
+				for _, payload := range append([]*models.ExtractionResult{result}, result.Alternates...) {
+					if Revealed(payload.ExtractedData, minPrintable) {
+						t.Errorf("%s extracted a payload from a clean carrier: %q", payload.Algorithm, LeadingText(payload.ExtractedData))
+					}
+				}
+				return
+			}
+			// Without a length header the payload can run into carrier bytes that
+			// happen to be printable
+			if !bytes.HasPrefix(result.ExtractedData, []byte(fixture.Payload)) {
+				t.Errorf("extracted %d bytes with %s, want the %d-byte payload first", len(result.ExtractedData), result.Algorithm, len(fixture.Payload))
+			}
+			if result.Algorithm != "lsb-sequential-rgb" {
+				t.Errorf("extracted with %s, want lsb-sequential-rgb", result.Algorithm)
+			}
+		})
+	}
+}
+
+func TestExtractTranslucent(t *testing.T) {
+	// Half of the carrier's RGB least significant bits, like the LSB fixtures
+	payload := strings.Repeat("Hidden in a PNG whose pixels are only partly opaque. ", 56)
+
+	tests := []struct {
+		name          string
+		alpha         func(x, y int) uint8
+		premultiplied bool // Whether reading premultiplied values also recovers the payload
+	}{
+		{"opaque", func(x, y int) uint8 { return 255 }, true},
+		{"half transparent", func(x, y int) uint8 { return 128 }, false},
+		{"alpha gradient", func(x, y int) uint8 { return uint8(64 + y) }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The embedder writes the stored, non-premultiplied samples
+			carrier := fixtures.Carrier(128, 128, 3)
+			if err := fixtures.EmbedLSB(carrier, []byte(payload), []int{0, 1, 2}, 0); err != nil {
+				t.Fatal(err)
+			}
+			img := image.NewNRGBA(carrier.Bounds())
+			copy(img.Pix, carrier.Pix)
+			for y := 0; y < 128; y++ {
+				for x := 0; x < 128; x++ {
+					img.Pix[img.PixOffset(x, y)+3] = tt.alpha(x, y)
+				}
+			}
+			data, err := fixtures.Encode(img, "png")
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "translucent.png")
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			decoded, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Opaque images are encoded without alpha and decoded as RGBA
+			if _, ok := decoded.(*image.NRGBA); !ok && !tt.premultiplied {
+				t.Fatalf("decoded as %T, want *image.NRGBA", decoded)
+			}
+			var premultiplied bytes.Buffer
+			writeChannelBits(context.Background(), premultipliedImage{decoded}, []int{0, 1, 2}, 0, MSBFirst, len(payload), &premultiplied)
+			if recovered := premultiplied.String() == payload; recovered != tt.premultiplied {
+				t.Fatalf("premultiplied values recover the payload: %v, want %v", recovered, tt.premultiplied)
+			}
+
+			result, err := NewLSBExtractor().Extract(path, extractor.ExtractionOptions{OutputDir: t.TempDir()})
+			if err != nil {
+				t.Fatalf("failed to extract: %v", err)
+			}
+			if !bytes.HasPrefix(result.ExtractedData, []byte(payload)) || result.Algorithm != "lsb-sequential-rgb" {
+				t.Errorf("extracted %.80q with %s, want the payload first with lsb-sequential-rgb", result.ExtractedData, result.Algorithm)
+			}
+		})
+	}
+}
+
+func TestExtract16Bit(t *testing.T) {
+	payload := strings.Repeat("Hidden in the true LSB of 16-bit samples. ", 64)
+
+	tests := []struct {
+		name  string
+		alpha uint16
+	}{
+		{"opaque", 0xFFFF},      // Encoded as 16-bit RGB, decoded as RGBA64
+		{"translucent", 0x8000}, // Encoded as 16-bit RGBA, decoded as NRGBA64
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Bit 8 holds the carrier's noise and bit 0 the payload, MSB first
+			carrier := fixtures.Carrier(128, 128, 4)
+			img := image.NewNRGBA64(carrier.Bounds())
+			n := 0
+			for y := 0; y < 128; y++ {
+				for x := 0; x < 128; x++ {
+					c := carrier.RGBAAt(x, y)
+					samples := [3]uint16{uint16(c.R)<<8 | 0x5A, uint16(c.G)<<8 | 0x5A, uint16(c.B)<<8 | 0x5A}
+					for i := range samples {
+						if n < len(payload)*8 {
+							samples[i] = samples[i]&^1 | uint16(payload[n/8]>>(7-n%8)&1)
+							n++
+						}
+					}
+					img.SetNRGBA64(x, y, color.NRGBA64{R: samples[0], G: samples[1], B: samples[2], A: tt.alpha})
+				}
+			}
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, img); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "deep.png")
+			if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := NewLSBExtractor().Extract(path, extractor.ExtractionOptions{OutputDir: t.TempDir()})
+			if err != nil {
+				t.Fatalf("failed to extract: %v", err)
+			}
+			if !bytes.HasPrefix(result.ExtractedData, []byte(payload)) || result.Algorithm != "lsb-sequential-rgb" {
+				t.Errorf("extracted %.80q with %s, want the payload first with lsb-sequential-rgb", result.ExtractedData, result.Algorithm)
+			}
+		})
+	}
+}
+
+func TestExtractVariants(t *testing.T) {
+	payload := strings.Repeat("Read with a bit layout other than the standard one. ", 20)
+
+	tests := []struct {
+		name      string
+		channels  []int
+		bit       uint
+		lsbFirst  bool
+		algorithm string
+		variant   bool     // Whether an alternate variant found it
+		order     string   // Channel order reported for a variant
+		hints     []string // Algorithm hints from the analyzers
+	}{
+		{"alpha inclusive", []int{0, 1, 2, 3}, 0, false, "lsb-sequential-rgba", false, "", nil},
+		{"alpha inclusive LSB first", []int{0, 1, 2, 3}, 0, true, "lsb-sequential-rgba-lsbfirst", true, "RGBA", nil},
+		{"LSB first", []int{0, 1, 2}, 0, true, "lsb-sequential-rgb-lsbfirst", true, "RGB", nil},
+		// The standard single-channel methods read these MSB-first and find noise
+		{"red LSB first", []int{0}, 0, true, "lsb-sequential-r-lsbfirst", true, "R", nil},
+		{"blue LSB first", []int{2}, 0, true, "lsb-sequential-b-lsbfirst", true, "B", nil},
+		{"bit 1", []int{0, 1, 2}, 1, false, "lsb-sequential-rgb-bit1", true, "RGB", nil},
+		{"alpha only", []int{3}, 0, false, "lsb-sequential-a", true, "A", nil},
+		{"BGR", []int{2, 1, 0}, 0, false, "lsb-sequential-bgr", true, "BGR", nil},
+		{"BGR LSB first", []int{2, 1, 0}, 0, true, "lsb-sequential-bgr-lsbfirst", true, "BGR", nil},
+		{"GBR", []int{1, 2, 0}, 0, false, "lsb-sequential-gbr", true, "GBR", nil},
+		{"ABGR", []int{3, 2, 1, 0}, 0, false, "lsb-sequential-abgr", true, "ABGR", nil},
+		// The analyzers' lsb-alpha hint makes alpha-only a standard method
+		{"alpha only, hinted", []int{3}, 0, false, "lsb-sequential-a", false, "", []string{"lsb-alpha"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embedded := []byte(payload)
+			if tt.lsbFirst {
+				embedded = bytes.Clone(embedded)
+				for i, b := range embedded {
+					embedded[i] = bits.Reverse8(b)
+				}
+			}
+			carrier := fixtures.Carrier(128, 128, 5)
+			if err := fixtures.EmbedLSB(carrier, embedded, tt.channels, tt.bit); err != nil {
+				t.Fatal(err)
+			}
+			// Stored as NRGBA, since alpha below 255 would make RGBA invalid
+			img := image.NewNRGBA(carrier.Bounds())
+			copy(img.Pix, carrier.Pix)
+			data, err := fixtures.Encode(img, "png")
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "variant.png")
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := NewLSBExtractor().Extract(path, extractor.ExtractionOptions{OutputDir: t.TempDir(), AlgorithmHints: tt.hints})
+			if err != nil {
+				t.Fatalf("failed to extract: %v", err)
+			}
+			if !bytes.HasPrefix(result.ExtractedData, []byte(payload)) || result.Algorithm != tt.algorithm {
+				t.Errorf("extracted %.60q with %s, want the payload first with %s", result.ExtractedData, result.Algorithm, tt.algorithm)
+			}
+			if variant := result.Details["alternate_variant"]; variant != tt.variant {
+				t.Errorf("got alternate_variant %v, want %v", variant, tt.variant)
+			}
+			if !tt.variant {
+				return
+			}
+			wantOrder := "msb-first"
+			if tt.lsbFirst {
+				wantOrder = "lsb-first"
+			}
+			if result.Details["channel_order"] != tt.order || result.Details["bit_order"] != wantOrder {
+				t.Errorf("got channel order %v and bit order %v, want %s and %s",
+					result.Details["channel_order"], result.Details["bit_order"], tt.order, wantOrder)
+			}
+		})
+	}
+}
+
+// premultipliedImage hides the concrete type of an image, so it is read
+// through the alpha-premultiplied values of its colors
+type premultipliedImage struct {
+	image.Image
+}
+
+func TestExtractLengthHeader(t *testing.T) {
+	// More text follows the payload, so that only the header can tell where
+	// it ends
+	payload := []byte("The payload ends after this sentence.")
+	const trailer = " This text is not part of it."
+
+	tests := []struct {
+		name   string
+		header []byte // Written in front of the payload
+		config extractor.LengthHeader
+	}{
+		{"32-bit big-endian", binary.BigEndian.AppendUint32(nil, uint32(len(payload))), extractor.LengthHeader{Size: 4}},
+		{"32-bit little-endian", binary.LittleEndian.AppendUint32(nil, uint32(len(payload))), extractor.LengthHeader{Size: 4, ByteOrder: binary.LittleEndian}},
+		{"16-bit big-endian", binary.BigEndian.AppendUint16(nil, uint16(len(payload))), extractor.LengthHeader{Size: 2}},
+		{"16-bit little-endian", binary.LittleEndian.AppendUint16(nil, uint16(len(payload))), extractor.LengthHeader{Size: 2, ByteOrder: binary.LittleEndian}},
+		{"magic and 16-bit", append([]byte("STG"), binary.BigEndian.AppendUint16(nil, uint16(len(payload)))...), extractor.LengthHeader{Size: 2, Magic: []byte("STG")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := fixtures.Carrier(64, 64, 4)
+			if err := fixtures.EmbedLSB(img, append(append(tt.header, payload...), trailer...), []int{0, 1, 2}, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := NewLSBExtractor().ExtractFromImage(img, extractor.ExtractionOptions{OutputDir: t.TempDir(), LengthHeader: tt.config})
+			if err != nil {
+				t.Fatalf("failed to extract: %v", err)
+			}
+			if !bytes.Equal(result.ExtractedData, payload) {
+				t.Errorf("extracted %q with %s, want exactly the %d-byte payload", result.ExtractedData, result.Algorithm, len(payload))
+			}
+		})
+	}
+}
+
+func TestExtractMinLength(t *testing.T) {
+	tests := []struct {
+		name      string
+		payload   string
+		minLength int // 0 keeps the default of 10 bytes
+		reported  bool
+	}{
+		{"short payload, default", "run it", 0, false},
+		{"short payload, lowered", "run it", 6, true},
+		{"short payload, lowered too little", "run it", 7, false},
+		{"long payload, default", "run it at noon", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The length header ends the payload exactly, so only its length decides
+			img := fixtures.Carrier(64, 64, 4)
+			data := append(binary.BigEndian.AppendUint32(nil, uint32(len(tt.payload))), tt.payload...)
+			if err := fixtures.EmbedLSB(img, data, []int{0, 1, 2}, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := NewLSBExtractor().ExtractFromImage(img, extractor.ExtractionOptions{
+				OutputDir:    t.TempDir(),
+				LengthHeader: extractor.LengthHeader{Size: 4},
+				Thresholds:   extractor.ReportThresholds{MinLength: tt.minLength},
+			})
+			reported := err == nil && string(result.ExtractedData) == tt.payload
+			if reported != tt.reported {
+				t.Errorf("payload reported %v, want %v (error %v)", reported, tt.reported, err)
+			}
+		})
+	}
+}
+
+func TestExtractZeroRun(t *testing.T) {
+	// A gzip stream whose body holds a 20-byte zero run, as binary files do
+	payload := append([]byte("\x1f\x8b\x08\x00"), fixtures.RandomPayload(200, 1)...)
+	payload = append(payload, make([]byte, 20)...)
+	payload = append(payload, fixtures.RandomPayload(200, 2)...)
+
+	tests := []struct {
+		name    string
+		options extractor.ExtractionOptions
+		header  bool // Whether a 32-bit length header precedes the payload
+		want    []byte
+		exact   bool // Whether the extracted data must be exactly want rather than start with it
+	}{
+		{"default", extractor.ExtractionOptions{}, false, payload, false},
+		{"zero run terminator", extractor.ExtractionOptions{ZeroRunLength: 20}, false, payload[:204], true},
+		{"longer zero run terminator", extractor.ExtractionOptions{ZeroRunLength: 21}, false, payload, false},
+		{"max bytes", extractor.ExtractionOptions{MaxBytes: 300}, false, payload[:300], true},
+		{"length header", extractor.ExtractionOptions{LengthHeader: extractor.LengthHeader{Size: 4}}, true, payload, true},
+		{"length header and zero run terminator", extractor.ExtractionOptions{LengthHeader: extractor.LengthHeader{Size: 4}, ZeroRunLength: 20}, true, payload, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := payload
+			if tt.header {
+				data = append(binary.BigEndian.AppendUint32(nil, uint32(len(payload))), payload...)
+			}
+			img := fixtures.Carrier(64, 64, 4)
+			if err := fixtures.EmbedLSB(img, data, []int{0, 1, 2}, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.options.OutputDir = t.TempDir()
+			result, err := NewLSBExtractor().ExtractFromImage(img, tt.options)
+			if err != nil {
+				t.Fatalf("failed to extract: %v", err)
+			}
+			got := result.ExtractedData
+			if !tt.exact && len(got) > len(tt.want) {
+				got = got[:len(tt.want)]
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("extracted %d bytes with %s, want %d bytes of the payload", len(result.ExtractedData), result.Algorithm, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestLengthHeaderParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		header extractor.LengthHeader
+		data   []byte
+		want   string // Expected payload, empty when parsing fails
+	}{
+		{"disabled", extractor.LengthHeader{}, []byte("raw"), "raw"},
+		{"little-endian", extractor.LengthHeader{Size: 2, ByteOrder: binary.LittleEndian}, []byte("\x03\x00abcdef"), "abc"},
+		{"big-endian read as little-endian", extractor.LengthHeader{Size: 2, ByteOrder: binary.LittleEndian}, []byte("\x00\x03abcdef"), ""},
+		{"length past the data", extractor.LengthHeader{Size: 4}, []byte("\x00\x00\x01\x00abc"), ""},
+		{"missing magic", extractor.LengthHeader{Size: 1, Magic: []byte("MG")}, []byte("\x03abc"), ""},
+		{"short", extractor.LengthHeader{Size: 4}, []byte("\x00\x00"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := tt.header.Parse(tt.data)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("parsed %q, want an error", payload)
+				}
+				return
+			}
+			if err != nil || string(payload) != tt.want {
+				t.Errorf("parsed %q (%v), want %q", payload, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractCancel(t *testing.T) {
+	// Random LSBs make the extractor try every stage, which takes a while on
+	// an image of this size
+	img := fixtures.Carrier(800, 800, 5)
+	if err := fixtures.EmbedLSB(img, fixtures.RandomPayload(800*800*3/8, 5), []int{0, 1, 2}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	NewLSBExtractor().ExtractFromImage(img, extractor.ExtractionOptions{OutputDir: t.TempDir()})
+	full := time.Since(start)
+
+	options := extractor.ExtractionOptions{OutputDir: t.TempDir()}
+	for _, delay := range []time.Duration{0, full / 10, full / 2} {
+		ctx, cancel := context.WithCancel(context.Background())
+		timer := time.AfterFunc(delay, cancel)
+		start := time.Now()
+		result, err := NewLSBExtractor().ExtractFromImageContext(ctx, img, options)
+		elapsed := time.Since(start)
+		timer.Stop()
+		cancel()
+
+		if !errors.Is(err, context.Canceled) || result != nil {
+			t.Errorf("cancelled after %v: got %v, %v, want no result and context.Canceled", delay, result, err)
+		}
+		// The methods check the context between rows, so the extraction stops
+		// soon after it is cancelled
+		if elapsed > delay+full/4+50*time.Millisecond {
+			t.Errorf("cancelled after %v, returned after %v; a full extraction takes %v", delay, elapsed, full)
+		}
+	}
+
+	entries, err := os.ReadDir(options.OutputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("cancelled extractions wrote %d files", len(entries))
+	}
+}
+
+func TestExtractTrace(t *testing.T) {
+	var standard, all []string
+	for _, l := range standardLayouts {
+		standard = append(standard, l.name)
+	}
+	all = append(all, standard...)
+	for _, l := range alternateVariants {
+		all = append(all, l.name)
+	}
+
+	tests := []struct {
+		fixture string
+		methods []string // Methods the trace lists, each once
+	}{
+		// The standard methods find the payload, so no variant is tried
+		{"lsb_rgb.png", standard},
+		{"clean.png", all},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			var attempts []extractor.Attempt
+			options := extractor.ExtractionOptions{
+				OutputDir: t.TempDir(),
+				Trace:     func(a extractor.Attempt) { attempts = append(attempts, a) },
+			}
+			if _, err := NewLSBExtractor().Extract(fixtures.Path(tt.fixture), options); err != nil {
+				t.Fatalf("failed to extract: %v", err)
+			}
+
+			var traced []string
+			for _, a := range attempts {
+				traced = append(traced, a.Method)
+				if a.Skipped == "" && a.Bytes == 0 {
+					t.Errorf("attempt %+v gives no output size", a)
+				}
+			}
+			if strings.Join(traced, ",") != strings.Join(tt.methods, ",") {
+				t.Errorf("got trace %q, want %q", traced, tt.methods)
+			}
+		})
+	}
+}
+
+// countingWriter counts the bytes written to it without keeping them
+type countingWriter struct {
+	n int
+}
+
+func (w *countingWriter) WriteByte(byte) error {
+	w.n++
+	return nil
+}
+
+func TestStreamMemory(t *testing.T) {
+	const size = 512
+	img := fixtures.Carrier(size, size, 8)
+	payload := fixtures.RandomPayload(size*size*3/8, 8)
+	if err := fixtures.EmbedLSB(img, payload, []int{0, 1, 2}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, l := range append(standardLayouts, alternateVariants...) {
+		t.Run(l.name, func(t *testing.T) {
+			var out countingWriter
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			n := l.method().write(context.Background(), img, MaxExtractSize, &out)
+			runtime.ReadMemStats(&after)
+
+			want := size * size * len(l.channels) / 8
+			if n != want || out.n != want {
+				t.Errorf("wrote %d bytes (%d counted), want the whole %d-byte stream", n, out.n, want)
+			}
+			// The stream is not held in memory, whatever its size
+			if allocated := after.TotalAlloc - before.TotalAlloc; allocated > streamHeadSize {
+				t.Errorf("allocated %d bytes streaming %d bytes, want at most %d", allocated, n, streamHeadSize)
+			}
+		})
+	}
+
+	// The extracted payload keeps only the head; the file has all of it
+	data, err := fixtures.Encode(img, "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "large.png")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := NewLSBExtractor().Extract(path, extractor.ExtractionOptions{OutputDir: t.TempDir(), Stream: true})
+	if err != nil {
+		t.Fatalf("failed to extract: %v", err)
+	}
+	written, err := os.ReadFile(result.OutputFiles[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.ExtractedData) > streamHeadSize || result.DataSize != len(payload) || !bytes.Equal(written, payload) {
+		t.Errorf("got %d bytes in memory and %d of %d on disk with %s, want at most %d in memory and the payload on disk",
+			len(result.ExtractedData), len(written), result.DataSize, result.Algorithm, streamHeadSize)
+	}
+}
+
+func TestExtractOffset(t *testing.T) {
+	payload := strings.Repeat("Starts some pixels into the image. ", 20)
+
+	tests := []struct {
+		name      string
+		offset    int // Pixels before the payload
+		channels  []int
+		algorithm string // Empty when the payload should not be found
+	}{
+		{"one pixel in", 1, []int{0, 1, 2}, "lsb-sequential-rgb-offset1"},
+		{"100 pixels in", 100, []int{0, 1, 2}, "lsb-sequential-rgb-offset100"},
+		{"1000 pixels in, red", 1000, []int{0}, "lsb-sequential-r-offset1000"},
+		{"past the sweep", maxOffsetPixels + 100, []int{0, 1, 2}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			carrier := fixtures.Carrier(128, 128, 5)
+			// The pixels from the offset on, as one row in raster order
+			rest := &image.RGBA{Pix: carrier.Pix[tt.offset*4:], Stride: len(carrier.Pix) - tt.offset*4, Rect: image.Rect(0, 0, 128*128-tt.offset, 1)}
+			if err := fixtures.EmbedLSB(rest, []byte(payload), tt.channels, 0); err != nil {
+				t.Fatal(err)
+			}
+			img := image.NewNRGBA(carrier.Bounds())
+			copy(img.Pix, carrier.Pix)
+			data, err := fixtures.Encode(img, "png")
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "offset.png")
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := NewLSBExtractor().Extract(path, extractor.ExtractionOptions{OutputDir: t.TempDir()})
+			if tt.algorithm == "" {
+				if err == nil && bytes.Contains(result.ExtractedData, []byte(payload[:40])) {
+					t.Errorf("extracted the payload with %s, want it missed", result.Algorithm)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to extract: %v", err)
+			}
+			if !bytes.HasPrefix(result.ExtractedData, []byte(payload)) || result.Algorithm != tt.algorithm {
+				t.Errorf("extracted %.60q with %s, want the payload first with %s", result.ExtractedData, result.Algorithm, tt.algorithm)
+			}
+			if offset := result.Details["pixel_offset"]; offset != tt.offset {
+				t.Errorf("got pixel_offset %v, want %d", offset, tt.offset)
+			}
+		})
+	}
+}
+
+func TestExtractAlternates(t *testing.T) {
+	red := strings.Repeat("The red channel carries this message. ", 20)
+	blue := strings.Repeat("A second message rides in the blue LSBs. ", 20)
+
+	tests := []struct {
+		name     string
+		payloads map[int]string // Payload embedded in each channel
+		want     []int          // Channels of the best payload and the alternates, in order
+	}{
+		{"red only", map[int]string{0: red}, []int{0}},
+		{"red and blue", map[int]string{0: red, 2: blue}, []int{0, 2}},
+		// The same bits read through another channel are not a second payload
+		{"same message in red and blue", map[int]string{0: red, 2: red}, []int{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			carrier := fixtures.Carrier(128, 128, 6)
+			for channel, payload := range tt.payloads {
+				if err := fixtures.EmbedLSB(carrier, []byte(payload), []int{channel}, 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+			data, err := fixtures.Encode(carrier, "png")
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "channels.png")
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := NewLSBExtractor().Extract(path, extractor.ExtractionOptions{OutputDir: t.TempDir()})
+			if err != nil {
+				t.Fatalf("failed to extract: %v", err)
+			}
+			payloads := append([]*models.ExtractionResult{result}, result.Alternates...)
+			if len(payloads) != len(tt.want) {
+				t.Fatalf("got %d payloads, want %d", len(payloads), len(tt.want))
+			}
+			for i, payload := range payloads {
+				channel := tt.want[i]
+				algorithm := "lsb-sequential-" + "rgb"[channel:channel+1]
+				if payload.Algorithm != algorithm || !bytes.HasPrefix(payload.ExtractedData, []byte(tt.payloads[channel])) {
+					t.Errorf("payload %d: got %.40q with %s, want %.40q with %s", i, payload.ExtractedData, payload.Algorithm, tt.payloads[channel], algorithm)
+				}
+			}
+		})
+	}
+}
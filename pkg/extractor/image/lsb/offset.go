@@ -0,0 +1,94 @@
+package lsb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+)
+
+/*
+This file contains the offset sweep. Some tools reserve the first pixels of an
+image, for a header of their own or to keep clear of the corner, and start the
+payload further in. Reading from the top left then gives noise followed by the
+payload at an arbitrary bit position, which no byte boundary lines up with. When
+neither the standard methods nor the alternate variants find a recognisable
+payload, the head of each sequential stream is searched for the first pixel
+offset where a file signature or text starts, and the layout is extracted again
+from that pixel.
+*/
+
+// maxOffsetPixels is the furthest pixel offset the sweep tries
+const maxOffsetPixels = 4096
+
+// offsetMethods sweeps the start offset of the sequential layouts and returns
+// a method for each layout whose stream holds a payload some pixels in,
+// together with the offsets found. In streaming mode the methods only extract
+// the head of each stream.
+func offsetMethods(ctx context.Context, img image.Image, layouts []bitLayout, minPrintable float64, stream bool) ([]extractionMethod, []int) {
+	bounds := img.Bounds()
+	sweep := min(maxOffsetPixels, bounds.Dx()*bounds.Dy()-1)
+
+	var methods []extractionMethod
+	var offsets []int
+	probe := make([]byte, payloadProbeSize)
+	for _, l := range layouts {
+		// Shifting the packed stream by whole pixels only works for MSB-first packing
+		if l.planes || l.order != MSBFirst || ctx.Err() != nil {
+			continue
+		}
+		size := (sweep*len(l.channels)+7)/8 + payloadProbeSize + 1
+		buf := bytes.NewBuffer(make([]byte, 0, size))
+		writeChannelBits(ctx, img, l.channels, l.bit, l.order, size, buf)
+		head := buf.Bytes()
+
+		for skip := 1; skip <= sweep; skip++ {
+			if shiftBits(head, skip*len(l.channels), probe) < payloadProbeSize {
+				break
+			}
+			if looksLikePayload(probe, minPrintable) {
+				methods = append(methods, offsetMethod(l, skip, stream))
+				offsets = append(offsets, skip)
+				break
+			}
+		}
+	}
+	return methods, offsets
+}
+
+// offsetMethod returns the method reading layout l from skip pixels in
+func offsetMethod(l bitLayout, skip int, stream bool) extractionMethod {
+	name := fmt.Sprintf("%s-offset%d", l.name, skip)
+	write := func(ctx context.Context, img image.Image, limit int, w io.ByteWriter) int {
+		return writeChannelBitsFrom(ctx, img, l.channels, l.bit, l.order, skip, limit, w)
+	}
+	m := extractionMethod{
+		name: name,
+		run: func(ctx context.Context, img image.Image) *ExtractionCandidate {
+			bounds := img.Bounds()
+			limit := min(max(bounds.Dx()*bounds.Dy()-skip, 0)*len(l.channels)/8, MaxExtractSize)
+			buf := bytes.NewBuffer(make([]byte, 0, limit))
+			write(ctx, img, limit, buf)
+			data := buf.Bytes()
+			return &ExtractionCandidate{Data: data, Method: name, Score: evaluateExtraction(data)}
+		},
+		memory: streamMemory(len(l.channels)),
+		write:  write,
+	}
+	if stream {
+		m = headMethod(m)
+	}
+	return m
+}
+
+// shiftBits fills out with the MSB-first bytes of data starting at the given
+// bit offset and returns how many it filled
+func shiftBits(data []byte, bit int, out []byte) int {
+	start, shift := bit/8, uint(bit%8)
+	n := 0
+	for ; n < len(out) && start+n+1 < len(data); n++ {
+		out[n] = data[start+n]<<shift | data[start+n+1]>>(8-shift)
+	}
+	return n
+}
@@ -0,0 +1,157 @@
+package lsb
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"runtime"
+	"sync"
+)
+
+/*
+This file contains the runner that executes the LSB extraction methods. Every
+method walks the whole image and builds its own output buffer, so the methods
+run on a bounded pool of workers. A shared memory budget limits how much buffer
+space the running methods may reserve together: a method waits until enough
+budget is free, and a method that could never fit is skipped. Candidates are
+returned in method order, so the caller picks the same best candidate however
+many workers ran.
+*/
+
+// DefaultMemoryBudget is the buffer budget used when none is configured
+const DefaultMemoryBudget = 256 * 1024 * 1024 // 256MB
+
+// extractionMethod is one way of reading hidden bits out of an image
+type extractionMethod struct {
+	name   string
+	run    func(ctx context.Context, img image.Image) *ExtractionCandidate
+	memory func(pixels int) int // Upper bound on the bytes the method allocates
+	write  writeFunc            // Streams the method's full output without buffering it
+}
+
+// writeFunc writes at most limit bytes of a method's output to w and returns how
+// many it wrote
+type writeFunc func(ctx context.Context, img image.Image, limit int, w io.ByteWriter) int
+
+// streamMemory returns the memory estimate of a method that packs the given
+// number of bits per pixel into one output buffer
+func streamMemory(bitsPerPixel int) func(pixels int) int {
+	return func(pixels int) int {
+		n := pixels * bitsPerPixel / 8
+		if n > MaxExtractSize {
+			n = MaxExtractSize
+		}
+		return n
+	}
+}
+
+// planesMemory is the estimate for extractPlanes, which keeps one byte per
+// pixel for each of the three channel planes besides its output
+func planesMemory(pixels int) int {
+	return 3*pixels + streamMemory(3)(pixels)
+}
+
+// methodOutcome is the result of running one method
+type methodOutcome struct {
+	candidate *ExtractionCandidate // nil when the method was skipped
+	skipped   string               // Why the method did not run
+}
+
+// memoryBudget hands out buffer space to concurrently running methods
+type memoryBudget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int
+	used  int
+}
+
+// newMemoryBudget creates a budget of limit bytes
+func newMemoryBudget(limit int) *memoryBudget {
+	b := &memoryBudget{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// acquire reserves n bytes, waiting for running methods to release theirs. It
+// returns false without reserving anything when n exceeds the whole budget.
+func (b *memoryBudget) acquire(n int) bool {
+	if n > b.limit {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.used+n > b.limit {
+		b.cond.Wait()
+	}
+	b.used += n
+	return true
+}
+
+// release returns n bytes to the budget
+func (b *memoryBudget) release(n int) {
+	b.mu.Lock()
+	b.used -= n
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// runMethods runs methods on img with the given number of workers (0 uses one
+// per CPU) and memory budget in bytes (0 uses DefaultMemoryBudget). The
+// outcomes are in the order of methods. Methods not yet started when ctx is
+// cancelled are skipped. Progress is written to log unless it is nil.
+func runMethods(ctx context.Context, img image.Image, methods []extractionMethod, workers, budget int, log io.Writer) []methodOutcome {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(methods) {
+		workers = len(methods)
+	}
+	if budget <= 0 {
+		budget = DefaultMemoryBudget
+	}
+
+	pixels := img.Bounds().Dx() * img.Bounds().Dy()
+	memory := newMemoryBudget(budget)
+	outcomes := make([]methodOutcome, len(methods))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				outcomes[i] = runMethod(ctx, img, methods[i], pixels, memory, log)
+			}
+		}()
+	}
+	for i := range methods {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return outcomes
+}
+
+// runMethod runs a single method within the memory budget
+func runMethod(ctx context.Context, img image.Image, method extractionMethod, pixels int, memory *memoryBudget, log io.Writer) methodOutcome {
+	if ctx.Err() != nil {
+		return methodOutcome{skipped: "cancelled"}
+	}
+
+	need := method.memory(pixels)
+	if !memory.acquire(need) {
+		if log != nil {
+			fmt.Fprintf(log, "Skipping extraction method %s: needs %d bytes, budget is %d\n", method.name, need, memory.limit)
+		}
+		return methodOutcome{skipped: fmt.Sprintf("needs %d bytes, over the %d byte memory budget", need, memory.limit)}
+	}
+	defer memory.release(need)
+
+	if log != nil {
+		fmt.Fprintf(log, "Trying extraction method: %s\n", method.name)
+	}
+	return methodOutcome{candidate: method.run(ctx, img)}
+}
@@ -0,0 +1,111 @@
+package lsb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/extractor"
+)
+
+func TestRunMethodsOrder(t *testing.T) {
+	img := fixtures.Carrier(16, 16, 1)
+	var running, peak int32
+	methods := make([]extractionMethod, 12)
+	for i := range methods {
+		i := i
+		delay := time.Duration(rand.Intn(5)) * time.Millisecond
+		methods[i] = extractionMethod{
+			name: fmt.Sprintf("method-%d", i),
+			run: func(ctx context.Context, img image.Image) *ExtractionCandidate {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(delay)
+				atomic.AddInt32(&running, -1)
+				return &ExtractionCandidate{Method: fmt.Sprintf("method-%d", i)}
+			},
+			memory: func(pixels int) int { return 10 },
+		}
+	}
+
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			peak = 0
+			outcomes := runMethods(context.Background(), img, methods, workers, 0, nil)
+			for i, outcome := range outcomes {
+				if outcome.candidate == nil || outcome.candidate.Method != methods[i].name {
+					t.Fatalf("outcome %d is %+v, want %s", i, outcome, methods[i].name)
+				}
+			}
+			if int(peak) > workers {
+				t.Errorf("%d methods ran at once, want at most %d", peak, workers)
+			}
+		})
+	}
+
+	t.Run("memory budget", func(t *testing.T) {
+		// A budget of 25 bytes fits two of the 10-byte methods at a time
+		peak = 0
+		runMethods(context.Background(), img, methods, 8, 25, nil)
+		if peak > 2 {
+			t.Errorf("%d methods ran at once, want at most 2", peak)
+		}
+	})
+}
+
+func TestRunMethodsOverBudget(t *testing.T) {
+	img := fixtures.Carrier(16, 16, 1)
+	methods := []extractionMethod{
+		{name: "small", run: func(context.Context, image.Image) *ExtractionCandidate { return &ExtractionCandidate{} }, memory: func(int) int { return 10 }},
+		{name: "large", run: func(context.Context, image.Image) *ExtractionCandidate { return &ExtractionCandidate{} }, memory: func(int) int { return 1000 }},
+	}
+	var log bytes.Buffer
+	outcomes := runMethods(context.Background(), img, methods, 2, 100, &log)
+	if outcomes[0].candidate == nil {
+		t.Errorf("small method was skipped: %s", outcomes[0].skipped)
+	}
+	if outcomes[1].candidate != nil || outcomes[1].skipped == "" {
+		t.Errorf("large method ran, want it skipped for exceeding the budget")
+	}
+	if !bytes.Contains(log.Bytes(), []byte("Skipping extraction method large")) {
+		t.Errorf("log does not mention the skipped method:\n%s", log.String())
+	}
+}
+
+func TestExtractWorkersAgree(t *testing.T) {
+	carriers := map[string]image.Image{
+		"clean": fixtures.TexturedCarrier(96, 96, 3),
+	}
+	embedded := fixtures.Carrier(96, 96, 4)
+	if err := fixtures.EmbedLSB(embedded, []byte("the same payload whichever worker finds it"), []int{0, 1, 2}, 0); err != nil {
+		t.Fatal(err)
+	}
+	carriers["embedded"] = embedded
+
+	for name, img := range carriers {
+		t.Run(name, func(t *testing.T) {
+			var results []string
+			for _, workers := range []int{1, 8} {
+				result, err := NewLSBExtractor().ExtractFromImage(img, extractor.ExtractionOptions{OutputDir: t.TempDir(), Workers: workers})
+				if err != nil {
+					t.Fatalf("%d workers: %v", workers, err)
+				}
+				results = append(results, fmt.Sprintf("%s %s %x", result.Algorithm, result.DataType, result.ExtractedData))
+			}
+			if results[0] != results[1] {
+				t.Errorf("sequential and concurrent runs picked different candidates:\n%.200s\n%.200s", results[0], results[1])
+			}
+		})
+	}
+}
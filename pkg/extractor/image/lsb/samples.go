@@ -0,0 +1,55 @@
+package lsb
+
+import (
+	"image"
+)
+
+/*
+This file contains how the extractor reads channel samples. The RGBA method of
+a color returns alpha-premultiplied values, so for a pixel that is not fully
+opaque it scales R, G and B by the alpha and rounds, which replaces the low
+bits an embedder wrote with bits of the product. Tools embedding into PNGs with
+transparency write the stored, non-premultiplied samples, so images that keep
+their samples that way are read from their pixel buffers instead.
+
+16-bit images store real 16-bit samples, whose LSB is bit 0 rather than the
+bit 8 that RGBA() scales an 8-bit sample's LSB to, so the reader also returns
+the shift that moves a sample's LSB to bit 0, as the analyzer's does.
+*/
+
+// sampleReader returns a function reading the R, G, B and A samples of a pixel
+// as the image stores them, scaled to 16 bits like RGBA(), and the shift that
+// moves a sample's least significant bit to bit 0
+func sampleReader(img image.Image) (func(x, y int) (r, g, b, a uint32), uint) {
+	switch m := img.(type) {
+	case *image.NRGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			s := m.Pix[m.PixOffset(x, y):]
+			return uint32(s[0]) * 0x101, uint32(s[1]) * 0x101, uint32(s[2]) * 0x101, uint32(s[3]) * 0x101
+		}, 8
+	case *image.RGBA:
+		// Read from the buffer too, since At boxes a color for every pixel
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			s := m.Pix[m.PixOffset(x, y):]
+			return uint32(s[0]) * 0x101, uint32(s[1]) * 0x101, uint32(s[2]) * 0x101, uint32(s[3]) * 0x101
+		}, 8
+	case *image.NRGBA64:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			c := m.NRGBA64At(x, y)
+			return uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A)
+		}, 0
+	case *image.RGBA64:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			c := m.RGBA64At(x, y)
+			return uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A)
+		}, 0
+	case *image.Gray16:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			v := uint32(m.Gray16At(x, y).Y)
+			return v, v, v, 0xFFFF
+		}, 0
+	}
+	return func(x, y int) (uint32, uint32, uint32, uint32) {
+		return img.At(x, y).RGBA()
+	}, 8
+}
@@ -0,0 +1,119 @@
+package lsb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"image"
+	"io"
+	"math/rand"
+)
+
+/*
+This file contains password-seeded LSB extraction. Instead of raster order, the
+pixels are visited in a pseudo-random order derived from a password, so a
+payload written in that order only lines up for someone who knows it. The
+seeded methods run alongside the standard methods when
+ExtractionOptions.Password is set.
+
+The order is DeSteGo's own scheme and does not match any other tool: steghide,
+OutGuess, OpenStego and the like derive their positions differently and also
+encrypt the payload, so their output is not recovered by these methods. The
+scheme is defined so that any program can reproduce it: the seed is the first 8
+bytes of the SHA-256 of the password read as a big-endian int64, and the order
+is rand.New(rand.NewSource(seed)).Perm(width*height) from Go's math/rand, whose
+sequence for a given seed is fixed by the Go 1 compatibility promise. Pixel
+indexes run row by row from the top left. Each visited pixel gives its R, G and
+B (or R, G, B and A) LSBs, packed most significant bit first.
+*/
+
+// seededLayouts are the channel sets read in password order
+var seededLayouts = []struct {
+	name     string
+	channels []int
+}{
+	{"seeded-rgb", []int{0, 1, 2}},
+	{"seeded-rgba", []int{0, 1, 2, 3}},
+}
+
+// seededMethods returns the password-seeded methods. In streaming mode they
+// only extract the head of each stream.
+func seededMethods(password string, stream bool) []extractionMethod {
+	seed := passwordSeed(password)
+	methods := make([]extractionMethod, len(seededLayouts))
+	for i, l := range seededLayouts {
+		write := func(ctx context.Context, img image.Image, limit int, w io.ByteWriter) int {
+			return writeSeededBits(ctx, img, seed, l.channels, limit, w)
+		}
+		m := extractionMethod{
+			name: l.name,
+			run: func(ctx context.Context, img image.Image) *ExtractionCandidate {
+				bounds := img.Bounds()
+				limit := min(bounds.Dx()*bounds.Dy()*len(l.channels)/8, MaxExtractSize)
+				buf := bytes.NewBuffer(make([]byte, 0, limit))
+				write(ctx, img, limit, buf)
+				data := buf.Bytes()
+				return &ExtractionCandidate{Data: data, Method: l.name, Score: evaluateExtraction(data)}
+			},
+			memory: func(pixels int) int {
+				return permutationMemory(pixels) + streamMemory(len(l.channels))(pixels)
+			},
+			write: write,
+		}
+		if stream {
+			m = headMethod(m)
+			// The permutation is built in full even for the head
+			m.memory = func(pixels int) int {
+				return permutationMemory(pixels) + min(streamHeadSize, streamMemory(len(l.channels))(pixels))
+			}
+		}
+		methods[i] = m
+	}
+	return methods
+}
+
+// passwordSeed derives the generator seed from a password
+func passwordSeed(password string) int64 {
+	sum := sha256.Sum256([]byte(password))
+	return int64(binary.BigEndian.Uint64(sum[:8]))
+}
+
+// permutationMemory is the size of the pixel order of an image
+func permutationMemory(pixels int) int {
+	return pixels * 8
+}
+
+// writeSeededBits writes at most limit bytes of the selected channels' LSBs,
+// visiting the pixels in the order seeded by seed, and returns how many it wrote
+func writeSeededBits(ctx context.Context, img image.Image, seed int64, channels []int, limit int, w io.ByteWriter) int {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	pixelAt, shift := sampleReader(img)
+	order := rand.New(rand.NewSource(seed)).Perm(width * bounds.Dy())
+
+	written := 0
+	var currentByte byte
+	bitIndex := 0
+	for i, index := range order {
+		if written >= limit || (i%width == 0 && ctx.Err() != nil) {
+			break
+		}
+		r, g, b, a := pixelAt(bounds.Min.X+index%width, bounds.Min.Y+index/width)
+		values := [4]uint32{r, g, b, a}
+
+		for _, c := range channels {
+			currentByte = MSBFirst.set(currentByte, bitIndex, byte(values[c]>>shift)&1)
+			bitIndex++
+			if bitIndex == 8 {
+				if w.WriteByte(currentByte) != nil {
+					return written
+				}
+				written++
+				currentByte = 0
+				bitIndex = 0
+			}
+		}
+	}
+	return written
+}
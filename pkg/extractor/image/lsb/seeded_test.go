@@ -0,0 +1,93 @@
+package lsb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+	"DeSteGo/pkg/extractor"
+)
+
+// embedSeeded writes payload into the RGB LSBs of img, visiting the pixels in
+// the order the seeded methods document for password
+func embedSeeded(img *image.NRGBA, password string, payload []byte) {
+	sum := sha256.Sum256([]byte(password))
+	seed := int64(binary.BigEndian.Uint64(sum[:8]))
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	order := rand.New(rand.NewSource(seed)).Perm(width * bounds.Dy())
+
+	bit := 0
+	for _, index := range order {
+		if bit >= len(payload)*8 {
+			return
+		}
+		x, y := bounds.Min.X+index%width, bounds.Min.Y+index/width
+		c := img.NRGBAAt(x, y)
+		channels := []*uint8{&c.R, &c.G, &c.B}
+		for _, v := range channels {
+			if bit < len(payload)*8 {
+				*v = *v&^1 | payload[bit/8]>>(7-bit%8)&1
+				bit++
+			}
+		}
+		img.SetNRGBA(x, y, c)
+	}
+}
+
+func TestExtractSeeded(t *testing.T) {
+	payload := []byte("The meeting point moved to the north pier. Bring the documents at dawn.")
+	textured := fixtures.TexturedCarrier(200, 150, 5)
+	img := image.NewNRGBA(textured.Bounds())
+	for y := 0; y < 150; y++ {
+		for x := 0; x < 200; x++ {
+			img.Set(x, y, color.NRGBAModel.Convert(textured.At(x, y)))
+		}
+	}
+	embedSeeded(img, "hunter2", payload)
+
+	tests := []struct {
+		name     string
+		password string
+		stream   bool
+		found    bool
+	}{
+		{"correct password", "hunter2", false, true},
+		{"correct password streamed", "hunter2", true, true},
+		{"wrong password", "hunter3", false, false},
+		{"no password", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := extractor.ExtractionOptions{OutputDir: t.TempDir(), Password: tt.password, Stream: tt.stream}
+			var methods []string
+			options.Trace = func(attempt extractor.Attempt) { methods = append(methods, attempt.Method) }
+			result, err := NewLSBExtractor().ExtractFromImage(img, options)
+
+			found := err == nil && bytes.Contains(result.ExtractedData, payload)
+			if found != tt.found {
+				algorithm := ""
+				if result != nil {
+					algorithm = result.Algorithm
+				}
+				t.Errorf("payload found is %v with %q (%v), want %v", found, algorithm, err, tt.found)
+			}
+			if found && result.Algorithm != "lsb-seeded-rgb" {
+				t.Errorf("extracted with %s, want lsb-seeded-rgb", result.Algorithm)
+			}
+
+			seeded := false
+			for _, method := range methods {
+				seeded = seeded || method == "seeded-rgb"
+			}
+			if seeded != (tt.password != "") {
+				t.Errorf("seeded methods ran is %v, want %v (attempts %v)", seeded, tt.password != "", methods)
+			}
+		})
+	}
+}
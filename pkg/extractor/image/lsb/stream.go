@@ -0,0 +1,144 @@
+package lsb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"os"
+
+	"DeSteGo/pkg/analyzer/carve"
+	"DeSteGo/pkg/analyzer/stats"
+	"DeSteGo/pkg/extractor"
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the streaming mode of the LSB extractor, used when
+ExtractionOptions.Stream is set. Every method first extracts only the head of
+its stream, which is enough to detect a file signature or text and to score the
+candidate. If the chosen payload runs past its head, the method is run again
+and writes the whole stream straight to the output file, counting bytes and
+byte frequencies as they pass so the entropy is known without holding the
+payload in memory. The extraction result then carries only the head in
+ExtractedData.
+*/
+
+// streamHeadSize is how much of each stream is kept in memory in streaming mode
+const streamHeadSize = 64 * 1024
+
+// streamBufferSize is the write buffer between a streaming method and its file
+const streamBufferSize = 64 * 1024
+
+// headMethod returns a method that only extracts the first streamHeadSize bytes
+// of m's stream
+func headMethod(m extractionMethod) extractionMethod {
+	return extractionMethod{
+		name: m.name,
+		run: func(ctx context.Context, img image.Image) *ExtractionCandidate {
+			buf := bytes.NewBuffer(make([]byte, 0, streamHeadSize))
+			n := m.write(ctx, img, streamHeadSize, buf)
+			data := buf.Bytes()
+			return &ExtractionCandidate{
+				Data:    data,
+				Method:  m.name,
+				Score:   evaluateExtraction(data),
+				Partial: n == streamHeadSize,
+			}
+		},
+		memory: func(pixels int) int {
+			return min(streamHeadSize, m.memory(pixels))
+		},
+		write: m.write,
+	}
+}
+
+// writePlanes is the streaming form of extractPlanes. It reads the image once
+// per plane instead of keeping the planes in memory.
+func writePlanes(ctx context.Context, img image.Image, order BitOrder, limit int, w io.ByteWriter) int {
+	bounds := img.Bounds()
+	perPlane := bounds.Dx() * bounds.Dy() / 8
+	pixelAt, shift := sampleReader(img)
+	written := 0
+
+	for channel := 0; channel < 3; channel++ {
+		var currentByte byte = 0
+		bitIndex, planeBytes := 0, 0
+		for y := bounds.Min.Y; y < bounds.Max.Y && ctx.Err() == nil; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X && planeBytes < perPlane; x++ {
+				if written >= limit {
+					return written
+				}
+				r, g, b, _ := pixelAt(x, y)
+				value := [3]uint32{r, g, b}[channel]
+				currentByte = order.set(currentByte, bitIndex, byte(value>>shift)&1)
+				bitIndex++
+
+				if bitIndex == 8 {
+					if w.WriteByte(currentByte) != nil {
+						return written
+					}
+					written++
+					planeBytes++
+					currentByte = 0
+					bitIndex = 0
+				}
+			}
+		}
+	}
+	return written
+}
+
+// payloadWriter passes bytes on to a buffered writer, counting them and their
+// frequencies
+type payloadWriter struct {
+	w      *bufio.Writer
+	counts stats.ByteCounts
+}
+
+// WriteByte implements io.ByteWriter
+func (p *payloadWriter) WriteByte(b byte) error {
+	if err := p.w.WriteByte(b); err != nil {
+		return err
+	}
+	p.counts.AddByte(b)
+	return nil
+}
+
+// streamExtractedData writes the full stream of a partial candidate to its
+// output file. The file type is sniffed from the candidate's head.
+func streamExtractedData(ctx context.Context, img image.Image, candidate *ExtractionCandidate, write writeFunc, options extractor.ExtractionOptions) (*models.ExtractionResult, error) {
+	fileType, extension, mimeType := carve.SniffPayload(candidate.Data)
+	outputPath := payloadPath(candidate, extension, options)
+
+	file, outputPath, err := filehandler.CreateUnique(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	limit := MaxExtractSize
+	if options.MaxBytes > 0 && options.MaxBytes < limit {
+		limit = options.MaxBytes
+	}
+	out := &payloadWriter{w: bufio.NewWriterSize(file, streamBufferSize)}
+	write(ctx, img, limit, out)
+
+	err = out.w.Flush()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		os.Remove(outputPath)
+		return nil, fmt.Errorf("failed to write extracted data: %w", err)
+	}
+
+	result := newExtractionResult(candidate, fileType, mimeType, outputPath, out.counts.Len(), out.counts.Entropy())
+	result.Details["streamed"] = true
+	return result, nil
+}
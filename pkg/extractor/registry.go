@@ -1,6 +1,7 @@
 package extractor
 
 import (
+	"strings"
 	"sync"
 )
 
@@ -50,6 +51,23 @@ func (r *Registry) GetExtractorByName(name string, format string) DataExtractor
 	return nil
 }
 
+// GetExtractorForAlgorithm finds the first registered extractor for the given
+// format that handles the algorithm. Algorithm names are matched case-insensitively.
+func (r *Registry) GetExtractorForAlgorithm(algorithm string, format string) DataExtractor {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, e := range r.extractors[format] {
+		for _, a := range e.SupportedAlgorithms() {
+			if strings.EqualFold(a, algorithm) {
+				return e
+			}
+		}
+	}
+
+	return nil
+}
+
 // GetSupportedFormats returns all formats that have registered extractors
 func (r *Registry) GetSupportedFormats() []string {
 	r.mu.RLock()
@@ -0,0 +1,101 @@
+package extractor
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"DeSteGo/pkg/models"
+)
+
+// fakeExtractor is a DataExtractor that only reports what it supports
+type fakeExtractor struct {
+	name       string
+	formats    []string
+	algorithms []string
+}
+
+func (f *fakeExtractor) CanExtract(format string) bool {
+	for _, supported := range f.formats {
+		if supported == format {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeExtractor) Extract(filePath string, options ExtractionOptions) (*models.ExtractionResult, error) {
+	return &models.ExtractionResult{Success: true, Algorithm: f.algorithms[0]}, nil
+}
+
+func (f *fakeExtractor) Name() string                  { return f.name }
+func (f *fakeExtractor) SupportedFormats() []string    { return f.formats }
+func (f *fakeExtractor) SupportedAlgorithms() []string { return f.algorithms }
+
+func TestRegistry(t *testing.T) {
+	lsb := &fakeExtractor{"lsb", []string{"png", "bmp"}, []string{"LSB", "lsb-planes"}}
+	trailer := &fakeExtractor{"trailer", []string{"png", "jpeg"}, []string{"appended"}}
+	jsteg := &fakeExtractor{"jsteg", []string{"jpeg"}, []string{"jsteg", "LSB"}}
+
+	registry := NewRegistry()
+	for _, e := range []DataExtractor{lsb, trailer, jsteg} {
+		registry.Register(e)
+	}
+
+	t.Run("by format", func(t *testing.T) {
+		tests := []struct {
+			format string
+			want   []string
+		}{
+			{"png", []string{"lsb", "trailer"}},
+			{"jpeg", []string{"trailer", "jsteg"}},
+			{"bmp", []string{"lsb"}},
+			{"gif", nil},
+		}
+		for _, tt := range tests {
+			var names []string
+			for _, e := range registry.GetExtractorsForFormat(tt.format) {
+				names = append(names, e.Name())
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("%s: got %v, want %v", tt.format, names, tt.want)
+			}
+		}
+	})
+
+	t.Run("by algorithm", func(t *testing.T) {
+		tests := []struct {
+			algorithm string
+			format    string
+			want      DataExtractor
+		}{
+			{"lsb", "png", lsb},
+			{"LSB-PLANES", "png", lsb},
+			{"appended", "jpeg", trailer},
+			{"lsb", "jpeg", jsteg},
+			{"jsteg", "png", nil},
+			{"f5", "jpeg", nil},
+		}
+		for _, tt := range tests {
+			got := registry.GetExtractorForAlgorithm(tt.algorithm, tt.format)
+			if got != tt.want {
+				t.Errorf("%s for %s: got %v, want %v", tt.algorithm, tt.format, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("by name", func(t *testing.T) {
+		if got := registry.GetExtractorByName("jsteg", "jpeg"); got != jsteg {
+			t.Errorf("got %v, want jsteg", got)
+		}
+		if got := registry.GetExtractorByName("jsteg", "png"); got != nil {
+			t.Errorf("got %v for a format jsteg does not support, want nil", got)
+		}
+	})
+
+	formats := registry.GetSupportedFormats()
+	sort.Strings(formats)
+	if strings.Join(formats, ",") != "bmp,jpeg,png" {
+		t.Errorf("supported formats %v, want bmp, jpeg and png", formats)
+	}
+}
@@ -0,0 +1,207 @@
+package filehandler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+/*
+This file unpacks the images inside ZIP and tar archives so that they can be
+analyzed like the files of a directory. Entries are read one at a time and
+only the images among them are written out, to a temporary directory, up to a
+limit on their total size. Entry paths are cleaned so that no entry is written
+outside that directory.
+*/
+
+// Archive formats whose images can be unpacked
+const (
+	ArchiveZIP   = "zip"
+	ArchiveTar   = "tar"
+	ArchiveTarGz = "tar.gz"
+)
+
+// DefaultArchiveLimit is the default total size of the images unpacked from an
+// archive
+const DefaultArchiveLimit = 1024 * 1024 * 1024
+
+// sniffLength is the number of leading bytes used to tell images from other
+// entries
+const sniffLength = 512
+
+// ArchiveKind returns the archive format of a file from its extension, or ""
+// when it is not a supported archive
+func ArchiveKind(filePath string) string {
+	name := strings.ToLower(filePath)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return ArchiveZIP
+	case strings.HasSuffix(name, ".tar"):
+		return ArchiveTar
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return ArchiveTarGz
+	}
+	return ""
+}
+
+// ArchiveEntry is an image unpacked from an archive
+type ArchiveEntry struct {
+	Name string // Path of the entry inside the archive
+	Path string // Path of the unpacked copy
+}
+
+// UnpackedArchive holds the images unpacked from an archive
+type UnpackedArchive struct {
+	Dir     string         // Temporary directory holding the images
+	Entries []ArchiveEntry // Unpacked images in archive order
+	Skipped int            // Images left in the archive because the size limit was reached
+
+	remaining int64
+}
+
+// UnpackImages writes the image entries of a ZIP or tar archive to a new
+// temporary directory, keeping their paths inside the archive. Entries count as
+// images by extension or by content. Once limit bytes of images have been
+// written, the remaining images are only counted. The caller removes the
+// directory with os.RemoveAll(unpacked.Dir).
+func UnpackImages(archivePath string, limit int64) (*UnpackedArchive, error) {
+	kind := ArchiveKind(archivePath)
+	if kind == "" {
+		return nil, fmt.Errorf("unsupported archive: %s", archivePath)
+	}
+
+	dir, err := os.MkdirTemp("", "destego_archive_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	unpacked := &UnpackedArchive{Dir: dir, remaining: limit}
+
+	if kind == ArchiveZIP {
+		err = unpacked.readZIP(archivePath)
+	} else {
+		err = unpacked.readTar(archivePath, kind == ArchiveTarGz)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return unpacked, nil
+}
+
+// readZIP unpacks the images of a ZIP archive
+func (u *UnpackedArchive) readZIP(archivePath string) error {
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer archive.Close()
+
+	for _, f := range archive.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		entry, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", f.Name, err)
+		}
+		err = u.add(f.Name, entry)
+		entry.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTar unpacks the images of a tar archive, gzip-compressed if gzipped is set
+func (u *UnpackedArchive) readTar(archivePath string, gzipped bool) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if gzipped {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to decompress archive: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := u.add(header.Name, archive); err != nil {
+			return err
+		}
+	}
+}
+
+// add writes an archive entry to the directory if it is an image. An image that
+// does not fit in the remaining size limit is removed again, and it and every
+// later image are counted as skipped.
+func (u *UnpackedArchive) add(name string, r io.Reader) error {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return nil
+	}
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	head = head[:n]
+	if !IsImageFile(name) {
+		if _, err := sniffFormat(head); err != nil {
+			return nil
+		}
+	}
+	if u.remaining <= 0 {
+		u.Skipped++
+		return nil
+	}
+
+	file, outPath, err := CreateUnique(filepath.Join(u.Dir, filepath.FromSlash(name)))
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(file, io.LimitReader(io.MultiReader(bytes.NewReader(head), r), u.remaining+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outPath)
+		return fmt.Errorf("failed to unpack %s: %w", name, err)
+	}
+	if written > u.remaining {
+		os.Remove(outPath)
+		u.remaining = 0
+		u.Skipped++
+		return nil
+	}
+
+	u.remaining -= written
+	u.Entries = append(u.Entries, ArchiveEntry{Name: name, Path: outPath})
+	return nil
+}
@@ -0,0 +1,128 @@
+package filehandler
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+)
+
+// archiveFile is an entry of a test archive
+type archiveFile struct {
+	name string
+	data []byte
+}
+
+// writeArchive writes files to an archive of the given kind in a temporary
+// directory and returns its path
+func writeArchive(t *testing.T, kind string, files []archiveFile) string {
+	t.Helper()
+	var buf bytes.Buffer
+	switch kind {
+	case ArchiveZIP:
+		w := zip.NewWriter(&buf)
+		for _, f := range files {
+			entry, err := w.Create(f.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entry.Write(f.data)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		var gz *gzip.Writer
+		tw := tar.NewWriter(&buf)
+		if kind == ArchiveTarGz {
+			gz = gzip.NewWriter(&buf)
+			tw = tar.NewWriter(gz)
+		}
+		for _, f := range files {
+			if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data)), Typeflag: tar.TypeReg}); err != nil {
+				t.Fatal(err)
+			}
+			tw.Write(f.data)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if gz != nil {
+			gz.Close()
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "bundle."+kind)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUnpackImages(t *testing.T) {
+	clean, err := fixtures.Load("clean.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stego, err := fixtures.Load("lsb_rgb.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []archiveFile{
+		{"clean.png", clean},
+		{"notes.txt", []byte("not an image\n")},
+		{"stego/lsb_rgb.png", stego},
+		{"../../escape", clean}, // An image by content only, with a path outside the archive
+	}
+
+	tests := []struct {
+		name    string
+		kind    string
+		limit   int64
+		want    []string // Entries expected to be unpacked
+		skipped int
+	}{
+		{"zip", ArchiveZIP, DefaultArchiveLimit, []string{"clean.png", "stego/lsb_rgb.png", "escape"}, 0},
+		{"tar", ArchiveTar, DefaultArchiveLimit, []string{"clean.png", "stego/lsb_rgb.png", "escape"}, 0},
+		{"tar.gz", ArchiveTarGz, DefaultArchiveLimit, []string{"clean.png", "stego/lsb_rgb.png", "escape"}, 0},
+		{"zip over limit", ArchiveZIP, int64(len(clean)) + 1, []string{"clean.png"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archivePath := writeArchive(t, tt.kind, files)
+			unpacked, err := UnpackImages(archivePath, tt.limit)
+			if err != nil {
+				t.Fatalf("failed to unpack: %v", err)
+			}
+			defer os.RemoveAll(unpacked.Dir)
+
+			if len(unpacked.Entries) != len(tt.want) || unpacked.Skipped != tt.skipped {
+				t.Fatalf("unpacked %+v and skipped %d, want %v and %d skipped", unpacked.Entries, unpacked.Skipped, tt.want, tt.skipped)
+			}
+			for i, entry := range unpacked.Entries {
+				if entry.Name != tt.want[i] {
+					t.Errorf("entry %d is %s, want %s", i, entry.Name, tt.want[i])
+				}
+				if entry.Path != filepath.Join(unpacked.Dir, filepath.FromSlash(entry.Name)) {
+					t.Errorf("%s unpacked to %s, outside %s", entry.Name, entry.Path, unpacked.Dir)
+				}
+				data, err := os.ReadFile(entry.Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := clean
+				if entry.Name == "stego/lsb_rgb.png" {
+					want = stego
+				}
+				if !bytes.Equal(data, want) {
+					t.Errorf("%s unpacked to %d bytes, want %d", entry.Name, len(data), len(want))
+				}
+			}
+		})
+	}
+}
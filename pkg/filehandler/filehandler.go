@@ -1,6 +1,9 @@
 package filehandler
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,10 +17,14 @@ import (
 File explanation:
 This file contains utility functions for file handling, such as detecting file formats, reading files, downloading files, and saving files.
 The DetectFileFormat function detects the format of a file by checking the extension and content type.
+The SniffFileFormat function detects the format of a file from its content only.
+Camera RAW files other than DNG are recognized by their extension and rejected with ErrUnsupportedFormat and a hint to convert them.
+The SaveStdin function copies standard input to a temporary file named after its sniffed format.
 The ReadFileBytes function reads a file and returns its content as a byte array.
 The IsURL function checks if a string is a URL.
 The DownloadFile function downloads a file from a URL and saves it to a temporary file.
 The SaveFile function saves data to a file.
+The CreateUnique and SaveFileUnique functions create a new file, adding a counter to the name instead of overwriting.
 The FilesInDirectory function returns a list of files in a directory with the given extensions.
 */
 
@@ -30,8 +37,44 @@ var SupportedImageFormats = map[string]string{
 	".bmp":  "bmp",
 	".webp": "webp",
 	".svg":  "svg",
+	".tif":  "tiff",
+	".tiff": "tiff",
+	".dng":  "dng",
 }
 
+// ErrUnsupportedFormat is returned for files in a format no analyzer reads
+var ErrUnsupportedFormat = errors.New("unsupported file format")
+
+// RawFormats maps the extensions of camera RAW formats to their names. DNG is
+// TIFF-based and analyzed, the other RAW formats are not.
+var RawFormats = map[string]string{
+	".3fr": "Hasselblad 3FR",
+	".arw": "Sony ARW",
+	".cr2": "Canon CR2",
+	".cr3": "Canon CR3",
+	".crw": "Canon CRW",
+	".erf": "Epson ERF",
+	".iiq": "Phase One IIQ",
+	".kdc": "Kodak KDC",
+	".mos": "Leaf MOS",
+	".mrw": "Minolta MRW",
+	".nef": "Nikon NEF",
+	".nrw": "Nikon NRW",
+	".orf": "Olympus ORF",
+	".pef": "Pentax PEF",
+	".raf": "Fujifilm RAF",
+	".raw": "camera RAW",
+	".rw2": "Panasonic RW2",
+	".rwl": "Leica RWL",
+	".sr2": "Sony SR2",
+	".srf": "Sony SRF",
+	".srw": "Samsung SRW",
+	".x3f": "Sigma X3F",
+}
+
+// tagDNGVersion is the TIFF tag that marks a file as a DNG
+const tagDNGVersion = 0xC612
+
 // DetectFileFormat detects the format of a file
 func DetectFileFormat(filePath string) (string, error) {
 	// First check extension
@@ -39,8 +82,17 @@ func DetectFileFormat(filePath string) (string, error) {
 	if format, ok := SupportedImageFormats[ext]; ok {
 		return format, nil
 	}
+	if name, ok := RawFormats[ext]; ok {
+		return "", fmt.Errorf("%w: %s is a camera RAW format, convert the file to DNG (Adobe DNG Converter) or to TIFF (dcraw -T) to analyze it", ErrUnsupportedFormat, name)
+	}
 
 	// If extension not recognized, try to detect by content
+	return SniffFileFormat(filePath)
+}
+
+// SniffFileFormat detects the format of a file from its content alone,
+// ignoring the extension
+func SniffFileFormat(filePath string) (string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return "", fmt.Errorf("failed to open file: %w", err)
@@ -49,10 +101,26 @@ func DetectFileFormat(filePath string) (string, error) {
 
 	// Read first 512 bytes to detect content type
 	buffer := make([]byte, 512)
-	_, err = file.Read(buffer)
-	if err != nil && err != io.EOF {
+	n, err := io.ReadFull(file, buffer)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
+	return sniffFormat(buffer[:n])
+}
+
+// sniffFormat detects the format of data from its first bytes
+func sniffFormat(buffer []byte) (string, error) {
+	if len(buffer) > 512 {
+		buffer = buffer[:512]
+	}
+
+	// http.DetectContentType does not know TIFF
+	if bytes.HasPrefix(buffer, []byte("II*\x00")) || bytes.HasPrefix(buffer, []byte("MM\x00*")) {
+		if isDNG(buffer) {
+			return "dng", nil
+		}
+		return "tiff", nil
+	}
 
 	contentType := http.DetectContentType(buffer)
 
@@ -71,8 +139,71 @@ func DetectFileFormat(filePath string) (string, error) {
 	case strings.Contains(contentType, "image/svg+xml"):
 		return "svg", nil
 	default:
-		return "", fmt.Errorf("unsupported file format: %s", contentType)
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, contentType)
+	}
+}
+
+// isDNG reports whether the first IFD of the TIFF data in buffer has a
+// DNGVersion tag. An IFD that does not fit in buffer is not looked at.
+func isDNG(buffer []byte) bool {
+	if len(buffer) < 8 {
+		return false
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if buffer[0] == 'M' {
+		order = binary.BigEndian
+	}
+	offset := int(order.Uint32(buffer[4:8]))
+	if offset < 8 || offset+2 > len(buffer) {
+		return false
+	}
+	entries := int(order.Uint16(buffer[offset:]))
+	for i := 0; i < entries; i++ {
+		entry := offset + 2 + i*12
+		if entry+2 > len(buffer) {
+			return false
+		}
+		if order.Uint16(buffer[entry:]) == tagDNGVersion {
+			return true
+		}
+	}
+	return false
+}
+
+// StdinPath is the file path that selects standard input
+const StdinPath = "-"
+
+// SaveStdin copies r, usually standard input, to "stdin.<format>" in a new
+// temporary directory so that the analyzers, which work on paths, can read it.
+// The format is sniffed from the content; unknown content is saved without an
+// extension. The caller removes the directory with os.RemoveAll(filepath.Dir(path)).
+func SaveStdin(r io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, 100*1024*1024+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read standard input: %w", err)
+	}
+	if len(data) > 100*1024*1024 {
+		return "", fmt.Errorf("input too large (max 100MB)")
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("standard input is empty")
+	}
+
+	name := "stdin"
+	if format, err := sniffFormat(data); err == nil {
+		name += "." + format
+	}
+
+	dir, err := os.MkdirTemp("", "destego_stdin_")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := SaveFile(data, path); err != nil {
+		os.RemoveAll(dir)
+		return "", err
 	}
+	return path, nil
 }
 
 // ReadFileBytes reads a file and returns its content as a byte array
@@ -175,6 +306,50 @@ func SaveFile(data []byte, filePath string) error {
 	return nil
 }
 
+// maxUniqueSuffix bounds the counter CreateUnique appends to a taken name
+const maxUniqueSuffix = 10000
+
+// CreateUnique creates a new file at path, creating missing directories. When
+// path is taken, "_1", "_2" and so on are added before the extension, so an
+// existing file is never overwritten. It returns the file and its path.
+func CreateUnique(path string) (*os.File, string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+	candidate := path
+	for i := 1; i <= maxUniqueSuffix; i++ {
+		file, err := os.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return file, candidate, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, "", fmt.Errorf("failed to create file: %w", err)
+		}
+		candidate = fmt.Sprintf("%s_%d%s", stem, i, ext)
+	}
+	return nil, "", fmt.Errorf("failed to create file: %s and %d numbered copies exist", path, maxUniqueSuffix)
+}
+
+// SaveFileUnique saves data to a new file like CreateUnique and returns its path
+func SaveFileUnique(data []byte, path string) (string, error) {
+	file, path, err := CreateUnique(path)
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write to file: %w", err)
+	}
+	return path, nil
+}
+
 // FilesInDirectory returns a list of files in a directory with the given extensions
 func FilesInDirectory(dirPath string, extensions []string) ([]string, error) {
 	var files []string
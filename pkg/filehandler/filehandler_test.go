@@ -0,0 +1,51 @@
+package filehandler
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+)
+
+func TestDetectCameraRAW(t *testing.T) {
+	dng, err := fixtures.DNG(fixtures.Carrier(32, 32, 1), fixtures.DNGRawCFA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiff := append([]byte("II*\x00\x08\x00\x00\x00"), make([]byte, 64)...)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+		reason string // Part of the error for unsupported files
+	}{
+		{"photo.dng", dng, "dng", ""},
+		{"photo.bin", dng, "dng", ""},
+		{"scan.tif.bin", tiff, "tiff", ""},
+		{"photo.CR2", tiff, "", "Canon CR2 is a camera RAW format"},
+		{"photo.nef", tiff, "", "Nikon NEF is a camera RAW format"},
+		{"notes.bin", []byte("\x00\x01\x02 not an image"), "", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.name)
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			format, err := DetectFileFormat(path)
+			if tt.reason == "" {
+				if err != nil || format != tt.format {
+					t.Errorf("detected %q (%v), want %s", format, err, tt.format)
+				}
+				return
+			}
+			if !errors.Is(err, ErrUnsupportedFormat) || !strings.Contains(err.Error(), tt.reason) {
+				t.Errorf("error %v, want ErrUnsupportedFormat saying %q", err, tt.reason)
+			}
+		})
+	}
+}
@@ -2,12 +2,18 @@ package filehandler
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 // GatherFiles collects all files in a directory (non-recursive)
@@ -50,45 +56,180 @@ func ReadLines(filePath string) ([]string, error) {
 
 // DownloadFromURL downloads a file from a URL to the specified directory
 func DownloadFromURL(url, outputDir string) (string, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
+	return downloadWithClient(http.DefaultClient, url, outputDir)
+}
+
+// DownloadOptions configures DownloadURLs
+type DownloadOptions struct {
+	Retries  int                  // Further attempts after a transient failure
+	Backoff  time.Duration        // Wait before the first retry, doubled for each one after it
+	Timeout  time.Duration        // Limit on each request, including reading the body (0: none)
+	Interval time.Duration        // Minimum time between two requests to the same host, after the burst (0: none)
+	Burst    int                  // Requests to a host that may start at once before Interval applies (default: 1)
+	Hosts    int                  // Hosts downloaded from in parallel (default: DefaultDownloadHosts)
+	OnResult func(DownloadResult) // Called as each URL finishes, if set; never concurrently
+}
+
+// DefaultDownloadHosts is how many hosts DownloadURLs downloads from at once
+// by default
+const DefaultDownloadHosts = 4
+
+// DownloadResult is the outcome of downloading one URL
+type DownloadResult struct {
+	URL      string
+	Path     string // Where the file was saved, empty on failure
+	Err      error  // Last error, nil on success
+	Attempts int    // Requests made for this URL
+}
+
+// statusError is returned for a response other than 200 OK
+type statusError struct {
+	status string
+	code   int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("bad status: %s", e.status)
+}
+
+// DownloadURLs downloads every URL into a subdirectory of outputDir named
+// after its host. The URLs of one host are downloaded one after another, paced
+// by the per-host rate limit, while different hosts are downloaded from in
+// parallel. Transient failures (network errors, 429 and 5xx responses) are
+// retried with exponential backoff; other failures are final. Every URL gets a
+// result, in input order.
+func DownloadURLs(urls []string, outputDir string, options DownloadOptions) []DownloadResult {
+	client := &http.Client{Timeout: options.Timeout}
+	limiter := newHostLimiter(options.Interval, options.Burst)
+	results := make([]DownloadResult, len(urls))
+
+	// Group the URLs by host, keeping the order of first appearance
+	var hosts []string
+	byHost := make(map[string][]int)
+	for i, url := range urls {
+		host := urlHost(url)
+		if _, ok := byHost[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		byHost[host] = append(byHost[host], i)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("bad status: %s", resp.Status)
+	workers := options.Hosts
+	if workers < 1 {
+		workers = DefaultDownloadHosts
+	}
+	var mu sync.Mutex // Serializes OnResult
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(hosts)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range jobs {
+				dir := filepath.Join(outputDir, hostDirName(host))
+				for _, i := range byHost[host] {
+					results[i] = downloadWithRetries(client, urls[i], host, dir, limiter, options)
+					if options.OnResult != nil {
+						mu.Lock()
+						options.OnResult(results[i])
+						mu.Unlock()
+					}
+				}
+			}
+		}()
 	}
+	for _, host := range hosts {
+		jobs <- host
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
 
-	// Extract filename from URL
-	urlParts := strings.Split(url, "/")
-	filename := urlParts[len(urlParts)-1]
-	if filename == "" {
-		filename = "downloaded_file"
+// downloadWithRetries downloads one URL, waiting for the host's rate limit
+// before every attempt
+func downloadWithRetries(client *http.Client, url, host, outputDir string, limiter *hostLimiter, options DownloadOptions) DownloadResult {
+	result := DownloadResult{URL: url}
+	backoff := options.Backoff
+	for {
+		limiter.Wait(host)
+		result.Attempts++
+
+		result.Path, result.Err = downloadWithClient(client, url, outputDir)
+		if result.Err == nil || result.Attempts > options.Retries || !retryable(result.Err) {
+			return result
+		}
+		time.Sleep(backoff)
+		backoff *= 2
 	}
+}
+
+// hostDirName returns the directory name for a host's downloads, replacing
+// the characters that are not allowed in file names
+func hostDirName(host string) string {
+	if host == "" {
+		return "unknown-host"
+	}
+	return strings.NewReplacer(":", "_", "/", "_", "\\", "_").Replace(host)
+}
 
-	// Create output directory if it doesn't exist
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
+// retryable reports whether a download error may go away on its own
+func retryable(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.code == http.StatusTooManyRequests || status.code >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// downloadWithClient downloads rawURL into outputDir, naming the file after the
+// last element of the URL's path. When that name is taken, a number is added
+// to it, so URLs that end in the same name keep separate files.
+func downloadWithClient(client *http.Client, rawURL, outputDir string) (string, error) {
+	resp, err := client.Get(rawURL)
+	if err != nil {
 		return "", err
 	}
+	defer resp.Body.Close()
 
-	// Create output file
-	outputPath := filepath.Join(outputDir, filename)
-	out, err := os.Create(outputPath)
+	if resp.StatusCode != http.StatusOK {
+		return "", &statusError{status: resp.Status, code: resp.StatusCode}
+	}
+
+	out, outputPath, err := CreateUnique(filepath.Join(outputDir, downloadName(rawURL)))
 	if err != nil {
 		return "", err
 	}
-	defer out.Close()
 
-	// Write the body to file
+	// Write the body to file, removing what was written if it fails so that a
+	// retry does not leave a truncated copy next to the complete one
 	_, err = io.Copy(out, resp.Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
+		os.Remove(outputPath)
 		return "", err
 	}
 
 	return outputPath, nil
 }
 
+// downloadName returns the file name for a URL's download: the last element of
+// its path, without the query string or fragment
+func downloadName(rawURL string) string {
+	name := ""
+	if u, err := url.Parse(rawURL); err == nil {
+		name = path.Base(u.Path)
+	}
+	name = strings.NewReplacer(":", "_", "\\", "_").Replace(name)
+	if name == "" || name == "." || name == "/" || name == ".." {
+		return "downloaded_file"
+	}
+	return name
+}
+
 // IsImageFile checks if a file is an image based on extension
 func IsImageFile(path string) bool {
 	ext := strings.ToLower(filepath.Ext(path))
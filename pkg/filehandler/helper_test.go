@@ -0,0 +1,110 @@
+package filehandler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDownloadURLsSameName(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+
+	urls := []string{
+		server.URL + "/a/img.png",
+		server.URL + "/b/img.png?size=large#top",
+	}
+	dir := t.TempDir()
+	results := DownloadURLs(urls, dir, DownloadOptions{})
+
+	wantNames := []string{"img.png", "img_1.png"}
+	wantBodies := []string{"/a/img.png", "/b/img.png"}
+	for i, result := range results {
+		if result.Err != nil {
+			t.Fatalf("%s: %v", result.URL, result.Err)
+		}
+		if name := filepath.Base(result.Path); name != wantNames[i] {
+			t.Errorf("%s saved as %s, want %s", result.URL, name, wantNames[i])
+		}
+		body, err := os.ReadFile(result.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != wantBodies[i] {
+			t.Errorf("%s holds %q, want %q", result.Path, body, wantBodies[i])
+		}
+	}
+}
+
+func TestDownloadName(t *testing.T) {
+	tests := []struct {
+		url  string
+		name string
+	}{
+		{"https://example.com/gallery/photo.jpg", "photo.jpg"},
+		{"https://example.com/photo.jpg?w=100&h=100", "photo.jpg"},
+		{"https://example.com/photo.jpg#main", "photo.jpg"},
+		{"https://example.com/a%3Ab.png", "a_b.png"},
+		{"https://example.com/", "downloaded_file"},
+		{"https://example.com", "downloaded_file"},
+	}
+	for _, tt := range tests {
+		if name := downloadName(tt.url); name != tt.name {
+			t.Errorf("downloadName(%q) = %q, want %q", tt.url, name, tt.name)
+		}
+	}
+}
+
+func TestDownloadURLsRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int // Response to the first failures requests
+		failures int
+		retries  int
+		attempts int
+		ok       bool
+	}{
+		{"recovers after two failures", http.StatusServiceUnavailable, 2, 2, 3, true},
+		{"too few retries", http.StatusServiceUnavailable, 2, 1, 2, false},
+		{"rate limited", http.StatusTooManyRequests, 1, 2, 2, true},
+		{"not found is final", http.StatusNotFound, 5, 2, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests <= tt.failures {
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.Write([]byte("image"))
+			}))
+			defer server.Close()
+
+			results := DownloadURLs([]string{server.URL + "/img.png"}, t.TempDir(), DownloadOptions{Retries: tt.retries, Backoff: time.Millisecond})
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			result := results[0]
+			if ok := result.Err == nil; ok != tt.ok || result.Attempts != tt.attempts || requests != tt.attempts {
+				t.Errorf("got success %v (%v) after %d attempts and %d requests, want %v after %d",
+					ok, result.Err, result.Attempts, requests, tt.ok, tt.attempts)
+			}
+			if !tt.ok {
+				if result.Path != "" {
+					t.Errorf("got path %q for a failed download", result.Path)
+				}
+				return
+			}
+			if body, err := os.ReadFile(result.Path); err != nil || string(body) != "image" {
+				t.Errorf("got %q (%v), want the downloaded image", body, err)
+			}
+		})
+	}
+}
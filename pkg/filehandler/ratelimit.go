@@ -0,0 +1,85 @@
+package filehandler
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+This file contains the per-host rate limiter of DownloadURLs. Each host has a
+token bucket that fills at one token per interval up to the burst size, and
+every request to the host takes a token. Hosts are limited independently, so a
+slow limit on one server does not hold up downloads from the others. Waiting
+requests reserve their token before they sleep, which keeps the limit when
+several goroutines share a host.
+*/
+
+// tokenBucket paces the requests to one host
+type tokenBucket struct {
+	mu       sync.Mutex
+	interval time.Duration // Time to earn one token
+	burst    int           // Tokens the bucket holds when full
+	tokens   float64       // Tokens available, negative when requests are waiting
+	last     time.Time     // When tokens was last brought up to date
+}
+
+// reserve takes a token and returns how long to wait before using it
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.last.IsZero() {
+		b.tokens = float64(b.burst)
+	} else {
+		b.tokens = min(float64(b.burst), b.tokens+float64(now.Sub(b.last))/float64(b.interval))
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens * float64(b.interval))
+}
+
+// hostLimiter keeps a token bucket per host
+type hostLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	buckets  map[string]*tokenBucket
+}
+
+// newHostLimiter returns a limiter allowing burst requests at once to a host,
+// and one more every interval after that. An interval of 0 disables it.
+func newHostLimiter(interval time.Duration, burst int) *hostLimiter {
+	return &hostLimiter{interval: interval, burst: max(burst, 1), buckets: make(map[string]*tokenBucket)}
+}
+
+// Wait blocks until a request to host is allowed
+func (l *hostLimiter) Wait(host string) {
+	if l.interval <= 0 {
+		return
+	}
+	l.mu.Lock()
+	bucket, ok := l.buckets[host]
+	if !ok {
+		bucket = &tokenBucket{interval: l.interval, burst: l.burst}
+		l.buckets[host] = bucket
+	}
+	l.mu.Unlock()
+
+	if wait := bucket.reserve(time.Now()); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// urlHost returns the lower-case host (with port) of a URL, or "" when it has none
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
@@ -0,0 +1,118 @@
+package filehandler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	start := time.Unix(1000, 0)
+	tests := []struct {
+		name  string
+		burst int
+		at    []time.Duration // When each request is made, from start
+		waits []time.Duration // Wait each request is given
+	}{
+		{"burst 1", 1, []time.Duration{0, 0, 0}, []time.Duration{0, interval, 2 * interval}},
+		{"burst 2", 2, []time.Duration{0, 0, 0, 0}, []time.Duration{0, 0, interval, 2 * interval}},
+		{"spaced out", 1, []time.Duration{0, interval, 2 * interval}, []time.Duration{0, 0, 0}},
+		{"half an interval", 1, []time.Duration{0, interval / 2}, []time.Duration{0, interval / 2}},
+		{"refill stops at burst", 2, []time.Duration{0, 10 * interval, 10 * interval, 10 * interval}, []time.Duration{0, 0, 0, interval}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := &tokenBucket{interval: interval, burst: tt.burst}
+			for i, at := range tt.at {
+				if wait := bucket.reserve(start.Add(at)); wait != tt.waits[i] {
+					t.Errorf("request %d: wait %s, want %s", i, wait, tt.waits[i])
+				}
+			}
+		})
+	}
+}
+
+// pacedServer records when each request to it arrives
+type pacedServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	arrivals []time.Time
+}
+
+func newPacedServer() *pacedServer {
+	s := &pacedServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.arrivals = append(s.arrivals, time.Now())
+		s.mu.Unlock()
+		w.Write([]byte("image data"))
+	}))
+	return s
+}
+
+func TestDownloadURLsPerHost(t *testing.T) {
+	const interval = 150 * time.Millisecond
+	const perHost = 4
+	servers := []*pacedServer{newPacedServer(), newPacedServer()}
+	for _, s := range servers {
+		defer s.Close()
+	}
+	var urls []string
+	for i := 0; i < perHost; i++ {
+		for _, s := range servers {
+			urls = append(urls, fmt.Sprintf("%s/%d.png", s.URL, i))
+		}
+	}
+
+	start := time.Now()
+	results := DownloadURLs(urls, t.TempDir(), DownloadOptions{Interval: interval})
+	elapsed := time.Since(start)
+	for _, result := range results {
+		if result.Err != nil {
+			t.Fatalf("%s: %v", result.URL, result.Err)
+		}
+	}
+
+	for i, s := range servers {
+		if len(s.arrivals) != perHost {
+			t.Fatalf("host %d got %d requests, want %d", i, len(s.arrivals), perHost)
+		}
+		// Timer slack can only make gaps longer; allow a little for scheduling
+		for j := 1; j < perHost; j++ {
+			if gap := s.arrivals[j].Sub(s.arrivals[j-1]); gap < interval*9/10 {
+				t.Errorf("host %d: requests %d and %d were %s apart, want at least %s", i, j-1, j, gap, interval)
+			}
+		}
+	}
+
+	// The hosts are paced independently, so they start together and the run
+	// takes about as long as one host's requests, not both hosts' in turn
+	if d := servers[0].arrivals[0].Sub(servers[1].arrivals[0]).Abs(); d > interval/2 {
+		t.Errorf("first requests to the two hosts were %s apart, want them in parallel", d)
+	}
+	if sequential := time.Duration(2*perHost-1) * interval; elapsed >= sequential {
+		t.Errorf("took %s, want less than the %s of one host after the other", elapsed, sequential)
+	}
+}
+
+func TestDownloadURLsBurst(t *testing.T) {
+	const interval = 200 * time.Millisecond
+	s := newPacedServer()
+	defer s.Close()
+	urls := []string{s.URL + "/0.png", s.URL + "/1.png", s.URL + "/2.png"}
+	DownloadURLs(urls, t.TempDir(), DownloadOptions{Interval: interval, Burst: 2})
+
+	if len(s.arrivals) != len(urls) {
+		t.Fatalf("got %d requests, want %d", len(s.arrivals), len(urls))
+	}
+	if gap := s.arrivals[1].Sub(s.arrivals[0]); gap > interval/2 {
+		t.Errorf("second request waited %s, want it in the burst", gap)
+	}
+	if gap := s.arrivals[2].Sub(s.arrivals[1]); gap < interval*3/4 {
+		t.Errorf("third request came %s after the second, want about %s", gap, interval)
+	}
+}
@@ -0,0 +1,107 @@
+package imghash
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+	"os"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+)
+
+/*
+This package provides perceptual image hashes that are robust to resizing and
+recompression, used to compare images by content rather than by bytes.
+*/
+
+// HashSize is the width and height of the grid the average hash is computed on
+const HashSize = 8
+
+// AverageHash computes the 64-bit average hash (aHash) of an image: the image is
+// reduced to an 8x8 grayscale grid and each bit records whether a cell is
+// brighter than the grid mean.
+func AverageHash(img image.Image) uint64 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return 0
+	}
+
+	// Box-filter the image down to the hash grid
+	var sums [HashSize * HashSize]float64
+	var counts [HashSize * HashSize]int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		cy := (y - bounds.Min.Y) * HashSize / height
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			cx := (x - bounds.Min.X) * HashSize / width
+			r, g, b, _ := img.At(x, y).RGBA()
+			lum := 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			sums[cy*HashSize+cx] += lum
+			counts[cy*HashSize+cx]++
+		}
+	}
+
+	var cells [HashSize * HashSize]float64
+	mean := 0.0
+	for i := range cells {
+		if counts[i] > 0 {
+			cells[i] = sums[i] / float64(counts[i])
+		}
+		mean += cells[i]
+	}
+	mean /= float64(len(cells))
+
+	var hash uint64
+	for i, v := range cells {
+		if v > mean {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// Distance returns the Hamming distance between two hashes (0 means identical)
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// HashFile decodes an image file and returns its average hash
+func HashFile(filePath string) (uint64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return AverageHash(img), nil
+}
+
+// Group assigns every hash to a group of near-duplicates. Two hashes belong to
+// the same group when their distance is at most threshold. The returned slice
+// holds, for each input, the index of its group's representative (the first
+// member seen), so representatives map to themselves.
+func Group(hashes []uint64, threshold int) []int {
+	representative := make([]int, len(hashes))
+	var leaders []int
+	for i, h := range hashes {
+		representative[i] = i
+		for _, leader := range leaders {
+			if Distance(h, hashes[leader]) <= threshold {
+				representative[i] = leader
+				break
+			}
+		}
+		if representative[i] == i {
+			leaders = append(leaders, i)
+		}
+	}
+	return representative
+}
@@ -0,0 +1,83 @@
+package imghash
+
+import (
+	"image"
+	"testing"
+
+	"DeSteGo/internal/fixtures"
+)
+
+// halve returns img scaled down by 2 in each direction, averaging each 2x2 square
+func halve(img *image.RGBA) *image.RGBA {
+	bounds := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, bounds.Dx()/2, bounds.Dy()/2))
+	for y := 0; y < out.Rect.Dy(); y++ {
+		for x := 0; x < out.Rect.Dx(); x++ {
+			for c := 0; c < 4; c++ {
+				sum := 0
+				for _, d := range [][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+					sum += int(img.Pix[img.PixOffset(2*x+d[0], 2*y+d[1])+c])
+				}
+				out.Pix[out.PixOffset(x, y)+c] = uint8(sum / 4)
+			}
+		}
+	}
+	return out
+}
+
+// checkerboard returns a width x height image of black and white squares of
+// the given side
+func checkerboard(width, height, side int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if (x/side+y/side)%2 == 0 {
+				copy(img.Pix[img.PixOffset(x, y):][:4], []uint8{255, 255, 255, 255})
+			} else {
+				img.Pix[img.PixOffset(x, y)+3] = 255
+			}
+		}
+	}
+	return img
+}
+
+func TestAverageHash(t *testing.T) {
+	img := fixtures.Carrier(128, 96, 1)
+	hash := AverageHash(img)
+
+	tests := []struct {
+		name        string
+		other       image.Image
+		maxDistance int // Largest expected distance, or -1 for unrelated images
+	}{
+		{"identical", fixtures.Carrier(128, 96, 1), 0},
+		{"different noise", fixtures.Carrier(128, 96, 2), 2},
+		{"resized", halve(img), 4},
+		{"unrelated", checkerboard(128, 96, 16), -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distance := Distance(hash, AverageHash(tt.other))
+			if tt.maxDistance < 0 {
+				if distance <= 16 {
+					t.Errorf("distance %d, want more than 16 for unrelated images", distance)
+				}
+				return
+			}
+			if distance > tt.maxDistance {
+				t.Errorf("distance %d, want at most %d", distance, tt.maxDistance)
+			}
+		})
+	}
+}
+
+func TestGroup(t *testing.T) {
+	hashes := []uint64{0, 0xFFFF, 1, 0xFFFE, 0xFFFF << 32}
+	want := []int{0, 1, 0, 1, 4}
+	got := Group(hashes, 2)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Group = %v, want %v", got, want)
+		}
+	}
+}
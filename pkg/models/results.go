@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 )
 
@@ -15,15 +16,18 @@ type AnalysisResult struct {
 	Findings          []Finding              `json:"findings"`
 	Recommendations   []string               `json:"recommendations"`
 	ExtractionHints   []ExtractionHint       `json:"extractionHints"`
+	Checks            []string               `json:"checks,omitempty"`         // Checks that ran and found nothing
+	DetectorScores    map[string]float64     `json:"detectorScores,omitempty"` // Probability each statistical detector gives, keyed by detector
 	AnalysisTime      time.Time              `json:"analysisTime"`
 	AnalysisDuration  time.Duration          `json:"analysisDuration"`
 }
 
 // Finding represents a specific detection or discovery during analysis
 type Finding struct {
-	Description string  `json:"description"`
-	Confidence  float64 `json:"confidence"` // 0.0-1.0
-	Details     string  `json:"details"`
+	Description string   `json:"description"`
+	Confidence  float64  `json:"confidence"` // 0.0-1.0
+	Severity    Severity `json:"severity"`
+	Details     string   `json:"details"`
 }
 
 // ExtractionHint provides guidance for data extraction
@@ -44,17 +48,148 @@ type ExtractionResult struct {
 	Details       map[string]interface{} `json:"details"`
 	OutputFiles   []string               `json:"outputFiles"` // Paths to any saved output files
 	MimeType      string                 `json:"mimeType"`
+	Alternates    []*ExtractionResult    `json:"alternates,omitempty"` // Other payloads found in the same carrier, best first
 }
 
-// AddFinding adds a finding to the analysis result
+// AddFinding adds a finding to the analysis result, graded by its confidence
 func (r *AnalysisResult) AddFinding(description string, confidence float64, details string) {
+	r.AddFindingWithSeverity(description, SeverityFromScore(confidence), confidence, details)
+}
+
+// AddFindingWithSeverity adds a finding whose severity is set explicitly
+func (r *AnalysisResult) AddFindingWithSeverity(description string, severity Severity, confidence float64, details string) {
 	r.Findings = append(r.Findings, Finding{
 		Description: description,
 		Confidence:  confidence,
+		Severity:    severity,
 		Details:     details,
 	})
 }
 
+// Severity returns the severity of the detection score, or Confirmed when any
+// finding is confirmed
+func (r *AnalysisResult) Severity() Severity {
+	for _, finding := range r.Findings {
+		if finding.Severity == SeverityConfirmed {
+			return SeverityConfirmed
+		}
+	}
+	return SeverityFromScore(r.DetectionScore)
+}
+
+// AddCheck records a check that ran without finding anything, such as
+// "no data after EOI". The checks explain a clean result.
+func (r *AnalysisResult) AddCheck(description string) {
+	r.Checks = append(r.Checks, description)
+}
+
+// SetDetectorScore records the probability a detector gives that data is
+// embedded, keeping the higher one when the detector already has a score
+func (r *AnalysisResult) SetDetectorScore(detector string, probability float64) {
+	if r.DetectorScores == nil {
+		r.DetectorScores = map[string]float64{}
+	}
+	if current, ok := r.DetectorScores[detector]; !ok || probability > current {
+		r.DetectorScores[detector] = probability
+	}
+}
+
+// SetCleanRationale stores the passed checks in Details["clean_rationale"] when
+// the result is clean, so a negative result says what was ruled out
+func (r *AnalysisResult) SetCleanRationale() {
+	if r.Severity() != SeverityClean || len(r.Checks) == 0 {
+		return
+	}
+	if r.Details == nil {
+		r.Details = map[string]interface{}{}
+	}
+	r.Details["clean_rationale"] = strings.Join(r.Checks, ", ")
+}
+
+// Merge combines the results of another analysis of the same file into r:
+//   - findings are united, a finding whose description r already has keeping
+//     the higher confidence of the two
+//   - the detection score is the higher one, and the confidence comes from
+//     the result that scored higher
+//   - the possible algorithm comes from the result with the higher
+//     confidence, or from the other when r names no algorithm
+//   - recommendations and checks are concatenated without repeats
+//   - detector scores are united, keeping the higher of a detector's two
+//   - extraction hints are concatenated and details are united, keeping r's
+//     value for keys both have
+func (r *AnalysisResult) Merge(other *AnalysisResult) {
+	if other == nil {
+		return
+	}
+
+	index := make(map[string]int, len(r.Findings))
+	for i, finding := range r.Findings {
+		index[finding.Description] = i
+	}
+	for _, finding := range other.Findings {
+		i, ok := index[finding.Description]
+		if !ok {
+			index[finding.Description] = len(r.Findings)
+			r.Findings = append(r.Findings, finding)
+			continue
+		}
+		if finding.Confidence > r.Findings[i].Confidence {
+			r.Findings[i] = finding
+		}
+	}
+
+	if other.PossibleAlgorithm != "" && (r.PossibleAlgorithm == "" || other.Confidence > r.Confidence) {
+		r.PossibleAlgorithm = other.PossibleAlgorithm
+	}
+	if other.DetectionScore > r.DetectionScore {
+		r.DetectionScore = other.DetectionScore
+		r.Confidence = other.Confidence
+	}
+
+	r.Recommendations = appendUnique(r.Recommendations, other.Recommendations)
+	r.Checks = appendUnique(r.Checks, other.Checks)
+	r.ExtractionHints = append(r.ExtractionHints, other.ExtractionHints...)
+	for detector, probability := range other.DetectorScores {
+		r.SetDetectorScore(detector, probability)
+	}
+
+	if r.Details == nil && len(other.Details) > 0 {
+		r.Details = make(map[string]interface{}, len(other.Details))
+	}
+	for key, value := range other.Details {
+		if _, ok := r.Details[key]; !ok {
+			r.Details[key] = value
+		}
+	}
+	if r.FileType == "" {
+		r.FileType = other.FileType
+	}
+	if r.Filename == "" {
+		r.Filename = other.Filename
+	}
+
+	// The rationale of either side no longer describes the merged result
+	if _, ok := r.Details["clean_rationale"]; ok {
+		delete(r.Details, "clean_rationale")
+		r.SetCleanRationale()
+	}
+}
+
+// appendUnique appends the strings of add that list does not already hold
+func appendUnique(list, add []string) []string {
+	seen := make(map[string]bool, len(list))
+	for _, s := range list {
+		seen[s] = true
+	}
+	for _, s := range add {
+		if !seen[s] {
+			seen[s] = true
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
 // AddExtractionHint adds an extraction hint to the analysis result
 func (r *AnalysisResult) AddExtractionHint(algorithm string, confidence float64, parameters map[string]interface{}) {
 	r.ExtractionHints = append(r.ExtractionHints, ExtractionHint{
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	r := &AnalysisResult{
+		FileType:          "png",
+		DetectionScore:    0.4,
+		Confidence:        0.6,
+		PossibleAlgorithm: "LSB Replacement",
+		Findings: []Finding{
+			{Description: "Anomalous LSB distribution", Confidence: 0.4, Severity: SeverityLow, Details: "first"},
+			{Description: "Text chunk present", Confidence: 0.2, Severity: SeverityClean},
+		},
+		Recommendations: []string{"Extract LSB data", "Check the text chunks"},
+		Checks:          []string{"no data after IEND"},
+		DetectorScores:  map[string]float64{"chi_square": 0.4, "rs": 0.7},
+		ExtractionHints: []ExtractionHint{{Algorithm: "lsb", Confidence: 0.4}},
+		Details:         map[string]interface{}{"width": 100, "mode": "first"},
+	}
+	other := &AnalysisResult{
+		Filename:          "image.png",
+		DetectionScore:    0.8,
+		Confidence:        0.9,
+		PossibleAlgorithm: "LSB Matching",
+		Findings: []Finding{
+			{Description: "Anomalous LSB distribution", Confidence: 0.8, Severity: SeverityMedium, Details: "second"},
+			{Description: "Text chunk present", Confidence: 0.1, Severity: SeverityClean, Details: "lower"},
+			{Description: "Sample pair asymmetry", Confidence: 0.7, Severity: SeverityMedium},
+		},
+		Recommendations: []string{"Check the text chunks", "Run a sample pair analysis"},
+		Checks:          []string{"no data after IEND", "palette in order"},
+		DetectorScores:  map[string]float64{"chi_square": 0.9, "rs": 0.3, "spa": 0.6},
+		ExtractionHints: []ExtractionHint{{Algorithm: "lsb-matching", Confidence: 0.8}},
+		Details:         map[string]interface{}{"mode": "second", "height": 50},
+	}
+	r.Merge(other)
+
+	var descriptions []string
+	for _, f := range r.Findings {
+		descriptions = append(descriptions, f.Description)
+	}
+	if want := []string{"Anomalous LSB distribution", "Text chunk present", "Sample pair asymmetry"}; !slices.Equal(descriptions, want) {
+		t.Errorf("findings %v, want %v", descriptions, want)
+	}
+	// A repeated finding keeps the copy with the higher confidence
+	if f := r.Findings[0]; f.Confidence != 0.8 || f.Details != "second" || f.Severity != SeverityMedium {
+		t.Errorf("repeated finding is %+v, want the 0.8 copy", f)
+	}
+	if f := r.Findings[1]; f.Confidence != 0.2 || f.Details != "" {
+		t.Errorf("repeated finding is %+v, want the 0.2 copy", f)
+	}
+
+	if r.DetectionScore != 0.8 || r.Confidence != 0.9 || r.PossibleAlgorithm != "LSB Matching" {
+		t.Errorf("got score %v, confidence %v, algorithm %q; want those of the higher-scoring, more confident result",
+			r.DetectionScore, r.Confidence, r.PossibleAlgorithm)
+	}
+	if want := []string{"Extract LSB data", "Check the text chunks", "Run a sample pair analysis"}; !slices.Equal(r.Recommendations, want) {
+		t.Errorf("recommendations %v, want %v", r.Recommendations, want)
+	}
+	if want := []string{"no data after IEND", "palette in order"}; !slices.Equal(r.Checks, want) {
+		t.Errorf("checks %v, want %v", r.Checks, want)
+	}
+	wantScores := map[string]float64{"chi_square": 0.9, "rs": 0.7, "spa": 0.6}
+	for detector, p := range wantScores {
+		if r.DetectorScores[detector] != p {
+			t.Errorf("detector %s: %v, want %v", detector, r.DetectorScores[detector], p)
+		}
+	}
+	if len(r.ExtractionHints) != 2 {
+		t.Errorf("got %d extraction hints, want 2", len(r.ExtractionHints))
+	}
+	if r.Details["mode"] != "first" || r.Details["width"] != 100 || r.Details["height"] != 50 {
+		t.Errorf("details %v, want r's value for keys both have", r.Details)
+	}
+	if r.FileType != "png" || r.Filename != "image.png" {
+		t.Errorf("got file %q of type %q", r.Filename, r.FileType)
+	}
+}
+
+func TestMergeLowerScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		other     AnalysisResult
+		algorithm string
+	}{
+		{"more confident", AnalysisResult{DetectionScore: 0.3, Confidence: 0.95, PossibleAlgorithm: "JSteg"}, "JSteg"},
+		{"less confident", AnalysisResult{DetectionScore: 0.3, Confidence: 0.5, PossibleAlgorithm: "JSteg"}, "F5"},
+		{"no algorithm", AnalysisResult{DetectionScore: 0.3, Confidence: 0.95}, "F5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &AnalysisResult{DetectionScore: 0.7, Confidence: 0.8, PossibleAlgorithm: "F5"}
+			r.Merge(&tt.other)
+			// The score and its confidence stay r's own
+			if r.DetectionScore != 0.7 || r.Confidence != 0.8 || r.PossibleAlgorithm != tt.algorithm {
+				t.Errorf("got %v, %v, %q; want 0.7, 0.8, %q", r.DetectionScore, r.Confidence, r.PossibleAlgorithm, tt.algorithm)
+			}
+		})
+	}
+
+	// The other result's algorithm fills in when r names none
+	r := &AnalysisResult{DetectionScore: 0.7}
+	r.Merge(&AnalysisResult{DetectionScore: 0.3, PossibleAlgorithm: "JSteg"})
+	if r.PossibleAlgorithm != "JSteg" {
+		t.Errorf("algorithm %q, want JSteg", r.PossibleAlgorithm)
+	}
+
+	r.Merge(nil)
+	if r.DetectionScore != 0.7 {
+		t.Error("merging nil changed the result")
+	}
+}
+
+func TestMergeCleanRationale(t *testing.T) {
+	r := &AnalysisResult{Checks: []string{"no trailing data"}}
+	r.SetCleanRationale()
+	other := &AnalysisResult{DetectionScore: 0.9, Checks: []string{"palette in order"}}
+	r.Merge(other)
+	if _, ok := r.Details["clean_rationale"]; ok {
+		t.Errorf("a suspicious merged result kept the clean rationale %v", r.Details["clean_rationale"])
+	}
+
+	r = &AnalysisResult{Checks: []string{"no trailing data"}}
+	r.SetCleanRationale()
+	r.Merge(&AnalysisResult{Checks: []string{"palette in order"}})
+	if got := r.Details["clean_rationale"]; got != "no trailing data, palette in order" {
+		t.Errorf("clean rationale %q, want both checks", got)
+	}
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity grades a result or finding on the same scale everywhere it is
+// reported. Clean to High follow the detection score bands; Confirmed is only
+// ever set explicitly, for example when an extracted payload is verified.
+type Severity int
+
+// Severity levels in increasing order
+const (
+	SeverityClean Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityConfirmed
+)
+
+// Upper bounds of the detection score bands, exclusive of the next band
+const (
+	cleanMaxScore  = 0.2
+	lowMaxScore    = 0.5
+	mediumMaxScore = 0.8
+)
+
+var severityNames = []string{"clean", "low", "medium", "high", "confirmed"}
+
+// SeverityFromScore maps a 0.0-1.0 detection score or confidence to a severity.
+// Scores up to 0.2 are clean, up to 0.5 low, up to 0.8 medium and above that high.
+func SeverityFromScore(score float64) Severity {
+	switch {
+	case score > mediumMaxScore:
+		return SeverityHigh
+	case score > lowMaxScore:
+		return SeverityMedium
+	case score > cleanMaxScore:
+		return SeverityLow
+	default:
+		return SeverityClean
+	}
+}
+
+// Score returns a representative detection score for the severity, chosen so
+// that SeverityFromScore(s.Score()) == s for every level below Confirmed
+func (s Severity) Score() float64 {
+	switch s {
+	case SeverityLow:
+		return 0.35
+	case SeverityMedium:
+		return 0.65
+	case SeverityHigh:
+		return 0.9
+	case SeverityConfirmed:
+		return 1.0
+	default:
+		return 0
+	}
+}
+
+// String returns the lower-case name of the severity
+func (s Severity) String() string {
+	if s < SeverityClean || int(s) >= len(severityNames) {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity returns the severity with the given name, ignoring case
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if strings.EqualFold(name, n) {
+			return Severity(i), nil
+		}
+	}
+	return SeverityClean, fmt.Errorf("unknown severity %q", name)
+}
+
+// MarshalText encodes the severity by name
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a severity name
+func (s *Severity) UnmarshalText(text []byte) error {
+	parsed, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSeverityFromScore(t *testing.T) {
+	tests := []struct {
+		score float64
+		want  Severity
+	}{
+		{-0.1, SeverityClean},
+		{0, SeverityClean},
+		{0.2, SeverityClean},
+		{0.2001, SeverityLow},
+		{0.5, SeverityLow},
+		{0.5001, SeverityMedium},
+		{0.8, SeverityMedium},
+		{0.8001, SeverityHigh},
+		{1, SeverityHigh},
+		{1.5, SeverityHigh},
+	}
+	for _, tt := range tests {
+		if got := SeverityFromScore(tt.score); got != tt.want {
+			t.Errorf("SeverityFromScore(%v) = %v, want %v", tt.score, got, tt.want)
+		}
+	}
+}
+
+func TestSeverityScore(t *testing.T) {
+	for s := SeverityClean; s < SeverityConfirmed; s++ {
+		if got := SeverityFromScore(s.Score()); got != s {
+			t.Errorf("SeverityFromScore(%v.Score()) = %v, want %v", s, got, s)
+		}
+	}
+	// Confirmed is never derived from a score
+	if got := SeverityFromScore(SeverityConfirmed.Score()); got != SeverityHigh {
+		t.Errorf("SeverityFromScore(confirmed.Score()) = %v, want high", got)
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for s := SeverityClean; s <= SeverityConfirmed; s++ {
+		got, err := ParseSeverity(s.String())
+		if err != nil || got != s {
+			t.Errorf("ParseSeverity(%q) = %v, %v, want %v", s.String(), got, err, s)
+		}
+	}
+	if got, err := ParseSeverity("HIGH"); err != nil || got != SeverityHigh {
+		t.Errorf("ParseSeverity(\"HIGH\") = %v, %v, want high", got, err)
+	}
+	if _, err := ParseSeverity("critical"); err == nil {
+		t.Error("ParseSeverity(\"critical\") succeeded, want an error")
+	}
+	if got := Severity(7).String(); got != "severity(7)" {
+		t.Errorf("Severity(7).String() = %q, want \"severity(7)\"", got)
+	}
+}
+
+func TestSeverityJSON(t *testing.T) {
+	finding := Finding{Description: "payload", Severity: SeverityConfirmed}
+	data, err := json.Marshal(finding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Finding
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Severity != SeverityConfirmed {
+		t.Errorf("got %v after a round trip through %s, want confirmed", decoded.Severity, data)
+	}
+	if err := json.Unmarshal([]byte(`{"severity":"bogus"}`), &decoded); err == nil {
+		t.Error("decoding an unknown severity succeeded, want an error")
+	}
+}
+
+func TestResultSeverity(t *testing.T) {
+	tests := []struct {
+		name     string
+		score    float64
+		findings []Finding
+		want     Severity
+	}{
+		{"clean", 0.1, nil, SeverityClean},
+		{"medium score", 0.7, []Finding{{Severity: SeverityHigh}}, SeverityMedium},
+		{"confirmed finding", 0.1, []Finding{{Severity: SeverityLow}, {Severity: SeverityConfirmed}}, SeverityConfirmed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &AnalysisResult{DetectionScore: tt.score, Findings: tt.findings}
+			if got := r.Severity(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddFinding(t *testing.T) {
+	r := &AnalysisResult{}
+	r.AddFinding("weak", 0.3, "")
+	r.AddFinding("strong", 0.95, "")
+	r.AddFindingWithSeverity("verified", SeverityConfirmed, 0.6, "")
+	want := []Severity{SeverityLow, SeverityHigh, SeverityConfirmed}
+	for i, finding := range r.Findings {
+		if finding.Severity != want[i] {
+			t.Errorf("finding %q: got %v, want %v", finding.Description, finding.Severity, want[i])
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"image"
+	"os"
+)
+
+/*
+This file contains the file source shared by the analyzers and extractors that
+look at the same file. A scan runs several analyzers, the QR scan, the heatmap
+and the extractors on each file, and each of them used to read and decode it
+again. A Source reads the file once and decodes it once; the helpers below fall
+back to the disk for a file the source does not hold, so that passing no source,
+or the source of another file, only costs the reads it saves.
+*/
+
+// Source is a file that is read and decoded at most once for everything that
+// looks at it. The bytes and image it returns are shared and must not be
+// modified.
+type Source interface {
+	// Path returns the path of the file
+	Path() string
+
+	// Bytes returns the contents of the file
+	Bytes() ([]byte, error)
+
+	// Image returns the decoded image, the name of its format and the repairs
+	// needed to decode it
+	Image() (img image.Image, format string, repairs []string, err error)
+}
+
+// ReadFile returns the contents of filePath from src when src holds that file,
+// and reads the file otherwise. src may be nil.
+func ReadFile(src Source, filePath string) ([]byte, error) {
+	if src != nil && src.Path() == filePath {
+		return src.Bytes()
+	}
+	return os.ReadFile(filePath)
+}
+
+// SourceImage returns the image src decoded for filePath and the repairs it
+// needed. It returns false when src is nil, holds another file, or did not
+// decode the file as format; an empty format accepts any format.
+func SourceImage(src Source, filePath, format string) (image.Image, []string, bool) {
+	if src == nil || src.Path() != filePath {
+		return nil, nil, false
+	}
+	img, decodedAs, repairs, err := src.Image()
+	if err != nil || (format != "" && decodedAs != format) {
+		return nil, nil, false
+	}
+	return img, repairs, true
+}
@@ -0,0 +1,277 @@
+package html
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"DeSteGo/pkg/filehandler"
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the HTML report, a single self-contained page for sharing the
+results of a scan. It has a summary table of every file, sortable by clicking a
+column header, and a section per file with its findings, recommendations,
+checks and links to the files extracted from it. Rows and sections are colored
+by severity. Entropy heatmaps written with -heatmap are embedded as data URIs,
+so the page can be moved without them; extracted files are linked relative to
+the report.
+*/
+
+// Options control what goes into a report
+type Options struct {
+	Title     string    // Page title (default: "DeSteGo report")
+	Version   string    // Tool version shown in the header
+	Generated time.Time // Time shown in the header (default: now)
+	BaseDir   string    // Directory links are made relative to, usually the report's
+}
+
+// fileView is one analyzed file as the template shows it
+type fileView struct {
+	ID       string
+	Result   models.AnalysisResult
+	Severity string
+	Files    []linkView
+	Heatmap  template.URL
+}
+
+// linkView is a link to a file written during the scan
+type linkView struct {
+	Label string
+	Href  string
+}
+
+// reportView is the data of the whole page
+type reportView struct {
+	Title     string
+	Version   string
+	Generated string
+	Counts    map[string]int
+	Files     []fileView
+}
+
+// Render writes an HTML report of results to w
+func Render(w io.Writer, results []models.AnalysisResult, options Options) error {
+	view := reportView{
+		Title:   options.Title,
+		Version: options.Version,
+		Counts:  map[string]int{},
+	}
+	if view.Title == "" {
+		view.Title = "DeSteGo report"
+	}
+	generated := options.Generated
+	if generated.IsZero() {
+		generated = time.Now()
+	}
+	view.Generated = generated.Format(time.RFC1123)
+
+	for i, result := range results {
+		severity := result.Severity().String()
+		view.Counts[severity]++
+		file := fileView{
+			ID:       fmt.Sprintf("file-%d", i+1),
+			Result:   result,
+			Severity: severity,
+			Files:    outputLinks(result.Details, options.BaseDir),
+		}
+		if path, ok := result.Details["heatmap_file"].(string); ok {
+			file.Heatmap = embedPNG(path)
+		}
+		view.Files = append(view.Files, file)
+	}
+
+	var buf bytes.Buffer
+	if err := reportTemplate.Execute(&buf, view); err != nil {
+		return fmt.Errorf("failed to render report: %w", err)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteFile writes an HTML report of results to path, linking files relative
+// to the report's directory
+func WriteFile(path string, results []models.AnalysisResult, options Options) error {
+	if options.BaseDir == "" {
+		options.BaseDir = filepath.Dir(path)
+	}
+	var buf bytes.Buffer
+	if err := Render(&buf, results, options); err != nil {
+		return err
+	}
+	return filehandler.SaveFile(buf.Bytes(), path)
+}
+
+// outputLinks returns links to the files recorded in a result's details:
+// extracted payloads and the artifacts analyzers save under "*_file" keys
+func outputLinks(details map[string]interface{}, baseDir string) []linkView {
+	var paths []string
+	switch files := details["extracted_files"].(type) {
+	case []string:
+		paths = append(paths, files...)
+	case []interface{}: // Results read back from the cache
+		for _, f := range files {
+			if s, ok := f.(string); ok {
+				paths = append(paths, s)
+			}
+		}
+	}
+	var keys []string
+	for key := range details {
+		if strings.HasSuffix(key, "_file") && key != "heatmap_file" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if s, ok := details[key].(string); ok {
+			paths = append(paths, s)
+		}
+	}
+
+	links := make([]linkView, 0, len(paths))
+	for _, path := range paths {
+		href := path
+		if baseDir != "" {
+			if rel, err := filepath.Rel(baseDir, path); err == nil {
+				href = rel
+			}
+		}
+		links = append(links, linkView{Label: filepath.Base(path), Href: filepath.ToSlash(href)})
+	}
+	return links
+}
+
+// embedPNG returns a PNG file as a data URI, or "" when it cannot be read
+func embedPNG(path string) template.URL {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(data))
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"score": func(f float64) string { return fmt.Sprintf("%.2f", f) },
+	"base":  filepath.Base,
+	"severity": func(f models.Finding) string {
+		return f.Severity.String()
+	},
+}).Parse(reportHTML))
+
+const reportHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #eee; cursor: pointer; user-select: none; }
+th.asc::after { content: " \25B2"; }
+th.desc::after { content: " \25BC"; }
+td.num { text-align: right; font-family: monospace; }
+.sev-clean { background: #e6f4ea; }
+.sev-low { background: #fff8e1; }
+.sev-medium { background: #ffe0b2; }
+.sev-high { background: #ffcdd2; }
+.sev-confirmed { background: #e57373; color: #fff; }
+.sev-confirmed a { color: #fff; }
+section { border-left: 6px solid #ccc; margin: 1.5em 0; padding: 0.2em 1em; }
+section.sev-clean { border-color: #34a853; }
+section.sev-low { border-color: #fbbc04; }
+section.sev-medium { border-color: #fb8c00; }
+section.sev-high { border-color: #e53935; }
+section.sev-confirmed { border-color: #b71c1c; }
+.details { color: #555; font-size: 0.9em; }
+img.heatmap { max-width: 256px; image-rendering: pixelated; border: 1px solid #ccc; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated {{.Generated}}{{if .Version}} by DeSteGo {{.Version}}{{end}}.
+{{len .Files}} files: {{index .Counts "clean"}} clean, {{index .Counts "low"}} low, {{index .Counts "medium"}} medium, {{index .Counts "high"}} high, {{index .Counts "confirmed"}} confirmed.</p>
+
+<table id="results">
+<thead><tr><th data-type="text">File</th><th data-type="text">Format</th><th data-type="num">Score</th><th data-type="text">Severity</th><th data-type="num">Confidence</th><th data-type="text">Possible algorithm</th><th data-type="num">Findings</th></tr></thead>
+<tbody>
+{{- range .Files}}
+<tr class="sev-{{.Severity}}"><td><a href="#{{.ID}}">{{.Result.Filename}}</a></td><td>{{.Result.FileType}}</td><td class="num">{{score .Result.DetectionScore}}</td><td>{{.Severity}}</td><td class="num">{{score .Result.Confidence}}</td><td>{{.Result.PossibleAlgorithm}}</td><td class="num">{{len .Result.Findings}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+
+{{range .Files}}
+<section id="{{.ID}}" class="sev-{{.Severity}}">
+<h2>{{base .Result.Filename}}</h2>
+<p>{{.Result.Filename}} ({{.Result.FileType}}): score {{score .Result.DetectionScore}}, {{.Severity}}, confidence {{score .Result.Confidence}}{{if .Result.PossibleAlgorithm}}, possibly {{.Result.PossibleAlgorithm}}{{end}}</p>
+{{- if .Result.Findings}}
+<h3>Findings</h3>
+<ol>
+{{- range .Result.Findings}}
+<li class="sev-{{severity .}}">{{.Description}} (confidence {{score .Confidence}}, {{severity .}}){{if .Details}}<div class="details">{{.Details}}</div>{{end}}</li>
+{{- end}}
+</ol>
+{{- end}}
+{{- if .Result.Recommendations}}
+<h3>Recommendations</h3>
+<ul>
+{{- range .Result.Recommendations}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Files}}
+<h3>Extracted files</h3>
+<ul>
+{{- range .Files}}
+<li><a href="{{.Href}}">{{.Label}}</a></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Result.Checks}}
+<h3>Checks passed</h3>
+<ul class="details">
+{{- range .Result.Checks}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Heatmap}}
+<h3>LSB entropy heatmap</h3>
+<img class="heatmap" src="{{.Heatmap}}" alt="LSB entropy heatmap of {{base .Result.Filename}}">
+{{- end}}
+</section>
+{{end}}
+
+<script>
+// Sort the table by the clicked column, toggling the direction
+document.querySelectorAll("#results th").forEach(function (th, column) {
+  th.addEventListener("click", function () {
+    var tbody = document.querySelector("#results tbody");
+    var ascending = !th.classList.contains("asc");
+    document.querySelectorAll("#results th").forEach(function (h) { h.classList.remove("asc", "desc"); });
+    th.classList.add(ascending ? "asc" : "desc");
+    var numeric = th.dataset.type === "num";
+    var rows = Array.prototype.slice.call(tbody.rows);
+    rows.sort(function (a, b) {
+      var x = a.cells[column].textContent, y = b.cells[column].textContent;
+      var order = numeric ? parseFloat(x) - parseFloat(y) : x.localeCompare(y);
+      return ascending ? order : -order;
+    });
+    rows.forEach(function (row) { tbody.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+`
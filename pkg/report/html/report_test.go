@@ -0,0 +1,100 @@
+package html
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"DeSteGo/pkg/models"
+)
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	heatmap := filepath.Join(dir, "b_heatmap.png")
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(heatmap, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	confirmed := models.AnalysisResult{Filename: "/scan/d.jpg", FileType: "jpeg", DetectionScore: 0.3}
+	confirmed.AddFindingWithSeverity("Extracted payload contains C2-style commands", models.SeverityConfirmed, 1, "")
+	results := []models.AnalysisResult{
+		{Filename: "/scan/a.png", FileType: "png", DetectionScore: 0.1, Checks: []string{"no data after IEND"}},
+		{Filename: "/scan/b.png", FileType: "png", DetectionScore: 0.9, Details: map[string]interface{}{
+			"heatmap_file":    heatmap,
+			"extracted_files": []string{filepath.Join(dir, "out", "b_lsb.txt")},
+		}},
+		{Filename: "/scan/c.gif", FileType: "gif", DetectionScore: 0.6},
+		confirmed,
+		{Filename: "/scan/<e>.bmp", FileType: "bmp", DetectionScore: 0.35},
+	}
+
+	var out bytes.Buffer
+	if err := Render(&out, results, Options{Generated: time.Unix(0, 0), BaseDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	page := out.String()
+
+	rows := regexp.MustCompile(`<tr class="sev-(\w+)"><td><a href="#file-\d+">([^<]*)</a>`).FindAllStringSubmatch(page, -1)
+	want := []struct{ severity, name string }{
+		{"clean", "/scan/a.png"},
+		{"high", "/scan/b.png"},
+		{"medium", "/scan/c.gif"},
+		{"confirmed", "/scan/d.jpg"},
+		{"low", "/scan/&lt;e&gt;.bmp"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(rows), len(want))
+	}
+	for i, row := range rows {
+		if row[1] != want[i].severity || row[2] != want[i].name {
+			t.Errorf("row %d: got %s %s, want %s %s", i, row[1], row[2], want[i].severity, want[i].name)
+		}
+		if !strings.Contains(page, fmt.Sprintf(`<section id="file-%d" class="sev-%s">`, i+1, want[i].severity)) {
+			t.Errorf("no %s section for %s", want[i].severity, want[i].name)
+		}
+	}
+
+	for _, s := range []string{
+		"5 files: 1 clean, 1 low, 1 medium, 1 high, 1 confirmed.",
+		`<a href="out/b_lsb.txt">b_lsb.txt</a>`,
+		`src="data:image/png;base64,`,
+		`<li class="sev-confirmed">Extracted payload contains C2-style commands`,
+		"<li>no data after IEND</li>",
+	} {
+		if !strings.Contains(page, s) {
+			t.Errorf("report does not contain %q", s)
+		}
+	}
+	if strings.Contains(page, "<e>") {
+		t.Error("file name is not escaped")
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.html")
+	results := []models.AnalysisResult{{Filename: "a.png", Details: map[string]interface{}{"idat_trailing_file": filepath.Join(dir, "a_idat_trailing.bin")}}}
+	if err := WriteFile(path, results, Options{Title: "Gallery triage"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"<title>Gallery triage</title>", `<a href="a_idat_trailing.bin">`} {
+		if !bytes.Contains(data, []byte(s)) {
+			t.Errorf("report does not contain %q", s)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package rules
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"DeSteGo/pkg/models"
+)
+
+/*
+This file contains the indicator rule engine used to classify extracted payloads.
+A rule matches either a regular expression or a plain substring and carries a
+weight (0-1) and a severity. The built-in rules are embedded from
+default_rules.json; analysts can load their own rules from a JSON file of the
+same format. Rules loaded from a file are added to the built-in set, and a rule
+with the ID of a built-in rule replaces it.
+*/
+
+// maxSampleLength bounds the matched text quoted in findings
+const maxSampleLength = 60
+
+//go:embed default_rules.json
+var defaultRules []byte
+
+// Rule is one indicator definition
+type Rule struct {
+	ID          string          `json:"id"`
+	Description string          `json:"description"`
+	Regex       string          `json:"regex,omitempty"`     // Regular expression (RE2 syntax)
+	Substring   string          `json:"substring,omitempty"` // Plain text, used when Regex is empty
+	IgnoreCase  bool            `json:"ignoreCase,omitempty"`
+	Weight      float64         `json:"weight"`   // 0.0-1.0 contribution to the detection score
+	Severity    models.Severity `json:"severity"` // low, medium, high or confirmed; medium when omitted
+
+	re *regexp.Regexp
+}
+
+// Hit is a rule that matched a payload
+type Hit struct {
+	Rule   *Rule
+	Count  int    // Number of non-overlapping matches
+	Sample string // First matched text, shortened for display
+}
+
+// RuleSet is an ordered collection of compiled rules
+type RuleSet struct {
+	rules []*Rule
+}
+
+// Default returns the built-in rule set
+func Default() *RuleSet {
+	set, err := parseRules(defaultRules)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in rules: %v", err))
+	}
+	return set
+}
+
+// Load returns the built-in rules extended with the rules in the JSON file at path
+func Load(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	custom, err := parseRules(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rules from %s: %w", path, err)
+	}
+
+	set := Default()
+	for _, rule := range custom.rules {
+		set.add(rule)
+	}
+	return set, nil
+}
+
+// parseRules decodes and compiles a JSON array of rules
+func parseRules(data []byte) (*RuleSet, error) {
+	var list []*Rule
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse rules: %w", err)
+	}
+
+	set := &RuleSet{}
+	seen := make(map[string]bool)
+	for i, rule := range list {
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i+1, rule.ID, err)
+		}
+		if seen[rule.ID] {
+			return nil, fmt.Errorf("duplicate rule id %q", rule.ID)
+		}
+		seen[rule.ID] = true
+		set.rules = append(set.rules, rule)
+	}
+	return set, nil
+}
+
+// compile validates a rule and prepares its matcher
+func (r *Rule) compile() error {
+	if r.ID == "" {
+		return errors.New("missing id")
+	}
+	if r.Weight < 0 || r.Weight > 1 {
+		return fmt.Errorf("weight %.2f is outside 0-1", r.Weight)
+	}
+	if r.Severity == models.SeverityClean {
+		r.Severity = models.SeverityMedium // A rule that matches is never clean
+	}
+
+	switch {
+	case r.Regex != "":
+		pattern := r.Regex
+		if r.IgnoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+		r.re = re
+	case r.Substring == "":
+		return errors.New("rule needs a regex or a substring")
+	}
+	return nil
+}
+
+// add appends rule, replacing any rule with the same ID
+func (s *RuleSet) add(rule *Rule) {
+	for i, existing := range s.rules {
+		if existing.ID == rule.ID {
+			s.rules[i] = rule
+			return
+		}
+	}
+	s.rules = append(s.rules, rule)
+}
+
+// Rules returns the rules in evaluation order
+func (s *RuleSet) Rules() []*Rule {
+	return s.rules
+}
+
+// Evaluate returns the rules that match data, in rule order
+func (s *RuleSet) Evaluate(data []byte) []Hit {
+	var hits []Hit
+	for _, rule := range s.rules {
+		if hit, ok := rule.match(data); ok {
+			hits = append(hits, hit)
+		}
+	}
+	return hits
+}
+
+// match runs one rule against data
+func (r *Rule) match(data []byte) (Hit, bool) {
+	if r.re != nil {
+		found := r.re.FindAll(data, -1)
+		if len(found) == 0 {
+			return Hit{}, false
+		}
+		return Hit{Rule: r, Count: len(found), Sample: shorten(found[0])}, true
+	}
+
+	haystack, needle := data, []byte(r.Substring)
+	if r.IgnoreCase {
+		haystack, needle = bytes.ToLower(data), bytes.ToLower(needle)
+	}
+	count := bytes.Count(haystack, needle)
+	if count == 0 {
+		return Hit{}, false
+	}
+	i := bytes.Index(haystack, needle)
+	if len(haystack) == len(data) {
+		haystack = data // Quote the original case unless lowering changed the offsets
+	}
+	return Hit{Rule: r, Count: count, Sample: shorten(haystack[i : i+len(needle)])}, true
+}
+
+// Score combines the weights of hits as independent pieces of evidence
+func Score(hits []Hit) float64 {
+	clean := 1.0
+	for _, hit := range hits {
+		clean *= 1 - hit.Rule.Weight
+	}
+	return 1 - clean
+}
+
+// Apply adds a finding for each hit to result and raises its detection score to
+// the combined rule score
+func Apply(hits []Hit, source string, result *models.AnalysisResult) {
+	for _, hit := range hits {
+		result.AddFindingWithSeverity(fmt.Sprintf("Rule %s matched the %s", hit.Rule.ID, source), hit.Rule.Severity, hit.Rule.Weight,
+			fmt.Sprintf("%s (%s severity, %d matches, e.g. %q)", hit.Rule.Description, hit.Rule.Severity, hit.Count, hit.Sample))
+	}
+	if score := Score(hits); score > result.DetectionScore {
+		result.DetectionScore = score
+	}
+}
+
+// IDs returns the IDs of the rules behind hits
+func IDs(hits []Hit) []string {
+	ids := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.Rule.ID)
+	}
+	return ids
+}
+
+// shorten returns match as display text of at most maxSampleLength characters
+func shorten(match []byte) string {
+	text := strings.ToValidUTF8(string(match), "?")
+	if len(text) > maxSampleLength {
+		text = text[:maxSampleLength] + "..."
+	}
+	return text
+}
@@ -0,0 +1,78 @@
+package toolexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*
+This file contains the wrapper every external program is run through: the
+optional tools the doctor checks and the analyzer plugins. A program that hangs,
+such as steghide waiting for a passphrase on a terminal it does not have, would
+otherwise block the analysis forever. Run gives each program a deadline, kills
+it when the deadline passes and captures stdout and stderr separately so a
+failure can be reported with what the program said. Output pipes are closed a
+moment after the kill, since a killed program's children can keep them open and
+stop Wait from returning.
+*/
+
+// pipeGrace is how long the output pipes stay open after a program is killed
+const pipeGrace = 2 * time.Second
+
+// Output is what a program wrote
+type Output struct {
+	Stdout []byte
+	Stderr []byte
+}
+
+// Message returns the first line of stderr, which is where most programs say
+// why they failed
+func (o Output) Message() string {
+	message, _, _ := strings.Cut(strings.TrimSpace(string(o.Stderr)), "\n")
+	return message
+}
+
+// Run runs the program name with args, giving it stdin when not nil, and
+// returns what it wrote. The program is killed when ctx is done or, when
+// timeout is positive, once timeout has passed. The output is returned with the
+// error too, since a program that fails or times out often explains itself on
+// stderr. A timeout error wraps context.DeadlineExceeded.
+func Run(ctx context.Context, timeout time.Duration, stdin []byte, name string, args ...string) (Output, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = pipeGrace
+
+	err := cmd.Run()
+	out := Output{Stdout: stdout.Bytes(), Stderr: stderr.Bytes()}
+	if err == nil {
+		return out, nil
+	}
+	program := filepath.Base(name)
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded) && timeout > 0:
+		return out, fmt.Errorf("%s timed out after %s: %w", program, timeout, ctx.Err())
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return out, fmt.Errorf("%s timed out: %w", program, ctx.Err())
+	case ctx.Err() != nil:
+		return out, fmt.Errorf("%s was stopped: %w", program, ctx.Err())
+	default:
+		return out, fmt.Errorf("%s failed: %w", program, err)
+	}
+}
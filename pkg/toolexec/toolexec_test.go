@@ -0,0 +1,105 @@
+package toolexec
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// needShell skips tests that run shell commands where there is no Unix shell
+func needShell(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a Unix shell")
+	}
+}
+
+func TestRun(t *testing.T) {
+	needShell(t)
+	tests := []struct {
+		name    string
+		script  string
+		stdin   []byte
+		stdout  string
+		message string
+		failed  bool
+	}{
+		{"output", "echo out; echo err >&2", nil, "out\n", "err", false},
+		{"stdin", "tr a-z A-Z", []byte("hidden"), "HIDDEN", "", false},
+		{"failure", "echo partial; echo 'bad input' >&2; echo more >&2; exit 3", nil, "partial\n", "bad input", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Run(context.Background(), time.Minute, tt.stdin, "sh", "-c", tt.script)
+			if (err != nil) != tt.failed {
+				t.Fatalf("got error %v, want failure %v", err, tt.failed)
+			}
+			if string(out.Stdout) != tt.stdout || out.Message() != tt.message {
+				t.Errorf("got stdout %q and message %q, want %q and %q", out.Stdout, out.Message(), tt.stdout, tt.message)
+			}
+			var exitErr *exec.ExitError
+			if tt.failed && (!errors.As(err, &exitErr) || exitErr.ExitCode() != 3 || !strings.HasPrefix(err.Error(), "sh failed")) {
+				t.Errorf("got error %v, want exit status 3", err)
+			}
+		})
+	}
+
+	if _, err := Run(context.Background(), 0, nil, "destego-no-such-program"); err == nil {
+		t.Error("running a missing program succeeded")
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	needShell(t)
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{"slow command", "echo started; exec sleep 30"},
+		// The child holds stdout open after the shell is killed
+		{"child holding output", "echo started; sleep 30 & wait"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			out, err := Run(context.Background(), 200*time.Millisecond, nil, "sh", "-c", tt.script)
+			elapsed := time.Since(start)
+
+			if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "timed out after 200ms") {
+				t.Errorf("got error %v, want a timeout", err)
+			}
+			if elapsed > 200*time.Millisecond+pipeGrace+time.Second {
+				t.Errorf("returned after %s", elapsed)
+			}
+			if string(out.Stdout) != "started\n" {
+				t.Errorf("got stdout %q, want the output written before the timeout", out.Stdout)
+			}
+		})
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	needShell(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := Run(ctx, time.Minute, nil, "sleep", "30")
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "sleep was stopped") {
+		t.Errorf("got error %v, want the program stopped", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("returned after %s", elapsed)
+	}
+
+	// A deadline of the caller's context is reported as a timeout too
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := Run(ctx, 0, nil, "sleep", "30"); !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "sleep timed out") {
+		t.Errorf("got error %v, want a timeout", err)
+	}
+}
@@ -0,0 +1,52 @@
+#!/bin/sh
+# Compares two runs of the benchmarks (go test -bench output) and fails when a
+# benchmark got slower than the baseline by more than BENCH_TOLERANCE (a
+# fraction, default 0.25). The fastest ns/op of each benchmark is compared, so
+# running with -count 5 or more smooths out noise. Benchmarks missing from
+# either run are listed but do not fail the check.
+#
+# Usage: scripts/benchcheck.sh baseline.txt current.txt
+
+set -eu
+
+if [ $# -ne 2 ]; then
+	echo "usage: $0 baseline.txt current.txt" >&2
+	exit 2
+fi
+
+awk -v tolerance="${BENCH_TOLERANCE:-0.25}" '
+# Keep the fastest ns/op of each benchmark, keyed by package and name
+$1 == "pkg:" { pkg = $2; next }
+/^Benchmark/ {
+	for (i = 3; i < NF; i++) {
+		if ($(i + 1) == "ns/op") {
+			key = pkg " " $1
+			if (FILENAME == ARGV[1]) {
+				if (!(key in base) || $i < base[key]) base[key] = $i
+			} else {
+				if (!(key in cur) || $i < cur[key]) cur[key] = $i
+			}
+		}
+	}
+}
+END {
+	failed = 0
+	for (key in cur) {
+		if (!(key in base)) {
+			printf "new       %s\n", key
+			continue
+		}
+		ratio = cur[key] / base[key]
+		status = "ok"
+		if (ratio > 1 + tolerance) {
+			status = "SLOWER"
+			failed = 1
+		}
+		printf "%-9s %s: %.0f -> %.0f ns/op (%+.1f%%)\n", status, key, base[key], cur[key], (ratio - 1) * 100
+	}
+	for (key in base) {
+		if (!(key in cur)) printf "missing   %s\n", key
+	}
+	exit failed
+}
+' "$1" "$2"